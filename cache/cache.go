@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics" // 指標註冊介面
+)
+
+// 緩存的資料類別 (key family)，每個類別以 "<family>:" 為 key 的前綴，資料變更時整個類別一起失效
+const (
+	FamilyMenus             = "menus"              // 選單與角色的選單 (GET /menus、/menus/:id、/roles/:roleID/menus)
+	FamilyRoles             = "roles"              // 角色 (GET /roles、/roles/:id)
+	FamilyProductCategories = "product_categories" // 產品類別與類別樹 (不含 include=counts 的結果)
+	FamilyPermissions       = "permissions"        // 權限目錄與角色的權限 (GET /permissions、/roles/:roleID/permissions)
+)
+
+// Store 定義緩存的儲存介面，value 為序列化後的內容
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error) // 不存在或已過期時返回 false
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	DeletePrefix(ctx context.Context, prefix string) error // 刪除以 prefix 開頭的所有 key，prefix 為空白時刪除全部
+}
+
+// Cache 定義 Service 讀取方法使用的 cache-aside 緩存：讀取時先查詢緩存，未命中時由 Service 查詢資料庫後寫入
+// 緩存失敗 (例如 Redis 無法連線) 只記錄日誌並視為未命中，不影響請求
+type Cache interface {
+	Get(ctx context.Context, family, key string, dest interface{}) bool // 命中時將內容解碼到 dest 並返回 true
+	Set(ctx context.Context, family, key string, value interface{})
+	Invalidate(ctx context.Context, families ...string) // 資料寫入成功後呼叫，使整個類別失效
+	Flush(ctx context.Context) error                    // 清除所有緩存 (POST /api/v1/admin/cache/flush)
+}
+
+// cacheImpl 實現 Cache 介面
+type cacheImpl struct {
+	store    Store
+	ttl      time.Duration
+	requests metrics.Counter
+}
+
+// NewCache 創建以 store 儲存的 Cache 實例，每個項目存活 ttl，並在 reg 註冊各類別的命中與未命中次數
+func NewCache(store Store, ttl time.Duration, reg metrics.Registry) Cache {
+	return &cacheImpl{
+		store:    store,
+		ttl:      ttl,
+		requests: reg.Counter("cache_requests_total", "Total read cache lookups by key family and result.", "family", "result"),
+	}
+}
+
+// Get 讀取緩存
+func (c *cacheImpl) Get(ctx context.Context, family, key string, dest interface{}) bool {
+	data, ok, err := c.store.Get(ctx, family+":"+key)
+	if err != nil {
+		zap.L().Warn("Failed to read cache entry", zap.String("family", family), zap.String("key", key), zap.Error(err))
+		c.requests.Inc(family, "error")
+		return false
+	}
+	if !ok {
+		c.requests.Inc(family, "miss")
+		return false
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		zap.L().Warn("Ignoring undecodable cache entry", zap.String("family", family), zap.String("key", key), zap.Error(err))
+		c.requests.Inc(family, "miss")
+		return false
+	}
+	c.requests.Inc(family, "hit")
+	return true
+}
+
+// Set 寫入緩存
+func (c *cacheImpl) Set(ctx context.Context, family, key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		zap.L().Warn("Failed to encode cache entry", zap.String("family", family), zap.String("key", key), zap.Error(err))
+		return
+	}
+	if err := c.store.Set(ctx, family+":"+key, data, c.ttl); err != nil {
+		zap.L().Warn("Failed to write cache entry", zap.String("family", family), zap.String("key", key), zap.Error(err))
+	}
+}
+
+// Invalidate 刪除 families 下的所有項目；失敗時記錄日誌，舊資料最多保留 ttl
+func (c *cacheImpl) Invalidate(ctx context.Context, families ...string) {
+	for _, family := range families {
+		if err := c.store.DeletePrefix(ctx, family+":"); err != nil {
+			zap.L().Error("Failed to invalidate cache family, stale entries expire after the TTL", zap.String("family", family), zap.Duration("ttl", c.ttl), zap.Error(err))
+		}
+	}
+}
+
+// Flush 清除所有緩存
+func (c *cacheImpl) Flush(ctx context.Context) error {
+	return c.store.DeletePrefix(ctx, "")
+}
+
+// disabledCache 實現 Cache 介面，不緩存任何內容 (CACHE_ENABLED=false)
+type disabledCache struct{}
+
+// NewDisabledCache 創建不緩存的 Cache 實例，每次讀取都查詢資料庫
+func NewDisabledCache() Cache {
+	return disabledCache{}
+}
+
+func (disabledCache) Get(context.Context, string, string, interface{}) bool { return false }
+func (disabledCache) Set(context.Context, string, string, interface{})      {}
+func (disabledCache) Invalidate(context.Context, ...string)                 {}
+func (disabledCache) Flush(context.Context) error                           { return nil }
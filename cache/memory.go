@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryMaxEntries 記憶體緩存的項目上限，已滿時先移除過期的項目，仍然已滿時移除任意一個項目
+const memoryMaxEntries = 10000
+
+// memoryEntry 記憶體緩存的項目
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// memoryStore 實現 Store 介面，項目只保存在本程序中 (多個實例各自緩存)
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStore 創建以記憶體儲存的 Store 實例
+func NewMemoryStore() Store {
+	return &memoryStore{entries: map[string]memoryEntry{}}
+}
+
+// Get 讀取項目，已過期的項目在此時移除
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set 寫入項目
+func (s *memoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.entries[key]; !exists && len(s.entries) >= memoryMaxEntries {
+		s.evictLocked()
+	}
+	s.entries[key] = memoryEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Delete 刪除項目
+func (s *memoryStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// DeletePrefix 刪除以 prefix 開頭的所有項目
+func (s *memoryStore) DeletePrefix(_ context.Context, prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
+
+// evictLocked 移除所有過期的項目，沒有過期的項目時移除任意一個，呼叫前需持有 s.mu
+func (s *memoryStore) evictLocked() {
+	now := time.Now()
+	evicted := false
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+			evicted = true
+		}
+	}
+	if evicted {
+		return
+	}
+	for key := range s.entries {
+		delete(s.entries, key)
+		return
+	}
+}
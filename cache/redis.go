@@ -0,0 +1,334 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis 連線的預設值
+const (
+	redisDefaultTimeout  = time.Second
+	redisDefaultPoolSize = 10
+	redisScanCount       = "500" // DeletePrefix 每次 SCAN 的建議數量
+)
+
+// RedisConfig Redis 緩存的連線設定
+type RedisConfig struct {
+	URL      string        // redis://[[user]:password@]host[:port][/db]，rediss:// 使用 TLS
+	Prefix   string        // 所有 key 的前綴，DeletePrefix 只會刪除此前綴下的 key
+	Timeout  time.Duration // 每個命令 (含建立連線) 的逾時，預設 1s
+	PoolSize int           // 保留的閒置連線上限，預設 10
+}
+
+// redisError Redis 返回的錯誤回應 (例如 WRONGTYPE)，連線本身仍可繼續使用
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// redisStore 實現 Store 介面，以 RESP 協定直接存取 Redis，多個實例共用同一份緩存
+type redisStore struct {
+	addr      string
+	username  string
+	password  string
+	db        int
+	tlsConfig *tls.Config // rediss:// 時非 nil
+	prefix    string
+	timeout   time.Duration
+	idle      chan *redisConn
+}
+
+// NewRedisStore 創建以 Redis 儲存的 Store 實例；URL 格式錯誤時返回錯誤，連線在第一次使用時建立
+func NewRedisStore(cfg RedisConfig) (Store, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis URL scheme %q: expected redis or rediss", u.Scheme)
+	}
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "6379"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = redisDefaultTimeout
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = redisDefaultPoolSize
+	}
+
+	s := &redisStore{
+		addr:    net.JoinHostPort(host, port),
+		prefix:  cfg.Prefix,
+		timeout: cfg.Timeout,
+		idle:    make(chan *redisConn, cfg.PoolSize),
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		s.db, err = strconv.Atoi(db)
+		if err != nil || s.db < 0 {
+			return nil, fmt.Errorf("invalid redis database %q in URL", db)
+		}
+	}
+	if u.Scheme == "rediss" {
+		s.tlsConfig = &tls.Config{ServerName: host}
+	}
+	return s, nil
+}
+
+// Get 讀取 key
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.do(ctx, "GET", s.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set 以 PX 設定存活時間寫入 key
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	_, err := s.do(ctx, "SET", s.prefix+key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+// Delete 刪除 keys
+func (s *redisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, key := range keys {
+		args = append(args, s.prefix+key)
+	}
+	_, err := s.do(ctx, args...)
+	return err
+}
+
+// DeletePrefix 以 SCAN 逐批找出以 prefix 開頭的 key 並刪除 (不使用 KEYS 或 FLUSHDB，不影響共用 Redis 的其他資料)
+func (s *redisStore) DeletePrefix(ctx context.Context, prefix string) error {
+	pattern := escapeRedisPattern(s.prefix+prefix) + "*"
+	cursor := "0"
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		reply, err := s.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", redisScanCount)
+		if err != nil {
+			return err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			return fmt.Errorf("redis: unexpected SCAN reply %v", reply)
+		}
+		next, _ := items[0].([]byte)
+		keys, _ := items[1].([]interface{})
+		if len(keys) > 0 {
+			args := make([]string, 0, len(keys)+1)
+			args = append(args, "DEL")
+			for _, key := range keys {
+				if b, ok := key.([]byte); ok {
+					args = append(args, string(b))
+				}
+			}
+			if _, err := s.do(ctx, args...); err != nil {
+				return err
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// escapeRedisPattern 跳脫 SCAN MATCH 的萬用字元，讓 prefix 依字面比對
+func escapeRedisPattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// do 以連接池中的連線執行命令；使用閒置連線時發生連線錯誤 (例如 Redis 已關閉閒置連線) 會以新的連線重試一次
+func (s *redisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	for attempt := 0; ; attempt++ {
+		var conn *redisConn
+		reused := false
+		select {
+		case conn = <-s.idle:
+			reused = true
+		default:
+			var err error
+			if conn, err = s.dial(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		reply, err := conn.do(ctx, s.timeout, args...)
+		var replyErr redisError
+		if err != nil && !errors.As(err, &replyErr) {
+			conn.close()
+			if reused && attempt == 0 && ctx.Err() == nil {
+				continue
+			}
+			return nil, err
+		}
+		s.release(conn)
+		return reply, err
+	}
+}
+
+// release 將連線放回連接池，已滿時關閉
+func (s *redisStore) release(conn *redisConn) {
+	select {
+	case s.idle <- conn:
+	default:
+		conn.close()
+	}
+}
+
+// dial 建立連線，依 URL 執行 AUTH 與 SELECT
+func (s *redisStore) dial(ctx context.Context) (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var netConn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", s.addr, err)
+	}
+	conn := &redisConn{conn: netConn, r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)}
+
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := conn.do(ctx, s.timeout, args...); err != nil {
+			conn.close()
+			return nil, fmt.Errorf("redis AUTH failed: %w", err)
+		}
+	}
+	if s.db != 0 {
+		if _, err := conn.do(ctx, s.timeout, "SELECT", strconv.Itoa(s.db)); err != nil {
+			conn.close()
+			return nil, fmt.Errorf("redis SELECT %d failed: %w", s.db, err)
+		}
+	}
+	return conn, nil
+}
+
+// redisConn 一條 Redis 連線
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// do 送出命令並讀取回應，逾時為 timeout 與 ctx 期限中較早者
+// 回應依 RESP 類型返回 string、int64、[]byte、[]interface{} 或 nil (不存在)，錯誤回應返回 redisError
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply 讀取一個 RESP 回應
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2) // 內容與結尾的 \r\n
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// close 關閉連線
+func (c *redisConn) close() {
+	c.conn.Close()
+}
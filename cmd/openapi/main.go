@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/wac0705/fastener-api/openapi" // 導入 OpenAPI 文件產生
+	"github.com/wac0705/fastener-api/routes"  // 導入路由定義
+)
+
+// 產生 OpenAPI 文件，不需要資料庫與設定檔：
+//
+//	go run ./cmd/openapi -o openapi.json   輸出文件
+//	go run ./cmd/openapi -check            只檢查，有路由沒有登記在 routes.APIDocs、routes.APIDocs 登記了不存在的路由，或 /api/v1 與 /api 別名的路由不一致時以非零狀態結束 (用於 CI)
+func main() {
+	output := flag.String("o", "", "output file (default stdout)")
+	check := flag.Bool("check", false, "only check that every registered route is documented and /api aliases match /api/v1")
+	version := flag.String("version", "dev", "version shown in the spec")
+	flag.Parse()
+
+	e := routes.NewRouteTable()
+
+	if missing := openapi.MissingRoutes(e.Routes(), routes.APIDocs); len(missing) > 0 {
+		log.Fatalf("Routes missing from the OpenAPI spec (add them to routes.APIDocs):\n  %s", strings.Join(missing, "\n  "))
+	}
+	if stale := openapi.StaleOperations(e.Routes(), routes.APIDocs); len(stale) > 0 {
+		log.Fatalf("OpenAPI spec documents routes that are not registered (remove them from routes.APIDocs):\n  %s", strings.Join(stale, "\n  "))
+	}
+	if mismatches := routes.LegacyAliasMismatches(e.Routes()); len(mismatches) > 0 {
+		log.Fatalf("Routes differ between %s and %s:\n  %s", routes.APIV1Prefix, routes.LegacyAPIPrefix, strings.Join(mismatches, "\n  "))
+	}
+	if *check {
+		fmt.Println("All routes are documented.")
+		return
+	}
+
+	spec, err := json.MarshalIndent(openapi.Build(openapi.Info{Title: routes.APITitle, Version: *version}, e.Routes(), routes.APIDocs), "", "  ")
+	if err != nil {
+		log.Fatalf("Error encoding OpenAPI spec: %v", err)
+	}
+	if *output == "" {
+		fmt.Println(string(spec))
+		return
+	}
+	if err := os.WriteFile(*output, append(spec, '\n'), 0o644); err != nil {
+		log.Fatalf("Error writing %s: %v", *output, err)
+	}
+}
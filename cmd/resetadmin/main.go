@@ -1,30 +1,43 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"go.uber.org/zap" // repository 與 seed 使用的日誌庫
+
 	"github.com/wac0705/fastener-api/config" // 導入配置模組
 	"github.com/wac0705/fastener-api/db"     // 導入資料庫模組
 	"github.com/wac0705/fastener-api/repository" // 導入 Repository 層
-	"github.com/wac0705/fastener-api/utils"  // 導入工具模組
+	"github.com/wac0705/fastener-api/seed"   // 導入種子資料 (共用重設管理員密碼的邏輯)
 )
 
 func main() {
+	noRetry := flag.Bool("no-retry", false, "fail immediately if the database is unavailable instead of retrying")
+	flag.Parse()
+
+	// logger 注入到 repository；資料庫連線以 zap.L() 記錄日誌，設定全局 logger 後才會輸出 (預設的全局 logger 不輸出任何內容)
+	logger := zap.Must(zap.NewDevelopment())
+	defer func() { _ = logger.Sync() }() // 寫入緩衝的日誌；stderr 的 Sync 可能返回錯誤，可忽略
+	zap.ReplaceGlobals(logger)
+
 	// 載入應用程式配置
-	config.LoadConfig()
+	if err := config.LoadConfig(); err != nil {
+		log.Fatal(err)
+	}
 
-	// 初始化資料庫連接
-	db.InitDB(config.Cfg.DatabaseURL)
+	// 初始化資料庫連接，-no-retry 時只嘗試一次
+	pool := config.Cfg.DBPoolConfig()
+	if *noRetry {
+		pool.ConnectAttempts = 1
+	}
+	db.InitDB(config.Cfg.DatabaseURL, "", pool) // 只寫入主庫，不使用唯讀副本
 	defer func() {
-		sqlDB, err := db.DB.DB()
-		if err != nil {
-			log.Printf("Error getting underlying SQL DB for resetadmin: %v\n", err)
-		} else if sqlDB != nil {
-			if err := sqlDB.Close(); err != nil {
-				log.Printf("Error closing database for resetadmin: %v\n", err)
-			}
+		if err := db.DB.Close(); err != nil {
+			log.Printf("Error closing database for resetadmin: %v\n", err)
 		}
 	}()
 
@@ -37,19 +50,11 @@ func main() {
 	}
 
 	// 創建 Account Repository 實例
-	accountRepo := repository.NewAccountRepository(db.DB)
-
-	// 雜湊新密碼
-	hashedPassword, err := utils.HashPassword(adminPassword)
-	if err != nil {
-		log.Fatalf("Error hashing password: %v", err)
-	}
+	accountRepo := repository.NewAccountRepository(db.DB, logger)
 
-	// 更新資料庫中的管理員密碼
-	// 假設有一個方法可以直接更新指定用戶名的密碼，且只針對 'admin' 角色
-	err = accountRepo.UpdateAdminPassword(adminUsername, hashedPassword)
-	if err != nil {
-		log.Fatalf("Error updating admin password for '%s': %v", adminUsername, err)
+	// 雜湊新密碼並更新資料庫中的管理員密碼 (只針對 'admin' 角色)
+	if err := seed.ResetAdminPassword(context.Background(), accountRepo, adminUsername, adminPassword); err != nil {
+		log.Fatal(err)
 	}
 
 	fmt.Printf("Admin account '%s' password reset successfully.\n", adminUsername)
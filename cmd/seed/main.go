@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"go.uber.org/zap" // repository 與 seed 使用的日誌庫
+
+	"github.com/wac0705/fastener-api/config"           // 導入配置模組
+	"github.com/wac0705/fastener-api/db"               // 導入資料庫模組
+	"github.com/wac0705/fastener-api/middleware/authz" // 導入路由登記的權限
+	"github.com/wac0705/fastener-api/repository"       // 導入 Repository 層
+	"github.com/wac0705/fastener-api/routes"           // 導入路由定義
+	"github.com/wac0705/fastener-api/seed"             // 導入種子資料
+)
+
+// 建立角色、權限、選單與 admin 的授權，可重複執行 (已存在的資料不會被修改)：
+//
+//	go run ./cmd/seed              寫入種子資料
+//	go run ./cmd/seed -dry-run     只列出會新增的資料，不寫入資料庫
+//	go run ./cmd/seed -admin       另外以 ADMIN_USERNAME / ADMIN_PASSWORD 建立管理員帳戶 (已存在時重設密碼)
+//	go run ./cmd/seed -no-retry    資料庫無法連線時立即失敗，不依 DB_CONNECT_ATTEMPTS 重試
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would be created without writing to the database")
+	withAdmin := flag.Bool("admin", false, "also create the admin account from ADMIN_USERNAME and ADMIN_PASSWORD (resets the password if it exists)")
+	noRetry := flag.Bool("no-retry", false, "fail immediately if the database is unavailable instead of retrying")
+	flag.Parse()
+
+	// logger 注入到 repository；資料庫連線以 zap.L() 記錄日誌，設定全局 logger 後才會輸出 (預設的全局 logger 不輸出任何內容)
+	logger := zap.Must(zap.NewDevelopment())
+	defer func() { _ = logger.Sync() }() // 寫入緩衝的日誌；stderr 的 Sync 可能返回錯誤，可忽略
+	zap.ReplaceGlobals(logger)
+
+	// 載入應用程式配置
+	if err := config.LoadConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	// 以零值的 handler 註冊路由，取得所有路由登記的權限
+	routes.NewRouteTable()
+	plan := seed.DefaultPlan(authz.RegisteredPermissions())
+
+	if *withAdmin {
+		if config.Cfg.AdminUsername == "" || config.Cfg.AdminPassword == "" {
+			log.Fatal("ADMIN_USERNAME and ADMIN_PASSWORD environment variables must be set in .env or environment for seed -admin.")
+		}
+		plan.Admin = &seed.AdminAccount{Username: config.Cfg.AdminUsername, Password: config.Cfg.AdminPassword}
+	}
+
+	// 初始化資料庫連接，-no-retry 時只嘗試一次
+	pool := config.Cfg.DBPoolConfig()
+	if *noRetry {
+		pool.ConnectAttempts = 1
+	}
+	db.InitDB(config.Cfg.DatabaseURL, "", pool) // 只寫入主庫，不使用唯讀副本
+	defer func() {
+		if err := db.DB.Close(); err != nil {
+			log.Printf("Error closing database for seed: %v\n", err)
+		}
+	}()
+
+	summary, err := seed.Run(context.Background(), db.DB, repository.NewAccountRepository(db.DB, logger), plan, *dryRun, logger)
+	if summary != nil {
+		printSummary(summary)
+	}
+	if err != nil {
+		log.Fatalf("Error seeding database: %v", err)
+	}
+}
+
+// printSummary 輸出每個資料表新建立與已存在的筆數
+func printSummary(summary *seed.Summary) {
+	if summary.DryRun {
+		fmt.Println("Dry run: no changes were written. Rows that would be created:")
+	} else {
+		fmt.Println("Seed completed:")
+	}
+	for _, result := range summary.Results {
+		fmt.Printf("  %-18s created %4d  existing %4d\n", result.Table, result.Created, result.Existing)
+	}
+	if summary.AdminReset {
+		fmt.Println("Existing admin account password reset.")
+	}
+}
@@ -5,29 +5,122 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"go.uber.org/zap/zapcore"
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/tracing"
 )
 
 // AppConfig 應用程式的配置結構
 type AppConfig struct {
 	Port                string
+	TLSCertFile         string   // 同時設定 TLSKeyFile 時以 HTTPS 提供服務 (PEM 格式)
+	TLSKeyFile          string
+	TLSAutocertDomains  []string // 設定時以 Let's Encrypt 自動申請憑證，只接受列出的網域；不可與 TLSCertFile 同時使用
+	TLSAutocertCacheDir string   // 自動申請的憑證的快取目錄，避免每次啟動重新申請
+	TLSAutocertEmail    string   // 提供給 Let's Encrypt 的聯絡信箱 (選填)
+	HTTPRedirectPort    string   // 啟用 TLS 時另外監聽的 HTTP 端口，將請求轉址到 HTTPS；空白時不監聽
 	DatabaseURL         string
+	DatabaseReadURL     string        // 唯讀副本的連接字串 (選填)，設定時客戶、公司與產品的列表及依 ID 查詢從副本讀取
+	DBMaxOpenConns      int           // 資料庫連接池的最大打開連接數，0 表示不限制
+	DBMaxIdleConns      int           // 資料庫連接池的最大閒置連接數，不可超過 DBMaxOpenConns
+	DBConnMaxLifetime   time.Duration // 連接最長生命週期，0 表示不限制
+	DBConnMaxIdleTime   time.Duration // 連接閒置多久後被回收，0 表示不限制
+	DBPingTimeout       time.Duration // 啟動時每次測試資料庫連接的逾時時間
+	DBConnectAttempts   int           // 啟動時資料庫連接失敗的最多嘗試次數，1 表示不重試
+	DBConnectBackoff    time.Duration // 第一次重試前的等待時間，之後每次加倍直到 DBConnectMaxBackoff
+	DBConnectMaxBackoff time.Duration
+	TxMaxRetries        int           // 事務因序列化失敗或死結中止時的最多重試次數，0 表示不重試
+	TxRetryBackoff      time.Duration // 第一次重試事務前的等待時間，之後每次加倍
+	SlowQueryThreshold  time.Duration // 資料庫查詢超過此耗時時以 WARN 記錄 (SLOW_QUERY_MS)，0 表示不記錄
+	DBStatementCacheCapacity int // 每個資料庫連接快取的 prepared statement 數量，0 表示不快取 (經過 PgBouncer transaction pooling 時)
 	JwtSecret           string
 	JwtAccessExpiresHours  int
 	JwtRefreshExpiresHours int
-	CorsAllowOrigin     string
+	CorsAllowOrigins    []string // 允許的 CORS 來源，可包含子網域樣式 (例如 https://*.example.com)，或只有 "*"
+	CorsAllowCredentials bool    // 是否允許跨來源請求帶上 Cookie 與 Authorization (Access-Control-Allow-Credentials)
 	AdminUsername       string
 	AdminPassword       string
 	AppEnv              string
 	LogLevel            string
+	MaxPageSize         int // 列表 API 的 page_size 上限
+	DefaultPageSize     int // 列表 API 未指定 page_size 時的每頁筆數
+	MaxExportRows       int // 單次 CSV 匯出的最大筆數
+	PaymentTerms        []string // 客戶可用的付款條件，例如 NET30、NET60
+	DefaultPhoneCountry string   // 本地格式電話號碼預設的國家 (ISO 3166-1 alpha-2)，用於轉換為 E.164
+	CustomerDuplicateThreshold float64 // 客戶重複檢查時名稱相似度 (pg_trgm) 的最低分數，介於 0 到 1
+	CustomerCodePrefix  string   // 自動產生客戶代碼的前綴，例如 "C" 產生 "C-000123"
+	ProductStandardBodies []string // 產品標準代號允許的標準組織，例如 DIN、ISO、ANSI
+	ProductBaseCurrency string   // 產品定義 price 欄位的幣別 (ISO 4217)，其他幣別價格另存於 product_prices
+	PriceScale          int32    // 計算後的價格 (報價、數量總價) 捨入的小數位數，最多 4 位
+	PriceRoundingMode   decimal.RoundingMode // 計算後的價格的捨入方式：half_up、half_even、down 或 up
+	ProductImageMaxBytes int64   // 產品圖片上傳的大小上限 (bytes)
+	ProductImageTypes   []string // 允許上傳的產品圖片格式 (MIME type)
+	FileStoreDriver     string   // 上傳檔案的儲存方式：local 或 s3
+	FileStoreLocalDir   string   // FileStoreDriver 為 local 時的儲存目錄
+	FileStoreS3Endpoint string   // 以下為 FileStoreDriver 為 s3 時的 S3 相容物件儲存設定
+	FileStoreS3Region   string
+	FileStoreS3Bucket   string
+	FileStoreS3AccessKeyID     string
+	FileStoreS3SecretAccessKey string
+	LivenessTimeout     time.Duration // /livez 的逾時時間
+	ReadinessTimeout    time.Duration // /readyz 所有檢查合計的逾時時間
+	RequestTimeout      time.Duration // 每個請求的逾時時間，逾時後取消進行中的資料庫查詢
+	MaxBodyBytes        int64         // 請求內容的大小上限 (bytes)，上傳路由除外
+	UploadMaxBodyBytes  int64         // 檔案上傳路由 (匯入、產品圖片) 的請求內容大小上限 (bytes)
+	ImportMaxFileBytes  int64         // 匯入檔案 (CSV、XLSX) 的大小上限 (bytes)
+	GzipLevel           int           // 回應的 gzip 壓縮等級 (1 到 9)，0 表示不壓縮
+	GzipMinLength       int           // 回應內容達到此大小 (bytes) 才壓縮
+	MigrationsDir       string        // 遷移檔案目錄，/readyz 以其中最新的版本檢查資料庫是否有尚未執行的遷移
+	LogHealthChecks     bool          // 是否在請求日誌中記錄健康檢查與探針請求
+	MetricsEnabled      bool          // 是否提供 /metrics (Prometheus) 並收集 HTTP 請求指標
+	MetricsUsername     string        // 兩者皆設定時 /metrics 需要 Basic Auth
+	MetricsPassword     string
+	AuditBufferSize     int           // 尚未寫入資料庫的稽核記錄上限，緩衝區已滿時丟棄新的記錄
+	AuditRetention      time.Duration // 稽核記錄的保留期限，由背景工作 audit_log_cleanup 刪除更舊的記錄；0 表示永久保留
+	AuditCleanupInterval time.Duration // audit_log_cleanup 的執行間隔
+	ShutdownTimeout     time.Duration // 收到 SIGINT/SIGTERM 後等待進行中的請求與背景工作結束的時間
+	EventsMaxStreams    int           // 每個帳戶同時開啟的事件串流 (GET /events) 上限
+	EventsHeartbeat     time.Duration // 事件串流沒有事件時送出心跳的間隔
+	EventsPGNotify      bool          // 以 PostgreSQL LISTEN/NOTIFY 在多個實例之間轉送事件；false 時只送給同一實例的訂閱者
+	CacheEnabled        bool          // 是否緩存選單、角色與產品類別的讀取結果；false 時每次都查詢資料庫 (除錯用)
+	CacheDriver         string        // 讀取緩存的儲存方式：memory 或 redis
+	CacheTTL            time.Duration // 緩存項目的存活時間，也是其他實例 (memory) 或失效失敗時讀到舊資料的最長時間
+	CacheRedisURL       string        // CacheDriver 為 redis 時的連線 URL，例如 redis://:password@localhost:6379/0
+	CacheRedisPrefix    string        // Redis key 的前綴，清除緩存時只刪除此前綴下的 key
+	SentryDSN           string        // 設定時將 5xx 錯誤與 panic 回報到 Sentry (或相容的服務)，空白時不回報
+	SentryEnvironment   string        // 回報中的環境名稱，預設為 APP_ENV
+	OtelEndpoint        string        // OTEL_EXPORTER_OTLP_ENDPOINT，設定時以 OTLP/HTTP 匯出追蹤，空白時不追蹤
+	OtelHeaders         map[string]string // OTEL_EXPORTER_OTLP_HEADERS，匯出時附加的標頭 (例如驗證用的 API key)
+	OtelServiceName     string        // OTEL_SERVICE_NAME，預設為 fastener-api
+	StrictJSONBinding   bool          // 所有路由的 JSON 請求內容都拒絕未知欄位；false 時只有 /api/v1 拒絕，已棄用的 /api 別名仍忽略未知欄位
+	LegacyAPISunset     time.Time     // 無版本的 /api 舊路徑停止提供的日期，以 Sunset 標頭告知用戶端；零值時不加 Sunset 標頭
+	DisplayTimezone     *time.Location // DISPLAY_TIMEZONE，以 X-Display-Timezone 標頭建議前端顯示時間的時區；nil 時不加標頭 (API 的時間一律為 UTC)
+	DefaultLocale       string        // Accept-Language 沒有支援的語言時，錯誤訊息使用的語系：en 或 zh-TW
+	DebugHTTPLog        bool          // 記錄所有 API 請求與回應的內容 (除錯用)；production 需同時設定 DebugHTTPLogForce
+	DebugHTTPLogRoutes  []string      // 只記錄這些路由 (不含版本前綴，例如 /customers/:id) 的內容，DebugHTTPLog 為 false 時使用
+	DebugHTTPLogMaxBytes int          // 每個請求或回應內容記錄的上限，超過時只記錄大小
+	DebugHTTPLogRedact  []string      // 記錄前以 "[REDACTED]" 取代的 JSON 與表單欄位 (不分大小寫)
+	DebugHTTPLogForce   bool          // 允許在 production 記錄內容
 }
 
 var Cfg *AppConfig // 全局配置實例
 
-// LoadConfig 載入應用程式配置
-func LoadConfig() {
+// MinJWTSecretLength JWT_SECRET 的最短長度 (位元組)，HS256 的密鑰應至少與雜湊輸出 (32 位元組) 一樣長
+const MinJWTSecretLength = 32
+
+// knownAppEnvs APP_ENV 允許的值
+var knownAppEnvs = []string{"development", "test", "production"}
+
+// LoadConfig 載入並驗證應用程式配置，成功時設定 Cfg
+// 不會在第一個錯誤就停止：所有有問題的環境變數一次以 *ValidationError 返回 (錯誤訊息不包含密鑰等敏感設定的值)，此時不修改 Cfg
+func LoadConfig() error {
+	var p problems
+
 	// 載入 .env 檔案，生產環境可能沒有，所以錯誤不Fatal
 	err := godotenv.Load()
 	if err != nil {
@@ -40,60 +133,503 @@ func LoadConfig() {
 		port = "8080"
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL environment variable is required.")
+	tlsCertFile := os.Getenv("TLS_CERT_FILE")
+	tlsKeyFile := os.Getenv("TLS_KEY_FILE")
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		p.add("TLS_CERT_FILE and TLS_KEY_FILE must be set together.")
+	}
+	if tlsCertFile != "" {
+		if err := loadTLSKeyPair(tlsCertFile, tlsKeyFile); err != nil {
+			p.addf("Invalid TLS configuration: %v", err)
+		}
+	}
+	tlsAutocertDomains, err := parseAutocertDomains(os.Getenv("TLS_AUTOCERT_DOMAINS"))
+	if err != nil {
+		p.addf("Invalid TLS_AUTOCERT_DOMAINS: %v", err)
+	}
+	if tlsCertFile != "" && len(tlsAutocertDomains) > 0 {
+		p.add("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS cannot be used together: use either your own certificate or Let's Encrypt.")
+	}
+	tlsAutocertCacheDir := os.Getenv("TLS_AUTOCERT_CACHE_DIR")
+	if tlsAutocertCacheDir == "" {
+		tlsAutocertCacheDir = "autocert-cache" // 相對於工作目錄，部署時應掛載為持久化的磁碟
+	}
+	httpRedirectPort := os.Getenv("HTTP_REDIRECT_PORT")
+	if httpRedirectPort != "" {
+		if tlsCertFile == "" && len(tlsAutocertDomains) == 0 {
+			p.add("HTTP_REDIRECT_PORT requires TLS: set TLS_CERT_FILE and TLS_KEY_FILE, or TLS_AUTOCERT_DOMAINS.")
+		}
+		if httpRedirectPort == port {
+			p.addf("HTTP_REDIRECT_PORT (%s) must differ from PORT.", httpRedirectPort)
+		}
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required.")
+	dbURL := secretEnv(&p, "DATABASE_URL") // DATABASE_URL_FILE 的問題已由 secretEnv 記錄
+	if dbURL == "" && os.Getenv("DATABASE_URL_FILE") == "" {
+		p.add("DATABASE_URL (or DATABASE_URL_FILE) environment variable is required.")
+	}
+	dbReadURL := secretEnv(&p, "DATABASE_READ_URL") // 選填，未設定時所有查詢使用 DATABASE_URL
+	if dbReadURL != "" && dbReadURL == dbURL {
+		dbReadURL = "" // 與主庫相同時不另外打開連接池
 	}
 
-	jwtAccessExpiresHoursStr := os.Getenv("JWT_ACCESS_EXPIRES_HOURS")
-	jwtAccessExpiresHours, err := strconv.Atoi(jwtAccessExpiresHoursStr)
-	if err != nil || jwtAccessExpiresHours == 0 {
-		jwtAccessExpiresHours = 1 // 預設 Access Token 有效期為 1 小時
-		log.Printf("JWT_ACCESS_EXPIRES_HOURS not set or invalid, using default %d hours.\n", jwtAccessExpiresHours)
+	dbMaxOpenConns := parseCountEnv(&p, "DB_MAX_OPEN_CONNS", db.DefaultPoolConfig.MaxOpenConns)
+	dbMaxIdleConns := parseCountEnv(&p, "DB_MAX_IDLE_CONNS", db.DefaultPoolConfig.MaxIdleConns)
+	if os.Getenv("DB_MAX_IDLE_CONNS") == "" && dbMaxOpenConns > 0 && dbMaxIdleConns > dbMaxOpenConns {
+		dbMaxIdleConns = dbMaxOpenConns // 只調低 DB_MAX_OPEN_CONNS 時，閒置連接數跟著調低
+	}
+	if dbMaxOpenConns > 0 && dbMaxIdleConns > dbMaxOpenConns {
+		p.addf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", dbMaxIdleConns, dbMaxOpenConns)
+	}
+	dbConnMaxLifetime := parseLifetimeEnv(&p, "DB_CONN_MAX_LIFETIME", db.DefaultPoolConfig.ConnMaxLifetime)
+	dbConnMaxIdleTime := parseLifetimeEnv(&p, "DB_CONN_MAX_IDLE_TIME", db.DefaultPoolConfig.ConnMaxIdleTime)
+	dbPingTimeout := parseDurationEnv(&p, "DB_PING_TIMEOUT", db.DefaultPoolConfig.PingTimeout)
+	dbConnectAttempts := parseCountEnv(&p, "DB_CONNECT_ATTEMPTS", db.DefaultPoolConfig.ConnectAttempts)
+	if dbConnectAttempts < 1 {
+		p.add("Invalid DB_CONNECT_ATTEMPTS: expected at least 1 (1 disables retries)")
+	}
+	dbConnectBackoff := parseDurationEnv(&p, "DB_CONNECT_BACKOFF", db.DefaultPoolConfig.ConnectBackoff)
+	dbConnectMaxBackoff := parseDurationEnv(&p, "DB_CONNECT_MAX_BACKOFF", db.DefaultPoolConfig.ConnectMaxBackoff)
+	if dbConnectMaxBackoff < dbConnectBackoff {
+		p.addf("DB_CONNECT_MAX_BACKOFF (%s) must not be less than DB_CONNECT_BACKOFF (%s)", dbConnectMaxBackoff, dbConnectBackoff)
 	}
+	dbStatementCacheCapacity := parseCountEnv(&p, "DB_STATEMENT_CACHE_CAPACITY", db.DefaultPoolConfig.StatementCacheCapacity)
+	txMaxRetries := parseCountEnv(&p, "TX_MAX_RETRIES", db.DefaultRetryConfig.MaxRetries)
+	txRetryBackoff := parseDurationEnv(&p, "TX_RETRY_BACKOFF", db.DefaultRetryConfig.Backoff)
+	slowQueryThreshold := time.Duration(parseCountEnv(&p, "SLOW_QUERY_MS", 500)) * time.Millisecond // 預設 500ms，0 表示不記錄慢查詢
 
-	jwtRefreshExpiresHoursStr := os.Getenv("JWT_REFRESH_EXPIRES_HOURS")
-	jwtRefreshExpiresHours, err := strconv.Atoi(jwtRefreshExpiresHoursStr)
-	if err != nil || jwtRefreshExpiresHours == 0 {
-		jwtRefreshExpiresHours = 720 // 預設 Refresh Token 有效期為 720 小時 (30 天)
-		log.Printf("JWT_REFRESH_EXPIRES_HOURS not set or invalid, using default %d hours.\n", jwtRefreshExpiresHours)
+	jwtSecret := secretEnv(&p, "JWT_SECRET") // JWT_SECRET_FILE 的問題已由 secretEnv 記錄
+	if jwtSecret == "" && os.Getenv("JWT_SECRET_FILE") == "" {
+		p.add("JWT_SECRET (or JWT_SECRET_FILE) environment variable is required.")
+	} else if jwtSecret != "" && len(jwtSecret) < MinJWTSecretLength {
+		p.addf("JWT_SECRET is too short (%d bytes): use at least %d random bytes.", len(jwtSecret), MinJWTSecretLength)
 	}
 
+	jwtAccessExpiresHours := parseHoursEnv(&p, "JWT_ACCESS_EXPIRES_HOURS", 1)     // 預設 Access Token 有效期為 1 小時
+	jwtRefreshExpiresHours := parseHoursEnv(&p, "JWT_REFRESH_EXPIRES_HOURS", 720) // 預設 Refresh Token 有效期為 720 小時 (30 天)
+
 	corsAllowOrigin := os.Getenv("CORS_ALLOW_ORIGIN")
 	if corsAllowOrigin == "" {
 		corsAllowOrigin = "*" // 預設允許所有來源 (開發環境可接受，生產環境應限制)
 		log.Println("CORS_ALLOW_ORIGIN not set, defaulting to '*'.")
 	}
+	corsAllowOrigins, err := parseCORSOrigins(corsAllowOrigin)
+	if err != nil {
+		p.addf("Invalid CORS_ALLOW_ORIGIN %q: %v", corsAllowOrigin, err)
+	}
+	allowAllOrigins := len(corsAllowOrigins) > 0 && corsAllowOrigins[0] == "*"
+	corsAllowCredentials := !allowAllOrigins // 預設允許；允許所有來源時瀏覽器不接受帶憑證的回應，預設不允許
+	if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+		corsAllowCredentials, err = strconv.ParseBool(v)
+		if err != nil {
+			p.addf("Invalid CORS_ALLOW_CREDENTIALS %q: expected true or false", v)
+		}
+	}
+	if allowAllOrigins && corsAllowCredentials {
+		p.add("CORS_ALLOW_ORIGIN=* cannot be combined with CORS_ALLOW_CREDENTIALS=true: list the allowed origins explicitly (wildcard subdomains such as https://*.example.com are supported).")
+	}
 
 	adminUsername := os.Getenv("ADMIN_USERNAME")
-	adminPassword := os.Getenv("ADMIN_PASSWORD") // 注意：此密碼僅用於初始化或重設工具，不應長期存在
+	adminPassword := secretEnv(&p, "ADMIN_PASSWORD") // 注意：此密碼僅用於初始化或重設工具，不應長期存在
 
 	appEnv := os.Getenv("APP_ENV")
 	if appEnv == "" {
 		appEnv = "development"
 	}
+	if !isKnownAppEnv(appEnv) {
+		p.addf("Invalid APP_ENV %q: expected one of %s", appEnv, strings.Join(knownAppEnvs, ", "))
+	}
 
 	logLevel := os.Getenv("LOG_LEVEL")
 	if logLevel == "" {
 		logLevel = "info"
 	}
+	if _, err := zapcore.ParseLevel(logLevel); err != nil {
+		p.addf("Invalid LOG_LEVEL %q: expected debug, info, warn, error, dpanic, panic or fatal", logLevel)
+	}
+
+	maxPageSize, err := strconv.Atoi(os.Getenv("MAX_PAGE_SIZE"))
+	if err != nil || maxPageSize <= 0 {
+		maxPageSize = 100 // 預設每頁最多 100 筆
+	}
+
+	defaultPageSize := 20 // 預設每頁 20 筆
+	if v := os.Getenv("DEFAULT_PAGE_SIZE"); v != "" {
+		defaultPageSize, err = strconv.Atoi(v)
+		if err != nil || defaultPageSize <= 0 || defaultPageSize > maxPageSize {
+			p.addf("Invalid DEFAULT_PAGE_SIZE %q: expected an integer between 1 and MAX_PAGE_SIZE (%d)", v, maxPageSize)
+		}
+	}
+
+	maxExportRows, err := strconv.Atoi(os.Getenv("MAX_EXPORT_ROWS"))
+	if err != nil || maxExportRows <= 0 {
+		maxExportRows = 10000 // 預設單次最多匯出 10000 筆
+	}
+
+	paymentTerms := []string{}
+	for _, term := range strings.Split(os.Getenv("PAYMENT_TERMS"), ",") {
+		if term = strings.ToUpper(strings.TrimSpace(term)); term != "" {
+			paymentTerms = append(paymentTerms, term)
+		}
+	}
+	if len(paymentTerms) == 0 {
+		paymentTerms = []string{"NET30", "NET60", "NET90", "COD", "PREPAID"} // 預設付款條件
+	}
+
+	defaultPhoneCountry := strings.ToUpper(strings.TrimSpace(os.Getenv("DEFAULT_PHONE_COUNTRY")))
+	if defaultPhoneCountry == "" {
+		defaultPhoneCountry = "TW" // 預設為台灣 (+886)
+	}
+
+	customerDuplicateThreshold, err := strconv.ParseFloat(os.Getenv("CUSTOMER_DUPLICATE_THRESHOLD"), 64)
+	if err != nil || customerDuplicateThreshold <= 0 || customerDuplicateThreshold > 1 {
+		customerDuplicateThreshold = 0.3 // 預設與 pg_trgm 的 similarity_threshold 相同
+	}
+
+	customerCodePrefix := strings.ToUpper(strings.TrimSpace(os.Getenv("CUSTOMER_CODE_PREFIX")))
+	if customerCodePrefix == "" {
+		customerCodePrefix = "C" // 預設前綴
+	}
+
+	productStandardBodies := []string{}
+	for _, body := range strings.Split(os.Getenv("PRODUCT_STANDARD_BODIES"), ",") {
+		if body = strings.ToUpper(strings.TrimSpace(body)); body != "" {
+			productStandardBodies = append(productStandardBodies, body)
+		}
+	}
+	if len(productStandardBodies) == 0 {
+		productStandardBodies = []string{"DIN", "ISO", "ANSI", "ASME", "JIS", "GB", "EN", "BS"} // 預設標準組織
+	}
+
+	productBaseCurrency := strings.ToUpper(strings.TrimSpace(os.Getenv("PRODUCT_BASE_CURRENCY")))
+	if productBaseCurrency == "" {
+		productBaseCurrency = "TWD" // 預設基準幣別
+	}
+
+	priceScale := int32(2) // 預設捨入到小數 2 位
+	if v := os.Getenv("PRICE_SCALE"); v != "" {
+		scale, err := strconv.Atoi(v)
+		if err != nil || scale < 0 || scale > decimal.StorageScale {
+			p.addf("Invalid PRICE_SCALE %q: expected an integer between 0 and %d", v, decimal.StorageScale)
+		}
+		priceScale = int32(scale)
+	}
+
+	priceRoundingMode := decimal.RoundingMode(strings.ToLower(strings.TrimSpace(os.Getenv("PRICE_ROUNDING_MODE"))))
+	if priceRoundingMode == "" {
+		priceRoundingMode = decimal.RoundHalfUp
+	}
+	if !priceRoundingMode.IsValid() {
+		p.addf("Invalid PRICE_ROUNDING_MODE %q: expected half_up, half_even, down or up", priceRoundingMode)
+	}
+
+	productImageMaxBytes, err := strconv.ParseInt(os.Getenv("PRODUCT_IMAGE_MAX_BYTES"), 10, 64)
+	if err != nil || productImageMaxBytes <= 0 {
+		productImageMaxBytes = 5 << 20 // 預設 5 MB
+	}
+
+	productImageTypes := []string{}
+	for _, t := range strings.Split(os.Getenv("PRODUCT_IMAGE_TYPES"), ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			productImageTypes = append(productImageTypes, t)
+		}
+	}
+	if len(productImageTypes) == 0 {
+		productImageTypes = []string{"image/jpeg", "image/png", "image/webp"} // 預設圖片格式
+	}
+
+	fileStoreDriver := strings.ToLower(strings.TrimSpace(os.Getenv("FILE_STORE_DRIVER")))
+	if fileStoreDriver == "" {
+		fileStoreDriver = "local"
+	}
+	if fileStoreDriver != "local" && fileStoreDriver != "s3" {
+		p.addf("Invalid FILE_STORE_DRIVER %q: expected local or s3", fileStoreDriver)
+	}
+
+	fileStoreLocalDir := os.Getenv("FILE_STORE_LOCAL_DIR")
+	if fileStoreLocalDir == "" {
+		fileStoreLocalDir = "uploads" // 預設為工作目錄下的 uploads
+	}
+
+	fileStoreS3Region := os.Getenv("FILE_STORE_S3_REGION")
+	if fileStoreS3Region == "" {
+		fileStoreS3Region = "us-east-1" // MinIO 等相容服務的預設區域
+	}
+	if fileStoreDriver == "s3" && (os.Getenv("FILE_STORE_S3_ENDPOINT") == "" || os.Getenv("FILE_STORE_S3_BUCKET") == "") {
+		p.add("FILE_STORE_S3_ENDPOINT and FILE_STORE_S3_BUCKET are required when FILE_STORE_DRIVER is s3.")
+	}
+
+	livenessTimeout := parseDurationEnv(&p, "LIVENESS_TIMEOUT", time.Second)
+	readinessTimeout := parseDurationEnv(&p, "READINESS_TIMEOUT", 3*time.Second)
+	requestTimeout := parseDurationEnv(&p, "REQUEST_TIMEOUT", 30*time.Second)
+
+	maxBodyBytes := parseBytesEnv(&p, "MAX_BODY_BYTES", 1<<20)                // 預設 1 MB
+	uploadMaxBodyBytes := parseBytesEnv(&p, "UPLOAD_MAX_BODY_BYTES", 20<<20) // 預設 20 MB
+	importMaxFileBytes := parseBytesEnv(&p, "IMPORT_MAX_FILE_BYTES", 10<<20) // 預設 10 MB
+	if importMaxFileBytes > uploadMaxBodyBytes || productImageMaxBytes > uploadMaxBodyBytes {
+		p.addf("IMPORT_MAX_FILE_BYTES (%d) and PRODUCT_IMAGE_MAX_BYTES (%d) must not exceed UPLOAD_MAX_BODY_BYTES (%d)",
+			importMaxFileBytes, productImageMaxBytes, uploadMaxBodyBytes)
+	}
+
+	gzipLevel := 5 // 預設 5，壓縮率與 CPU 用量之間的折衷
+	if v := os.Getenv("GZIP_LEVEL"); v != "" {
+		gzipLevel, err = strconv.Atoi(v)
+		if err != nil || gzipLevel < 0 || gzipLevel > 9 {
+			p.addf("Invalid GZIP_LEVEL %q: expected an integer between 0 (disabled) and 9", v)
+		}
+	}
+	gzipMinLength := 1024 // 預設 1 KB，更小的回應壓縮後可能反而變大
+	if v := os.Getenv("GZIP_MIN_LENGTH"); v != "" {
+		gzipMinLength, err = strconv.Atoi(v)
+		if err != nil || gzipMinLength < 0 {
+			p.addf("Invalid GZIP_MIN_LENGTH %q: expected a non-negative number of bytes", v)
+		}
+	}
+
+	migrationsDir := os.Getenv("MIGRATIONS_DIR")
+	if migrationsDir == "" {
+		migrationsDir = "db/migrations" // 相對於工作目錄，與 Dockerfile 拷貝的位置一致
+	}
+
+	logHealthChecks := strings.EqualFold(os.Getenv("LOG_HEALTH_CHECKS"), "true") // 預設不記錄，避免探針淹沒請求日誌
+
+	metricsEnabled := !strings.EqualFold(os.Getenv("METRICS_ENABLED"), "false") // 預設啟用
+	metricsUsername := os.Getenv("METRICS_USERNAME")
+	metricsPassword := os.Getenv("METRICS_PASSWORD")
+	if (metricsUsername == "") != (metricsPassword == "") {
+		p.add("METRICS_USERNAME and METRICS_PASSWORD must be set together.")
+	}
+
+	auditBufferSize := parseCountEnv(&p, "AUDIT_BUFFER_SIZE", 1000)
+	if auditBufferSize < 1 {
+		p.add("Invalid AUDIT_BUFFER_SIZE: expected at least 1")
+	}
+	auditRetention := parseLifetimeEnv(&p, "AUDIT_RETENTION", 0) // 預設永久保留
+	auditCleanupInterval := parseDurationEnv(&p, "AUDIT_CLEANUP_INTERVAL", time.Hour)
+
+	shutdownTimeout := parseDurationEnv(&p, "SHUTDOWN_TIMEOUT", 10*time.Second)
+
+	eventsMaxStreams := parseCountEnv(&p, "EVENTS_MAX_STREAMS_PER_ACCOUNT", 5)
+	if eventsMaxStreams < 1 {
+		p.add("Invalid EVENTS_MAX_STREAMS_PER_ACCOUNT: expected at least 1")
+	}
+	eventsHeartbeat := parseDurationEnv(&p, "EVENTS_HEARTBEAT_INTERVAL", 25*time.Second)
+	eventsPGNotify := false
+	if v := os.Getenv("EVENTS_PG_NOTIFY"); v != "" {
+		eventsPGNotify, err = strconv.ParseBool(v)
+		if err != nil {
+			p.addf("Invalid EVENTS_PG_NOTIFY %q: expected true or false", v)
+		}
+	}
+
+	cacheEnabled := true
+	if v := os.Getenv("CACHE_ENABLED"); v != "" {
+		cacheEnabled, err = strconv.ParseBool(v)
+		if err != nil {
+			p.addf("Invalid CACHE_ENABLED %q: expected true or false", v)
+		}
+	}
+	cacheDriver := strings.ToLower(strings.TrimSpace(os.Getenv("CACHE_DRIVER")))
+	if cacheDriver == "" {
+		cacheDriver = "memory"
+	}
+	if cacheDriver != "memory" && cacheDriver != "redis" {
+		p.addf("Invalid CACHE_DRIVER %q: expected memory or redis", cacheDriver)
+	}
+	cacheTTL := parseDurationEnv(&p, "CACHE_TTL", 5*time.Minute)
+	cacheRedisURL := os.Getenv("CACHE_REDIS_URL")
+	if cacheEnabled && cacheDriver == "redis" && cacheRedisURL == "" {
+		p.add("CACHE_REDIS_URL is required when CACHE_DRIVER is redis.")
+	}
+	cacheRedisPrefix := os.Getenv("CACHE_REDIS_PREFIX")
+	if cacheRedisPrefix == "" {
+		cacheRedisPrefix = "fastener:cache:"
+	}
+
+	// 錯誤訊息的預設語系，與 utils 的訊息目錄 (utils/locales) 相同的標籤
+	defaultLocale := "en"
+	if v := strings.TrimSpace(os.Getenv("DEFAULT_LOCALE")); v != "" {
+		switch strings.ToLower(strings.ReplaceAll(v, "_", "-")) {
+		case "en":
+			defaultLocale = "en"
+		case "zh-tw":
+			defaultLocale = "zh-TW"
+		default:
+			p.addf("Invalid DEFAULT_LOCALE %q: expected en or zh-TW", v)
+		}
+	}
+
+	// 請求與回應內容的除錯日誌，預設關閉；production 只在 DEBUG_HTTP_LOG_FORCE=true 時允許
+	debugHTTPLog := false
+	if v := os.Getenv("DEBUG_HTTP_LOG"); v != "" {
+		debugHTTPLog, err = strconv.ParseBool(v)
+		if err != nil {
+			p.addf("Invalid DEBUG_HTTP_LOG %q: expected true or false", v)
+		}
+	}
+	debugHTTPLogRoutes := []string{}
+	for _, route := range strings.Split(os.Getenv("DEBUG_HTTP_LOG_ROUTES"), ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			if !strings.HasPrefix(route, "/") {
+				p.addf("Invalid DEBUG_HTTP_LOG_ROUTES entry %q: expected a route path such as /customers/:id", route)
+			}
+			debugHTTPLogRoutes = append(debugHTTPLogRoutes, route)
+		}
+	}
+	debugHTTPLogMaxBytes := parseCountEnv(&p, "DEBUG_HTTP_LOG_MAX_BYTES", 16<<10) // 預設 16 KB
+	debugHTTPLogRedact := []string{}
+	for _, field := range strings.Split(os.Getenv("DEBUG_HTTP_LOG_REDACT"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			debugHTTPLogRedact = append(debugHTTPLogRedact, field)
+		}
+	}
+	if len(debugHTTPLogRedact) == 0 {
+		debugHTTPLogRedact = []string{"password", "old_password", "new_password", "refresh_token", "access_token", "api_key"} // 預設遮蔽的欄位
+	}
+	debugHTTPLogForce := false
+	if v := os.Getenv("DEBUG_HTTP_LOG_FORCE"); v != "" {
+		debugHTTPLogForce, err = strconv.ParseBool(v)
+		if err != nil {
+			p.addf("Invalid DEBUG_HTTP_LOG_FORCE %q: expected true or false", v)
+		}
+	}
+	if appEnv == "production" && (debugHTTPLog || len(debugHTTPLogRoutes) > 0) && !debugHTTPLogForce {
+		p.add("DEBUG_HTTP_LOG and DEBUG_HTTP_LOG_ROUTES are not allowed in production unless DEBUG_HTTP_LOG_FORCE=true.")
+	}
+
+	sentryEnvironment := os.Getenv("SENTRY_ENVIRONMENT")
+	if sentryEnvironment == "" {
+		sentryEnvironment = appEnv
+	}
+
+	otelEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	otelHeaders, err := tracing.ParseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		p.addf("Invalid OTEL_EXPORTER_OTLP_HEADERS: %v", err)
+	}
+	otelServiceName := os.Getenv("OTEL_SERVICE_NAME")
+	if otelServiceName == "" {
+		otelServiceName = "fastener-api"
+	}
+
+	strictJSONBinding := false
+	if v := os.Getenv("STRICT_JSON_BINDING"); v != "" {
+		strictJSONBinding, err = strconv.ParseBool(v)
+		if err != nil {
+			p.addf("Invalid STRICT_JSON_BINDING %q: expected true or false", v)
+		}
+	}
+
+	var legacyAPISunset time.Time
+	if v := os.Getenv("LEGACY_API_SUNSET"); v != "" {
+		legacyAPISunset, err = time.Parse("2006-01-02", v)
+		if err != nil {
+			p.addf("Invalid LEGACY_API_SUNSET %q: expected a date such as 2027-06-30", v)
+		}
+	}
+
+	var displayTimezone *time.Location
+	if v := os.Getenv("DISPLAY_TIMEZONE"); v != "" {
+		displayTimezone, err = time.LoadLocation(v)
+		if err != nil {
+			p.addf("Invalid DISPLAY_TIMEZONE %q: expected an IANA time zone such as Asia/Taipei", v)
+		}
+	}
+
+	if err := p.err(); err != nil {
+		return err
+	}
 
 	Cfg = &AppConfig{
 		Port:                port,
+		TLSCertFile:         tlsCertFile,
+		TLSKeyFile:          tlsKeyFile,
+		TLSAutocertDomains:  tlsAutocertDomains,
+		TLSAutocertCacheDir: tlsAutocertCacheDir,
+		TLSAutocertEmail:    os.Getenv("TLS_AUTOCERT_EMAIL"),
+		HTTPRedirectPort:    httpRedirectPort,
 		DatabaseURL:         dbURL,
+		DatabaseReadURL:     dbReadURL,
+		DBMaxOpenConns:      dbMaxOpenConns,
+		DBMaxIdleConns:      dbMaxIdleConns,
+		DBConnMaxLifetime:   dbConnMaxLifetime,
+		DBConnMaxIdleTime:   dbConnMaxIdleTime,
+		DBPingTimeout:       dbPingTimeout,
+		DBConnectAttempts:   dbConnectAttempts,
+		DBConnectBackoff:    dbConnectBackoff,
+		DBConnectMaxBackoff: dbConnectMaxBackoff,
+		DBStatementCacheCapacity: dbStatementCacheCapacity,
+		TxMaxRetries:        txMaxRetries,
+		TxRetryBackoff:      txRetryBackoff,
+		SlowQueryThreshold:  slowQueryThreshold,
 		JwtSecret:           jwtSecret,
 		JwtAccessExpiresHours:  jwtAccessExpiresHours,
 		JwtRefreshExpiresHours: jwtRefreshExpiresHours,
-		CorsAllowOrigin:     corsAllowOrigin,
+		CorsAllowOrigins:    corsAllowOrigins,
+		CorsAllowCredentials: corsAllowCredentials,
 		AdminUsername:       adminUsername,
 		AdminPassword:       adminPassword,
 		AppEnv:              appEnv,
 		LogLevel:            logLevel,
+		MaxPageSize:         maxPageSize,
+		DefaultPageSize:     defaultPageSize,
+		MaxExportRows:       maxExportRows,
+		PaymentTerms:        paymentTerms,
+		DefaultPhoneCountry: defaultPhoneCountry,
+		CustomerDuplicateThreshold: customerDuplicateThreshold,
+		CustomerCodePrefix:  customerCodePrefix,
+		ProductStandardBodies: productStandardBodies,
+		ProductBaseCurrency: productBaseCurrency,
+		PriceScale:          priceScale,
+		PriceRoundingMode:   priceRoundingMode,
+		ProductImageMaxBytes: productImageMaxBytes,
+		ProductImageTypes:   productImageTypes,
+		FileStoreDriver:     fileStoreDriver,
+		FileStoreLocalDir:   fileStoreLocalDir,
+		FileStoreS3Endpoint: os.Getenv("FILE_STORE_S3_ENDPOINT"),
+		FileStoreS3Region:   fileStoreS3Region,
+		FileStoreS3Bucket:   os.Getenv("FILE_STORE_S3_BUCKET"),
+		FileStoreS3AccessKeyID:     os.Getenv("FILE_STORE_S3_ACCESS_KEY_ID"),
+		FileStoreS3SecretAccessKey: os.Getenv("FILE_STORE_S3_SECRET_ACCESS_KEY"),
+		LivenessTimeout:     livenessTimeout,
+		ReadinessTimeout:    readinessTimeout,
+		RequestTimeout:      requestTimeout,
+		MaxBodyBytes:        maxBodyBytes,
+		UploadMaxBodyBytes:  uploadMaxBodyBytes,
+		ImportMaxFileBytes:  importMaxFileBytes,
+		GzipLevel:           gzipLevel,
+		GzipMinLength:       gzipMinLength,
+		MigrationsDir:       migrationsDir,
+		LogHealthChecks:     logHealthChecks,
+		MetricsEnabled:      metricsEnabled,
+		MetricsUsername:     metricsUsername,
+		MetricsPassword:     metricsPassword,
+		AuditBufferSize:     auditBufferSize,
+		AuditRetention:      auditRetention,
+		AuditCleanupInterval: auditCleanupInterval,
+		ShutdownTimeout:     shutdownTimeout,
+		EventsMaxStreams:    eventsMaxStreams,
+		EventsHeartbeat:     eventsHeartbeat,
+		EventsPGNotify:      eventsPGNotify,
+		CacheEnabled:        cacheEnabled,
+		CacheDriver:         cacheDriver,
+		CacheTTL:            cacheTTL,
+		CacheRedisURL:       cacheRedisURL,
+		CacheRedisPrefix:    cacheRedisPrefix,
+		SentryDSN:           os.Getenv("SENTRY_DSN"),
+		SentryEnvironment:   sentryEnvironment,
+		OtelEndpoint:        otelEndpoint,
+		OtelHeaders:         otelHeaders,
+		OtelServiceName:     otelServiceName,
+		StrictJSONBinding:   strictJSONBinding,
+		LegacyAPISunset:     legacyAPISunset,
+		DisplayTimezone:     displayTimezone,
+		DefaultLocale:       defaultLocale,
+		DebugHTTPLog:        debugHTTPLog,
+		DebugHTTPLogRoutes:  debugHTTPLogRoutes,
+		DebugHTTPLogMaxBytes: debugHTTPLogMaxBytes,
+		DebugHTTPLogRedact:  debugHTTPLogRedact,
+		DebugHTTPLogForce:   debugHTTPLogForce,
 	}
 
 	// 敏感資訊的警告 (僅在開發環境輸出)
@@ -101,4 +637,109 @@ func LoadConfig() {
 		log.Println("--- WARNING: Using .env file for sensitive configurations. ---")
 		log.Println("--- For production, use secure secrets management (e.g., Kubernetes Secrets, Vault, AWS Secrets Manager). ---")
 	}
+	return nil
+}
+
+// DBPoolConfig 返回傳給 db.InitDB 的連接池參數
+func (c *AppConfig) DBPoolConfig() db.PoolConfig {
+	return db.PoolConfig{
+		MaxOpenConns:      c.DBMaxOpenConns,
+		MaxIdleConns:      c.DBMaxIdleConns,
+		ConnMaxLifetime:   c.DBConnMaxLifetime,
+		ConnMaxIdleTime:   c.DBConnMaxIdleTime,
+		PingTimeout:       c.DBPingTimeout,
+		ConnectAttempts:   c.DBConnectAttempts,
+		ConnectBackoff:    c.DBConnectBackoff,
+		ConnectMaxBackoff: c.DBConnectMaxBackoff,
+		StatementCacheCapacity: c.DBStatementCacheCapacity,
+	}
+}
+
+// TxRetryConfig 返回傳給 db.NewTxManager 的事務重試參數；等待時間的上限至少為 TxRetryBackoff
+func (c *AppConfig) TxRetryConfig() db.RetryConfig {
+	maxBackoff := db.DefaultRetryConfig.MaxBackoff
+	if c.TxRetryBackoff > maxBackoff {
+		maxBackoff = c.TxRetryBackoff
+	}
+	return db.RetryConfig{MaxRetries: c.TxMaxRetries, Backoff: c.TxRetryBackoff, MaxBackoff: maxBackoff}
+}
+
+// parseDurationEnv 讀取 Go duration 格式 (例如 "500ms"、"3s") 的環境變數，未設定時使用 def，格式錯誤或不為正數時記錄問題並返回 def
+func parseDurationEnv(p *problems, name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		p.addf("Invalid %s %q: expected a positive duration such as 500ms or 3s", name, v)
+		return def
+	}
+	return d
+}
+
+// parseBytesEnv 讀取以 bytes 表示的大小設定，未設定時返回 def，格式錯誤時記錄問題並返回 def
+func parseBytesEnv(p *problems, name string, def int64) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		p.addf("Invalid %s %q: expected a positive number of bytes such as 1048576", name, v)
+		return def
+	}
+	return n
+}
+
+// parseCountEnv 讀取非負整數的環境變數 (例如連接數)，未設定時返回 def，格式錯誤時記錄問題並返回 def
+func parseCountEnv(p *problems, name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		p.addf("Invalid %s %q: expected a non-negative integer", name, v)
+		return def
+	}
+	return n
+}
+
+// parseHoursEnv 讀取以小時表示的有效期 (例如 JWT 的有效期)，未設定時返回 def，不是正整數時記錄問題並返回 def
+func parseHoursEnv(p *problems, name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		p.addf("Invalid %s %q: expected a positive number of hours", name, v)
+		return def
+	}
+	return n
+}
+
+// isKnownAppEnv APP_ENV 是否為 knownAppEnvs 之一
+func isKnownAppEnv(appEnv string) bool {
+	for _, known := range knownAppEnvs {
+		if appEnv == known {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLifetimeEnv 與 parseDurationEnv 相同，但允許 0 (表示不限制)
+func parseLifetimeEnv(p *problems, name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		p.addf("Invalid %s %q: expected a duration such as 5m, or 0 for no limit", name, v)
+		return def
+	}
+	return d
 }
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseCORSOrigins 解析以逗號分隔的 CORS 來源，例如 "https://admin.example.com,https://*.example.com,http://localhost:5173"
+// 來源為 scheme://host[:port]，結尾的 / 會被去除；host 最左邊的標籤可以是 *，比對任何子網域 (不含網域本身)
+// "*" 允許所有來源，只能單獨使用
+func parseCORSOrigins(raw string) ([]string, error) {
+	origins := []string{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSuffix(strings.TrimSpace(entry), "/")
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			origins = append(origins, entry)
+			continue
+		}
+		origin, err := parseCORSOrigin(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid origin %q: %w", entry, err)
+		}
+		origins = append(origins, origin)
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("no origins specified")
+	}
+	if len(origins) > 1 {
+		for _, origin := range origins {
+			if origin == "*" {
+				return nil, fmt.Errorf(`"*" cannot be combined with other origins`)
+			}
+		}
+	}
+	return origins, nil
+}
+
+// parseCORSOrigin 驗證單一來源 (或子網域樣式) 並轉為小寫
+func parseCORSOrigin(entry string) (string, error) {
+	// url.Parse 不接受 host 中的 *，先以合法的標籤取代後再驗證
+	wildcard := strings.Contains(entry, "://*.")
+	u, err := url.Parse(strings.Replace(entry, "://*.", "://wildcard.", 1))
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "", fmt.Errorf("scheme must be http or https")
+	case u.Host == "" || u.Hostname() == "":
+		return "", fmt.Errorf("missing host")
+	case u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "":
+		return "", fmt.Errorf("must be scheme://host[:port] without a path")
+	case strings.Contains(strings.TrimPrefix(entry, u.Scheme+"://*."), "*"):
+		return "", fmt.Errorf("* is only allowed as the leftmost label of the host, e.g. https://*.example.com")
+	}
+	host := strings.ToLower(u.Host)
+	if wildcard {
+		host = "*." + strings.TrimPrefix(host, "wildcard.")
+	}
+	return u.Scheme + "://" + host, nil
+}
+
+// MatchCORSOrigin 請求的 Origin 是否符合 origins (由 CORS_ALLOW_ORIGIN 解析) 其中之一，供 CORS 中介軟體的 AllowOriginFunc 使用
+// 子網域樣式 https://*.example.com 符合 https://admin.example.com 與 https://a.b.example.com，但不符合 https://example.com；port 必須相同
+func MatchCORSOrigin(origins []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range origins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+		rest, ok := strings.CutPrefix(origin, scheme+"://")
+		if ok && strings.HasSuffix(rest, "."+host) && !strings.ContainsAny(strings.TrimSuffix(rest, "."+host), "/:@") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// secretEnv 讀取密鑰類的設定：name 有值時直接使用，否則讀取 name_FILE 指定的檔案 (例如 Kubernetes 或 Docker secrets 掛載的檔案)，去除前後空白
+// 檔案無法讀取或內容為空時記錄問題並返回空字串；問題訊息只包含檔案路徑，不包含檔案內容
+func secretEnv(p *problems, name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return ""
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		p.addf("Cannot read %s_FILE: %v", name, err)
+		return ""
+	}
+	secret := strings.TrimSpace(string(content))
+	if secret == "" {
+		p.addf("%s_FILE (%s) is empty", name, path)
+	}
+	return secret
+}
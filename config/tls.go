@@ -0,0 +1,40 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadTLSKeyPair 讀取 TLS_CERT_FILE 與 TLS_KEY_FILE 並確認兩者是相符的 PEM 憑證與私鑰，在啟動時指出是哪一個檔案有問題
+func loadTLSKeyPair(certFile, keyFile string) error {
+	cert, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("cannot read TLS_CERT_FILE: %w", err)
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot read TLS_KEY_FILE: %w", err)
+	}
+	if _, err := tls.X509KeyPair(cert, key); err != nil {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE are not a valid PEM certificate and key pair: %w", err)
+	}
+	return nil
+}
+
+// parseAutocertDomains 解析以逗號分隔的 Let's Encrypt 網域白名單，只有列出的網域會申請憑證
+func parseAutocertDomains(raw string) ([]string, error) {
+	domains := []string{}
+	for _, domain := range strings.Split(raw, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" {
+			continue
+		}
+		if strings.ContainsAny(domain, "*/: ") {
+			return nil, fmt.Errorf("invalid domain %q: expected a host name such as api.example.com (wildcards and ports are not supported)", domain)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
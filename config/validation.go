@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError LoadConfig 發現的所有配置問題
+type ValidationError struct {
+	Problems []string // 每個問題一行，不包含密鑰等敏感設定的值
+}
+
+// Error 實現 error 介面，每個問題一行
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration (%d problem(s)):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// problems 在 LoadConfig 中收集驗證問題，全部檢查完才一次返回
+type problems []string
+
+// add 記錄一個問題
+func (p *problems) add(msg string) {
+	*p = append(*p, msg)
+}
+
+// addf 以格式字串記錄一個問題
+func (p *problems) addf(format string, args ...interface{}) {
+	p.add(fmt.Sprintf(format, args...))
+}
+
+// err 沒有問題時返回 nil，否則返回 *ValidationError
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: p}
+}
@@ -1,39 +1,166 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"time"
 
-	_ "github.com/lib/pq" // PostgreSQL 驅動註冊
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/stdlib" // PostgreSQL 驅動 (pgx 的 database/sql 介面)
+
+	"github.com/wac0705/fastener-api/tracing" // 資料庫查詢的 span
 )
 
-var DB *sql.DB // 全局資料庫連接實例
+var DB *sql.DB // 全局資料庫連接實例 (主庫，所有寫入在此執行)
+
+// ReadDB 唯讀副本 (read replica) 的連接實例，未設定 DATABASE_READ_URL 時為 nil；見 Reader
+var ReadDB *sql.DB
+
+// PoolConfig 資料庫連接池與啟動時連線的參數，由 config.AppConfig.DBPoolConfig 提供
+type PoolConfig struct {
+	MaxOpenConns      int           // 最大打開連接數，0 表示不限制
+	MaxIdleConns      int           // 最大閒置連接數，不可超過 MaxOpenConns
+	ConnMaxLifetime   time.Duration // 連接最長生命週期 (防止長期空閒連接被資料庫斷開)，0 表示不限制
+	ConnMaxIdleTime   time.Duration // 連接在被連接池回收前可以閒置的最大時間，0 表示不限制
+	PingTimeout       time.Duration // 啟動時每次測試連接的逾時時間
+	ConnectAttempts   int           // 啟動時測試連接的最多次數，1 表示失敗時不重試
+	ConnectBackoff    time.Duration // 第一次重試前的等待時間，之後每次加倍
+	ConnectMaxBackoff time.Duration // 重試等待時間的上限
+	// StatementCacheCapacity 每個連接快取的 prepared statement 數量：相同的查詢 (例如登入的 FindByUsername、權限與角色選單的載入) 只在每個連接第一次執行時解析與規劃
+	// 0 表示不快取，改以未命名的 prepared statement 執行 (經過 transaction pooling 模式的 PgBouncer 時需要)
+	StatementCacheCapacity int
+}
+
+// DefaultPoolConfig 未設定環境變數時使用的連接池參數；預設的重試在約 30 秒後放棄 (0.5s、1s、2s，之後每次 4s)
+var DefaultPoolConfig = PoolConfig{
+	MaxOpenConns:           25,
+	MaxIdleConns:           25,
+	ConnMaxLifetime:        5 * time.Minute,
+	ConnMaxIdleTime:        1 * time.Minute,
+	PingTimeout:            5 * time.Second,
+	ConnectAttempts:        10,
+	ConnectBackoff:         500 * time.Millisecond,
+	ConnectMaxBackoff:      4 * time.Second,
+	StatementCacheCapacity: 512, // 與 pgx 的預設相同
+}
 
-// InitDB 初始化資料庫連接
-func InitDB(connStr string) {
+// InitDB 初始化資料庫連接並等待資料庫可連線 (見 Connect)，超過重試次數時終止程式
+// readConnStr 不為空時另外打開唯讀副本的連接 (ReadDB)；供命令列工具使用，API 伺服器以 Open 與 Connect 分開執行，讓 /readyz 在等待期間可以回應
+func InitDB(connStr, readConnStr string, pool PoolConfig) {
+	Open(connStr, readConnStr, pool)
+	if err := Connect(context.Background(), DB, pool); err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+	if ReadDB != nil {
+		if err := Connect(context.Background(), ReadDB, pool); err != nil {
+			log.Fatalf("Error connecting to the read replica: %v", err)
+		}
+	}
+}
+
+// Open 打開資料庫連接 (DB) 並依 pool 設定連接池參數，不測試連接
+// readConnStr 不為空時以相同的連接池參數打開唯讀副本的連接 (ReadDB)，否則 ReadDB 為 nil，讀取一律使用 DB
+// 驅動以 tracing.WrapConnector 包裝，啟用追蹤時每個查詢都會建立 span；InitQueryLog 之後每個查詢都會計時 (慢查詢日誌)
+func Open(connStr, readConnStr string, pool PoolConfig) {
 	if connStr == "" {
 		log.Fatal("Database connection string is empty. Please set DATABASE_URL in environment or .env file.")
 	}
+	DB = openPool("primary", connStr, pool)
+	ReadDB = nil
+	if readConnStr != "" {
+		ReadDB = openPool("read replica", readConnStr, pool)
+	}
+}
 
-	var err error
-	DB, err = sql.Open("postgres", connStr) // 打開資料庫連接
+// openPool 依 connStr 打開一個連接池並設定連接池參數，name 只用於日誌
+// prepared statement 由 pgx 在每個連接上快取 (見 PoolConfig.StatementCacheCapacity)，連接關閉 (被連接池回收或 Close) 時一併釋放，不需要另外管理 sql.Stmt
+// 時間一律以 UTC 處理，不受資料庫伺服器或應用程式主機的時區設定影響 (見 sessionTimezone 與 scanTimestamptzInUTC)
+func openPool(name, connStr string, pool PoolConfig) *sql.DB {
+	connConfig, err := pgx.ParseConfig(connStr) // 解析連接字串
 	if err != nil {
-		log.Fatalf("Error opening database connection: %v", err)
+		log.Fatalf("Error opening %s database connection: %v", name, err)
+	}
+	connConfig.StatementCacheCapacity = pool.StatementCacheCapacity
+	if pool.StatementCacheCapacity == 0 && connConfig.DefaultQueryExecMode == pgx.QueryExecModeCacheStatement {
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeExec // 沒有快取時 QueryExecModeCacheStatement 無法執行查詢
 	}
+	connConfig.RuntimeParams["timezone"] = sessionTimezone
+	database := sql.OpenDB(tracing.WrapConnector(wrapQueryLog(stdlib.GetConnector(*connConfig, stdlib.OptionAfterConnect(scanTimestamptzInUTC)))))
 
 	// 設定連接池參數
-	DB.SetMaxOpenConns(25)                  // 最大打開連接數
-	DB.SetMaxIdleConns(25)                  // 最大閒置連接數
-	DB.SetConnMaxLifetime(5 * time.Minute)  // 連接最長生命週期 (防止長期空閒連接被資料庫斷開)
-	DB.SetConnMaxIdleTime(1 * time.Minute)  // 連接在被連接池回收前可以閒置的最大時間
+	database.SetMaxOpenConns(pool.MaxOpenConns)
+	database.SetMaxIdleConns(pool.MaxIdleConns)
+	database.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	database.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 
-	// 測試連接
-	err = DB.Ping()
-	if err != nil {
-		log.Fatalf("Error connecting to the database: %v", err)
+	log.Printf("Database pool (%s): max_open_conns=%d max_idle_conns=%d conn_max_lifetime=%s conn_max_idle_time=%s statement_cache_capacity=%d query_exec_mode=%s\n",
+		name, database.Stats().MaxOpenConnections, pool.MaxIdleConns, pool.ConnMaxLifetime, pool.ConnMaxIdleTime, connConfig.StatementCacheCapacity, connConfig.DefaultQueryExecMode)
+	return database
+}
+
+// sessionTimezone 每個連接的工作階段時區 (TimeZone)，連接字串中的 timezone 參數也會被覆寫
+// 查詢中的 ::date 轉換 (例如 created_at_gte=2024-01-01 的篩選)、date_trunc 與文字格式的時間都以此時區計算，
+// 結果不會因資料庫伺服器的預設時區 (例如 Asia/Taipei) 而相差一天或 8 小時
+const sessionTimezone = "UTC"
+
+// scanTimestamptzInUTC 讓連接把 timestamptz 掃描為 UTC 的 time.Time
+// pgx 預設轉為應用程式主機的 time.Local，JSON 中的時差 (+08:00 或 Z) 會隨部署環境改變；時間點本身不變
+func scanTimestamptzInUTC(ctx context.Context, conn *pgx.Conn) error {
+	conn.TypeMap().RegisterType(&pgtype.Type{Name: "timestamptz", OID: pgtype.TimestamptzOID, Codec: &pgtype.TimestamptzCodec{ScanLocation: time.UTC}})
+	return nil
+}
+
+// Writer 返回主庫的連接 (DB)，所有寫入與寫入後立即讀取的查詢都應使用它
+func Writer() *sql.DB {
+	return DB
+}
+
+// Reader 返回執行純讀取查詢 (例如列表) 的連接：有唯讀副本時為 ReadDB，否則為 DB
+// 副本可能落後主庫，剛寫入的資料不一定讀得到；需要讀到自己寫入的資料時使用 Writer 或 WithPrimary
+func Reader() *sql.DB {
+	if ReadDB != nil {
+		return ReadDB
+	}
+	return DB
+}
+
+// Connect 測試 database 的連接，失敗時依 pool 的重試設定以指數退避重試 (例如容器啟動時 PostgreSQL 尚未就緒)
+// 每次失敗都會記錄；超過 ConnectAttempts 或 ctx 結束時返回最後一次的錯誤
+func Connect(ctx context.Context, database *sql.DB, pool PoolConfig) error {
+	attempts := pool.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
 	}
+	backoff := pool.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx, database, pool.PingTimeout)
+		if err == nil {
+			fmt.Println("Database connected successfully!")
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("database unavailable after %d attempt(s): %w", attempt, err)
+		}
+		log.Printf("Database not ready (attempt %d/%d), retrying in %s: %v\n", attempt, attempts, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > pool.ConnectMaxBackoff {
+			backoff = pool.ConnectMaxBackoff
+		}
+	}
+}
 
-	fmt.Println("Database connected successfully!")
+// ping 以 timeout 為期限測試一次連接
+func ping(ctx context.Context, database *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return database.PingContext(ctx)
 }
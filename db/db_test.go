@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// statementCacheQuery 與權限載入 (FindPermissionsByRoleID)、角色選單載入形狀相近的查詢：兩個資料表的 JOIN、一個參數與排序
+// 使用系統目錄，不需要執行過遷移的資料庫
+const statementCacheQuery = `SELECT c.oid, c.relname, n.nspname
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relkind = 'r'
+	ORDER BY c.relname
+	LIMIT 20`
+
+// BenchmarkStatementCache 以 openPool 開啟的連接池 (與 API 相同的連接設定) 並行執行同一個查詢，
+// 比較每個連接快取 prepared statement (DB_STATEMENT_CACHE_CAPACITY 預設 512) 與不快取 (0，每次以未命名的 prepared statement 解析與規劃)
+func BenchmarkStatementCache(b *testing.B) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		b.Skip("TEST_DATABASE_URL is not set")
+	}
+	for _, capacity := range []int{DefaultPoolConfig.StatementCacheCapacity, 0} {
+		b.Run(fmt.Sprintf("capacity=%d", capacity), func(b *testing.B) {
+			pool := DefaultPoolConfig
+			pool.StatementCacheCapacity = capacity
+			database := openPool("benchmark", url, pool)
+			b.Cleanup(func() { database.Close() })
+			ctx := context.Background()
+			if err := database.PingContext(ctx); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) { // 模擬多個請求同時檢查權限
+				for pb.Next() {
+					rows, err := database.QueryContext(ctx, statementCacheQuery, "pg_catalog")
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					for rows.Next() {
+						var (
+							oid           uint32
+							relation, nsp string
+						)
+						if err := rows.Scan(&oid, &relation, &nsp); err != nil {
+							b.Error(err)
+						}
+					}
+					if err := rows.Err(); err != nil {
+						b.Error(err)
+					}
+					rows.Close()
+				}
+			})
+		})
+	}
+}
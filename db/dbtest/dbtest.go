@@ -0,0 +1,168 @@
+//go:build integration
+
+// Package dbtest 整合測試使用的 PostgreSQL：以 testcontainers 啟動容器 (或使用 TEST_DATABASE_URL)，執行 db/migrations 中的遷移，
+// 並提供清空資料表與建立測試資料 (角色、權限、帳戶、公司) 的輔助函數
+// 只在 integration 建置標籤下編譯：go test -tags integration ./... (見 make test-integration)
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/wac0705/fastener-api/db"
+)
+
+// postgresImage 測試容器的映像，與生產環境的主要版本一致
+const postgresImage = "postgres:16-alpine"
+
+var (
+	setupOnce sync.Once
+	setupDB   *sql.DB
+	setupErr  error
+)
+
+// Open 返回已執行所有遷移、資料表已清空的資料庫 (每個測試開始時呼叫)
+// 同一個測試程序中只啟動一次容器並執行一次遷移，容器在程序結束後由 testcontainers 回收；
+// 設定 TEST_DATABASE_URL 時改用該資料庫 (資料會被清空，只能指向測試專用的資料庫)，未設定且沒有可用的 Docker 時跳過測試；
+// 基準測試同樣可以使用 (傳入 *testing.B)
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	if os.Getenv("TEST_DATABASE_URL") == "" {
+		skipWithoutDocker(t)
+	}
+	setupOnce.Do(func() {
+		setupDB, setupErr = setup(context.Background())
+	})
+	if setupErr != nil {
+		t.Fatalf("dbtest: %v", setupErr)
+	}
+	Reset(t, setupDB)
+	return setupDB
+}
+
+// skipWithoutDocker 與 testcontainers.SkipIfProviderIsNotHealthy 相同，但也接受 *testing.B
+func skipWithoutDocker(t testing.TB) {
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err == nil {
+		err = provider.Health(context.Background())
+	}
+	if err != nil {
+		t.Skipf("Docker is not running and TEST_DATABASE_URL is not set: %v", err)
+	}
+}
+
+// setup 連接資料庫 (必要時先啟動容器) 並執行遷移
+func setup(ctx context.Context) (*sql.DB, error) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		container, err := postgres.RunContainer(ctx,
+			testcontainers.WithImage(postgresImage),
+			postgres.WithDatabase("fastener_test"),
+			postgres.WithUsername("fastener"),
+			postgres.WithPassword("fastener"),
+			testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").WithOccurrence(2).WithStartupTimeout(time.Minute)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start postgres container: %w", err)
+		}
+		if url, err = container.ConnectionString(ctx, "sslmode=disable"); err != nil {
+			return nil, fmt.Errorf("failed to get container connection string: %w", err)
+		}
+	}
+
+	pool := db.DefaultPoolConfig
+	pool.ConnectAttempts = 5
+	db.Open(url, "", pool)
+	if err := db.Connect(ctx, db.DB, pool); err != nil {
+		return nil, err
+	}
+	if err := migrate(ctx, db.DB, migrationsDir()); err != nil {
+		return nil, err
+	}
+	return db.DB, nil
+}
+
+// migrationsDir 返回 db/migrations 的絕對路徑 (與測試執行的目錄無關)
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "migrations")
+}
+
+// migrate 依版本順序執行 dir 中的 *.up.sql，並與 migrate 工具相同地在 schema_migrations 記錄最新版本 (/readyz 的遷移檢查)
+// 已經執行過的資料庫 (TEST_DATABASE_URL 重複使用時) 只執行版本較新的遷移
+func migrate(ctx context.Context, database *sql.DB, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files) // 檔名以補零的版本開頭
+	if _, err := database.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, dirty BOOLEAN NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var current int64
+	if err := database.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= current {
+			continue
+		}
+		script, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		// 沒有參數的 Exec 以 simple protocol 執行，一個檔案可以包含多個語句
+		if _, err := database.ExecContext(ctx, string(script)); err != nil {
+			return fmt.Errorf("migration %s failed: %w", filepath.Base(file), err)
+		}
+		if _, err := database.ExecContext(ctx, `DELETE FROM schema_migrations; INSERT INTO schema_migrations (version, dirty) VALUES (`+prefix+`, false)`); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// Reset 清空 schema_migrations 以外的所有資料表並重設序號，包含遷移建立的初始角色、權限與選單；測試需要的資料以 Seed* 建立
+func Reset(t testing.TB, database *sql.DB) {
+	t.Helper()
+	ctx := context.Background()
+	rows, err := database.QueryContext(ctx, `SELECT quote_ident(tablename) FROM pg_tables WHERE schemaname = 'public' AND tablename <> 'schema_migrations'`)
+	if err != nil {
+		t.Fatalf("dbtest: failed to list tables: %v", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatalf("dbtest: failed to scan table name: %v", err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		t.Fatalf("dbtest: failed to list tables: %v", err)
+	}
+	if len(tables) == 0 {
+		return
+	}
+	if _, err := database.ExecContext(ctx, `TRUNCATE `+strings.Join(tables, ", ")+` RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatalf("dbtest: failed to truncate tables: %v", err)
+	}
+}
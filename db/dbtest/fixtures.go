@@ -0,0 +1,100 @@
+//go:build integration
+
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// SeedRole 建立角色並返回 ID
+func SeedRole(t *testing.T, database *sql.DB, name string) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO roles (name) VALUES ($1) RETURNING id`, name)
+}
+
+// SeedPermission 建立權限 (描述與名稱相同) 並返回 ID
+func SeedPermission(t *testing.T, database *sql.DB, name string) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO permissions (name, description) VALUES ($1, $1) RETURNING id`, name)
+}
+
+// GrantPermissions 將權限賦予角色，權限不存在時先建立
+func GrantPermissions(t *testing.T, database *sql.DB, roleID int, permissions ...string) {
+	t.Helper()
+	for _, name := range permissions {
+		permissionID := insertID(t, database,
+			`INSERT INTO permissions (name, description) VALUES ($1, $1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id`, name)
+		if _, err := database.ExecContext(context.Background(),
+			`INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, roleID, permissionID); err != nil {
+			t.Fatalf("dbtest: failed to grant %s to role %d: %v", name, roleID, err)
+		}
+	}
+}
+
+// SeedRoleWithPermissions 建立角色並賦予 permissions，返回角色 ID
+func SeedRoleWithPermissions(t *testing.T, database *sql.DB, name string, permissions ...string) int {
+	t.Helper()
+	roleID := SeedRole(t, database, name)
+	GrantPermissions(t, database, roleID, permissions...)
+	return roleID
+}
+
+// SeedAccount 建立屬於 roleID 的帳戶並返回 ID；密碼欄位為固定的佔位值，需要登入的測試使用 SeedAccountWithPassword
+func SeedAccount(t *testing.T, database *sql.DB, username string, roleID int) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO accounts (username, password, role_id) VALUES ($1, 'not-a-bcrypt-hash', $2) RETURNING id`, username, roleID)
+}
+
+// SeedAccountWithPassword 建立可以 password 登入的帳戶並返回 ID
+func SeedAccountWithPassword(t *testing.T, database *sql.DB, username, password string, roleID int) int {
+	t.Helper()
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatalf("dbtest: failed to hash password: %v", err)
+	}
+	return insertID(t, database, `INSERT INTO accounts (username, password, role_id) VALUES ($1, $2, $3) RETURNING id`, username, hashed, roleID)
+}
+
+// SeedCompany 建立公司並返回 ID，parentID 為 nil 時沒有父公司
+func SeedCompany(t *testing.T, database *sql.DB, name string, parentID *int) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO companies (name, parent_company_id) VALUES ($1, $2) RETURNING id`, name, parentID)
+}
+
+// SeedCustomer 建立客戶並返回 ID
+func SeedCustomer(t *testing.T, database *sql.DB, code, name string) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO customers (code, name) VALUES ($1, $2) RETURNING id`, code, name)
+}
+
+// SeedMenu 建立選單並返回 ID，icon 與初始遷移的選單相同為 NULL；parentID 為 nil 時為頂層選單
+func SeedMenu(t *testing.T, database *sql.DB, name, path string, parentID *int) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO menus (name, path, parent_id) VALUES ($1, $2, $3) RETURNING id`, name, path, parentID)
+}
+
+// SeedProductCategory 建立產品類別並返回 ID，parentID 為 nil 時為根類別
+func SeedProductCategory(t *testing.T, database *sql.DB, name string, parentID *int) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO product_categories (name, parent_id) VALUES ($1, $2) RETURNING id`, name, parentID)
+}
+
+// SeedProductDefinition 建立產品定義並返回 ID；sku 為空字串時為 NULL，price 為十進位字串 (例如 "1.25")
+func SeedProductDefinition(t *testing.T, database *sql.DB, name, sku string, categoryID int, price string) int {
+	t.Helper()
+	return insertID(t, database, `INSERT INTO product_definitions (name, sku, category_id, price) VALUES ($1, NULLIF($2, ''), $3, $4::numeric) RETURNING id`, name, sku, categoryID, price)
+}
+
+// insertID 執行 RETURNING id 的 INSERT 並返回 ID，失敗時結束測試
+func insertID(t *testing.T, database *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var id int
+	if err := database.QueryRowContext(context.Background(), query, args...).Scan(&id); err != nil {
+		t.Fatalf("dbtest: %s: %v", query, err)
+	}
+	return id
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // 只用於比較：切換到 pgx 之前使用的驅動
+)
+
+// benchmarkDrivers 比較的 database/sql 驅動：切換前的 lib/pq 與目前的 pgx (未經過追蹤與查詢日誌的包裝，兩者條件相同)
+var benchmarkDrivers = []struct {
+	name   string
+	driver string
+}{
+	{name: "lib/pq", driver: "postgres"},
+	{name: "pgx", driver: "pgx"},
+}
+
+// benchmarkDB 以 TEST_DATABASE_URL 開啟 driver 的連接池，未設定時跳過
+func benchmarkDB(b *testing.B, driver string) *sql.DB {
+	b.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		b.Skip("TEST_DATABASE_URL is not set")
+	}
+	database, err := sql.Open(driver, url)
+	if err != nil {
+		b.Fatalf("sql.Open(%s): %v", driver, err)
+	}
+	b.Cleanup(func() { database.Close() })
+	if err := database.PingContext(context.Background()); err != nil {
+		b.Fatalf("ping (%s): %v", driver, err)
+	}
+	return database
+}
+
+// BenchmarkDriverQueryRow 單列查詢 (依 ID 查詢的情況)，主要比較每次往返的開銷
+func BenchmarkDriverQueryRow(b *testing.B) {
+	for _, d := range benchmarkDrivers {
+		b.Run(d.name, func(b *testing.B) {
+			database := benchmarkDB(b, d.driver)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var n int
+				if err := database.QueryRowContext(ctx, "SELECT $1::int + 1", i).Scan(&n); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDriverQueryRows 100 列的列表查詢，欄位類型與客戶、產品列表相近 (整數、文字、numeric 與 timestamptz)
+func BenchmarkDriverQueryRows(b *testing.B) {
+	const query = `SELECT g, 'customer-' || g, (g * 1.25)::numeric(12, 2), now() - g * interval '1 hour'
+		FROM generate_series(1, $1::int) AS g`
+	for _, d := range benchmarkDrivers {
+		b.Run(d.name, func(b *testing.B) {
+			database := benchmarkDB(b, d.driver)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rows, err := database.QueryContext(ctx, query, 100)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for rows.Next() {
+					var (
+						id        int
+						name      string
+						price     string
+						createdAt time.Time
+					)
+					if err := rows.Scan(&id, &name, &price, &createdAt); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := rows.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDriverTx 交易內執行一個帶參數的語句後提交 (寫入路徑的 BEGIN、語句與 COMMIT 往返)
+func BenchmarkDriverTx(b *testing.B) {
+	for _, d := range benchmarkDrivers {
+		b.Run(d.name, func(b *testing.B) {
+			database := benchmarkDB(b, d.driver)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tx, err := database.BeginTx(ctx, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := tx.ExecContext(ctx, "SELECT set_config('application_name', $1, true)", "driver-benchmark"); err != nil {
+					tx.Rollback()
+					b.Fatal(err)
+				}
+				if err := tx.Commit(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
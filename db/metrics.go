@@ -0,0 +1,38 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/wac0705/fastener-api/metrics"
+)
+
+// RegisterPoolMetrics 註冊 database 連接池 (sql.DBStats) 的指標
+func RegisterPoolMetrics(reg metrics.Registry, database *sql.DB) {
+	reg.GaugeFunc("db_open_connections", "Number of established database connections, both in use and idle.", func() float64 {
+		return float64(database.Stats().OpenConnections)
+	})
+	reg.GaugeFunc("db_in_use_connections", "Number of database connections currently in use.", func() float64 {
+		return float64(database.Stats().InUse)
+	})
+	reg.GaugeFunc("db_idle_connections", "Number of idle database connections.", func() float64 {
+		return float64(database.Stats().Idle)
+	})
+	reg.GaugeFunc("db_max_open_connections", "Maximum number of open database connections.", func() float64 {
+		return float64(database.Stats().MaxOpenConnections)
+	})
+	reg.CounterFunc("db_wait_count_total", "Total number of connections waited for because the pool was exhausted.", func() float64 {
+		return float64(database.Stats().WaitCount)
+	})
+	reg.CounterFunc("db_wait_duration_seconds_total", "Total time blocked waiting for a new database connection.", func() float64 {
+		return database.Stats().WaitDuration.Seconds()
+	})
+	reg.CounterFunc("db_max_idle_closed_total", "Total number of connections closed due to DB_MAX_IDLE_CONNS.", func() float64 {
+		return float64(database.Stats().MaxIdleClosed)
+	})
+	reg.CounterFunc("db_max_idle_time_closed_total", "Total number of connections closed due to DB_CONN_MAX_IDLE_TIME.", func() float64 {
+		return float64(database.Stats().MaxIdleTimeClosed)
+	})
+	reg.CounterFunc("db_max_lifetime_closed_total", "Total number of connections closed due to DB_CONN_MAX_LIFETIME.", func() float64 {
+		return float64(database.Stats().MaxLifetimeClosed)
+	})
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LatestMigrationVersion 返回 dir 中最新的遷移版本 (檔名格式為 000001_name.up.sql)，用於 /readyz 判斷是否有尚未執行的遷移
+// 遷移由 migrate 工具在部署時執行，版本記錄於 schema_migrations 資料表
+func LatestMigrationVersion(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations directory %s: %w", dir, err)
+	}
+	var latest int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			continue
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			continue // 不是遷移檔案
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("no migrations found in %s", dir)
+	}
+	return latest, nil
+}
@@ -162,13 +162,14 @@ ON CONFLICT (role_id, permission_id) DO NOTHING;
 
 -- 初始選單數據 (範例)
 INSERT INTO menus (name, path, display_order) VALUES
-('儀表板', '/dashboard', 10) ON CONFLICT (path) DO NOTHING,
-('公司管理', '/dashboard/companies', 20) ON CONFLICT (path) DO NOTHING,
-('客戶管理', '/dashboard/customers', 30) ON CONFLICT (path) DO NOTHING,
-('產品定義', '/dashboard/product-definitions', 40) ON CONFLICT (path) DO NOTHING,
-('帳戶管理', '/dashboard/accounts', 50) ON CONFLICT (path) DO NOTHING,
-('選單管理', '/dashboard/menus', 60) ON CONFLICT (path) DO NOTHING,
-('角色選單', '/dashboard/role-menus', 70) ON CONFLICT (path) DO NOTHING;
+('儀表板', '/dashboard', 10),
+('公司管理', '/dashboard/companies', 20),
+('客戶管理', '/dashboard/customers', 30),
+('產品定義', '/dashboard/product-definitions', 40),
+('帳戶管理', '/dashboard/accounts', 50),
+('選單管理', '/dashboard/menus', 60),
+('角色選單', '/dashboard/role-menus', 70)
+ON CONFLICT (path) DO NOTHING;
 
 -- 將所有選單賦予 'admin' 角色 (初始設定)
 INSERT INTO role_menus (role_id, menu_id)
@@ -0,0 +1,15 @@
+-- db/migrations/000002_company_hierarchy.up.sql
+
+-- 為 companies 表新增父公司欄位，用於集團/子公司層級結構
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS parent_company_id INT;
+
+ALTER TABLE companies
+    ADD CONSTRAINT companies_parent_company_id_fkey
+    FOREIGN KEY (parent_company_id) REFERENCES companies(id) ON DELETE SET NULL;
+
+-- 公司不可以是自己的父公司 (更深層的循環由 Service 層檢查)
+ALTER TABLE companies
+    ADD CONSTRAINT companies_parent_not_self CHECK (parent_company_id IS NULL OR parent_company_id <> id);
+
+-- 加速查詢子公司與遞迴 CTE
+CREATE INDEX IF NOT EXISTS idx_companies_parent_company_id ON companies(parent_company_id);
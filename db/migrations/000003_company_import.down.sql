@@ -0,0 +1,9 @@
+-- db/migrations/000003_company_import.down.sql
+
+DELETE FROM permissions WHERE name = 'company:import';
+
+DROP INDEX IF EXISTS companies_tax_id_key;
+
+ALTER TABLE companies DROP COLUMN IF EXISTS currency;
+ALTER TABLE companies DROP COLUMN IF EXISTS country;
+ALTER TABLE companies DROP COLUMN IF EXISTS tax_id;
@@ -0,0 +1,18 @@
+-- db/migrations/000003_company_import.up.sql
+
+-- 為 companies 表新增統一編號、國家與幣別欄位 (舊 ERP 匯入所需)
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS tax_id VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS country CHAR(2) NOT NULL DEFAULT '';   -- ISO 3166-1 alpha-2
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT '';  -- ISO 4217
+
+-- 統一編號非空時必須唯一 (匯入時以名稱或統一編號比對既有公司)
+CREATE UNIQUE INDEX IF NOT EXISTS companies_tax_id_key ON companies(tax_id) WHERE tax_id <> '';
+
+-- 公司匯入權限
+INSERT INTO permissions (name, description) VALUES ('company:import', 'Allow importing companies from CSV') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'company:import'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
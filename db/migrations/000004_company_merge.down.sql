@@ -0,0 +1,16 @@
+-- db/migrations/000004_company_merge.down.sql
+
+DELETE FROM permissions WHERE name = 'company:merge';
+
+DROP TABLE IF EXISTS company_merges;
+
+-- 恢復全表唯一約束前必須移除已軟刪除的公司，否則可能與現有名稱衝突
+DELETE FROM companies WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS companies_tax_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS companies_tax_id_key ON companies(tax_id) WHERE tax_id <> '';
+DROP INDEX IF EXISTS companies_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_name_key UNIQUE (name);
+
+ALTER TABLE companies DROP COLUMN IF EXISTS merged_into_company_id;
+ALTER TABLE companies DROP COLUMN IF EXISTS deleted_at;
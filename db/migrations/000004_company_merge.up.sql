@@ -0,0 +1,36 @@
+-- db/migrations/000004_company_merge.up.sql
+
+-- 公司軟刪除 (合併後的來源公司保留紀錄，不再出現在查詢中)
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS merged_into_company_id INT REFERENCES companies(id) ON DELETE SET NULL;
+
+-- 名稱與統一編號只需在未刪除的公司間唯一，沿用原本的約束名稱以保持錯誤判斷一致
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS companies_name_key ON companies(name) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS companies_tax_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS companies_tax_id_key ON companies(tax_id) WHERE tax_id <> '' AND deleted_at IS NULL;
+
+-- 建立 company_merges 表 (公司合併歷史紀錄)
+CREATE TABLE IF NOT EXISTS company_merges (
+    id SERIAL PRIMARY KEY,
+    source_company_id INT NOT NULL, -- 被合併 (軟刪除) 的公司
+    target_company_id INT NOT NULL, -- 合併後保留的公司
+    moved_customers INT NOT NULL DEFAULT 0,
+    moved_child_companies INT NOT NULL DEFAULT 0,
+    merged_by INT, -- 執行合併的帳戶 ID
+    merged_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    FOREIGN KEY (merged_by) REFERENCES accounts(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_company_merges_target_company_id ON company_merges(target_company_id);
+
+-- 公司合併權限
+INSERT INTO permissions (name, description) VALUES ('company:merge', 'Allow merging duplicate companies') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'company:merge'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- db/migrations/000005_customer_email_unique.down.sql
+
+DROP INDEX IF EXISTS customers_email_lower_key;
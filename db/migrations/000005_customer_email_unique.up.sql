@@ -0,0 +1,5 @@
+-- db/migrations/000005_customer_email_unique.up.sql
+
+-- 客戶 Email 不分大小寫唯一；空 Email 允許重複
+-- 注意：若現有資料已有重複的 Email，需先手動合併或清理，否則建立索引會失敗
+CREATE UNIQUE INDEX IF NOT EXISTS customers_email_lower_key ON customers (lower(email)) WHERE email IS NOT NULL AND email <> '';
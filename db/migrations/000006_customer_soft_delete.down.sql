@@ -0,0 +1,11 @@
+-- db/migrations/000006_customer_soft_delete.down.sql
+
+DELETE FROM permissions WHERE name IN ('customer:restore', 'customer:read_deleted');
+
+-- 回滾後無法區分已刪除的客戶，直接移除這些記錄
+DELETE FROM customers WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS customers_email_lower_key;
+CREATE UNIQUE INDEX IF NOT EXISTS customers_email_lower_key ON customers (lower(email)) WHERE email IS NOT NULL AND email <> '';
+
+ALTER TABLE customers DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,18 @@
+-- db/migrations/000006_customer_soft_delete.up.sql
+
+-- 客戶軟刪除：刪除時只設定 deleted_at，保留與其他系統 (報價/訂單) 的歷史關聯，並可還原
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Email 唯一性只在未刪除的客戶間檢查；還原時若 Email 已被使用會回報衝突
+DROP INDEX IF EXISTS customers_email_lower_key;
+CREATE UNIQUE INDEX IF NOT EXISTS customers_email_lower_key ON customers (lower(email)) WHERE email IS NOT NULL AND email <> '' AND deleted_at IS NULL;
+
+-- 還原與查看已刪除客戶的權限
+INSERT INTO permissions (name, description) VALUES ('customer:restore', 'Allow restoring deleted customers') ON CONFLICT (name) DO NOTHING;
+INSERT INTO permissions (name, description) VALUES ('customer:read_deleted', 'Allow listing deleted customers') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name IN ('customer:restore', 'customer:read_deleted')
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- db/migrations/000007_customer_addresses.down.sql
+
+DROP TABLE IF EXISTS customer_addresses;
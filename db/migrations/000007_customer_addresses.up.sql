@@ -0,0 +1,24 @@
+-- db/migrations/000007_customer_addresses.up.sql
+
+-- 建立 customer_addresses 表 (客戶的帳單/送貨地址)
+-- 客戶軟刪除時地址保留，還原後即可繼續使用
+CREATE TABLE IF NOT EXISTS customer_addresses (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('billing', 'shipping')),
+    line1 VARCHAR(255) NOT NULL,
+    line2 VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL DEFAULT '',
+    country CHAR(2) NOT NULL, -- ISO 3166-1 alpha-2
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_addresses_customer_id ON customer_addresses(customer_id);
+
+-- 每位客戶每種類型最多一個預設地址 (切換預設由 Service 層處理，此索引作為最後防線)
+CREATE UNIQUE INDEX IF NOT EXISTS customer_addresses_default_key ON customer_addresses(customer_id, type) WHERE is_default;
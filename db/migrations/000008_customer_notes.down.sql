@@ -0,0 +1,3 @@
+-- db/migrations/000008_customer_notes.down.sql
+
+DROP TABLE IF EXISTS customer_notes;
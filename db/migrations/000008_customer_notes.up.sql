@@ -0,0 +1,16 @@
+-- db/migrations/000008_customer_notes.up.sql
+
+-- 建立 customer_notes 表 (客戶備註/活動時間軸)
+CREATE TABLE IF NOT EXISTS customer_notes (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL,
+    author_id INT, -- 撰寫備註的帳戶 ID，帳戶刪除後保留備註
+    body TEXT NOT NULL,
+    pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES accounts(id) ON DELETE SET NULL
+);
+
+-- 時間軸分頁與客戶的 last_note_at 都依 created_at 排序
+CREATE INDEX IF NOT EXISTS idx_customer_notes_customer_id_created_at ON customer_notes(customer_id, created_at DESC);
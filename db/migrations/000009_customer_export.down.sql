@@ -0,0 +1,3 @@
+-- db/migrations/000009_customer_export.down.sql
+
+DELETE FROM permissions WHERE name = 'customer:export';
@@ -0,0 +1,10 @@
+-- db/migrations/000009_customer_export.up.sql
+
+-- 客戶 CSV 匯出權限
+INSERT INTO permissions (name, description) VALUES ('customer:export', 'Allow exporting customers to CSV') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'customer:export'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- db/migrations/000010_customer_import.down.sql
+
+DELETE FROM permissions WHERE name = 'customer:import';
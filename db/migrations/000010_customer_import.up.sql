@@ -0,0 +1,10 @@
+-- db/migrations/000010_customer_import.up.sql
+
+-- 客戶 CSV 匯入權限
+INSERT INTO permissions (name, description) VALUES ('customer:import', 'Allow importing customers from CSV') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'customer:import'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,4 @@
+-- db/migrations/000011_customer_currency_payment_terms.down.sql
+
+ALTER TABLE customers DROP COLUMN IF EXISTS payment_terms;
+ALTER TABLE customers DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,5 @@
+-- db/migrations/000011_customer_currency_payment_terms.up.sql
+
+-- 客戶的預設幣別與付款條件 (報價時使用)
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT '';           -- ISO 4217
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS payment_terms VARCHAR(20) NOT NULL DEFAULT '';  -- 例如 NET30、NET60，可選值由 PAYMENT_TERMS 設定
@@ -0,0 +1,7 @@
+-- db/migrations/000012_customer_sales_rep.down.sql
+
+DELETE FROM permissions WHERE name = 'customer:read_own';
+
+DROP INDEX IF EXISTS idx_customers_sales_rep_account_id;
+ALTER TABLE customers DROP COLUMN IF EXISTS sales_rep_account_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS is_active;
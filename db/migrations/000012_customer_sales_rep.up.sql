@@ -0,0 +1,17 @@
+-- db/migrations/000012_customer_sales_rep.up.sql
+
+-- 帳戶啟用狀態 (停用的帳戶不可再被指派為業務代表)
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- 負責該客戶的業務代表
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS sales_rep_account_id INT REFERENCES accounts(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_customers_sales_rep_account_id ON customers(sales_rep_account_id);
+
+-- 只能讀取指派給自己的客戶
+INSERT INTO permissions (name, description) VALUES ('customer:read_own', 'Allow reading customers assigned to the current account') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'customer:read_own'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,4 @@
+-- db/migrations/000013_customer_phone_normalized.down.sql
+
+DROP INDEX IF EXISTS idx_customers_phone_normalized;
+ALTER TABLE customers DROP COLUMN IF EXISTS phone_normalized;
@@ -0,0 +1,11 @@
+-- db/migrations/000013_customer_phone_normalized.up.sql
+
+-- E.164 格式的電話號碼，原始輸入仍保留在 phone
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS phone_normalized VARCHAR(16);
+CREATE INDEX IF NOT EXISTS idx_customers_phone_normalized ON customers(phone_normalized);
+
+-- 既有資料只回填已是國際格式的號碼，本地格式需待下次更新時由應用程式依預設國家轉換
+UPDATE customers
+SET phone_normalized = '+' || regexp_replace(phone, '[^0-9]', '', 'g')
+WHERE phone LIKE '+%'
+  AND length(regexp_replace(phone, '[^0-9]', '', 'g')) BETWEEN 8 AND 15;
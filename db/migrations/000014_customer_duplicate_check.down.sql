@@ -0,0 +1,4 @@
+-- db/migrations/000014_customer_duplicate_check.down.sql
+
+DROP INDEX IF EXISTS idx_customers_name_trgm;
+-- pg_trgm 可能被其他物件使用，保留擴充套件
@@ -0,0 +1,6 @@
+-- db/migrations/000014_customer_duplicate_check.up.sql
+
+-- 名稱相似度比對 (similarity) 需要 pg_trgm
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_customers_name_trgm ON customers USING gin (name gin_trgm_ops) WHERE deleted_at IS NULL;
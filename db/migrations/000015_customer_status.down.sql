@@ -0,0 +1,6 @@
+-- db/migrations/000015_customer_status.down.sql
+
+DROP TABLE IF EXISTS customer_history;
+
+DROP INDEX IF EXISTS idx_customers_status;
+ALTER TABLE customers DROP COLUMN IF EXISTS status;
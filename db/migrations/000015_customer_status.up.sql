@@ -0,0 +1,21 @@
+-- db/migrations/000015_customer_status.up.sql
+
+-- 客戶生命週期狀態，既有客戶視為往來中，新客戶預設為潛在客戶
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('prospect', 'active', 'inactive'));
+ALTER TABLE customers ALTER COLUMN status SET DEFAULT 'prospect';
+CREATE INDEX IF NOT EXISTS idx_customers_status ON customers(status);
+
+-- 客戶欄位變更歷史
+CREATE TABLE IF NOT EXISTS customer_history (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    event VARCHAR(20) NOT NULL,
+    field VARCHAR(50) NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    reason TEXT,
+    actor_account_id INT REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_customer_history_customer_id ON customer_history(customer_id, created_at DESC);
@@ -0,0 +1,5 @@
+-- db/migrations/000016_customer_code.down.sql
+
+DROP INDEX IF EXISTS customers_code_key;
+ALTER TABLE customers DROP COLUMN IF EXISTS code;
+DROP TABLE IF EXISTS customer_code_sequences;
@@ -0,0 +1,19 @@
+-- db/migrations/000016_customer_code.up.sql
+
+-- 每個前綴各自遞增的客戶代碼序號
+CREATE TABLE IF NOT EXISTS customer_code_sequences (
+    prefix VARCHAR(10) PRIMARY KEY,
+    last_value BIGINT NOT NULL DEFAULT 0
+);
+
+-- 客戶代碼 (例如 "C-000123")，建立後不可修改；已軟刪除的客戶也保留代碼，因此唯一索引不排除 deleted_at
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS code VARCHAR(32);
+
+-- 既有客戶以預設前綴 C 與 ID 回填代碼，並將序號推進到目前最大 ID
+UPDATE customers SET code = 'C-' || lpad(id::text, 6, '0') WHERE code IS NULL;
+INSERT INTO customer_code_sequences (prefix, last_value)
+SELECT 'C', COALESCE(MAX(id), 0) FROM customers
+ON CONFLICT (prefix) DO UPDATE SET last_value = GREATEST(customer_code_sequences.last_value, EXCLUDED.last_value);
+
+ALTER TABLE customers ALTER COLUMN code SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS customers_code_key ON customers(code);
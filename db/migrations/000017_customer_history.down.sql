@@ -0,0 +1,7 @@
+-- db/migrations/000017_customer_history.down.sql
+
+DELETE FROM permissions WHERE name = 'customer:read_history';
+
+DROP INDEX IF EXISTS idx_customer_history_field;
+DELETE FROM customer_history WHERE field IS NULL;
+ALTER TABLE customer_history ALTER COLUMN field SET NOT NULL;
@@ -0,0 +1,14 @@
+-- db/migrations/000017_customer_history.up.sql
+
+-- created/deleted/restored 事件沒有對應的欄位
+ALTER TABLE customer_history ALTER COLUMN field DROP NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_customer_history_field ON customer_history(customer_id, field);
+
+-- 查看客戶變更歷史的權限
+INSERT INTO permissions (name, description) VALUES ('customer:read_history', 'Allow viewing customer change history') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'customer:read_history'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,4 @@
+-- db/migrations/000018_product_definition_filters.down.sql
+
+DROP INDEX IF EXISTS idx_product_definitions_name;
+DROP INDEX IF EXISTS idx_product_definitions_category_id;
@@ -0,0 +1,5 @@
+-- db/migrations/000018_product_definition_filters.up.sql
+
+-- 產品定義列表依類別篩選與排序
+CREATE INDEX IF NOT EXISTS idx_product_definitions_category_id ON product_definitions(category_id);
+CREATE INDEX IF NOT EXISTS idx_product_definitions_name ON product_definitions(name);
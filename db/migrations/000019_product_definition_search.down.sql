@@ -0,0 +1,3 @@
+-- db/migrations/000019_product_definition_search.down.sql
+
+DROP INDEX IF EXISTS idx_product_definitions_search_trgm;
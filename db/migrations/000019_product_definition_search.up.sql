@@ -0,0 +1,20 @@
+-- db/migrations/000019_product_definition_search.up.sql
+
+-- 產品全文搜尋使用 pg_trgm；沒有建立擴充套件的權限時略過，應用程式會在啟動時偵測並退回 ILIKE
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege THEN
+    RAISE NOTICE 'pg_trgm is not available, product search falls back to ILIKE';
+END
+$$;
+
+-- 運算式需與 repository.productSearchDocument 一致
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_product_definitions_search_trgm ON product_definitions
+            USING gin ((name || ' ' || coalesce(description, '') || ' ' || coalesce(unit, '')) gin_trgm_ops);
+    END IF;
+END
+$$;
@@ -0,0 +1,14 @@
+-- db/migrations/000020_product_definition_standard.down.sql
+
+DROP INDEX IF EXISTS idx_product_definitions_search_trgm;
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_product_definitions_search_trgm ON product_definitions
+            USING gin ((name || ' ' || coalesce(description, '') || ' ' || coalesce(unit, '')) gin_trgm_ops);
+    END IF;
+END
+$$;
+
+DROP INDEX IF EXISTS idx_product_definitions_standard;
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS standard;
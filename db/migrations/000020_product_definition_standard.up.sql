@@ -0,0 +1,17 @@
+-- db/migrations/000020_product_definition_standard.up.sql
+
+-- 產品依循的標準代號，儲存正規化後的值 (例如 "DIN 933"、"ISO 4017")
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS standard VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_product_definitions_standard ON product_definitions (standard);
+
+-- 全文搜尋加入標準代號，運算式需與 repository.productSearchDocument 一致
+DROP INDEX IF EXISTS idx_product_definitions_search_trgm;
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_product_definitions_search_trgm ON product_definitions
+            USING gin ((name || ' ' || coalesce(description, '') || ' ' || coalesce(unit, '') || ' ' || coalesce(standard, '')) gin_trgm_ops);
+    END IF;
+END
+$$;
@@ -0,0 +1,3 @@
+-- db/migrations/000021_product_prices.down.sql
+
+DROP TABLE IF EXISTS product_prices;
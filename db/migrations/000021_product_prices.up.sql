@@ -0,0 +1,14 @@
+-- db/migrations/000021_product_prices.up.sql
+
+-- 產品定義在各幣別的價格；product_definitions.price 仍為基準幣別的價格
+-- 同一產品與幣別可有多筆不同生效日的價格，查詢時取 valid_from <= 今天中最新的一筆
+CREATE TABLE IF NOT EXISTS product_prices (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES product_definitions(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    price DECIMAL(12, 4) NOT NULL CHECK (price >= 0),
+    valid_from DATE NOT NULL DEFAULT CURRENT_DATE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT product_prices_product_currency_valid_from_key UNIQUE (product_id, currency, valid_from)
+);
@@ -0,0 +1,3 @@
+-- db/migrations/000022_product_price_tiers.down.sql
+
+DROP TABLE IF EXISTS product_price_tiers;
@@ -0,0 +1,11 @@
+-- db/migrations/000022_product_price_tiers.up.sql
+
+-- 產品依數量分級的單價 (基準幣別)；每一級自 min_qty 起適用，直到下一級的 min_qty 為止
+CREATE TABLE IF NOT EXISTS product_price_tiers (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES product_definitions(id) ON DELETE CASCADE,
+    min_qty INT NOT NULL CHECK (min_qty >= 1),
+    price DECIMAL(12, 4) NOT NULL CHECK (price >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT product_price_tiers_product_min_qty_key UNIQUE (product_id, min_qty)
+);
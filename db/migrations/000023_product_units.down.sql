@@ -0,0 +1,3 @@
+-- db/migrations/000023_product_units.down.sql
+
+DROP TABLE IF EXISTS product_units;
@@ -0,0 +1,12 @@
+-- db/migrations/000023_product_units.up.sql
+
+-- 產品的換算單位；product_definitions.unit 為標準單位 (例如 pcs)
+-- factor 為 1 個該單位等於多少個標準單位，例如 kg => 250 (每公斤 250 支)、per1000 => 1000
+CREATE TABLE IF NOT EXISTS product_units (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES product_definitions(id) ON DELETE CASCADE,
+    unit VARCHAR(50) NOT NULL,
+    factor DECIMAL(18, 8) NOT NULL CHECK (factor > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT product_units_product_unit_key UNIQUE (product_id, unit)
+);
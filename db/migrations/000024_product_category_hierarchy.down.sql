@@ -0,0 +1,4 @@
+-- db/migrations/000024_product_category_hierarchy.down.sql
+
+DROP INDEX IF EXISTS idx_product_categories_parent_id;
+ALTER TABLE product_categories DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,7 @@
+-- db/migrations/000024_product_category_hierarchy.up.sql
+
+-- 產品類別改為樹狀結構 (例如 Bolts > Hex Bolts > Flange Bolts)，parent_id 為 NULL 表示根類別
+-- 外鍵使用預設的 NO ACTION，讓整棵子樹可以在同一個 DELETE 語句中刪除
+ALTER TABLE product_categories ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES product_categories(id);
+
+CREATE INDEX IF NOT EXISTS idx_product_categories_parent_id ON product_categories (parent_id);
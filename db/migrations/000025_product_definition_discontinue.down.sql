@@ -0,0 +1,7 @@
+-- db/migrations/000025_product_definition_discontinue.down.sql
+
+DELETE FROM permissions WHERE name = 'product_definition:reactivate';
+
+-- 回滾後停售的產品定義會重新出現在列表中 (仍保留給歷史報價引用，不刪除記錄)
+DROP INDEX IF EXISTS idx_product_definitions_discontinued_at;
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS discontinued_at;
@@ -0,0 +1,15 @@
+-- db/migrations/000025_product_definition_discontinue.up.sql
+
+-- 產品定義停售 (軟刪除)：刪除時只設定 discontinued_at，保留給歷史報價引用，並可重新啟用
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS discontinued_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_product_definitions_discontinued_at ON product_definitions (discontinued_at);
+
+-- 重新啟用已停售產品的權限
+INSERT INTO permissions (name, description) VALUES ('product_definition:reactivate', 'Allow reactivating discontinued product definitions') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'product_definition:reactivate'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
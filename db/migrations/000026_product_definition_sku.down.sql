@@ -0,0 +1,4 @@
+-- db/migrations/000026_product_definition_sku.down.sql
+
+DROP INDEX IF EXISTS product_definitions_sku_key;
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS sku;
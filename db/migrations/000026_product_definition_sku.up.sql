@@ -0,0 +1,6 @@
+-- db/migrations/000026_product_definition_sku.up.sql
+
+-- 產品料號 (SKU)；既有產品可不填，有填時必須唯一
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_definitions_sku_key ON product_definitions (sku) WHERE sku IS NOT NULL;
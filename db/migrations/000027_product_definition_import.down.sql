@@ -0,0 +1,3 @@
+-- db/migrations/000027_product_definition_import.down.sql
+
+DELETE FROM permissions WHERE name = 'product_definition:import';
@@ -0,0 +1,10 @@
+-- db/migrations/000027_product_definition_import.up.sql
+
+-- 產品定義 CSV / XLSX 匯入權限
+INSERT INTO permissions (name, description) VALUES ('product_definition:import', 'Allow importing product definitions from CSV or XLSX') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'product_definition:import'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
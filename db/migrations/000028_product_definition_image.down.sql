@@ -0,0 +1,6 @@
+-- db/migrations/000028_product_definition_image.down.sql
+
+-- 回滾後 FileStore 中已上傳的圖片檔案不會被刪除，需要時請另行清理
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS image_updated_at;
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS image_content_type;
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS image_key;
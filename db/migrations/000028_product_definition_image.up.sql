@@ -0,0 +1,6 @@
+-- db/migrations/000028_product_definition_image.up.sql
+
+-- 產品圖片：檔案本身存放於 FileStore (本機目錄或 S3)，此處只記錄儲存的 key、格式與上傳時間
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS image_key VARCHAR(255);
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS image_content_type VARCHAR(100);
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS image_updated_at TIMESTAMP WITH TIME ZONE;
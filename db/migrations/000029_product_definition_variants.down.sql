@@ -0,0 +1,5 @@
+-- db/migrations/000029_product_definition_variants.down.sql
+
+-- 回滾後變體成為各自獨立的產品定義
+DROP INDEX IF EXISTS idx_product_definitions_parent_definition_id;
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS parent_definition_id;
@@ -0,0 +1,6 @@
+-- db/migrations/000029_product_definition_variants.up.sql
+
+-- 產品變體：同一規格不同長度等的產品掛在父產品定義之下 (只有一層，變體不能再有變體)
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS parent_definition_id INT REFERENCES product_definitions(id);
+
+CREATE INDEX IF NOT EXISTS idx_product_definitions_parent_definition_id ON product_definitions (parent_definition_id);
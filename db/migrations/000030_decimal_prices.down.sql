@@ -0,0 +1,5 @@
+-- db/migrations/000030_decimal_prices.down.sql
+
+-- 回滾時價格四捨五入到小數 2 位
+ALTER TABLE product_definitions DROP CONSTRAINT IF EXISTS product_definitions_price_check;
+ALTER TABLE product_definitions ALTER COLUMN price TYPE DECIMAL(10, 2) USING ROUND(price, 2);
@@ -0,0 +1,8 @@
+-- db/migrations/000030_decimal_prices.up.sql
+
+-- 價格統一為 NUMERIC(12, 4)，與 product_prices、product_price_tiers 相同，應用程式以十進位定點數處理，不再經過 float64
+-- 既有的 DECIMAL(10, 2) 資料可無損轉換
+ALTER TABLE product_definitions ALTER COLUMN price TYPE NUMERIC(12, 4);
+
+ALTER TABLE product_definitions DROP CONSTRAINT IF EXISTS product_definitions_price_check;
+ALTER TABLE product_definitions ADD CONSTRAINT product_definitions_price_check CHECK (price >= 0);
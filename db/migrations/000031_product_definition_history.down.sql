@@ -0,0 +1,5 @@
+-- db/migrations/000031_product_definition_history.down.sql
+
+DELETE FROM permissions WHERE name = 'product_definition:read_history';
+
+DROP TABLE IF EXISTS product_definition_history;
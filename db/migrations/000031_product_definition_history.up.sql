@@ -0,0 +1,23 @@
+-- db/migrations/000031_product_definition_history.up.sql
+
+-- 產品定義欄位變更歷史，與變更在同一事務中寫入
+CREATE TABLE IF NOT EXISTS product_definition_history (
+    id SERIAL PRIMARY KEY,
+    product_definition_id INT NOT NULL REFERENCES product_definitions(id) ON DELETE CASCADE,
+    event VARCHAR(20) NOT NULL,
+    field VARCHAR(50),
+    old_value TEXT,
+    new_value TEXT,
+    actor_account_id INT REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_product_definition_history_product ON product_definition_history(product_definition_id, created_at DESC);
+
+-- 查看產品定義變更歷史的權限
+INSERT INTO permissions (name, description) VALUES ('product_definition:read_history', 'Allow viewing product definition change history') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'product_definition:read_history'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
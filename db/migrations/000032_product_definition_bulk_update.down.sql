@@ -0,0 +1,3 @@
+-- db/migrations/000032_product_definition_bulk_update.down.sql
+
+DELETE FROM permissions WHERE name = 'product_definition:bulk_update';
@@ -0,0 +1,10 @@
+-- db/migrations/000032_product_definition_bulk_update.up.sql
+
+-- 批次調整產品定義價格的權限
+INSERT INTO permissions (name, description) VALUES ('product_definition:bulk_update', 'Allow bulk updating product definition prices') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'product_definition:bulk_update'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- db/migrations/000033_product_definition_name_unique.down.sql
+
+DROP INDEX IF EXISTS product_definitions_category_name_key;
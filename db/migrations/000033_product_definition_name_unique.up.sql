@@ -0,0 +1,5 @@
+-- db/migrations/000033_product_definition_name_unique.up.sql
+
+-- 同一類別中產品名稱不分大小寫唯一 (包含已停售的產品)；名稱需與 repository.productDefinitionNameConstraint 一致
+-- 注意：若現有資料在同一類別中已有重複的名稱，需先手動改名或合併，否則建立索引會失敗
+CREATE UNIQUE INDEX IF NOT EXISTS product_definitions_category_name_key ON product_definitions (category_id, lower(name));
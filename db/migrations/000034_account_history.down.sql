@@ -0,0 +1,5 @@
+-- db/migrations/000034_account_history.down.sql
+
+DELETE FROM permissions WHERE name = 'role:create';
+
+DROP TABLE IF EXISTS account_history;
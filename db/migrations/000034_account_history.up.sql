@@ -0,0 +1,23 @@
+-- db/migrations/000034_account_history.up.sql
+
+-- 帳戶變更記錄 (稽核)，與變更在同一事務中寫入
+CREATE TABLE IF NOT EXISTS account_history (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    event VARCHAR(20) NOT NULL,
+    field VARCHAR(50),
+    old_value TEXT,
+    new_value TEXT,
+    actor_account_id INT REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_account_history_account ON account_history(account_id, created_at DESC);
+
+-- 複製角色 (POST /roles/:roleID/clone) 的權限
+INSERT INTO permissions (name, description) VALUES ('role:create', 'Allow creating roles by cloning an existing role') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'role:create'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
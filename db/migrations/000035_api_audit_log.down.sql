@@ -0,0 +1,5 @@
+-- db/migrations/000035_api_audit_log.down.sql
+
+DELETE FROM permissions WHERE name = 'audit:read';
+
+DROP TABLE IF EXISTS api_audit_log;
@@ -0,0 +1,29 @@
+-- db/migrations/000035_api_audit_log.up.sql
+
+-- 已驗證請求中所有寫入操作 (POST、PUT、PATCH、DELETE) 的稽核記錄，由稽核中介軟體在背景批次寫入
+CREATE TABLE IF NOT EXISTS api_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    request_id VARCHAR(64),
+    actor_account_id INT REFERENCES accounts(id) ON DELETE SET NULL,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL, -- 路由樣板，例如 /api/v1/customers/:id
+    path TEXT NOT NULL,          -- 實際請求的路徑
+    entity_id VARCHAR(64),       -- 路由中最後一個路徑參數的值 (例如 :id)，新增資源的請求為 NULL
+    status INT NOT NULL,
+    latency_ms INT NOT NULL,
+    remote_ip VARCHAR(64),
+    body JSONB,                  -- 只有選擇記錄請求內容的路由才有值，敏感欄位已遮蔽
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_api_audit_log_created_at ON api_audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_api_audit_log_actor ON api_audit_log(actor_account_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_api_audit_log_path ON api_audit_log(path text_pattern_ops);
+
+-- 查詢稽核記錄的權限
+INSERT INTO permissions (name, description) VALUES ('audit:read', 'Allow reading the API audit log') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'audit:read'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
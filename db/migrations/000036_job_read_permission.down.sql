@@ -0,0 +1,3 @@
+-- db/migrations/000036_job_read_permission.down.sql
+
+DELETE FROM permissions WHERE name = 'job:read';
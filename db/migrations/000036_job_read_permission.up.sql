@@ -0,0 +1,10 @@
+-- db/migrations/000036_job_read_permission.up.sql
+
+-- 查詢背景工作狀態 (GET /api/v1/admin/jobs) 的權限
+INSERT INTO permissions (name, description) VALUES ('job:read', 'Allow reading background job status') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'job:read'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- db/migrations/000037_cache_flush_permission.down.sql
+
+DELETE FROM permissions WHERE name = 'cache:flush';
@@ -0,0 +1,10 @@
+-- db/migrations/000037_cache_flush_permission.up.sql
+
+-- 清除讀取緩存 (POST /api/v1/admin/cache/flush) 的權限
+INSERT INTO permissions (name, description) VALUES ('cache:flush', 'Allow flushing the read cache') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'cache:flush'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
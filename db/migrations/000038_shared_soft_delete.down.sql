@@ -0,0 +1,13 @@
+-- db/migrations/000038_shared_soft_delete.down.sql
+
+-- 恢復包含已停售產品的名稱唯一索引；若停售與未停售的產品已有重複的名稱，需先手動改名，否則建立索引會失敗
+DROP INDEX IF EXISTS product_definitions_category_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS product_definitions_category_name_key ON product_definitions (category_id, lower(name));
+
+-- 恢復全表唯一約束前必須移除已軟刪除的帳戶，否則可能與現有用戶名衝突
+DELETE FROM accounts WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS accounts_username_key;
+ALTER TABLE accounts ADD CONSTRAINT accounts_username_key UNIQUE (username);
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,13 @@
+-- db/migrations/000038_shared_soft_delete.up.sql
+
+-- 帳戶軟刪除：刪除時只設定 deleted_at，保留業務代表、稽核等記錄的引用，並可還原
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- 用戶名只需在未刪除的帳戶間唯一，沿用原本的約束名稱以保持錯誤判斷一致；還原時若用戶名已被使用會回報衝突
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_username_key;
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_username_key ON accounts(username) WHERE deleted_at IS NULL;
+
+-- 產品定義以 discontinued_at 作為軟刪除 (停售)：同一類別中的名稱只需在未停售的產品間唯一，重新啟用時若名稱已被使用會回報衝突
+-- SKU 與客戶代碼相同，停售後仍保留，唯一索引不排除已停售的產品
+DROP INDEX IF EXISTS product_definitions_category_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS product_definitions_category_name_key ON product_definitions (category_id, lower(name)) WHERE discontinued_at IS NULL;
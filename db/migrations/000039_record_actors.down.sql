@@ -0,0 +1,16 @@
+-- db/migrations/000039_record_actors.down.sql
+
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE roles DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE roles DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE menus DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE menus DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE companies DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE companies DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE customers DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE customers DROP COLUMN IF EXISTS created_by;
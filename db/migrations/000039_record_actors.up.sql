@@ -0,0 +1,17 @@
+-- db/migrations/000039_record_actors.up.sql
+
+-- 記錄建立與最後修改記錄的帳戶；由已驗證的使用者寫入時填入，種子資料等系統寫入與既有的記錄為 NULL
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS created_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS updated_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS created_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS updated_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+
+ALTER TABLE menus ADD COLUMN IF NOT EXISTS created_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+ALTER TABLE menus ADD COLUMN IF NOT EXISTS updated_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS created_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS updated_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS created_by INT REFERENCES accounts(id) ON DELETE SET NULL;
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS updated_by INT REFERENCES accounts(id) ON DELETE SET NULL;
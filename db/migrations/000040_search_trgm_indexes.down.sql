@@ -0,0 +1,10 @@
+-- db/migrations/000040_search_trgm_indexes.down.sql
+
+DROP INDEX IF EXISTS idx_product_definitions_sku_trgm;
+DROP INDEX IF EXISTS idx_product_definitions_description_trgm;
+DROP INDEX IF EXISTS idx_product_definitions_name_trgm;
+DROP INDEX IF EXISTS idx_customers_phone_normalized_trgm;
+DROP INDEX IF EXISTS idx_customers_phone_trgm;
+DROP INDEX IF EXISTS idx_customers_email_trgm;
+DROP INDEX IF EXISTS idx_customers_contact_person_trgm;
+-- pg_trgm 可能被其他物件使用，保留擴充套件
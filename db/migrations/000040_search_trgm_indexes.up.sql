@@ -0,0 +1,36 @@
+-- db/migrations/000040_search_trgm_indexes.up.sql
+
+-- 客戶與產品的關鍵字搜尋 (q) 以 ILIKE '%...%' 比對多個欄位，條件以 OR 組合：每個欄位都有 trigram 索引時才能以 BitmapOr 使用索引，否則整張表掃描
+-- 沒有建立擴充套件的權限時略過，應用程式會在啟動時偵測並記錄警告，搜尋仍可使用 (較慢)
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege THEN
+    RAISE NOTICE 'pg_trgm is not available, customer and product searches fall back to sequential scans';
+END
+$$;
+
+-- 客戶：欄位需與 repository.buildCustomerWhere 的 q 條件一致；名稱已有 idx_customers_name_trgm (000014)
+-- 與名稱索引相同為未刪除客戶的部分索引，列表預設只查詢未刪除的客戶
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_customers_contact_person_trgm ON customers USING gin (contact_person gin_trgm_ops) WHERE deleted_at IS NULL;
+        CREATE INDEX IF NOT EXISTS idx_customers_email_trgm ON customers USING gin (email gin_trgm_ops) WHERE deleted_at IS NULL;
+        CREATE INDEX IF NOT EXISTS idx_customers_phone_trgm ON customers USING gin (phone gin_trgm_ops) WHERE deleted_at IS NULL;
+        CREATE INDEX IF NOT EXISTS idx_customers_phone_normalized_trgm ON customers USING gin (phone_normalized gin_trgm_ops) WHERE deleted_at IS NULL;
+    END IF;
+END
+$$;
+
+-- 產品定義：欄位需與 repository.buildProductDefinitionWhere 的 q 條件一致 (search 條件使用 idx_product_definitions_search_trgm)
+-- 列表經常包含已停售的產品，不使用部分索引
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_product_definitions_name_trgm ON product_definitions USING gin (name gin_trgm_ops);
+        CREATE INDEX IF NOT EXISTS idx_product_definitions_description_trgm ON product_definitions USING gin (description gin_trgm_ops);
+        CREATE INDEX IF NOT EXISTS idx_product_definitions_sku_trgm ON product_definitions USING gin (sku gin_trgm_ops);
+    END IF;
+END
+$$;
@@ -0,0 +1,15 @@
+-- db/migrations/000041_public_ids.down.sql
+
+DROP INDEX IF EXISTS idx_product_definitions_public_id;
+DROP INDEX IF EXISTS idx_roles_public_id;
+DROP INDEX IF EXISTS idx_menus_public_id;
+DROP INDEX IF EXISTS idx_customers_public_id;
+DROP INDEX IF EXISTS idx_companies_public_id;
+DROP INDEX IF EXISTS idx_accounts_public_id;
+
+ALTER TABLE product_definitions DROP COLUMN IF EXISTS public_id;
+ALTER TABLE roles DROP COLUMN IF EXISTS public_id;
+ALTER TABLE menus DROP COLUMN IF EXISTS public_id;
+ALTER TABLE customers DROP COLUMN IF EXISTS public_id;
+ALTER TABLE companies DROP COLUMN IF EXISTS public_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS public_id;
@@ -0,0 +1,18 @@
+-- db/migrations/000041_public_ids.up.sql
+
+-- 對外的識別碼：API 回應、匯出與 Webhook 使用 UUID，不透露記錄數量，也不因環境不同而改變；內部的外鍵仍使用整數 ID
+-- gen_random_uuid() 為 PostgreSQL 13 起的內建函式；DEFAULT 為 volatile 函式時既有的每一列都會得到不同的值
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE menus ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE product_definitions ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+-- 路徑參數為 UUID 時以 public_id 查詢 (見各 Repository 的 FindByPublicID)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_public_id ON accounts (public_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_companies_public_id ON companies (public_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_public_id ON customers (public_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_menus_public_id ON menus (public_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_public_id ON roles (public_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_definitions_public_id ON product_definitions (public_id);
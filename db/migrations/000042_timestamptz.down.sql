@@ -0,0 +1,3 @@
+-- db/migrations/000042_timestamptz.down.sql
+
+-- 不還原：轉換前的欄位型別未記錄，而 schema 中的時間欄位本來就應為 TIMESTAMP WITH TIME ZONE
@@ -0,0 +1,22 @@
+-- db/migrations/000042_timestamptz.up.sql
+
+-- 時間欄位一律為 TIMESTAMP WITH TIME ZONE (000001 起的 migration 都以此建立)
+-- 手動建立或以其他工具修改過的資料庫可能有 TIMESTAMP WITHOUT TIME ZONE 的欄位：讀出的時間沒有時區，前端會依部署環境相差 8 小時
+-- 轉換時以執行 migration 的工作階段時區 (TimeZone) 解讀既有的值，需與當初寫入這些值時資料庫的時區相同
+-- 例如資料庫原本的時區為 Asia/Taipei 時，先執行 SET TIME ZONE 'Asia/Taipei' 或以 PGTZ=Asia/Taipei 執行 migrate
+DO $$
+DECLARE
+    col RECORD;
+BEGIN
+    FOR col IN
+        SELECT table_name, column_name
+        FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND data_type = 'timestamp without time zone'
+          AND table_name <> 'schema_migrations'
+    LOOP
+        RAISE NOTICE 'Converting %.% to timestamptz (session time zone %)', col.table_name, col.column_name, current_setting('TimeZone');
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE TIMESTAMP WITH TIME ZONE', col.table_name, col.column_name);
+    END LOOP;
+END
+$$;
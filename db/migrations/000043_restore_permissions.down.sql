@@ -0,0 +1,3 @@
+-- db/migrations/000043_restore_permissions.down.sql
+
+DELETE FROM permissions WHERE name IN ('account:restore', 'company:restore');
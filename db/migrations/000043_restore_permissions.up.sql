@@ -0,0 +1,11 @@
+-- db/migrations/000043_restore_permissions.up.sql
+
+-- 帳戶與公司的還原 (POST /accounts/:id/restore、/companies/:id/restore)，與客戶的 customer:restore 相同只授予 admin
+INSERT INTO permissions (name, description) VALUES ('account:restore', 'Allow restoring deleted accounts') ON CONFLICT (name) DO NOTHING;
+INSERT INTO permissions (name, description) VALUES ('company:restore', 'Allow restoring deleted companies') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name IN ('account:restore', 'company:restore')
+ON CONFLICT (role_id, permission_id) DO NOTHING;
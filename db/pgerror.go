@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgreSQL 錯誤代碼 (SQLSTATE)，見 https://www.postgresql.org/docs/current/errcodes-appendix.html
+// 以錯誤代碼判斷，不比對錯誤訊息 (訊息會隨 PostgreSQL 的語系與驅動而不同)
+const (
+	UniqueViolationCode      = "23505"
+	ForeignKeyViolationCode  = "23503"
+	UndefinedTableCode       = "42P01"
+	SerializationFailureCode = "40001"
+	DeadlockDetectedCode     = "40P01"
+)
+
+// ErrorCode 返回 err 中 PostgreSQL 錯誤的 SQLSTATE 與約束名稱，不是資料庫錯誤時返回空字串
+func ErrorCode(err error) (code, constraint string) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return "", ""
+	}
+	return pgErr.Code, pgErr.ConstraintName
+}
+
+// IsUniqueViolation err 是否為唯一約束衝突 (23505)，是時一併返回約束 (或唯一索引) 名稱
+func IsUniqueViolation(err error) (constraint string, ok bool) {
+	code, constraint := ErrorCode(err)
+	return constraint, code == UniqueViolationCode
+}
+
+// IsForeignKeyViolation err 是否為外鍵約束錯誤 (23503)，例如刪除仍被引用的記錄，是時一併返回約束名稱
+func IsForeignKeyViolation(err error) (constraint string, ok bool) {
+	code, constraint := ErrorCode(err)
+	return constraint, code == ForeignKeyViolationCode
+}
+
+// IsUndefinedTable err 是否為資料表不存在 (42P01)，例如尚未執行遷移
+func IsUndefinedTable(err error) bool {
+	code, _ := ErrorCode(err)
+	return code == UndefinedTableCode
+}
+
+// IsRetryable err 是否為重新執行整個事務可能成功的錯誤：序列化失敗 (40001) 或死結 (40P01)
+// 兩者都是 PostgreSQL 為了解決並發衝突而中止事務，事務已回滾，見 TxManager.WithinTx 的重試
+func IsRetryable(err error) bool {
+	code, _ := ErrorCode(err)
+	return code == SerializationFailureCode || code == DeadlockDetectedCode
+}
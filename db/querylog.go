@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics"
+)
+
+// maxLoggedStatementLength 慢查詢日誌中 SQL 語句的長度上限 (位元組)，避免批次 INSERT 等長查詢讓日誌過大
+const maxLoggedStatementLength = 2048
+
+// queryLog 查詢計時的設定，由 InitQueryLog 設定；未設定時查詢不計時
+type queryLog struct {
+	slowThreshold time.Duration                         // 超過時以 WARN 記錄，0 表示不記錄
+	duration      metrics.Histogram                     // db_query_duration_seconds，標籤為查詢名稱 (見 queryName)
+	logger        func(ctx context.Context) *zap.Logger // 返回請求範圍的 logger (帶有 request_id)
+}
+
+// activeQueryLog 目前的查詢計時設定，連接池在 InitQueryLog 之前打開，因此每次查詢時讀取
+var activeQueryLog atomic.Pointer[queryLog]
+
+// InitQueryLog 開始為每個查詢計時：耗時記錄在 db_query_duration_seconds 直方圖 (依查詢名稱)，超過 slowThreshold 的查詢以 WARN 記錄
+// 日誌只包含查詢名稱、SQL 語句 (參數以 $1、$2 表示)、耗時與資料列數，不包含參數的值；slowThreshold 為 0 時不記錄慢查詢
+// logger 返回 ctx 的請求範圍 logger，讓慢查詢能以 request_id 對應到請求
+func InitQueryLog(reg metrics.Registry, slowThreshold time.Duration, logger func(ctx context.Context) *zap.Logger) {
+	activeQueryLog.Store(&queryLog{
+		slowThreshold: slowThreshold,
+		duration:      reg.Histogram("db_query_duration_seconds", "Database query duration in seconds by query name, including reading the rows.", metrics.DefaultLatencyBuckets, "query"),
+		logger:        logger,
+	})
+}
+
+// observe 記錄一次查詢的耗時，超過門檻時記錄慢查詢
+func (l *queryLog) observe(ctx context.Context, query string, elapsed time.Duration, rows int64) {
+	name := queryName(query)
+	l.duration.Observe(elapsed.Seconds(), name)
+	if l.slowThreshold <= 0 || elapsed < l.slowThreshold {
+		return
+	}
+	if len(query) > maxLoggedStatementLength {
+		query = query[:maxLoggedStatementLength]
+	}
+	l.logger(ctx).Warn("Slow database query",
+		zap.String("query", name),
+		zap.String("statement", query),
+		zap.Duration("duration", elapsed),
+		zap.Int64("rows", rows),
+		zap.Duration("threshold", l.slowThreshold))
+}
+
+// queryTablePattern 查詢的第一個資料表 (FROM、INTO、UPDATE 或 JOIN 之後的名稱)
+var queryTablePattern = regexp.MustCompile(`(?i)\b(?:from|into|update|join)\s+([a-z_][a-z0-9_]*)`)
+
+// queryName 以語句的類型與第一個資料表命名查詢 (例如 "select customers"、"insert role_menus")，作為直方圖的標籤
+// 相同的查詢樣板得到相同的名稱，數量有限 (不會因參數或 IN 列表的長度而增加)
+func queryName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := strings.ToLower(fields[0])
+	if m := queryTablePattern.FindStringSubmatch(query); m != nil {
+		return verb + " " + strings.ToLower(m[1])
+	}
+	return verb
+}
+
+// wrapQueryLog 包裝資料庫驅動的 Connector，InitQueryLog 之後為每個查詢與 Exec 計時
+func wrapQueryLog(connector driver.Connector) driver.Connector {
+	return &timedConnector{connector: connector}
+}
+
+// timedConnector 建立 timedConn 的 Connector
+type timedConnector struct {
+	connector driver.Connector
+}
+
+// Connect 建立連接並包裝為 timedConn
+func (c *timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{Conn: conn}, nil
+}
+
+// Driver 返回原本的驅動
+func (c *timedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// timedConn 包裝資料庫連接為查詢計時，轉發 database/sql 會使用的選用介面 (同 tracing 的 tracedConn)
+type timedConn struct {
+	driver.Conn
+}
+
+// ExecContext 執行不返回資料列的語句，資料列數為受影響的筆數
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	log := activeQueryLog.Load()
+	if log == nil {
+		return execer.ExecContext(ctx, query, args)
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	var affected int64
+	if err == nil {
+		affected, _ = result.RowsAffected()
+	}
+	log.observe(ctx, query, time.Since(start), affected)
+	return result, err
+}
+
+// QueryContext 執行查詢；耗時計算到資料列讀取完畢並關閉為止 (見 timedRows)
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	log := activeQueryLog.Load()
+	if log == nil {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		log.observe(ctx, query, time.Since(start), 0)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, ctx: ctx, query: query, start: start, log: log}, nil
+}
+
+// PrepareContext 準備語句
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx 開始事務
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.Conn.(driver.ConnBeginTx)
+	if !ok {
+		return nil, errors.New("driver does not support BeginTx")
+	}
+	return beginner.BeginTx(ctx, opts)
+}
+
+// Ping 測試連接
+func (c *timedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession 連接回到連接池前重置狀態
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid 連接是否可以繼續使用
+func (c *timedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue 讓驅動自行轉換參數 (pgx 以此支援陣列等型別)，未實現時使用 database/sql 的預設轉換
+func (c *timedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// timedRows 計算讀取的資料列數，關閉時記錄查詢的耗時
+type timedRows struct {
+	driver.Rows
+	ctx   context.Context
+	query string
+	start time.Time
+	log   *queryLog
+	count int64
+	done  bool
+}
+
+// Next 讀取下一列
+func (r *timedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.count++
+	}
+	return err
+}
+
+// Close 關閉資料列並記錄耗時；database/sql 可能重複呼叫 Close，只記錄一次
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.done {
+		r.done = true
+		r.log.observe(r.ctx, r.query, time.Since(r.start), r.count)
+	}
+	return err
+}
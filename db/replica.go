@@ -0,0 +1,18 @@
+package db
+
+import "context"
+
+// primaryContextKey 在 context 中標記查詢必須使用主庫的 key
+type primaryContextKey struct{}
+
+// WithPrimary 返回標記為只使用主庫的 ctx：Repository 以該 ctx 執行的讀取不會送到唯讀副本 (見 UsesPrimary)
+// 用於寫入後在同一請求中讀取剛寫入的資料 (例如建立後重新讀取完整記錄)，避免副本延遲導致讀不到
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// UsesPrimary ctx 是否以 WithPrimary 標記為只使用主庫
+func UsesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// txContextKey 在 context 中保存進行中事務的 key
+type txContextKey struct{}
+
+// TxManager 讓 Service 層把多個 Repository 的寫入包在同一個事務中
+type TxManager interface {
+	// WithinTx 在事務中執行 fn：fn 返回錯誤或 panic 時回滾，否則提交
+	// 事務保存在傳給 fn 的 ctx 中，Repository 以該 ctx 執行的查詢都會使用此事務 (見 TxFromContext)
+	// ctx 已在事務中時直接沿用外層事務，由外層決定提交或回滾 (也由外層重試)
+	// 事務因序列化失敗或死結中止時 (見 IsRetryable) 以新的事務重新執行 fn，fn 因此必須可以重複執行；
+	// fn 有事務以外的副作用 (例如累加到外部的變數、發送通知) 時傳入 NoRetry
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error
+}
+
+// RetryConfig 事務因序列化失敗或死結中止時的重試參數
+type RetryConfig struct {
+	MaxRetries int           // 最多重試次數，0 表示不重試
+	Backoff    time.Duration // 第一次重試前的等待時間，之後每次加倍；實際等待時間為其一半到全部之間的隨機值，避免衝突的事務同時重試
+	MaxBackoff time.Duration // 等待時間的上限
+}
+
+// DefaultRetryConfig 未設定環境變數時使用的重試參數
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries: 3,
+	Backoff:    20 * time.Millisecond,
+	MaxBackoff: 500 * time.Millisecond,
+}
+
+// TxOption WithinTx 的選項
+type TxOption func(*txOptions)
+
+// txOptions WithinTx 套用選項後的設定
+type txOptions struct {
+	noRetry bool
+}
+
+// NoRetry 事務因序列化失敗或死結中止時不重試，直接返回錯誤；用於不能重複執行的 fn
+func NoRetry() TxOption {
+	return func(o *txOptions) {
+		o.noRetry = true
+	}
+}
+
+// txManagerImpl 實現 TxManager 介面
+type txManagerImpl struct {
+	db    *sql.DB
+	retry RetryConfig
+}
+
+// NewTxManager 創建 TxManager 實例，retry 為序列化失敗與死結時的重試參數
+func NewTxManager(db *sql.DB, retry RetryConfig) TxManager {
+	return &txManagerImpl{db: db, retry: retry}
+}
+
+// WithinTx 在事務中執行 fn，可重試的錯誤以新的事務重新執行，每次重試都會記錄
+func (m *txManagerImpl) WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	var o txOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	maxRetries := m.retry.MaxRetries
+	if o.noRetry {
+		maxRetries = 0
+	}
+
+	backoff := m.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := m.runTx(ctx, fn)
+		if err == nil || attempt > maxRetries || !IsRetryable(err) {
+			return err
+		}
+		wait := backoff/2 + rand.N(backoff/2+1)
+		code, _ := ErrorCode(err)
+		zap.L().Warn("Transaction aborted by a concurrent transaction, retrying",
+			zap.String("sqlstate", code), zap.Int("attempt", attempt), zap.Int("max_retries", maxRetries), zap.Duration("backoff", wait), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > m.retry.MaxBackoff {
+			backoff = m.retry.MaxBackoff
+		}
+	}
+}
+
+// runTx 在一個新的事務中執行 fn 一次
+func (m *txManagerImpl) runTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txContextKey{}, tx)); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// TxFromContext 返回 ctx 中由 TxManager.WithinTx 開啟的事務
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sql.Tx)
+	return tx, ok
+}
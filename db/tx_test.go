@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestWithinTx 以 sqlmock 模擬事務：fn 成功時提交，返回錯誤或 panic 時回滾 (panic 繼續向上拋出)
+func TestWithinTx(t *testing.T) {
+	errFn := errors.New("insert role menu failed")
+	tests := []struct {
+		name       string
+		fn         func(ctx context.Context) error
+		wantCommit bool
+		wantErr    error
+		wantPanic  bool
+	}{
+		{name: "commits on success", fn: func(ctx context.Context) error { return nil }, wantCommit: true},
+		{name: "rolls back on error", fn: func(ctx context.Context) error { return errFn }, wantErr: errFn},
+		{name: "rolls back on panic", fn: func(ctx context.Context) error { panic("nil map") }, wantPanic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			mock.ExpectBegin()
+			if tt.wantCommit {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			manager := NewTxManager(database, DefaultRetryConfig)
+			panicked := func() (panicked bool) {
+				defer func() { panicked = recover() != nil }()
+				err = manager.WithinTx(context.Background(), func(ctx context.Context) error {
+					if _, ok := TxFromContext(ctx); !ok {
+						t.Error("fn called without a transaction in ctx")
+					}
+					return tt.fn(ctx)
+				})
+				return false
+			}()
+
+			if panicked != tt.wantPanic {
+				t.Errorf("panicked = %v, want %v", panicked, tt.wantPanic)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WithinTx error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestWithinTxNested 巢狀呼叫沿用 ctx 中的事務 (只開啟一個事務)，內層的錯誤由外層返回時整個事務回滾
+func TestWithinTxNested(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	errInner := errors.New("write audit log failed")
+	manager := NewTxManager(database, DefaultRetryConfig)
+	err = manager.WithinTx(context.Background(), func(ctx context.Context) error {
+		outerTx, _ := TxFromContext(ctx)
+		return manager.WithinTx(ctx, func(ctx context.Context) error {
+			if innerTx, ok := TxFromContext(ctx); !ok || innerTx != outerTx {
+				t.Errorf("inner transaction = %p, want the outer transaction %p", innerTx, outerTx)
+			}
+			return errInner
+		})
+	})
+	if !errors.Is(err, errInner) {
+		t.Errorf("WithinTx error = %v, want %v", err, errInner)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestWithinTxRetry 以 sqlmock 模擬事務，fn 返回死結 (40P01) 或序列化失敗 (40001) 時以新的事務重試，
+// 成功後停止、達到 MaxRetries 後返回最後的錯誤；NoRetry 與其他錯誤不重試，每次重試都記錄警告
+func TestWithinTxRetry(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: DeadlockDetectedCode, Message: "deadlock detected"}
+	serialization := &pgconn.PgError{Code: SerializationFailureCode, Message: "could not serialize access due to concurrent update"}
+	uniqueViolation := &pgconn.PgError{Code: UniqueViolationCode}
+
+	tests := []struct {
+		name         string
+		failures     []error // 前幾次執行 fn 返回的錯誤，之後的執行成功
+		opts         []TxOption
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "succeeds first time", wantAttempts: 1},
+		{name: "deadlock then success", failures: []error{deadlock}, wantAttempts: 2},
+		{name: "wrapped serialization failures then success", failures: []error{fmt.Errorf("update stock: %w", serialization), serialization}, wantAttempts: 3},
+		{name: "gives up after max retries", failures: []error{deadlock, serialization, deadlock, serialization, deadlock}, wantAttempts: 4, wantErr: serialization},
+		{name: "no retry option", failures: []error{deadlock}, opts: []TxOption{NoRetry()}, wantAttempts: 1, wantErr: deadlock},
+		{name: "other errors are not retried", failures: []error{uniqueViolation}, wantAttempts: 1, wantErr: uniqueViolation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			core, logs := observer.New(zap.WarnLevel)
+			defer zap.ReplaceGlobals(zap.New(core))()
+
+			for i := 0; i < tt.wantAttempts; i++ {
+				mock.ExpectBegin()
+				if i < len(tt.failures) {
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectCommit()
+				}
+			}
+
+			attempts := 0
+			manager := NewTxManager(database, RetryConfig{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+			err = manager.WithinTx(context.Background(), func(ctx context.Context) error {
+				if _, ok := TxFromContext(ctx); !ok {
+					t.Error("fn called without a transaction in ctx")
+				}
+				attempts++
+				if attempts <= len(tt.failures) {
+					return tt.failures[attempts-1]
+				}
+				return nil
+			}, tt.opts...)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WithinTx error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("fn ran %d times, want %d", attempts, tt.wantAttempts)
+			}
+			if retries := logs.FilterMessageSnippet("retrying").Len(); retries != tt.wantAttempts-1 {
+				t.Errorf("logged %d retries, want %d", retries, tt.wantAttempts-1)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestWithinTxRetryNested 已在事務中時沿用外層事務，可重試的錯誤交由外層重試
+func TestWithinTxRetryNested(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	manager := NewTxManager(database, RetryConfig{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	inner, outer := 0, 0
+	err = manager.WithinTx(context.Background(), func(ctx context.Context) error {
+		outer++
+		err := manager.WithinTx(ctx, func(ctx context.Context) error {
+			inner++
+			if inner == 1 {
+				return &pgconn.PgError{Code: DeadlockDetectedCode}
+			}
+			return nil
+		})
+		if outer == 1 && !IsRetryable(err) {
+			t.Errorf("inner WithinTx error = %v, want the deadlock", err)
+		}
+		return nil // 外層忽略錯誤時不重試
+	})
+	if err != nil || inner != 1 || outer != 1 {
+		t.Errorf("WithinTx = %v, inner ran %d times, outer ran %d times; want nil, 1, 1", err, inner, outer)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,296 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// StorageScale 價格欄位在資料庫 (NUMERIC(12, 4)) 與 API 中保存的小數位數
+const StorageScale = 4
+
+// RoundingMode 捨入方式
+type RoundingMode string
+
+const (
+	RoundHalfUp   RoundingMode = "half_up"   // 四捨五入 (0.5 遠離零)
+	RoundHalfEven RoundingMode = "half_even" // 銀行家捨入 (0.5 取偶數)
+	RoundDown     RoundingMode = "down"      // 無條件捨去 (朝零)
+	RoundUp       RoundingMode = "up"        // 無條件進位 (遠離零)
+)
+
+// IsValid 檢查捨入方式是否為支援的值
+func (m RoundingMode) IsValid() bool {
+	switch m {
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundUp:
+		return true
+	}
+	return false
+}
+
+// Decimal 十進位定點數，值為 coefficient × 10^-scale
+// 零值代表 0；所有運算都返回新的值，不會修改原本的 Decimal
+type Decimal struct {
+	coefficient *big.Int // nil 表示 0
+	scale       int32    // 小數位數，>= 0
+}
+
+// Zero 值為 0 的 Decimal
+var Zero = Decimal{}
+
+// New 以係數與小數位數建立 Decimal，例如 New(1250, 2) 為 12.50
+func New(coefficient int64, scale int32) Decimal {
+	if scale < 0 {
+		panic("decimal: negative scale")
+	}
+	return Decimal{coefficient: big.NewInt(coefficient), scale: scale}
+}
+
+// NewFromInt 以整數建立 Decimal
+func NewFromInt(n int64) Decimal {
+	return New(n, 0)
+}
+
+// Parse 解析十進位字串 (例如 "12.5"、"-0.0001")，不接受指數表示法
+func Parse(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	intPart, fracPart := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intPart, fracPart = digits[:i], digits[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	for _, part := range []string{intPart, fracPart} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+			}
+		}
+	}
+	coefficient, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if strings.HasPrefix(s, "-") {
+		coefficient.Neg(coefficient)
+	}
+	return Decimal{coefficient: coefficient, scale: int32(len(fracPart))}, nil
+}
+
+// MustParse 同 Parse，解析失敗時 panic，僅用於常數
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewFromFloat 以 float64 的最短十進位表示建立 Decimal (例如 0.1 為 "0.1" 而不是二進位近似值)
+func NewFromFloat(f float64) (Decimal, error) {
+	return Parse(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// value 返回係數 (零值時為 0)
+func (d Decimal) value() *big.Int {
+	if d.coefficient == nil {
+		return new(big.Int)
+	}
+	return d.coefficient
+}
+
+// Scale 返回小數位數
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign 返回 -1、0 或 1
+func (d Decimal) Sign() int {
+	return d.value().Sign()
+}
+
+// IsZero 是否為 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// rescale 將係數放大到指定的小數位數 (scale 必須 >= d.scale)
+func (d Decimal) rescale(scale int32) *big.Int {
+	if scale == d.scale {
+		return d.value()
+	}
+	return new(big.Int).Mul(d.value(), pow10(scale-d.scale))
+}
+
+// Cmp 比較兩個值：d < other 返回 -1，相等返回 0，d > other 返回 1
+func (d Decimal) Cmp(other Decimal) int {
+	scale := maxScale(d, other)
+	return d.rescale(scale).Cmp(other.rescale(scale))
+}
+
+// Equal 數值是否相等 (不考慮小數位數，例如 1.50 等於 1.5)
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// Add 返回 d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	scale := maxScale(d, other)
+	return Decimal{coefficient: new(big.Int).Add(d.rescale(scale), other.rescale(scale)), scale: scale}
+}
+
+// Sub 返回 d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	scale := maxScale(d, other)
+	return Decimal{coefficient: new(big.Int).Sub(d.rescale(scale), other.rescale(scale)), scale: scale}
+}
+
+// Mul 返回 d × other (不捨入，小數位數為兩者相加)
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{coefficient: new(big.Int).Mul(d.value(), other.value()), scale: d.scale + other.scale}
+}
+
+// MulInt 返回 d × n
+func (d Decimal) MulInt(n int64) Decimal {
+	return Decimal{coefficient: new(big.Int).Mul(d.value(), big.NewInt(n)), scale: d.scale}
+}
+
+// Shift 返回 d × 10^places (精確，不捨入)，例如 Shift(-2) 為除以 100
+func (d Decimal) Shift(places int32) Decimal {
+	if places <= d.scale {
+		return Decimal{coefficient: d.value(), scale: d.scale - places}
+	}
+	return Decimal{coefficient: new(big.Int).Mul(d.value(), pow10(places-d.scale)), scale: 0}
+}
+
+// Round 依捨入方式將值調整為指定的小數位數；小數位數不足時補零
+func (d Decimal) Round(scale int32, mode RoundingMode) Decimal {
+	if scale < 0 {
+		panic("decimal: negative scale")
+	}
+	if scale >= d.scale {
+		return Decimal{coefficient: d.rescale(scale), scale: scale}
+	}
+	divisor := pow10(d.scale - scale)
+	quotient, remainder := new(big.Int).QuoRem(d.value(), divisor, new(big.Int)) // 朝零截斷，餘數與被除數同號
+	if remainder.Sign() != 0 {
+		sign := int64(d.Sign())
+		// 比較 |餘數| × 2 與除數，判斷是否超過一半
+		half := new(big.Int).Abs(remainder)
+		half.Lsh(half, 1)
+		roundAway := false
+		switch mode {
+		case RoundDown:
+		case RoundUp:
+			roundAway = true
+		case RoundHalfEven:
+			c := half.Cmp(divisor)
+			roundAway = c > 0 || c == 0 && quotient.Bit(0) == 1
+		default: // RoundHalfUp
+			roundAway = half.Cmp(divisor) >= 0
+		}
+		if roundAway {
+			quotient.Add(quotient, big.NewInt(sign))
+		}
+	}
+	return Decimal{coefficient: quotient, scale: scale}
+}
+
+// Rescale 將值調整為指定的小數位數，會遺失精度時返回錯誤
+func (d Decimal) Rescale(scale int32) (Decimal, error) {
+	rounded := d.Round(scale, RoundDown)
+	if !rounded.Equal(d) {
+		return Decimal{}, fmt.Errorf("decimal %s has more than %d decimal places", d.String(), scale)
+	}
+	return rounded, nil
+}
+
+// Float64 返回最接近的 float64 (可能遺失精度，僅用於驗證與顯示)
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String 以目前的小數位數返回十進位字串，例如 "12.5000"
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.value()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if d.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON 以固定小數位數的字串輸出 (例如 "12.5000")，避免客戶端以浮點數解析造成誤差
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON 接受字串 ("12.5") 或數字 (12.5)，數字不經過 float64 轉換；小數位數統一為 StorageScale
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		*d = Decimal{}
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	if parsed, err = parsed.Rescale(StorageScale); err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan 實現 sql.Scanner 介面 (pgx 以 string 返回 NUMERIC)；價格欄位都是 NOT NULL，NULL 時返回錯誤
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		*d, err = Parse(string(v))
+	case string:
+		*d, err = Parse(v)
+	case int64:
+		*d = NewFromInt(v)
+	case float64:
+		*d, err = NewFromFloat(v)
+	default:
+		return fmt.Errorf("cannot scan %T into decimal", src)
+	}
+	return err
+}
+
+// Value 實現 driver.Valuer 介面，以字串寫入 NUMERIC 欄位
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// maxScale 返回兩者中較大的小數位數
+func maxScale(a, b Decimal) int32 {
+	if a.scale > b.scale {
+		return a.scale
+	}
+	return b.scale
+}
+
+// pow10 返回 10^n
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
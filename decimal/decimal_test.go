@@ -0,0 +1,215 @@
+package decimal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      string // String() 的結果，空字串表示應返回錯誤
+		wantScale int32  // 小數位數
+	}{
+		{in: "12.5", want: "12.5", wantScale: 1},
+		{in: "+1.25", want: "1.25", wantScale: 2},
+		{in: "-0.0001", want: "-0.0001", wantScale: 4},
+		{in: "-0", want: "0"},
+		{in: "1.", want: "1"},
+		{in: ".5", want: "0.5", wantScale: 1},
+		{in: "-.5", want: "-0.5", wantScale: 1},
+		{in: " 3.10 ", want: "3.10", wantScale: 2},
+		{in: "007", want: "7"},
+		{in: ""},
+		{in: " "},
+		{in: "-"},
+		{in: "+"},
+		{in: "."},
+		{in: "-."},
+		{in: "1e3"},
+		{in: "--1"},
+		{in: "+-1"},
+		{in: "1.2.3"},
+		{in: "1,000"},
+		{in: "abc"},
+	}
+	for _, tt := range tests {
+		d, err := Parse(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Parse(%q) = %s, want an error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.want || d.Scale() != tt.wantScale {
+			t.Errorf("Parse(%q) = %s (scale %d), want %s (scale %d)", tt.in, d, d.Scale(), tt.want, tt.wantScale)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int32
+		mode  RoundingMode
+		want  string
+	}{
+		{"1.005", 2, RoundHalfUp, "1.01"},
+		{"1.004", 2, RoundHalfUp, "1.00"},
+		{"-1.005", 2, RoundHalfUp, "-1.01"},
+		{"-0.005", 2, RoundHalfUp, "-0.01"},
+		{"2.5", 0, RoundHalfUp, "3"},
+		{"-2.5", 0, RoundHalfUp, "-3"},
+
+		{"2.5", 0, RoundHalfEven, "2"},
+		{"3.5", 0, RoundHalfEven, "4"},
+		{"-2.5", 0, RoundHalfEven, "-2"},
+		{"-3.5", 0, RoundHalfEven, "-4"},
+		{"0.125", 2, RoundHalfEven, "0.12"},
+		{"0.135", 2, RoundHalfEven, "0.14"},
+		{"1.0051", 2, RoundHalfEven, "1.01"},
+		{"-0.005", 2, RoundHalfEven, "0.00"},
+
+		{"1.999", 2, RoundDown, "1.99"},
+		{"-1.999", 2, RoundDown, "-1.99"},
+		{"-0.005", 2, RoundDown, "0.00"},
+
+		{"1.001", 2, RoundUp, "1.01"},
+		{"-1.001", 2, RoundUp, "-1.01"},
+		{"-0.005", 2, RoundUp, "-0.01"},
+		{"1.000", 2, RoundUp, "1.00"},
+
+		{"1.5", 4, RoundHalfUp, "1.5000"}, // 小數位數不足時補零
+		{"0", 2, RoundUp, "0.00"},
+	}
+	for _, tt := range tests {
+		got := MustParse(tt.in).Round(tt.scale, tt.mode)
+		if got.String() != tt.want || got.Scale() != tt.scale {
+			t.Errorf("Round(%s, %d, %s) = %s (scale %d), want %s", tt.in, tt.scale, tt.mode, got, got.Scale(), tt.want)
+		}
+	}
+}
+
+func TestRescale(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int32
+		want  string // 空字串表示會遺失精度，應返回錯誤
+	}{
+		{in: "1.5", scale: 4, want: "1.5000"},
+		{in: "1.2300", scale: 2, want: "1.23"},
+		{in: "-7", scale: 4, want: "-7.0000"},
+		{in: "1.23456", scale: 4},
+		{in: "-0.00001", scale: 4},
+		{in: "0.01", scale: 0},
+	}
+	for _, tt := range tests {
+		got, err := MustParse(tt.in).Rescale(tt.scale)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Rescale(%s, %d) = %s, want an error", tt.in, tt.scale, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("Rescale(%s, %d) = %s, %v; want %s", tt.in, tt.scale, got, err, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		d    Decimal
+		want string
+	}{
+		{Zero, "0"},
+		{New(0, 2), "0.00"},
+		{New(5, 4), "0.0005"},
+		{New(-5, 4), "-0.0005"},
+		{New(50, 2), "0.50"},
+		{New(1250, 2), "12.50"},
+		{New(123456, 4), "12.3456"},
+		{New(-1, 0), "-1"},
+		{NewFromInt(100), "100"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// TestJSON 輸出固定 StorageScale 位小數的字串；字串與數字輸入都調整為 StorageScale，超過時返回錯誤
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // 重新輸出的 JSON，空字串表示應返回錯誤
+	}{
+		{in: `"12.5"`, want: `"12.5000"`},
+		{in: `12.5`, want: `"12.5000"`},
+		{in: `"-0.0001"`, want: `"-0.0001"`},
+		{in: `0.1`, want: `"0.1000"`},
+		{in: `"12.5000"`, want: `"12.5000"`},
+		{in: `null`, want: `"0"`}, // 重設為零值
+		{in: `"1.23456"`},
+		{in: `"abc"`},
+		{in: `1e3`},
+		{in: `""`},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		err := json.Unmarshal([]byte(tt.in), &d)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want an error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		out, err := json.Marshal(d)
+		if err != nil || string(out) != tt.want {
+			t.Errorf("Marshal(Unmarshal(%s)) = %s, %v; want %s", tt.in, out, err, tt.want)
+			continue
+		}
+		var again Decimal
+		if err := json.Unmarshal(out, &again); err != nil || !again.Equal(d) || again.Scale() != StorageScale {
+			t.Errorf("round trip of %s = %s, %v; want %s with scale %d", out, again, err, d, StorageScale)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want string // 空字串表示應返回錯誤
+	}{
+		{src: []byte("12.3400"), want: "12.3400"},
+		{src: []byte("-0.0005"), want: "-0.0005"},
+		{src: "99.9900", want: "99.9900"},
+		{src: int64(7), want: "7"},
+		{src: float64(0.1), want: "0.1"},
+		{src: nil}, // 價格欄位都是 NOT NULL
+		{src: []byte("NaN")},
+		{src: true},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		err := d.Scan(tt.src)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %s, want an error", tt.src, d)
+			}
+			continue
+		}
+		if err != nil || d.String() != tt.want {
+			t.Errorf("Scan(%#v) = %s, %v; want %s", tt.src, d, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package errorreport
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics"        // 指標註冊介面
+	"github.com/wac0705/fastener-api/middleware/jwt" // AccessClaims
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// 背景回報的工作者設定
+const (
+	reportWorkers     = 2                // 同時送出回報的工作者數量
+	reportQueueSize   = 100              // 等待送出的回報上限，已滿時丟棄新的回報
+	reportSendTimeout = 10 * time.Second // 每筆回報送出的逾時時間
+	maxStackDepth     = 64               // 呼叫堆疊最多保留的層數
+)
+
+// Event 一筆要回報的伺服器錯誤 (5xx) 或 panic
+type Event struct {
+	Err       error
+	Status    int // 返回給用戶端的狀態碼
+	Panic     bool
+	RequestID string
+	Method    string
+	Route     string // 路由樣板，例如 /api/v1/customers/:id
+	Path      string // 實際請求的路徑
+	AccountID *int   // 發出請求的帳戶，未通過 JWT 驗證的請求為 nil
+	Stack     []uintptr
+	Time      time.Time
+}
+
+// Reporter 定義錯誤回報介面，由全局錯誤處理器對所有 5xx 呼叫 (panic 由 Recover 中介軟體以 Panic 包裝後交給錯誤處理器)
+// Report 不可阻塞請求
+type Reporter interface {
+	Report(event Event)
+}
+
+// Sender 將一筆回報送到錯誤追蹤服務 (例如 Sentry)
+type Sender interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// noopReporter 未設定錯誤追蹤服務時使用，不做任何事
+type noopReporter struct{}
+
+// NewNoopReporter 創建不回報任何錯誤的 Reporter 實例
+func NewNoopReporter() Reporter {
+	return noopReporter{}
+}
+
+// Report 忽略回報
+func (noopReporter) Report(Event) {}
+
+// asyncReporter 以固定數量的工作者在背景送出回報
+type asyncReporter struct {
+	sender  Sender
+	queue   chan Event
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// NewReporter 創建以 sender 在背景送出回報的 Reporter 實例，並在 reg 註冊回報結果的指標
+func NewReporter(sender Sender, reg metrics.Registry) Reporter {
+	r := &asyncReporter{sender: sender, queue: make(chan Event, reportQueueSize)}
+	reg.CounterFunc("error_reports_sent_total", "Total error reports sent to the error tracking service.", func() float64 {
+		return float64(r.sent.Load())
+	})
+	reg.CounterFunc("error_reports_dropped_total", "Total error reports dropped because the queue was full.", func() float64 {
+		return float64(r.dropped.Load())
+	})
+	reg.CounterFunc("error_reports_failed_total", "Total error reports that could not be sent.", func() float64 {
+		return float64(r.failed.Load())
+	})
+	for i := 0; i < reportWorkers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+// Report 將回報放入佇列，佇列已滿時丟棄 (例如錯誤追蹤服務無法連線而大量錯誤同時發生)
+func (r *asyncReporter) Report(event Event) {
+	select {
+	case r.queue <- event:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// work 逐筆送出佇列中的回報
+func (r *asyncReporter) work() {
+	for event := range r.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), reportSendTimeout)
+		if err := r.sender.Send(ctx, event); err != nil {
+			zap.L().Warn("Failed to send error report", zap.Error(err), zap.String("request_id", event.RequestID))
+			r.failed.Add(1)
+		} else {
+			r.sent.Add(1)
+		}
+		cancel()
+	}
+}
+
+// panicError Recover 中介軟體捕捉到的 panic，保留 panic 當下的呼叫堆疊
+type panicError struct {
+	err   error
+	stack []uintptr
+}
+
+func (e *panicError) Error() string { return "panic: " + e.err.Error() }
+func (e *panicError) Unwrap() error { return e.err }
+
+// Panic 包裝 panic 的值，回報時使用 panic 發生處的呼叫堆疊；需在 Recover 中介軟體的 LogErrorFunc 中呼叫 (堆疊尚未展開)
+func Panic(err error) error {
+	return &panicError{err: err, stack: callers(3)}
+}
+
+// NewEvent 以請求的資訊建立回報；err 不是以 Panic 包裝時，堆疊為呼叫 NewEvent 之處 (全局錯誤處理器)
+func NewEvent(c echo.Context, err error, status int) Event {
+	event := Event{
+		Err:       err,
+		Status:    status,
+		RequestID: utils.RequestID(c),
+		Method:    c.Request().Method,
+		Route:     c.Path(),
+		Path:      c.Request().URL.Path,
+		Time:      time.Now(),
+	}
+	var pe *panicError
+	if errors.As(err, &pe) {
+		event.Panic = true
+		event.Stack = pe.stack
+	} else {
+		event.Stack = callers(3)
+	}
+	if claims, ok := c.Get("claims").(*jwt.AccessClaims); ok && claims != nil {
+		accountID := claims.AccountID
+		event.AccountID = &accountID
+	}
+	return event
+}
+
+// callers 返回呼叫堆疊的程式計數器，skip 為略過的層數 (同 runtime.Callers)
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	return pcs[:runtime.Callers(skip, pcs)]
+}
@@ -0,0 +1,114 @@
+package errorreport
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// inAppPrefix 本專案的套件路徑，Sentry 以 in_app 區分專案程式碼與相依套件的堆疊
+const inAppPrefix = "github.com/wac0705/fastener-api/"
+
+// SentryConfig Sentry (或相容的服務，例如 GlitchTip) 的連線設定
+type SentryConfig struct {
+	DSN         string // 例如 https://<public_key>@o0.ingest.sentry.io/<project_id>
+	Environment string // 例如 production
+	Release     string // 建置版本
+}
+
+// sentrySender 以 Sentry SDK (sentry-go) 的 Client 送出回報
+// 使用同步的 HTTPSyncTransport：背景佇列與並發數量由 asyncReporter 控制，不再經過 SDK 自己的佇列
+type sentrySender struct {
+	client *sentry.Client
+}
+
+// NewSentrySender 以 cfg 創建送到 Sentry 的 Sender 實例，DSN 格式錯誤時返回錯誤
+// 不使用 sentry.Init 的全局 Hub，測試或同一程序中的其他伺服器不會共用設定
+func NewSentrySender(cfg SentryConfig) (Sender, error) {
+	transport := sentry.NewHTTPSyncTransport()
+	transport.Timeout = reportSendTimeout
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		Transport:   transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	return &sentrySender{client: client}, nil
+}
+
+// Send 將回報轉為 Sentry 事件並同步送出；事件被 SDK 捨棄 (例如取樣或速率限制) 時返回錯誤，計入 error_reports_failed_total
+// 逾時由 transport 的 Timeout 控制 (與 reportSendTimeout 相同)
+func (s *sentrySender) Send(ctx context.Context, event Event) error {
+	if s.client.CaptureEvent(newSentryEvent(event), nil, nil) == nil {
+		return fmt.Errorf("sentry dropped the event for request %s", event.RequestID)
+	}
+	return nil
+}
+
+// newSentryEvent 將回報轉為 Sentry 事件：panic 的等級為 fatal，標籤包含 request_id、路由與狀態碼，user 為發出請求的帳戶
+func newSentryEvent(event Event) *sentry.Event {
+	level, errType := sentry.LevelError, fmt.Sprintf("%T", event.Err)
+	if event.Panic {
+		level, errType = sentry.LevelFatal, "panic"
+	}
+	e := sentry.NewEvent()
+	e.Level = level
+	e.Timestamp = event.Time.UTC()
+	e.Logger = "fastener-api"
+	e.Transaction = event.Method + " " + event.Route
+	e.Tags = map[string]string{
+		"request_id": event.RequestID,
+		"route":      event.Route,
+		"status":     strconv.Itoa(event.Status),
+	}
+	e.Request = &sentry.Request{Method: event.Method, URL: event.Path}
+	e.Exception = []sentry.Exception{{
+		Type:       errType,
+		Value:      event.Err.Error(),
+		Stacktrace: &sentry.Stacktrace{Frames: sentryFrames(event.Stack)},
+	}}
+	if event.AccountID != nil {
+		e.User = sentry.User{ID: strconv.Itoa(*event.AccountID)}
+	}
+	return e
+}
+
+// sentryFrames 將程式計數器轉為 Sentry 的堆疊格式 (最外層的呼叫在前)，略過 Go runtime 的內部函式
+// 只送出檔案路徑的最後兩層，不送出絕對路徑 (建置環境的目錄)，in_app 以本專案的套件路徑判斷
+func sentryFrames(pcs []uintptr) []sentry.Frame {
+	frames := []sentry.Frame{}
+	callersFrames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := callersFrames.Next()
+		if frame.Function != "" && !strings.HasPrefix(frame.Function, "runtime.") {
+			f := sentry.NewFrame(frame)
+			f.AbsPath = ""
+			f.Filename = trimFilename(frame.File)
+			f.InApp = strings.HasPrefix(frame.Function, inAppPrefix)
+			frames = append(frames, f)
+		}
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// trimFilename 只保留檔案路徑的最後兩層 (例如 handler/customer.go)，避免在回報中暴露建置環境的目錄
+func trimFilename(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 {
+		parts = parts[len(parts)-2:]
+	}
+	return strings.Join(parts, "/")
+}
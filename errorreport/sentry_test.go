@@ -0,0 +1,74 @@
+package errorreport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// captureTransport 記錄送出的事件，不連接 Sentry
+type captureTransport struct {
+	events []*sentry.Event
+}
+
+func (t *captureTransport) Flush(time.Duration) bool       { return true }
+func (t *captureTransport) Configure(sentry.ClientOptions) {}
+func (t *captureTransport) SendEvent(event *sentry.Event)  { t.events = append(t.events, event) }
+
+func TestNewSentrySenderRejectsInvalidDSN(t *testing.T) {
+	for _, dsn := range []string{"not a url", "ftp://key@sentry.example.com/1", "https://sentry.example.com/1", "https://key@sentry.example.com/"} {
+		if _, err := NewSentrySender(SentryConfig{DSN: dsn}); err == nil {
+			t.Errorf("NewSentrySender(%q) returned no error", dsn)
+		}
+	}
+	if _, err := NewSentrySender(SentryConfig{DSN: "https://key@o0.ingest.sentry.io/42"}); err != nil {
+		t.Errorf("NewSentrySender(valid DSN) = %v", err)
+	}
+}
+
+// TestSentrySenderSend 回報轉為 Sentry 事件：panic 為 fatal，帶有請求的標籤與帳戶，堆疊不含建置環境的絕對路徑
+func TestSentrySenderSend(t *testing.T) {
+	transport := &captureTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@o0.ingest.sentry.io/42", Release: "1.2.3", Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountID := 7
+	event := Event{
+		Err:       Panic(errors.New("boom")),
+		Status:    500,
+		RequestID: "req-1",
+		Method:    "GET",
+		Route:     "/api/v1/customers/:id",
+		Path:      "/api/v1/customers/3",
+		AccountID: &accountID,
+		Time:      time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+	}
+	event.Panic, event.Stack = true, callers(2) // 從本測試開始的堆疊
+
+	if err := (&sentrySender{client: client}).Send(context.Background(), event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(transport.events) != 1 {
+		t.Fatalf("sent %d events, want 1", len(transport.events))
+	}
+	got := transport.events[0]
+	if got.Level != sentry.LevelFatal || got.Release != "1.2.3" || got.Transaction != "GET /api/v1/customers/:id" {
+		t.Errorf("level %q, release %q, transaction %q", got.Level, got.Release, got.Transaction)
+	}
+	if got.Tags["request_id"] != "req-1" || got.Tags["status"] != "500" || got.User.ID != "7" {
+		t.Errorf("tags %v, user %+v", got.Tags, got.User)
+	}
+	if len(got.Exception) != 1 || got.Exception[0].Type != "panic" || got.Exception[0].Value != "panic: boom" {
+		t.Fatalf("exception = %+v", got.Exception)
+	}
+	frames := got.Exception[0].Stacktrace.Frames
+	last := frames[len(frames)-1] // 最內層的呼叫 (本測試)
+	if !last.InApp || last.AbsPath != "" || last.Filename != "errorreport/sentry_test.go" || !strings.HasSuffix(last.Function, "TestSentrySenderSend") {
+		t.Errorf("innermost frame = %+v", last)
+	}
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics" // 指標註冊介面
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// subscriptionBuffer 每個訂閱尚未送出的事件上限，已滿時中斷該訂閱 (用戶端重新連線後重新載入)
+const subscriptionBuffer = 16
+
+// 事件類型
+const (
+	MenusChanged       = "menus_changed"       // 角色可訪問的選單改變，用戶端應重新載入選單
+	PermissionsChanged = "permissions_changed" // 角色的權限或帳戶的角色改變，用戶端應重新載入權限；各實例的權限緩存收到後失效
+)
+
+// Event 推送給事件串流 (GET /api/v1/events) 訂閱者的事件，只告知哪個角色受影響，不包含變更的內容
+type Event struct {
+	Type      string `json:"type"`
+	RoleID    int    `json:"role_id"`              // 受影響的角色
+	AccountID int    `json:"account_id,omitempty"` // 不為 0 時只有此帳戶受影響 (帳戶的角色改為 RoleID)，用戶端應重新取得 Token
+}
+
+// Publisher 發布事件，Service 在寫入成功後呼叫，不直接依賴 Hub；發布失敗不影響已完成的寫入
+type Publisher interface {
+	Publish(ctx context.Context, event Event)
+}
+
+// Hub 定義程序內的事件中心：管理事件串流的訂閱並將事件廣播給所有訂閱者
+type Hub interface {
+	Publisher
+	Subscribe(accountID int) (*Subscription, error) // 帳戶的訂閱數已達上限時返回 429 (utils.ErrorCodeTooManyStreams)，Hub 已關閉時返回 503
+	Broadcast(event Event)                          // 將事件送給本程序的所有訂閱者與 OnBroadcast 的處理函式 (Publish 未設定跨實例轉送時即為 Broadcast)
+	OnBroadcast(fn func(Event))                     // 登記在每次 Broadcast 時呼叫的程序內處理函式 (例如使權限緩存失效)，fn 不可阻塞
+	Close()                                         // 關閉所有訂閱 (Events 被關閉)，之後的 Subscribe 返回錯誤
+}
+
+// Subscription 一個事件串流的訂閱
+type Subscription struct {
+	Events <-chan Event // Hub 關閉、訂閱者跟不上事件或呼叫 Close 後被關閉
+	hub    *hub
+	id     uint64
+}
+
+// Close 取消訂閱，可重複呼叫
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s.id)
+}
+
+// subscriber 訂閱者的狀態
+type subscriber struct {
+	accountID int
+	events    chan Event
+}
+
+// hub 實現 Hub 介面
+type hub struct {
+	mu            sync.Mutex
+	subscribers   map[uint64]*subscriber
+	perAccount    map[int]int
+	handlers      []func(Event) // OnBroadcast 登記的處理函式
+	nextID        uint64
+	closed        bool
+	maxPerAccount int
+	published     atomic.Uint64
+	disconnected  atomic.Uint64 // 因跟不上事件而被中斷的訂閱數
+}
+
+// NewHub 創建 Hub 實例，maxPerAccount 為每個帳戶同時訂閱的上限，並在 reg 註冊訂閱數與事件數的指標
+func NewHub(maxPerAccount int, reg metrics.Registry) Hub {
+	h := &hub{
+		subscribers:   map[uint64]*subscriber{},
+		perAccount:    map[int]int{},
+		maxPerAccount: maxPerAccount,
+	}
+	reg.GaugeFunc("event_stream_subscribers", "Number of open event stream connections.", func() float64 {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return float64(len(h.subscribers))
+	})
+	reg.CounterFunc("event_stream_events_total", "Total events broadcast to event stream subscribers.", func() float64 {
+		return float64(h.published.Load())
+	})
+	reg.CounterFunc("event_stream_slow_disconnects_total", "Total event stream connections closed because the subscriber fell behind.", func() float64 {
+		return float64(h.disconnected.Load())
+	})
+	return h
+}
+
+// Publish 將事件廣播給本程序的訂閱者
+func (h *hub) Publish(_ context.Context, event Event) {
+	h.Broadcast(event)
+}
+
+// Subscribe 為 accountID 建立訂閱
+func (h *hub) Subscribe(accountID int) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, utils.NewCustomError(http.StatusServiceUnavailable, "Server is shutting down", nil)
+	}
+	if h.perAccount[accountID] >= h.maxPerAccount {
+		return nil, utils.NewTooManyStreamsError(h.maxPerAccount)
+	}
+	h.nextID++
+	sub := &subscriber{accountID: accountID, events: make(chan Event, subscriptionBuffer)}
+	h.subscribers[h.nextID] = sub
+	h.perAccount[accountID]++
+	return &Subscription{Events: sub.events, hub: h, id: h.nextID}, nil
+}
+
+// OnBroadcast 登記處理函式，之後每次 Broadcast 都會以事件呼叫 (在 h.mu 之外呼叫，fn 可以再發布事件)
+func (h *hub) OnBroadcast(fn func(Event)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers = append(h.handlers, fn)
+}
+
+// Broadcast 將事件送給所有訂閱者並呼叫登記的處理函式；訂閱者的緩衝區已滿時中斷該訂閱，不阻塞發布者
+func (h *hub) Broadcast(event Event) {
+	h.mu.Lock()
+	h.published.Add(1)
+	for id, sub := range h.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			zap.L().Warn("Event stream subscriber fell behind, disconnecting", zap.Int("account_id", sub.accountID))
+			h.disconnected.Add(1)
+			h.removeLocked(id)
+		}
+	}
+	handlers := h.handlers
+	h.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(event)
+	}
+}
+
+// Close 關閉所有訂閱
+func (h *hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for id := range h.subscribers {
+		h.removeLocked(id)
+	}
+}
+
+// unsubscribe 移除訂閱 (已移除時不做任何事)
+func (h *hub) unsubscribe(id uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(id)
+}
+
+// removeLocked 移除訂閱並關閉其 Events，呼叫前需持有 h.mu
+func (h *hub) removeLocked(id uint64) {
+	sub, ok := h.subscribers[id]
+	if !ok {
+		return
+	}
+	delete(h.subscribers, id)
+	h.perAccount[sub.accountID]--
+	if h.perAccount[sub.accountID] <= 0 {
+		delete(h.perAccount, sub.accountID)
+	}
+	close(sub.events)
+}
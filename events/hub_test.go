@@ -0,0 +1,37 @@
+package events
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wac0705/fastener-api/metrics"
+)
+
+func TestHubOnBroadcast(t *testing.T) {
+	h := NewHub(1, metrics.NewRegistry())
+	defer h.Close()
+	sub, err := h.Subscribe(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handled []Event
+	h.OnBroadcast(func(event Event) {
+		handled = append(handled, event)
+		if event.Type == PermissionsChanged {
+			h.Broadcast(Event{Type: MenusChanged, RoleID: event.RoleID}) // 處理函式可以再發布事件
+		}
+	})
+	h.Publish(context.Background(), Event{Type: PermissionsChanged, RoleID: 2})
+
+	want := []Event{{Type: PermissionsChanged, RoleID: 2}, {Type: MenusChanged, RoleID: 2}}
+	if !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled = %v, want %v", handled, want)
+	}
+	for _, w := range want {
+		if got := <-sub.Events; got != w {
+			t.Errorf("subscriber received %v, want %v", got, w)
+		}
+	}
+}
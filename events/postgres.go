@@ -0,0 +1,97 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// NotifyChannel PostgreSQL LISTEN/NOTIFY 轉送事件使用的頻道
+const NotifyChannel = "fastener_events"
+
+// 監聽連線中斷後重新連線的等待時間
+const (
+	listenRetryBackoff    = time.Second
+	listenRetryMaxBackoff = 30 * time.Second
+)
+
+// PostgresBridge 以 PostgreSQL LISTEN/NOTIFY 在多個 API 實例之間轉送事件：
+// Publish 以 NOTIFY 送出事件，Run 監聽 NotifyChannel 並將收到的事件 (包含本實例送出的) 交給 Hub 廣播
+type PostgresBridge struct {
+	database    *sql.DB
+	databaseURL string // LISTEN 使用獨立的連線，不佔用連接池
+	hub         Hub
+}
+
+// NewPostgresBridge 創建 PostgresBridge 實例，database 用於 NOTIFY，databaseURL 用於建立 LISTEN 的連線
+func NewPostgresBridge(database *sql.DB, databaseURL string, hub Hub) *PostgresBridge {
+	return &PostgresBridge{database: database, databaseURL: databaseURL, hub: hub}
+}
+
+// Publish 以 NOTIFY 將事件送給所有實例；送出失敗時記錄日誌並只在本實例廣播
+func (b *PostgresBridge) Publish(ctx context.Context, event Event) {
+	payload, err := json.Marshal(event)
+	if err == nil {
+		_, err = b.database.ExecContext(ctx, `SELECT pg_notify($1, $2)`, NotifyChannel, string(payload))
+	}
+	if err != nil {
+		zap.L().Warn("Failed to publish event through PostgreSQL NOTIFY, broadcasting locally only", zap.String("type", event.Type), zap.Int("role_id", event.RoleID), zap.Error(err))
+		b.hub.Broadcast(event)
+	}
+}
+
+// Run 監聽 NotifyChannel 並廣播收到的事件，直到 ctx 取消；連線中斷時以遞增的間隔重新連線
+func (b *PostgresBridge) Run(ctx context.Context) {
+	backoff := listenRetryBackoff
+	for {
+		listening, err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if listening {
+			backoff = listenRetryBackoff // 曾經成功監聽，重新從最短的間隔開始
+		}
+		zap.L().Warn("Event listener disconnected, reconnecting", zap.Error(err), zap.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > listenRetryMaxBackoff {
+			backoff = listenRetryMaxBackoff
+		}
+	}
+}
+
+// listen 建立連線並執行 LISTEN，持續接收通知直到發生錯誤或 ctx 取消；listening 表示 LISTEN 是否曾經成功
+func (b *PostgresBridge) listen(ctx context.Context) (listening bool, err error) {
+	conn, err := pgx.Connect(ctx, b.databaseURL)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{NotifyChannel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("failed to listen on %s: %w", NotifyChannel, err)
+	}
+	zap.L().Info("Listening for events", zap.String("channel", NotifyChannel))
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil || event.Type == "" {
+			zap.L().Warn("Ignoring malformed event notification", zap.String("payload", notification.Payload), zap.Error(err))
+			continue
+		}
+		b.hub.Broadcast(event)
+	}
+}
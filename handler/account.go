@@ -2,7 +2,6 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap" // 使用 zap 進行日誌記錄
@@ -29,7 +28,7 @@ func (h *AccountHandler) CreateAccount(c echo.Context) error {
 
 	// 綁定請求體到結構體
 	if err := c.Bind(account); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 驗證請求數據
@@ -38,30 +37,47 @@ func (h *AccountHandler) CreateAccount(c echo.Context) error {
 		return err // 驗證錯誤會被全局錯誤處理器捕獲和格式化
 	}
 
-	// 調用 Service 層創建帳戶
-	if err := h.accountService.CreateAccount(account); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	// 調用 Service 層創建帳戶 (同時記錄執行建立的帳戶)
+	if err := h.accountService.CreateAccount(c.Request().Context(), account, claims.AccountID); err != nil {
 		// 如果是自定義錯誤，直接返回
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
 		// 其他未知錯誤，記錄並返回內部錯誤
-		zap.L().Error("Failed to create account", zap.Error(err), zap.Any("account", account))
+		utils.Logger(c).Error("Failed to create account", zap.Error(err), zap.String("username", account.Username)) // 不記錄密碼
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
+	// 重新讀取以返回完整的記錄 (含 JOIN 取得的角色名稱)；讀取失敗時仍返回已建立的記錄
+	if createdAccount, err := h.accountService.GetAccountByID(c.Request().Context(), account.ID); err != nil {
+		utils.Logger(c).Warn("Failed to reload created account", zap.Int("account_id", account.ID), zap.Error(err))
+	} else if createdAccount != nil {
+		account = createdAccount
+	}
+
 	// 成功創建後，不返回密碼等敏感信息
 	account.Password = "" // 清除密碼字段
-	return c.JSON(http.StatusCreated, account)
+	return respondCreated(c, "accounts", account.ID, account)
 }
 
-// GetAccounts 獲取所有帳戶
+// GetAccounts 分頁獲取帳戶 (page、page_size、cursor 與 include_total，見 utils.ParsePagination)
 func (h *AccountHandler) GetAccounts(c echo.Context) error {
-	accounts, err := h.accountService.GetAllAccounts()
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	accounts, err := h.accountService.GetAllAccounts(c.Request().Context(), pagination)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get accounts", zap.Error(err))
+		utils.Logger(c).Error("Failed to get accounts", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	return c.JSON(http.StatusOK, accounts)
@@ -69,17 +85,17 @@ func (h *AccountHandler) GetAccounts(c echo.Context) error {
 
 // GetAccountById 根據 ID 獲取帳戶
 func (h *AccountHandler) GetAccountById(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.accountService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
-	account, err := h.accountService.GetAccountByID(id)
+	account, err := h.accountService.GetAccountByID(c.Request().Context(), id)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get account by ID", zap.Int("account_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to get account by ID", zap.Int("account_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	if account == nil { // Service 層返回 nil, nil 表示未找到
@@ -92,14 +108,14 @@ func (h *AccountHandler) GetAccountById(c echo.Context) error {
 
 // UpdateAccount 更新帳戶信息
 func (h *AccountHandler) UpdateAccount(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.accountService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
 	account := new(models.Account)
 	if err := c.Bind(account); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 確保更新的是正確的帳戶 ID
@@ -112,11 +128,11 @@ func (h *AccountHandler) UpdateAccount(c echo.Context) error {
 	}
 
 	// 調用 Service 層更新帳戶
-	if err := h.accountService.UpdateAccount(account); err != nil {
+	if err := h.accountService.UpdateAccount(c.Request().Context(), account); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to update account", zap.Int("account_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to update account", zap.Int("account_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -126,28 +142,49 @@ func (h *AccountHandler) UpdateAccount(c echo.Context) error {
 
 // DeleteAccount 刪除帳戶
 func (h *AccountHandler) DeleteAccount(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.accountService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
 	// 調用 Service 層刪除帳戶
-	if err := h.accountService.DeleteAccount(id); err != nil {
+	if err := h.accountService.DeleteAccount(c.Request().Context(), id); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to delete account", zap.Int("account_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to delete account", zap.Int("account_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
 	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
 }
 
+// RestoreAccount 還原已軟刪除的帳戶
+func (h *AccountHandler) RestoreAccount(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.accountService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	account, err := h.accountService.RestoreAccount(c.Request().Context(), id)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to restore account", zap.Int("account_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	if account == nil {
+		return c.JSON(http.StatusNotFound, utils.ErrNotFound) // 還原後隨即被刪除
+	}
+	return c.JSON(http.StatusOK, account)
+}
+
 // UpdateAccountPassword 更新帳戶密碼
 func (h *AccountHandler) UpdateAccountPassword(c echo.Context) error {
-    id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取目標帳戶 ID
-    if err != nil {
-        return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+    id, idErr := pathID(c, "id", h.accountService.ResolvePublicID) // 從 URL 參數獲取目標帳戶 ID
+    if idErr != nil {
+    	return c.JSON(idErr.Code, idErr)
     }
 
     // 獲取當前請求用戶的 claims，用於檢查是否是自己修改密碼或有權限的管理員修改
@@ -158,7 +195,7 @@ func (h *AccountHandler) UpdateAccountPassword(c echo.Context) error {
 
     req := new(models.UpdatePasswordRequest)
     if err := c.Bind(req); err != nil {
-        return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+        return c.JSON(http.StatusBadRequest, utils.BindError(err))
     }
 
     if err := c.Validate(req); err != nil {
@@ -166,11 +203,11 @@ func (h *AccountHandler) UpdateAccountPassword(c echo.Context) error {
     }
 
     // 調用 Service 層更新密碼
-    if err := h.accountService.UpdatePassword(id, req.OldPassword, req.NewPassword, claims.AccountID, claims.RoleID); err != nil {
+    if err := h.accountService.UpdatePassword(c.Request().Context(), id, req.OldPassword, req.NewPassword, claims.AccountID, claims.RoleID); err != nil {
         if customErr, ok := err.(*utils.CustomError); ok {
             return c.JSON(customErr.Code, customErr)
         }
-        zap.L().Error("Failed to update account password", zap.Int("account_id", id), zap.Error(err))
+        utils.Logger(c).Error("Failed to update account password", zap.Int("account_id", id), zap.Error(err))
         return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
     }
 
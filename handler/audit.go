@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/service"
+	"github.com/wac0705/fastener-api/utils"
+	"github.com/wac0705/fastener-api/utils/query"
+)
+
+// AuditHandler 定義稽核記錄處理器結構，包含 AuditService 的依賴
+type AuditHandler struct {
+	auditService service.AuditService
+}
+
+// NewAuditHandler 創建 AuditHandler 實例
+func NewAuditHandler(s service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: s}
+}
+
+// auditLogQuerySpec 稽核記錄列表允許的篩選欄位 (例如 actor_id=3、status_gte=400、created_at_gte=2024-01-01)
+// path_prefix 由 GetAuditLogs 自行解析
+var auditLogQuerySpec = query.Spec{
+	Fields: map[string]query.Field{
+		"actor_id":   {Type: query.TypeInt, Operators: query.Equality},
+		"method":     {Type: query.TypeString, Operators: query.Equality},
+		"status":     {Type: query.TypeInt, Operators: append(append([]query.Operator{}, query.Range...), query.Ne, query.In)},
+		"created_at": {Type: query.TypeDate, Operators: query.Range},
+	},
+	Params: []string{"path_prefix"},
+}
+
+// GetAuditLogs 分頁獲取寫入操作的稽核記錄，依時間由新到舊
+func (h *AuditHandler) GetAuditLogs(c echo.Context) error {
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+	criteria, parseErr := query.Parse(c.QueryParams(), auditLogQuerySpec)
+	if parseErr != nil {
+		return c.JSON(parseErr.Code, parseErr)
+	}
+	filter := models.AuditLogFilter{
+		Criteria:   criteria,
+		PathPrefix: strings.TrimSpace(c.QueryParam("path_prefix")),
+	}
+
+	result, err := h.auditService.GetAuditLogs(c.Request().Context(), filter, pagination)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get audit logs", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, result)
+}
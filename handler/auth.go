@@ -28,7 +28,7 @@ func (h *AuthHandler) Login(c echo.Context) error {
 
 	// 綁定請求體
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 驗證請求數據
@@ -37,12 +37,12 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	}
 
 	// 調用 Service 層進行登入
-	accessToken, refreshToken, account, err := h.authService.Login(req.Username, req.Password)
+	accessToken, refreshToken, account, err := h.authService.Login(c.Request().Context(), req.Username, req.Password)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Login failed due to internal error", zap.String("username", req.Username), zap.Error(err))
+		utils.Logger(c).Error("Login failed due to internal error", zap.String("username", req.Username), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -66,7 +66,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 
 	// 綁定請求體
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 驗證請求數據
@@ -75,17 +75,17 @@ func (h *AuthHandler) Register(c echo.Context) error {
 	}
 
 	// 調用 Service 層進行註冊
-	account, err := h.authService.Register(req.Username, req.Password, req.RoleID)
+	account, err := h.authService.Register(c.Request().Context(), req.Username, req.Password, req.RoleID)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Registration failed due to internal error", zap.String("username", req.Username), zap.Error(err))
+		utils.Logger(c).Error("Registration failed due to internal error", zap.String("username", req.Username), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
 	account.Password = "" // 清除密碼敏感信息
-	return c.JSON(http.StatusCreated, account)
+	return respondCreated(c, "accounts", account.ID, account)
 }
 
 // RefreshToken 處理 Token 刷新請求
@@ -94,16 +94,16 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 
 	// 綁定請求體 (只需 Refresh Token)
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 調用 Service 層刷新 Token
-	newAccessToken, err := h.authService.RefreshToken(req.RefreshToken)
+	newAccessToken, err := h.authService.RefreshToken(c.Request().Context(), req.RefreshToken)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to refresh token", zap.Error(err))
+		utils.Logger(c).Error("Failed to refresh token", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -118,7 +118,7 @@ func (h *AuthHandler) GetMyProfile(c echo.Context) error {
     claims, ok := c.Get("claims").(*jwt.AccessClaims)
     if !ok || claims == nil {
         // 這條路徑通常不會被觸發，因為有 JWT 中介軟體保護
-        zap.L().Warn("Claims not found in context for GetMyProfile")
+        utils.Logger(c).Warn("Claims not found in context for GetMyProfile")
         return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
     }
 
@@ -127,12 +127,12 @@ func (h *AuthHandler) GetMyProfile(c echo.Context) error {
     // 為了簡化，直接返回 claims 中的部分資訊
     
     // 從資料庫獲取完整帳戶信息，包括角色名
-    account, err := h.authService.GetAccountByID(claims.AccountID)
+    account, err := h.authService.GetAccountByID(c.Request().Context(), claims.AccountID)
     if err != nil {
         if customErr, ok := err.(*utils.CustomError); ok {
             return c.JSON(customErr.Code, customErr)
         }
-        zap.L().Error("Failed to get account profile", zap.Int("account_id", claims.AccountID), zap.Error(err))
+        utils.Logger(c).Error("Failed to get account profile", zap.Int("account_id", claims.AccountID), zap.Error(err))
         return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
     }
     if account == nil {
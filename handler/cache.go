@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// CacheHandler 定義讀取緩存處理器結構，包含 cache.Cache 的依賴
+type CacheHandler struct {
+	readCache cache.Cache
+}
+
+// NewCacheHandler 創建 CacheHandler 實例
+func NewCacheHandler(c cache.Cache) *CacheHandler {
+	return &CacheHandler{readCache: c}
+}
+
+// FlushCache 清除所有讀取緩存 (例如直接修改資料庫之後)，之後的讀取重新查詢資料庫
+func (h *CacheHandler) FlushCache(c echo.Context) error {
+	if err := h.readCache.Flush(c.Request().Context()); err != nil {
+		utils.Logger(c).Error("Failed to flush read cache", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.NoContent(http.StatusNoContent) // 成功清除，返回 204 No Content
+}
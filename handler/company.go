@@ -3,11 +3,12 @@ package handler
 import (
 	"database/sql" // 導入 sql 包，用於檢查 ErrNoRows
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/middleware/jwt"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/service"
 	"github.com/wac0705/fastener-api/utils"
@@ -28,50 +29,87 @@ func (h *CompanyHandler) CreateCompany(c echo.Context) error {
 	company := new(models.Company)
 
 	if err := c.Bind(company); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
+	company.RecordActors = models.RecordActors{} // 唯讀欄位，忽略請求中的值
 
 	if err := c.Validate(company); err != nil {
 		return err // 驗證錯誤會被全局錯誤處理器捕獲
 	}
 
-	if err := h.companyService.CreateCompany(company); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.companyService.CreateCompany(c.Request().Context(), company, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to create company", zap.Error(err), zap.String("company_name", company.Name))
+		utils.Logger(c).Error("Failed to create company", zap.Error(err), zap.String("company_name", company.Name))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
-	return c.JSON(http.StatusCreated, company)
+	return respondCreated(c, "companies", company.ID, company)
 }
 
 // GetCompanies 獲取所有公司
 func (h *CompanyHandler) GetCompanies(c echo.Context) error {
-	companies, err := h.companyService.GetAllCompanies()
+	companies, err := h.companyService.GetAllCompanies(c.Request().Context())
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get companies", zap.Error(err))
+		utils.Logger(c).Error("Failed to get companies", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	return c.JSON(http.StatusOK, companies)
 }
 
+// GetCompanyTree 獲取公司集團樹狀結構
+func (h *CompanyHandler) GetCompanyTree(c echo.Context) error {
+	tree, err := h.companyService.GetCompanyTree(c.Request().Context())
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get company tree", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, tree)
+}
+
+// GetCompanyStats 獲取每間公司的客戶統計 (分頁，可依 sort 排序，前綴 "-" 為降序)
+func (h *CompanyHandler) GetCompanyStats(c echo.Context) error {
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	stats, err := h.companyService.GetCompanyStats(c.Request().Context(), pagination, c.QueryParam("sort"))
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get company stats", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
 // GetCompanyById 根據 ID 獲取公司
 func (h *CompanyHandler) GetCompanyById(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.companyService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
-	company, err := h.companyService.GetCompanyByID(id)
+	company, err := h.companyService.GetCompanyByID(c.Request().Context(), id)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get company by ID", zap.Int("company_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to get company by ID", zap.Int("company_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	if company == nil { // Service 層返回 nil, nil 表示未找到
@@ -83,28 +121,34 @@ func (h *CompanyHandler) GetCompanyById(c echo.Context) error {
 
 // UpdateCompany 更新公司信息
 func (h *CompanyHandler) UpdateCompany(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.companyService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
 	company := new(models.Company)
 	if err := c.Bind(company); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 確保更新的是正確的公司 ID
 	company.ID = id
+	company.RecordActors = models.RecordActors{} // 唯讀欄位，忽略請求中的值
 
 	if err := c.Validate(company); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.companyService.UpdateCompany(company); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.companyService.UpdateCompany(c.Request().Context(), company, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to update company", zap.Int("company_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to update company", zap.Int("company_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -113,18 +157,135 @@ func (h *CompanyHandler) UpdateCompany(c echo.Context) error {
 
 // DeleteCompany 刪除公司
 func (h *CompanyHandler) DeleteCompany(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.companyService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	// children 參數決定子公司的處理方式：block (預設)、cascade、detach
+	mode := models.ChildrenDeleteMode(c.QueryParam("children"))
+	if mode == "" {
+		mode = models.ChildrenDeleteBlock
+	}
+	if !mode.IsValid() {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid children mode; expected block, cascade or detach"))
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
 	}
 
-	if err := h.companyService.DeleteCompany(id); err != nil {
+	if err := h.companyService.DeleteCompany(c.Request().Context(), id, mode, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to delete company", zap.Int("company_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to delete company", zap.Int("company_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
 	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
 }
+
+// RestoreCompany 還原已軟刪除的公司
+func (h *CompanyHandler) RestoreCompany(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.companyService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
+	}
+
+	company, err := h.companyService.RestoreCompany(c.Request().Context(), id, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to restore company", zap.Int("company_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	if company == nil {
+		return c.JSON(http.StatusNotFound, utils.ErrNotFound) // 還原後隨即被刪除
+	}
+	return c.JSON(http.StatusOK, company)
+}
+
+// MergeCompanies 將請求中的來源公司合併到 URL 中的目標公司
+func (h *CompanyHandler) MergeCompanies(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.companyService.ResolvePublicID) // 從 URL 參數獲取目標公司 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
+	}
+
+	req := new(models.CompanyMergeRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err // 驗證錯誤
+	}
+
+	result, err := h.companyService.MergeCompanies(c.Request().Context(), id, req.SourceCompanyID, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to merge companies", zap.Int("target_id", id), zap.Int("source_id", req.SourceCompanyID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// ImportCompanies 從 CSV 檔案批次匯入公司 (multipart 欄位 "file")
+// CSV 標題需包含 name，可選 tax_id、country、currency；dry_run=true 時只驗證並回報，不寫入
+func (h *CompanyHandler) ImportCompanies(c echo.Context) error {
+	dryRun := c.QueryParam("dry_run") == "true" || c.FormValue("dry_run") == "true"
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
+	}
+
+	records, err := readCSVUpload(c, "file", "name")
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok { // 檔案超過大小上限 (413)
+			return c.JSON(customErr.Code, customErr)
+		}
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails(err.Error()))
+	}
+
+	invalid := []models.ImportRowResult{}
+	rows := []models.CompanyImportRow{}
+	for _, record := range records {
+		company := models.Company{
+			Name:     record.Fields["name"],
+			TaxID:    record.Fields["tax_id"],
+			Country:  strings.ToUpper(record.Fields["country"]),
+			Currency: strings.ToUpper(record.Fields["currency"]),
+		}
+		if err := c.Validate(&company); err != nil {
+			invalid = append(invalid, models.ImportRowResult{Line: record.Line, Action: models.ImportActionInvalid, Details: validationDetails(c, err)})
+			continue
+		}
+		rows = append(rows, models.CompanyImportRow{Line: record.Line, Company: company})
+	}
+
+	written, err := h.companyService.ImportCompanies(c.Request().Context(), rows, claims.AccountID, dryRun)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to import companies", zap.Error(err), zap.Int("rows", len(rows)))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+
+	return c.JSON(http.StatusOK, newImportResult(dryRun, invalid, written))
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/service"
+)
+
+// createdAt 假 Service 寫入的建立時間 (模擬資料庫產生的欄位)
+var createdAt = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// 以下假 Service 嵌入介面，只實作建立端點呼叫的方法；呼叫其他方法會 panic
+type (
+	fakeAccountService struct {
+		service.AccountService
+		reloadErr error
+	}
+	fakeAuthService    struct{ service.AuthService }
+	fakeCompanyService struct {
+		service.CompanyService
+	}
+	fakeCustomerService struct {
+		service.CustomerService
+		actorID int
+	}
+	fakeMenuService              struct{ service.MenuService }
+	fakeRoleService              struct{ service.RoleService }
+	fakeProductDefinitionService struct {
+		service.ProductDefinitionService
+	}
+)
+
+func (f *fakeAccountService) CreateAccount(ctx context.Context, account *models.Account, actorID int) error {
+	account.ID, account.PublicID, account.CreatedAt, account.UpdatedAt = 11, "acc-public-id", createdAt, createdAt
+	account.Password = "$2a$10$hashed" // Service 以雜湊取代密碼
+	return nil
+}
+
+func (f *fakeAccountService) GetAccountByID(ctx context.Context, id int) (*models.Account, error) {
+	if f.reloadErr != nil {
+		return nil, f.reloadErr
+	}
+	return &models.Account{ID: id, PublicID: "acc-public-id", Username: "new.user", Password: "$2a$10$hashed", RoleID: 2, RoleName: "finance", IsActive: true, CreatedAt: createdAt, UpdatedAt: createdAt}, nil
+}
+
+func (f *fakeAuthService) Register(ctx context.Context, username, password string, roleID int) (*models.Account, error) {
+	return &models.Account{ID: 12, PublicID: "reg-public-id", Username: username, Password: "$2a$10$hashed", RoleID: roleID, IsActive: true, CreatedAt: createdAt, UpdatedAt: createdAt}, nil
+}
+
+func (f *fakeCompanyService) CreateCompany(ctx context.Context, company *models.Company, actorID int) error {
+	company.ID, company.PublicID, company.CreatedAt, company.UpdatedAt = 21, "company-public-id", createdAt, createdAt
+	company.CreatedBy, company.UpdatedBy = &actorID, &actorID
+	return nil
+}
+
+func (f *fakeCustomerService) CreateCustomer(ctx context.Context, customer *models.Customer, actorID int) error {
+	f.actorID = actorID
+	customer.ID, customer.PublicID, customer.Code, customer.CreatedAt, customer.UpdatedAt = 31, "customer-public-id", "C-000031", createdAt, createdAt
+	return nil
+}
+
+func (f *fakeCustomerService) GetCustomerByID(ctx context.Context, id int) (*models.Customer, error) {
+	companyName, actor := "Acme", "tester"
+	companyID := 21
+	return &models.Customer{ID: id, PublicID: "customer-public-id", Code: "C-000031", Name: "Bolt Buyer", CompanyID: &companyID, CompanyName: &companyName,
+		Status: "prospect", CreatedAt: createdAt, UpdatedAt: createdAt, RecordActors: models.RecordActors{CreatedByUsername: &actor}}, nil
+}
+
+func (f *fakeMenuService) CreateMenu(ctx context.Context, menu *models.Menu, actorID int) error {
+	menu.ID, menu.PublicID, menu.CreatedAt, menu.UpdatedAt = 41, "menu-public-id", createdAt, createdAt
+	return nil
+}
+
+func (f *fakeRoleService) CloneRole(ctx context.Context, sourceID int, name string, actorID int) (*models.Role, error) {
+	return &models.Role{ID: 51, PublicID: "role-public-id", Name: name, CreatedAt: createdAt, UpdatedAt: createdAt}, nil
+}
+
+func (f *fakeProductDefinitionService) CreateProductCategory(ctx context.Context, category *models.ProductCategory) error {
+	category.ID, category.CreatedAt, category.UpdatedAt = 61, createdAt, createdAt
+	return nil
+}
+
+func (f *fakeProductDefinitionService) CreateProductDefinition(ctx context.Context, definition *models.ProductDefinition, actorID int) error {
+	definition.ID, definition.PublicID, definition.CreatedAt, definition.UpdatedAt = 71, "product-public-id", createdAt, createdAt
+	return nil
+}
+
+func (f *fakeProductDefinitionService) GetProductDefinitionByID(ctx context.Context, id int, currency string) (*models.ProductDefinition, error) {
+	return &models.ProductDefinition{ID: id, PublicID: "product-public-id", SKU: "HB-M8-30", Name: "Hex bolt M8x30", CategoryID: 61, CategoryName: "Bolts",
+		Price: decimal.MustParse("1.2500"), CreatedAt: createdAt, UpdatedAt: createdAt}, nil
+}
+
+// TestCreateEndpoints 所有建立端點返回 201、指向新資源的 Location 與完整的記錄 (含 ID、時間戳記、產生的代碼與 JOIN 取得的欄位)，且不返回密碼
+func TestCreateEndpoints(t *testing.T) {
+	products := NewProductDefinitionHandler(&fakeProductDefinitionService{}, nil)
+	tests := []struct {
+		name      string
+		handler   echo.HandlerFunc
+		path      string
+		params    map[string]string
+		body      string
+		location  string
+		want      map[string]interface{} // 回應中必須有的欄位與值
+		forbidden []string               // 回應中不可出現的欄位
+	}{
+		{
+			name:      "account",
+			handler:   NewAccountHandler(&fakeAccountService{}).CreateAccount,
+			path:      "/api/v1/accounts",
+			body:      `{"username": "new.user", "password": "secret123", "role_id": 2}`,
+			location:  "/api/v1/accounts/11",
+			want:      map[string]interface{}{"id": 11.0, "public_id": "acc-public-id", "role_at_read": "finance", "created_at": "2024-03-10T12:00:00Z"},
+			forbidden: []string{"password"},
+		},
+		{
+			name:      "register",
+			handler:   NewAuthHandler(&fakeAuthService{}).Register,
+			path:      "/api/v1/register",
+			body:      `{"username": "self.user", "password": "secret123", "role_id": 3}`,
+			location:  "/api/v1/accounts/12",
+			want:      map[string]interface{}{"id": 12.0, "username": "self.user", "created_at": "2024-03-10T12:00:00Z"},
+			forbidden: []string{"password"},
+		},
+		{
+			name:     "company",
+			handler:  NewCompanyHandler(&fakeCompanyService{}).CreateCompany,
+			path:     "/api/v1/companies",
+			body:     `{"name": "Acme", "country": "TW"}`,
+			location: "/api/v1/companies/21",
+			want:     map[string]interface{}{"id": 21.0, "public_id": "company-public-id", "name": "Acme", "created_by": float64(testAccountID), "updated_at": "2024-03-10T12:00:00Z"},
+		},
+		{
+			name:     "customer",
+			handler:  NewCustomerHandler(&fakeCustomerService{}, nil).CreateCustomer,
+			path:     "/api/v1/customers",
+			body:     `{"name": "Bolt Buyer", "company_id": 21, "code": "C-999999"}`,
+			location: "/api/v1/customers/31",
+			want:     map[string]interface{}{"id": 31.0, "code": "C-000031", "company_name": "Acme", "status": "prospect", "created_by_username": "tester"},
+		},
+		{
+			name:     "menu",
+			handler:  NewMenuHandler(&fakeMenuService{}, nil).CreateMenu,
+			path:     "/api/v1/menus",
+			body:     `{"name": "Reports", "path": "/reports"}`,
+			location: "/api/v1/menus/41",
+			want:     map[string]interface{}{"id": 41.0, "public_id": "menu-public-id", "path": "/reports", "created_at": "2024-03-10T12:00:00Z"},
+		},
+		{
+			name:     "role clone",
+			handler:  NewRoleHandler(&fakeRoleService{}, nil).CloneRole,
+			path:     "/api/v1/roles/5/clone",
+			params:   map[string]string{"roleID": "5"},
+			body:     `{"name": "auditor"}`,
+			location: "/api/v1/roles/51",
+			want:     map[string]interface{}{"id": 51.0, "name": "auditor", "created_at": "2024-03-10T12:00:00Z"},
+		},
+		{
+			name:     "product category",
+			handler:  products.CreateProductCategory,
+			path:     "/api/v1/product_categories",
+			body:     `{"name": "Bolts"}`,
+			location: "/api/v1/product_categories/61",
+			want:     map[string]interface{}{"id": 61.0, "name": "Bolts", "created_at": "2024-03-10T12:00:00Z"},
+		},
+		{
+			name:     "product definition",
+			handler:  products.CreateProductDefinition,
+			path:     "/api/v1/product_definitions",
+			body:     `{"name": "Hex bolt M8x30", "category_id": 61, "price": "1.25"}`,
+			location: "/api/v1/product_definitions/71",
+			want:     map[string]interface{}{"id": 71.0, "sku": "HB-M8-30", "category_name": "Bolts", "price": "1.2500", "created_at": "2024-03-10T12:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newTestContext(t, http.MethodPost, tt.path, tt.body)
+			for name, value := range tt.params {
+				c.SetParamNames(name)
+				c.SetParamValues(value)
+			}
+			if err := tt.handler(c); err != nil {
+				t.Fatalf("handler returned %v", err)
+			}
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want 201 (body %s)", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get(echo.HeaderLocation); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			body := decodeBody(t, rec)
+			for key, value := range tt.want {
+				if body[key] != value {
+					t.Errorf("%s = %#v, want %#v", key, body[key], value)
+				}
+			}
+			for _, key := range tt.forbidden {
+				if _, ok := body[key]; ok {
+					t.Errorf("response contains %s", key)
+				}
+			}
+			if strings.Contains(rec.Body.String(), "$2a$") {
+				t.Errorf("response contains a password hash: %s", rec.Body)
+			}
+		})
+	}
+}
+
+// TestCreateAccountReloadFailure 重新讀取失敗時仍返回已建立的記錄與 Location，且不含密碼
+func TestCreateAccountReloadFailure(t *testing.T) {
+	h := NewAccountHandler(&fakeAccountService{reloadErr: errors.New("connection reset")})
+	c, rec := newTestContext(t, http.MethodPost, "/api/v1/accounts", `{"username": "new.user", "password": "secret123", "role_id": 2}`)
+	if err := h.CreateAccount(c); err != nil {
+		t.Fatal(err)
+	}
+	body := decodeBody(t, rec)
+	if rec.Code != http.StatusCreated || rec.Header().Get(echo.HeaderLocation) != "/api/v1/accounts/11" || body["id"] != 11.0 || body["username"] != "new.user" {
+		t.Errorf("status %d, Location %q, body %v", rec.Code, rec.Header().Get(echo.HeaderLocation), body)
+	}
+	if _, ok := body["password"]; ok {
+		t.Error("response contains password")
+	}
+}
+
+// TestCreateCustomerIgnoresReadOnlyFields 請求中的唯讀欄位 (代碼、公司名稱) 不會傳給 Service
+func TestCreateCustomerIgnoresReadOnlyFields(t *testing.T) {
+	svc := &fakeCustomerService{}
+	var received *models.Customer
+	h := NewCustomerHandler(&recordingCustomerService{fakeCustomerService: svc, received: &received}, nil)
+	c, _ := newTestContext(t, http.MethodPost, "/api/v1/customers", `{"name": "Bolt Buyer", "code": "C-999999", "company_name": "Spoofed"}`)
+	if err := h.CreateCustomer(c); err != nil {
+		t.Fatal(err)
+	}
+	if received == nil || received.CompanyName != nil || svc.actorID != testAccountID {
+		t.Fatalf("received = %+v, actor %d", received, svc.actorID)
+	}
+}
+
+// recordingCustomerService 記錄傳給 CreateCustomer 的客戶 (呼叫前的副本)
+type recordingCustomerService struct {
+	*fakeCustomerService
+	received **models.Customer
+}
+
+func (r *recordingCustomerService) CreateCustomer(ctx context.Context, customer *models.Customer, actorID int) error {
+	copied := *customer
+	*r.received = &copied
+	if copied.Code != "" {
+		return errors.New("client-supplied code reached the service")
+	}
+	return r.fakeCustomerService.CreateCustomer(ctx, customer, actorID)
+}
@@ -2,25 +2,34 @@ package handler
 
 import (
 	"database/sql" // 導入 sql 包，用於檢查 ErrNoRows
+	"encoding/csv"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/config"
+	"github.com/wac0705/fastener-api/middleware/authz"
+	"github.com/wac0705/fastener-api/middleware/jwt"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/service"
 	"github.com/wac0705/fastener-api/utils"
+	"github.com/wac0705/fastener-api/utils/query"
 )
 
 // CustomerHandler 定義客戶處理器結構，包含 CustomerService 的依賴
 type CustomerHandler struct {
-	customerService service.CustomerService
+	customerService   service.CustomerService
+	permissionService service.PermissionService // 用於檢查 include_deleted 等額外權限
 }
 
 // NewCustomerHandler 創建 CustomerHandler 實例
-func NewCustomerHandler(s service.CustomerService) *CustomerHandler {
-	return &CustomerHandler{customerService: s}
+func NewCustomerHandler(s service.CustomerService, permissionService service.PermissionService) *CustomerHandler {
+	return &CustomerHandler{customerService: s, permissionService: permissionService}
 }
 
 // CreateCustomer 創建新客戶
@@ -28,70 +37,411 @@ func (h *CustomerHandler) CreateCustomer(c echo.Context) error {
 	customer := new(models.Customer)
 
 	if err := c.Bind(customer); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
+	customer.CompanyName = nil // 唯讀欄位，忽略請求中的值
+	customer.DeletedAt = nil
+	customer.Addresses = nil
+	customer.LastNoteAt = nil
+	customer.SalesRepUsername = nil
+	customer.RecordActors = models.RecordActors{}
+	customer.PhoneNormalized = ""
+	customer.StatusReason = "" // 新增時不記錄狀態歷史
+	customer.Code = ""         // 由系統產生
 
 	if err := c.Validate(customer); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.customerService.CreateCustomer(customer); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.customerService.CreateCustomer(c.Request().Context(), customer, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to create customer", zap.Error(err), zap.String("customer_name", customer.Name))
+		utils.Logger(c).Error("Failed to create customer", zap.Error(err), zap.String("customer_name", customer.Name))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
-	return c.JSON(http.StatusCreated, customer)
+	// 重新讀取以返回完整的記錄 (公司名稱、業務代表名稱等 JOIN 取得的唯讀欄位)；讀取失敗時仍返回已建立的記錄
+	if createdCustomer, err := h.customerService.GetCustomerByID(c.Request().Context(), customer.ID); err != nil {
+		utils.Logger(c).Warn("Failed to reload created customer", zap.Int("customer_id", customer.ID), zap.Error(err))
+	} else if createdCustomer != nil {
+		customer = createdCustomer
+	}
+
+	return respondCreated(c, "customers", customer.ID, customer)
+}
+
+// defaultCustomerDuplicateThreshold 未設定 CUSTOMER_DUPLICATE_THRESHOLD 時的名稱相似度門檻
+const defaultCustomerDuplicateThreshold = 0.3
+
+// customerDuplicateThreshold 返回重複檢查的名稱相似度門檻，由 CUSTOMER_DUPLICATE_THRESHOLD 設定
+func customerDuplicateThreshold() float64 {
+	if config.Cfg != nil && config.Cfg.CustomerDuplicateThreshold > 0 {
+		return config.Cfg.CustomerDuplicateThreshold
+	}
+	return defaultCustomerDuplicateThreshold
+}
+
+// CheckDuplicateCustomers 建立客戶前檢查是否已有相似記錄
+// 請求 body 可包含 name、email、phone (至少一個)，返回最多 10 筆依相似度排序的候選客戶
+func (h *CustomerHandler) CheckDuplicateCustomers(c echo.Context) error {
+	req := new(models.CustomerDuplicateCheckRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err // 驗證錯誤
+	}
+
+	candidates, err := h.customerService.CheckDuplicateCustomers(c.Request().Context(), *req, customerDuplicateThreshold())
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to check duplicate customers", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, candidates)
 }
 
-// GetCustomers 獲取所有客戶
+// GetCustomers 獲取客戶列表
+// 支援查詢參數 page、page_size、q (模糊搜尋名稱/聯絡人/Email) 與 sort (前綴 "-" 為降序)；
+// company_id=<id> 只返回該公司的客戶 (include_descendants=true 包含子孫公司)，company_id=null 返回未關聯公司的客戶；
+// include_deleted=true 包含已軟刪除的客戶，需要 customer:read_deleted 權限；
+// sales_rep=me 或 sales_rep=<account_id> 只返回指派給該業務代表的客戶，只有 customer:read_own 權限時固定為自己；
+// status=prospect|active|inactive 只返回該狀態的客戶
 func (h *CustomerHandler) GetCustomers(c echo.Context) error {
-	customers, err := h.customerService.GetAllCustomers()
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	filter, filterErr := h.parseCustomerFilter(c)
+	if filterErr != nil {
+		return c.JSON(filterErr.Code, filterErr)
+	}
+
+	// 不存在的公司 ID 只會得到空列表，不視為錯誤
+	result, err := h.customerService.GetAllCustomers(c.Request().Context(), filter, pagination)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get customers", zap.Error(err))
+		utils.Logger(c).Error("Failed to get customers", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
-	return c.JSON(http.StatusOK, customers)
+	return c.JSON(http.StatusOK, result)
+}
+
+// customerQuerySpec 客戶列表與匯出允許的通用篩選與排序欄位 (例如 created_at_gte=2024-01-01、sort=-name)
+// Params 中的參數由 parseCustomerFilter 自行解析，其他未宣告的參數返回 400
+var customerQuerySpec = query.Spec{
+	Fields: map[string]query.Field{
+		"currency":      {Type: query.TypeString, Operators: query.Equality},
+		"payment_terms": {Type: query.TypeString, Operators: query.Equality},
+		"created_at":    {Type: query.TypeDate, Operators: query.Range},
+		"updated_at":    {Type: query.TypeDate, Operators: query.Range},
+	},
+	Sorts:  []string{"id", "code", "name", "contact_person", "email", "company_name", "sales_rep", "status", "last_note_at", "created_at", "updated_at"},
+	Params: []string{"q", "company_id", "include_descendants", "include_deleted", "status", "sales_rep", "format"},
+}
+
+// parseCustomerFilter 解析客戶列表與匯出共用的篩選與排序參數
+// include_deleted=true 需要 customer:read_deleted 權限；sales_rep=me 由 JWT claims 解析為當前帳戶
+func (h *CustomerHandler) parseCustomerFilter(c echo.Context) (models.CustomerFilter, *utils.CustomError) {
+	filter := models.CustomerFilter{
+		Query: strings.TrimSpace(c.QueryParam("q")),
+	}
+	criteria, parseErr := query.Parse(c.QueryParams(), customerQuerySpec)
+	if parseErr != nil {
+		return filter, parseErr
+	}
+	filter.Criteria = criteria
+	if companyIDStr := c.QueryParam("company_id"); companyIDStr == "null" {
+		filter.NoCompany = true
+	} else if companyIDStr != "" {
+		companyID, convErr := strconv.Atoi(companyIDStr)
+		if convErr != nil || companyID <= 0 {
+			return filter, utils.ErrBadRequest.SetDetails("Invalid company_id")
+		}
+		filter.CompanyID = &companyID
+		filter.IncludeDescendants = c.QueryParam("include_descendants") == "true"
+	}
+	if c.QueryParam("include_deleted") == "true" {
+		allowed, permErr := authz.HasPermission(c, "customer:read_deleted", h.permissionService)
+		if permErr != nil {
+			utils.Logger(c).Error("Failed to check permission for deleted customers", zap.Error(permErr))
+			return filter, utils.ErrInternalServer
+		}
+		if !allowed {
+			return filter, utils.ErrForbidden.SetDetails("Insufficient permissions to list deleted customers")
+		}
+		filter.IncludeDeleted = true
+	}
+	switch status := c.QueryParam("status"); status {
+	case "":
+	case models.CustomerStatusProspect, models.CustomerStatusActive, models.CustomerStatusInactive:
+		filter.Status = status
+	default:
+		return filter, utils.ErrBadRequest.SetDetails("Invalid status")
+	}
+	if salesRep := c.QueryParam("sales_rep"); salesRep == "me" {
+		claims, ok := c.Get("claims").(*jwt.AccessClaims)
+		if !ok || claims == nil {
+			return filter, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials")
+		}
+		filter.SalesRepAccountID = &claims.AccountID
+	} else if salesRep != "" {
+		accountID, convErr := strconv.Atoi(salesRep)
+		if convErr != nil || accountID <= 0 {
+			return filter, utils.ErrBadRequest.SetDetails("Invalid sales_rep")
+		}
+		filter.SalesRepAccountID = &accountID
+	}
+
+	ownAccountID, scopeErr := h.ownCustomersScope(c)
+	if scopeErr != nil {
+		return filter, scopeErr
+	}
+	if ownAccountID != nil {
+		filter.SalesRepAccountID = ownAccountID // 只有 customer:read_own 時忽略 sales_rep，固定為自己
+	}
+	return filter, nil
+}
+
+// ownCustomersScope 判斷當前用戶是否只能讀取指派給自己的客戶
+// 具備 customer:read 時返回 nil (不限制)，否則返回當前帳戶 ID
+func (h *CustomerHandler) ownCustomersScope(c echo.Context) (*int, *utils.CustomError) {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return nil, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials")
+	}
+	canReadAll, err := authz.HasPermission(c, "customer:read", h.permissionService)
+	if err != nil {
+		utils.Logger(c).Error("Failed to check permission for reading customers", zap.Error(err), zap.Int("account_id", claims.AccountID))
+		return nil, utils.ErrInternalServer
+	}
+	if canReadAll {
+		return nil, nil
+	}
+	return &claims.AccountID, nil
+}
+
+// ImportCustomers 從 CSV 檔案批次匯入客戶 (multipart 欄位 "file")
+// CSV 標題需包含 name，可選 code、contact_person (或 contact)、email、phone、company_name (或 company)；
+// on_conflict 決定 Email 重複時的處理方式 (reject 預設、skip、update)，create_companies=true 時自動建立不存在的公司，
+// dry_run=true 時只驗證並回報，不寫入
+func (h *CustomerHandler) ImportCustomers(c echo.Context) error {
+	formOrQuery := func(name string) string {
+		if v := c.QueryParam(name); v != "" {
+			return v
+		}
+		return c.FormValue(name)
+	}
+	dryRun := formOrQuery("dry_run") == "true"
+	createCompanies := formOrQuery("create_companies") == "true"
+	onConflict := models.ImportConflictMode(formOrQuery("on_conflict"))
+	if onConflict == "" {
+		onConflict = models.ImportConflictReject
+	}
+	if !onConflict.IsValid() {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid on_conflict; expected reject, skip or update"))
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	records, err := readCSVUpload(c, "file", "name")
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok { // 檔案超過大小上限 (413)
+			return c.JSON(customErr.Code, customErr)
+		}
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails(err.Error()))
+	}
+
+	invalid := []models.ImportRowResult{}
+	rows := []models.CustomerImportRow{}
+	for _, record := range records {
+		customer := models.Customer{
+			Code:          record.Fields["code"],
+			Name:          record.Fields["name"],
+			ContactPerson: record.field("contact_person", "contact"),
+			Email:         record.Fields["email"],
+			Phone:         record.Fields["phone"],
+		}
+		if err := c.Validate(&customer); err != nil {
+			invalid = append(invalid, models.ImportRowResult{Line: record.Line, Action: models.ImportActionInvalid, Details: validationDetails(c, err)})
+			continue
+		}
+		rows = append(rows, models.CustomerImportRow{Line: record.Line, Customer: customer, CompanyName: record.field("company_name", "company")})
+	}
+
+	written, err := h.customerService.ImportCustomers(c.Request().Context(), rows, onConflict, createCompanies, claims.AccountID, dryRun)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to import customers", zap.Error(err), zap.Int("rows", len(rows)))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+
+	return c.JSON(http.StatusOK, newImportResult(dryRun, invalid, written))
+}
+
+// ExportCustomers 以 CSV 匯出符合篩選條件的客戶 (參數與列表相同，忽略分頁)
+func (h *CustomerHandler) ExportCustomers(c echo.Context) error {
+	if format := c.QueryParam("format"); format != "" && format != "csv" {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Unsupported export format; only csv is available"))
+	}
+	filter, filterErr := h.parseCustomerFilter(c)
+	if filterErr != nil {
+		return c.JSON(filterErr.Code, filterErr)
+	}
+
+	filename := fmt.Sprintf("customers-%s.csv", time.Now().Format("20060102-150405"))
+	var writer *csv.Writer
+	started := false
+	err := h.customerService.ExportCustomers(c.Request().Context(), filter, maxExportRows(), func(batch []models.Customer) error {
+		if !started {
+			// 第一批資料到達時才送出標頭，之前的錯誤仍可以返回一般的 JSON 錯誤
+			started = true
+			c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+			c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+			c.Response().WriteHeader(http.StatusOK)
+			writer = csv.NewWriter(c.Response())
+			writer.Write(customerExportHeader)
+		}
+		for _, customer := range batch {
+			writer.Write(customerExportRecord(customer))
+		}
+		writer.Flush()
+		c.Response().Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		if started {
+			// 已開始輸出，無法再變更狀態碼，只能記錄錯誤並中斷
+			utils.Logger(c).Error("Customer export interrupted", zap.Error(err))
+			return nil
+		}
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to export customers", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	if !started {
+		// 沒有符合條件的客戶時仍輸出只有標題列的檔案
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", []byte(strings.Join(customerExportHeader, ",")+"\n"))
+	}
+	return nil
+}
+
+// customerExportHeader 匯出 CSV 的標題列
+var customerExportHeader = []string{"public_id", "code", "name", "contact_person", "email", "phone", "company_id", "company_name", "created_at"}
+
+// customerExportRecord 將客戶轉為匯出 CSV 的一列，順序需與 customerExportHeader 一致
+// 以對外的 UUID 識別碼 (public_id) 識別客戶，不輸出內部的整數 ID
+func customerExportRecord(customer models.Customer) []string {
+	companyID, companyName := "", ""
+	if customer.CompanyID != nil {
+		companyID = strconv.Itoa(*customer.CompanyID)
+	}
+	if customer.CompanyName != nil {
+		companyName = *customer.CompanyName
+	}
+	return []string{
+		customer.PublicID,
+		customer.Code,
+		customer.Name,
+		customer.ContactPerson,
+		customer.Email,
+		customer.Phone,
+		companyID,
+		companyName,
+		customer.CreatedAt.Format(time.RFC3339),
+	}
 }
 
 // GetCustomerById 根據 ID 獲取客戶
 func (h *CustomerHandler) GetCustomerById(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
+	id, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	customer, err := h.customerService.GetCustomerByID(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get customer by ID", zap.Int("customer_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
+	return h.respondWithReadableCustomer(c, customer)
+}
 
-	customer, err := h.customerService.GetCustomerByID(id)
+// GetCustomerByCode 根據客戶代碼 (例如 "C-000123") 獲取客戶，供 ERP 整合使用
+func (h *CustomerHandler) GetCustomerByCode(c echo.Context) error {
+	code := strings.TrimSpace(c.Param("code"))
+	if code == "" {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Customer code is required"))
+	}
+
+	customer, err := h.customerService.GetCustomerByCode(c.Request().Context(), code)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get customer by ID", zap.Int("customer_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to get customer by code", zap.String("code", code), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
+	return h.respondWithReadableCustomer(c, customer)
+}
+
+// respondWithReadableCustomer 返回單一客戶，只有 customer:read_own 權限時未指派給自己的客戶視為不存在
+func (h *CustomerHandler) respondWithReadableCustomer(c echo.Context, customer *models.Customer) error {
 	if customer == nil { // Service 層返回 nil, nil 表示未找到
 		return c.JSON(http.StatusNotFound, utils.ErrNotFound)
 	}
 
+	ownAccountID, scopeErr := h.ownCustomersScope(c)
+	if scopeErr != nil {
+		return c.JSON(scopeErr.Code, scopeErr)
+	}
+	if ownAccountID != nil && (customer.SalesRepAccountID == nil || *customer.SalesRepAccountID != *ownAccountID) {
+		return c.JSON(http.StatusNotFound, utils.ErrNotFound) // 不透露未指派給自己的客戶是否存在
+	}
+
 	return c.JSON(http.StatusOK, customer)
 }
 
 // UpdateCustomer 更新客戶信息
 func (h *CustomerHandler) UpdateCustomer(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
 	customer := new(models.Customer)
 	if err := c.Bind(customer); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
+	customer.CompanyName = nil // 唯讀欄位，忽略請求中的值
+	customer.DeletedAt = nil
+	customer.Addresses = nil
+	customer.LastNoteAt = nil
+	customer.SalesRepUsername = nil
+	customer.RecordActors = models.RecordActors{}
+	customer.PhoneNormalized = ""
 
 	// 確保更新的是正確的客戶 ID
 	customer.ID = id
@@ -100,31 +450,268 @@ func (h *CustomerHandler) UpdateCustomer(c echo.Context) error {
 		return err // 驗證錯誤
 	}
 
-	if err := h.customerService.UpdateCustomer(customer); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.customerService.UpdateCustomer(c.Request().Context(), customer, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to update customer", zap.Int("customer_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to update customer", zap.Int("customer_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
+	customer.StatusReason = "" // 原因只記錄在歷史中
 
 	return c.JSON(http.StatusOK, customer)
 }
 
-// DeleteCustomer 刪除客戶
+// DeleteCustomer 刪除客戶 (軟刪除，可透過 RestoreCustomer 還原)
 func (h *CustomerHandler) DeleteCustomer(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
+	id, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.customerService.DeleteCustomer(c.Request().Context(), id, claims.AccountID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to delete customer", zap.Int("customer_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+
+	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
+}
+
+// RestoreCustomer 還原已軟刪除的客戶
+func (h *CustomerHandler) RestoreCustomer(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	customer, err := h.customerService.RestoreCustomer(c.Request().Context(), id, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to restore customer", zap.Int("customer_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, customer)
+}
+
+// GetCustomerHistory 分頁獲取客戶的變更歷史 (由新到舊)，field=<欄位> 只返回該欄位的變更
+func (h *CustomerHandler) GetCustomerHistory(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	history, err := h.customerService.GetCustomerHistory(c.Request().Context(), customerID, c.QueryParam("field"), pagination)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get customer history", zap.Int("customer_id", customerID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, history)
+}
+
+// GetCustomerAddresses 獲取客戶的所有地址
+func (h *CustomerHandler) GetCustomerAddresses(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	addresses, err := h.customerService.GetCustomerAddresses(c.Request().Context(), customerID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get customer addresses", zap.Int("customer_id", customerID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, addresses)
+}
+
+// CreateCustomerAddress 為客戶新增地址
+func (h *CustomerHandler) CreateCustomerAddress(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	address := new(models.CustomerAddress)
+	if err := c.Bind(address); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	address.CustomerID = customerID
+
+	if err := c.Validate(address); err != nil {
+		return err // 驗證錯誤
+	}
+
+	if err := h.customerService.CreateCustomerAddress(c.Request().Context(), address); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to create customer address", zap.Int("customer_id", customerID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusCreated, address)
+}
+
+// UpdateCustomerAddress 更新客戶地址
+func (h *CustomerHandler) UpdateCustomerAddress(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	addressID, err := strconv.Atoi(c.Param("address_id"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
 	}
 
-	if err := h.customerService.DeleteCustomer(id); err != nil {
+	address := new(models.CustomerAddress)
+	if err := c.Bind(address); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	address.ID = addressID
+	address.CustomerID = customerID
+
+	if err := c.Validate(address); err != nil {
+		return err // 驗證錯誤
+	}
+
+	if err := h.customerService.UpdateCustomerAddress(c.Request().Context(), address); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to update customer address", zap.Int("customer_id", customerID), zap.Int("address_id", addressID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, address)
+}
+
+// DeleteCustomerAddress 刪除客戶地址
+func (h *CustomerHandler) DeleteCustomerAddress(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	addressID, err := strconv.Atoi(c.Param("address_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	}
+
+	if err := h.customerService.DeleteCustomerAddress(c.Request().Context(), customerID, addressID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to delete customer", zap.Int("customer_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to delete customer address", zap.Int("customer_id", customerID), zap.Int("address_id", addressID), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
+	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
+}
+
+// GetCustomerNotes 分頁獲取客戶備註 (由新到舊)，pinned_first=true 時置頂備註排在最前
+func (h *CustomerHandler) GetCustomerNotes(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+	pinnedFirst := c.QueryParam("pinned_first") == "true"
 
+	notes, err := h.customerService.GetCustomerNotes(c.Request().Context(), customerID, pinnedFirst, pagination)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get customer notes", zap.Int("customer_id", customerID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, notes)
+}
+
+// CreateCustomerNote 為客戶新增備註，作者為當前登入的帳戶
+func (h *CustomerHandler) CreateCustomerNote(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
+	}
+
+	note := new(models.CustomerNote)
+	if err := c.Bind(note); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	note.CustomerID = customerID
+	note.AuthorID = &claims.AccountID
+	note.AuthorUsername = &claims.Username
+
+	if err := c.Validate(note); err != nil {
+		return err // 驗證錯誤
+	}
+
+	if err := h.customerService.CreateCustomerNote(c.Request().Context(), note); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to create customer note", zap.Int("customer_id", customerID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusCreated, note)
+}
+
+// DeleteCustomerNote 刪除客戶備註，只有作者本人或管理員可以刪除
+func (h *CustomerHandler) DeleteCustomerNote(c echo.Context) error {
+	customerID, idErr := pathID(c, "id", h.customerService.ResolvePublicID) // 從 URL 參數獲取客戶 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	noteID, err := strconv.Atoi(c.Param("note_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
+	}
+
+	if err := h.customerService.DeleteCustomerNote(c.Request().Context(), customerID, noteID, claims.AccountID, claims.RoleID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to delete customer note", zap.Int("customer_id", customerID), zap.Int("note_id", noteID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
 	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
 }
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/openapi"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// DocsHandler 定義 API 文件處理器結構，以已註冊的路由與登記的文件產生 OpenAPI 文件
+type DocsHandler struct {
+	echo    *echo.Echo
+	info    openapi.Info
+	docs    map[string]openapi.Operation
+	specURL string
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// NewDocsHandler 創建 DocsHandler 實例；specURL 為 Swagger UI 載入文件的路徑
+// 文件在第一次請求時才產生，因此所有路由都需在伺服器啟動前註冊
+func NewDocsHandler(e *echo.Echo, info openapi.Info, docs map[string]openapi.Operation, specURL string) *DocsHandler {
+	return &DocsHandler{echo: e, info: info, docs: docs, specURL: specURL}
+}
+
+// OpenAPISpec 返回 OpenAPI 3 文件 (JSON)
+func (h *DocsHandler) OpenAPISpec(c echo.Context) error {
+	h.once.Do(func() {
+		h.spec, h.err = json.MarshalIndent(openapi.Build(h.info, h.echo.Routes(), h.docs), "", "  ")
+	})
+	if h.err != nil {
+		utils.Logger(c).Error("Failed to build OpenAPI spec", zap.Error(h.err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, h.spec)
+}
+
+// swaggerUIPage Swagger UI 頁面，靜態資源由 CDN 載入
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "{{spec_url}}", dom_id: "#swagger-ui", persistAuthorization: true });
+  </script>
+</body>
+</html>
+`
+
+// SwaggerUI 返回瀏覽 OpenAPI 文件的 Swagger UI 頁面 (以 Authorize 按鈕填入 Bearer Token 後可直接呼叫 API)
+func (h *DocsHandler) SwaggerUI(c echo.Context) error {
+	page := strings.NewReplacer("{{title}}", html.EscapeString(h.info.Title), "{{spec_url}}", h.specURL).Replace(swaggerUIPage)
+	return c.HTML(http.StatusOK, page)
+}
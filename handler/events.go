@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/events"
+	"github.com/wac0705/fastener-api/middleware/jwt"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// EventsHandler 定義事件串流處理器結構，包含 events.Hub 的依賴
+type EventsHandler struct {
+	hub       events.Hub
+	heartbeat time.Duration // 沒有事件時送出註解行的間隔，避免代理伺服器因閒置而中斷連線
+}
+
+// NewEventsHandler 創建 EventsHandler 實例
+func NewEventsHandler(hub events.Hub, heartbeat time.Duration) *EventsHandler {
+	return &EventsHandler{hub: hub, heartbeat: heartbeat}
+}
+
+// StreamEvents 以 Server-Sent Events 推送事件 (例如 menus_changed)，直到用戶端中斷連線或伺服器關閉
+// 每個事件為 "event: <type>" 與 JSON 的 "data:"；伺服器關閉或連線跟不上事件時結束串流，用戶端 (EventSource) 會自動重新連線
+func (h *EventsHandler) StreamEvents(c echo.Context) error {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized)
+	}
+
+	sub, err := h.hub.Subscribe(claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to subscribe to events", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no") // 讓 nginx 不緩衝串流
+	res.WriteHeader(http.StatusOK)
+	fmt.Fprint(res, ": connected\n\n")
+	res.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil // 用戶端中斷連線
+		case event, ok := <-sub.Events:
+			if !ok {
+				return nil // 伺服器關閉或連線跟不上事件
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				utils.Logger(c).Error("Failed to encode event", zap.String("type", event.Type), zap.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
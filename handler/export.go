@@ -0,0 +1,13 @@
+package handler
+
+import "github.com/wac0705/fastener-api/config"
+
+const defaultMaxExportRows = 10000 // 未設定 MAX_EXPORT_ROWS 時的匯出筆數上限
+
+// maxExportRows 返回單次匯出的筆數上限，由 MAX_EXPORT_ROWS 設定
+func maxExportRows() int {
+	if config.Cfg != nil && config.Cfg.MaxExportRows > 0 {
+		return config.Cfg.MaxExportRows
+	}
+	return defaultMaxExportRows
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// routeMethods 判斷 405 時檢查的 HTTP 方法，順序與 Echo Router 產生的 Allow 標頭相同 (OPTIONS 一律放在最前面)
+var routeMethods = []string{
+	http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead,
+	http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace,
+}
+
+// RouteNotFound 沒有對應路由時的處理器，返回 utils.CustomError 格式的 404 (error_code 為 ROUTE_NOT_FOUND)
+// 以 e.RouteNotFound 與 Group.RouteNotFound 註冊 (見 routes.RegisterAPIRoutes)
+// 分組的 404 路由 ("/*") 會讓 Router 不再判斷 405，因此路徑存在於其他方法時改為返回 405 (見 MethodNotAllowed)
+func RouteNotFound(c echo.Context) error {
+	if allow := routeAllowedMethods(c); len(allow) > 0 {
+		c.Set(echo.ContextKeyHeaderAllow, strings.Join(allow, ", "))
+		return MethodNotAllowed(c)
+	}
+	customErr := utils.NewRouteNotFoundError(c.Request().Method, c.Request().URL.Path)
+	return c.JSON(customErr.Code, customErr)
+}
+
+// MethodNotAllowed 路徑存在但不支援請求方法時的處理器，由 RouteNotFound 在路徑存在於其他方法時呼叫
+// 返回 utils.CustomError 格式的 405 (error_code 為 METHOD_NOT_ALLOWED)，並以 Allow 標頭列出支援的方法
+func MethodNotAllowed(c echo.Context) error {
+	allow := AllowedMethods(c)
+	if len(allow) > 0 {
+		c.Response().Header().Set(echo.HeaderAllow, strings.Join(allow, ", "))
+	}
+	customErr := utils.NewMethodNotAllowedError(c.Request().Method, c.Request().URL.Path, allow)
+	return c.JSON(customErr.Code, customErr)
+}
+
+// AllowedMethods 返回 Router 為 405 記錄的此路徑支援的方法 (echo.ContextKeyHeaderAllow)，沒有時返回 nil
+func AllowedMethods(c echo.Context) []string {
+	header, _ := c.Get(echo.ContextKeyHeaderAllow).(string)
+	if header == "" {
+		return nil
+	}
+	return strings.Split(header, ", ")
+}
+
+// routeAllowedMethods 以其他 HTTP 方法在 Router 中查詢請求路徑，返回有對應路由的方法 (含 OPTIONS)，都沒有時返回 nil
+func routeAllowedMethods(c echo.Context) []string {
+	e := c.Echo()
+	notFoundPaths := map[string]bool{}
+	for _, route := range e.Routes() {
+		if route.Method == echo.RouteNotFound {
+			notFoundPaths[route.Path] = true
+		}
+	}
+
+	allow := []string{}
+	for _, method := range routeMethods {
+		if method == c.Request().Method {
+			continue
+		}
+		probe := e.NewContext(nil, nil)
+		e.Router().Find(method, echo.GetPath(c.Request()), probe)
+		if probe.Path() != "" && !notFoundPaths[probe.Path()] && probe.Get(echo.ContextKeyHeaderAllow) == nil {
+			allow = append(allow, method)
+		}
+	}
+	if len(allow) == 0 {
+		return nil
+	}
+	return append([]string{http.MethodOptions}, allow...)
+}
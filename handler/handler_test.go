@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/middleware/jwt"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// testAccountID 測試請求中已通過驗證的帳戶 ID
+const testAccountID = 7
+
+// newTestContext 以與 server.New 相同的驗證器與嚴格 JSON 綁定建立請求的 Echo Context，並帶有已驗證帳戶的 claims
+func newTestContext(t *testing.T, method, path, body string) (echo.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	e := echo.New()
+	validator := utils.NewCustomValidator()
+	if err := validator.RegisterCustomValidations([]string{"net30"}); err != nil {
+		t.Fatal(err)
+	}
+	e.Validator = validator
+	e.Binder = utils.NewJSONBinder(true)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("claims", &jwt.AccessClaims{AccountID: testAccountID, Username: "tester", RoleID: 1})
+	return c, rec
+}
+
+// decodeBody 將 JSON 回應解碼為 map，方便檢查欄位是否存在
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not a JSON object: %v (body %s)", err, rec.Body)
+	}
+	return body
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/service"
+)
+
+// HealthHandler 定義健康檢查處理器結構，包含 HealthService 的依賴
+type HealthHandler struct {
+	healthService    service.HealthService
+	livenessTimeout  time.Duration // /livez 的逾時時間
+	readinessTimeout time.Duration // /readyz 所有檢查合計的逾時時間
+}
+
+// NewHealthHandler 創建 HealthHandler 實例
+func NewHealthHandler(s service.HealthService, livenessTimeout, readinessTimeout time.Duration) *HealthHandler {
+	return &HealthHandler{healthService: s, livenessTimeout: livenessTimeout, readinessTimeout: readinessTimeout}
+}
+
+// Healthz 健康檢查 (不需驗證)，返回建置版本、運行時間與資料庫狀態
+// 正常時返回 200；資料庫無法連線時返回 503，回應格式相同 (見 models.HealthCheckResponse)
+func (h *HealthHandler) Healthz(c echo.Context) error {
+	return healthResponse(c, h.healthService.Check(c.Request().Context()))
+}
+
+// Livez 存活探針，不檢查資料庫等相依元件，避免相依元件短暫異常時程序被重啟
+func (h *HealthHandler) Livez(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), h.livenessTimeout)
+	defer cancel()
+	return healthResponse(c, h.healthService.Live(ctx))
+}
+
+// Readyz 就緒探針，檢查啟動程序、資料庫、權限緩存與遷移版本；任一項失敗時返回 503，流量會暫時不導入此實例
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), h.readinessTimeout)
+	defer cancel()
+	return healthResponse(c, h.healthService.Ready(ctx))
+}
+
+// healthResponse 依檢查結果返回 200 或 503
+func healthResponse(c echo.Context, result *models.HealthCheckResponse) error {
+	if result.Status != models.HealthStatusOK {
+		return c.JSON(http.StatusServiceUnavailable, result)
+	}
+	return c.JSON(http.StatusOK, result)
+}
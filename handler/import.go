@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/config"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// csvRecord CSV (或 XLSX) 檔案中的一列資料，以標題名稱對應欄位值
+type csvRecord struct {
+	Line   int               // 原始檔案中的行號 (標題列為第 1 行)
+	Fields map[string]string // 標題 (小寫、去除空白) => 欄位值
+}
+
+// sheetRow 解析器返回的一列原始資料
+type sheetRow struct {
+	Line   int      // 原始檔案中的行號 (XLSX 為工作表的列號)
+	Values []string // 依欄位順序的儲存格值
+}
+
+// importSheetParser 將上傳的檔案解析為列資料 (包含標題列)，CSV 與 XLSX 各有一個實作
+type importSheetParser interface {
+	Parse(r io.ReaderAt, size int64) ([]sheetRow, error)
+}
+
+// csvSheetParser 解析 CSV 檔案
+type csvSheetParser struct{}
+
+// Parse 實現 importSheetParser 介面
+func (csvSheetParser) Parse(r io.ReaderAt, size int64) ([]sheetRow, error) {
+	reader := csv.NewReader(io.NewSectionReader(r, 0, size))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1 // 欄位數由標題決定，缺少的欄位視為空值
+
+	rows := []sheetRow{}
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, sheetRow{Line: line, Values: values})
+	}
+	return rows, nil
+}
+
+const defaultMaxImportFileBytes = 10 << 20 // 未設定 IMPORT_MAX_FILE_BYTES 時的匯入檔案大小上限
+
+// maxImportFileBytes 返回匯入檔案 (CSV、XLSX) 的大小上限，由 IMPORT_MAX_FILE_BYTES 設定
+func maxImportFileBytes() int64 {
+	if config.Cfg != nil && config.Cfg.ImportMaxFileBytes > 0 {
+		return config.Cfg.ImportMaxFileBytes
+	}
+	return defaultMaxImportFileBytes
+}
+
+// readCSVUpload 讀取 multipart 表單中的 CSV 檔案，第一列必須為標題列
+// required 列出必須存在的標題，標題比對不區分大小寫，空白與連字號視同底線
+func readCSVUpload(c echo.Context, field string, required ...string) ([]csvRecord, error) {
+	return readImportUpload(c, field, csvSheetParser{}, required...)
+}
+
+// readSpreadsheetUpload 與 readCSVUpload 相同，但檔名副檔名為 .xlsx 時改以 XLSX 解析 (讀取第一個工作表)
+func readSpreadsheetUpload(c echo.Context, field string, required ...string) ([]csvRecord, error) {
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("missing multipart file field %q", field)
+	}
+	var parser importSheetParser = csvSheetParser{}
+	if strings.EqualFold(filepath.Ext(fileHeader.Filename), ".xlsx") {
+		parser = xlsxSheetParser{}
+	}
+	return readImportUpload(c, field, parser, required...)
+}
+
+// readImportUpload 以 parser 解析 multipart 表單中的檔案，並以標題列將各列轉為 csvRecord
+// 檔案超過 IMPORT_MAX_FILE_BYTES 時返回 413 的 *utils.CustomError，其他錯誤由呼叫端以 400 返回
+func readImportUpload(c echo.Context, field string, parser importSheetParser, required ...string) ([]csvRecord, error) {
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("missing multipart file field %q", field)
+	}
+	if maxBytes := maxImportFileBytes(); fileHeader.Size > maxBytes {
+		return nil, utils.NewRequestTooLargeError("File too large", maxBytes)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer file.Close()
+
+	rows, err := parser.Parse(file, fileHeader.Size)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("failed to read header: file is empty")
+	}
+
+	header := rows[0].Values
+	columns := make([]string, len(header))
+	present := map[string]bool{}
+	for i, h := range header {
+		columns[i] = normalizeCSVHeader(h)
+		present[columns[i]] = true
+	}
+	missing := []string{}
+	for _, name := range required {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("header is missing required columns: %s", strings.Join(missing, ", "))
+	}
+
+	records := []csvRecord{}
+	for _, row := range rows[1:] {
+		record := csvRecord{Line: row.Line, Fields: make(map[string]string, len(columns))}
+		empty := true
+		for i, name := range columns {
+			if i < len(row.Values) {
+				record.Fields[name] = strings.TrimSpace(row.Values[i])
+				if record.Fields[name] != "" {
+					empty = false
+				}
+			}
+		}
+		if empty {
+			continue // 忽略空白列
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// field 返回第一個非空的欄位值，用於支援同一欄位的多種標題 (例如 "company" 與 "company_name")
+func (r csvRecord) field(names ...string) string {
+	for _, name := range names {
+		if v := r.Fields[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalizeCSVHeader 將標題轉為小寫並以底線取代空白與連字號，例如 "Tax ID" => "tax_id"
+func normalizeCSVHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))) // 去除 Excel 匯出的 BOM
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(h)
+}
+
+// validationDetails 將驗證錯誤轉換為依 Accept-Language 翻譯的欄位錯誤列表，與全局錯誤處理器的格式一致
+func validationDetails(c echo.Context, err error) interface{} {
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		return utils.TranslateValidationErrors(c, validationErrors)
+	}
+	return err.Error()
+}
+
+// newImportResult 合併驗證失敗與寫入結果，依行號排序並計算彙總數量
+func newImportResult(dryRun bool, invalid, written []models.ImportRowResult) *models.ImportResult {
+	rows := append(append([]models.ImportRowResult{}, invalid...), written...)
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Line < rows[j].Line })
+
+	result := &models.ImportResult{DryRun: dryRun, Total: len(rows), Rows: rows}
+	for _, row := range rows {
+		switch row.Action {
+		case models.ImportActionCreated:
+			result.Created++
+		case models.ImportActionUpdated:
+			result.Updated++
+		case models.ImportActionSkipped:
+			result.Skipped++
+		default:
+			result.Failed++
+		}
+	}
+	return result
+}
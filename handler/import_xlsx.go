@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// XLSX 解析的上限，避免壓縮炸彈或極度稀疏的工作表耗盡記憶體
+const (
+	xlsxMaxPartSize = 64 << 20 // 單一 XML 檔案 (工作表、共用字串) 解壓縮後的大小上限
+	xlsxMaxColumns  = 1024     // 儲存格欄位的上限 (AMJ)；空白儲存格會補齊到每列最後一個有值的欄位
+)
+
+// xlsxSheetParser 解析 XLSX (Office Open XML) 檔案的第一個工作表
+// 只讀取儲存格的值，不處理公式計算、合併儲存格與日期格式
+type xlsxSheetParser struct{}
+
+// xlsxWorkbook xl/workbook.xml 中的工作表清單
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelationships xl/_rels/workbook.xml.rels，對應工作表的關聯 ID 與檔案路徑
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText 共用字串或內嵌字串，富文字由多個 run 組成
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// String 返回完整文字 (合併所有富文字 run)
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+// xlsxSharedStrings xl/sharedStrings.xml
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxWorksheet 工作表的列與儲存格
+type xlsxWorksheet struct {
+	Rows []struct {
+		Num   int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"` // 例如 "C5"
+			Type   string   `xml:"t,attr"` // s (共用字串)、inlineStr、str、b、n (預設)…
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Parse 實現 importSheetParser 介面
+func (xlsxSheetParser) Parse(r io.ReaderAt, size int64) ([]sheetRow, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open XLSX file: %w", err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := xlsxFirstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok { // 只有數字的活頁簿沒有共用字串
+		if err := decodeXLSXPart(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+	sheetFile, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("failed to parse XLSX: worksheet %s not found", sheetPath)
+	}
+	var sheet xlsxWorksheet
+	if err := decodeXLSXPart(sheetFile, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([]sheetRow, 0, len(sheet.Rows))
+	for i, row := range sheet.Rows {
+		line := row.Num
+		if line == 0 {
+			line = i + 1 // 省略列號時依序編號
+		}
+		values := []string{}
+		for j, cell := range row.Cells {
+			col := j
+			if cell.Ref != "" {
+				if col, err = xlsxColumnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			for len(values) <= col {
+				values = append(values, "") // 空白儲存格不會出現在 XML 中
+			}
+			switch cell.Type {
+			case "s":
+				idx, err := strconv.Atoi(cell.Value)
+				if err != nil || idx < 0 || idx >= len(shared.Items) {
+					return nil, fmt.Errorf("failed to parse XLSX: invalid shared string index in cell %s", cell.Ref)
+				}
+				values[col] = shared.Items[idx].String()
+			case "inlineStr":
+				values[col] = cell.Inline.String()
+			case "b":
+				values[col] = strings.ToUpper(strconv.FormatBool(cell.Value == "1"))
+			default:
+				values[col] = cell.Value
+			}
+		}
+		rows = append(rows, sheetRow{Line: line, Values: values})
+	}
+	return rows, nil
+}
+
+// xlsxFirstSheetPath 透過活頁簿與其關聯檔案找出第一個工作表的路徑，沒有關聯資訊時使用預設的 sheet1.xml
+func xlsxFirstSheetPath(files map[string]*zip.File) (string, error) {
+	const defaultPath = "xl/worksheets/sheet1.xml"
+	workbookFile, ok := files["xl/workbook.xml"]
+	relsFile, hasRels := files["xl/_rels/workbook.xml.rels"]
+	if !ok || !hasRels {
+		return defaultPath, nil
+	}
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(workbookFile, &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("failed to parse XLSX: workbook has no sheets")
+	}
+	var rels xlsxRelationships
+	if err := decodeXLSXPart(relsFile, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil // 絕對路徑 (相對於封裝根目錄)
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return defaultPath, nil
+}
+
+// decodeXLSXPart 解壓縮並解析 XLSX 中的一個 XML 檔案
+// 解壓縮後超過 xlsxMaxPartSize 時返回 413 的 *utils.CustomError (不論 zip 標頭宣告的大小是否屬實)
+func decodeXLSXPart(f *zip.File, v interface{}) error {
+	tooLarge := utils.NewRequestTooLargeError("File too large when decompressed", xlsxMaxPartSize)
+	if f.UncompressedSize64 > xlsxMaxPartSize {
+		return tooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open XLSX part %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	limited := &io.LimitedReader{R: rc, N: xlsxMaxPartSize + 1}
+	if err := xml.NewDecoder(limited).Decode(v); err != nil {
+		if limited.N == 0 {
+			return tooLarge
+		}
+		return fmt.Errorf("failed to parse XLSX part %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// xlsxColumnIndex 將儲存格參照 (例如 "AB12") 的欄位字母轉為從 0 開始的欄位索引
+// 參照必須是大寫的欄位字母加上列號，欄位超過 xlsxMaxColumns 時返回錯誤
+func xlsxColumnIndex(ref string) (int, error) {
+	col, letters := 0, 0
+	for letters < len(ref) && ref[letters] >= 'A' && ref[letters] <= 'Z' {
+		col = col*26 + int(ref[letters]-'A'+1)
+		letters++
+	}
+	row := ref[letters:]
+	if letters == 0 || letters > 3 || row == "" || strings.Trim(row, "0123456789") != "" { // XLSX 最多 16384 欄 (XFD)
+		return 0, fmt.Errorf("failed to parse XLSX: invalid cell reference %q", ref)
+	}
+	if col > xlsxMaxColumns {
+		return 0, fmt.Errorf("failed to parse XLSX: cell %s is beyond the %d column limit", ref, xlsxMaxColumns)
+	}
+	return col - 1, nil
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// XLSX 測試檔案的組成部分 (只包含解析器讀取的檔案)
+const (
+	xlsxTestWorkbook = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Products" sheetId="1" r:id="rId2"/><sheet name="Notes" sheetId="2" r:id="rId3"/></sheets></workbook>`
+	xlsxTestRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/products.xml"/>` +
+		`</Relationships>`
+	xlsxTestSharedStrings = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<si><t>sku</t></si><si><t>name</t></si><si><r><t>M6</t></r><r><rPr><b/></rPr><t>-20</t></r></si><si><t xml:space="preserve"> padded </t></si></sst>`
+)
+
+// xlsxTestSheet 以 rows (<row> 元素) 組成工作表 XML
+func xlsxTestSheet(rows string) string {
+	return `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`
+}
+
+// newTestXLSX 以 parts (封裝中的路徑 => 內容) 建立 XLSX 檔案
+func newTestXLSX(t *testing.T, parts map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestXLSXSheetParser(t *testing.T) {
+	productsSheet := xlsxTestSheet(
+		`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>price</t></is></c></row>` +
+			`<row r="3"><c r="A3" t="s"><v>2</v></c><c r="D3"><v>12.5</v></c></row>` +
+			`<row r="4"><c r="B4" t="inlineStr"><is><r><t>Hex </t></r><r><t>bolt</t></r></is></c><c r="C4" t="b"><v>1</v></c><c r="E4" t="str"><v>ZN-01</v></c><c r="F4" t="s"><v>3</v></c></row>` +
+			`<row r="5"><c r="C5" t="b"><v>0</v></c><c r="AMJ5"><v>9</v></c></row>`)
+	wideRow := make([]string, 1024)
+	wideRow[2], wideRow[1023] = "FALSE", "9"
+
+	tests := []struct {
+		name  string
+		parts map[string]string
+		want  []sheetRow
+	}{
+		{
+			name: "first sheet from workbook relationships",
+			parts: map[string]string{
+				"xl/workbook.xml":            xlsxTestWorkbook,
+				"xl/_rels/workbook.xml.rels": xlsxTestRels,
+				"xl/sharedStrings.xml":       xlsxTestSharedStrings,
+				"xl/worksheets/products.xml": productsSheet,
+				"xl/worksheets/sheet1.xml":   xlsxTestSheet(`<row r="1"><c r="A1" t="inlineStr"><is><t>not the first sheet</t></is></c></row>`),
+			},
+			want: []sheetRow{
+				{Line: 1, Values: []string{"sku", "name", "", "price"}}, // 空白的 C1 不在 XML 中
+				{Line: 3, Values: []string{"M6-20", "", "", "12.5"}},    // 富文字合併所有 run；第 2 列完全空白
+				{Line: 4, Values: []string{"", "Hex bolt", "TRUE", "", "ZN-01", " padded "}},
+				{Line: 5, Values: wideRow},
+			},
+		},
+		{
+			name: "absolute relationship target",
+			parts: map[string]string{
+				"xl/workbook.xml":            xlsxTestWorkbook,
+				"xl/_rels/workbook.xml.rels": strings.Replace(xlsxTestRels, `Target="worksheets/products.xml"`, `Target="/xl/worksheets/products.xml"`, 1),
+				"xl/worksheets/products.xml": xlsxTestSheet(`<row r="2"><c r="B2"><v>42</v></c></row>`),
+			},
+			want: []sheetRow{{Line: 2, Values: []string{"", "42"}}},
+		},
+		{
+			name: "default sheet without workbook and shared strings",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": xlsxTestSheet(`<row><c><v>1</v></c><c><v>2</v></c></row><row><c r="B2"><v>3</v></c></row><row/>`),
+			},
+			want: []sheetRow{
+				{Line: 1, Values: []string{"1", "2"}}, // 省略列號與儲存格參照時依序編號
+				{Line: 2, Values: []string{"", "3"}},
+				{Line: 3, Values: []string{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := newTestXLSX(t, tt.parts)
+			rows, err := xlsxSheetParser{}.Parse(file, file.Size())
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %q, want %q", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestXLSXSheetParserErrors(t *testing.T) {
+	sheet := func(cells string) map[string]string {
+		return map[string]string{
+			"xl/sharedStrings.xml":     xlsxTestSharedStrings,
+			"xl/worksheets/sheet1.xml": xlsxTestSheet(`<row r="1">` + cells + `</row>`),
+		}
+	}
+	tests := []struct {
+		name    string
+		parts   map[string]string
+		raw     []byte // 不為 nil 時直接作為上傳檔案，不建立 zip
+		wantErr string
+	}{
+		{name: "not a zip file", raw: []byte("sku,name\nM6,bolt\n"), wantErr: "failed to open XLSX file"},
+		{name: "missing worksheet", parts: map[string]string{"xl/sharedStrings.xml": xlsxTestSharedStrings}, wantErr: "worksheet xl/worksheets/sheet1.xml not found"},
+		{
+			name: "relationship to missing worksheet",
+			parts: map[string]string{
+				"xl/workbook.xml":            xlsxTestWorkbook,
+				"xl/_rels/workbook.xml.rels": xlsxTestRels,
+				"xl/worksheets/sheet1.xml":   xlsxTestSheet(""),
+			},
+			wantErr: "worksheet xl/worksheets/products.xml not found",
+		},
+		{
+			name: "workbook without sheets",
+			parts: map[string]string{
+				"xl/workbook.xml":            `<workbook><sheets/></workbook>`,
+				"xl/_rels/workbook.xml.rels": xlsxTestRels,
+			},
+			wantErr: "workbook has no sheets",
+		},
+		{name: "malformed sheet XML", parts: map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row>`}, wantErr: "failed to parse XLSX part xl/worksheets/sheet1.xml"},
+		{name: "shared string index out of range", parts: sheet(`<c r="A1" t="s"><v>4</v></c>`), wantErr: "invalid shared string index in cell A1"},
+		{name: "negative shared string index", parts: sheet(`<c r="A1" t="s"><v>-1</v></c>`), wantErr: "invalid shared string index in cell A1"},
+		{name: "non-numeric shared string index", parts: sheet(`<c r="A1" t="s"><v>x</v></c>`), wantErr: "invalid shared string index in cell A1"},
+		{name: "shared string without shared strings part", parts: map[string]string{"xl/worksheets/sheet1.xml": xlsxTestSheet(`<row><c r="A1" t="s"><v>0</v></c></row>`)}, wantErr: "invalid shared string index"},
+		{name: "reference without column", parts: sheet(`<c r="12"><v>1</v></c>`), wantErr: `invalid cell reference "12"`},
+		{name: "reference without row", parts: sheet(`<c r="B"><v>1</v></c>`), wantErr: `invalid cell reference "B"`},
+		{name: "lower case reference", parts: sheet(`<c r="a1"><v>1</v></c>`), wantErr: `invalid cell reference "a1"`},
+		{name: "trailing characters", parts: sheet(`<c r="A1x"><v>1</v></c>`), wantErr: `invalid cell reference "A1x"`},
+		{name: "four column letters", parts: sheet(`<c r="AAAA1"><v>1</v></c>`), wantErr: `invalid cell reference "AAAA1"`},
+		{name: "column beyond limit", parts: sheet(`<c r="AMK1"><v>1</v></c>`), wantErr: "cell AMK1 is beyond the 1024 column limit"},
+		{name: "last Excel column is beyond limit", parts: sheet(`<c r="XFD1"><v>1</v></c>`), wantErr: "cell XFD1 is beyond the 1024 column limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file *bytes.Reader
+			if tt.raw != nil {
+				file = bytes.NewReader(tt.raw)
+			} else {
+				file = newTestXLSX(t, tt.parts)
+			}
+			rows, err := xlsxSheetParser{}.Parse(file, file.Size())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse = %q, %v; want an error containing %q", rows, err, tt.wantErr)
+			}
+			var customErr *utils.CustomError
+			if errors.As(err, &customErr) {
+				t.Errorf("error = %v, want a plain error reported as 400", err)
+			}
+		})
+	}
+}
+
+// TestXLSXSheetParserDecompressedLimit 工作表解壓縮後超過 xlsxMaxPartSize 時返回 413，不解壓縮內容
+func TestXLSXSheetParserDecompressedLimit(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := []byte(xlsxTestSheet(""))
+	padding := bytes.Repeat([]byte(" "), 1<<20)
+	for written := 0; written <= xlsxMaxPartSize; written += len(padding) {
+		if _, err := f.Write(padding); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.Write(sheet); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 1<<20 {
+		t.Fatalf("compressed test file is %d bytes, want a small file that expands past the limit", buf.Len())
+	}
+
+	file := bytes.NewReader(buf.Bytes())
+	_, err = xlsxSheetParser{}.Parse(file, file.Size())
+	var customErr *utils.CustomError
+	if !errors.As(err, &customErr) || customErr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Parse error = %v, want 413", err)
+	}
+	if details, _ := customErr.Details.(map[string]interface{}); details["max_bytes"] != int64(xlsxMaxPartSize) {
+		t.Errorf("details = %v, want max_bytes %d", customErr.Details, xlsxMaxPartSize)
+	}
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/jobs"
+)
+
+// JobHandler 定義背景工作處理器結構，包含 jobs.Runner 的依賴
+type JobHandler struct {
+	runner jobs.Runner
+}
+
+// NewJobHandler 創建 JobHandler 實例
+func NewJobHandler(r jobs.Runner) *JobHandler {
+	return &JobHandler{runner: r}
+}
+
+// GetJobs 獲取所有背景工作的排程與最近一次執行的結果
+func (h *JobHandler) GetJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.runner.Statuses())
+}
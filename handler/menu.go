@@ -3,11 +3,12 @@ package handler
 import (
 	"database/sql" // 導入 sql 包，用於檢查 ErrNoRows
 	"net/http"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/middleware/etag"
+	"github.com/wac0705/fastener-api/middleware/jwt"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/service"
 	"github.com/wac0705/fastener-api/utils"
@@ -16,11 +17,12 @@ import (
 // MenuHandler 定義選單處理器結構，包含 MenuService 的依賴
 type MenuHandler struct {
 	menuService service.MenuService
+	roleService service.RoleService // 將路徑中角色的 public_id 轉換為 ID
 }
 
 // NewMenuHandler 創建 MenuHandler 實例
-func NewMenuHandler(s service.MenuService) *MenuHandler {
-	return &MenuHandler{menuService: s}
+func NewMenuHandler(s service.MenuService, roleService service.RoleService) *MenuHandler {
+	return &MenuHandler{menuService: s, roleService: roleService}
 }
 
 // CreateMenu 創建新選單
@@ -28,83 +30,95 @@ func (h *MenuHandler) CreateMenu(c echo.Context) error {
 	menu := new(models.Menu)
 
 	if err := c.Bind(menu); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
+	menu.RecordActors = models.RecordActors{} // 唯讀欄位，忽略請求中的值
 
 	if err := c.Validate(menu); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.menuService.CreateMenu(menu); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.menuService.CreateMenu(c.Request().Context(), menu, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to create menu", zap.Error(err), zap.String("menu_name", menu.Name))
+		utils.Logger(c).Error("Failed to create menu", zap.Error(err), zap.String("menu_name", menu.Name))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
-	return c.JSON(http.StatusCreated, menu)
+	return respondCreated(c, "menus", menu.ID, menu)
 }
 
 // GetMenus 獲取所有選單
 func (h *MenuHandler) GetMenus(c echo.Context) error {
-	menus, err := h.menuService.GetAllMenus()
+	menus, err := h.menuService.GetAllMenus(c.Request().Context())
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get menus", zap.Error(err))
+		utils.Logger(c).Error("Failed to get menus", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
-	return c.JSON(http.StatusOK, menus)
+	return etag.JSON(c, http.StatusOK, menus)
 }
 
 // GetMenuById 根據 ID 獲取選單
 func (h *MenuHandler) GetMenuById(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.menuService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
-	menu, err := h.menuService.GetMenuByID(id)
+	menu, err := h.menuService.GetMenuByID(c.Request().Context(), id)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get menu by ID", zap.Int("menu_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to get menu by ID", zap.Int("menu_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	if menu == nil { // Service 層返回 nil, nil 表示未找到
 		return c.JSON(http.StatusNotFound, utils.ErrNotFound)
 	}
 
-	return c.JSON(http.StatusOK, menu)
+	return etag.JSON(c, http.StatusOK, menu)
 }
 
 // UpdateMenu 更新選單信息
 func (h *MenuHandler) UpdateMenu(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.menuService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
 	menu := new(models.Menu)
 	if err := c.Bind(menu); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 確保更新的是正確的選單 ID
 	menu.ID = id
+	menu.RecordActors = models.RecordActors{} // 唯讀欄位，忽略請求中的值
 
 	if err := c.Validate(menu); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.menuService.UpdateMenu(menu); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.menuService.UpdateMenu(c.Request().Context(), menu, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to update menu", zap.Int("menu_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to update menu", zap.Int("menu_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -113,18 +127,36 @@ func (h *MenuHandler) UpdateMenu(c echo.Context) error {
 
 // DeleteMenu 刪除選單
 func (h *MenuHandler) DeleteMenu(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.menuService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
-	if err := h.menuService.DeleteMenu(id); err != nil {
+	if err := h.menuService.DeleteMenu(c.Request().Context(), id); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to delete menu", zap.Int("menu_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to delete menu", zap.Int("menu_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
 	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
 }
+
+// GetMenusByRoleID 獲取角色可訪問的選單，供前端產生動態選單；以 ETag 支援條件式 GET，選單未變更時返回 304
+func (h *MenuHandler) GetMenusByRoleID(c echo.Context) error {
+	roleID, idErr := pathID(c, "roleID", h.roleService.ResolvePublicID) // 從 URL 參數獲取角色 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	menus, err := h.menuService.GetMenusByRoleID(c.Request().Context(), roleID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get menus by role ID", zap.Int("role_id", roleID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return etag.JSON(c, http.StatusOK, menus)
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// MetricsHandler 定義指標處理器結構，包含指標註冊表的依賴
+type MetricsHandler struct {
+	registry metrics.Registry
+}
+
+// NewMetricsHandler 創建 MetricsHandler 實例
+func NewMetricsHandler(reg metrics.Registry) *MetricsHandler {
+	return &MetricsHandler{registry: reg}
+}
+
+// Metrics 以 Prometheus 文字格式輸出所有指標，供 Prometheus 抓取
+func (h *MetricsHandler) Metrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.TextContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	if err := h.registry.WriteText(c.Response()); err != nil {
+		utils.Logger(c).Warn("Failed to write metrics", zap.Error(err)) // 標頭已送出，只能記錄
+	}
+	return nil
+}
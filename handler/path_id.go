@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// publicIDResolver 將對外的 UUID 識別碼 (public_id) 轉換為內部的整數 ID，即各 Service 的 ResolvePublicID
+type publicIDResolver func(ctx context.Context, publicID string) (int, error)
+
+// pathID 讀取路徑參數 name 指定的資源 ID：整數直接作為 ID，UUID 視為 public_id 並以 resolve 查詢對應的 ID
+// 兩者皆非時返回 400，public_id 不存在時返回 utils.ErrNotFound
+func pathID(c echo.Context, name string, resolve publicIDResolver) (int, *utils.CustomError) {
+	value := c.Param(name)
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	if !isUUID(value) {
+		return 0, utils.ErrBadRequest.SetDetails("Invalid " + name + " in path, expected an integer ID or a UUID")
+	}
+	id, err := resolve(c.Request().Context(), value)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return 0, customErr
+		}
+		utils.Logger(c).Error("Failed to resolve public ID", zap.String("param", name), zap.String("public_id", value), zap.Error(err))
+		return 0, utils.ErrInternalServer
+	}
+	return id, nil
+}
+
+// isUUID 檢查 s 是否為 8-4-4-4-12 格式的 UUID (十六進位不分大小寫)
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			c := s[i]
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -1,26 +1,48 @@
 package handler
 
 import (
-	"database/sql" // 導入 sql 包，用於檢查 ErrNoRows
+	"fmt"
+	"io"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/config"
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/middleware/authz"
+	"github.com/wac0705/fastener-api/middleware/etag"
+	"github.com/wac0705/fastener-api/middleware/jwt"
 	"github.com/wac0705/fastener-api/models"
-	"github.com->wac0705/fastener-api/service"
+	"github.com/wac0705/fastener-api/service"
 	"github.com/wac0705/fastener-api/utils"
 )
 
 // ProductDefinitionHandler 定義產品定義處理器結構，包含 ProductDefinitionService 的依賴
 type ProductDefinitionHandler struct {
 	productDefinitionService service.ProductDefinitionService
+	permissionService        service.PermissionService // 用於檢查 version_at 等額外權限
 }
 
 // NewProductDefinitionHandler 創建 ProductDefinitionHandler 實例
-func NewProductDefinitionHandler(s service.ProductDefinitionService) *ProductDefinitionHandler {
-	return &ProductDefinitionHandler{productDefinitionService: s}
+func NewProductDefinitionHandler(s service.ProductDefinitionService, permissionService service.PermissionService) *ProductDefinitionHandler {
+	return &ProductDefinitionHandler{productDefinitionService: s, permissionService: permissionService}
+}
+
+// quoteCurrencyPattern currency 查詢參數的格式 (ISO 4217 三個英文字母)
+var quoteCurrencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// parseQuoteCurrency 解析 currency 查詢參數 (不分大小寫)，未指定時返回空字串
+func parseQuoteCurrency(c echo.Context) (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(c.QueryParam("currency")))
+	if currency != "" && !quoteCurrencyPattern.MatchString(currency) {
+		return "", utils.ErrBadRequest.SetDetails("Invalid currency, expected an ISO 4217 code such as EUR")
+	}
+	return currency, nil
 }
 
 // CreateProductCategory 創建新產品類別
@@ -28,35 +50,73 @@ func (h *ProductDefinitionHandler) CreateProductCategory(c echo.Context) error {
 	category := new(models.ProductCategory)
 
 	if err := c.Bind(category); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
+	category.Children = nil // 唯讀欄位，子類別需各自指定 parent_id
 
 	if err := c.Validate(category); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.productDefinitionService.CreateProductCategory(category); err != nil {
+	if err := h.productDefinitionService.CreateProductCategory(c.Request().Context(), category); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to create product category", zap.Error(err), zap.String("category_name", category.Name))
+		utils.Logger(c).Error("Failed to create product category", zap.Error(err), zap.String("category_name", category.Name))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
-	return c.JSON(http.StatusCreated, category)
+	return respondCreated(c, "product_categories", category.ID, category)
 }
 
-// GetProductCategories 獲取所有產品類別
+// GetProductCategories 獲取所有產品類別，include=counts 時返回各類別的 product_count (見 parseCategoryListOptions)
 func (h *ProductDefinitionHandler) GetProductCategories(c echo.Context) error {
-	categories, err := h.productDefinitionService.GetAllProductCategories()
+	opts, err := parseCategoryListOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+	categories, err := h.productDefinitionService.GetAllProductCategories(c.Request().Context(), opts)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product categories", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return etag.JSON(c, http.StatusOK, categories)
+}
+
+// parseCategoryListOptions 解析類別列表的查詢參數：include=counts 時返回 product_count (含子孫類別，預設不計已停售的產品)，
+// include_discontinued=true 時一併計入已停售的產品定義
+func parseCategoryListOptions(c echo.Context) (models.ProductCategoryListOptions, error) {
+	opts := models.ProductCategoryListOptions{IncludeDiscontinued: c.QueryParam("include_discontinued") == "true"}
+	for _, include := range strings.Split(c.QueryParam("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "counts":
+			opts.IncludeCounts = true
+		default:
+			return opts, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid include: %s, expected counts", include))
+		}
+	}
+	return opts, nil
+}
+
+// GetProductCategoryTree 獲取樹狀的產品類別，子類別放在 children 中；支援與 GetProductCategories 相同的 include=counts
+func (h *ProductDefinitionHandler) GetProductCategoryTree(c echo.Context) error {
+	opts, err := parseCategoryListOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+	tree, err := h.productDefinitionService.GetProductCategoryTree(c.Request().Context(), opts)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get product categories", zap.Error(err))
+		utils.Logger(c).Error("Failed to get product category tree", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
-	return c.JSON(http.StatusOK, categories)
+	return etag.JSON(c, http.StatusOK, tree)
 }
 
 // GetProductCategoryById 根據 ID 獲取產品類別
@@ -66,19 +126,19 @@ func (h *ProductDefinitionHandler) GetProductCategoryById(c echo.Context) error
 		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
 	}
 
-	category, err := h.productDefinitionService.GetProductCategoryByID(id)
+	category, err := h.productDefinitionService.GetProductCategoryByID(c.Request().Context(), id)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get product category by ID", zap.Int("category_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to get product category by ID", zap.Int("category_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	if category == nil { // Service 層返回 nil, nil 表示未找到
 		return c.JSON(http.StatusNotFound, utils.ErrNotFound)
 	}
 
-	return c.JSON(http.StatusOK, category)
+	return etag.JSON(c, http.StatusOK, category)
 }
 
 // UpdateProductCategory 更新產品類別信息
@@ -90,21 +150,22 @@ func (h *ProductDefinitionHandler) UpdateProductCategory(c echo.Context) error {
 
 	category := new(models.ProductCategory)
 	if err := c.Bind(category); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	// 確保更新的是正確的類別 ID
 	category.ID = id
+	category.Children = nil // 唯讀欄位，子類別需各自指定 parent_id
 
 	if err := c.Validate(category); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.productDefinitionService.UpdateProductCategory(category); err != nil {
+	if err := h.productDefinitionService.UpdateProductCategory(c.Request().Context(), category); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to update product category", zap.Int("category_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to update product category", zap.Int("category_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -112,17 +173,31 @@ func (h *ProductDefinitionHandler) UpdateProductCategory(c echo.Context) error {
 }
 
 // DeleteProductCategory 刪除產品類別
+// 查詢參數 children 決定仍有子類別時的處理方式：block (預設，返回 409)、cascade (一併刪除子孫類別)、detach (子類別改為根類別)；
+// 類別仍有產品定義時返回 409，可用 reassign_to=<categoryID> 將產品定義移到其他類別後再刪除
 func (h *ProductDefinitionHandler) DeleteProductCategory(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
 	}
+	mode := models.CategoryDeleteMode(c.QueryParam("children"))
+	if mode == "" {
+		mode = models.CategoryDeleteBlock
+	}
+	var reassignTo *int
+	if reassignStr := c.QueryParam("reassign_to"); reassignStr != "" {
+		target, convErr := strconv.Atoi(reassignStr)
+		if convErr != nil || target <= 0 {
+			return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid reassign_to"))
+		}
+		reassignTo = &target
+	}
 
-	if err := h.productDefinitionService.DeleteProductCategory(id); err != nil {
+	if err := h.productDefinitionService.DeleteProductCategory(c.Request().Context(), id, mode, reassignTo); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to delete product category", zap.Int("category_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to delete product category", zap.Int("category_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -134,70 +209,477 @@ func (h *ProductDefinitionHandler) CreateProductDefinition(c echo.Context) error
 	definition := new(models.ProductDefinition)
 
 	if err := c.Bind(definition); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
+	definition.CategoryName = "" // 唯讀欄位，忽略請求中的值
+	definition.MatchRank = nil
+	definition.QuotedPrice = nil
+	definition.DiscontinuedAt = nil // 停售狀態只能透過 DELETE 與 reactivate 變更
+	definition.Discontinued = false
+	definition.ImageURL = "" // 圖片只能透過 /image 子資源變更
+	definition.VariantCount = nil
+	definition.RecordActors = models.RecordActors{}
 
 	if err := c.Validate(definition); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.productDefinitionService.CreateProductDefinition(definition); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.productDefinitionService.CreateProductDefinition(c.Request().Context(), definition, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to create product definition", zap.Error(err), zap.String("definition_name", definition.Name))
+		utils.Logger(c).Error("Failed to create product definition", zap.Error(err), zap.String("definition_name", definition.Name))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
-	return c.JSON(http.StatusCreated, definition)
+	// 重新讀取以返回完整的記錄 (類別名稱等 JOIN 取得的唯讀欄位)；讀取失敗時仍返回已建立的記錄
+	if createdDefinition, err := h.productDefinitionService.GetProductDefinitionByID(c.Request().Context(), definition.ID, ""); err != nil {
+		utils.Logger(c).Warn("Failed to reload created product definition", zap.Int("definition_id", definition.ID), zap.Error(err))
+	} else if createdDefinition != nil {
+		definition = createdDefinition
+	}
+
+	return respondCreated(c, "product_definitions", definition.ID, definition)
 }
 
-// GetProductDefinitions 獲取所有產品定義
+// GetProductDefinitions 獲取產品定義列表
+// 預設不包含已停售的產品，include_discontinued=true 時一併返回；
+// 支援查詢參數 page、page_size、category_id (搭配 descendants=true 時包含所有子類別的產品)、q (模糊搜尋名稱/描述) 與 sort (name、price、standard、created_at，前綴 "-" 為降序)；
+// standard 依標準代號篩選 (例如 "din933" 與 "DIN 933" 相同)；currency 以該幣別報價並返回 quoted_price；
+// search 為全文搜尋 (例如 "M8x30 hex flange zinc")，結果帶 match_rank 並在未指定 sort 時依相關度排序；
+// variants=collapse 時只列出非變體的產品並返回 variant_count (預設 expand，變體逐列列出)；
+// price_min / price_max 依基準幣別價格篩選 (含邊界)，unit 依標準單位篩選 (不分大小寫)，has_image=true/false 依是否有圖片篩選；
+// 所有篩選條件以 AND 組合；
+// 未指定排序時依 ID 升序
 func (h *ProductDefinitionHandler) GetProductDefinitions(c echo.Context) error {
-	definitions, err := h.productDefinitionService.GetAllProductDefinitions()
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	filter := models.ProductDefinitionFilter{
+		Query:    strings.TrimSpace(c.QueryParam("q")),
+		Sort:     c.QueryParam("sort"),
+		Search:   strings.TrimSpace(c.QueryParam("search")),
+		Standard: strings.TrimSpace(c.QueryParam("standard")),
+	}
+	if filter.Currency, err = parseQuoteCurrency(c); err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+	if categoryIDStr := c.QueryParam("category_id"); categoryIDStr != "" {
+		categoryID, convErr := strconv.Atoi(categoryIDStr)
+		if convErr != nil || categoryID <= 0 {
+			return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid category_id"))
+		}
+		filter.CategoryID = &categoryID
+	}
+	filter.IncludeDiscontinued = c.QueryParam("include_discontinued") == "true"
+	if descendantsStr := c.QueryParam("descendants"); descendantsStr != "" {
+		descendants, convErr := strconv.ParseBool(descendantsStr)
+		if convErr != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid descendants, expected true or false"))
+		}
+		filter.IncludeDescendants = descendants
+	}
+	if variants := c.QueryParam("variants"); variants != "" {
+		filter.Variants = models.VariantListMode(variants)
+		if !filter.Variants.IsValid() {
+			return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid variants, expected collapse or expand"))
+		}
+	}
+	if filter.PriceMin, err = parsePriceBound(c, "price_min"); err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+	if filter.PriceMax, err = parsePriceBound(c, "price_max"); err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+	if filter.PriceMin != nil && filter.PriceMax != nil && filter.PriceMin.Cmp(*filter.PriceMax) > 0 {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("price_min must not be greater than price_max"))
+	}
+	filter.Unit = strings.TrimSpace(c.QueryParam("unit"))
+	if hasImageStr := c.QueryParam("has_image"); hasImageStr != "" {
+		hasImage, convErr := strconv.ParseBool(hasImageStr)
+		if convErr != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid has_image, expected true or false"))
+		}
+		filter.HasImage = &hasImage
+	}
+
+	definitions, err := h.productDefinitionService.GetAllProductDefinitions(c.Request().Context(), filter, pagination)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product definitions", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return etag.JSON(c, http.StatusOK, definitions)
+}
+
+// parsePriceBound 解析價格範圍查詢參數 (例如 price_min=0.5)，未指定時返回 nil；不接受負數
+func parsePriceBound(c echo.Context, name string) (*decimal.Decimal, error) {
+	raw := strings.TrimSpace(c.QueryParam(name))
+	if raw == "" {
+		return nil, nil
+	}
+	price, err := decimal.Parse(raw)
+	if err != nil || price.Sign() < 0 {
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid %s, expected a non-negative decimal", name))
+	}
+	return &price, nil
+}
+
+// ReactivateProductDefinition 重新啟用已停售的產品定義
+func (h *ProductDefinitionHandler) ReactivateProductDefinition(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	definition, err := h.productDefinitionService.ReactivateProductDefinition(c.Request().Context(), id, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to reactivate product definition", zap.Int("definition_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, definition)
+}
+
+// CloneProductDefinition 複製產品定義 (含幣別價格、數量分級價格與換算單位)
+// 請求內容可選擇指定新的 name 與 sku，成功時返回 201 與新產品定義的 Location
+func (h *ProductDefinitionHandler) CloneProductDefinition(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取要複製的產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	req := new(models.ProductDefinitionCloneRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err // 驗證錯誤
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	definition, err := h.productDefinitionService.CloneProductDefinition(c.Request().Context(), id, *req, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to clone product definition", zap.Int("definition_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+
+	return respondCreated(c, "product_definitions", definition.ID, definition)
+}
+
+// parseImportPrice 解析匯入檔案中的價格，小數位數超過 decimal.StorageScale 時返回錯誤
+// 試算表的數字儲存格可能是指數表示法或帶有二進位誤差 (例如 0.10000000000000001)，此時改以 float64 的最短十進位表示解析
+func parseImportPrice(raw string) (decimal.Decimal, error) {
+	price, err := decimal.Parse(raw)
+	if err == nil {
+		if price, err = price.Rescale(decimal.StorageScale); err == nil {
+			return price, nil
+		}
+	}
+	f, floatErr := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if floatErr != nil {
+		return decimal.Decimal{}, err
+	}
+	if price, err = decimal.NewFromFloat(f); err != nil {
+		return decimal.Decimal{}, err
+	}
+	return price.Rescale(decimal.StorageScale)
+}
+
+// ImportProductDefinitions 從 CSV 或 XLSX 檔案批次匯入產品定義 (multipart 欄位 "file"，副檔名 .xlsx 時以 XLSX 解析)
+// 標題需包含 sku、name、category (或 category_name)、price，可選 description、standard、unit；依 SKU 新增或更新。
+// create_categories=true 時自動建立不存在的類別；partial=true 時只略過失敗的列，否則有任何一列失敗時整批不寫入；
+// dry_run=true 時只驗證並回報，不寫入
+func (h *ProductDefinitionHandler) ImportProductDefinitions(c echo.Context) error {
+	formOrQuery := func(name string) string {
+		if v := c.QueryParam(name); v != "" {
+			return v
+		}
+		return c.FormValue(name)
+	}
+	opts := models.ProductDefinitionImportOptions{
+		CreateCategories: formOrQuery("create_categories") == "true",
+		DryRun:           formOrQuery("dry_run") == "true",
+		Partial:          formOrQuery("partial") == "true",
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	records, err := readSpreadsheetUpload(c, "file", "sku", "name", "price")
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok { // 檔案超過大小上限 (413)
+			return c.JSON(customErr.Code, customErr)
+		}
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails(err.Error()))
+	}
+
+	invalid := []models.ImportRowResult{}
+	rows := []models.ProductDefinitionImportRow{}
+	for _, record := range records {
+		row := models.ProductDefinitionImportRow{
+			Line:         record.Line,
+			SKU:          record.Fields["sku"],
+			Name:         record.Fields["name"],
+			Description:  record.Fields["description"],
+			CategoryName: record.field("category", "category_name"),
+			Standard:     record.Fields["standard"],
+			Unit:         record.Fields["unit"],
+		}
+		if raw := record.Fields["price"]; raw != "" {
+			price, err := parseImportPrice(raw)
+			if err != nil {
+				invalid = append(invalid, models.ImportRowResult{Line: record.Line, Action: models.ImportActionInvalid, Details: fmt.Sprintf("Invalid price %s: %v", raw, err)})
+				continue
+			}
+			row.Price = price
+		}
+		if err := c.Validate(&row); err != nil {
+			invalid = append(invalid, models.ImportRowResult{Line: record.Line, Action: models.ImportActionInvalid, Details: validationDetails(c, err)})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	serviceOpts := opts
+	if !opts.Partial && len(invalid) > 0 {
+		serviceOpts.DryRun = true // 整批不寫入，其餘各列仍回報驗證結果
+	}
+	written, err := h.productDefinitionService.ImportProductDefinitions(c.Request().Context(), rows, serviceOpts, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to import product definitions", zap.Error(err), zap.Int("rows", len(rows)))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+
+	result := newImportResult(opts.DryRun, invalid, written)
+	result.RolledBack = !opts.DryRun && !opts.Partial && result.Failed > 0
+	return c.JSON(http.StatusOK, result)
+}
+
+// BulkUpdateProductPrices 依條件批次調整產品定義的基準價格
+// filter 可指定 category_id (搭配 include_descendants)、standard 與 skus，至少需一項且以 AND 組合，已停售的產品不受影響；
+// adjustment.type 為 percent (value 為百分比) 或 delta (value 為金額)，scale 與 rounding 未指定時使用預設的價格捨入設定；
+// dry_run=true 時只返回符合數量與調整前後的價格範例，不寫入
+func (h *ProductDefinitionHandler) BulkUpdateProductPrices(c echo.Context) error {
+	req := new(models.ProductBulkPriceUpdateRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err // 驗證錯誤
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	result, err := h.productDefinitionService.BulkUpdateProductPrices(c.Request().Context(), *req, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to bulk update product prices", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetProductVariants 獲取產品定義的變體 (include_discontinued=true 時包含已停售的變體)
+func (h *ProductDefinitionHandler) GetProductVariants(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	variants, err := h.productDefinitionService.GetProductVariants(c.Request().Context(), id, c.QueryParam("include_discontinued") == "true")
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get product definitions", zap.Error(err))
+		utils.Logger(c).Error("Failed to get product variants", zap.Int("definition_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
-	return c.JSON(http.StatusOK, definitions)
+	return c.JSON(http.StatusOK, variants)
 }
 
-// GetProductDefinitionById 根據 ID 獲取產品定義
+// GenerateProductVariants 依長度範圍與間距產生變體，SKU 已存在的長度會略過；有新建立的變體時返回 201
+func (h *ProductDefinitionHandler) GenerateProductVariants(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取父產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	req := new(models.ProductVariantGenerateRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err // 驗證錯誤
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	result, err := h.productDefinitionService.GenerateProductVariants(c.Request().Context(), id, *req, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to generate product variants", zap.Int("definition_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	if len(result.Created) == 0 {
+		return c.JSON(http.StatusOK, result) // 範圍內的變體都已存在
+	}
+	return c.JSON(http.StatusCreated, result)
+}
+
+// GetProductStandards 獲取使用中的標準代號列表，供篩選下拉選單使用
+func (h *ProductDefinitionHandler) GetProductStandards(c echo.Context) error {
+	standards, err := h.productDefinitionService.GetProductStandards(c.Request().Context())
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product standards", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, standards)
+}
+
+// GetProductDefinitionById 根據 ID 獲取產品定義，已停售的產品也會返回 (discontinued 為 true)，讓舊連結仍可使用
+// 支援查詢參數 currency (例如 EUR)，返回該幣別目前生效的 quoted_price，沒有時退回基準幣別並標記 converted=false；
+// version_at (RFC 3339 時間或 YYYY-MM-DD，日期表示當天結束時 (UTC)) 依變更歷史返回當時的產品定義，
+// 需要 product_definition:read_history 權限，且不能與 currency 同時使用
 func (h *ProductDefinitionHandler) GetProductDefinitionById(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	currency, err := parseQuoteCurrency(c)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, err)
 	}
 
-	definition, err := h.productDefinitionService.GetProductDefinitionByID(id)
+	var definition *models.ProductDefinition
+	if versionAtStr := c.QueryParam("version_at"); versionAtStr != "" {
+		versionAt, parseErr := parseVersionAt(versionAtStr)
+		if parseErr != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid version_at, expected RFC 3339 timestamp or YYYY-MM-DD"))
+		}
+		if currency != "" {
+			return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("version_at cannot be combined with currency"))
+		}
+		allowed, permErr := authz.HasPermission(c, "product_definition:read_history", h.permissionService)
+		if permErr != nil {
+			utils.Logger(c).Error("Failed to check permission for product definition version", zap.Error(permErr))
+			return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+		}
+		if !allowed {
+			return c.JSON(http.StatusForbidden, utils.ErrForbidden.SetDetails("Insufficient permissions to view product definition history"))
+		}
+		definition, err = h.productDefinitionService.GetProductDefinitionVersion(c.Request().Context(), id, versionAt)
+	} else {
+		definition, err = h.productDefinitionService.GetProductDefinitionByID(c.Request().Context(), id, currency)
+	}
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get product definition by ID", zap.Int("definition_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to get product definition by ID", zap.Int("definition_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	if definition == nil { // Service 層返回 nil, nil 表示未找到
 		return c.JSON(http.StatusNotFound, utils.ErrNotFound)
 	}
 
-	return c.JSON(http.StatusOK, definition)
+	return etag.JSON(c, http.StatusOK, definition)
+}
+
+// parseVersionAt 解析 version_at 查詢參數；只有日期時表示該日結束的時間 (UTC)，包含當天的所有變更
+func parseVersionAt(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// GetProductDefinitionHistory 分頁獲取產品定義的變更歷史 (由新到舊)，field=<欄位> 只返回該欄位的變更
+func (h *ProductDefinitionHandler) GetProductDefinitionHistory(c echo.Context) error {
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	pagination, err := utils.ParsePagination(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err)
+	}
+
+	history, err := h.productDefinitionService.GetProductDefinitionHistory(c.Request().Context(), id, c.QueryParam("field"), pagination)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product definition history", zap.Int("definition_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, history)
 }
 
 // UpdateProductDefinition 更新產品定義信息
 func (h *ProductDefinitionHandler) UpdateProductDefinition(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
 	definition := new(models.ProductDefinition)
 	if err := c.Bind(definition); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
+	definition.CategoryName = "" // 唯讀欄位，忽略請求中的值
+	definition.MatchRank = nil
+	definition.QuotedPrice = nil
+	definition.DiscontinuedAt = nil // 停售狀態只能透過 DELETE 與 reactivate 變更
+	definition.Discontinued = false
+	definition.ImageURL = "" // 圖片只能透過 /image 子資源變更
+	definition.VariantCount = nil
+	definition.RecordActors = models.RecordActors{}
 
 	// 確保更新的是正確的定義 ID
 	definition.ID = id
@@ -206,31 +688,411 @@ func (h *ProductDefinitionHandler) UpdateProductDefinition(c echo.Context) error
 		return err // 驗證錯誤
 	}
 
-	if err := h.productDefinitionService.UpdateProductDefinition(definition); err != nil {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.productDefinitionService.UpdateProductDefinition(c.Request().Context(), definition, claims.AccountID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to update product definition", zap.Int("definition_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to update product definition", zap.Int("definition_id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
 	return c.JSON(http.StatusOK, definition)
 }
 
-// DeleteProductDefinition 刪除產品定義
+// DeleteProductDefinition 停售產品定義 (軟刪除)，可透過 reactivate 重新啟用
 func (h *ProductDefinitionHandler) DeleteProductDefinition(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id")) // 從 URL 參數獲取 ID
+	id, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.productDefinitionService.DeleteProductDefinition(c.Request().Context(), id, claims.AccountID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to delete product definition", zap.Int("definition_id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+
+	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
+}
+
+// GetProductPrices 獲取產品定義的所有幣別價格
+func (h *ProductDefinitionHandler) GetProductPrices(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	prices, err := h.productDefinitionService.GetProductPrices(c.Request().Context(), productID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product prices", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, prices)
+}
+
+// CreateProductPrice 為產品定義新增幣別價格
+func (h *ProductDefinitionHandler) CreateProductPrice(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	price := new(models.ProductPrice)
+	if err := c.Bind(price); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	price.ID = 0
+	price.ProductID = productID
+
+	if err := c.Validate(price); err != nil {
+		return err // 驗證錯誤
+	}
+
+	if err := h.productDefinitionService.CreateProductPrice(c.Request().Context(), price); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to create product price", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusCreated, price)
+}
+
+// UpdateProductPrice 更新產品定義的幣別價格
+func (h *ProductDefinitionHandler) UpdateProductPrice(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	priceID, err := strconv.Atoi(c.Param("price_id"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
 	}
 
-	if err := h.productDefinitionService.DeleteProductDefinition(id); err != nil {
+	price := new(models.ProductPrice)
+	if err := c.Bind(price); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	price.ID = priceID
+	price.ProductID = productID
+
+	if err := c.Validate(price); err != nil {
+		return err // 驗證錯誤
+	}
+
+	if err := h.productDefinitionService.UpdateProductPrice(c.Request().Context(), price); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to update product price", zap.Int("definition_id", productID), zap.Int("price_id", priceID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, price)
+}
+
+// DeleteProductPrice 刪除產品定義的幣別價格
+func (h *ProductDefinitionHandler) DeleteProductPrice(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	priceID, err := strconv.Atoi(c.Param("price_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+	}
+
+	if err := h.productDefinitionService.DeleteProductPrice(c.Request().Context(), productID, priceID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to delete product price", zap.Int("definition_id", productID), zap.Int("price_id", priceID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
+}
+
+// GetProductPriceTiers 獲取產品定義的數量分級價格
+func (h *ProductDefinitionHandler) GetProductPriceTiers(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	tiers, err := h.productDefinitionService.GetProductPriceTiers(c.Request().Context(), productID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product price tiers", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, tiers)
+}
+
+// ReplaceProductPriceTiers 以請求中的 tiers 整組取代產品定義的數量分級價格
+func (h *ProductDefinitionHandler) ReplaceProductPriceTiers(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	req := new(models.ProductPriceTiersRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err // 驗證錯誤
+	}
+
+	resp, err := h.productDefinitionService.ReplaceProductPriceTiers(c.Request().Context(), productID, req.Tiers)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to replace product price tiers", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetProductPriceForQuantity 依查詢參數 qty (正整數) 解析產品定義適用的分級單價
+func (h *ProductDefinitionHandler) GetProductPriceForQuantity(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	qty, err := strconv.Atoi(c.QueryParam("qty"))
+	if err != nil || qty <= 0 {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("qty must be a positive integer"))
+	}
+
+	price, err := h.productDefinitionService.GetProductPriceForQuantity(c.Request().Context(), productID, qty)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product price for quantity", zap.Int("definition_id", productID), zap.Int("qty", qty), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, price)
+}
+
+// GetProductUnits 獲取產品定義的換算單位
+func (h *ProductDefinitionHandler) GetProductUnits(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	units, err := h.productDefinitionService.GetProductUnits(c.Request().Context(), productID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product units", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, units)
+}
+
+// ReplaceProductUnits 以請求中的 units 整組取代產品定義的換算單位
+func (h *ProductDefinitionHandler) ReplaceProductUnits(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	req := new(models.ProductUnitsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err // 驗證錯誤
+	}
+
+	units, err := h.productDefinitionService.ReplaceProductUnits(c.Request().Context(), productID, req.Units)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to replace product units", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, units)
+}
+
+// ConvertProductUnits 換算產品數量的單位，查詢參數 from、to (單位名稱) 與 value (數量)
+// 例如 ?from=kg&to=pcs&value=25
+func (h *ProductDefinitionHandler) ConvertProductUnits(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+	from, to := strings.TrimSpace(c.QueryParam("from")), strings.TrimSpace(c.QueryParam("to"))
+	if from == "" || to == "" {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("from and to are required"))
+	}
+	value, err := strconv.ParseFloat(c.QueryParam("value"), 64)
+	if err != nil || value < 0 {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("value must be a non-negative number"))
+	}
+
+	conversion, err := h.productDefinitionService.ConvertProductUnits(c.Request().Context(), productID, from, to, value)
+	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to delete product definition", zap.Int("definition_id", id), zap.Error(err))
+		utils.Logger(c).Error("Failed to convert product units", zap.Int("definition_id", productID), zap.String("from", from), zap.String("to", to), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
+	return c.JSON(http.StatusOK, conversion)
+}
+
+const defaultMaxProductImageBytes = 5 << 20 // 未設定 PRODUCT_IMAGE_MAX_BYTES 時的圖片大小上限
+
+// defaultProductImageTypes 未設定 PRODUCT_IMAGE_TYPES 時允許的圖片格式
+var defaultProductImageTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+// maxProductImageBytes 返回產品圖片的大小上限，由 PRODUCT_IMAGE_MAX_BYTES 設定
+func maxProductImageBytes() int64 {
+	if config.Cfg != nil && config.Cfg.ProductImageMaxBytes > 0 {
+		return config.Cfg.ProductImageMaxBytes
+	}
+	return defaultMaxProductImageBytes
+}
+
+// productImageTypes 返回允許的產品圖片格式，由 PRODUCT_IMAGE_TYPES 設定
+func productImageTypes() []string {
+	if config.Cfg != nil && len(config.Cfg.ProductImageTypes) > 0 {
+		return config.Cfg.ProductImageTypes
+	}
+	return defaultProductImageTypes
+}
 
+// UploadProductImage 上傳產品圖片 (multipart 欄位 "file")，已有圖片時取代並刪除舊檔案
+// 圖片格式以檔案內容判斷，不採信用戶端送出的 Content-Type；超過大小上限返回 413，不允許的格式返回 415
+func (h *ProductDefinitionHandler) UploadProductImage(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails(`missing multipart file field "file"`))
+	}
+	maxBytes := maxProductImageBytes()
+	tooLarge := utils.NewRequestTooLargeError("Image too large", maxBytes)
+	if fileHeader.Size > maxBytes {
+		return c.JSON(tooLarge.Code, tooLarge)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("failed to open uploaded file"))
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("failed to read uploaded file"))
+	}
+	if int64(len(data)) > maxBytes {
+		return c.JSON(tooLarge.Code, tooLarge)
+	}
+
+	contentType := strings.TrimSpace(strings.SplitN(http.DetectContentType(data), ";", 2)[0])
+	allowed := false
+	for _, t := range productImageTypes() {
+		if t == contentType {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		unsupported := utils.NewCustomError(http.StatusUnsupportedMediaType, "Unsupported image type",
+			map[string]interface{}{"content_type": contentType, "allowed_types": productImageTypes()})
+		return c.JSON(unsupported.Code, unsupported)
+	}
+
+	definition, err := h.productDefinitionService.SetProductImage(c.Request().Context(), productID, data, contentType, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to upload product image", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, definition)
+}
+
+// GetProductImage 串流返回產品圖片，沒有圖片時返回 404
+// 以圖片內容雜湊作為 ETag，支援 If-None-Match 返回 304；image_url 帶有版本參數，更換圖片後用戶端會取得新網址
+func (h *ProductDefinitionHandler) GetProductImage(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	image, content, err := h.productDefinitionService.GetProductImage(c.Request().Context(), productID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get product image", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	defer content.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderCacheControl, "private, max-age=86400") // 需要登入才能取得，只允許瀏覽器快取
+	header.Set("ETag", image.ETag)
+	header.Set(echo.HeaderLastModified, image.UpdatedAt.UTC().Format(http.TimeFormat))
+	if match := c.Request().Header.Get("If-None-Match"); match != "" && strings.Contains(match, image.ETag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	if image.Size >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(image.Size, 10))
+	}
+	return c.Stream(http.StatusOK, image.ContentType, content)
+}
+
+// DeleteProductImage 刪除產品圖片，沒有圖片時返回 404
+func (h *ProductDefinitionHandler) DeleteProductImage(c echo.Context) error {
+	productID, idErr := pathID(c, "id", h.productDefinitionService.ResolvePublicID) // 從 URL 參數獲取產品定義 ID
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	if err := h.productDefinitionService.DeleteProductImage(c.Request().Context(), productID, claims.AccountID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to delete product image", zap.Int("definition_id", productID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
 	return c.NoContent(http.StatusNoContent) // 成功刪除，返回 204 No Content
 }
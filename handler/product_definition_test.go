@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/service"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// stubProductDefinitionService 記錄列表的篩選條件；未覆寫的方法呼叫時 panic (嵌入的介面為 nil)
+type stubProductDefinitionService struct {
+	service.ProductDefinitionService
+	filter *models.ProductDefinitionFilter
+}
+
+func (s *stubProductDefinitionService) GetAllProductDefinitions(ctx context.Context, filter models.ProductDefinitionFilter, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	s.filter = &filter
+	return &models.PaginatedResponse{Data: []models.ProductDefinition{}}, nil
+}
+
+// TestGetProductDefinitionsPriceRange 價格範圍兩端都包含邊界：price_min 等於 price_max 是合法的範圍，
+// 與其他篩選條件一起傳給 Service (由 Repository 以 AND 組合，見 repository 的 TestBuildProductDefinitionWhereRanges)
+func TestGetProductDefinitionsPriceRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantMin    string
+		wantMax    string
+	}{
+		{name: "min equals max", query: "price_min=1.50&price_max=1.5", wantStatus: http.StatusOK, wantMin: "1.5", wantMax: "1.5"},
+		{name: "zero lower bound", query: "price_min=0", wantStatus: http.StatusOK, wantMin: "0"},
+		{name: "upper bound only", query: "price_max=12.345", wantStatus: http.StatusOK, wantMax: "12.345"},
+		{name: "min greater than max", query: "price_min=2.01&price_max=2", wantStatus: http.StatusBadRequest},
+		{name: "negative min", query: "price_min=-0.01", wantStatus: http.StatusBadRequest},
+		{name: "not a number", query: "price_max=cheap", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubProductDefinitionService{}
+			rec := serveProductDefinitions(t, stub, tt.query)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if stub.filter != nil {
+					t.Errorf("service called with an invalid price range")
+				}
+				return
+			}
+			checkPriceBound(t, "PriceMin", stub.filter.PriceMin, tt.wantMin)
+			checkPriceBound(t, "PriceMax", stub.filter.PriceMax, tt.wantMax)
+		})
+	}
+}
+
+// TestGetProductDefinitionsCombinedFilters 價格、單位、圖片與類別篩選同時指定時全部傳給 Service
+func TestGetProductDefinitionsCombinedFilters(t *testing.T) {
+	stub := &stubProductDefinitionService{}
+	rec := serveProductDefinitions(t, stub, "category_id=3&price_min=1&price_max=2&unit=PCS&has_image=false")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+	}
+	f := stub.filter
+	if f.CategoryID == nil || *f.CategoryID != 3 {
+		t.Errorf("CategoryID = %v, want 3", f.CategoryID)
+	}
+	checkPriceBound(t, "PriceMin", f.PriceMin, "1")
+	checkPriceBound(t, "PriceMax", f.PriceMax, "2")
+	if f.Unit != "PCS" {
+		t.Errorf("Unit = %q, want PCS", f.Unit)
+	}
+	if f.HasImage == nil || *f.HasImage {
+		t.Errorf("HasImage = %v, want false", f.HasImage)
+	}
+}
+
+// checkPriceBound 檢查價格範圍的一端：want 為空字串表示未指定 (nil)，否則以數值比較 (1.50 與 1.5 相同)
+func checkPriceBound(t *testing.T, name string, got *decimal.Decimal, want string) {
+	t.Helper()
+	switch {
+	case want == "" && got != nil:
+		t.Errorf("%s = %s, want unset", name, got)
+	case want != "" && got == nil:
+		t.Errorf("%s unset, want %s", name, want)
+	case want != "" && got.Cmp(decimal.MustParse(want)) != 0:
+		t.Errorf("%s = %s, want %s", name, got, want)
+	}
+}
+
+// serveProductDefinitions 以 query 呼叫 GET /product_definitions
+func serveProductDefinitions(t *testing.T, s service.ProductDefinitionService, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/product_definitions?"+query, nil)
+	rec := httptest.NewRecorder()
+	h := NewProductDefinitionHandler(s, nil)
+	if err := h.GetProductDefinitions(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("GetProductDefinitions returned error: %v", err)
+	}
+	return rec
+}
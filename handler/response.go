@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// apiV1Prefix Location 標頭使用的路徑前綴，與 routes.APIV1Prefix 相同 (handler 不能導入 routes)
+const apiV1Prefix = "/api/v1"
+
+// resourceLocation 返回資源的路徑，例如 /api/v1/customers/42
+func resourceLocation(resource string, id int) string {
+	return fmt.Sprintf("%s/%s/%d", apiV1Prefix, resource, id)
+}
+
+// respondCreated 設定指向新資源的 Location 標頭並以 201 返回 body
+// body 應為完整的記錄 (含資料庫產生的 ID、時間戳記與代碼)，呼叫前需清除密碼等敏感欄位
+func respondCreated(c echo.Context, resource string, id int, body interface{}) error {
+	c.Response().Header().Set(echo.HeaderLocation, resourceLocation(resource, id))
+	return c.JSON(http.StatusCreated, body)
+}
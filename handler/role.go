@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/middleware/etag"
+	"github.com/wac0705/fastener-api/middleware/jwt"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/service"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// RoleHandler 定義角色處理器結構，包含 RoleService 與 PermissionService (權限目錄) 的依賴
+type RoleHandler struct {
+	roleService       service.RoleService
+	permissionService service.PermissionService
+}
+
+// NewRoleHandler 創建 RoleHandler 實例
+func NewRoleHandler(s service.RoleService, permissionService service.PermissionService) *RoleHandler {
+	return &RoleHandler{roleService: s, permissionService: permissionService}
+}
+
+// GetPermissions 獲取權限目錄 (GET /permissions)，以 ETag 支援條件式 GET
+func (h *RoleHandler) GetPermissions(c echo.Context) error {
+	permissions, err := h.permissionService.GetAllPermissions(c.Request().Context())
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get all permissions", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return etag.JSON(c, http.StatusOK, permissions)
+}
+
+// GetRolePermissions 獲取角色擁有的權限 (GET /roles/:roleID/permissions)，以 ETag 支援條件式 GET
+func (h *RoleHandler) GetRolePermissions(c echo.Context) error {
+	roleID, idErr := pathID(c, "roleID", h.roleService.ResolvePublicID)
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	permissions, err := h.permissionService.GetRolePermissions(c.Request().Context(), roleID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to get permissions by role ID", zap.Int("role_id", roleID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return etag.JSON(c, http.StatusOK, permissions)
+}
+
+// CloneRole 以新名稱複製角色及其權限與選單 (POST /roles/:roleID/clone)
+func (h *RoleHandler) CloneRole(c echo.Context) error {
+	sourceID, idErr := pathID(c, "roleID", h.roleService.ResolvePublicID)
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	req := new(models.RoleCloneRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+	}
+
+	role, err := h.roleService.CloneRole(c.Request().Context(), sourceID, req.Name, claims.AccountID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to clone role", zap.Error(err), zap.Int("source_role_id", sourceID), zap.String("name", req.Name))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return respondCreated(c, "roles", role.ID, role)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/middleware/etag"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/service"
 	"github.com/wac0705/fastener-api/utils"
@@ -16,11 +17,13 @@ import (
 // RoleMenuHandler 定義角色選單處理器結構，包含 RoleMenuService 的依賴
 type RoleMenuHandler struct {
 	roleMenuService service.RoleMenuService
+	roleService     service.RoleService // 將路徑中角色與選單的 public_id 轉換為 ID
+	menuService     service.MenuService
 }
 
 // NewRoleMenuHandler 創建 RoleMenuHandler 實例
-func NewRoleMenuHandler(s service.RoleMenuService) *RoleMenuHandler {
-	return &RoleMenuHandler{roleMenuService: s}
+func NewRoleMenuHandler(s service.RoleMenuService, roleService service.RoleService, menuService service.MenuService) *RoleMenuHandler {
+	return &RoleMenuHandler{roleMenuService: s, roleService: roleService, menuService: menuService}
 }
 
 // CreateRoleMenu 創建新的角色選單關聯
@@ -28,18 +31,18 @@ func (h *RoleMenuHandler) CreateRoleMenu(c echo.Context) error {
 	roleMenu := new(models.RoleMenu)
 
 	if err := c.Bind(roleMenu); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 
 	if err := c.Validate(roleMenu); err != nil {
 		return err // 驗證錯誤
 	}
 
-	if err := h.roleMenuService.CreateRoleMenu(roleMenu); err != nil {
+	if err := h.roleMenuService.CreateRoleMenu(c.Request().Context(), roleMenu); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to create role menu", zap.Error(err), zap.Int("role_id", roleMenu.RoleID), zap.Int("menu_id", roleMenu.MenuID))
+		utils.Logger(c).Error("Failed to create role menu", zap.Error(err), zap.Int("role_id", roleMenu.RoleID), zap.Int("menu_id", roleMenu.MenuID))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -69,33 +72,33 @@ func (h *RoleMenuHandler) GetRoleMenus(c echo.Context) error {
 		menuID = &id
 	}
 
-	roleMenus, err := h.roleMenuService.GetAllRoleMenus(roleID, menuID)
+	roleMenus, err := h.roleMenuService.GetAllRoleMenus(c.Request().Context(), roleID, menuID)
 	if err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to get role menus", zap.Error(err))
+		utils.Logger(c).Error("Failed to get role menus", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
-	return c.JSON(http.StatusOK, roleMenus)
+	return etag.JSON(c, http.StatusOK, roleMenus)
 }
 
 // DeleteRoleMenu 刪除角色選單關聯
 func (h *RoleMenuHandler) DeleteRoleMenu(c echo.Context) error {
-	roleID, err := strconv.Atoi(c.Param("id1")) // 假設 URL 參數是 /role_menus/:role_id/:menu_id
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid role_id in path"))
+	roleID, idErr := pathID(c, "id1", h.roleService.ResolvePublicID) // 假設 URL 參數是 /role_menus/:role_id/:menu_id
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
-	menuID, err := strconv.Atoi(c.Param("id2")) // 假設 URL 參數是 /role_menus/:role_id/:menu_id
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid menu_id in path"))
+	menuID, idErr := pathID(c, "id2", h.menuService.ResolvePublicID) // 假設 URL 參數是 /role_menus/:role_id/:menu_id
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
-	if err := h.roleMenuService.DeleteRoleMenu(roleID, menuID); err != nil {
+	if err := h.roleMenuService.DeleteRoleMenu(c.Request().Context(), roleID, menuID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to delete role menu", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
+		utils.Logger(c).Error("Failed to delete role menu", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 
@@ -107,18 +110,18 @@ func (h *RoleMenuHandler) DeleteRoleMenu(c echo.Context) error {
 // 如果實際需求是修改關聯，通常是通過 delete + create 來實現。
 // 但為了提供一個範例，我們假設可以更新一個新的菜單 ID
 func (h *RoleMenuHandler) UpdateRoleMenu(c echo.Context) error {
-	oldRoleID, err := strconv.Atoi(c.Param("id1"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid old role_id in path"))
+	oldRoleID, idErr := pathID(c, "id1", h.roleService.ResolvePublicID)
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
-	oldMenuID, err := strconv.Atoi(c.Param("id2"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest.SetDetails("Invalid old menu_id in path"))
+	oldMenuID, idErr := pathID(c, "id2", h.menuService.ResolvePublicID)
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
 	}
 
 	req := new(models.RoleMenu) // 新的關聯數據，可能包含新的 menu_id 或 role_id
 	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, utils.ErrBadRequest)
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
 	}
 	if err := c.Validate(req); err != nil {
 		return err
@@ -127,14 +130,40 @@ func (h *RoleMenuHandler) UpdateRoleMenu(c echo.Context) error {
 	// 這裡假定更新是從 oldRoleID, oldMenuID 更改為 req.RoleID, req.MenuID
 	// 實際操作中，如果是更新複合主鍵，一般是先刪後插
 	// 這裡我們直接調用 Service 層的 Update 方法來處理邏輯
-	if err := h.roleMenuService.UpdateRoleMenu(oldRoleID, oldMenuID, req.RoleID, req.MenuID); err != nil {
+	if err := h.roleMenuService.UpdateRoleMenu(c.Request().Context(), oldRoleID, oldMenuID, req.RoleID, req.MenuID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok {
 			return c.JSON(customErr.Code, customErr)
 		}
-		zap.L().Error("Failed to update role menu", zap.Error(err),
+		utils.Logger(c).Error("Failed to update role menu", zap.Error(err),
 			zap.Int("old_role_id", oldRoleID), zap.Int("old_menu_id", oldMenuID),
 			zap.Int("new_role_id", req.RoleID), zap.Int("new_menu_id", req.MenuID))
 		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
 	}
 	return c.JSON(http.StatusOK, req)
 }
+
+// ReplaceRoleMenus 以請求中的 menu_ids 整組取代角色的選單 (PUT /roles/:roleID/menus)，返回取代後的選單
+func (h *RoleMenuHandler) ReplaceRoleMenus(c echo.Context) error {
+	roleID, idErr := pathID(c, "roleID", h.roleService.ResolvePublicID)
+	if idErr != nil {
+		return c.JSON(idErr.Code, idErr)
+	}
+
+	req := new(models.RoleMenusReplaceRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.BindError(err))
+	}
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	menus, err := h.roleMenuService.ReplaceRoleMenus(c.Request().Context(), roleID, req.MenuIDs)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return c.JSON(customErr.Code, customErr)
+		}
+		utils.Logger(c).Error("Failed to replace role menus", zap.Error(err), zap.Int("role_id", roleID))
+		return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	}
+	return c.JSON(http.StatusOK, menus)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/service"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// 以下 Service 在被呼叫時讓測試失敗：驗證失敗的請求不應到達 Service 層
+type (
+	unreachableAccountService struct {
+		service.AccountService
+		t *testing.T
+	}
+	unreachableAuthService struct {
+		service.AuthService
+		t *testing.T
+	}
+	unreachableCustomerService struct {
+		service.CustomerService
+		t *testing.T
+	}
+)
+
+func (s unreachableAccountService) CreateAccount(ctx context.Context, account *models.Account, actorID int) error {
+	s.t.Errorf("CreateAccount reached the service with %+v", account)
+	return errors.New("service reached")
+}
+
+func (s unreachableAuthService) Register(ctx context.Context, username, password string, roleID int) (*models.Account, error) {
+	s.t.Errorf("Register reached the service with username %q", username)
+	return nil, errors.New("service reached")
+}
+
+func (s unreachableCustomerService) CreateCustomer(ctx context.Context, customer *models.Customer, actorID int) error {
+	s.t.Errorf("CreateCustomer reached the service with %+v", customer)
+	return errors.New("service reached")
+}
+
+// TestInvalidPayloadsDoNotReachService 驗證失敗的請求由 Handler 返回驗證錯誤 (全局錯誤處理器轉為 400 與欄位明細)，不呼叫 Service
+func TestInvalidPayloadsDoNotReachService(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    func(t *testing.T) echo.HandlerFunc
+		path       string
+		body       string
+		wantFields map[string]string // 欄位的 JSON 路徑 -> 未通過的規則
+	}{
+		{
+			name:       "register with short username",
+			handler:    func(t *testing.T) echo.HandlerFunc { return NewAuthHandler(unreachableAuthService{t: t}).Register },
+			path:       "/api/v1/register",
+			body:       `{"username": "ab", "password": "Str0ng-Passw0rd", "role_id": 2}`,
+			wantFields: map[string]string{"username": "min"},
+		},
+		{
+			name:       "register with missing fields",
+			handler:    func(t *testing.T) echo.HandlerFunc { return NewAuthHandler(unreachableAuthService{t: t}).Register },
+			path:       "/api/v1/register",
+			body:       `{"username": "ab"}`,
+			wantFields: map[string]string{"username": "min", "password": "required", "role_id": "required"},
+		},
+		{
+			name: "create account with short username",
+			handler: func(t *testing.T) echo.HandlerFunc {
+				return NewAccountHandler(unreachableAccountService{t: t}).CreateAccount
+			},
+			path:       "/api/v1/accounts",
+			body:       `{"username": "x", "password": "Str0ng-Passw0rd", "role_id": 2}`,
+			wantFields: map[string]string{"username": "min"},
+		},
+		{
+			name: "create customer with bad email",
+			handler: func(t *testing.T) echo.HandlerFunc {
+				return NewCustomerHandler(unreachableCustomerService{t: t}, nil).CreateCustomer
+			},
+			path:       "/api/v1/customers",
+			body:       `{"name": "Acme Fasteners", "email": "not-an-email"}`,
+			wantFields: map[string]string{"email": "email"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newTestContext(t, http.MethodPost, tt.path, tt.body)
+			err := tt.handler(t)(c)
+
+			var validationErrors validator.ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				t.Fatalf("handler returned %v (status %d, body %s), want validator.ValidationErrors", err, rec.Code, rec.Body)
+			}
+			if c.Response().Committed {
+				t.Errorf("handler wrote a %d response instead of leaving the validation error to the error handler", rec.Code)
+			}
+
+			// 與全局錯誤處理器相同的方式組成回應內容
+			customErr := utils.NewValidationError(c.Echo().Validator.(*utils.CustomValidator).Translate(validationErrors, ""))
+			if customErr.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", customErr.Code, http.StatusBadRequest)
+			}
+			got := map[string]string{}
+			for _, detail := range customErr.Details.([]utils.ValidationErrorDetail) {
+				got[detail.Field] = detail.Rule
+				if detail.Message == "" {
+					t.Errorf("field %s has no message", detail.Field)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("field details = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics" // 指標註冊介面
+)
+
+// jobDurationBuckets 工作執行時間 (秒) 的直方圖區間，清理類工作可能需要數分鐘
+var jobDurationBuckets = []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// Job 定期在背景執行的工作
+type Job struct {
+	Name     string
+	Interval time.Duration // 上次執行結束到下次執行開始的間隔
+	Jitter   time.Duration // 每次等待額外加上 0 到 Jitter 的隨機時間，避免多個實例同時執行
+	// Run 執行一次工作；Runner.Stop 時 ctx 被取消，Run 應盡快返回
+	Run func(ctx context.Context) error
+}
+
+// Status 工作的排程與最近一次執行的結果 (GET /api/v1/admin/jobs)
+type Status struct {
+	Name           string     `json:"name"`
+	Interval       string     `json:"interval"`
+	Running        bool       `json:"running"`
+	Runs           uint64     `json:"runs"`     // 已完成的執行次數 (含失敗)
+	Failures       uint64     `json:"failures"` // 返回錯誤或 panic 的次數
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"` // 最近一次執行的錯誤，成功時為空
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+}
+
+// Runner 定義背景工作的排程器：每個工作在各自的 goroutine 中依 Interval 重複執行，同一工作不會同時執行兩次
+type Runner interface {
+	Register(job Job)               // 在 Start 前註冊；名稱重複、Interval 不為正數或 Run 為 nil 時 panic
+	Start()                         // 開始排程已註冊的工作，第一次執行在一個 Interval (加上 Jitter) 之後
+	Stop(ctx context.Context) error // 停止排程、取消執行中工作的 ctx 並等待其返回；ctx 先結束時返回 ctx.Err()
+	Statuses() []Status             // 所有工作的狀態，依名稱排序
+}
+
+// scheduledJob 已註冊的工作與其執行狀態
+type scheduledJob struct {
+	Job
+	mu     sync.Mutex
+	status Status
+}
+
+// runner 實現 Runner 介面
+type runner struct {
+	mu       sync.Mutex
+	jobs     map[string]*scheduledJob
+	started  bool
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	runs     metrics.Counter
+	duration metrics.Histogram
+}
+
+// NewRunner 創建 Runner 實例，並在 reg 註冊工作執行次數與時間的指標
+func NewRunner(reg metrics.Registry) Runner {
+	return &runner{
+		jobs:     map[string]*scheduledJob{},
+		runs:     reg.Counter("job_runs_total", "Total background job runs by job and result.", "job", "result"),
+		duration: reg.Histogram("job_duration_seconds", "Background job run duration in seconds.", jobDurationBuckets, "job"),
+	}
+}
+
+// Register 註冊工作
+func (r *runner) Register(job Job) {
+	if job.Name == "" || job.Interval <= 0 || job.Run == nil {
+		panic(fmt.Sprintf("jobs: invalid job %q: name, positive interval and run function are required", job.Name))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		panic(fmt.Sprintf("jobs: cannot register %q after Start", job.Name))
+	}
+	if _, exists := r.jobs[job.Name]; exists {
+		panic(fmt.Sprintf("jobs: %q already registered", job.Name))
+	}
+	r.jobs[job.Name] = &scheduledJob{Job: job, status: Status{Name: job.Name, Interval: job.Interval.String()}}
+}
+
+// Start 為每個工作啟動排程 goroutine，重複呼叫時不做任何事
+func (r *runner) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		go r.schedule(ctx, job)
+	}
+	zap.L().Info("Background jobs started", zap.Int("jobs", len(r.jobs)))
+}
+
+// Stop 取消所有工作並等待執行中的工作返回
+func (r *runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.mu.Unlock()
+	if cancel == nil {
+		return nil // 尚未 Start
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		zap.L().Info("Background jobs stopped")
+		return nil
+	case <-ctx.Done():
+		zap.L().Warn("Timed out waiting for background jobs to stop", zap.Error(ctx.Err()))
+		return ctx.Err()
+	}
+}
+
+// Statuses 返回所有工作的狀態
+func (r *runner) Statuses() []Status {
+	r.mu.Lock()
+	jobs := make([]*scheduledJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		jobs = append(jobs, job)
+	}
+	r.mu.Unlock()
+
+	statuses := make([]Status, 0, len(jobs))
+	for _, job := range jobs {
+		job.mu.Lock()
+		statuses = append(statuses, job.status)
+		job.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// schedule 依 Interval 與 Jitter 重複執行 job，直到 ctx 取消
+func (r *runner) schedule(ctx context.Context, job *scheduledJob) {
+	defer r.wg.Done()
+	for {
+		wait := job.Interval
+		if job.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(job.Jitter) + 1))
+		}
+		next := time.Now().Add(wait).UTC() // API 中的時間一律為 UTC
+		job.mu.Lock()
+		job.status.NextRunAt = &next
+		job.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			job.mu.Lock()
+			job.status.NextRunAt = nil // 已停止，不會再執行
+			job.mu.Unlock()
+			return
+		case <-timer.C:
+		}
+		r.run(ctx, job)
+	}
+}
+
+// run 執行一次 job 並記錄結果；panic 視為失敗，不影響其他工作
+func (r *runner) run(ctx context.Context, job *scheduledJob) {
+	started := time.Now()
+	startedAt := started.UTC() // 顯示用；started 保留單調時鐘計算耗時
+	job.mu.Lock()
+	job.status.Running = true
+	job.status.LastStartedAt = &startedAt
+	job.status.NextRunAt = nil
+	job.mu.Unlock()
+
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		return job.Run(ctx)
+	}()
+	elapsed := time.Since(started)
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+		zap.L().Error("Background job failed", zap.String("job", job.Name), zap.Duration("duration", elapsed), zap.Error(err))
+	} else {
+		zap.L().Debug("Background job finished", zap.String("job", job.Name), zap.Duration("duration", elapsed))
+	}
+	r.runs.Inc(job.Name, result)
+	r.duration.Observe(elapsed.Seconds(), job.Name)
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.status.Running = false
+	job.status.Runs++
+	job.status.LastDurationMs = elapsed.Milliseconds()
+	job.status.LastError = ""
+	if err != nil {
+		job.status.Failures++
+		job.status.LastError = err.Error()
+	}
+}
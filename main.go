@@ -1,31 +1,32 @@
 package main
 
 import (
-	"errors" // 用於錯誤類型斷言
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"time" // 用於 CORS MaxAge
+	"os/signal" // 收到 SIGINT/SIGTERM 時優雅關閉
+	"syscall"
+	"time" // 用於 HTTP 轉址監聽器的逾時
+	_ "time/tzdata"                      // 內嵌時區資料，DISPLAY_TIMEZONE 在沒有安裝 tzdata 的 alpine 映像中也能解析
 
-	"github.com/go-playground/validator/v10" // 驗證器
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"           // 結構化日誌庫
 	"go.uber.org/zap/zapcore"    // zap 的核心組件
+	"golang.org/x/crypto/acme/autocert" // Let's Encrypt 自動申請憑證
 
 	"github.com/wac0705/fastener-api/config"        // 應用程式配置
 	"github.com/wac0705/fastener-api/db"            // 資料庫初始化
-	"github.com/wac0705/fastener-api/handler"       // 處理器
-	"github.com/wac0705/fastener-api/middleware/authz" // 授權中介軟體
-	"github.com/wac0705/fastener-api/middleware/jwt" // JWT 中介軟體
-	"github.com/wac0705/fastener-api/repository"    // Repository 層
-	"github.com/wac0705/fastener-api/routes"        // 路由定義
-	"github.com/wac0705/fastener-api/service"       // Service 層
-	"github.com/wac0705/fastener-api/utils"         // 工具函式 (包含自定義錯誤)
+	"github.com/wac0705/fastener-api/server"        // API 伺服器的組裝
 )
 
 var logger *zap.Logger // 全局日誌器
 
+// version 建置版本，由 go build -ldflags "-X main.version=<版本>" 設定，顯示在 /healthz
+var version = "dev"
+
 // init 函數會在 main 函數之前執行，用於初始化日誌器
 func init() {
 	var cfg zap.Config
@@ -58,10 +59,13 @@ func init() {
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
 	}
-	// zap.ReplaceGlobals(logger) // 設定為全局 Zap logger，以便其他包直接使用 zap.L() 或 zap.S()
+	zap.ReplaceGlobals(logger) // 設定為全局 Zap logger：service 與 repository 使用注入的 logger (見 server.New)，資料庫連線與背景工作等仍以 zap.L() 記錄日誌
 }
 
 func main() {
+	checkConfig := flag.Bool("check-config", false, "validate the configuration, report every problem and exit (non-zero when invalid)")
+	flag.Parse()
+
 	defer func() {
 		// 確保所有緩衝日誌都被寫入。對於某些輸出（如 /dev/stderr），sync 可能會返回錯誤，需要忽略。
 		if err := logger.Sync(); err != nil && err.Error() != "sync /dev/stderr: invalid argument" {
@@ -69,160 +73,115 @@ func main() {
 		}
 	}()
 
-	// 載入應用程式配置
-	config.LoadConfig()
+	// 載入應用程式配置：所有有問題的環境變數一次列出 (見 config.ValidationError)
+	if err := config.LoadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *checkConfig {
+		fmt.Println("Configuration OK")
+		return
+	}
 
-	// 初始化資料庫
-	db.InitDB(config.Cfg.DatabaseURL)
+	// 初始化資料庫 (設定 DATABASE_READ_URL 時另外打開唯讀副本)：此處只打開連接，連線測試 (含重試) 在伺服器開始監聽後進行，等待期間 /readyz 的 database 為 connecting
+	db.Open(config.Cfg.DatabaseURL, config.Cfg.DatabaseReadURL, config.Cfg.DBPoolConfig())
 	defer func() {
-		sqlDB, err := db.DB.DB()
-		if err != nil {
-			logger.Error("Error getting underlying SQL DB", zap.Error(err))
-		} else if sqlDB != nil {
-			if err := sqlDB.Close(); err != nil {
-				logger.Error("Error closing database", zap.Error(err))
-			}
+		if err := db.DB.Close(); err != nil {
+			logger.Error("Error closing database", zap.Error(err))
 		}
-	}()
-
-	e := echo.New() // 創建 Echo 實例
-
-	// 設定自定義錯誤處理器
-	e.HTTPErrorHandler = func(err error, c echo.Context) {
-		var he *echo.HTTPError
-		if errors.As(err, &he) { // 如果是 Echo 內部錯誤
-			// 如果內部錯誤是我們自定義的錯誤，則直接使用
-			if he.Internal != nil {
-				if customErr, ok := he.Internal.(*utils.CustomError); ok {
-					c.JSON(customErr.Code, customErr)
-					return
-				}
+		if db.ReadDB != nil {
+			if err := db.ReadDB.Close(); err != nil {
+				logger.Error("Error closing read replica", zap.Error(err))
 			}
-			// 否則，將 Echo HTTP 錯誤轉換為自定義錯誤格式
-			c.JSON(he.Code, &utils.CustomError{Code: he.Code, Message: he.Message.(string)})
-			return
-		}
-
-		// 如果錯誤是我們自定義的錯誤
-		if customErr, ok := err.(*utils.CustomError); ok {
-			c.JSON(customErr.Code, customErr)
-			return
-		}
-
-		// 如果是驗證錯誤 (來自 go-playground/validator)
-		if validationErrors, ok := err.(validator.ValidationErrors); ok {
-			details := make(map[string]string)
-			for _, fieldErr := range validationErrors {
-				details[fieldErr.Field()] = fieldErr.Tag() // 簡化處理，實際應用中可轉換為更友好的訊息
-			}
-			customErr := utils.NewValidationError(details)
-			c.JSON(customErr.Code, customErr)
-			return
 		}
+	}()
 
-		// 其他未處理的錯誤，記錄到日誌並返回通用的內部伺服器錯誤
-		logger.Error("Unhandled internal server error", zap.Error(err),
-			zap.String("path", c.Path()),
-			zap.String("method", c.Request().Method),
-			zap.Any("error_type", fmt.Sprintf("%T", err)), // 記錄錯誤類型
-		)
-		c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+	// 組裝中介軟體、依賴注入與路由 (見 server.New)
+	srv, err := server.New(config.Cfg, db.Writer(), db.ReadDB, version, logger)
+	if err != nil {
+		logger.Fatal("Failed to set up server", zap.Error(err))
 	}
+	e := srv.Echo
 
-	// Echo 全局中介軟體
-	e.Use(middleware.Recover()) // 錯誤恢復
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{ // CORS 設定
-		AllowOrigins:     []string{config.Cfg.CorsAllowOrigin},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
-		AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch},
-		AllowCredentials: true,
-		MaxAge:           int(12 * time.Hour / time.Second), // CORS 預檢請求緩存時間
-	}))
-
-	// 設定 RequestLogger 以使用 zap
-	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
-		LogURI:      true,
-		LogStatus:   true,
-		LogLatency:  true,
-		LogRemoteIP: true,
-		LogMethod:   true,
-		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
-			logger.Info("request",
-				zap.String("method", v.Method),
-				zap.String("uri", v.URI),
-				zap.Int("status", v.Status),
-				zap.Duration("latency", v.Latency),
-				zap.String("remote_ip", v.RemoteIP),
-				// 可以在這裡加入更多上下文，例如如果已經經過 JWT 驗證，可以加入用戶 ID
-			)
-			return nil
-		},
-	}))
-
-	// 設定 Echo 的日誌輸出到 Zap
-	e.Logger.SetOutput(zap.NewStdLog(logger).Writer())
-	e.Logger.SetLevel(echo.Lvl(config.Cfg.LogLevel)) // 設定 Echo 日誌級別
-
-	// 將 JWT 驗證器實例綁定到 Echo 上下文 (用於處理器內部手動驗證，如果需要)
-	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			c.Set("jwtVerifier", jwt.NewJwtVerifier(config.Cfg.JwtSecret))
-			return next(c)
+	// 啟動程序：在背景等待資料庫可連線並預載入權限緩存，完成前 /readyz 返回 503 (伺服器先開始監聽，/livez 可立即回應)
+	go func() {
+		if err := srv.Startup(context.Background()); err != nil {
+			logger.Fatal("Server startup failed, giving up", zap.Error(err))
 		}
-	})
+	}()
 
-	// 設置靜態檔案伺服 (如果需要，可創建 public 目錄)
-	// e.Static("/", "public")
-
-	// --- 依賴注入和服務啟動 ---
-	// 實例化 Repository 層
-	accountRepo := repository.NewAccountRepository(db.DB)
-	companyRepo := repository.NewCompanyRepository(db.DB)
-	customerRepo := repository.NewCustomerRepository(db.DB)
-	menuRepo := repository.NewMenuRepository(db.DB)
-	productDefinitionRepo := repository.NewProductDefinitionRepository(db.DB)
-	roleRepo := repository.NewRoleRepository(db.DB)             // 新增 Role Repository
-	roleMenuRepo := repository.NewRoleMenuRepository(db.DB)     // 新增 RoleMenu Repository
-	permissionRepo := repository.NewPermissionRepository(db.DB) // 新增 Permission Repository
-
-	// 實例化 Service 層，並注入 Repository 依賴
-	accountService := service.NewAccountService(accountRepo, roleRepo) // AccountService 依賴 AccountRepo 和 RoleRepo
-	authService := service.NewAuthService(accountRepo, roleRepo, config.Cfg.JwtSecret, config.Cfg.JwtAccessExpiresHours, config.Cfg.JwtRefreshExpiresHours) // AuthService 依賴 AccountRepo, RoleRepo, JWT配置
-	companyService := service.NewCompanyService(companyRepo)
-	customerService := service.NewCustomerService(customerRepo)
-	menuService := service.NewMenuService(menuRepo)
-	productDefinitionService := service.NewProductDefinitionService(productDefinitionRepo)
-	roleService := service.NewRoleService(roleRepo)             // 新增 RoleService
-	roleMenuService := service.NewRoleMenuService(roleMenuRepo) // 新增 RoleMenuService
-	permissionService := service.NewPermissionService(permissionRepo, roleRepo) // 新增 PermissionService 依賴 PermissionRepo 和 RoleRepo
-
-	// 實例化 Handler 層，並注入 Service 依賴
-	accountHandler := handler.NewAccountHandler(accountService)
-	authHandler := handler.NewAuthHandler(authService)
-	companyHandler := handler.NewCompanyHandler(companyService)
-	customerHandler := handler.NewCustomerHandler(customerService)
-	menuHandler := handler.NewMenuHandler(menuService)
-	productDefinitionHandler := handler.NewProductDefinitionHandler(productDefinitionService)
-	roleMenuHandler := handler.NewRoleMenuHandler(roleMenuService)
-
-	// --- API 路由定義 ---
-	// 使用 routes 包來集中定義所有路由
-	routes.RegisterAPIRoutes(e,
-		authHandler,
-		accountHandler,
-		companyHandler,
-		customerHandler,
-		menuHandler,
-		productDefinitionHandler,
-		roleMenuHandler,
-		permissionService, // 將權限服務傳入以便在路由中介軟體中使用
-		config.Cfg.JwtSecret, // JWT Secret 也傳入
-	)
+	// 收到 SIGINT 或 SIGTERM 時優雅關閉：等待進行中的請求與背景工作結束 (SHUTDOWN_TIMEOUT)，之後關閉資料庫
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		sig := <-stop
+		logger.Info("Shutting down server", zap.String("signal", sig.String()), zap.Duration("timeout", config.Cfg.ShutdownTimeout))
+		ctx, cancel := context.WithTimeout(context.Background(), config.Cfg.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("Graceful shutdown did not complete", zap.Error(err))
+		}
+	}()
 
 	// 啟動伺服器
 	port := config.Cfg.Port
 	if port == "" {
 		port = "8080" // 預設端口
 	}
-	logger.Fatal("Server failed to start", zap.Error(e.Start(":"+port))) // 使用 zap 記錄 Fatal 錯誤
+	address := ":" + port
+	switch {
+	case config.Cfg.TLSCertFile != "":
+		// 以自備的憑證提供 HTTPS (檔案已在 LoadConfig 驗證)
+		startHTTPRedirect(config.Cfg.HTTPRedirectPort, port, nil)
+		logger.Info("Serving HTTPS with certificate file", zap.String("address", address), zap.String("cert_file", config.Cfg.TLSCertFile))
+		err = e.StartTLS(address, config.Cfg.TLSCertFile, config.Cfg.TLSKeyFile)
+	case len(config.Cfg.TLSAutocertDomains) > 0:
+		// 以 Let's Encrypt 自動申請與更新憑證 (TLS-ALPN-01；轉址監聽器同時處理 HTTP-01 驗證)
+		e.AutoTLSManager.HostPolicy = autocert.HostWhitelist(config.Cfg.TLSAutocertDomains...)
+		e.AutoTLSManager.Cache = autocert.DirCache(config.Cfg.TLSAutocertCacheDir)
+		e.AutoTLSManager.Email = config.Cfg.TLSAutocertEmail
+		startHTTPRedirect(config.Cfg.HTTPRedirectPort, port, e.AutoTLSManager.HTTPHandler)
+		logger.Info("Serving HTTPS with Let's Encrypt certificates", zap.String("address", address), zap.Strings("domains", config.Cfg.TLSAutocertDomains))
+		err = e.StartAutoTLS(address)
+	default:
+		// 預設為 HTTP，TLS 由前端的反向代理或負載平衡器處理
+		err = e.Start(address)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal("Server failed to start", zap.Error(err)) // 使用 zap 記錄 Fatal 錯誤
+	}
+	<-shutdownDone // Shutdown 返回後才執行關閉資料庫與同步日誌的 defer
+	logger.Info("Server stopped")
+}
+
+// startHTTPRedirect 在 redirectPort 監聽 HTTP，將請求以 308 轉址到 tlsPort 的 HTTPS；redirectPort 為空白時不監聽
+// wrap 不為 nil 時包裝轉址處理器 (autocert.Manager.HTTPHandler，處理 Let's Encrypt 的 HTTP-01 驗證請求)
+func startHTTPRedirect(redirectPort, tlsPort string, wrap func(http.Handler) http.Handler) {
+	if redirectPort == "" {
+		return
+	}
+	var redirect http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+	if wrap != nil {
+		redirect = wrap(redirect)
+	}
+	server := &http.Server{
+		Addr:              ":" + redirectPort,
+		Handler:           redirect,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logger.Info("Redirecting HTTP to HTTPS", zap.String("address", server.Addr))
+		logger.Fatal("HTTP redirect listener failed", zap.Error(server.ListenAndServe()))
+	}()
 }
@@ -0,0 +1,300 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets HTTP 請求延遲 (秒) 的預設直方圖區間
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Counter 只增不減的計數器，labelValues 需與註冊時的 labelNames 數量相同
+type Counter interface {
+	Inc(labelValues ...string)
+	Add(v float64, labelValues ...string)
+}
+
+// Histogram 依區間累計觀測值的直方圖 (例如請求延遲)
+type Histogram interface {
+	Observe(v float64, labelValues ...string)
+}
+
+// Registry 指標註冊與輸出介面，handler 與 service 透過它新增指標，不直接依賴 Prometheus 函式庫
+// 以相同名稱與類型重複註冊時返回既有的指標；名稱相同但類型或標籤不同時 panic
+type Registry interface {
+	Counter(name, help string, labelNames ...string) Counter
+	Histogram(name, help string, buckets []float64, labelNames ...string) Histogram
+	GaugeFunc(name, help string, fn func() float64)   // 每次輸出時呼叫 fn 取得目前的值
+	CounterFunc(name, help string, fn func() float64) // 同 GaugeFunc，但 fn 返回只增不減的累計值
+	// WriteText 以 Prometheus 文字格式 (version 0.0.4) 輸出所有指標，依名稱與標籤排序
+	WriteText(w io.Writer) error
+}
+
+// TextContentType Prometheus 文字格式的 Content-Type
+const TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metric 已註冊的指標
+type metric interface {
+	kind() string // counter、gauge 或 histogram
+	labelNames() []string
+	write(w io.Writer, name string) error
+}
+
+// registry 實現 Registry 介面
+type registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+	help    map[string]string
+}
+
+// NewRegistry 創建 Registry 實例
+func NewRegistry() Registry {
+	return &registry{metrics: map[string]metric{}, help: map[string]string{}}
+}
+
+// register 註冊指標，已存在相同名稱時返回既有的指標
+func (r *registry) register(name, help string, m metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.metrics[name]; ok {
+		if existing.kind() != m.kind() || strings.Join(existing.labelNames(), ",") != strings.Join(m.labelNames(), ",") {
+			panic(fmt.Sprintf("metrics: %s already registered as %s with labels %v", name, existing.kind(), existing.labelNames()))
+		}
+		return existing
+	}
+	r.metrics[name] = m
+	r.help[name] = help
+	return m
+}
+
+// Counter 註冊或取得計數器
+func (r *registry) Counter(name, help string, labelNames ...string) Counter {
+	return r.register(name, help, &counter{labels: labelNames, values: map[string]*series{}}).(*counter)
+}
+
+// Histogram 註冊或取得直方圖，buckets 為各區間的上限 (遞增)
+func (r *registry) Histogram(name, help string, buckets []float64, labelNames ...string) Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return r.register(name, help, &histogram{labels: labelNames, buckets: sorted, values: map[string]*histogramSeries{}}).(*histogram)
+}
+
+// GaugeFunc 註冊以函式取值的量測值
+func (r *registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, &valueFunc{typ: "gauge", fn: fn})
+}
+
+// CounterFunc 註冊以函式取值的累計值
+func (r *registry) CounterFunc(name, help string, fn func() float64) {
+	r.register(name, help, &valueFunc{typ: "counter", fn: fn})
+}
+
+// WriteText 輸出所有指標
+func (r *registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make(map[string]metric, len(r.metrics))
+	help := make(map[string]string, len(r.help))
+	for name, m := range r.metrics {
+		metrics[name] = m
+		help[name] = r.help[name]
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := metrics[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help[name]), name, m.kind()); err != nil {
+			return err
+		}
+		if err := m.write(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// series 一組標籤值的計數
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// counter 實現 Counter 介面
+type counter struct {
+	mu     sync.Mutex
+	labels []string
+	values map[string]*series // 以標籤值組合為 key
+}
+
+func (c *counter) kind() string         { return "counter" }
+func (c *counter) labelNames() []string { return c.labels }
+
+// Inc 計數加 1
+func (c *counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 計數加 v (v 必須 >= 0)
+func (c *counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	key := seriesKey(c.labels, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+func (c *counter) write(w io.Writer, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(c.labels, s.labelValues, "", ""), formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// histogramSeries 一組標籤值的直方圖資料
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // 各區間 (不累計) 的數量，最後一個為 +Inf
+	sum         float64
+	count       uint64
+}
+
+// histogram 實現 Histogram 介面
+type histogram struct {
+	mu      sync.Mutex
+	labels  []string
+	buckets []float64
+	values  map[string]*histogramSeries
+}
+
+func (h *histogram) kind() string         { return "histogram" }
+func (h *histogram) labelNames() []string { return h.labels }
+
+// Observe 記錄一個觀測值
+func (h *histogram) Observe(v float64, labelValues ...string) {
+	key := seriesKey(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets)+1)}
+		h.values[key] = s
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, v)]++ // 第一個 >= v 的區間 (區間上限包含邊界)
+	s.sum += v
+	s.count++
+}
+
+func (h *histogram) write(w io.Writer, name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatValue(h.buckets[i])
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(h.labels, s.labelValues, "le", le), cumulative); err != nil {
+				return err
+			}
+		}
+		labels := formatLabels(h.labels, s.labelValues, "", "")
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", name, labels, formatValue(s.sum), name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueFunc 以函式取值的 gauge 或 counter (沒有標籤)
+type valueFunc struct {
+	typ string
+	fn  func() float64
+}
+
+func (f *valueFunc) kind() string         { return f.typ }
+func (f *valueFunc) labelNames() []string { return nil }
+
+func (f *valueFunc) write(w io.Writer, name string) error {
+	_, err := fmt.Fprintf(w, "%s %s\n", name, formatValue(f.fn()))
+	return err
+}
+
+// seriesKey 以標籤值組合產生 map key，標籤數量不符時 panic (屬於程式錯誤)
+func seriesKey(labelNames, labelValues []string) string {
+	if len(labelNames) != len(labelValues) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// sortedKeys 返回排序後的 key，讓輸出順序固定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels 輸出 {name="value",...}；extraName 不為空時附加在最後 (直方圖的 le)
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue 以 Prometheus 格式輸出數值 (+Inf、-Inf、NaN)
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabelValue 跳脫標籤值中的反斜線、雙引號與換行
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// escapeHelp 跳脫說明文字中的反斜線與換行
+func escapeHelp(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/middleware/jwt" // AccessClaims
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// MaxBodyBytes 記錄請求內容的大小上限，超過時不記錄內容 (其餘欄位照常記錄)
+const MaxBodyBytes = 16 << 10
+
+// redactedValue 取代敏感欄位值的字串
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactedFields RecordBody 一律遮蔽的欄位 (不區分大小寫，包含巢狀物件中的欄位)
+var DefaultRedactedFields = []string{"password", "old_password", "new_password", "token", "access_token", "refresh_token", "secret"}
+
+// bodyContextKey RecordBody 存放已遮蔽的請求內容的 Echo Context key
+const bodyContextKey = "audit_body"
+
+// Recorder 接收稽核記錄 (service.AuditService)，Record 不可阻塞請求
+type Recorder interface {
+	Record(entry models.AuditLog)
+}
+
+// Middleware 在寫入操作 (POST、PUT、PATCH、DELETE) 結束後產生稽核記錄交給 recorder，記錄帳戶、路由、實體 ID 與回應狀態碼
+// 需放在將 AccessClaims 存入 "claims" 的中介軟體之後；handler 返回的錯誤會在這裡交給全局錯誤處理器，才能記錄實際的狀態碼
+// 預設不記錄請求內容，要記錄的路由另外加上 RecordBody
+func Middleware(recorder Recorder) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			switch req.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				return next(c)
+			}
+
+			start := time.Now()
+			if err := next(c); err != nil {
+				c.Error(err) // 寫入錯誤回應後 Response().Status 才是實際的狀態碼
+			}
+
+			entry := models.AuditLog{
+				RequestID: utils.RequestID(c),
+				Method:    req.Method,
+				Route:     c.Path(),
+				Path:      req.URL.Path,
+				EntityID:  entityID(c),
+				Status:    c.Response().Status,
+				LatencyMs: int(time.Since(start).Milliseconds()),
+				RemoteIP:  c.RealIP(),
+				CreatedAt: start,
+			}
+			if claims, ok := c.Get("claims").(*jwt.AccessClaims); ok && claims != nil {
+				actorID := claims.AccountID
+				entry.ActorID = &actorID
+			}
+			if body, ok := c.Get(bodyContextKey).(json.RawMessage); ok {
+				entry.Body = body
+			}
+			recorder.Record(entry)
+			return nil
+		}
+	}
+}
+
+// entityID 返回路由中最後一個路徑參數的值 (例如 /customers/:id/addresses/:address_id 的地址 ID)，沒有路徑參數時返回空字串
+func entityID(c echo.Context) string {
+	values := c.ParamValues()
+	for i := len(c.ParamNames()) - 1; i >= 0; i-- {
+		if i < len(values) && values[i] != "" {
+			return values[i]
+		}
+	}
+	return ""
+}
+
+// RecordBody 讓個別路由的稽核記錄包含請求內容，DefaultRedactedFields 與 redact 列出的欄位值以 [REDACTED] 取代
+// 只記錄不超過 MaxBodyBytes 的 JSON 內容；讀取後還原請求內容，handler 仍可正常 Bind
+func RecordBody(redact ...string) echo.MiddlewareFunc {
+	redacted := map[string]bool{}
+	for _, field := range append(append([]string{}, DefaultRedactedFields...), redact...) {
+		redacted[strings.ToLower(field)] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Body == nil || !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+				return next(c)
+			}
+			raw, err := io.ReadAll(io.LimitReader(req.Body, MaxBodyBytes+1))
+			req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw), req.Body))
+			if err == nil && len(raw) <= MaxBodyBytes {
+				if body, ok := redactJSON(raw, redacted); ok {
+					c.Set(bodyContextKey, body)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// redactJSON 解析 JSON 並遮蔽 redacted 中的欄位，不是有效的 JSON 時返回 false
+func redactJSON(raw []byte, redacted map[string]bool) (json.RawMessage, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber() // 保留數字原本的精度 (例如價格)
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	body, err := json.Marshal(redactValue(value, redacted))
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// redactValue 遞迴遮蔽物件 (含陣列中的物件) 中名稱符合 redacted 的欄位
+func redactValue(value interface{}, redacted map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if redacted[strings.ToLower(key)] {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactValue(item, redacted)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item, redacted)
+		}
+	}
+	return value
+}
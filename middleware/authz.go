@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"net/http"
+	"sort"
+	"sync"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -11,16 +13,45 @@ import (
 	"github.com/wac0705/fastener-api/utils"         // 導入自定義錯誤
 )
 
+// registeredPermissions 註冊路由時登記的權限字串 (見 RegisterPermissions)
+var (
+	registeredMu          sync.Mutex
+	registeredPermissions = map[string]struct{}{}
+)
+
+// RegisterPermissions 登記 API 使用到的權限字串，供 cmd/seed 建立權限資料
+// Authorize 與 AuthorizeAny 會自動登記，只在 Handler 內以 HasPermission 檢查的權限需在註冊路由時另行登記
+func RegisterPermissions(permissions ...string) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	for _, permission := range permissions {
+		registeredPermissions[permission] = struct{}{}
+	}
+}
+
+// RegisteredPermissions 返回已登記的權限字串 (依名稱排序)
+func RegisteredPermissions() []string {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	permissions := make([]string, 0, len(registeredPermissions))
+	for permission := range registeredPermissions {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
 // Authorize 授權中介軟體，根據用戶角色檢查是否具備指定權限
 // permission 參數是這個 API 端點所需的權限字串，例如 "company:read"
 func Authorize(permission string, permissionService service.PermissionService) echo.MiddlewareFunc {
+	RegisterPermissions(permission)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// 從上下文中獲取 JWT claims (假設 JWT 中介軟體已將 claims 設置為 "claims")
 			claims, ok := c.Get("claims").(*jwt.AccessClaims)
 			if !ok || claims == nil {
 				// 這通常表示 JWT 中介軟體沒有正確執行，或者 Token 解析失敗
-				zap.L().Warn("Authorization failed: JWT claims not found or invalid in context",
+				utils.Logger(c).Warn("Authorization failed: JWT claims not found or invalid in context",
 					zap.String("path", c.Path()), zap.String("method", c.Request().Method))
 				return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
 			}
@@ -32,9 +63,9 @@ func Authorize(permission string, permissionService service.PermissionService) e
 			}
 
 			// 檢查用戶角色是否具備所需權限
-			hasPermission, err := permissionService.HasPermission(claims.RoleID, permission)
+			hasPermission, err := permissionService.HasPermission(c.Request().Context(), claims.RoleID, permission)
 			if err != nil {
-				zap.L().Error("Error checking permission for user",
+				utils.Logger(c).Error("Error checking permission for user",
 					zap.Int("account_id", claims.AccountID),
 					zap.Int("role_id", claims.RoleID),
 					zap.String("required_permission", permission),
@@ -44,7 +75,7 @@ func Authorize(permission string, permissionService service.PermissionService) e
 			}
 
 			if !hasPermission {
-				zap.L().Warn("User forbidden from accessing resource due to insufficient permissions",
+				utils.Logger(c).Warn("User forbidden from accessing resource due to insufficient permissions",
 					zap.Int("account_id", claims.AccountID),
 					zap.Int("role_id", claims.RoleID),
 					zap.String("required_permission", permission),
@@ -56,3 +87,55 @@ func Authorize(permission string, permissionService service.PermissionService) e
 		}
 	}
 }
+
+// AuthorizeAny 授權中介軟體，用戶只要具備 permissions 其中之一即可放行
+// 適用於同一端點依權限範圍返回不同資料的情況 (例如 customer:read 與 customer:read_own)，範圍由 Handler 再行判斷
+func AuthorizeAny(permissions []string, permissionService service.PermissionService) echo.MiddlewareFunc {
+	RegisterPermissions(permissions...)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := c.Get("claims").(*jwt.AccessClaims)
+			if !ok || claims == nil {
+				utils.Logger(c).Warn("Authorization failed: JWT claims not found or invalid in context",
+					zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+				return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or missing authentication credentials"))
+			}
+
+			for _, permission := range permissions {
+				hasPermission, err := HasPermission(c, permission, permissionService)
+				if err != nil {
+					utils.Logger(c).Error("Error checking permission for user",
+						zap.Int("account_id", claims.AccountID),
+						zap.Int("role_id", claims.RoleID),
+						zap.String("required_permission", permission),
+						zap.Error(err),
+						zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+					return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+				}
+				if hasPermission {
+					return next(c)
+				}
+			}
+
+			utils.Logger(c).Warn("User forbidden from accessing resource due to insufficient permissions",
+				zap.Int("account_id", claims.AccountID),
+				zap.Int("role_id", claims.RoleID),
+				zap.Strings("required_permissions", permissions),
+				zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+			return c.JSON(http.StatusForbidden, utils.ErrForbidden.SetDetails("Insufficient permissions to perform this action"))
+		}
+	}
+}
+
+// HasPermission 檢查當前請求的用戶是否具備指定權限
+// 供需要依查詢參數決定額外權限的 Handler 使用 (例如列出已刪除的記錄)
+func HasPermission(c echo.Context, permission string, permissionService service.PermissionService) (bool, error) {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return false, nil
+	}
+	if claims.RoleID == 1 { // 與 Authorize 一致，admin 角色擁有所有權限
+		return true, nil
+	}
+	return permissionService.HasPermission(c.Request().Context(), claims.RoleID, permission)
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestRegisterPermissionsConcurrent 多個 goroutine 同時登記 (部分重複的) 權限並讀取目錄時沒有資料競爭 (以 go test -race 執行)，
+// 最後的目錄包含每個權限一次且依名稱排序
+func TestRegisterPermissionsConcurrent(t *testing.T) {
+	const (
+		goroutines = 32
+		resources  = 8
+		prefix     = "concurrent_test_"
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resource := fmt.Sprintf("%s%d", prefix, i%resources) // 每個資源由多個 goroutine 重複登記
+			RegisterPermissions(resource+":read", resource+":update")
+			RegisterPermissions(resource + ":read")
+			RegisteredPermissions()
+		}(i)
+	}
+	wg.Wait()
+
+	registered := RegisteredPermissions()
+	if !sort.StringsAreSorted(registered) {
+		t.Errorf("RegisteredPermissions is not sorted: %v", registered)
+	}
+	var got []string
+	for _, permission := range registered {
+		if strings.HasPrefix(permission, prefix) {
+			got = append(got, permission)
+		}
+	}
+	var want []string
+	for i := 0; i < resources; i++ {
+		want = append(want, fmt.Sprintf("%s%d:read", prefix, i), fmt.Sprintf("%s%d:update", prefix, i))
+	}
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("registered permissions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package bodylimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/wac0705/fastener-api/middleware/internal/rewrite"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// Config BodyLimit 中介軟體的設定
+type Config struct {
+	Skipper middleware.Skipper // 返回 true 時不限制 (例如另外套用較大上限的上傳路由)
+	Limit   int64              // 請求內容的大小上限 (bytes)
+}
+
+// Middleware 限制請求內容的大小，超過 limit 時返回 413 (utils.ErrorCodeRequestTooLarge，details 帶有 max_bytes)
+func Middleware(limit int64) echo.MiddlewareFunc {
+	return WithConfig(Config{Limit: limit})
+}
+
+// WithConfig 以 config 建立 BodyLimit 中介軟體
+// Content-Length 超過上限時不讀取內容直接拒絕；沒有 Content-Length (chunked) 時最多讀取 Limit bytes，
+// 超過時 Bind 或 FormFile 失敗，handler 因此返回的錯誤回應會改為 413
+func WithConfig(config Config) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			tooLarge := utils.NewRequestTooLargeError("Request body too large", config.Limit)
+			req := c.Request()
+			if req.ContentLength > config.Limit {
+				return tooLarge
+			}
+
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(c.Response(), req.Body, config.Limit)}
+			req.Body = body
+
+			// handler 讀取內容失敗後寫出的錯誤回應 (通常是 400)，在送出標頭前改為 413
+			res := c.Response()
+			writer := rewrite.Wrap(res)
+			res.Before(func() {
+				if body.exceeded && res.Status >= http.StatusBadRequest {
+					_ = writer.ReplaceJSON(res, tooLarge.Code, tooLarge.WithRequestID(utils.RequestID(c)))
+				}
+			})
+
+			err := next(c)
+			if err != nil && body.exceeded && !res.Committed {
+				return tooLarge
+			}
+			return err
+		}
+	}
+}
+
+// limitedBody 記錄讀取請求內容時是否超過上限
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+// Read 讀取請求內容；http.MaxBytesReader 回報超過上限時記錄下來
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
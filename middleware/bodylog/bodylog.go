@@ -0,0 +1,199 @@
+package bodylog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/utils" // 請求範圍 logger
+)
+
+// redacted 取代被遮蔽欄位的值
+const redacted = "[REDACTED]"
+
+// Config 內容日誌中介軟體的設定
+type Config struct {
+	Skipper      middleware.Skipper // 返回 true 時不記錄 (例如只記錄部分路由)
+	MaxBytes     int                // 每個請求或回應內容記錄的上限 (bytes)，超過時只記錄類型與大小
+	RedactFields []string           // JSON 物件與表單中以 "[REDACTED]" 取代值的欄位名稱 (不分大小寫，任何層級)
+}
+
+// WithConfig 以 config 建立記錄請求與回應內容的中介軟體 (除錯用)，每個請求在回應後以請求範圍 logger 記錄一筆 "http body"
+// JSON 與表單內容遮蔽 RedactFields 後記錄；超過 MaxBytes 或無法解析的 JSON 只記錄大小，避免記錄到未遮蔽的片段
+// 文字內容 (text/*) 截斷到 MaxBytes；multipart 與其他二進位內容只記錄類型與大小
+// 不讀取 handler 沒有讀取的請求內容；handler 返回的錯誤在此寫出 (c.Error)，才能記錄錯誤回應的內容
+func WithConfig(config Config) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	redact := make(map[string]bool, len(config.RedactFields))
+	for _, field := range config.RedactFields {
+		redact[strings.ToLower(field)] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			req := c.Request()
+			reqBody := &capture{limit: config.MaxBytes}
+			if req.Body != nil && req.Body != http.NoBody {
+				req.Body = &captureReader{ReadCloser: req.Body, capture: reqBody}
+			}
+			res := c.Response()
+			resBody := &capture{limit: config.MaxBytes}
+			res.Writer = &captureWriter{ResponseWriter: res.Writer, capture: resBody}
+
+			if err := next(c); err != nil {
+				c.Error(err) // 寫入錯誤回應後才能記錄回應的內容
+			}
+			utils.Logger(c).Info("http body",
+				zap.String("method", req.Method),
+				zap.String("uri", req.RequestURI),
+				zap.Int("status", res.Status),
+				describe("request_body", req.Header.Get(echo.HeaderContentType), reqBody, redact),
+				describe("response_body", res.Header().Get(echo.HeaderContentType), resBody, redact),
+			)
+			return nil
+		}
+	}
+}
+
+// capture 保留內容的前 limit bytes 並計算總大小
+type capture struct {
+	buf   bytes.Buffer
+	size  int64
+	limit int
+}
+
+// record 記錄一段內容
+func (b *capture) record(p []byte) {
+	b.size += int64(len(p))
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+}
+
+// truncated 內容是否超過 limit
+func (b *capture) truncated() bool {
+	return b.size > int64(b.buf.Len())
+}
+
+// captureReader 記錄 handler 讀取的請求內容
+type captureReader struct {
+	io.ReadCloser
+	capture *capture
+}
+
+// Read 讀取請求內容
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.capture.record(p[:n])
+	return n, err
+}
+
+// captureWriter 記錄寫出的回應內容 (壓縮前)
+type captureWriter struct {
+	http.ResponseWriter
+	capture *capture
+}
+
+// Write 寫入回應內容
+func (w *captureWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.capture.record(b[:n])
+	return n, err
+}
+
+// Flush 實現 http.Flusher 介面 (串流回應，例如產品圖片)
+func (w *captureWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回原本的 ResponseWriter，供 http.ResponseController 使用
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// describe 依 Content-Type 返回內容的日誌欄位；沒有內容時不加欄位
+func describe(key, contentType string, body *capture, redact map[string]bool) zap.Field {
+	if body.size == 0 {
+		return zap.Skip()
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "unknown"
+	}
+	switch {
+	case mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"):
+		if body.truncated() {
+			return zap.String(key, summary(mediaType, body.size, "exceeds the size cap"))
+		}
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body.buf.Bytes()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return zap.String(key, summary(mediaType, body.size, "invalid JSON"))
+		}
+		return zap.Any(key, redactJSON(value, redact))
+	case mediaType == echo.MIMEApplicationForm:
+		if body.truncated() {
+			return zap.String(key, summary(mediaType, body.size, "exceeds the size cap"))
+		}
+		values, err := url.ParseQuery(body.buf.String())
+		if err != nil {
+			return zap.String(key, summary(mediaType, body.size, "invalid form"))
+		}
+		for name := range values {
+			if redact[strings.ToLower(name)] {
+				values[name] = []string{redacted}
+			}
+		}
+		return zap.Any(key, values)
+	case strings.HasPrefix(mediaType, "text/"):
+		text := body.buf.String()
+		if body.truncated() {
+			text += fmt.Sprintf("... (truncated, %d bytes)", body.size)
+		}
+		return zap.String(key, text)
+	}
+	return zap.String(key, summary(mediaType, body.size, ""))
+}
+
+// summary 不記錄內容時的說明，例如 "[multipart/form-data, 20480 bytes]"
+func summary(mediaType string, size int64, reason string) string {
+	if reason != "" {
+		return fmt.Sprintf("[%s, %d bytes, %s]", mediaType, size, reason)
+	}
+	return fmt.Sprintf("[%s, %d bytes]", mediaType, size)
+}
+
+// redactJSON 將 JSON 物件中名稱在 redact 中的欄位 (任何層級) 取代為 "[REDACTED]"
+func redactJSON(value interface{}, redact map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redact[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = redactJSON(field, redact)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSON(item, redact)
+		}
+	}
+	return value
+}
@@ -0,0 +1,27 @@
+package deprecation
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware 為已棄用的路徑前綴加上 Deprecation 標頭，並以 Link 標頭指向 successorPrefix 下的相同路徑
+// sunset 不為零值時另加上 Sunset 標頭 (RFC 8594)，告知用戶端該日期後路徑將停止提供
+func Middleware(prefix, successorPrefix string, sunset time.Time) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set("Deprecation", "true")
+			if !sunset.IsZero() {
+				header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			if path := c.Request().URL.Path; strings.HasPrefix(path, prefix) {
+				header.Add("Link", "<"+successorPrefix+strings.TrimPrefix(path, prefix)+`>; rel="successor-version"`)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package displaytz
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderDisplayTimezone 建議前端顯示時間所使用時區的回應標頭 (IANA 名稱，例如 Asia/Taipei)
+const HeaderDisplayTimezone = "X-Display-Timezone"
+
+// Middleware 為每個回應加上 X-Display-Timezone 標頭
+// API 中的時間一律為帶時區的 RFC 3339 (UTC)，此標頭只是顯示用的建議，不影響 API 的內容與篩選條件的計算
+func Middleware(location *time.Location) echo.MiddlewareFunc {
+	name := location.String()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set(HeaderDisplayTimezone, name)
+			return next(c)
+		}
+	}
+}
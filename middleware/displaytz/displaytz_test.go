@@ -0,0 +1,61 @@
+package displaytz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	_ "time/tzdata" // 與 main 相同，不依賴主機的時區資料
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestMiddlewareDSTRoundTrip 回應的時間為 UTC，前端以 X-Display-Timezone 的時區顯示：
+// America/New_York 夏令時間切換前後 (03-10 撥快、11-03 撥慢) 的時間點都轉回原本的當地時間與時區縮寫
+func TestMiddlewareDSTRoundTrip(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	est, edt := time.FixedZone("EST", -5*3600), time.FixedZone("EDT", -4*3600)
+	instants := []time.Time{
+		time.Date(2024, 3, 10, 1, 59, 59, 0, est),
+		time.Date(2024, 3, 10, 3, 0, 0, 0, edt),
+		time.Date(2024, 11, 3, 1, 30, 0, 0, edt),
+		time.Date(2024, 11, 3, 1, 30, 0, 0, est),
+	}
+
+	e := echo.New()
+	e.Use(Middleware(newYork))
+	e.GET("/times", func(c echo.Context) error {
+		utc := make([]time.Time, len(instants))
+		for i, instant := range instants {
+			utc[i] = instant.UTC() // 與 Repository 掃描的結果相同
+		}
+		return c.JSON(http.StatusOK, utc)
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/times", nil))
+
+	hint := rec.Header().Get(HeaderDisplayTimezone)
+	if hint != "America/New_York" {
+		t.Fatalf("%s = %q, want America/New_York", HeaderDisplayTimezone, hint)
+	}
+	display, err := time.LoadLocation(hint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Time
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for i, instant := range instants {
+		local := got[i].In(display)
+		gotZone, _ := local.Zone()
+		wantZone, _ := instant.Zone()
+		if !local.Equal(instant) || gotZone != wantZone || local.Format(time.DateTime) != instant.Format(time.DateTime) {
+			t.Errorf("%v displayed as %v", instant, local)
+		}
+	}
+}
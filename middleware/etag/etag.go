@@ -0,0 +1,78 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/middleware/internal/rewrite"
+)
+
+// Middleware 處理條件式 GET：handler 以 JSON 或 Set 設定 ETag 後，
+// 請求的 If-None-Match 與其相符時將 200 回應改為沒有內容的 304，沒有 If-None-Match 的請求不受影響
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ifNoneMatch := req.Header.Get("If-None-Match")
+			if ifNoneMatch == "" || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+				return next(c)
+			}
+
+			res := c.Response()
+			writer := rewrite.Wrap(res)
+			res.Before(func() {
+				if res.Status != http.StatusOK || !matches(ifNoneMatch, res.Header().Get("ETag")) {
+					return
+				}
+				res.Status = http.StatusNotModified
+				res.Header().Del(echo.HeaderContentType)
+				res.Header().Del(echo.HeaderContentLength)
+				writer.Discard()
+			})
+			return next(c)
+		}
+	}
+}
+
+// JSON 以 JSON 返回 v，並以內容的雜湊設定 ETag；取代 c.JSON 即可讓端點支援 If-None-Match (需套用 Middleware)
+func JSON(c echo.Context, code int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	Set(c, FromBytes(body))
+	return c.JSONBlob(code, body)
+}
+
+// Set 設定回應的 ETag 與快取標頭；內容可能依使用者權限不同，只允許瀏覽器快取，且每次使用前都需重新驗證
+func Set(c echo.Context, tag string) {
+	header := c.Response().Header()
+	header.Set("ETag", tag)
+	header.Set(echo.HeaderCacheControl, "private, no-cache")
+}
+
+// FromBytes 以內容的 SHA-256 產生 weak ETag (W/"...")；回應可能經過 gzip 壓縮，因此不使用 strong ETag
+func FromBytes(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matches If-None-Match 是否包含 etag (weak 比較，忽略 W/ 前綴)，* 符合任何 ETag
+func matches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestEcho 返回套用 Middleware 的 Echo：GET /items 以 JSON 返回目前的名稱，PUT /items 修改名稱，GET /missing 以 JSON 返回 404
+func newTestEcho() *echo.Echo {
+	names := []string{"bolt"}
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/items", func(c echo.Context) error {
+		return JSON(c, http.StatusOK, names)
+	})
+	e.PUT("/items", func(c echo.Context) error {
+		names = append(names, c.QueryParam("name"))
+		return JSON(c, http.StatusOK, names)
+	})
+	e.GET("/missing", func(c echo.Context) error {
+		return JSON(c, http.StatusNotFound, map[string]string{"message": "not found"})
+	})
+	return e
+}
+
+// do 發送請求，ifNoneMatch 為空字串時不帶 If-None-Match
+func do(e *echo.Echo, method, path, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestConditionalGet If-None-Match 與 ETag 相符時返回沒有內容的 304，不符或沒有 If-None-Match 時返回完整的 200
+func TestConditionalGet(t *testing.T) {
+	e := newTestEcho()
+	first := do(e, http.MethodGet, "/items", "")
+	tag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || tag == "" {
+		t.Fatalf("first GET: status %d, ETag %q; want 200 with an ETag", first.Code, tag)
+	}
+	if got := first.Header().Get(echo.HeaderCacheControl); got != "private, no-cache" {
+		t.Errorf("Cache-Control = %q, want private, no-cache", got)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "hit", method: http.MethodGet, ifNoneMatch: tag, wantStatus: http.StatusNotModified},
+		{name: "hit without weak prefix", method: http.MethodGet, ifNoneMatch: strings.TrimPrefix(tag, "W/"), wantStatus: http.StatusNotModified},
+		{name: "hit in a list", method: http.MethodGet, ifNoneMatch: `W/"other", ` + tag, wantStatus: http.StatusNotModified},
+		{name: "wildcard", method: http.MethodGet, ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "miss", method: http.MethodGet, ifNoneMatch: `W/"stale"`, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(e, tt.method, "/items", tt.ifNoneMatch)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("ETag"); got != tag {
+				t.Errorf("ETag = %q, want %q", got, tag)
+			}
+			switch tt.wantStatus {
+			case http.StatusNotModified:
+				if rec.Body.Len() != 0 || rec.Header().Get(echo.HeaderContentType) != "" {
+					t.Errorf("304 has a body (%q) or Content-Type (%q)", rec.Body, rec.Header().Get(echo.HeaderContentType))
+				}
+			case http.StatusOK:
+				if rec.Body.String() != first.Body.String() {
+					t.Errorf("body = %q, want %q", rec.Body, first.Body)
+				}
+			}
+		})
+	}
+}
+
+// TestConditionalGetAfterWrite 資料修改後 ETag 改變，舊的 If-None-Match 取得新的內容，新的 ETag 再次返回 304
+func TestConditionalGetAfterWrite(t *testing.T) {
+	e := newTestEcho()
+	oldTag := do(e, http.MethodGet, "/items", "").Header().Get("ETag")
+
+	// 寫入請求帶有 If-None-Match 也不會被改為 304
+	if rec := do(e, http.MethodPut, "/items?name=nut", oldTag); rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200", rec.Code)
+	}
+
+	rec := do(e, http.MethodGet, "/items", oldTag)
+	newTag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "nut") {
+		t.Fatalf("GET with the old ETag: status %d, body %s; want 200 with the updated list", rec.Code, rec.Body)
+	}
+	if newTag == oldTag {
+		t.Fatalf("ETag did not change after the write: %s", newTag)
+	}
+	if rec := do(e, http.MethodGet, "/items", newTag); rec.Code != http.StatusNotModified {
+		t.Errorf("GET with the new ETag: status %d, want 304", rec.Code)
+	}
+}
+
+// TestConditionalGetIgnoresErrors 非 200 的回應即使 ETag 相符也照常返回
+func TestConditionalGetIgnoresErrors(t *testing.T) {
+	e := newTestEcho()
+	tag := do(e, http.MethodGet, "/missing", "").Header().Get("ETag")
+	rec := do(e, http.MethodGet, "/missing", tag)
+	if rec.Code != http.StatusNotFound || rec.Body.Len() == 0 {
+		t.Errorf("status %d, body %q; want the 404 with its body", rec.Code, rec.Body)
+	}
+}
+
+// TestFromBytes 相同內容產生相同的 weak ETag，不同內容產生不同的 ETag
+func TestFromBytes(t *testing.T) {
+	a, b := FromBytes([]byte(`["bolt"]`)), FromBytes([]byte(`["bolt"]`))
+	if a != b || !strings.HasPrefix(a, `W/"`) {
+		t.Errorf("FromBytes = %q and %q, want the same weak ETag", a, b)
+	}
+	if c := FromBytes([]byte(`["nut"]`)); c == a {
+		t.Errorf("different content produced the same ETag %q", c)
+	}
+}
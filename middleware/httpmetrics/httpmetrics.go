@@ -0,0 +1,33 @@
+package httpmetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/metrics" // 指標註冊介面
+)
+
+// Middleware 記錄每個請求的數量與延遲，以路由樣板 (例如 /api/customers/:id) 而不是實際路徑作為標籤，避免標籤數量無限增長
+// handler 返回的錯誤會在這裡交給全局錯誤處理器，才能記錄實際的狀態碼
+func Middleware(reg metrics.Registry) echo.MiddlewareFunc {
+	requests := reg.Counter("http_requests_total", "Total number of HTTP requests by method, route and status.", "method", "route", "status")
+	latency := reg.Histogram("http_request_duration_seconds", "HTTP request latency in seconds by method and route.", metrics.DefaultLatencyBuckets, "method", "route")
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			if err := next(c); err != nil {
+				c.Error(err) // 寫入錯誤回應後 Response().Status 才是實際的狀態碼
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched" // 沒有對應的路由
+			}
+			method := c.Request().Method
+			requests.Inc(method, route, strconv.Itoa(c.Response().Status))
+			latency.Observe(time.Since(start).Seconds(), method, route)
+			return nil
+		}
+	}
+}
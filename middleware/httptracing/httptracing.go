@@ -0,0 +1,56 @@
+package httptracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/tracing"
+	"github.com/wac0705/fastener-api/utils" // 請求範圍 logger 的存取
+)
+
+// headerTraceparent W3C Trace Context 的標頭，上游服務以此傳遞 trace ID 與父 span
+const headerTraceparent = "traceparent"
+
+// Middleware 為每個請求建立 server span (名稱為方法與路由樣板，例如 GET /api/customers/:id) 並存入請求的 context，
+// service 與資料庫查詢的 span 都是它的子 span；請求帶有 traceparent 標頭時延續上游的 trace
+// 請求範圍 logger (utils.Logger) 會加上 trace_id，需放在 requestlog.ContextLogger 之後
+// handler 返回的錯誤會在這裡交給全局錯誤處理器，才能記錄實際的狀態碼，錯誤回應也能帶上 trace_id
+func Middleware(skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !tracing.Enabled() || (skipper != nil && skipper(c)) {
+				return next(c)
+			}
+			req := c.Request()
+			route := c.Path()
+			if route == "" {
+				route = "unmatched" // 沒有對應的路由
+			}
+			ctx := tracing.ContextWithRemoteParent(req.Context(), req.Header.Get(headerTraceparent))
+			ctx, span := tracing.StartSpan(ctx, req.Method+" "+route, tracing.KindServer,
+				tracing.String("http.method", req.Method),
+				tracing.String("http.route", route),
+				tracing.String("http.target", req.URL.Path),
+				tracing.String("http.client_ip", c.RealIP()),
+				tracing.String("http.request_id", utils.RequestID(c)),
+			)
+			defer span.End()
+			ctx = utils.ContextWithLogFields(ctx, zap.String("trace_id", span.TraceID()))
+			c.SetRequest(req.WithContext(ctx))
+
+			if err := next(c); err != nil {
+				c.Error(err) // 寫入錯誤回應後 Response().Status 才是實際的狀態碼
+			}
+			status := c.Response().Status
+			span.SetAttributes(tracing.Int("http.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.RecordError(fmt.Errorf("HTTP %d", status))
+			}
+			return nil
+		}
+	}
+}
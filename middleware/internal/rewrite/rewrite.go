@@ -0,0 +1,67 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Writer 包裝回應的 http.ResponseWriter，讓中介軟體可以在送出標頭前 (echo.Response.Before) 改寫 handler 已決定的回應
+// 設定 replacement 後以其取代 handler 寫入的回應內容
+type Writer struct {
+	http.ResponseWriter
+	replacement []byte
+	written     bool
+}
+
+// Wrap 以 Writer 取代 res.Writer 並返回；writer 不在請求結束時還原，HTTPErrorHandler 在此之後寫出的回應也可以被改寫
+func Wrap(res *echo.Response) *Writer {
+	writer := &Writer{ResponseWriter: res.Writer}
+	res.Writer = writer
+	return writer
+}
+
+// ReplaceJSON 將回應改為狀態碼 code 與 v 的 JSON 內容，必須在 res.Before 中呼叫
+func (w *Writer) ReplaceJSON(res *echo.Response, code int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	res.Status = code
+	res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	res.Header().Del(echo.HeaderContentLength)
+	w.replacement = body
+	return nil
+}
+
+// Discard 捨棄 handler 寫入的回應內容 (例如改為 304 時)，必須在 res.Before 中呼叫
+func (w *Writer) Discard() {
+	w.replacement = []byte{}
+}
+
+// Write 寫入回應內容；已設定 replacement 時只寫入一次 replacement，其餘內容捨棄
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.replacement == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	if !w.written && len(w.replacement) > 0 {
+		w.written = true
+		if _, err := w.ResponseWriter.Write(w.replacement); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush 實現 http.Flusher 介面 (串流回應，例如產品圖片)
+func (w *Writer) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回原本的 ResponseWriter，供 http.ResponseController 使用
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
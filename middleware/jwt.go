@@ -77,7 +77,7 @@ func JwtAccessConfig(secret string) echojwt.Config {
 		TokenLookup: "header:" + echo.HeaderAuthorization, // 從 Authorization 頭部查找 Token
 		AuthScheme:  "Bearer",                             // Token 方案
 		ErrorHandler: func(c echo.Context, err error) error {
-			zap.L().Info("Access Token validation failed", zap.Error(err), zap.String("path", c.Path()))
+			utils.Logger(c).Info("Access Token validation failed", zap.Error(err), zap.String("path", c.Path()))
 			return c.JSON(http.StatusUnauthorized, utils.ErrUnauthorized.SetDetails("Invalid or expired access token"))
 		},
 	}
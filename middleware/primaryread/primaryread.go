@@ -0,0 +1,25 @@
+package primaryread
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/db"
+)
+
+// Middleware 將寫入請求 (GET、HEAD、OPTIONS 以外的方法) 的 context 以 db.WithPrimary 標記，
+// 讓請求中的讀取 (例如建立後重新讀取完整記錄、更新前讀取現有資料) 都使用主庫，不受唯讀副本延遲影響
+// 未設定 DATABASE_READ_URL 時所有查詢本來就使用主庫，此中介軟體沒有作用
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				c.SetRequest(c.Request().WithContext(db.WithPrimary(c.Request().Context())))
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package requestlog
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/middleware/jwt" // AccessClaims
+	"github.com/wac0705/fastener-api/utils"          // 請求範圍 logger 的存取
+)
+
+// ContextLogger 為每個請求建立帶有 request_id 的 logger 並存入請求的 context (見 utils.Logger 與 utils.ContextLogger)
+// 需放在 Echo 的 RequestID 中介軟體之後，才能取得請求 ID
+func ContextLogger(base *zap.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := utils.ContextWithLogger(req.Context(), base)
+			c.SetRequest(req.WithContext(utils.ContextWithLogFields(ctx, zap.String("request_id", utils.RequestID(c)))))
+			return next(c)
+		}
+	}
+}
+
+// WithAccount 在請求範圍 logger 加上發出請求的帳戶 (見 AccountFields)，handler 以 utils.Logger 記錄的日誌也能對應到帳戶
+// 需放在將 AccessClaims 存入 "claims" 的中介軟體之後
+func WithAccount() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if fields := AccountFields(c); fields != nil {
+				req := c.Request()
+				c.SetRequest(req.WithContext(utils.ContextWithLogFields(req.Context(), fields...)))
+			}
+			return next(c)
+		}
+	}
+}
+
+// AccountFields 返回已通過 JWT 驗證的請求的 account_id、username 與 role_id 欄位
+// 未驗證的請求 (公開路由、驗證失敗) 返回 nil，日誌中不出現這些欄位，以免與 ID 為 0 的帳戶混淆
+func AccountFields(c echo.Context) []zap.Field {
+	claims, ok := c.Get("claims").(*jwt.AccessClaims)
+	if !ok || claims == nil {
+		return nil
+	}
+	return []zap.Field{
+		zap.Int("account_id", claims.AccountID),
+		zap.String("username", claims.Username),
+		zap.Int("role_id", claims.RoleID),
+	}
+}
@@ -0,0 +1,86 @@
+package requesttimeout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/middleware/internal/rewrite"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// Config RequestTimeout 中介軟體的設定
+type Config struct {
+	Skipper middleware.Skipper // 返回 true 時不設定逾時 (例如長時間保持連線的事件串流)
+	Timeout time.Duration      // 每個請求的逾時
+}
+
+// Middleware 為每個請求的 context 設定逾時，handler 將 c.Request().Context() 傳入 service 與 repository，
+// 逾時或用戶端中斷連線時進行中的資料庫查詢會被取消
+// 查詢因此失敗時 handler 返回的 500 會改為 503 (utils.ErrRequestTimeout) 或 499 (utils.ErrClientClosedRequest)
+func Middleware(timeout time.Duration) echo.MiddlewareFunc {
+	return WithConfig(Config{Timeout: timeout})
+}
+
+// WithConfig 以 config 建立 RequestTimeout 中介軟體
+func WithConfig(config Config) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	timeout := config.Timeout
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			// handler 直接寫出 500 時 (c.JSON(http.StatusInternalServerError, ...))，在送出標頭前改寫狀態碼與內容
+			// HTTPErrorHandler 在此之後寫出的 500 也會被改寫
+			res := c.Response()
+			writer := rewrite.Wrap(res)
+			res.Before(func() {
+				if res.Status != http.StatusInternalServerError {
+					return
+				}
+				if cancelErr := cancellationError(ctx, c, timeout); cancelErr != nil {
+					_ = writer.ReplaceJSON(res, cancelErr.Code, cancelErr.WithRequestID(utils.RequestID(c)))
+				}
+			})
+
+			err := next(c)
+			if err == nil || res.Committed {
+				return err
+			}
+			// 返回錯誤交由 HTTPErrorHandler 處理時，用戶端錯誤 (4xx) 維持原樣
+			var customErr *utils.CustomError
+			if errors.As(err, &customErr) && customErr.Code < http.StatusInternalServerError {
+				return err
+			}
+			if cancelErr := cancellationError(ctx, c, timeout); cancelErr != nil {
+				return cancelErr
+			}
+			return err
+		}
+	}
+}
+
+// cancellationError 依請求 context 的狀態返回對應的錯誤並記錄日誌，context 未結束時返回 nil
+func cancellationError(ctx context.Context, c echo.Context, timeout time.Duration) *utils.CustomError {
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		utils.Logger(c).Warn("Request timed out", zap.String("path", c.Path()), zap.Duration("timeout", timeout))
+		return utils.ErrRequestTimeout
+	case errors.Is(err, context.Canceled):
+		utils.Logger(c).Info("Client closed request", zap.String("path", c.Path()))
+		return utils.ErrClientClosedRequest
+	}
+	return nil
+}
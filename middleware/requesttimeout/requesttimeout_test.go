@@ -0,0 +1,118 @@
+package requesttimeout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/routes"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// testTimeout 測試使用的請求逾時
+const testTimeout = 20 * time.Millisecond
+
+// newTestEcho 返回套用 WithConfig (Skipper 與伺服器相同為 routes.IsStreamRequest) 的 Echo，錯誤處理器與伺服器相同以 CustomError 的狀態碼回應：
+// /api/v1/slow 等到請求 context 結束後返回查詢失敗的錯誤 (status=direct 時直接寫出 500，status=404 時返回 utils.ErrNotFound)，
+// /api/v1/bad 立即返回 400，/api/v1/events 返回請求 context 是否有期限
+func newTestEcho() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		var customErr *utils.CustomError
+		if !errors.As(err, &customErr) {
+			customErr = utils.ErrInternalServer
+		}
+		_ = c.JSON(customErr.Code, customErr)
+	}
+	e.Use(WithConfig(Config{Skipper: routes.IsStreamRequest, Timeout: testTimeout}))
+	e.GET(routes.APIV1Prefix+"/slow", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		<-ctx.Done()
+		queryErr := fmt.Errorf("failed to query customers: %w", ctx.Err()) // 與 repository 包裝的錯誤相同
+		switch c.QueryParam("status") {
+		case "direct":
+			return c.JSON(http.StatusInternalServerError, utils.ErrInternalServer)
+		case "404":
+			return utils.ErrNotFound
+		}
+		return queryErr
+	})
+	e.GET(routes.APIV1Prefix+"/bad", func(c echo.Context) error {
+		return utils.ErrBadRequest.SetDetails("Invalid ID")
+	})
+	e.GET(routes.APIV1Prefix+"/events", func(c echo.Context) error {
+		_, hasDeadline := c.Request().Context().Deadline()
+		return c.JSON(http.StatusOK, map[string]bool{"deadline": hasDeadline})
+	})
+	return e
+}
+
+// TestWithConfig 逾時與用戶端中斷連線時 500 改為 503 與 499 (不論 handler 返回錯誤或直接寫出)，4xx 維持原樣，事件串流不設定逾時
+func TestWithConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		cancel      bool // 用戶端在回應前中斷連線
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "deadline exceeded", path: "/slow", wantStatus: http.StatusServiceUnavailable, wantMessage: utils.ErrRequestTimeout.Message},
+		{name: "deadline exceeded with a direct 500", path: "/slow?status=direct", wantStatus: http.StatusServiceUnavailable, wantMessage: utils.ErrRequestTimeout.Message},
+		{name: "client closed request", path: "/slow", cancel: true, wantStatus: utils.StatusClientClosedRequest, wantMessage: utils.ErrClientClosedRequest.Message},
+		{name: "client closed request with a direct 500", path: "/slow?status=direct", cancel: true, wantStatus: utils.StatusClientClosedRequest, wantMessage: utils.ErrClientClosedRequest.Message},
+		{name: "4xx after the deadline is kept", path: "/slow?status=404", wantStatus: http.StatusNotFound, wantMessage: utils.ErrNotFound.Message},
+		{name: "4xx is kept", path: "/bad", wantStatus: http.StatusBadRequest, wantMessage: utils.ErrBadRequest.Message},
+	}
+	e := newTestEcho()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			req := httptest.NewRequest(http.MethodGet, routes.APIV1Prefix+tt.path, nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			e.ServeHTTP(rec, req)
+
+			var body utils.CustomError
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q: %v", rec.Body, err)
+			}
+			if rec.Code != tt.wantStatus || body.Code != tt.wantStatus || body.Message != tt.wantMessage {
+				t.Errorf("response = %d %s, want %d %q", rec.Code, rec.Body, tt.wantStatus, tt.wantMessage)
+			}
+			if tt.cancel && time.Since(start) >= testTimeout {
+				t.Errorf("canceled request took %v, want it to end before the %v timeout", time.Since(start), testTimeout)
+			}
+		})
+	}
+}
+
+// TestWithConfigSkipsStreams 事件串流 (routes.IsStreamRequest) 的請求 context 沒有期限，其他 API 路由有
+func TestWithConfigSkipsStreams(t *testing.T) {
+	e := newTestEcho()
+	e.GET(routes.APIV1Prefix+"/customers", func(c echo.Context) error {
+		_, hasDeadline := c.Request().Context().Deadline()
+		return c.JSON(http.StatusOK, map[string]bool{"deadline": hasDeadline})
+	})
+	for path, want := range map[string]bool{"/events": false, "/customers": true} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, routes.APIV1Prefix+path, nil))
+		var got map[string]bool
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: body %q: %v", path, rec.Body, err)
+		}
+		if rec.Code != http.StatusOK || got["deadline"] != want {
+			t.Errorf("%s: status %d, deadline %v; want 200, %v", path, rec.Code, got["deadline"], want)
+		}
+	}
+}
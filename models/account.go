@@ -4,13 +4,16 @@ import "time"
 
 // Account 帳戶模型，用於應用程式用戶
 type Account struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username" validate:"required,min=3,max=50"`
-	Password  string    `json:"password,omitempty" validate:"required,min=6"` // `omitempty` 在 JSON 序列化時忽略空值
-	RoleID    int       `json:"role_id"`
-	RoleName  string    `json:"role_at_read,omitempty"` // 角色名稱，通常在讀取時通過 JOIN 填充
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int        `json:"id"`
+	PublicID  string     `json:"public_id"` // 唯讀，對外的 UUID 識別碼，可取代路徑中的 ID
+	Username  string     `json:"username" validate:"required,min=3,max=50"`
+	Password  string     `json:"password,omitempty" validate:"required,password"` // `omitempty` 在 JSON 序列化時忽略空值
+	RoleID    int        `json:"role_id"`
+	RoleName  string     `json:"role_at_read,omitempty"` // 角色名稱，通常在讀取時通過 JOIN 填充
+	IsActive  bool       `json:"is_active"`              // 停用的帳戶不可被指派為業務代表
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // 軟刪除時間，未刪除時為 nil (只有 IncludeDeleted 查詢會返回已刪除的帳戶)
 }
 
 // LoginRequest 用於登入請求的結構
@@ -22,14 +25,14 @@ type LoginRequest struct {
 // RegisterRequest 用於註冊請求的結構
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required,password"`
 	RoleID   int    `json:"role_id" validate:"required,min=1"` // 註冊時必須指定角色
 }
 
 // UpdatePasswordRequest 用於更新密碼請求
 type UpdatePasswordRequest struct {
 	OldPassword string `json:"old_password" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=6"`
+	NewPassword string `json:"new_password" validate:"required,password"`
 }
 
 // RefreshTokenRequest 用於刷新 Token 請求
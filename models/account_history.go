@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// 帳戶歷史事件類型
+const (
+	AccountEventCreated = "created" // 建立帳戶，new_value 為角色 ID
+)
+
+// AccountHistory 帳戶的一筆變更記錄 (稽核)
+type AccountHistory struct {
+	ID        int       `json:"id"`
+	AccountID int       `json:"account_id"`
+	Event     string    `json:"event"`
+	Field     string    `json:"field,omitempty"` // created 事件為 role_id
+	OldValue  *string   `json:"old_value"`       // 變更前的值，nil 表示原本為空
+	NewValue  *string   `json:"new_value"`       // 變更後的值，nil 表示清空
+	ActorID   *int      `json:"actor_id"`        // 執行變更的帳戶，帳戶刪除後為 nil
+	CreatedAt time.Time `json:"created_at"`
+}
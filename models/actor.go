@@ -0,0 +1,10 @@
+package models
+
+// RecordActors 建立與最後修改記錄的帳戶，嵌入在客戶、公司、選單、角色與產品定義中
+// 由已驗證的使用者寫入時以其帳戶 ID 填入，種子資料等系統寫入為 nil；唯讀，請求內容中的值一律被忽略
+type RecordActors struct {
+	CreatedBy         *int    `json:"created_by"`          // 建立記錄的帳戶 ID
+	CreatedByUsername *string `json:"created_by_username"` // 唯讀，由查詢時 JOIN 帳戶取得
+	UpdatedBy         *int    `json:"updated_by"`          // 最後修改記錄的帳戶 ID (建立時與 CreatedBy 相同)
+	UpdatedByUsername *string `json:"updated_by_username"` // 唯讀，由查詢時 JOIN 帳戶取得
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/wac0705/fastener-api/utils/query"
+)
+
+// AuditLog 一筆寫入操作 (POST、PUT、PATCH、DELETE) 的稽核記錄，由稽核中介軟體在請求結束後產生
+type AuditLog struct {
+	ID            int64           `json:"id"`
+	RequestID     string          `json:"request_id"`     // 與請求日誌、錯誤回應的 request_id 相同
+	ActorID       *int            `json:"actor_id"`       // 發出請求的帳戶，帳戶刪除後為 nil
+	ActorUsername *string         `json:"actor_username"` // 唯讀，由查詢時 JOIN 帳戶取得
+	Method        string          `json:"method"`
+	Route         string          `json:"route"`               // 路由樣板，例如 /api/v1/customers/:id
+	Path          string          `json:"path"`                // 實際請求的路徑
+	EntityID      string          `json:"entity_id,omitempty"` // 路由中最後一個路徑參數的值，新增資源的請求為空
+	Status        int             `json:"status"`
+	LatencyMs     int             `json:"latency_ms"`
+	RemoteIP      string          `json:"remote_ip,omitempty"`
+	Body          json.RawMessage `json:"body,omitempty"` // 只有選擇記錄請求內容的路由才有值，敏感欄位已遮蔽
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AuditLogFilter 稽核記錄列表的篩選條件
+type AuditLogFilter struct {
+	Criteria   query.Query // actor_id、method、status 與 created_at 的篩選，已依 Handler 宣告的 query.Spec 驗證
+	PathPrefix string      // 只返回實際路徑以此開頭的記錄，例如 /api/v1/customers
+}
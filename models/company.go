@@ -4,8 +4,48 @@ import "time"
 
 // Company 公司模型
 type Company struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name" validate:"required,min=2,max=255"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              int        `json:"id"`
+	PublicID        string     `json:"public_id"` // 唯讀，對外的 UUID 識別碼，可取代路徑中的 ID
+	Name            string     `json:"name" validate:"required,min=2,max=255"`
+	TaxID           string     `json:"tax_id,omitempty" validate:"omitempty,max=50"`            // 統一編號
+	Country         string     `json:"country,omitempty" validate:"omitempty,iso3166_1_alpha2"` // ISO 3166-1 alpha-2 國家代碼
+	Currency        string     `json:"currency,omitempty" validate:"omitempty,iso4217"`         // ISO 4217 幣別代碼
+	ParentCompanyID *int       `json:"parent_company_id,omitempty"`                             // 父公司 ID，允許為 NULL (集團最上層)
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"` // 軟刪除時間，未刪除時為 nil (只有 IncludeDeleted 查詢會返回已刪除的公司)
+	RecordActors               // 唯讀，建立者與最後修改者
+}
+
+// CompanyTreeNode 公司樹狀結構節點，用於返回集團層級
+type CompanyTreeNode struct {
+	Company
+	Children []*CompanyTreeNode `json:"children"`
+}
+
+// CompanyImportRow CSV 匯入的單列公司資料，Line 為原始檔案中的行號
+type CompanyImportRow struct {
+	Line    int
+	Company Company
+}
+
+// CompanyStats 公司統計資料，用於儀表板
+type CompanyStats struct {
+	CompanyID             int        `json:"company_id"`
+	Name                  string     `json:"name"`
+	CustomerCount         int        `json:"customer_count"`
+	LastCustomerCreatedAt *time.Time `json:"last_customer_created_at"` // 沒有客戶時為 null
+}
+
+// CompanyMergeRequest 合併公司時的請求結構，來源公司會被合併到 URL 中的目標公司
+type CompanyMergeRequest struct {
+	SourceCompanyID int `json:"source_company_id" validate:"required,gt=0"`
+}
+
+// CompanyMergeResult 合併公司的結果，包含被移動的記錄數量
+type CompanyMergeResult struct {
+	SourceCompanyID     int `json:"source_company_id"`
+	TargetCompanyID     int `json:"target_company_id"`
+	MovedCustomers      int `json:"moved_customers"`
+	MovedChildCompanies int `json:"moved_child_companies"`
 }
@@ -1,15 +1,85 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/wac0705/fastener-api/utils/query"
+)
+
+// 客戶生命週期狀態
+const (
+	CustomerStatusProspect = "prospect" // 潛在客戶
+	CustomerStatusActive   = "active"   // 往來中
+	CustomerStatusInactive = "inactive" // 已停止往來
+)
 
 // Customer 客戶模型
 type Customer struct {
 	ID           int       `json:"id"`
+	PublicID     string    `json:"public_id"` // 唯讀，對外的 UUID 識別碼，可取代路徑中的 ID
+	Code         string    `json:"code"`                                   // 客戶代碼 (例如 "C-000123")，建立時自動產生，之後不可修改
 	Name         string    `json:"name" validate:"required,min=2,max=255"`
 	ContactPerson string    `json:"contact_person"`
 	Email        string    `json:"email" validate:"omitempty,email"` // omitempty 表示可選，email 驗證格式
-	Phone        string    `json:"phone" validate:"omitempty,min=7,max=20"`
+	Phone        string    `json:"phone" validate:"omitempty,min=7,max=20,phone"` // 保留使用者輸入的原始格式
+	PhoneNormalized string `json:"phone_normalized"`                              // 唯讀，E.164 格式，由 Service 層產生
 	CompanyID    *int      `json:"company_id,omitempty"` // 指針類型允許為 NULL
+	CompanyName  *string   `json:"company_name"`         // 唯讀，由查詢時 JOIN 公司取得；未關聯公司時為 null
+	Currency     string    `json:"currency" validate:"omitempty,currency"`           // ISO 4217，未指定時沿用公司的幣別
+	PaymentTerms string    `json:"payment_terms" validate:"omitempty,payment_terms"` // 付款條件，可選值由 PAYMENT_TERMS 設定
+	SalesRepAccountID *int  `json:"sales_rep_account_id,omitempty"` // 負責的業務代表帳戶 ID
+	SalesRepUsername  *string `json:"sales_rep_username"`           // 唯讀，由查詢時 JOIN 帳戶取得
+	Status       string    `json:"status" validate:"omitempty,oneof=prospect active inactive"` // 新增時預設 prospect，更新時未指定則保留原狀態
+	StatusReason string    `json:"status_reason,omitempty" validate:"max=500"`               // 僅寫入，狀態變更的原因，記錄在客戶歷史中
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"` // 軟刪除時間，未刪除時為 nil
+	RecordActors                  // 唯讀，建立者與最後修改者
+	LastNoteAt   *time.Time `json:"last_note_at"`         // 唯讀，最近一則備註的時間，可用於列表排序
+	Addresses    []CustomerAddress `json:"addresses,omitempty"` // 唯讀，僅在客戶詳情中返回
+}
+
+// CustomerConflict 客戶唯一欄位衝突時返回的錯誤細節，讓前端可以提供 "開啟既有記錄"
+type CustomerConflict struct {
+	Field              string `json:"field"`                // 發生衝突的欄位，例如 "email"
+	ExistingCustomerID int    `json:"existing_customer_id"` // 已使用該值的客戶 ID
+}
+
+// CustomerDuplicateCheckRequest 建立客戶前檢查相似記錄的請求，至少需提供一個欄位
+type CustomerDuplicateCheckRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email" validate:"omitempty,email"`
+	Phone string `json:"phone" validate:"omitempty,phone"`
+}
+
+// CustomerDuplicateCandidate 可能重複的既有客戶，依 Score 由高到低排序
+type CustomerDuplicateCandidate struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	ContactPerson string   `json:"contact_person"`
+	Email         string   `json:"email"`
+	Phone         string   `json:"phone"`
+	CompanyID     *int     `json:"company_id,omitempty"`
+	CompanyName   *string  `json:"company_name"`
+	Score         float64  `json:"score"`      // 0 到 1，Email 完全相同為 1，電話相同為 0.9，否則為名稱相似度
+	MatchedOn     []string `json:"matched_on"` // 命中的欄位：email、phone、name
+}
+
+// CustomerFilter 客戶列表的搜尋與排序條件
+type CustomerFilter struct {
+	Query              string // 以 ILIKE 模糊比對名稱、聯絡人與 Email
+	Criteria           query.Query // 通用篩選條件與排序 (例如 created_at_gte、sort=-name)，已依 Handler 宣告的 query.Spec 驗證
+	CompanyID          *int   // 只返回該公司的客戶
+	NoCompany          bool   // 只返回未關聯公司的客戶 (company_id=null)
+	IncludeDescendants bool   // 搭配 CompanyID，包含所有子孫公司的客戶
+	IncludeDeleted     bool   // 包含已軟刪除的客戶
+	SalesRepAccountID  *int   // 只返回指派給該業務代表的客戶
+	Status             string // 只返回該狀態的客戶
+}
+
+// CustomerImportRow 匯入時已通過驗證的一列客戶資料
+type CustomerImportRow struct {
+	Line        int      // 原始檔案中的行號
+	Customer    Customer // CompanyID 已由公司名稱解析
+	CompanyName string   // 需要自動建立的公司名稱 (CompanyID 為 nil 且允許自動建立時)
 }
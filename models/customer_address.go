@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// 地址類型
+const (
+	AddressTypeBilling  = "billing"  // 帳單地址
+	AddressTypeShipping = "shipping" // 送貨地址
+)
+
+// CustomerAddress 客戶地址模型
+type CustomerAddress struct {
+	ID         int       `json:"id"`
+	CustomerID int       `json:"customer_id"` // 由 URL 參數決定，忽略請求中的值
+	Type       string    `json:"type" validate:"required,oneof=billing shipping"`
+	Line1      string    `json:"line1" validate:"required,max=255"`
+	Line2      string    `json:"line2" validate:"omitempty,max=255"`
+	City       string    `json:"city" validate:"required,max=100"`
+	State      string    `json:"state" validate:"omitempty,max=100"`
+	PostalCode string    `json:"postal_code" validate:"omitempty,max=20"`
+	Country    string    `json:"country" validate:"required,iso3166_1_alpha2"`
+	IsDefault  bool      `json:"is_default"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
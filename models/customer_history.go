@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// 客戶歷史事件類型
+const (
+	CustomerEventCreated  = "created"  // 建立客戶
+	CustomerEventUpdated  = "updated"  // 欄位變更，每個變更的欄位一筆
+	CustomerEventDeleted  = "deleted"  // 軟刪除
+	CustomerEventRestored = "restored" // 還原
+)
+
+// CustomerHistory 客戶的一筆變更記錄
+type CustomerHistory struct {
+	ID            int       `json:"id"`
+	CustomerID    int       `json:"customer_id"`
+	Event         string    `json:"event"`
+	Field         string    `json:"field,omitempty"` // 僅 updated 事件有值
+	OldValue      *string   `json:"old_value"`       // 變更前的值，nil 表示原本為空
+	NewValue      *string   `json:"new_value"`       // 變更後的值，nil 表示清空
+	Reason        string    `json:"reason,omitempty"`
+	ActorID       *int      `json:"actor_id"`       // 執行變更的帳戶，帳戶刪除後為 nil
+	ActorUsername *string   `json:"actor_username"` // 唯讀，由查詢時 JOIN 帳戶取得
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// CustomerNote 客戶備註模型 (例如 "致電詢問 M8 螺栓報價")
+type CustomerNote struct {
+	ID             int       `json:"id"`
+	CustomerID     int       `json:"customer_id"`               // 由 URL 參數決定
+	AuthorID       *int      `json:"author_id"`                 // 由 JWT claims 決定，帳戶刪除後為 null
+	AuthorUsername *string   `json:"author_username,omitempty"` // 唯讀，查詢時 JOIN 帳戶取得
+	Body           string    `json:"body" validate:"required,max=5000"`
+	Pinned         bool      `json:"pinned"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// 健康檢查的整體與元件狀態
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+	HealthStatusSkipped     = "skipped"    // 元件未檢查 (例如找不到遷移檔案時的 migrations)，不影響整體狀態
+	HealthStatusConnecting  = "connecting" // 啟動時仍在等待資料庫可連線 (重試中)，整體狀態為 unavailable
+)
+
+// HealthCheckResponse GET /healthz、/livez 與 /readyz 的回應；欄位固定，供負載平衡器與 Kubernetes 探針判斷
+// 正常時 HTTP 200 且 status 為 ok，任一元件異常時 HTTP 503、status 為 unavailable 並以 failed_component 指出第一個失敗的元件
+type HealthCheckResponse struct {
+	Status          string            `json:"status"`
+	Version         string            `json:"version"`    // 建置版本
+	StartedAt       time.Time         `json:"started_at"` // 程序啟動時間
+	UptimeSeconds   int64             `json:"uptime_seconds"`
+	Checks          map[string]string `json:"checks"` // 元件名稱 => ok、unavailable、skipped 或 connecting，例如 {"database": "ok"}
+	FailedComponent string            `json:"failed_component,omitempty"`
+}
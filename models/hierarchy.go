@@ -0,0 +1,19 @@
+package models
+
+// ChildrenDeleteMode 刪除具有子節點的資源時，子節點的處理方式
+type ChildrenDeleteMode string
+
+const (
+	ChildrenDeleteBlock   ChildrenDeleteMode = "block"   // 有子節點時拒絕刪除 (預設)
+	ChildrenDeleteCascade ChildrenDeleteMode = "cascade" // 連同所有子孫節點一併刪除
+	ChildrenDeleteDetach  ChildrenDeleteMode = "detach"  // 將子節點的父 ID 設為 NULL 後再刪除
+)
+
+// IsValid 檢查刪除模式是否為支援的值
+func (m ChildrenDeleteMode) IsValid() bool {
+	switch m {
+	case ChildrenDeleteBlock, ChildrenDeleteCascade, ChildrenDeleteDetach:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package models
+
+// 匯入結果中每一列的處理動作
+const (
+	ImportActionCreated = "created" // 新增
+	ImportActionUpdated = "updated" // 更新既有資料
+	ImportActionInvalid = "invalid" // 驗證失敗或衝突被拒絕，未寫入
+	ImportActionSkipped = "skipped" // 與既有資料重複，依設定略過
+)
+
+// ImportConflictMode 匯入的資料與既有資料重複時的處理方式
+type ImportConflictMode string
+
+const (
+	ImportConflictReject ImportConflictMode = "reject" // 將該列標記為失敗 (預設)
+	ImportConflictSkip   ImportConflictMode = "skip"   // 略過該列，保留既有資料
+	ImportConflictUpdate ImportConflictMode = "update" // 以匯入的資料更新既有資料
+)
+
+// IsValid 檢查是否為支援的衝突處理方式
+func (m ImportConflictMode) IsValid() bool {
+	switch m {
+	case ImportConflictReject, ImportConflictSkip, ImportConflictUpdate:
+		return true
+	}
+	return false
+}
+
+// ImportRowResult 匯入時單列的處理結果
+type ImportRowResult struct {
+	Line    int         `json:"line"`              // 原始檔案中的行號 (標題列為第 1 行)
+	Action  string      `json:"action"`            // created / updated / skipped / invalid
+	ID      *int        `json:"id,omitempty"`      // 寫入 (或 dry run 時比對到) 的資料 ID
+	Details interface{} `json:"details,omitempty"` // 驗證失敗或錯誤的細節
+}
+
+// ImportResult 匯入的彙總結果
+type ImportResult struct {
+	DryRun     bool              `json:"dry_run"`
+	RolledBack bool              `json:"rolled_back,omitempty"` // 有列失敗而整批回滾，沒有任何資料被寫入 (僅用於不允許部分寫入的匯入)
+	Total      int               `json:"total"`
+	Created    int               `json:"created"`
+	Updated    int               `json:"updated"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Rows       []ImportRowResult `json:"rows"`
+}
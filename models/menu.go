@@ -5,6 +5,7 @@ import "time"
 // Menu 選單模型
 type Menu struct {
 	ID           int       `json:"id"`
+	PublicID     string    `json:"public_id"` // 唯讀，對外的 UUID 識別碼，可取代路徑中的 ID
 	Name         string    `json:"name" validate:"required,min=2,max=100"`
 	Path         string    `json:"path" validate:"required,min=1,max=255"` // 前端路由路徑
 	Icon         string    `json:"icon,omitempty"`                         // 選單圖標
@@ -12,4 +13,5 @@ type Menu struct {
 	DisplayOrder int       `json:"display_order"`                          // 顯示順序
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	RecordActors           // 唯讀，建立者與最後修改者
 }
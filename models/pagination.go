@@ -0,0 +1,24 @@
+package models
+
+// PaginatedResponse 分頁列表的統一響應格式
+type PaginatedResponse struct {
+	Data       interface{} `json:"data"`                  // 當前頁的資料
+	Total      *int        `json:"total"`                 // 符合條件的總筆數；支援 include_total 的列表未要求時為 null
+	Page       int         `json:"page"`                  // 當前頁碼 (從 1 開始)
+	PageSize   int         `json:"page_size"`             // 每頁筆數
+	HasMore    *bool       `json:"has_more,omitempty"`    // 是否還有下一頁 (支援 include_total 的列表)
+	NextCursor string      `json:"next_cursor,omitempty"` // 下一頁的游標 (支援游標分頁的列表，以 cursor 參數傳回)，沒有下一頁時省略
+}
+
+// PageInfo 支援游標分頁與 include_total 的 Repository 列表查詢返回的分頁資訊
+type PageInfo struct {
+	Total      *int   // 符合條件的總筆數 (不受游標影響)，只在 Pagination.IncludeTotal 時計算
+	HasMore    bool   // 是否還有下一頁
+	NextCursor string // 下一頁的游標 (已編碼)，沒有下一頁時為空白
+}
+
+// NewPageResponse 以 Repository 返回的 PageInfo 建立分頁列表的響應
+func NewPageResponse(data interface{}, page, pageSize int, info PageInfo) *PaginatedResponse {
+	hasMore := info.HasMore
+	return &PaginatedResponse{Data: data, Total: info.Total, Page: page, PageSize: pageSize, HasMore: &hasMore, NextCursor: info.NextCursor}
+}
@@ -1,24 +1,304 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/wac0705/fastener-api/decimal"
+)
 
 // ProductCategory 產品類別模型
 type ProductCategory struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name" validate:"required,min=2,max=255"`
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           int               `json:"id"`
+	Name         string            `json:"name" validate:"required,min=2,max=255"`
+	Description  string            `json:"description,omitempty"`
+	ParentID     *int              `json:"parent_id,omitempty"` // 父類別 ID，NULL 表示根類別
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Children     []ProductCategory `json:"children,omitempty"`      // 唯讀，僅在樹狀查詢時返回
+	ProductCount *int              `json:"product_count,omitempty"` // 唯讀，include=counts 時返回，包含所有子孫類別的產品定義
+}
+
+// ProductCategoryListOptions 產品類別列表與類別樹的選項
+type ProductCategoryListOptions struct {
+	IncludeCounts       bool // 填入 ProductCount
+	IncludeDiscontinued bool // ProductCount 是否計入已停售的產品定義
+}
+
+// CategoryDeleteMode 刪除仍有子類別的產品類別時的處理方式
+type CategoryDeleteMode string
+
+const (
+	CategoryDeleteBlock   CategoryDeleteMode = "block"   // 有子類別時拒絕刪除 (預設)
+	CategoryDeleteCascade CategoryDeleteMode = "cascade" // 一併刪除所有子孫類別
+	CategoryDeleteDetach  CategoryDeleteMode = "detach"  // 子類別改為根類別後再刪除
+)
+
+// IsValid 檢查是否為支援的刪除方式
+func (m CategoryDeleteMode) IsValid() bool {
+	switch m {
+	case CategoryDeleteBlock, CategoryDeleteCascade, CategoryDeleteDetach:
+		return true
+	}
+	return false
 }
 
 // ProductDefinition 產品定義模型
 type ProductDefinition struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name" validate:"required,min=2,max=255"`
-	Description string    `json:"description,omitempty"`
-	CategoryID  int       `json:"category_id" validate:"required,min=1"`
-	Unit        string    `json:"unit,omitempty"`
-	Price       float64   `json:"price" validate:"required,min=0"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               int                 `json:"id"`
+	PublicID         string              `json:"public_id"`                                 // 唯讀，對外的 UUID 識別碼，可取代路徑中的 ID
+	SKU              string              `json:"sku,omitempty" validate:"omitempty,max=64"` // 料號，有填時必須唯一
+	Name             string              `json:"name" validate:"required,min=2,max=255"`
+	Description      string              `json:"description,omitempty"`
+	CategoryID       int                 `json:"category_id" validate:"required,min=1"`
+	CategoryName     string              `json:"category_name"`                                  // 唯讀，由查詢時 JOIN 類別取得
+	ParentID         *int                `json:"parent_definition_id,omitempty"`                 // 父產品定義 ID，非空時表示此產品為變體
+	VariantCount     *int                `json:"variant_count,omitempty"`                        // 唯讀，僅在 variants=collapse 時返回
+	Standard         string              `json:"standard,omitempty" validate:"omitempty,max=50"` // 標準代號，例如 "DIN 933"，儲存時正規化
+	Unit             string              `json:"unit,omitempty"`                                 // 標準單位 (例如 pcs)，其他單位的換算係數另存於 product_units
+	Price            decimal.Decimal     `json:"price" validate:"required,min=0"`                // 基準幣別 (PRODUCT_BASE_CURRENCY) 的價格，JSON 中為固定 4 位小數的字串
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+	DiscontinuedAt   *time.Time          `json:"discontinued_at,omitempty"` // 唯讀，刪除時設定的停售時間，停售的產品不出現在預設列表中
+	Discontinued     bool                `json:"discontinued"`              // 唯讀，DiscontinuedAt 非空時為 true
+	MatchRank        *float64            `json:"match_rank,omitempty"`      // 唯讀，僅在使用 search 參數時返回，越大越相關
+	QuotedPrice      *ProductQuotedPrice `json:"quoted_price,omitempty"`    // 唯讀，僅在使用 currency 參數時返回
+	ImageURL         string              `json:"image_url,omitempty"`       // 唯讀，有上傳圖片時為取得圖片的 API 路徑 (含版本參數，更換圖片後會改變)
+	ImageKey         string              `json:"-"`                         // 圖片在 FileStore 中的 key
+	ImageContentType string              `json:"-"`
+	ImageUpdatedAt   *time.Time          `json:"-"`
+	RecordActors                         // 唯讀，建立者與最後修改者
+}
+
+// VariantListMode 產品定義列表中變體的呈現方式
+type VariantListMode string
+
+const (
+	VariantListExpand   VariantListMode = "expand"   // 變體與其他產品一樣逐列列出 (預設)
+	VariantListCollapse VariantListMode = "collapse" // 只列出非變體的產品，並返回各自的變體數量
+)
+
+// IsValid 檢查是否為支援的呈現方式
+func (m VariantListMode) IsValid() bool {
+	switch m {
+	case VariantListExpand, VariantListCollapse:
+		return true
+	}
+	return false
+}
+
+// ProductVariantGenerateRequest 依長度範圍與間距產生變體的請求
+// 名稱與 SKU 以樣式產生，可用的佔位符為 {name}、{sku} (父產品的值) 與 {length}，兩個樣式都必須包含 {length}
+type ProductVariantGenerateRequest struct {
+	LengthFrom  float64 `json:"length_from" validate:"gt=0"`
+	LengthTo    float64 `json:"length_to" validate:"gtefield=LengthFrom"`
+	Step        float64 `json:"step" validate:"gt=0"`
+	NamePattern string  `json:"name_pattern" validate:"omitempty,max=255"` // 預設 "{name} x {length}"
+	SKUPattern  string  `json:"sku_pattern" validate:"omitempty,max=64"`   // 預設 "{sku}-{length}"
+}
+
+// ProductVariantSkipped 產生變體時因 SKU 已存在而略過的長度
+type ProductVariantSkipped struct {
+	Length     float64 `json:"length"`
+	SKU        string  `json:"sku"`
+	ExistingID int     `json:"existing_id"`
+}
+
+// ProductVariantGenerateResponse 產生變體的結果
+type ProductVariantGenerateResponse struct {
+	Created []ProductDefinition     `json:"created"`
+	Skipped []ProductVariantSkipped `json:"skipped"`
+}
+
+// ProductImage 產品圖片的中繼資料，內容另外以串流返回
+type ProductImage struct {
+	ProductID   int
+	ContentType string
+	Size        int64 // 未知時為 -1
+	ETag        string
+	UpdatedAt   time.Time
+}
+
+// ProductDefinitionCloneRequest 複製產品定義的請求，未指定 Name/SKU 時由系統產生
+type ProductDefinitionCloneRequest struct {
+	Name string `json:"name" validate:"omitempty,min=2,max=255"`
+	SKU  string `json:"sku" validate:"omitempty,max=64"`
+}
+
+// ProductPrice 產品定義在特定幣別的價格，自 ValidFrom 起生效
+type ProductPrice struct {
+	ID        int             `json:"id"`
+	ProductID int             `json:"product_id"`
+	Currency  string          `json:"currency" validate:"required,currency"` // ISO 4217 幣別代碼 (大寫)
+	Price     decimal.Decimal `json:"price" validate:"min=0"`
+	ValidFrom string          `json:"valid_from" validate:"omitempty,datetime=2006-01-02"` // 生效日 (YYYY-MM-DD)，未指定時為當天
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ProductQuotedPrice 以指定幣別查詢產品定義時返回的價格
+// Converted 為 false 表示沒有該幣別的有效價格，Price 為基準幣別的 price
+type ProductQuotedPrice struct {
+	Currency  string          `json:"currency"`
+	Price     decimal.Decimal `json:"price"`
+	ValidFrom string          `json:"valid_from,omitempty"`
+	Converted bool            `json:"converted"`
+}
+
+// ProductDefinitionFilter 產品定義列表的搜尋與排序條件
+type ProductDefinitionFilter struct {
+	Query               string           // 以 ILIKE 模糊比對名稱與描述
+	Search              string           // 全文搜尋，每個詞都需出現在名稱、描述、單位或標準代號中，結果依相關度排序
+	Sort                string           // 排序欄位 (白名單)，前綴 "-" 表示降序
+	CategoryID          *int             // 只返回該類別的產品定義
+	IncludeDescendants  bool             // 與 CategoryID 一起使用，同時包含所有子孫類別的產品定義
+	Standard            string           // 只返回該標準代號 (正規化後完全相符) 的產品定義
+	Currency            string           // 以該幣別報價並填入 QuotedPrice，不影響篩選
+	IncludeDiscontinued bool             // 是否包含已停售的產品定義
+	Variants            VariantListMode  // 變體的呈現方式，空值等同 expand
+	ParentID            *int             // 只返回該產品定義的變體
+	PriceMin            *decimal.Decimal // 基準幣別價格下限 (含)
+	PriceMax            *decimal.Decimal // 基準幣別價格上限 (含)
+	Unit                string           // 只返回該標準單位的產品定義 (不分大小寫)
+	HasImage            *bool            // true 只返回有圖片的產品定義，false 只返回沒有圖片的
+	SKUs                []string         // 只返回 SKU 在清單中的產品定義 (用於批次調整價格)
+}
+
+// PriceAdjustmentType 批次調整價格的方式
+type PriceAdjustmentType string
+
+const (
+	PriceAdjustmentPercent PriceAdjustmentType = "percent" // 依百分比調整，Value 為 4 表示漲 4%，-5 表示降 5%
+	PriceAdjustmentDelta   PriceAdjustmentType = "delta"   // 加上固定金額 (基準幣別)，可為負數
+)
+
+// IsValid 檢查調整方式是否為支援的值
+func (t PriceAdjustmentType) IsValid() bool {
+	return t == PriceAdjustmentPercent || t == PriceAdjustmentDelta
+}
+
+// ProductPriceAdjustment 批次價格調整的內容與捨入規則
+// Scale 與 Rounding 未指定時由服務層填入 PRICE_SCALE 與 PRICE_ROUNDING_MODE
+type ProductPriceAdjustment struct {
+	Type     PriceAdjustmentType  `json:"type" validate:"required"`
+	Value    decimal.Decimal      `json:"value"`
+	Scale    *int32               `json:"scale,omitempty" validate:"omitempty,min=0,max=4"` // 調整後價格捨入的小數位數
+	Rounding decimal.RoundingMode `json:"rounding,omitempty"`                               // half_up、half_even、down 或 up
+}
+
+// Apply 返回調整並捨入後的價格 (以 decimal.StorageScale 位小數表示)，呼叫前 Scale 必須已填入
+func (a ProductPriceAdjustment) Apply(price decimal.Decimal) decimal.Decimal {
+	adjusted := price.Add(a.Value)
+	if a.Type == PriceAdjustmentPercent {
+		adjusted = price.Add(price.Mul(a.Value).Shift(-2))
+	}
+	return adjusted.Round(*a.Scale, a.Rounding).Round(decimal.StorageScale, a.Rounding)
+}
+
+// ProductBulkPriceFilter 批次調整價格時選取產品定義的條件，至少需指定一個條件；已停售的產品不受影響
+type ProductBulkPriceFilter struct {
+	CategoryID         *int     `json:"category_id,omitempty" validate:"omitempty,min=1"`
+	IncludeDescendants bool     `json:"include_descendants"`                            // 與 CategoryID 一起使用，同時包含所有子孫類別
+	Standard           string   `json:"standard,omitempty" validate:"omitempty,max=50"` // 標準代號，正規化後比對 (例如 "din933")
+	SKUs               []string `json:"skus,omitempty" validate:"omitempty,max=1000,dive,required,max=64"`
+}
+
+// ProductBulkPriceUpdateRequest 批次調整產品定義基準價格的請求
+type ProductBulkPriceUpdateRequest struct {
+	Filter     ProductBulkPriceFilter `json:"filter"`
+	Adjustment ProductPriceAdjustment `json:"adjustment"`
+	DryRun     bool                   `json:"dry_run"` // 只預覽調整結果，不寫入
+}
+
+// ProductPriceChange 一個產品定義調整前後的價格
+type ProductPriceChange struct {
+	ProductID int             `json:"product_definition_id"`
+	SKU       string          `json:"sku,omitempty"`
+	Name      string          `json:"name"`
+	OldPrice  decimal.Decimal `json:"old_price"`
+	NewPrice  decimal.Decimal `json:"new_price"`
+}
+
+// ProductBulkPriceUpdateResponse 批次調整價格的結果，Samples 為前幾筆變更 (依 ID 排序)
+type ProductBulkPriceUpdateResponse struct {
+	DryRun   bool                 `json:"dry_run"`
+	Matched  int                  `json:"matched"`  // 符合條件的產品定義數量
+	Affected int                  `json:"affected"` // 價格實際改變的數量 (捨入後與原價相同的不更新)
+	Samples  []ProductPriceChange `json:"samples"`
+}
+
+// ProductPriceTier 產品依數量分級的單價 (基準幣別)，自 MinQty 起適用直到下一級
+type ProductPriceTier struct {
+	ID        int             `json:"id"`
+	ProductID int             `json:"product_id,omitempty"` // 若指定，必須與 URL 中的產品定義 ID 相同
+	MinQty    int             `json:"min_qty" validate:"min=1"`
+	Price     decimal.Decimal `json:"price" validate:"min=0"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ProductPriceTiersRequest 以整組取代產品數量分級價格的請求
+type ProductPriceTiersRequest struct {
+	Tiers []ProductPriceTier `json:"tiers" validate:"dive"`
+}
+
+// ProductPriceTiersResponse 產品數量分級價格，Warnings 為不阻擋儲存的提醒 (例如數量越多單價反而越高)
+type ProductPriceTiersResponse struct {
+	Tiers    []ProductPriceTier `json:"tiers"`
+	Warnings []string           `json:"warnings,omitempty"`
+}
+
+// ProductQuantityPrice 依購買數量解析出的單價
+// Tier 為適用的分級；沒有適用的分級時為 nil，UnitPrice 為產品定義的基準價格
+type ProductQuantityPrice struct {
+	ProductID int               `json:"product_id"`
+	Quantity  int               `json:"quantity"`
+	Currency  string            `json:"currency"`
+	UnitPrice decimal.Decimal   `json:"unit_price"`
+	Total     decimal.Decimal   `json:"total"` // UnitPrice × Quantity，依 PRICE_SCALE 與 PRICE_ROUNDING_MODE 捨入
+	Tier      *ProductPriceTier `json:"tier,omitempty"`
+}
+
+// ProductUnit 產品的換算單位，Factor 為 1 個該單位等於多少個標準單位 (產品定義的 Unit)
+// 例如標準單位為 pcs 時，kg 的 Factor 為每公斤的支數，per1000 的 Factor 為 1000
+type ProductUnit struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	Unit      string    `json:"unit" validate:"required,max=50"` // 儲存時轉為小寫
+	Factor    float64   `json:"factor" validate:"gt=0"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProductUnitsRequest 以整組取代產品換算單位的請求
+type ProductUnitsRequest struct {
+	Units []ProductUnit `json:"units" validate:"dive"`
+}
+
+// ProductUnitConversion 產品數量的單位換算結果
+type ProductUnitConversion struct {
+	ProductID     int     `json:"product_id"`
+	CanonicalUnit string  `json:"canonical_unit"`
+	From          string  `json:"from"`
+	To            string  `json:"to"`
+	Value         float64 `json:"value"`
+	Result        float64 `json:"result"`
+}
+
+// ProductDefinitionImportRow 匯入時的一列產品定義資料，以 SKU 比對既有產品
+// CategoryName 由服務層解析為 CategoryID；CategoryID 為 0 表示需要在匯入事務中建立該類別
+type ProductDefinitionImportRow struct {
+	Line         int    // 原始檔案中的行號
+	SKU          string `validate:"required,max=64"`
+	Name         string `validate:"required,min=2,max=255"`
+	Description  string
+	CategoryName string `validate:"required,min=2,max=255"`
+	CategoryID   int
+	Standard     string          `validate:"omitempty,max=50"`
+	Unit         string          `validate:"omitempty,max=50"`
+	Price        decimal.Decimal `validate:"required,min=0"` // 基準幣別的價格
+}
+
+// ProductDefinitionImportOptions 產品定義匯入的選項
+type ProductDefinitionImportOptions struct {
+	CreateCategories bool // 類別名稱不存在時自動建立
+	DryRun           bool // 只驗證並回報，不寫入
+	Partial          bool // 只略過失敗的列並寫入其餘各列；預設有任何一列失敗時整批不寫入
 }
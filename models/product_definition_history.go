@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// 產品定義歷史事件類型
+const (
+	ProductDefinitionEventCreated          = "created"            // 建立產品定義
+	ProductDefinitionEventUpdated          = "updated"            // 欄位變更，每個變更的欄位一筆
+	ProductDefinitionEventDiscontinued     = "discontinued"       // 停售，field 為 discontinued_at
+	ProductDefinitionEventReactivated      = "reactivated"        // 重新啟用，field 為 discontinued_at
+	ProductDefinitionEventBulkPriceUpdated = "bulk_price_updated" // 批次調整價格，field 為 price
+)
+
+// ProductDefinitionHistoryTimeLayout 歷史記錄中時間欄位 (discontinued_at) 的格式
+const ProductDefinitionHistoryTimeLayout = time.RFC3339Nano
+
+// ProductDefinitionHistory 產品定義的一筆變更記錄
+type ProductDefinitionHistory struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_definition_id"`
+	Event         string    `json:"event"`
+	Field         string    `json:"field,omitempty"` // created 事件沒有值
+	OldValue      *string   `json:"old_value"`       // 變更前的值，nil 表示原本為空
+	NewValue      *string   `json:"new_value"`       // 變更後的值，nil 表示清空
+	ActorID       *int      `json:"actor_id"`        // 執行變更的帳戶，帳戶刪除後為 nil
+	ActorUsername *string   `json:"actor_username"`  // 唯讀，由查詢時 JOIN 帳戶取得
+	CreatedAt     time.Time `json:"created_at"`
+}
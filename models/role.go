@@ -1,13 +1,24 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Role 角色模型
 type Role struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name" validate:"required,min=2,max=50,alphanum"` // 例如: "admin", "finance", "user"
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           int       `json:"id"`
+	PublicID     string    `json:"public_id"` // 唯讀，對外的 UUID 識別碼，可取代路徑中的 ID
+	Name         string    `json:"name" validate:"required,min=2,max=50,alphanum"` // 例如: "admin", "finance", "user"
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	RecordActors           // 唯讀，建立者與最後修改者
+}
+
+// RoleCloneRequest 複製角色 (含權限與選單) 的請求
+type RoleCloneRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=50,alphanum"` // 新角色的名稱
 }
 
 // Permission 權限模型
@@ -19,6 +30,15 @@ type Permission struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// PermissionDescription 由權限字串產生描述，例如 "customer:read_history" -> "Allow read history on customer"
+func PermissionDescription(permission string) string {
+	resource, action, found := strings.Cut(permission, ":")
+	if !found {
+		return "Allow " + permission
+	}
+	return fmt.Sprintf("Allow %s on %s", strings.ReplaceAll(action, "_", " "), strings.ReplaceAll(resource, "_", " "))
+}
+
 // RolePermission 角色與權限的關聯模型 (用於多對多關係)
 type RolePermission struct {
 	RoleID      int `json:"role_id" validate:"required,min=1"`
@@ -10,6 +10,11 @@ type RoleMenu struct {
 	UpdatedAt time.Time `json:"updated_at"` // 在關聯更新時自動設置 (如果需要)
 }
 
+// RoleMenusReplaceRequest 整組取代角色選單的請求，menu_ids 為空陣列表示移除所有選單
+type RoleMenusReplaceRequest struct {
+	MenuIDs []int `json:"menu_ids" validate:"required,dive,min=1"`
+}
+
 // 這個模型可能用於返回給前端，包含更多詳細資訊
 type RoleMenuDetail struct {
 	RoleID   int    `json:"role_id"`
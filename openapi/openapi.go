@@ -0,0 +1,456 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// Version 產生的文件所使用的 OpenAPI 版本
+const Version = "3.0.3"
+
+// bearerScheme 受保護路由使用的 securitySchemes 名稱
+const bearerScheme = "bearerAuth"
+
+// Info 文件的標題與版本 (info 區塊)
+type Info struct {
+	Title   string
+	Version string
+}
+
+// Parameter 查詢參數
+type Parameter struct {
+	Name        string
+	Type        string // string、integer、number 或 boolean，預設 string
+	Description string
+	Required    bool
+}
+
+// Operation 一個路由的文件，以 Key(method, path) 為 key 登記
+// Request 與 Response 為模型的零值 (例如 models.Account{})，由反射產生 JSON Schema
+type Operation struct {
+	Summary             string
+	Description         string
+	Tag                 string      // 分組，未設定時以 /api 與版本之後的第一段路徑為分組 (例如 customers)
+	Public              bool        // 不需 Bearer Token
+	Query               []Parameter // 查詢參數；Paginated 時自動加上 page、page_size、cursor 與 include_total
+	Request             interface{} // JSON 請求內容，nil 表示沒有
+	Upload              bool        // 請求為 multipart/form-data，檔案欄位為 "file"
+	Status              int         // 成功的狀態碼，預設 200
+	Response            interface{} // 成功回應的模型，nil 表示沒有內容
+	Location            bool        // 成功回應帶有指向新資源的 Location 標頭 (建立資源的 201 回應)
+	Paginated           bool        // 回應為 models.PaginatedResponse，Response 為 data 的元素
+	ResponseContentType string      // 非 JSON 的回應格式 (例如 text/csv)，內容以二進位表示
+	Deprecated          bool        // 已棄用的路徑 (例如無版本的 /api 別名)，operationId 加上 Deprecated 後綴以保持唯一
+	PublicIDParams      []string    // 也接受 UUID (資源的 public_id) 的路徑參數，文件中以整數或 UUID 表示
+}
+
+// Key 返回登記 Operation 時使用的 key，path 為 Echo 的路由樣板 (例如 /api/v1/accounts/:id)
+func Key(method, path string) string {
+	return method + " " + path
+}
+
+// MissingRoutes 返回已註冊但沒有登記文件的路由 (依 key 排序)，用於確保新增的路由都出現在文件中
+func MissingRoutes(routes []*echo.Route, docs map[string]Operation) []string {
+	missing := []string{}
+	for _, route := range documentableRoutes(routes) {
+		if _, ok := docs[Key(route.Method, route.Path)]; !ok {
+			missing = append(missing, Key(route.Method, route.Path))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// StaleOperations 返回有登記文件但沒有註冊的路由 (依 key 排序)，例如路由改名或移除後留下的文件
+func StaleOperations(routes []*echo.Route, docs map[string]Operation) []string {
+	registered := map[string]bool{}
+	for _, route := range documentableRoutes(routes) {
+		registered[Key(route.Method, route.Path)] = true
+	}
+	stale := []string{}
+	for key := range docs {
+		if !registered[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// documentableRoutes 過濾掉 Echo 內部的路由 (例如 RouteNotFound 的方法不是 HTTP 方法)，並去除重複
+func documentableRoutes(routes []*echo.Route) []*echo.Route {
+	seen := map[string]bool{}
+	result := []*echo.Route{}
+	for _, route := range routes {
+		key := Key(route.Method, route.Path)
+		if seen[key] || !isHTTPMethod(route.Method) {
+			continue
+		}
+		seen[key] = true
+		result = append(result, route)
+	}
+	return result
+}
+
+// isHTTPMethod 是否為文件中可以表示的 HTTP 方法
+func isHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// Build 以已註冊的路由與登記的文件產生 OpenAPI 文件；沒有登記的路由不會出現在文件中 (見 MissingRoutes)
+func Build(info Info, routes []*echo.Route, docs map[string]Operation) map[string]interface{} {
+	b := &builder{schemas: map[string]interface{}{}}
+	errorSchema := b.schemaOf(reflect.TypeOf(utils.CustomError{}))
+	b.schemaOf(reflect.TypeOf(models.PaginatedResponse{}))
+
+	paths := map[string]interface{}{}
+	for _, route := range documentableRoutes(routes) {
+		op, ok := docs[Key(route.Method, route.Path)]
+		if !ok {
+			continue
+		}
+		path, pathParams := convertPath(route.Path)
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = b.operation(route, op, pathParams, errorSchema)
+	}
+
+	return map[string]interface{}{
+		"openapi": Version,
+		"info":    map[string]interface{}{"title": info.Title, "version": info.Version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]interface{}{
+				bearerScheme: map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// convertPath 將 Echo 的路由樣板 (/accounts/:id) 轉換為 OpenAPI 的格式 (/accounts/{id})，並返回路徑參數名稱
+func convertPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	params := []string{}
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID 以 handler 的方法名稱作為 operationId，例如 handler.(*AccountHandler).GetAccounts-fm 為 GetAccounts
+func operationID(route *echo.Route) string {
+	name := strings.TrimSuffix(route.Name, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// defaultTag 以 /api 與版本 (例如 v1) 之後的第一段路徑作為分組，例如 /api/v1/customers/:id 為 customers
+func defaultTag(path string) string {
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api"), "/") {
+		if segment != "" && !isVersionSegment(segment) {
+			return segment
+		}
+	}
+	return "default"
+}
+
+// isVersionSegment 路徑片段是否為 API 版本，例如 v1、v2
+func isVersionSegment(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	for _, r := range segment[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// builder 產生文件時累積 components.schemas
+type builder struct {
+	schemas map[string]interface{}
+}
+
+// operation 產生一個路由的 Operation Object
+func (b *builder) operation(route *echo.Route, op Operation, pathParams []string, errorSchema map[string]interface{}) map[string]interface{} {
+	tag := op.Tag
+	if tag == "" {
+		tag = defaultTag(route.Path)
+	}
+	result := map[string]interface{}{
+		"operationId": operationID(route),
+		"summary":     op.Summary,
+		"tags":        []string{tag},
+	}
+	if op.Deprecated {
+		result["operationId"] = operationID(route) + "Deprecated"
+		result["deprecated"] = true
+	}
+	if op.Description != "" {
+		result["description"] = op.Description
+	}
+
+	parameters := []interface{}{}
+	for _, name := range pathParams {
+		typ := "string"
+		if strings.HasSuffix(strings.ToLower(name), "id") || strings.HasPrefix(name, "id") {
+			typ = "integer"
+		}
+		schema := map[string]interface{}{"type": typ}
+		if slices.Contains(op.PublicIDParams, name) {
+			schema = map[string]interface{}{"oneOf": []interface{}{
+				map[string]interface{}{"type": "integer"},
+				map[string]interface{}{"type": "string", "format": "uuid"},
+			}}
+		}
+		parameters = append(parameters, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": schema})
+	}
+	query := op.Query
+	if op.Paginated {
+		query = append([]Parameter{
+			{Name: "page", Type: "integer", Description: "頁碼，從 1 開始"},
+			{Name: "page_size", Type: "integer", Description: "每頁筆數"},
+			{Name: "cursor", Description: "游標 (不可與 page 同時使用)，傳入上一頁回應的 next_cursor 取得下一頁；支援游標分頁的列表才會返回 next_cursor"},
+			{Name: "include_total", Type: "boolean", Description: "是否計算符合條件的總筆數；支援的列表未要求時 total 為 null，以 has_more 判斷是否有下一頁"},
+		}, query...)
+	}
+	for _, p := range query {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		param := map[string]interface{}{"name": p.Name, "in": "query", "required": p.Required, "schema": map[string]interface{}{"type": typ}}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		parameters = append(parameters, param)
+	}
+	if len(parameters) > 0 {
+		result["parameters"] = parameters
+	}
+
+	switch {
+	case op.Upload:
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{"multipart/form-data": map[string]interface{}{"schema": map[string]interface{}{
+				"type":       "object",
+				"required":   []string{"file"},
+				"properties": map[string]interface{}{"file": map[string]interface{}{"type": "string", "format": "binary"}},
+			}}},
+		}
+	case op.Request != nil:
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{echo.MIMEApplicationJSON: map[string]interface{}{"schema": b.schemaOf(reflect.TypeOf(op.Request))}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if op.Location {
+		success["headers"] = map[string]interface{}{
+			echo.HeaderLocation: map[string]interface{}{"description": "新資源的路徑", "schema": map[string]interface{}{"type": "string"}},
+		}
+	}
+	switch {
+	case op.ResponseContentType != "":
+		success["content"] = map[string]interface{}{op.ResponseContentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}}
+	case op.Paginated:
+		success["content"] = map[string]interface{}{echo.MIMEApplicationJSON: map[string]interface{}{"schema": map[string]interface{}{
+			"allOf": []interface{}{
+				refSchema("PaginatedResponse"),
+				map[string]interface{}{"properties": map[string]interface{}{"data": map[string]interface{}{"type": "array", "items": b.schemaOf(reflect.TypeOf(op.Response))}}},
+			},
+		}}}
+	case op.Response != nil:
+		success["content"] = map[string]interface{}{echo.MIMEApplicationJSON: map[string]interface{}{"schema": b.schemaOf(reflect.TypeOf(op.Response))}}
+	}
+	result["responses"] = map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default": map[string]interface{}{
+			"description": "錯誤 (utils.CustomError)",
+			"content":     map[string]interface{}{echo.MIMEApplicationJSON: map[string]interface{}{"schema": errorSchema}},
+		},
+	}
+	if !op.Public {
+		result["security"] = []interface{}{map[string]interface{}{bearerScheme: []string{}}}
+	}
+	return result
+}
+
+// refSchema 返回指向 components.schemas 的 $ref
+func refSchema(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf 以反射產生類型的 JSON Schema；具名的 struct 登記在 components.schemas 並返回 $ref
+func (b *builder) schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case decimalType:
+		return map[string]interface{}{"type": "string", "pattern": `^-?\d+(\.\d+)?$`, "example": "12.5000"} // 見 decimal.Decimal.MarshalJSON
+	case rawJSONType:
+		return map[string]interface{}{} // 原樣輸出的 JSON，可以是任意值
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t) // 匿名 struct (例如登入回應) 直接內嵌
+		}
+		if _, ok := b.schemas[t.Name()]; !ok {
+			b.schemas[t.Name()] = map[string]interface{}{} // 先佔位，避免自我參照的類型 (樹狀結構) 無限遞迴
+			b.schemas[t.Name()] = b.structSchema(t)
+		}
+		return refSchema(t.Name())
+	}
+	return map[string]interface{}{} // interface{} 等任意值
+}
+
+// structSchema 依 json 標籤產生 struct 的屬性；validate 標籤的 required、min、max 與 oneof 轉換為對應的限制
+func (b *builder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" && !field.Anonymous {
+				continue // 未匯出的欄位
+			}
+			name, omitEmpty := jsonName(field)
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" {
+				embedded := field.Type
+				if embedded.Kind() == reflect.Ptr {
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct {
+					addFields(embedded) // 內嵌 struct 的欄位與外層同一層
+					continue
+				}
+			}
+			if name == "" {
+				name = field.Name
+			}
+			schema := b.schemaOf(field.Type)
+			if isRequired := applyValidation(schema, field); isRequired && !omitEmpty {
+				required = append(required, name)
+			}
+			properties[name] = schema
+		}
+	}
+	addFields(t)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonName 返回 json 標籤中的名稱與是否有 omitempty
+func jsonName(field reflect.StructField) (string, bool) {
+	parts := strings.Split(field.Tag.Get("json"), ",")
+	omitEmpty := false
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty
+}
+
+// applyValidation 將 validate 標籤轉換為 schema 的限制，返回欄位是否為必填
+// $ref 的 schema 不能加上其他限制，只判斷是否必填
+func applyValidation(schema map[string]interface{}, field reflect.StructField) bool {
+	isRequired := false
+	_, isRef := schema["$ref"]
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		name, value, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			isRequired = true
+		case "min", "max":
+			if isRef {
+				continue
+			}
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch schema["type"] {
+			case "string":
+				schema[name+"Length"] = int(n)
+			case "array":
+				schema[name+"Items"] = int(n)
+			case "integer", "number":
+				schema[map[string]string{"min": "minimum", "max": "maximum"}[name]] = n
+			}
+		case "oneof":
+			if !isRef && schema["type"] == "string" {
+				schema["enum"] = strings.Fields(value)
+			}
+		}
+	}
+	return isRequired
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -8,149 +9,261 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository/internal/queries"
 	"github.com/wac0705/fastener-api/utils"
 )
 
 // AccountRepository 定義帳戶資料庫操作介面
+// 以 db.TxManager.WithinTx 的 ctx 呼叫時，查詢在該事務中執行
 type AccountRepository interface {
-	Create(account *models.Account) error
-	FindAll() ([]models.Account, error)
-	FindByID(id int) (*models.Account, error)
-	FindByUsername(username string) (*models.Account, error)
-	Update(account *models.Account) error
-	Delete(id int) error
-	UpdatePassword(accountID int, hashedPassword string) error
-	UpdateAdminPassword(username, hashedPassword string) error // 專門為 resetadmin 工具提供的方法
+	Create(ctx context.Context, account *models.Account) error
+	FindAll(ctx context.Context, pagination utils.Pagination) ([]models.Account, models.PageInfo, error) // 依 ID 升序分頁 (支援游標)，返回分頁資訊
+	FindByID(ctx context.Context, id int, opts ...FindOptions) (*models.Account, error)                  // 預設不包含已刪除的帳戶
+	FindByPublicID(ctx context.Context, publicID string, opts ...FindOptions) (*models.Account, error)   // 以對外的 UUID 識別碼查詢，預設不包含已刪除的帳戶
+	FindByUsername(ctx context.Context, username string) (*models.Account, error)                        // 只查詢未刪除的帳戶 (登入驗證)
+	Update(ctx context.Context, account *models.Account) error
+	Delete(ctx context.Context, id int) error  // 軟刪除 (設定 deleted_at)
+	Restore(ctx context.Context, id int) error // 還原已軟刪除的帳戶，用戶名已被使用時返回 409
+	UpdatePassword(ctx context.Context, accountID int, hashedPassword string) error
+	UpdateAdminPassword(ctx context.Context, username, hashedPassword string) error // 專門為 resetadmin 工具提供的方法
 }
 
 // accountRepositoryImpl 實現 AccountRepository 介面
 type accountRepositoryImpl struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *zap.Logger
 }
 
 // NewAccountRepository 創建 AccountRepository 實例
-func NewAccountRepository(db *sql.DB) AccountRepository {
-	return &accountRepositoryImpl{db: db}
+func NewAccountRepository(db *sql.DB, logger *zap.Logger) AccountRepository {
+	return &accountRepositoryImpl{db: db, logger: logger}
 }
 
 // Create 創建新帳戶
-func (r *accountRepositoryImpl) Create(account *models.Account) error {
-	query := `INSERT INTO accounts (username, password, role_id) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`
-	err := r.db.QueryRow(query, account.Username, account.Password, account.RoleID).
-		Scan(&account.ID, &account.CreatedAt, &account.UpdatedAt)
+func (r *accountRepositoryImpl) Create(ctx context.Context, account *models.Account) error {
+	row, err := queriesOn(conn(ctx, r.db)).CreateAccount(ctx, queries.CreateAccountParams{
+		Username: account.Username,
+		Password: account.Password,
+		RoleID:   account.RoleID,
+	})
 	if err != nil {
-		zap.L().Error("Repository: Failed to create account", zap.Error(err), zap.String("username", account.Username))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create account", zap.Error(err), zap.String("username", account.Username))
+		// 與其他請求同時建立相同用戶名時，由唯一約束擋下
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
+		}
 		return fmt.Errorf("failed to create account: %w", err) // 包裝原始錯誤
 	}
+	account.ID, account.PublicID, account.CreatedAt, account.UpdatedAt = row.ID, row.PublicID, row.CreatedAt.Time, row.UpdatedAt.Time
 	return nil
 }
 
-// FindAll 獲取所有帳戶，並帶上角色名稱
-func (r *accountRepositoryImpl) FindAll() ([]models.Account, error) {
-	query := `SELECT a.id, a.username, a.role_id, r.name AS role_name, a.created_at, a.updated_at
-              FROM accounts a
-              JOIN roles r ON a.role_id = r.id`
-	rows, err := r.db.Query(query)
+// accountSoftDelete 帳戶的軟刪除欄位 (a.deleted_at)，見 softDeletable
+var accountSoftDelete = softDeletable{table: "accounts", alias: "a"}
+
+// accountColumns 動態查詢 (分頁) 帳戶時統一使用的欄位順序 (不含密碼)，需與 scanAccount
+// 及 internal/queries/account.sql 中 GetAccountByID 的欄位保持一致
+// 搭配 accountFrom 使用：a 為 accounts，r 為所屬角色
+const accountColumns = `a.id, a.public_id, a.username, a.role_id, r.name AS role_name, a.is_active, a.created_at, a.updated_at, a.deleted_at`
+
+// accountFrom 查詢帳戶時的 FROM 子句，JOIN 角色以取得角色名稱
+const accountFrom = ` FROM accounts a JOIN roles r ON a.role_id = r.id`
+
+// scanAccount 將一列查詢結果掃描為 Account
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var account models.Account
+	var deletedAt sql.NullTime
+	dest := []interface{}{
+		&account.ID,
+		&account.PublicID,
+		&account.Username,
+		&account.RoleID,
+		&account.RoleName,
+		&account.IsActive,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+		&deletedAt,
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		account.DeletedAt = &deletedAt.Time
+	}
+	return &account, nil
+}
+
+// accountFromRow 將 sqlc 產生的帳戶查詢結果轉為 Account
+// GetAccountByPublicIDRow 欄位相同，可直接轉換為 GetAccountByIDRow 後傳入
+func accountFromRow(row queries.GetAccountByIDRow) *models.Account {
+	return &models.Account{
+		ID:        row.ID,
+		PublicID:  row.PublicID,
+		Username:  row.Username,
+		RoleID:    row.RoleID,
+		RoleName:  row.RoleName,
+		IsActive:  row.IsActive,
+		CreatedAt: row.CreatedAt.Time,
+		UpdatedAt: row.UpdatedAt.Time,
+		DeletedAt: optionalTime(row.DeletedAt),
+	}
+}
+
+// FindAll 依 ID 升序分頁獲取未刪除的帳戶，並帶上角色名稱
+// 以游標分頁 (keyset，只依 a.id)，有下一頁時返回 NextCursor；總筆數只在 IncludeTotal 時於同一個查詢中計算 (見 totalColumn)
+func (r *accountRepositoryImpl) FindAll(ctx context.Context, pagination utils.Pagination) ([]models.Account, models.PageInfo, error) {
+	k, _ := newKeyset("", false, nil, "a.id")
+	where, clause, args, err := k.pageClause(" WHERE "+accountSoftDelete.active(), pagination, nil)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get all accounts", zap.Error(err))
-		return nil, fmt.Errorf("failed to get all accounts: %w", err)
+		return nil, models.PageInfo{}, err
+	}
+	query := `SELECT ` + accountColumns + k.selectColumn() + totalColumn(pagination) + accountFrom + where + clause
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all accounts", zap.Error(err))
+		return nil, models.PageInfo{}, fmt.Errorf("failed to get all accounts: %w", err)
 	}
 	defer rows.Close()
 
 	accounts := []models.Account{}
+	var page pageRows
 	for rows.Next() {
-		var account models.Account
-		if err := rows.Scan(&account.ID, &account.Username, &account.RoleID, &account.RoleName, &account.CreatedAt, &account.UpdatedAt); err != nil {
-			zap.L().Error("Repository: Failed to scan account data", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan account data: %w", err)
+		var columns pageColumns
+		account, err := scanAccount(columns.scanner(rows))
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan account data", zap.Int("row", len(accounts)+1), zap.Error(err))
+			return nil, models.PageInfo{}, fmt.Errorf("failed to scan account data at row %d: %w", len(accounts)+1, err)
 		}
-		accounts = append(accounts, account)
+		accounts = append(accounts, *account)
+		page.add(account.ID, columns)
 	}
-	return accounts, nil
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating account data", zap.Error(err))
+		return nil, models.PageInfo{}, fmt.Errorf("error iterating account data: %w", err)
+	}
+	n, info, err := page.finish(ctx, pagination, &k, r.count)
+	if err != nil {
+		return nil, models.PageInfo{}, err
+	}
+	return accounts[:n], info, nil
 }
 
-// FindByID 根據 ID 獲取帳戶，並帶上角色名稱
-func (r *accountRepositoryImpl) FindByID(id int) (*models.Account, error) {
-	query := `SELECT a.id, a.username, a.role_id, r.name AS role_name, a.created_at, a.updated_at
-              FROM accounts a
-              JOIN roles r ON a.role_id = r.id
-              WHERE a.id = $1`
-	row := r.db.QueryRow(query, id)
-	var account models.Account
-	if err := row.Scan(&account.ID, &account.Username, &account.RoleID, &account.RoleName, &account.CreatedAt, &account.UpdatedAt); err != nil {
+// count 計算未刪除的帳戶數量，供游標分頁或本頁沒有資料時計算總筆數
+func (r *accountRepositoryImpl) count(ctx context.Context) (int, error) {
+	total, err := queriesOn(conn(ctx, r.db)).CountAccounts(ctx)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count accounts", zap.Error(err))
+		return 0, fmt.Errorf("failed to count accounts: %w", err)
+	}
+	return int(total), nil
+}
+
+// FindByID 根據 ID 獲取帳戶，並帶上角色名稱；FindOptions.IncludeDeleted 時包含已軟刪除的帳戶
+func (r *accountRepositoryImpl) FindByID(ctx context.Context, id int, opts ...FindOptions) (*models.Account, error) {
+	row, err := queriesOn(conn(ctx, r.db)).GetAccountByID(ctx, queries.GetAccountByIDParams{ID: id, IncludeDeleted: includeDeleted(opts)})
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // 未找到
 		}
-		zap.L().Error("Repository: Failed to get account by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get account by ID", zap.Int("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get account by ID %d: %w", id, err)
 	}
-	return &account, nil
+	return accountFromRow(row), nil
 }
 
-// FindByUsername 根據用戶名獲取帳戶
-func (r *accountRepositoryImpl) FindByUsername(username string) (*models.Account, error) {
-	query := `SELECT a.id, a.username, a.password, a.role_id, r.name AS role_name, a.created_at, a.updated_at
-              FROM accounts a
-              JOIN roles r ON a.role_id = r.id
-              WHERE a.username = $1`
-	row := r.db.QueryRow(query, username)
-	var account models.Account
-	if err := row.Scan(&account.ID, &account.Username, &account.Password, &account.RoleID, &account.RoleName, &account.CreatedAt, &account.UpdatedAt); err != nil {
+// FindByPublicID 根據對外的 UUID 識別碼獲取帳戶，並帶上角色名稱；FindOptions.IncludeDeleted 時包含已軟刪除的帳戶
+func (r *accountRepositoryImpl) FindByPublicID(ctx context.Context, publicID string, opts ...FindOptions) (*models.Account, error) {
+	row, err := queriesOn(conn(ctx, r.db)).GetAccountByPublicID(ctx, queries.GetAccountByPublicIDParams{PublicID: publicID, IncludeDeleted: includeDeleted(opts)})
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // 未找到
 		}
-		zap.L().Error("Repository: Failed to get account by username", zap.String("username", username), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get account by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get account by public ID %s: %w", publicID, err)
+	}
+	return accountFromRow(queries.GetAccountByIDRow(row)), nil
+}
+
+// FindByUsername 根據用戶名獲取未刪除的帳戶 (含密碼雜湊，供登入驗證)；已刪除的帳戶無法登入
+func (r *accountRepositoryImpl) FindByUsername(ctx context.Context, username string) (*models.Account, error) {
+	row, err := queriesOn(conn(ctx, r.db)).GetAccountByUsername(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get account by username", zap.String("username", username), zap.Error(err))
 		return nil, fmt.Errorf("failed to get account by username %s: %w", username, err)
 	}
-	return &account, nil
+	account := accountFromRow(queries.GetAccountByIDRow{
+		ID:        row.ID,
+		PublicID:  row.PublicID,
+		Username:  row.Username,
+		RoleID:    row.RoleID,
+		RoleName:  row.RoleName,
+		IsActive:  row.IsActive,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+		DeletedAt: row.DeletedAt,
+	})
+	account.Password = row.Password
+	return account, nil
 }
 
 // Update 更新帳戶信息
-func (r *accountRepositoryImpl) Update(account *models.Account) error {
-	query := `UPDATE accounts SET username = $1, role_id = $2, updated_at = NOW() WHERE id = $3 RETURNING updated_at`
-	err := r.db.QueryRow(query, account.Username, account.RoleID, account.ID).Scan(&account.UpdatedAt)
+func (r *accountRepositoryImpl) Update(ctx context.Context, account *models.Account) error {
+	updatedAt, err := queriesOn(conn(ctx, r.db)).UpdateAccount(ctx, queries.UpdateAccountParams{
+		Username: account.Username,
+		RoleID:   account.RoleID,
+		ID:       account.ID,
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return utils.ErrNotFound // 未找到要更新的記錄
 		}
-		zap.L().Error("Repository: Failed to update account", zap.Error(err), zap.Int("id", account.ID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update account", zap.Error(err), zap.Int("id", account.ID))
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
+		}
 		return fmt.Errorf("failed to update account %d: %w", account.ID, err)
 	}
+	account.UpdatedAt = updatedAt.Time
 	return nil
 }
 
-// Delete 刪除帳戶
-func (r *accountRepositoryImpl) Delete(id int) error {
-	query := `DELETE FROM accounts WHERE id = $1`
-	res, err := r.db.Exec(query, id)
-	if err != nil {
-		zap.L().Error("Repository: Failed to delete account", zap.Error(err), zap.Int("id", id))
-		return fmt.Errorf("failed to delete account %d: %w", id, err)
-	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
-		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
+// Delete 軟刪除帳戶 (設定 deleted_at)，保留業務代表與歷史記錄的引用；已刪除的帳戶不再出現在查詢中，也無法登入
+func (r *accountRepositoryImpl) Delete(ctx context.Context, id int) error {
+	if err := accountSoftDelete.delete(ctx, conn(ctx, r.db), id, 0); err != nil { // 帳戶沒有 updated_by
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 未找到要刪除的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete account", zap.Error(err), zap.Int("id", id))
+		return err
 	}
-	if rowsAffected == 0 {
-		return utils.ErrNotFound // 未找到要刪除的記錄
+	return nil
+}
+
+// Restore 還原已軟刪除的帳戶；用戶名已被其他未刪除的帳戶使用時返回 409，需先解決衝突再還原
+func (r *accountRepositoryImpl) Restore(ctx context.Context, id int) error {
+	if err := accountSoftDelete.restore(ctx, conn(ctx, r.db), id, 0); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 未找到已刪除的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to restore account", zap.Error(err), zap.Int("id", id))
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr // 用戶名已被其他帳戶使用
+		}
+		return err
 	}
 	return nil
 }
 
 // UpdatePassword 更新帳戶密碼
-func (r *accountRepositoryImpl) UpdatePassword(accountID int, hashedPassword string) error {
-	query := `UPDATE accounts SET password = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`
-	res, err := r.db.Exec(query, hashedPassword, accountID)
-	if err != nil {
-		zap.L().Error("Repository: Failed to update password", zap.Error(err), zap.Int("account_id", accountID))
+func (r *accountRepositoryImpl) UpdatePassword(ctx context.Context, accountID int, hashedPassword string) error {
+	rowsAffected, err := queriesOn(conn(ctx, r.db)).UpdateAccountPassword(ctx, queries.UpdateAccountPasswordParams{Password: hashedPassword, ID: accountID})
+	if err != nil { // 包含無法取得影響行數的錯誤
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update password", zap.Error(err), zap.Int("account_id", accountID))
 		return fmt.Errorf("failed to update password for account %d: %w", accountID, err)
 	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after password update", zap.Error(err), zap.Int("account_id", accountID))
-		return fmt.Errorf("failed to check rows affected for password update %d: %w", accountID, err)
-	}
 	if rowsAffected == 0 {
 		return utils.ErrNotFound // 未找到要更新的記錄
 	}
@@ -158,18 +271,12 @@ func (r *accountRepositoryImpl) UpdatePassword(accountID int, hashedPassword str
 }
 
 // UpdateAdminPassword 專門用於重設管理員密碼的工具
-func (r *accountRepositoryImpl) UpdateAdminPassword(username, hashedPassword string) error {
-	query := `UPDATE accounts SET password = $1, updated_at = NOW() WHERE username = $2 AND role_id = (SELECT id FROM roles WHERE name = 'admin')`
-	res, err := r.db.Exec(query, hashedPassword, username)
-	if err != nil {
-		zap.L().Error("Repository: Failed to update admin password", zap.Error(err), zap.String("username", username))
+func (r *accountRepositoryImpl) UpdateAdminPassword(ctx context.Context, username, hashedPassword string) error {
+	rowsAffected, err := queriesOn(conn(ctx, r.db)).UpdateAdminPassword(ctx, queries.UpdateAdminPasswordParams{Password: hashedPassword, Username: username})
+	if err != nil { // 包含無法取得影響行數的錯誤
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update admin password", zap.Error(err), zap.String("username", username))
 		return fmt.Errorf("failed to update admin password for '%s': %w", username, err)
 	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after admin password update", zap.Error(err), zap.String("username", username))
-		return fmt.Errorf("failed to check rows affected for admin password update '%s': %w", username, err)
-	}
 	if rowsAffected == 0 {
 		return fmt.Errorf("admin account '%s' not found or not an admin role", username)
 	}
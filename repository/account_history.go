@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// AccountHistoryRepository 定義帳戶變更歷史資料庫操作介面
+// 應以 db.TxManager.WithinTx 的 ctx 呼叫，讓歷史記錄與帳戶變更在同一事務中寫入
+type AccountHistoryRepository interface {
+	Create(ctx context.Context, entry *models.AccountHistory) error
+}
+
+// accountHistoryRepositoryImpl 實現 AccountHistoryRepository 介面
+type accountHistoryRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewAccountHistoryRepository 創建 AccountHistoryRepository 實例
+func NewAccountHistoryRepository(db *sql.DB, logger *zap.Logger) AccountHistoryRepository {
+	return &accountHistoryRepositoryImpl{db: db, logger: logger}
+}
+
+// Create 寫入一筆帳戶歷史記錄，並填入 ID 與建立時間
+func (r *accountHistoryRepositoryImpl) Create(ctx context.Context, entry *models.AccountHistory) error {
+	query := `INSERT INTO account_history (account_id, event, field, old_value, new_value, actor_account_id) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6) RETURNING id, created_at`
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, entry.AccountID, entry.Event, entry.Field, entry.OldValue, entry.NewValue, entry.ActorID).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to insert account history", zap.Error(err), zap.Int("account_id", entry.AccountID), zap.String("event", entry.Event))
+		return fmt.Errorf("failed to insert account history for account %d: %w", entry.AccountID, err)
+	}
+	return nil
+}
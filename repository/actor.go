@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/wac0705/fastener-api/models"
+)
+
+// actorReturning 返回 RETURNING 子句中 created_by、建立者用戶名、updated_by 與修改者用戶名的欄位，以 actorDest 掃描
+// table 為 INSERT/UPDATE 的資料表名稱 (或 UPDATE 的別名)；RETURNING 不能 JOIN，用戶名以子查詢取得
+// 寫入後以這些欄位覆蓋 models.RecordActors，請求內容中的值不會返回
+func actorReturning(table string) string {
+	return fmt.Sprintf(`%[1]s.created_by, (SELECT username FROM accounts WHERE id = %[1]s.created_by), %[1]s.updated_by, (SELECT username FROM accounts WHERE id = %[1]s.updated_by)`, table)
+}
+
+// actorDest 返回掃描 created_by、建立者用戶名、updated_by 與修改者用戶名 (查詢欄位或 actorReturning) 的目標
+func actorDest(actors *models.RecordActors) []interface{} {
+	return []interface{}{&actors.CreatedBy, &actors.CreatedByUsername, &actors.UpdatedBy, &actors.UpdatedByUsername}
+}
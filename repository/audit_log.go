@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// AuditLogRepository 定義 API 稽核記錄資料庫操作介面
+type AuditLogRepository interface {
+	CreateBatch(ctx context.Context, entries []models.AuditLog) error                                                       // 以單一 INSERT 寫入多筆記錄
+	FindAll(ctx context.Context, filter models.AuditLogFilter, pagination utils.Pagination) ([]models.AuditLog, int, error) // 依時間由新到舊分頁
+	DeleteCreatedBefore(ctx context.Context, before time.Time, limit int) (int64, error)                                    // 刪除 before 之前的記錄，最多 limit 筆
+}
+
+// auditLogColumns 查詢稽核記錄時統一使用的欄位順序，需與 scanAuditLog 保持一致
+const auditLogColumns = `l.id, l.request_id, l.actor_account_id, a.username, l.method, l.route, l.path, l.entity_id, l.status, l.latency_ms, l.remote_ip, l.body, l.created_at`
+
+// auditLogFilterColumns 稽核記錄列表的篩選欄位對應的 SQL 欄位 (見 handler 的 auditLogQuerySpec)
+var auditLogFilterColumns = map[string]string{
+	"actor_id":   "l.actor_account_id",
+	"method":     "l.method",
+	"status":     "l.status",
+	"created_at": "l.created_at",
+}
+
+// scanAuditLog 將一列查詢結果掃描為 AuditLog，處理 NULLABLE 的欄位與執行者
+func scanAuditLog(row rowScanner) (*models.AuditLog, error) {
+	var entry models.AuditLog
+	var requestID, actorUsername, entityID, remoteIP sql.NullString
+	var actorID sql.NullInt64
+	var body []byte
+	if err := row.Scan(
+		&entry.ID,
+		&requestID,
+		&actorID,
+		&actorUsername,
+		&entry.Method,
+		&entry.Route,
+		&entry.Path,
+		&entityID,
+		&entry.Status,
+		&entry.LatencyMs,
+		&remoteIP,
+		&body,
+		&entry.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	entry.RequestID = requestID.String
+	entry.EntityID = entityID.String
+	entry.RemoteIP = remoteIP.String
+	entry.Body = body
+	if actorID.Valid {
+		entry.ActorID = new(int)
+		*entry.ActorID = int(actorID.Int64)
+	}
+	if actorUsername.Valid {
+		entry.ActorUsername = &actorUsername.String
+	}
+	return &entry, nil
+}
+
+// auditLogRepositoryImpl 實現 AuditLogRepository 介面
+type auditLogRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewAuditLogRepository 創建 AuditLogRepository 實例
+func NewAuditLogRepository(db *sql.DB, logger *zap.Logger) AuditLogRepository {
+	return &auditLogRepositoryImpl{db: db, logger: logger}
+}
+
+// CreateBatch 以單一 INSERT 寫入多筆稽核記錄，任一筆失敗時整批都不寫入
+func (r *auditLogRepositoryImpl) CreateBatch(ctx context.Context, entries []models.AuditLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	const columnCount = 11
+	values := make([]string, 0, len(entries))
+	args := make([]interface{}, 0, len(entries)*columnCount)
+	for _, entry := range entries {
+		placeholders := make([]string, columnCount)
+		for i := range placeholders {
+			placeholders[i] = fmt.Sprintf("$%d", len(args)+i+1)
+		}
+		// 空字串與沒有內容的 body 存為 NULL
+		placeholders[0] = "NULLIF(" + placeholders[0] + ", '')"
+		placeholders[5] = "NULLIF(" + placeholders[5] + ", '')"
+		placeholders[8] = "NULLIF(" + placeholders[8] + ", '')"
+		placeholders[9] = "NULLIF(" + placeholders[9] + ", '')::jsonb"
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		args = append(args, entry.RequestID, entry.ActorID, entry.Method, entry.Route, entry.Path, entry.EntityID,
+			entry.Status, entry.LatencyMs, entry.RemoteIP, string(entry.Body), entry.CreatedAt)
+	}
+
+	query := `INSERT INTO api_audit_log (request_id, actor_account_id, method, route, path, entity_id, status, latency_ms, remote_ip, body, created_at)
+              VALUES ` + strings.Join(values, ", ")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to insert audit log entries", zap.Error(err), zap.Int("count", len(entries)))
+		return fmt.Errorf("failed to insert %d audit log entries: %w", len(entries), err)
+	}
+	return nil
+}
+
+// buildAuditLogWhere 依篩選條件建立 WHERE 子句 (含關鍵字)，沒有條件時返回空字串
+func buildAuditLogWhere(filter models.AuditLogFilter) (string, []interface{}, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	criteria, args, err := filter.Criteria.Where(auditLogFilterColumns, args)
+	if err != nil {
+		return "", nil, err
+	}
+	if criteria != "" {
+		conditions = append(conditions, criteria)
+	}
+	if filter.PathPrefix != "" {
+		args = append(args, likeEscaper.Replace(filter.PathPrefix)+"%")
+		conditions = append(conditions, fmt.Sprintf("l.path LIKE $%d", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// FindAll 分頁獲取稽核記錄，依時間由新到舊
+func (r *auditLogRepositoryImpl) FindAll(ctx context.Context, filter models.AuditLogFilter, pagination utils.Pagination) ([]models.AuditLog, int, error) {
+	where, args, err := buildAuditLogWhere(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_audit_log l`+where, args...).Scan(&total); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count audit log entries", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count audit log entries: %w", err)
+	}
+
+	clause, args := pageClause("l.created_at DESC, l.id DESC", pagination, args)
+	query := `SELECT ` + auditLogColumns + `
+              FROM api_audit_log l
+              LEFT JOIN accounts a ON a.id = l.actor_account_id` + where + clause
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get audit log entries", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to get audit log entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLog{}
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan audit log entry", zap.Int("row", len(entries)+1), zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan audit log entry at row %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating audit log entries", zap.Error(err))
+		return nil, 0, fmt.Errorf("error iterating audit log entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+// DeleteCreatedBefore 刪除 before 之前建立的稽核記錄 (最舊的優先)，每次最多 limit 筆以免長時間鎖定資料表，返回刪除的筆數
+func (r *auditLogRepositoryImpl) DeleteCreatedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `DELETE FROM api_audit_log
+              WHERE id IN (SELECT id FROM api_audit_log WHERE created_at < $1 ORDER BY created_at LIMIT $2)`
+	res, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete expired audit log entries", zap.Error(err), zap.Time("before", before))
+		return 0, fmt.Errorf("failed to delete audit log entries before %s: %w", before.Format(time.RFC3339), err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after deleting audit log entries", zap.Error(err))
+		return 0, fmt.Errorf("failed to check deleted audit log entries: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxBatchParams PostgreSQL 單一語句可綁定的參數上限 (協定以 16 位元表示參數數量)
+const maxBatchParams = 65535
+
+// defaultBatchRows 每個多列 INSERT 語句最多的列數，避免單一語句過長
+const defaultBatchRows = 1000
+
+// batchRows 返回每個語句的列數：每列 columns 個參數，最多 maxRows 列且參數總數不超過 maxBatchParams
+func batchRows(columns, maxRows int) int {
+	if limit := maxBatchParams / columns; maxRows <= 0 || maxRows > limit {
+		return limit
+	}
+	return maxRows
+}
+
+// insertIgnoreBatch 以多列的 INSERT ... VALUES (...), (...) ON CONFLICT (conflict) DO NOTHING 寫入 rows，返回新增的筆數
+// 每個語句最多 defaultBatchRows 列 (見 batchRows)；rows 的每個元素依序對應 columns，已存在的列略過不計
+// 多個語句不在同一事務中，需要全部成功或全部回滾時以 db.TxManager.WithinTx 的 ctx 與 conn 取得的 exec 呼叫
+func insertIgnoreBatch(ctx context.Context, exec executor, table string, columns []string, conflict string, rows [][]interface{}) (int, error) {
+	size := batchRows(len(columns), defaultBatchRows)
+	created := 0
+	for start := 0; start < len(rows); start += size {
+		end := min(start+size, len(rows))
+		query, args := insertIgnoreStatement(table, columns, conflict, rows[start:end])
+		res, err := exec.ExecContext(ctx, query, args...)
+		if err != nil {
+			return created, fmt.Errorf("failed to insert rows %d-%d into %s: %w", start+1, end, table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return created, fmt.Errorf("failed to check inserted rows for %s: %w", table, err)
+		}
+		created += int(n)
+	}
+	return created, nil
+}
+
+// insertIgnoreStatement 產生一個多列 INSERT ... ON CONFLICT DO NOTHING 語句與其參數
+func insertIgnoreStatement(table string, columns []string, conflict string, rows [][]interface{}) (string, []interface{}) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j, value := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			args = append(args, value)
+			fmt.Fprintf(&sb, "$%d", len(args))
+		}
+		sb.WriteString(")")
+	}
+	fmt.Fprintf(&sb, " ON CONFLICT (%s) DO NOTHING", conflict)
+	return sb.String(), args
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+)
+
+// roleMenuStatement 匹配寫入 n 列 role_menus 的多列 INSERT (每個語句的參數從 $1 開始)
+func roleMenuStatement(n int) string {
+	return fmt.Sprintf(`^INSERT INTO role_menus \(role_id, menu_id\) VALUES \(\$1, \$2\)(, \(\$\d+, \$\d+\)){%d} ON CONFLICT \(role_id, menu_id\) DO NOTHING$`, n-1)
+}
+
+// TestInsertIgnoreBatchChunkBoundaries 每個語句最多 defaultBatchRows 列：剛好一個語句的列數、多一列與沒有資料列的情況
+func TestInsertIgnoreBatchChunkBoundaries(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   int
+		chunks []int // 每個語句的列數
+	}{
+		{name: "empty", rows: 0},
+		{name: "single row", rows: 1, chunks: []int{1}},
+		{name: "exactly one chunk", rows: defaultBatchRows, chunks: []int{defaultBatchRows}},
+		{name: "one row over a chunk", rows: defaultBatchRows + 1, chunks: []int{defaultBatchRows, 1}},
+		{name: "exactly two chunks", rows: 2 * defaultBatchRows, chunks: []int{defaultBatchRows, defaultBatchRows}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDB(t)
+			rows := make([][]interface{}, tt.rows)
+			for i := range rows {
+				rows[i] = []interface{}{1, i + 1}
+			}
+			want := 0
+			for _, n := range tt.chunks {
+				// 每個語句有一列已存在 (ON CONFLICT 略過)，不計入新增的筆數
+				mock.ExpectExec(roleMenuStatement(n)).WillReturnResult(sqlmock.NewResult(0, int64(n-1)))
+				want += n - 1
+			}
+
+			created, err := insertIgnoreBatch(context.Background(), database, "role_menus", []string{"role_id", "menu_id"}, "role_id, menu_id", rows)
+			if err != nil || created != want {
+				t.Fatalf("insertIgnoreBatch = %d, %v, want %d", created, err, want)
+			}
+		})
+	}
+}
+
+// TestInsertIgnoreBatchFailsMidway 第二個語句失敗時返回第一個語句已新增的筆數與失敗的列範圍
+func TestInsertIgnoreBatchFailsMidway(t *testing.T) {
+	database, mock := newMockDB(t)
+	rows := make([][]interface{}, defaultBatchRows+1)
+	for i := range rows {
+		rows[i] = []interface{}{1, i + 1}
+	}
+	mock.ExpectExec(roleMenuStatement(defaultBatchRows)).WillReturnResult(sqlmock.NewResult(0, defaultBatchRows))
+	mock.ExpectExec(roleMenuStatement(1)).WillReturnError(errConnectionReset)
+
+	created, err := insertIgnoreBatch(context.Background(), database, "role_menus", []string{"role_id", "menu_id"}, "role_id, menu_id", rows)
+	if !errors.Is(err, errConnectionReset) || created != defaultBatchRows {
+		t.Fatalf("insertIgnoreBatch = %d, %v, want %d and %v", created, err, defaultBatchRows, errConnectionReset)
+	}
+	if want := "failed to insert rows 1001-1001 into role_menus: connection reset by peer"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestBatchRows(t *testing.T) {
+	tests := []struct {
+		columns, maxRows, want int
+	}{
+		{columns: 2, maxRows: defaultBatchRows, want: defaultBatchRows},
+		{columns: 100, maxRows: defaultBatchRows, want: 655}, // 參數上限 65535 / 100
+		{columns: 2, maxRows: 0, want: maxBatchParams / 2},
+		{columns: 65535, maxRows: defaultBatchRows, want: 1},
+	}
+	for _, tt := range tests {
+		if got := batchRows(tt.columns, tt.maxRows); got != tt.want {
+			t.Errorf("batchRows(%d, %d) = %d, want %d", tt.columns, tt.maxRows, got, tt.want)
+		}
+	}
+}
+
+// customerRows 返回 n 列客戶查詢結果，欄位順序與 customerColumns 相同
+func customerRows(n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "public_id", "code", "name", "contact_person", "email", "phone", "phone_normalized",
+		"company_id", "company_name", "currency", "payment_terms", "status", "sales_rep_account_id", "sales_rep_username",
+		"created_at", "updated_at", "deleted_at", "last_note_at", "created_by", "created_by_username", "updated_by", "updated_by_username"})
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		rows.AddRow(i, fmt.Sprintf("00000000-0000-0000-0000-%012d", i), fmt.Sprintf("C%05d", i), "customer", "", "", "", nil,
+			nil, nil, "", "", "active", nil, nil, now, now, nil, nil, nil, nil, nil, nil)
+	}
+	return rows
+}
+
+// TestCustomerStreamAllBatchBoundaries 匯出逐批讀取：剛好一批、多一筆與沒有資料時呼叫 fn 的次數與每批的筆數
+func TestCustomerStreamAllBatchBoundaries(t *testing.T) {
+	const batchSize = 3
+	tests := []struct {
+		name    string
+		rows    int
+		batches []int
+	}{
+		{name: "empty", rows: 0},
+		{name: "exactly one batch", rows: batchSize, batches: []int{batchSize}},
+		{name: "one row over a batch", rows: batchSize + 1, batches: []int{batchSize, 1}},
+		{name: "exactly two batches", rows: 2 * batchSize, batches: []int{batchSize, batchSize}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDB(t)
+			mock.ExpectQuery(`SELECT cu\.id, .* FROM customers cu`).WillReturnRows(customerRows(tt.rows))
+
+			var batches []int
+			lastID := 0
+			err := NewCustomerRepository(database, nil, zap.NewNop()).StreamAll(context.Background(), models.CustomerFilter{}, batchSize, func(batch []models.Customer) error {
+				batches = append(batches, len(batch))
+				for _, customer := range batch {
+					if customer.ID != lastID+1 {
+						t.Errorf("customer %d after %d, want every row exactly once in order", customer.ID, lastID)
+					}
+					lastID = customer.ID
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("StreamAll: %v", err)
+			}
+			if !reflect.DeepEqual(batches, tt.batches) {
+				t.Errorf("batches = %v, want %v", batches, tt.batches)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -12,34 +13,95 @@ import (
 )
 
 // CompanyRepository 定義公司資料庫操作介面
+// 列表與依 ID 查詢在有唯讀副本時從副本讀取，ctx 以 db.WithPrimary 標記或在事務中時改用主庫
 type CompanyRepository interface {
-	Create(company *models.Company) error
-	FindAll() ([]models.Company, error)
-	FindByID(id int) (*models.Company, error)
-	Update(company *models.Company) error
-	Delete(id int) error
+	Create(ctx context.Context, company *models.Company, actorID int) error // actorID 為執行建立的帳戶，記錄在 created_by/updated_by (0 表示系統寫入，記錄為 NULL)
+	FindAll(ctx context.Context) ([]models.Company, error)
+	FindByID(ctx context.Context, id int, opts ...FindOptions) (*models.Company, error)                // 預設不包含已刪除 (含已合併) 的公司
+	FindByPublicID(ctx context.Context, publicID string, opts ...FindOptions) (*models.Company, error) // 以對外的 UUID 識別碼查詢，預設不包含已刪除的公司
+	FindByName(ctx context.Context, name string) (*models.Company, error)
+	FindByNameOrTaxID(ctx context.Context, name, taxID string) (*models.Company, error) // 匯入時用於比對既有公司
+	Update(ctx context.Context, company *models.Company, actorID int) error
+	// 以下寫入方法的 actorID 與 Merge 的 mergedBy 記錄在被修改的公司 (與客戶) 的 updated_by，0 表示系統寫入
+	Delete(ctx context.Context, id, actorID int) error                                                                           // 軟刪除 (設定 deleted_at)，子公司與客戶不再指向此公司
+	Restore(ctx context.Context, id, actorID int) error                                                                          // 還原已軟刪除的公司 (已合併的公司除外)
+	CountChildren(ctx context.Context, id int) (int, error)                                                                      // 計算直屬子公司數量
+	DeleteWithDescendants(ctx context.Context, id, actorID int) error                                                            // 遞迴軟刪除公司及其所有子孫公司
+	ImportBatch(ctx context.Context, rows []models.CompanyImportRow, actorID int, dryRun bool) ([]models.ImportRowResult, error) // 在單一事務中批次 upsert
+	Stats(ctx context.Context, pagination utils.Pagination, sort string) ([]models.CompanyStats, int, error)                     // 每間公司的客戶統計 (分頁)，返回總筆數
+	Merge(ctx context.Context, sourceID, targetID, mergedBy int) (*models.CompanyMergeResult, error)                             // 將來源公司合併到目標公司並軟刪除來源公司
+}
+
+// companySoftDelete 公司的軟刪除欄位 (deleted_at)，合併後的來源公司也以此標示，見 softDeletable
+var companySoftDelete = softDeletable{table: "companies", actors: true}
+
+// companyStatsSortColumns Stats 允許排序的欄位白名單，避免 SQL 注入
+var companyStatsSortColumns = map[string]string{
+	"company_id":               "c.id",
+	"name":                     "c.name",
+	"customer_count":           "customer_count",
+	"last_customer_created_at": "last_customer_created_at",
+}
+
+// companyColumns 查詢公司時統一使用的欄位順序，需與 scanCompany 保持一致 (別名 c，搭配 companyFrom)
+const companyColumns = `c.id, c.public_id, c.name, c.tax_id, c.country, c.currency, c.parent_company_id, c.created_at, c.updated_at, c.deleted_at, c.created_by, cb.username, c.updated_by, ub.username`
+
+// companyFrom 查詢公司的 FROM 子句，JOIN 建立者與最後修改者的帳戶以取得用戶名
+const companyFrom = ` FROM companies c LEFT JOIN accounts cb ON cb.id = c.created_by LEFT JOIN accounts ub ON ub.id = c.updated_by`
+
+// scanCompany 將一列查詢結果掃描為 Company，處理 NULLABLE 的 parent_company_id 與 deleted_at
+func scanCompany(row rowScanner) (*models.Company, error) {
+	var company models.Company
+	var parentID sql.NullInt64
+	var deletedAt sql.NullTime
+	dest := []interface{}{
+		&company.ID,
+		&company.PublicID,
+		&company.Name,
+		&company.TaxID,
+		&company.Country,
+		&company.Currency,
+		&parentID,
+		&company.CreatedAt,
+		&company.UpdatedAt,
+		&deletedAt,
+	}
+	if err := row.Scan(append(dest, actorDest(&company.RecordActors)...)...); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		company.ParentCompanyID = new(int)
+		*company.ParentCompanyID = int(parentID.Int64)
+	}
+	if deletedAt.Valid {
+		company.DeletedAt = &deletedAt.Time
+	}
+	return &company, nil
 }
 
 // companyRepositoryImpl 實現 CompanyRepository 介面
 type companyRepositoryImpl struct {
-	db *sql.DB
+	db     *sql.DB
+	reader *sql.DB // FindAll 與 FindByID 使用的唯讀副本，nil 時使用 db (見 readConn)
+	logger *zap.Logger
 }
 
-// NewCompanyRepository 創建 CompanyRepository 實例
-func NewCompanyRepository(db *sql.DB) CompanyRepository {
-	return &companyRepositoryImpl{db: db}
+// NewCompanyRepository 創建 CompanyRepository 實例，reader 為唯讀副本 (db.Reader())，沒有副本時可傳入 nil
+func NewCompanyRepository(db, reader *sql.DB, logger *zap.Logger) CompanyRepository {
+	return &companyRepositoryImpl{db: db, reader: reader, logger: logger}
 }
 
-// Create 創建新公司
-func (r *companyRepositoryImpl) Create(company *models.Company) error {
-	query := `INSERT INTO companies (name) VALUES ($1) RETURNING id, created_at, updated_at`
-	err := r.db.QueryRow(query, company.Name).
-		Scan(&company.ID, &company.CreatedAt, &company.UpdatedAt)
+// Create 創建新公司，建立者與最後修改者為 actorID
+func (r *companyRepositoryImpl) Create(ctx context.Context, company *models.Company, actorID int) error {
+	query := `INSERT INTO companies (name, tax_id, country, currency, parent_company_id, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($6, 0))
+              RETURNING id, public_id, created_at, updated_at, ` + actorReturning("companies")
+	err := r.db.QueryRowContext(ctx, query, company.Name, company.TaxID, company.Country, company.Currency, company.ParentCompanyID, actorID).
+		Scan(append([]interface{}{&company.ID, &company.PublicID, &company.CreatedAt, &company.UpdatedAt}, actorDest(&company.RecordActors)...)...)
 	if err != nil {
-		zap.L().Error("Repository: Failed to create company", zap.Error(err), zap.String("name", company.Name))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create company", zap.Error(err), zap.String("name", company.Name))
 		// 檢查是否是唯一約束衝突錯誤 (例如，公司名稱已存在)
-		if err.Error() == `pq: duplicate key value violates unique constraint "companies_name_key"` { // 這是 PostgreSQL 特有的錯誤訊息
-			return utils.ErrBadRequest.SetDetails("Company name already exists")
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
 		}
 		return fmt.Errorf("failed to create company: %w", err)
 	}
@@ -47,75 +109,456 @@ func (r *companyRepositoryImpl) Create(company *models.Company) error {
 }
 
 // FindAll 獲取所有公司
-func (r *companyRepositoryImpl) FindAll() ([]models.Company, error) {
-	query := `SELECT id, name, created_at, updated_at FROM companies`
-	rows, err := r.db.Query(query)
+func (r *companyRepositoryImpl) FindAll(ctx context.Context) ([]models.Company, error) {
+	query := `SELECT ` + companyColumns + companyFrom + ` WHERE ` + companySoftDelete.as("c").active() + ` ORDER BY c.id ASC`
+	rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, query)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get all companies", zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all companies", zap.Error(err))
 		return nil, fmt.Errorf("failed to get all companies: %w", err)
 	}
 	defer rows.Close()
 
 	companies := []models.Company{}
 	for rows.Next() {
-		var company models.Company
-		if err := rows.Scan(&company.ID, &company.Name, &company.CreatedAt, &company.UpdatedAt); err != nil {
-			zap.L().Error("Repository: Failed to scan company data", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan company data: %w", err)
+		company, err := scanCompany(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan company data", zap.Int("row", len(companies)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan company data at row %d: %w", len(companies)+1, err)
 		}
-		companies = append(companies, company)
+		companies = append(companies, *company)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating company data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating company data: %w", err)
 	}
 	return companies, nil
 }
 
-// FindByID 根據 ID 獲取公司
-func (r *companyRepositoryImpl) FindByID(id int) (*models.Company, error) {
-	query := `SELECT id, name, created_at, updated_at FROM companies WHERE id = $1`
-	row := r.db.QueryRow(query, id)
-	var company models.Company
-	if err := row.Scan(&company.ID, &company.Name, &company.CreatedAt, &company.UpdatedAt); err != nil {
+// FindByID 根據 ID 獲取公司；FindOptions.IncludeDeleted 時包含已軟刪除 (含已合併) 的公司
+func (r *companyRepositoryImpl) FindByID(ctx context.Context, id int, opts ...FindOptions) (*models.Company, error) {
+	query := `SELECT ` + companyColumns + companyFrom + ` WHERE c.id = $1` + companySoftDelete.as("c").and(includeDeleted(opts))
+	company, err := scanCompany(readConn(ctx, r.db, r.reader).QueryRowContext(ctx, query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // 未找到
 		}
-		zap.L().Error("Repository: Failed to get company by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get company by ID", zap.Int("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get company by ID %d: %w", id, err)
 	}
-	return &company, nil
+	return company, nil
+}
+
+// FindByPublicID 根據對外的 UUID 識別碼獲取公司；FindOptions.IncludeDeleted 時包含已軟刪除 (含已合併) 的公司
+func (r *companyRepositoryImpl) FindByPublicID(ctx context.Context, publicID string, opts ...FindOptions) (*models.Company, error) {
+	query := `SELECT ` + companyColumns + companyFrom + ` WHERE c.public_id = $1` + companySoftDelete.as("c").and(includeDeleted(opts))
+	company, err := scanCompany(readConn(ctx, r.db, r.reader).QueryRowContext(ctx, query, publicID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get company by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get company by public ID %s: %w", publicID, err)
+	}
+	return company, nil
+}
+
+// FindByName 根據名稱獲取公司
+func (r *companyRepositoryImpl) FindByName(ctx context.Context, name string) (*models.Company, error) {
+	query := `SELECT ` + companyColumns + companyFrom + ` WHERE c.name = $1` + companySoftDelete.as("c").and(false)
+	company, err := scanCompany(r.db.QueryRowContext(ctx, query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get company by name", zap.String("name", name), zap.Error(err))
+		return nil, fmt.Errorf("failed to get company by name %s: %w", name, err)
+	}
+	return company, nil
+}
+
+// FindByNameOrTaxID 根據名稱或統一編號 (非空時) 獲取公司，名稱相符者優先
+func (r *companyRepositoryImpl) FindByNameOrTaxID(ctx context.Context, name, taxID string) (*models.Company, error) {
+	company, err := scanCompany(r.db.QueryRowContext(ctx, findCompanyByNameOrTaxIDQuery, name, taxID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get company by name or tax ID", zap.String("name", name), zap.String("tax_id", taxID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get company by name %s or tax ID %s: %w", name, taxID, err)
+	}
+	return company, nil
 }
 
-// Update 更新公司信息
-func (r *companyRepositoryImpl) Update(company *models.Company) error {
-	query := `UPDATE companies SET name = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`
-	err := r.db.QueryRow(query, company.Name, company.ID).Scan(&company.UpdatedAt)
+// findCompanyByNameOrTaxIDQuery 匯入比對既有公司時使用的查詢 (名稱相符者優先)
+var findCompanyByNameOrTaxIDQuery = `SELECT ` + companyColumns + companyFrom + `
+              WHERE ` + companySoftDelete.as("c").active() + ` AND (c.name = $1 OR ($2 <> '' AND c.tax_id = $2))
+              ORDER BY (c.name = $1) DESC
+              LIMIT 1`
+
+// Update 更新公司信息，最後修改者為 actorID
+func (r *companyRepositoryImpl) Update(ctx context.Context, company *models.Company, actorID int) error {
+	query := `UPDATE companies SET name = $1, tax_id = $2, country = $3, currency = $4, parent_company_id = $5, updated_by = NULLIF($7, 0), updated_at = NOW() WHERE id = $6 AND deleted_at IS NULL
+              RETURNING created_at, updated_at, ` + actorReturning("companies")
+	err := r.db.QueryRowContext(ctx, query, company.Name, company.TaxID, company.Country, company.Currency, company.ParentCompanyID, company.ID, actorID).
+		Scan(append([]interface{}{&company.CreatedAt, &company.UpdatedAt}, actorDest(&company.RecordActors)...)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return utils.ErrNotFound // 未找到要更新的記錄
 		}
-		zap.L().Error("Repository: Failed to update company", zap.Error(err), zap.Int("id", company.ID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update company", zap.Error(err), zap.Int("id", company.ID))
 		// 檢查是否是唯一約束衝突錯誤
-		if err.Error() == `pq: duplicate key value violates unique constraint "companies_name_key"` {
-			return utils.ErrBadRequest.SetDetails("Company name already exists")
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
 		}
 		return fmt.Errorf("failed to update company %d: %w", company.ID, err)
 	}
 	return nil
 }
 
-// Delete 刪除公司
-func (r *companyRepositoryImpl) Delete(id int) error {
-	query := `DELETE FROM companies WHERE id = $1`
-	res, err := r.db.Exec(query, id)
+// Delete 軟刪除公司，並在同一事務中將其子公司與客戶改為不屬於任何公司 (與原本外鍵 ON DELETE SET NULL 的結果相同)
+// 還原公司時不會恢復這些關聯
+func (r *companyRepositoryImpl) Delete(ctx context.Context, id, actorID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		zap.L().Error("Repository: Failed to delete company", zap.Error(err), zap.Int("id", id))
-		return fmt.Errorf("failed to delete company %d: %w", id, err)
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for company delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	if err := companySoftDelete.delete(ctx, tx, id, actorID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 未找到要刪除的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete company", zap.Error(err), zap.Int("id", id))
+		return err
 	}
-	rowsAffected, err := res.RowsAffected()
+	if _, err := tx.ExecContext(ctx, `UPDATE companies SET parent_company_id = NULL, updated_by = NULLIF($2, 0), updated_at = NOW() WHERE parent_company_id = $1`, id, actorID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to detach child companies", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to detach child companies of %d: %w", id, err)
+	}
+	if err := detachCustomers(ctx, r.logger, tx, id, actorID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit company delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to commit company delete %d: %w", id, err)
+	}
+	return nil
+}
+
+// detachCustomers 將已刪除公司的客戶 (包含已刪除的客戶) 改為不屬於任何公司，actorID 記錄在客戶的 updated_by
+func detachCustomers(ctx context.Context, logger *zap.Logger, tx *sql.Tx, companyID, actorID int) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE customers SET company_id = NULL, updated_by = NULLIF($2, 0), updated_at = NOW() WHERE company_id = $1`, companyID, actorID); err != nil {
+		utils.ContextLogger(ctx, logger).Error("Repository: Failed to detach customers of deleted company", zap.Error(err), zap.Int("company_id", companyID))
+		return fmt.Errorf("failed to detach customers of company %d: %w", companyID, err)
+	}
+	return nil
+}
+
+// Restore 還原已軟刪除的公司；已合併的公司 (客戶已移到目標公司) 不可還原，名稱或統一編號已被使用時返回 409
+func (r *companyRepositoryImpl) Restore(ctx context.Context, id, actorID int) error {
+	var mergedInto sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `SELECT merged_into_company_id FROM companies WHERE id = $1 AND deleted_at IS NOT NULL`, id).Scan(&mergedInto)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
-		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到已刪除的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get deleted company for restore", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to get deleted company %d: %w", id, err)
+	}
+	if mergedInto.Valid {
+		return utils.ErrConflict.SetDetails("Merged companies cannot be restored")
+	}
+	if err := companySoftDelete.restore(ctx, r.db, id, actorID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to restore company", zap.Error(err), zap.Int("id", id))
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr // 名稱或統一編號已被其他公司使用
+		}
+		return err
+	}
+	return nil
+}
+
+// CountChildren 計算指定公司的直屬子公司數量
+func (r *companyRepositoryImpl) CountChildren(ctx context.Context, id int) (int, error) {
+	query := `SELECT COUNT(*) FROM companies WHERE parent_company_id = $1` + companySoftDelete.and(false)
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count child companies", zap.Error(err), zap.Int("id", id))
+		return 0, fmt.Errorf("failed to count child companies of %d: %w", id, err)
+	}
+	return count, nil
+}
+
+// DeleteWithDescendants 使用遞迴 CTE 軟刪除公司及其所有未刪除的子孫公司
+// 與 Delete 相同，關聯客戶的 company_id 會被清空；子孫公司之間的 parent_company_id 保留
+func (r *companyRepositoryImpl) DeleteWithDescendants(ctx context.Context, id, actorID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for company cascade delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	// 先取得整棵樹再逐一軟刪除，子孫公司的 deleted_at 設定後就無法再以未刪除的條件遞迴
+	treeQuery := `WITH RECURSIVE company_tree AS (
+                  SELECT id FROM companies WHERE id = $1 AND ` + companySoftDelete.active() + `
+                  UNION
+                  SELECT c.id FROM companies c
+                  JOIN company_tree ct ON c.parent_company_id = ct.id
+                  WHERE ` + companySoftDelete.as("c").active() + `
+              )
+              SELECT id FROM company_tree`
+	rows, err := tx.QueryContext(ctx, treeQuery, id)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get company tree for cascade delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to get company tree of %d: %w", id, err)
+	}
+	var ids []int
+	for rows.Next() {
+		var companyID int
+		if err := rows.Scan(&companyID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan company tree of %d: %w", id, err)
+		}
+		ids = append(ids, companyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating company tree of %d: %w", id, err)
 	}
-	if rowsAffected == 0 {
+	if len(ids) == 0 {
 		return utils.ErrNotFound // 未找到要刪除的記錄
 	}
+
+	for _, companyID := range ids {
+		if err := companySoftDelete.delete(ctx, tx, companyID, actorID); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete company with descendants", zap.Error(err), zap.Int("id", id), zap.Int("company_id", companyID))
+			return err
+		}
+		if err := detachCustomers(ctx, r.logger, tx, companyID, actorID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit company cascade delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to commit company cascade delete %d: %w", id, err)
+	}
 	return nil
 }
+
+// ImportBatch 在單一事務中依名稱或統一編號批次 upsert 公司
+// 比對到既有公司時更新名稱、統一編號、國家與幣別 (不變動父公司)，否則新增。
+// dryRun 為 true 時執行完全相同的寫入，最後回滾事務，因此報告結果 (包含約束衝突) 與實際匯入一致。
+// 任何一列寫入失敗都會回滾整個事務，錯誤中包含該列的行號。
+func (r *companyRepositoryImpl) ImportBatch(ctx context.Context, rows []models.CompanyImportRow, actorID int, dryRun bool) ([]models.ImportRowResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for company import", zap.Error(err))
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback() // 確保在函數返回前回滾，除非明確提交
+
+	results := make([]models.ImportRowResult, 0, len(rows))
+	for _, row := range rows {
+		company := row.Company
+		existing, err := scanCompany(tx.QueryRowContext(ctx, findCompanyByNameOrTaxIDQuery, company.Name, company.TaxID))
+		if err != nil && err != sql.ErrNoRows {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to match company during import", zap.Error(err), zap.Int("line", row.Line))
+			return nil, fmt.Errorf("line %d: failed to match existing company: %w", row.Line, err)
+		}
+
+		if existing != nil {
+			id := existing.ID
+			_, err = tx.ExecContext(ctx, `UPDATE companies SET name = $1, tax_id = $2, country = $3, currency = $4, updated_by = NULLIF($6, 0), updated_at = NOW() WHERE id = $5`,
+				company.Name, company.TaxID, company.Country, company.Currency, id, actorID)
+			if err != nil {
+				utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update company during import", zap.Error(err), zap.Int("line", row.Line), zap.Int("id", id))
+				return nil, fmt.Errorf("line %d: failed to update company %d: %w", row.Line, id, err)
+			}
+			results = append(results, models.ImportRowResult{Line: row.Line, Action: models.ImportActionUpdated, ID: &id})
+			continue
+		}
+
+		var id int
+		err = tx.QueryRowContext(ctx, `INSERT INTO companies (name, tax_id, country, currency, created_by, updated_by) VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($5, 0)) RETURNING id`,
+			company.Name, company.TaxID, company.Country, company.Currency, actorID).Scan(&id)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create company during import", zap.Error(err), zap.Int("line", row.Line))
+			return nil, fmt.Errorf("line %d: failed to create company: %w", row.Line, err)
+		}
+		result := models.ImportRowResult{Line: row.Line, Action: models.ImportActionCreated}
+		if !dryRun {
+			result.ID = &id // dry run 回滾後此 ID 不存在，不返回
+		}
+		results = append(results, result)
+	}
+
+	if dryRun {
+		return results, nil // 延遲的 Rollback 會丟棄所有寫入
+	}
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit company import", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit company import: %w", err)
+	}
+	return results, nil
+}
+
+// Stats 以單一聚合查詢獲取每間公司的客戶數與最近一位客戶的建立時間
+// sort 為白名單中的欄位名稱，前綴 "-" 表示降序；空字串時依公司 ID 升序
+func (r *companyRepositoryImpl) Stats(ctx context.Context, pagination utils.Pagination, sort string) ([]models.CompanyStats, int, error) {
+	orderBy, err := buildOrderBy(sort, companyStatsSortColumns, "c.id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// COUNT(*) OVER() 在 GROUP BY 之後計算，即公司總數，避免額外的 COUNT 查詢
+	query := `SELECT c.id, c.name, COUNT(cu.id) AS customer_count, MAX(cu.created_at) AS last_customer_created_at,
+                     COUNT(*) OVER() AS total
+              FROM companies c
+              LEFT JOIN customers cu ON cu.company_id = c.id AND ` + customerSoftDelete.active() + `
+              WHERE ` + companySoftDelete.as("c").active() + `
+              GROUP BY c.id, c.name`
+	clause, args := pageClause(orderBy, pagination, nil)
+	rows, err := r.db.QueryContext(ctx, query+clause, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get company stats", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to get company stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []models.CompanyStats{}
+	total := 0
+	for rows.Next() {
+		var stat models.CompanyStats
+		var lastCreatedAt sql.NullTime
+		if err := rows.Scan(&stat.CompanyID, &stat.Name, &stat.CustomerCount, &lastCreatedAt, &total); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan company stats", zap.Int("row", len(stats)+1), zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan company stats at row %d: %w", len(stats)+1, err)
+		}
+		if lastCreatedAt.Valid {
+			stat.LastCustomerCreatedAt = &lastCreatedAt.Time
+		}
+		stats = append(stats, stat)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating company stats", zap.Error(err))
+		return nil, 0, fmt.Errorf("error iterating company stats: %w", err)
+	}
+
+	// 頁碼超出範圍時查不到任何列，另外取得總數以保持分頁資訊正確
+	if len(stats) == 0 && pagination.Offset() > 0 {
+		if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM companies WHERE `+companySoftDelete.active()).Scan(&total); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count companies for stats", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to count companies: %w", err)
+		}
+	}
+	return stats, total, nil
+}
+
+// Merge 在單一事務中將來源公司合併到目標公司：
+// 將客戶與子公司改為指向目標公司，軟刪除來源公司，並寫入 company_merges 歷史紀錄。
+// 之後新增引用 companies 的資料表時，也需要在這裡將其改為指向目標公司。
+func (r *companyRepositoryImpl) Merge(ctx context.Context, sourceID, targetID, mergedBy int) (*models.CompanyMergeResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for company merge", zap.Error(err))
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback() // 確保在函數返回前回滾，除非明確提交
+
+	// 鎖定兩間公司，避免合併期間被其他請求修改或刪除
+	rows, err := tx.QueryContext(ctx, `SELECT id, deleted_at IS NOT NULL FROM companies WHERE id IN ($1, $2) FOR UPDATE`, sourceID, targetID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to lock companies for merge", zap.Error(err), zap.Int("source_id", sourceID), zap.Int("target_id", targetID))
+		return nil, fmt.Errorf("failed to lock companies for merge: %w", err)
+	}
+	deleted := make(map[int]bool, 2)
+	for rows.Next() {
+		var id int
+		var isDeleted bool
+		if err := rows.Scan(&id, &isDeleted); err != nil {
+			rows.Close()
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan locked company for merge", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan locked company: %w", err)
+		}
+		deleted[id] = isDeleted
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating locked companies for merge", zap.Error(err))
+		return nil, fmt.Errorf("error iterating locked companies: %w", err)
+	}
+
+	if isDeleted, ok := deleted[sourceID]; !ok || isDeleted {
+		return nil, utils.ErrNotFound.SetDetails("Source company not found")
+	}
+	if isDeleted, ok := deleted[targetID]; !ok {
+		return nil, utils.ErrNotFound.SetDetails("Target company not found")
+	} else if isDeleted {
+		return nil, utils.ErrBadRequest.SetDetails("Cannot merge into a deleted company")
+	}
+
+	// 目標公司若是來源公司的子孫，先將其提升到來源公司的父公司之下，避免改指向後形成循環
+	_, err = tx.ExecContext(ctx, `WITH RECURSIVE source_tree AS (
+                          SELECT id FROM companies WHERE parent_company_id = $1
+                          UNION
+                          SELECT c.id FROM companies c
+                          JOIN source_tree st ON c.parent_company_id = st.id
+                      )
+                      UPDATE companies
+                      SET parent_company_id = (SELECT parent_company_id FROM companies WHERE id = $1), updated_by = NULLIF($3, 0), updated_at = NOW()
+                      WHERE id = $2 AND id IN (SELECT id FROM source_tree)`, sourceID, targetID, mergedBy)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to detach target company from source hierarchy", zap.Error(err), zap.Int("source_id", sourceID), zap.Int("target_id", targetID))
+		return nil, fmt.Errorf("failed to detach target company from source hierarchy: %w", err)
+	}
+
+	result := &models.CompanyMergeResult{SourceCompanyID: sourceID, TargetCompanyID: targetID}
+
+	res, err := tx.ExecContext(ctx, `UPDATE customers SET company_id = $1, updated_by = NULLIF($3, 0), updated_at = NOW() WHERE company_id = $2`, targetID, sourceID, mergedBy)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to move customers during company merge", zap.Error(err), zap.Int("source_id", sourceID), zap.Int("target_id", targetID))
+		return nil, fmt.Errorf("failed to move customers: %w", err)
+	}
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check moved customers: %w", err)
+	}
+	result.MovedCustomers = int(moved)
+
+	res, err = tx.ExecContext(ctx, `UPDATE companies SET parent_company_id = $1, updated_by = NULLIF($3, 0), updated_at = NOW() WHERE parent_company_id = $2`, targetID, sourceID, mergedBy)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to move child companies during company merge", zap.Error(err), zap.Int("source_id", sourceID), zap.Int("target_id", targetID))
+		return nil, fmt.Errorf("failed to move child companies: %w", err)
+	}
+	moved, err = res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check moved child companies: %w", err)
+	}
+	result.MovedChildCompanies = int(moved)
+
+	_, err = tx.ExecContext(ctx, `UPDATE companies SET deleted_at = NOW(), merged_into_company_id = $1, parent_company_id = NULL, updated_by = NULLIF($3, 0), updated_at = NOW() WHERE id = $2`, targetID, sourceID, mergedBy)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to soft delete source company", zap.Error(err), zap.Int("source_id", sourceID))
+		return nil, fmt.Errorf("failed to delete source company %d: %w", sourceID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO company_merges (source_company_id, target_company_id, moved_customers, moved_child_companies, merged_by) VALUES ($1, $2, $3, $4, $5)`,
+		sourceID, targetID, result.MovedCustomers, result.MovedChildCompanies, mergedBy)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to record company merge history", zap.Error(err), zap.Int("source_id", sourceID), zap.Int("target_id", targetID))
+		return nil, fmt.Errorf("failed to record company merge: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit company merge", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit company merge: %w", err)
+	}
+	return result, nil
+}
@@ -1,169 +1,866 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository/internal/queries"
 	"github.com/wac0705/fastener-api/utils"
 )
 
 // CustomerRepository 定義客戶資料庫操作介面
+// 列表與依 ID 查詢在有唯讀副本時從副本讀取，ctx 以 db.WithPrimary 標記或在事務中時改用主庫
 type CustomerRepository interface {
-	Create(customer *models.Customer) error
-	FindAll() ([]models.Customer, error)
-	FindByID(id int) (*models.Customer, error)
-	Update(customer *models.Customer) error
-	Delete(id int) error
+	Create(ctx context.Context, customer *models.Customer, codePrefix string, actorID int, history []models.CustomerHistory) error      // 未指定 Code 時以 codePrefix 的序號產生；actorID 記錄在 created_by/updated_by
+	FindAll(ctx context.Context, filter models.CustomerFilter, pagination utils.Pagination) ([]models.Customer, models.PageInfo, error) // 分頁搜尋，返回分頁資訊 (總筆數、是否有下一頁與游標)
+	Count(ctx context.Context, filter models.CustomerFilter) (int, error)
+	StreamAll(ctx context.Context, filter models.CustomerFilter, batchSize int, fn func(batch []models.Customer) error) error                                                              // 逐批讀取，用於匯出
+	ImportBatch(ctx context.Context, rows []models.CustomerImportRow, onConflict models.ImportConflictMode, codePrefix string, actorID int, dryRun bool) ([]models.ImportRowResult, error) // 在單一事務中依 Email 批次 upsert；actorID 記錄在新增與更新的客戶
+	FindByID(ctx context.Context, id int, opts ...FindOptions) (*models.Customer, error)                                                                                                   // 預設不包含已刪除的客戶
+	FindByPublicID(ctx context.Context, publicID string, opts ...FindOptions) (*models.Customer, error)                                                                                    // 以對外的 UUID 識別碼查詢，預設不包含已刪除的客戶
+	FindByCode(ctx context.Context, code string) (*models.Customer, error)                                                                                                                 // 不分大小寫比對客戶代碼
+	FindByCompanyID(ctx context.Context, companyID int, includeDescendants bool) ([]models.Customer, error)                                                                                // includeDescendants 為 true 時包含所有子孫公司的客戶
+	FindByEmail(ctx context.Context, email string) (*models.Customer, error)                                                                                                               // 不分大小寫比對 Email
+	Update(ctx context.Context, customer *models.Customer, actorID int, history []models.CustomerHistory) error                                                                            // 在同一事務中寫入客戶歷史；actorID 記錄在 updated_by
+	Delete(ctx context.Context, id, actorID int, history []models.CustomerHistory) error                                                                                                   // 軟刪除 (設定 deleted_at)
+	Restore(ctx context.Context, id, actorID int, history []models.CustomerHistory) error                                                                                                  // 還原已軟刪除的客戶
+	// FindDuplicates 依 Email、正規化電話與名稱相似度找出可能重複的客戶
+	FindDuplicates(ctx context.Context, name, email, phoneNormalized string, nameThreshold float64, limit int) ([]models.CustomerDuplicateCandidate, error)
 }
 
+// customerSoftDelete 客戶的軟刪除欄位 (cu.deleted_at)，見 softDeletable
+var customerSoftDelete = softDeletable{table: "customers", alias: "cu", actors: true}
+
+// customerSortColumns 客戶列表排序欄位對應的 SQL 欄位 (允許的欄位由 Handler 的 query.Spec 宣告)
+var customerSortColumns = map[string]string{
+	"id":             "cu.id",
+	"code":           "cu.code",
+	"name":           "cu.name",
+	"contact_person": "cu.contact_person",
+	"email":          "cu.email",
+	"company_name":   "co.name",
+	"sales_rep":      "sr.username",
+	"status":         "cu.status",
+	"last_note_at":   "last_note_at",
+	"created_at":     "cu.created_at",
+	"updated_at":     "cu.updated_at",
+}
+
+// customerCursorColumns 可用於游標分頁的客戶排序欄位與其型別 (見 keyset)
+var customerCursorColumns = map[string]cursorColumn{
+	"id":             {Expr: "cu.id", Type: "integer"},
+	"code":           {Expr: "cu.code", Type: "text"},
+	"name":           {Expr: "cu.name", Type: "text"},
+	"contact_person": {Expr: "cu.contact_person", Type: "text"},
+	"email":          {Expr: "cu.email", Type: "text"},
+	"company_name":   {Expr: "co.name", Type: "text"},
+	"sales_rep":      {Expr: "sr.username", Type: "text"},
+	"status":         {Expr: "cu.status", Type: "text"},
+	"last_note_at":   {Expr: "(SELECT MAX(n.created_at) FROM customer_notes n WHERE n.customer_id = cu.id)", Type: "timestamptz"},
+	"created_at":     {Expr: "cu.created_at", Type: "timestamptz"},
+	"updated_at":     {Expr: "cu.updated_at", Type: "timestamptz"},
+}
+
+// customerFilterColumns 客戶列表通用篩選欄位 (models.CustomerFilter.Criteria) 對應的 SQL 欄位
+var customerFilterColumns = map[string]string{
+	"currency":      "cu.currency",
+	"payment_terms": "cu.payment_terms",
+	"created_at":    "cu.created_at",
+	"updated_at":    "cu.updated_at",
+}
+
+// customerColumns 動態查詢 (列表、搜尋、匯出) 客戶時統一使用的欄位順序，需與 scanCustomer
+// 及 internal/queries/customer.sql 中 GetCustomerByID 的欄位保持一致
+// 搭配 customerFrom 使用：cu 為 customers，co 為關聯的公司，sr 為業務代表帳戶，cb 與 ub 為建立者與最後修改者帳戶
+const customerColumns = `cu.id, cu.public_id, cu.code, cu.name, cu.contact_person, cu.email, cu.phone, cu.phone_normalized, cu.company_id, co.name, cu.currency, cu.payment_terms, cu.status, cu.sales_rep_account_id, sr.username, cu.created_at, cu.updated_at, cu.deleted_at,
+    (SELECT MAX(n.created_at) FROM customer_notes n WHERE n.customer_id = cu.id) AS last_note_at, cu.created_by, cb.username, cu.updated_by, ub.username`
+
+// customerFrom 查詢客戶時的 FROM 子句，LEFT JOIN 公司、業務代表、建立者與最後修改者以取得名稱
+const customerFrom = ` FROM customers cu LEFT JOIN companies co ON co.id = cu.company_id LEFT JOIN accounts sr ON sr.id = cu.sales_rep_account_id
+    LEFT JOIN accounts cb ON cb.id = cu.created_by LEFT JOIN accounts ub ON ub.id = cu.updated_by`
+
+// scanCustomer 將一列查詢結果掃描為 Customer，處理 NULLABLE 的 company_id 與公司名稱
+func scanCustomer(row rowScanner) (*models.Customer, error) {
+	var customer models.Customer
+	var companyID sql.NullInt64
+	var companyName sql.NullString
+	var phoneNormalized sql.NullString
+	var salesRepID sql.NullInt64
+	var salesRepUsername sql.NullString
+	var deletedAt sql.NullTime
+	var lastNoteAt sql.NullTime
+	dest := []interface{}{
+		&customer.ID,
+		&customer.PublicID,
+		&customer.Code,
+		&customer.Name,
+		&customer.ContactPerson,
+		&customer.Email,
+		&customer.Phone,
+		&phoneNormalized,
+		&companyID,
+		&companyName,
+		&customer.Currency,
+		&customer.PaymentTerms,
+		&customer.Status,
+		&salesRepID,
+		&salesRepUsername,
+		&customer.CreatedAt,
+		&customer.UpdatedAt,
+		&deletedAt,
+		&lastNoteAt,
+	}
+	if err := row.Scan(append(dest, actorDest(&customer.RecordActors)...)...); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		customer.DeletedAt = &deletedAt.Time
+	}
+	if lastNoteAt.Valid {
+		customer.LastNoteAt = &lastNoteAt.Time
+	}
+	if companyID.Valid {
+		customer.CompanyID = new(int)
+		*customer.CompanyID = int(companyID.Int64)
+	}
+	if companyName.Valid {
+		customer.CompanyName = &companyName.String
+	}
+	customer.PhoneNormalized = phoneNormalized.String
+	if salesRepID.Valid {
+		customer.SalesRepAccountID = new(int)
+		*customer.SalesRepAccountID = int(salesRepID.Int64)
+	}
+	if salesRepUsername.Valid {
+		customer.SalesRepUsername = &salesRepUsername.String
+	}
+	return &customer, nil
+}
+
+// customerFromRow 將 sqlc 產生的客戶查詢結果轉為 Customer
+// GetCustomerByPublicIDRow 欄位相同，可直接轉換為 GetCustomerByIDRow 後傳入
+func customerFromRow(row queries.GetCustomerByIDRow) *models.Customer {
+	return &models.Customer{
+		ID:                row.ID,
+		PublicID:          row.PublicID,
+		Code:              row.Code,
+		Name:              row.Name,
+		ContactPerson:     row.ContactPerson.String,
+		Email:             row.Email.String,
+		Phone:             row.Phone.String,
+		PhoneNormalized:   row.PhoneNormalized.String,
+		CompanyID:         optionalInt(row.CompanyID),
+		CompanyName:       optionalString(row.CompanyName),
+		Currency:          row.Currency,
+		PaymentTerms:      row.PaymentTerms,
+		Status:            row.Status,
+		SalesRepAccountID: optionalInt(row.SalesRepAccountID),
+		SalesRepUsername:  optionalString(row.SalesRepUsername),
+		CreatedAt:         row.CreatedAt.Time,
+		UpdatedAt:         row.UpdatedAt.Time,
+		DeletedAt:         optionalTime(row.DeletedAt),
+		LastNoteAt:        optionalTime(row.LastNoteAt),
+		RecordActors:      recordActors(row.CreatedBy, row.CreatedByUsername, row.UpdatedBy, row.UpdatedByUsername),
+	}
+}
+
+// customerEmailConstraint 客戶 Email 唯一索引名稱 (見 000005_customer_email_unique)
+const customerEmailConstraint = "customers_email_lower_key"
+
 // customerRepositoryImpl 實現 CustomerRepository 介面
 type customerRepositoryImpl struct {
-	db *sql.DB
+	db     *sql.DB
+	reader *sql.DB // 列表、計數、匯出與依 ID 查詢使用的唯讀副本，nil 時使用 db (見 readConn)
+	logger *zap.Logger
 }
 
-// NewCustomerRepository 創建 CustomerRepository 實例
-func NewCustomerRepository(db *sql.DB) CustomerRepository {
-	return &customerRepositoryImpl{db: db}
+// NewCustomerRepository 創建 CustomerRepository 實例，reader 為唯讀副本 (db.Reader())，沒有副本時可傳入 nil
+func NewCustomerRepository(db, reader *sql.DB, logger *zap.Logger) CustomerRepository {
+	return &customerRepositoryImpl{db: db, reader: reader, logger: logger}
+}
+
+// customerCodeMaxAttempts 產生客戶代碼時遇到已被使用 (例如匯入時指定) 的代碼最多重試次數
+const customerCodeMaxAttempts = 100
+
+// codeQueryer 抽象 *sql.DB 與 *sql.Tx 共有的 QueryRowContext 方法
+type codeQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// nextCustomerCode 從 prefix 的序號取得下一個未被使用的客戶代碼，格式為 "<prefix>-000123"
+// 序號以 upsert 遞增，並發建立時由資料列鎖保證不會取得相同序號
+func nextCustomerCode(ctx context.Context, logger *zap.Logger, q codeQueryer, prefix string) (string, error) {
+	for attempt := 0; attempt < customerCodeMaxAttempts; attempt++ {
+		var n int64
+		err := q.QueryRowContext(ctx, `INSERT INTO customer_code_sequences (prefix, last_value) VALUES ($1, 1)
+                           ON CONFLICT (prefix) DO UPDATE SET last_value = customer_code_sequences.last_value + 1
+                           RETURNING last_value`, prefix).Scan(&n)
+		if err != nil {
+			utils.ContextLogger(ctx, logger).Error("Repository: Failed to advance customer code sequence", zap.Error(err), zap.String("prefix", prefix))
+			return "", fmt.Errorf("failed to advance customer code sequence %s: %w", prefix, err)
+		}
+		code := fmt.Sprintf("%s-%06d", prefix, n)
+
+		var exists bool
+		if err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE code = $1)`, code).Scan(&exists); err != nil {
+			utils.ContextLogger(ctx, logger).Error("Repository: Failed to check customer code", zap.Error(err), zap.String("code", code))
+			return "", fmt.Errorf("failed to check customer code %s: %w", code, err)
+		}
+		if !exists {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("failed to find an unused customer code for prefix %s after %d attempts", prefix, customerCodeMaxAttempts)
 }
 
-// Create 創建新客戶
-func (r *customerRepositoryImpl) Create(customer *models.Customer) error {
-	query := `INSERT INTO customers (name, contact_person, email, phone, company_id) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
-	err := r.db.QueryRow(query,
+// Create 創建新客戶，並在同一事務中寫入 history (CustomerID 由新客戶的 ID 填入)
+// customer.Code 為空時以 codePrefix 自動產生；建立者與最後修改者為 actorID
+func (r *customerRepositoryImpl) Create(ctx context.Context, customer *models.Customer, codePrefix string, actorID int, history []models.CustomerHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for customer create", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	if customer.Code == "" {
+		code, err := nextCustomerCode(ctx, r.logger, tx, codePrefix)
+		if err != nil {
+			return err
+		}
+		customer.Code = code
+	}
+
+	query := `INSERT INTO customers (code, name, contact_person, email, phone, phone_normalized, company_id, currency, payment_terms, status, sales_rep_account_id, created_by, updated_by)
+              VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, NULLIF($12, 0), NULLIF($12, 0)) RETURNING id, public_id, created_at, updated_at, ` + actorReturning("customers")
+	err = tx.QueryRowContext(ctx, query,
+		customer.Code,
 		customer.Name,
 		customer.ContactPerson,
 		customer.Email,
 		customer.Phone,
+		customer.PhoneNormalized,
 		customer.CompanyID,
-	).Scan(&customer.ID, &customer.CreatedAt, &customer.UpdatedAt)
+		customer.Currency,
+		customer.PaymentTerms,
+		customer.Status,
+		customer.SalesRepAccountID,
+		actorID,
+	).Scan(append([]interface{}{&customer.ID, &customer.PublicID, &customer.CreatedAt, &customer.UpdatedAt}, actorDest(&customer.RecordActors)...)...)
 	if err != nil {
-		zap.L().Error("Repository: Failed to create customer", zap.Error(err), zap.String("name", customer.Name))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create customer", zap.Error(err), zap.String("name", customer.Name))
+		if conflictErr := r.emailConflictError(ctx, err, customer.Email); conflictErr != nil {
+			return conflictErr
+		}
 		return fmt.Errorf("failed to create customer: %w", err)
 	}
+
+	for i := range history {
+		history[i].CustomerID = customer.ID
+	}
+	if err := insertCustomerHistory(ctx, r.logger, tx, history); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit customer create", zap.Error(err), zap.String("name", customer.Name))
+		return fmt.Errorf("failed to commit customer create: %w", err)
+	}
 	return nil
 }
 
-// FindAll 獲取所有客戶
-func (r *customerRepositoryImpl) FindAll() ([]models.Customer, error) {
-	query := `SELECT id, name, contact_person, email, phone, company_id, created_at, updated_at FROM customers`
-	rows, err := r.db.Query(query)
+// buildCustomerWhere 依篩選條件組合 WHERE 子句與參數 (別名 cu 為 customers)
+func buildCustomerWhere(filter models.CustomerFilter) (string, []interface{}, error) {
+	// 動態組合 WHERE 條件，所有使用者輸入都透過參數佔位符傳入
+	conditions := []string{}
+	args := []interface{}{}
+	conditions = customerSoftDelete.conditions(conditions, filter.IncludeDeleted)
+	if filter.Query != "" {
+		// 電話同時比對原始輸入與移除分隔字元後的正規化值，"02-1234" 與 "021234" 都能找到
+		// 每個欄位都有 pg_trgm 的部分索引 (000040_search_trgm_indexes)，新增比對欄位時需一併建立索引，否則 OR 條件會退回整張表掃描
+		args = append(args, containsPattern(filter.Query), containsPattern(utils.StripPhoneSeparators(filter.Query)))
+		n := len(args) - 1
+		conditions = append(conditions, fmt.Sprintf("(cu.name ILIKE $%d OR cu.contact_person ILIKE $%d OR cu.email ILIKE $%d OR cu.phone ILIKE $%d OR cu.phone_normalized ILIKE $%d)", n, n, n, n, n+1))
+	}
+	switch {
+	case filter.NoCompany:
+		conditions = append(conditions, "cu.company_id IS NULL")
+	case filter.CompanyID != nil && filter.IncludeDescendants:
+		args = append(args, *filter.CompanyID)
+		conditions = append(conditions, fmt.Sprintf(`cu.company_id IN (
+                     WITH RECURSIVE company_tree AS (
+                         SELECT id FROM companies WHERE id = $%d
+                         UNION
+                         SELECT c.id FROM companies c
+                         JOIN company_tree ct ON c.parent_company_id = ct.id
+                     )
+                     SELECT id FROM company_tree)`, len(args)))
+	case filter.CompanyID != nil:
+		args = append(args, *filter.CompanyID)
+		conditions = append(conditions, fmt.Sprintf("cu.company_id = $%d", len(args)))
+	}
+	if filter.SalesRepAccountID != nil {
+		args = append(args, *filter.SalesRepAccountID)
+		conditions = append(conditions, fmt.Sprintf("cu.sales_rep_account_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("cu.status = $%d", len(args)))
+	}
+	criteria, args, err := filter.Criteria.Where(customerFilterColumns, args)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get all customers", zap.Error(err))
-		return nil, fmt.Errorf("failed to get all customers: %w", err)
+		return "", nil, err
+	}
+	if criteria != "" {
+		conditions = append(conditions, criteria)
+	}
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// FindAll 依篩選條件分頁獲取客戶，並返回分頁資訊
+// pagination.PageSize 為 0 時不分頁；排序最多一個欄位時以游標分頁 (keyset)，有下一頁時返回 NextCursor，
+// 多個排序欄位時只支援 page (提供 cursor 時返回 400)；總筆數只在 IncludeTotal 時於同一個查詢中計算 (見 totalColumn)
+func (r *customerRepositoryImpl) FindAll(ctx context.Context, filter models.CustomerFilter, pagination utils.Pagination) ([]models.Customer, models.PageInfo, error) {
+	where, args, err := buildCustomerWhere(filter)
+	if err != nil {
+		return nil, models.PageInfo{}, err
+	}
+
+	var k keyset
+	ok := len(filter.Criteria.Sorts) == 0
+	if len(filter.Criteria.Sorts) == 1 {
+		k, ok = newKeyset(filter.Criteria.Sorts[0].Field, filter.Criteria.Sorts[0].Desc, customerCursorColumns, "cu.id")
+	}
+	if !ok && pagination.Cursor != "" {
+		return nil, models.PageInfo{}, utils.NewInvalidParamError("cursor", "cannot be combined with multiple sort fields")
+	}
+
+	var query string
+	if ok {
+		var clause string
+		where, clause, args, err = k.pageClause(where, pagination, args)
+		if err != nil {
+			return nil, models.PageInfo{}, err
+		}
+		query = `SELECT ` + customerColumns + k.selectColumn() + totalColumn(pagination) + customerFrom + where + clause
+	} else {
+		orderBy, err := filter.Criteria.OrderBy(customerSortColumns, "cu.id")
+		if err != nil {
+			return nil, models.PageInfo{}, err
+		}
+		var clause string
+		clause, args = pageProbeClause(orderBy, pagination, args)
+		query = `SELECT ` + customerColumns + `, NULL::text AS cursor_value` + totalColumn(pagination) + customerFrom + where + clause
+	}
+	rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all customers", zap.Error(err))
+		return nil, models.PageInfo{}, fmt.Errorf("failed to get all customers: %w", err)
 	}
 	defer rows.Close()
 
 	customers := []models.Customer{}
+	var page pageRows
 	for rows.Next() {
-		var customer models.Customer
-		// 注意這裡對 company_id 的處理，因為它是 NULLABLE
-		var companyID sql.NullInt64
-		if err := rows.Scan(
-			&customer.ID,
-			&customer.Name,
-			&customer.ContactPerson,
-			&customer.Email,
-			&customer.Phone,
-			&companyID, // Scan 到 sql.NullInt64
-			&customer.CreatedAt,
-			&customer.UpdatedAt,
-		); err != nil {
-			zap.L().Error("Repository: Failed to scan customer data", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan customer data: %w", err)
+		var columns pageColumns
+		customer, err := scanCustomer(columns.scanner(rows))
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan customer data", zap.Int("row", len(customers)+1), zap.Error(err))
+			return nil, models.PageInfo{}, fmt.Errorf("failed to scan customer data at row %d: %w", len(customers)+1, err)
 		}
-		if companyID.Valid {
-			customer.CompanyID = new(int)
-			*customer.CompanyID = int(companyID.Int64)
-		} else {
-			customer.CompanyID = nil
+		customers = append(customers, *customer)
+		page.add(customer.ID, columns)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating customer data", zap.Error(err))
+		return nil, models.PageInfo{}, fmt.Errorf("error iterating customer data: %w", err)
+	}
+	var cursorKeyset *keyset
+	if ok {
+		cursorKeyset = &k
+	}
+	n, info, err := page.finish(ctx, pagination, cursorKeyset, func(ctx context.Context) (int, error) {
+		return r.Count(ctx, filter)
+	})
+	if err != nil {
+		return nil, models.PageInfo{}, err
+	}
+	return customers[:n], info, nil
+}
+
+// Count 計算符合篩選條件的客戶數量
+func (r *customerRepositoryImpl) Count(ctx context.Context, filter models.CustomerFilter) (int, error) {
+	where, args, err := buildCustomerWhere(filter)
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	if err := readConn(ctx, r.db, r.reader).QueryRowContext(ctx, `SELECT COUNT(*) FROM customers cu`+where, args...).Scan(&total); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count customers", zap.Error(err))
+		return 0, fmt.Errorf("failed to count customers: %w", err)
+	}
+	return total, nil
+}
+
+// StreamAll 依篩選條件與排序逐批讀取所有客戶，每累積 batchSize 筆呼叫一次 fn
+// 用於匯出等大量資料的情境，避免一次將所有資料載入記憶體；fn 返回錯誤時中止讀取。
+// batch 的底層陣列會被重複使用，fn 不應在返回後保留它
+func (r *customerRepositoryImpl) StreamAll(ctx context.Context, filter models.CustomerFilter, batchSize int, fn func(batch []models.Customer) error) error {
+	where, args, err := buildCustomerWhere(filter)
+	if err != nil {
+		return err
+	}
+	orderBy, err := filter.Criteria.OrderBy(customerSortColumns, "cu.id")
+	if err != nil {
+		return err
+	}
+
+	rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, `SELECT `+customerColumns+customerFrom+where+` ORDER BY `+orderBy, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to stream customers", zap.Error(err))
+		return fmt.Errorf("failed to stream customers: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]models.Customer, 0, batchSize)
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan streamed customer", zap.Error(err))
+			return fmt.Errorf("failed to scan customer data: %w", err)
+		}
+		batch = append(batch, *customer)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
 		}
-		customers = append(customers, customer)
 	}
-	return customers, nil
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed while iterating streamed customers", zap.Error(err))
+		return fmt.Errorf("failed to stream customers: %w", err)
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
 }
 
-// FindByID 根據 ID 獲取客戶
-func (r *customerRepositoryImpl) FindByID(id int) (*models.Customer, error) {
-	query := `SELECT id, name, contact_person, email, phone, company_id, created_at, updated_at FROM customers WHERE id = $1`
-	row := r.db.QueryRow(query, id)
-	var customer models.Customer
-	var companyID sql.NullInt64 // 用於處理 NULLABLE 的 company_id
-	if err := row.Scan(
-		&customer.ID,
-		&customer.Name,
-		&customer.ContactPerson,
-		&customer.Email,
-		&customer.Phone,
-		&companyID,
-		&customer.CreatedAt,
-		&customer.UpdatedAt,
-	); err != nil {
+// FindByID 根據 ID 獲取客戶；FindOptions.IncludeDeleted 時包含已軟刪除的客戶
+func (r *customerRepositoryImpl) FindByID(ctx context.Context, id int, opts ...FindOptions) (*models.Customer, error) {
+	row, err := queriesOn(readConn(ctx, r.db, r.reader)).GetCustomerByID(ctx, queries.GetCustomerByIDParams{ID: id, IncludeDeleted: includeDeleted(opts)})
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // 未找到
 		}
-		zap.L().Error("Repository: Failed to get customer by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer by ID", zap.Int("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get customer by ID %d: %w", id, err)
 	}
-	if companyID.Valid {
-		customer.CompanyID = new(int)
-		*customer.CompanyID = int(companyID.Int64)
-	} else {
-		customer.CompanyID = nil
+	return customerFromRow(row), nil
+}
+
+// FindByCompanyID 根據公司 ID 獲取客戶
+// includeDescendants 為 true 時，使用遞迴 CTE 包含該公司所有子孫公司的客戶
+func (r *customerRepositoryImpl) FindByCompanyID(ctx context.Context, companyID int, includeDescendants bool) ([]models.Customer, error) {
+	query := `SELECT ` + customerColumns + customerFrom + ` WHERE cu.company_id = $1` + customerSoftDelete.and(false) + ` ORDER BY cu.id ASC`
+	if includeDescendants {
+		query = `WITH RECURSIVE company_tree AS (
+                     SELECT id FROM companies WHERE id = $1
+                     UNION
+                     SELECT c.id FROM companies c
+                     JOIN company_tree ct ON c.parent_company_id = ct.id
+                 )
+                 SELECT ` + customerColumns + customerFrom + `
+                 WHERE cu.company_id IN (SELECT id FROM company_tree)` + customerSoftDelete.and(false) + `
+                 ORDER BY cu.id ASC`
 	}
-	return &customer, nil
+	rows, err := r.db.QueryContext(ctx, query, companyID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customers by company ID", zap.Int("company_id", companyID), zap.Bool("include_descendants", includeDescendants), zap.Error(err))
+		return nil, fmt.Errorf("failed to get customers for company %d: %w", companyID, err)
+	}
+	defer rows.Close()
+
+	customers := []models.Customer{}
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan customer data for company", zap.Int("company_id", companyID), zap.Int("row", len(customers)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan customer data for company %d at row %d: %w", companyID, len(customers)+1, err)
+		}
+		customers = append(customers, *customer)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating customer data for company", zap.Int("company_id", companyID), zap.Error(err))
+		return nil, fmt.Errorf("error iterating customer data for company %d: %w", companyID, err)
+	}
+	return customers, nil
+}
+
+// FindByPublicID 根據對外的 UUID 識別碼獲取客戶；FindOptions.IncludeDeleted 時包含已軟刪除的客戶
+func (r *customerRepositoryImpl) FindByPublicID(ctx context.Context, publicID string, opts ...FindOptions) (*models.Customer, error) {
+	row, err := queriesOn(readConn(ctx, r.db, r.reader)).GetCustomerByPublicID(ctx, queries.GetCustomerByPublicIDParams{PublicID: publicID, IncludeDeleted: includeDeleted(opts)})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get customer by public ID %s: %w", publicID, err)
+	}
+	return customerFromRow(queries.GetCustomerByIDRow(row)), nil
 }
 
-// Update 更新客戶信息
-func (r *customerRepositoryImpl) Update(customer *models.Customer) error {
-	query := `UPDATE customers SET name = $1, contact_person = $2, email = $3, phone = $4, company_id = $5, updated_at = NOW() WHERE id = $6 RETURNING updated_at`
-	res, err := r.db.Exec(query,
+// FindByCode 根據客戶代碼獲取客戶 (不分大小寫)
+// 已軟刪除客戶的代碼仍保留，不會被重新分配
+func (r *customerRepositoryImpl) FindByCode(ctx context.Context, code string) (*models.Customer, error) {
+	query := `SELECT ` + customerColumns + customerFrom + ` WHERE cu.code = upper($1)` + customerSoftDelete.and(false)
+	customer, err := scanCustomer(r.db.QueryRowContext(ctx, query, code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer by code", zap.String("code", code), zap.Error(err))
+		return nil, fmt.Errorf("failed to get customer by code %s: %w", code, err)
+	}
+	return customer, nil
+}
+
+// FindByEmail 根據 Email 獲取客戶 (不分大小寫)，email 為空時直接返回未找到
+func (r *customerRepositoryImpl) FindByEmail(ctx context.Context, email string) (*models.Customer, error) {
+	if email == "" {
+		return nil, nil
+	}
+	query := `SELECT ` + customerColumns + customerFrom + ` WHERE lower(cu.email) = lower($1)` + customerSoftDelete.and(false)
+	customer, err := scanCustomer(r.db.QueryRowContext(ctx, query, email))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer by email", zap.String("email", email), zap.Error(err))
+		return nil, fmt.Errorf("failed to get customer by email %s: %w", email, err)
+	}
+	return customer, nil
+}
+
+// emailConflictError 若 err 為 Email 唯一索引衝突 (23505)，返回包含既有客戶 ID 的 409 錯誤；否則返回 nil
+// 用於 Service 層預先檢查與寫入之間發生競爭的情況
+func (r *customerRepositoryImpl) emailConflictError(ctx context.Context, err error, email string) error {
+	if !isUniqueViolation(err, customerEmailConstraint) {
+		return nil
+	}
+	conflict := models.CustomerConflict{Field: "email"}
+	if existing, findErr := r.FindByEmail(ctx, email); findErr == nil && existing != nil {
+		conflict.ExistingCustomerID = existing.ID
+	}
+	return utils.NewConflictError("Customer email already exists", conflict)
+}
+
+// Update 更新客戶信息，並在同一事務中寫入 history 中的變更記錄；最後修改者為 actorID
+func (r *customerRepositoryImpl) Update(ctx context.Context, customer *models.Customer, actorID int, history []models.CustomerHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for customer update", zap.Error(err), zap.Int("id", customer.ID))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	query := `UPDATE customers SET name = $1, contact_person = $2, email = $3, phone = $4, phone_normalized = NULLIF($5, ''), company_id = $6, currency = $7, payment_terms = $8, status = $9, sales_rep_account_id = $10, updated_by = NULLIF($12, 0), updated_at = NOW() WHERE id = $11 AND deleted_at IS NULL
+              RETURNING created_at, updated_at, ` + actorReturning("customers")
+	err = tx.QueryRowContext(ctx, query,
 		customer.Name,
 		customer.ContactPerson,
 		customer.Email,
 		customer.Phone,
+		customer.PhoneNormalized,
 		customer.CompanyID,
+		customer.Currency,
+		customer.PaymentTerms,
+		customer.Status,
+		customer.SalesRepAccountID,
 		customer.ID,
-	)
+		actorID,
+	).Scan(append([]interface{}{&customer.CreatedAt, &customer.UpdatedAt}, actorDest(&customer.RecordActors)...)...)
 	if err != nil {
-		zap.L().Error("Repository: Failed to update customer", zap.Error(err), zap.Int("id", customer.ID))
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要更新的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update customer", zap.Error(err), zap.Int("id", customer.ID))
+		if conflictErr := r.emailConflictError(ctx, err, customer.Email); conflictErr != nil {
+			return conflictErr
+		}
 		return fmt.Errorf("failed to update customer %d: %w", customer.ID, err)
 	}
-	rowsAffected, err := res.RowsAffected()
+
+	if err := insertCustomerHistory(ctx, r.logger, tx, history); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit customer update", zap.Error(err), zap.Int("id", customer.ID))
+		return fmt.Errorf("failed to commit customer update %d: %w", customer.ID, err)
+	}
+	return nil
+}
+
+// Delete 軟刪除客戶，保留記錄以便還原，並在同一事務中寫入 history
+func (r *customerRepositoryImpl) Delete(ctx context.Context, id, actorID int, history []models.CustomerHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after update", zap.Error(err), zap.Int("id", customer.ID))
-		return fmt.Errorf("failed to check update rows affected %d: %w", customer.ID, err)
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for customer delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if rowsAffected == 0 {
-		return utils.ErrNotFound // 未找到要更新的記錄
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	if err := customerSoftDelete.delete(ctx, tx, id, actorID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 未找到要刪除的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete customer", zap.Error(err), zap.Int("id", id))
+		return err
+	}
+
+	if err := insertCustomerHistory(ctx, r.logger, tx, history); err != nil {
+		return err
 	}
-	// 重新讀取 updated_at
-	row := r.db.QueryRow(`SELECT updated_at FROM customers WHERE id = $1`, customer.ID)
-	if err := row.Scan(&customer.UpdatedAt); err != nil {
-		zap.L().Error("Repository: Failed to scan updated_at after update", zap.Error(err), zap.Int("id", customer.ID))
-		return fmt.Errorf("failed to scan updated_at for customer %d: %w", customer.ID, err)
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit customer delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to commit customer delete %d: %w", id, err)
 	}
 	return nil
 }
 
-// Delete 刪除客戶
-func (r *customerRepositoryImpl) Delete(id int) error {
-	query := `DELETE FROM customers WHERE id = $1`
-	res, err := r.db.Exec(query, id)
+// Restore 還原已軟刪除的客戶
+// 若其 Email 已被其他未刪除的客戶使用，返回包含該客戶 ID 的 409 錯誤，需先解決衝突再還原
+func (r *customerRepositoryImpl) Restore(ctx context.Context, id, actorID int, history []models.CustomerHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		zap.L().Error("Repository: Failed to delete customer", zap.Error(err), zap.Int("id", id))
-		return fmt.Errorf("failed to delete customer %d: %w", id, err)
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for customer restore", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	rowsAffected, err := res.RowsAffected()
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	var email sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT email FROM customers WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`, id).Scan(&email)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
-		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到已刪除的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get deleted customer for restore", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to get deleted customer %d: %w", id, err)
+	}
+
+	if err := customerSoftDelete.restore(ctx, tx, id, actorID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to restore customer", zap.Error(err), zap.Int("id", id))
+		if conflictErr := r.emailConflictError(ctx, err, email.String); conflictErr != nil {
+			return conflictErr
+		}
+		return err
 	}
-	if rowsAffected == 0 {
-		return utils.ErrNotFound // 未找到要刪除的記錄
+
+	if err := insertCustomerHistory(ctx, r.logger, tx, history); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit customer restore", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to commit customer restore %d: %w", id, err)
 	}
 	return nil
 }
+
+// FindDuplicates 找出與輸入相似的未刪除客戶，依分數由高到低排序
+// Email 完全相同 (不分大小寫) 得 1 分、正規化電話相同得 0.9 分，否則以 pg_trgm similarity 計算名稱相似度，
+// 名稱相似度低於 nameThreshold 且其他欄位都未命中的客戶不返回；空字串的欄位不參與比對
+func (r *customerRepositoryImpl) FindDuplicates(ctx context.Context, name, email, phoneNormalized string, nameThreshold float64, limit int) ([]models.CustomerDuplicateCandidate, error) {
+	query := `SELECT id, name, contact_person, email, phone, company_id, company_name, email_match, phone_match, name_score
+              FROM (
+                  SELECT cu.id, cu.name, cu.contact_person, cu.email, cu.phone, cu.company_id, co.name AS company_name,
+                         ($2 <> '' AND lower(cu.email) = lower($2)) AS email_match,
+                         ($3 <> '' AND cu.phone_normalized = $3) AS phone_match,
+                         CASE WHEN $1 <> '' THEN similarity(cu.name, $1) ELSE 0 END AS name_score
+                  FROM customers cu
+                  LEFT JOIN companies co ON co.id = cu.company_id
+                  WHERE ` + customerSoftDelete.active() + `
+              ) m
+              WHERE email_match OR phone_match OR name_score >= $4
+              ORDER BY GREATEST(CASE WHEN email_match THEN 1 ELSE 0 END, CASE WHEN phone_match THEN 0.9 ELSE 0 END, name_score) DESC, id ASC
+              LIMIT $5`
+	rows, err := r.db.QueryContext(ctx, query, name, email, phoneNormalized, nameThreshold, limit)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to find duplicate customers", zap.Error(err))
+		return nil, fmt.Errorf("failed to find duplicate customers: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := []models.CustomerDuplicateCandidate{}
+	for rows.Next() {
+		var candidate models.CustomerDuplicateCandidate
+		var companyID sql.NullInt64
+		var companyName sql.NullString
+		var emailMatch, phoneMatch bool
+		var nameScore float64
+		if err := rows.Scan(&candidate.ID, &candidate.Name, &candidate.ContactPerson, &candidate.Email, &candidate.Phone, &companyID, &companyName, &emailMatch, &phoneMatch, &nameScore); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan duplicate customer", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan duplicate customer: %w", err)
+		}
+		if companyID.Valid {
+			candidate.CompanyID = new(int)
+			*candidate.CompanyID = int(companyID.Int64)
+		}
+		if companyName.Valid {
+			candidate.CompanyName = &companyName.String
+		}
+
+		candidate.MatchedOn = []string{}
+		candidate.Score = nameScore
+		if nameScore >= nameThreshold {
+			candidate.MatchedOn = append(candidate.MatchedOn, "name")
+		}
+		if phoneMatch {
+			candidate.MatchedOn = append(candidate.MatchedOn, "phone")
+			if candidate.Score < 0.9 {
+				candidate.Score = 0.9
+			}
+		}
+		if emailMatch {
+			candidate.MatchedOn = append(candidate.MatchedOn, "email")
+			candidate.Score = 1
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating duplicate customers", zap.Error(err))
+		return nil, fmt.Errorf("error iterating duplicate customers: %w", err)
+	}
+	return candidates, nil
+}
+
+// ImportBatch 在單一事務中依 Email (不分大小寫) 批次 upsert 客戶
+// 比對到既有客戶時依 onConflict 略過、更新或拒絕；沒有 Email 的列一律新增。
+// 需要自動建立的公司在同一事務中建立，同一批次內相同名稱只建立一次。
+// 新增的列未指定代碼時以 codePrefix 產生；指定的代碼已被使用時該列標記為無效，既有客戶的代碼不可變更。
+// dryRun 為 true 時執行完全相同的寫入，最後回滾事務，因此報告結果與實際匯入一致。
+func (r *customerRepositoryImpl) ImportBatch(ctx context.Context, rows []models.CustomerImportRow, onConflict models.ImportConflictMode, codePrefix string, actorID int, dryRun bool) ([]models.ImportRowResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for customer import", zap.Error(err))
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback() // 確保在函數返回前回滾，除非明確提交
+
+	createdCompanies := map[string]int{}
+	results := make([]models.ImportRowResult, 0, len(rows))
+	for _, row := range rows {
+		customer := row.Customer
+
+		if customer.CompanyID == nil && row.CompanyName != "" {
+			companyID, ok := createdCompanies[row.CompanyName]
+			if !ok {
+				if err := tx.QueryRowContext(ctx, `INSERT INTO companies (name, created_by, updated_by) VALUES ($1, NULLIF($2, 0), NULLIF($2, 0)) RETURNING id`, row.CompanyName, actorID).Scan(&companyID); err != nil {
+					utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create company during customer import", zap.Error(err), zap.Int("line", row.Line))
+					return nil, fmt.Errorf("line %d: failed to create company %q: %w", row.Line, row.CompanyName, err)
+				}
+				createdCompanies[row.CompanyName] = companyID
+			}
+			customer.CompanyID = &companyID
+		}
+
+		var existingID int
+		var existingCode string
+		if customer.Email != "" {
+			err := tx.QueryRowContext(ctx, `SELECT id, code FROM customers WHERE lower(email) = lower($1) AND deleted_at IS NULL`, customer.Email).Scan(&existingID, &existingCode)
+			if err != nil && err != sql.ErrNoRows {
+				utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to match customer during import", zap.Error(err), zap.Int("line", row.Line))
+				return nil, fmt.Errorf("line %d: failed to match existing customer: %w", row.Line, err)
+			}
+		}
+
+		if existingID != 0 {
+			id := existingID
+			if customer.Code != "" && customer.Code != existingCode && onConflict == models.ImportConflictUpdate {
+				results = append(results, models.ImportRowResult{
+					Line:    row.Line,
+					Action:  models.ImportActionInvalid,
+					ID:      &id,
+					Details: fmt.Sprintf("Customer code cannot be changed (existing code: %s)", existingCode),
+				})
+				continue
+			}
+			switch onConflict {
+			case models.ImportConflictSkip:
+				results = append(results, models.ImportRowResult{Line: row.Line, Action: models.ImportActionSkipped, ID: &id})
+			case models.ImportConflictUpdate:
+				// 匯入列未指定公司時保留既有客戶的公司
+				_, err = tx.ExecContext(ctx, `UPDATE customers SET name = $1, contact_person = $2, phone = $3, phone_normalized = NULLIF($4, ''), company_id = COALESCE($5, company_id), updated_by = NULLIF($7, 0), updated_at = NOW() WHERE id = $6`,
+					customer.Name, customer.ContactPerson, customer.Phone, customer.PhoneNormalized, customer.CompanyID, id, actorID)
+				if err != nil {
+					utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update customer during import", zap.Error(err), zap.Int("line", row.Line), zap.Int("id", id))
+					return nil, fmt.Errorf("line %d: failed to update customer %d: %w", row.Line, id, err)
+				}
+				results = append(results, models.ImportRowResult{Line: row.Line, Action: models.ImportActionUpdated, ID: &id})
+			default:
+				results = append(results, models.ImportRowResult{
+					Line:    row.Line,
+					Action:  models.ImportActionInvalid,
+					Details: models.CustomerConflict{Field: "email", ExistingCustomerID: id},
+				})
+			}
+			continue
+		}
+
+		if customer.Code == "" {
+			code, err := nextCustomerCode(ctx, r.logger, tx, codePrefix)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", row.Line, err)
+			}
+			customer.Code = code
+		} else {
+			var codeTaken bool
+			if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE code = $1)`, customer.Code).Scan(&codeTaken); err != nil {
+				utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to check customer code during import", zap.Error(err), zap.Int("line", row.Line))
+				return nil, fmt.Errorf("line %d: failed to check customer code: %w", row.Line, err)
+			}
+			if codeTaken {
+				results = append(results, models.ImportRowResult{
+					Line:    row.Line,
+					Action:  models.ImportActionInvalid,
+					Details: fmt.Sprintf("Customer code already exists: %s", customer.Code),
+				})
+				continue
+			}
+		}
+
+		var id int
+		err = tx.QueryRowContext(ctx, `INSERT INTO customers (code, name, contact_person, email, phone, phone_normalized, company_id, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NULLIF($8, 0), NULLIF($8, 0)) RETURNING id`,
+			customer.Code, customer.Name, customer.ContactPerson, customer.Email, customer.Phone, customer.PhoneNormalized, customer.CompanyID, actorID).Scan(&id)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create customer during import", zap.Error(err), zap.Int("line", row.Line))
+			return nil, fmt.Errorf("line %d: failed to create customer: %w", row.Line, err)
+		}
+		result := models.ImportRowResult{Line: row.Line, Action: models.ImportActionCreated}
+		if !dryRun {
+			result.ID = &id // dry run 回滾後此 ID 不存在，不返回
+		}
+		results = append(results, result)
+	}
+
+	if dryRun {
+		return results, nil // 延遲的 Rollback 會丟棄所有寫入
+	}
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit customer import", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit customer import: %w", err)
+	}
+	return results, nil
+}
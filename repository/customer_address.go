@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// CustomerAddressRepository 定義客戶地址資料庫操作介面
+type CustomerAddressRepository interface {
+	Create(ctx context.Context, address *models.CustomerAddress) error
+	FindByCustomerID(ctx context.Context, customerID int) ([]models.CustomerAddress, error)
+	FindByID(ctx context.Context, customerID, id int) (*models.CustomerAddress, error)
+	Update(ctx context.Context, address *models.CustomerAddress) error
+	Delete(ctx context.Context, customerID, id int) error
+	CountByType(ctx context.Context, customerID int, addressType string) (int, error) // 計算客戶某類型的地址數量
+	PromoteDefault(ctx context.Context, customerID int, addressType string) error     // 該類型沒有預設地址時，將最早建立的地址設為預設
+}
+
+// customerAddressColumns 查詢地址時統一使用的欄位順序，需與 scanCustomerAddress 保持一致
+const customerAddressColumns = `id, customer_id, type, line1, line2, city, state, postal_code, country, is_default, created_at, updated_at`
+
+// scanCustomerAddress 將一列查詢結果掃描為 CustomerAddress
+func scanCustomerAddress(row rowScanner) (*models.CustomerAddress, error) {
+	var address models.CustomerAddress
+	if err := row.Scan(
+		&address.ID,
+		&address.CustomerID,
+		&address.Type,
+		&address.Line1,
+		&address.Line2,
+		&address.City,
+		&address.State,
+		&address.PostalCode,
+		&address.Country,
+		&address.IsDefault,
+		&address.CreatedAt,
+		&address.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// customerAddressRepositoryImpl 實現 CustomerAddressRepository 介面
+type customerAddressRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewCustomerAddressRepository 創建 CustomerAddressRepository 實例
+func NewCustomerAddressRepository(db *sql.DB, logger *zap.Logger) CustomerAddressRepository {
+	return &customerAddressRepositoryImpl{db: db, logger: logger}
+}
+
+// clearDefault 在事務中取消客戶同類型其他地址的預設狀態
+func clearDefault(ctx context.Context, tx *sql.Tx, customerID int, addressType string, exceptID int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE customer_addresses SET is_default = FALSE, updated_at = NOW()
+                       WHERE customer_id = $1 AND type = $2 AND is_default AND id <> $3`, customerID, addressType, exceptID)
+	return err
+}
+
+// Create 創建新地址；IsDefault 為 true 時在同一事務中取消同類型其他地址的預設
+func (r *customerAddressRepositoryImpl) Create(ctx context.Context, address *models.CustomerAddress) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for customer address create", zap.Error(err))
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback() // 確保在函數返回前回滾，除非明確提交
+
+	if address.IsDefault {
+		if err := clearDefault(ctx, tx, address.CustomerID, address.Type, 0); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to clear default customer address", zap.Error(err), zap.Int("customer_id", address.CustomerID))
+			return fmt.Errorf("failed to clear default address: %w", err)
+		}
+	}
+
+	query := `INSERT INTO customer_addresses (customer_id, type, line1, line2, city, state, postal_code, country, is_default)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query,
+		address.CustomerID,
+		address.Type,
+		address.Line1,
+		address.Line2,
+		address.City,
+		address.State,
+		address.PostalCode,
+		address.Country,
+		address.IsDefault,
+	).Scan(&address.ID, &address.CreatedAt, &address.UpdatedAt)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create customer address", zap.Error(err), zap.Int("customer_id", address.CustomerID))
+		return fmt.Errorf("failed to create customer address: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit customer address create", zap.Error(err))
+		return fmt.Errorf("failed to commit customer address: %w", err)
+	}
+	return nil
+}
+
+// FindByCustomerID 獲取客戶的所有地址，依類型、預設優先、ID 排序
+func (r *customerAddressRepositoryImpl) FindByCustomerID(ctx context.Context, customerID int) ([]models.CustomerAddress, error) {
+	query := `SELECT ` + customerAddressColumns + ` FROM customer_addresses WHERE customer_id = $1 ORDER BY type ASC, is_default DESC, id ASC`
+	rows, err := r.db.QueryContext(ctx, query, customerID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer addresses", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, fmt.Errorf("failed to get addresses for customer %d: %w", customerID, err)
+	}
+	defer rows.Close()
+
+	addresses := []models.CustomerAddress{}
+	for rows.Next() {
+		address, err := scanCustomerAddress(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan customer address", zap.Int("row", len(addresses)+1), zap.Error(err), zap.Int("customer_id", customerID))
+			return nil, fmt.Errorf("failed to scan customer address at row %d: %w", len(addresses)+1, err)
+		}
+		addresses = append(addresses, *address)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating customer addresses", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, fmt.Errorf("error iterating customer addresses: %w", err)
+	}
+	return addresses, nil
+}
+
+// FindByID 根據 ID 獲取客戶的地址
+func (r *customerAddressRepositoryImpl) FindByID(ctx context.Context, customerID, id int) (*models.CustomerAddress, error) {
+	query := `SELECT ` + customerAddressColumns + ` FROM customer_addresses WHERE id = $1 AND customer_id = $2`
+	address, err := scanCustomerAddress(r.db.QueryRowContext(ctx, query, id, customerID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer address by ID", zap.Int("id", id), zap.Int("customer_id", customerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get customer address %d: %w", id, err)
+	}
+	return address, nil
+}
+
+// Update 更新地址；IsDefault 為 true 時在同一事務中取消同類型其他地址的預設
+func (r *customerAddressRepositoryImpl) Update(ctx context.Context, address *models.CustomerAddress) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for customer address update", zap.Error(err))
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback() // 確保在函數返回前回滾，除非明確提交
+
+	if address.IsDefault {
+		if err := clearDefault(ctx, tx, address.CustomerID, address.Type, address.ID); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to clear default customer address", zap.Error(err), zap.Int("customer_id", address.CustomerID))
+			return fmt.Errorf("failed to clear default address: %w", err)
+		}
+	}
+
+	query := `UPDATE customer_addresses
+              SET type = $1, line1 = $2, line2 = $3, city = $4, state = $5, postal_code = $6, country = $7, is_default = $8, updated_at = NOW()
+              WHERE id = $9 AND customer_id = $10 RETURNING created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query,
+		address.Type,
+		address.Line1,
+		address.Line2,
+		address.City,
+		address.State,
+		address.PostalCode,
+		address.Country,
+		address.IsDefault,
+		address.ID,
+		address.CustomerID,
+	).Scan(&address.CreatedAt, &address.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要更新的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update customer address", zap.Error(err), zap.Int("id", address.ID))
+		return fmt.Errorf("failed to update customer address %d: %w", address.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit customer address update", zap.Error(err))
+		return fmt.Errorf("failed to commit customer address: %w", err)
+	}
+	return nil
+}
+
+// Delete 刪除客戶的地址
+func (r *customerAddressRepositoryImpl) Delete(ctx context.Context, customerID, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM customer_addresses WHERE id = $1 AND customer_id = $2`, id, customerID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete customer address", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to delete customer address %d: %w", id, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		return utils.ErrNotFound // 未找到要刪除的記錄
+	}
+	return nil
+}
+
+// CountByType 計算客戶某類型的地址數量
+func (r *customerAddressRepositoryImpl) CountByType(ctx context.Context, customerID int, addressType string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM customer_addresses WHERE customer_id = $1 AND type = $2`, customerID, addressType).Scan(&count)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count customer addresses", zap.Error(err), zap.Int("customer_id", customerID), zap.String("type", addressType))
+		return 0, fmt.Errorf("failed to count addresses for customer %d: %w", customerID, err)
+	}
+	return count, nil
+}
+
+// PromoteDefault 若客戶某類型沒有預設地址，將最早建立的地址設為預設
+func (r *customerAddressRepositoryImpl) PromoteDefault(ctx context.Context, customerID int, addressType string) error {
+	query := `UPDATE customer_addresses SET is_default = TRUE, updated_at = NOW()
+              WHERE id = (
+                  SELECT id FROM customer_addresses WHERE customer_id = $1 AND type = $2 ORDER BY id ASC LIMIT 1
+              )
+              AND NOT EXISTS (
+                  SELECT 1 FROM customer_addresses WHERE customer_id = $1 AND type = $2 AND is_default
+              )`
+	if _, err := r.db.ExecContext(ctx, query, customerID, addressType); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to promote default customer address", zap.Error(err), zap.Int("customer_id", customerID), zap.String("type", addressType))
+		return fmt.Errorf("failed to promote default address for customer %d: %w", customerID, err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// CustomerHistoryRepository 定義客戶變更歷史資料庫操作介面
+// 寫入由 CustomerRepository 在變更客戶的同一事務中完成 (見 insertCustomerHistory)
+type CustomerHistoryRepository interface {
+	FindByCustomerID(ctx context.Context, customerID int, field string, pagination utils.Pagination) ([]models.CustomerHistory, int, error) // 依時間由新到舊分頁，field 不為空時只返回該欄位的變更
+}
+
+// customerHistoryColumns 查詢歷史時統一使用的欄位順序，需與 scanCustomerHistory 保持一致
+const customerHistoryColumns = `h.id, h.customer_id, h.event, h.field, h.old_value, h.new_value, h.reason, h.actor_account_id, a.username, h.created_at`
+
+// scanCustomerHistory 將一列查詢結果掃描為 CustomerHistory，處理 NULLABLE 的欄位與執行者
+func scanCustomerHistory(row rowScanner) (*models.CustomerHistory, error) {
+	var entry models.CustomerHistory
+	var field, oldValue, newValue, reason, actorUsername sql.NullString
+	var actorID sql.NullInt64
+	if err := row.Scan(
+		&entry.ID,
+		&entry.CustomerID,
+		&entry.Event,
+		&field,
+		&oldValue,
+		&newValue,
+		&reason,
+		&actorID,
+		&actorUsername,
+		&entry.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	entry.Field = field.String
+	entry.Reason = reason.String
+	if oldValue.Valid {
+		entry.OldValue = &oldValue.String
+	}
+	if newValue.Valid {
+		entry.NewValue = &newValue.String
+	}
+	if actorID.Valid {
+		entry.ActorID = new(int)
+		*entry.ActorID = int(actorID.Int64)
+	}
+	if actorUsername.Valid {
+		entry.ActorUsername = &actorUsername.String
+	}
+	return &entry, nil
+}
+
+// insertCustomerHistory 在事務中寫入客戶歷史記錄
+func insertCustomerHistory(ctx context.Context, logger *zap.Logger, tx *sql.Tx, history []models.CustomerHistory) error {
+	for i := range history {
+		entry := &history[i]
+		err := tx.QueryRowContext(ctx, `INSERT INTO customer_history (customer_id, event, field, old_value, new_value, reason, actor_account_id) VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7) RETURNING id, created_at`,
+			entry.CustomerID, entry.Event, entry.Field, entry.OldValue, entry.NewValue, entry.Reason, entry.ActorID,
+		).Scan(&entry.ID, &entry.CreatedAt)
+		if err != nil {
+			utils.ContextLogger(ctx, logger).Error("Repository: Failed to insert customer history", zap.Error(err), zap.Int("customer_id", entry.CustomerID), zap.String("event", entry.Event), zap.String("field", entry.Field))
+			return fmt.Errorf("failed to insert customer history for customer %d: %w", entry.CustomerID, err)
+		}
+	}
+	return nil
+}
+
+// customerHistoryRepositoryImpl 實現 CustomerHistoryRepository 介面
+type customerHistoryRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewCustomerHistoryRepository 創建 CustomerHistoryRepository 實例
+func NewCustomerHistoryRepository(db *sql.DB, logger *zap.Logger) CustomerHistoryRepository {
+	return &customerHistoryRepositoryImpl{db: db, logger: logger}
+}
+
+// FindByCustomerID 分頁獲取客戶的變更歷史，依時間由新到舊
+func (r *customerHistoryRepositoryImpl) FindByCustomerID(ctx context.Context, customerID int, field string, pagination utils.Pagination) ([]models.CustomerHistory, int, error) {
+	where := ` WHERE h.customer_id = $1`
+	args := []interface{}{customerID}
+	if field != "" {
+		args = append(args, field)
+		where += ` AND h.field = $2`
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM customer_history h`+where, args...).Scan(&total); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count customer history", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, 0, fmt.Errorf("failed to count history for customer %d: %w", customerID, err)
+	}
+
+	clause, args := pageClause("h.created_at DESC, h.id DESC", pagination, args)
+	query := `SELECT ` + customerHistoryColumns + `
+              FROM customer_history h
+              LEFT JOIN accounts a ON a.id = h.actor_account_id` + where + clause
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer history", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, 0, fmt.Errorf("failed to get history for customer %d: %w", customerID, err)
+	}
+	defer rows.Close()
+
+	history := []models.CustomerHistory{}
+	for rows.Next() {
+		entry, err := scanCustomerHistory(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan customer history", zap.Int("row", len(history)+1), zap.Error(err), zap.Int("customer_id", customerID))
+			return nil, 0, fmt.Errorf("failed to scan customer history at row %d: %w", len(history)+1, err)
+		}
+		history = append(history, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating customer history", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, 0, fmt.Errorf("error iterating customer history: %w", err)
+	}
+	return history, total, nil
+}
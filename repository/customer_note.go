@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// CustomerNoteRepository 定義客戶備註資料庫操作介面
+type CustomerNoteRepository interface {
+	Create(ctx context.Context, note *models.CustomerNote) error
+	FindByCustomerID(ctx context.Context, customerID int, pinnedFirst bool, pagination utils.Pagination) ([]models.CustomerNote, int, error) // 依建立時間由新到舊分頁，返回總筆數
+	FindByID(ctx context.Context, customerID, id int) (*models.CustomerNote, error)
+	Delete(ctx context.Context, customerID, id int) error
+}
+
+// customerNoteColumns 查詢備註時統一使用的欄位順序，需與 scanCustomerNote 保持一致
+const customerNoteColumns = `n.id, n.customer_id, n.author_id, a.username, n.body, n.pinned, n.created_at`
+
+// scanCustomerNote 將一列查詢結果掃描為 CustomerNote，處理 NULLABLE 的作者
+func scanCustomerNote(row rowScanner) (*models.CustomerNote, error) {
+	var note models.CustomerNote
+	var authorID sql.NullInt64
+	var authorUsername sql.NullString
+	if err := row.Scan(
+		&note.ID,
+		&note.CustomerID,
+		&authorID,
+		&authorUsername,
+		&note.Body,
+		&note.Pinned,
+		&note.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if authorID.Valid {
+		note.AuthorID = new(int)
+		*note.AuthorID = int(authorID.Int64)
+	}
+	if authorUsername.Valid {
+		note.AuthorUsername = &authorUsername.String
+	}
+	return &note, nil
+}
+
+// customerNoteRepositoryImpl 實現 CustomerNoteRepository 介面
+type customerNoteRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewCustomerNoteRepository 創建 CustomerNoteRepository 實例
+func NewCustomerNoteRepository(db *sql.DB, logger *zap.Logger) CustomerNoteRepository {
+	return &customerNoteRepositoryImpl{db: db, logger: logger}
+}
+
+// Create 創建新備註
+func (r *customerNoteRepositoryImpl) Create(ctx context.Context, note *models.CustomerNote) error {
+	query := `INSERT INTO customer_notes (customer_id, author_id, body, pinned) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query, note.CustomerID, note.AuthorID, note.Body, note.Pinned).Scan(&note.ID, &note.CreatedAt)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create customer note", zap.Error(err), zap.Int("customer_id", note.CustomerID))
+		return fmt.Errorf("failed to create customer note: %w", err)
+	}
+	return nil
+}
+
+// FindByCustomerID 分頁獲取客戶的備註，依建立時間由新到舊；pinnedFirst 為 true 時置頂備註排在最前
+func (r *customerNoteRepositoryImpl) FindByCustomerID(ctx context.Context, customerID int, pinnedFirst bool, pagination utils.Pagination) ([]models.CustomerNote, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM customer_notes WHERE customer_id = $1`, customerID).Scan(&total); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count customer notes", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, 0, fmt.Errorf("failed to count notes for customer %d: %w", customerID, err)
+	}
+
+	orderBy := "n.created_at DESC, n.id DESC"
+	if pinnedFirst {
+		orderBy = "n.pinned DESC, " + orderBy
+	}
+	clause, args := pageClause(orderBy, pagination, []interface{}{customerID})
+	query := `SELECT ` + customerNoteColumns + `
+              FROM customer_notes n
+              LEFT JOIN accounts a ON a.id = n.author_id
+              WHERE n.customer_id = $1` + clause
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer notes", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, 0, fmt.Errorf("failed to get notes for customer %d: %w", customerID, err)
+	}
+	defer rows.Close()
+
+	notes := []models.CustomerNote{}
+	for rows.Next() {
+		note, err := scanCustomerNote(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan customer note", zap.Int("row", len(notes)+1), zap.Error(err), zap.Int("customer_id", customerID))
+			return nil, 0, fmt.Errorf("failed to scan customer note at row %d: %w", len(notes)+1, err)
+		}
+		notes = append(notes, *note)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating customer notes", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, 0, fmt.Errorf("error iterating customer notes: %w", err)
+	}
+	return notes, total, nil
+}
+
+// FindByID 根據 ID 獲取客戶的備註
+func (r *customerNoteRepositoryImpl) FindByID(ctx context.Context, customerID, id int) (*models.CustomerNote, error) {
+	query := `SELECT ` + customerNoteColumns + `
+              FROM customer_notes n
+              LEFT JOIN accounts a ON a.id = n.author_id
+              WHERE n.id = $1 AND n.customer_id = $2`
+	note, err := scanCustomerNote(r.db.QueryRowContext(ctx, query, id, customerID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get customer note by ID", zap.Int("id", id), zap.Int("customer_id", customerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get customer note %d: %w", id, err)
+	}
+	return note, nil
+}
+
+// Delete 刪除客戶的備註
+func (r *customerNoteRepositoryImpl) Delete(ctx context.Context, customerID, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM customer_notes WHERE id = $1 AND customer_id = $2`, id, customerID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete customer note", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to delete customer note %d: %w", id, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		return utils.ErrNotFound // 未找到要刪除的記錄
+	}
+	return nil
+}
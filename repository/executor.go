@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/wac0705/fastener-api/db"
+)
+
+// executor 抽象 *sql.DB 與 *sql.Tx 共有的查詢方法，也滿足 sqlc 產生的 queries.DBTX
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn 返回執行查詢的對象：ctx 在 db.TxManager.WithinTx 的事務中時為該事務，否則為連接池
+func conn(ctx context.Context, pool *sql.DB) executor {
+	if tx, ok := db.TxFromContext(ctx); ok {
+		return tx
+	}
+	return pool
+}
+
+// readConn 返回執行純讀取查詢的對象：ctx 在事務中時為該事務，ctx 以 db.WithPrimary 標記或 reader 為 nil 時為主庫 (writer)，否則為唯讀副本 (reader)
+// 只用於可容忍副本延遲的讀取 (列表、依 ID 查詢)；寫入與寫入前的檢查使用 conn
+func readConn(ctx context.Context, writer, reader *sql.DB) executor {
+	if tx, ok := db.TxFromContext(ctx); ok {
+		return tx
+	}
+	if reader == nil || db.UsesPrimary(ctx) {
+		return writer
+	}
+	return reader
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// errConnectionReset 模擬查詢或事務途中斷線的資料庫錯誤
+var errConnectionReset = errors.New("connection reset by peer")
+
+// newMockDB 返回以 sqlmock 模擬的資料庫，測試結束時檢查所有預期的語句都已依序執行
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = database.Close()
+	})
+	return database, mock
+}
+
+// menuRows 返回 n 列選單查詢結果，欄位順序與 menuColumns 相同
+func menuRows(n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "public_id", "name", "path", "icon", "parent_id", "display_order",
+		"created_at", "updated_at", "created_by", "created_by_username", "updated_by", "updated_by_username"})
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		rows.AddRow(i, fmt.Sprintf("00000000-0000-0000-0000-%012d", i), "menu", "/menu", "", nil, i, now, now, nil, nil, nil, nil)
+	}
+	return rows
+}
+
+// TestFindAllSurfacesIterationError 逐列讀取途中發生的錯誤 (rows.Err) 不可被當作結果已讀完，而返回截斷的列表
+func TestFindAllSurfacesIterationError(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		wantErr error
+	}{
+		{"error after first row", menuRows(3).RowError(1, errConnectionReset), errConnectionReset},
+		{"error on first row", menuRows(2).RowError(0, errConnectionReset), errConnectionReset},
+		{"error closing after last row", menuRows(2).CloseError(errConnectionReset), errConnectionReset}, // 驅動在讀完後關閉結果集失敗，同樣由 rows.Err 返回
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDB(t)
+			mock.ExpectQuery(`SELECT m\.id, .* FROM menus m`).WillReturnRows(tt.rows)
+
+			menus, err := NewMenuRepository(database, zap.NewNop()).FindAll(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FindAll error = %v, want %v", err, tt.wantErr)
+			}
+			if menus != nil {
+				t.Errorf("FindAll returned %d menus with an error, want nil", len(menus))
+			}
+		})
+	}
+}
+
+// TestFindAllSurfacesScanError 無法掃描的列返回帶有列號的錯誤
+func TestFindAllSurfacesScanError(t *testing.T) {
+	database, mock := newMockDB(t)
+	rows := menuRows(1)
+	rows.AddRow("not-a-number", "x", "menu", "/menu", "", nil, 2, time.Now(), time.Now(), nil, nil, nil, nil)
+	mock.ExpectQuery(`FROM menus m`).WillReturnRows(rows)
+
+	menus, err := NewMenuRepository(database, zap.NewNop()).FindAll(context.Background())
+	if err == nil || menus != nil {
+		t.Fatalf("FindAll = %v, %v, want a scan error", menus, err)
+	}
+	if want := "failed to scan menu data at row 2"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %q, want prefix %q", err, want)
+	}
+}
+
+// TestCompanyDeleteRollsBack 事務中任一語句失敗時回滾，且返回原本的錯誤而非已完成部分更新的結果；每個語句都以操作者 (7) 更新 updated_by
+func TestCompanyDeleteRollsBack(t *testing.T) {
+	const (
+		softDelete     = `UPDATE companies SET deleted_at = NOW\(\)`
+		detachChildren = `UPDATE companies SET parent_company_id = NULL`
+		detachCustomer = `UPDATE customers SET company_id = NULL`
+	)
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "fails after soft delete",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(softDelete).WithArgs(5, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(detachChildren).WithArgs(5, 7).WillReturnError(errConnectionReset)
+				mock.ExpectRollback()
+			},
+			wantErr: errConnectionReset,
+		},
+		{
+			name: "fails on last statement",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(softDelete).WithArgs(5, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(detachChildren).WithArgs(5, 7).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(detachCustomer).WithArgs(5, 7).WillReturnError(errConnectionReset)
+				mock.ExpectRollback()
+			},
+			wantErr: errConnectionReset,
+		},
+		{
+			name: "rows affected unavailable",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(softDelete).WithArgs(5, 7).WillReturnResult(sqlmock.NewErrorResult(errConnectionReset))
+				mock.ExpectRollback()
+			},
+			wantErr: errConnectionReset,
+		},
+		{
+			name: "not found",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(softDelete).WithArgs(5, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: utils.ErrNotFound,
+		},
+		{
+			name: "commit fails",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(softDelete).WithArgs(5, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(detachChildren).WithArgs(5, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(detachCustomer).WithArgs(5, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit().WillReturnError(errConnectionReset)
+			},
+			wantErr: errConnectionReset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := newMockDB(t)
+			mock.ExpectBegin()
+			tt.expect(mock)
+
+			err := NewCompanyRepository(database, database, zap.NewNop()).Delete(context.Background(), 5, 7)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Delete error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCompanyDeleteBeginFails 無法開始事務時直接返回錯誤，不執行任何語句
+func TestCompanyDeleteBeginFails(t *testing.T) {
+	database, mock := newMockDB(t)
+	mock.ExpectBegin().WillReturnError(errConnectionReset)
+
+	if err := NewCompanyRepository(database, database, zap.NewNop()).Delete(context.Background(), 5, 7); !errors.Is(err, errConnectionReset) {
+		t.Errorf("Delete error = %v, want %v", err, errConnectionReset)
+	}
+}
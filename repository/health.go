@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/wac0705/fastener-api/db"
+)
+
+// HealthRepository 定義健康檢查所需的資料庫操作介面
+type HealthRepository interface {
+	Ping(ctx context.Context) error // 依 ctx 的期限確認資料庫可連線
+	// MigrationVersion 返回 schema_migrations 中已執行的遷移版本與是否處於失敗 (dirty) 狀態；尚未執行過遷移時返回 0
+	MigrationVersion(ctx context.Context) (int64, bool, error)
+}
+
+// healthRepositoryImpl 實現 HealthRepository 介面
+type healthRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewHealthRepository 創建 HealthRepository 實例
+func NewHealthRepository(db *sql.DB) HealthRepository {
+	return &healthRepositoryImpl{db: db}
+}
+
+// Ping 確認資料庫可連線 (錯誤由服務層記錄，避免探針頻繁呼叫時重複記錄)
+func (r *healthRepositoryImpl) Ping(ctx context.Context) error {
+	if err := r.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// MigrationVersion 讀取 migrate 工具記錄的遷移版本
+func (r *healthRepositoryImpl) MigrationVersion(ctx context.Context) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := r.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		if err == sql.ErrNoRows || db.IsUndefinedTable(err) { // 資料表不存在
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to get schema migration version: %w", err)
+	}
+	return version, dirty, nil
+}
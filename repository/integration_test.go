@@ -0,0 +1,1041 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/db/dbtest"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+	"github.com/wac0705/fastener-api/utils/query"
+)
+
+// checkErr 比對 Repository 返回的錯誤：wantCode 為 0 時不應有錯誤，-1 表示未轉換的資料庫錯誤 (不是 CustomError)，
+// 其他值為 CustomError 的狀態碼 (例如唯一衝突的 409、找不到記錄的 404)
+func checkErr(t *testing.T, err error, wantCode int) {
+	t.Helper()
+	var customErr *utils.CustomError
+	switch {
+	case wantCode == 0 && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case wantCode == 0:
+	case err == nil:
+		t.Fatalf("error = nil, want %d", wantCode)
+	case wantCode == -1 && errors.As(err, &customErr):
+		t.Fatalf("error = %v (CustomError %d), want an unmapped database error", err, customErr.Code)
+	case wantCode != -1 && !errors.As(err, &customErr):
+		t.Fatalf("error = %v (%T), want CustomError %d", err, err, wantCode)
+	case wantCode != -1 && customErr.Code != wantCode:
+		t.Fatalf("error = %v, want CustomError %d", err, wantCode)
+	}
+}
+
+func TestAccountRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewAccountRepository(database, zaptest.NewLogger(t))
+	roleID := dbtest.SeedRole(t, database, "sales")
+	existingID := dbtest.SeedAccount(t, database, "alice", roleID)
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			username string
+			roleID   int
+			wantCode int
+		}{
+			{name: "new username", username: "bob", roleID: roleID},
+			{name: "duplicate username", username: "alice", roleID: roleID, wantCode: http.StatusConflict},
+			{name: "unknown role", username: "carol", roleID: roleID + 100, wantCode: -1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				account := &models.Account{Username: tt.username, Password: "hash", RoleID: tt.roleID}
+				err := repo.Create(ctx, account)
+				checkErr(t, err, tt.wantCode)
+				if err == nil && (account.ID == 0 || account.PublicID == "" || account.CreatedAt.IsZero()) {
+					t.Errorf("Create did not fill the generated columns: %+v", account)
+				}
+			})
+		}
+	})
+
+	t.Run("FindByID", func(t *testing.T) {
+		found, err := repo.FindByID(ctx, existingID)
+		checkErr(t, err, 0)
+		if found == nil || found.Username != "alice" || found.RoleName != "sales" {
+			t.Fatalf("FindByID = %+v, want alice with role sales", found)
+		}
+		missing, err := repo.FindByID(ctx, existingID+100)
+		checkErr(t, err, 0)
+		if missing != nil {
+			t.Errorf("FindByID(missing) = %+v, want nil", missing)
+		}
+	})
+
+	t.Run("DeleteAndRestore", func(t *testing.T) {
+		id := dbtest.SeedAccount(t, database, "dave", roleID)
+		checkErr(t, repo.Delete(ctx, id), 0)
+		checkErr(t, repo.Delete(ctx, id), http.StatusNotFound) // 已刪除
+		if found, _ := repo.FindByID(ctx, id); found != nil {
+			t.Errorf("deleted account is still returned: %+v", found)
+		}
+		if found, _ := repo.FindByID(ctx, id, FindOptions{IncludeDeleted: true}); found == nil || found.DeletedAt == nil {
+			t.Errorf("FindByID(IncludeDeleted) = %+v, want the account with deleted_at", found)
+		}
+		if found, _ := repo.FindByUsername(ctx, "dave"); found != nil {
+			t.Errorf("deleted account can still log in: %+v", found)
+		}
+
+		// 刪除期間用戶名被其他帳戶使用，還原時返回 409
+		otherID := dbtest.SeedAccount(t, database, "dave", roleID)
+		checkErr(t, repo.Restore(ctx, id), http.StatusConflict)
+		checkErr(t, repo.Delete(ctx, otherID), 0)
+		checkErr(t, repo.Restore(ctx, id), 0)
+		checkErr(t, repo.Restore(ctx, id), http.StatusNotFound) // 未刪除
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		erinID := dbtest.SeedAccount(t, database, "erin", roleID)
+		checkErr(t, repo.Update(ctx, &models.Account{ID: erinID, Username: "alice", RoleID: roleID}), http.StatusConflict)
+		checkErr(t, repo.Update(ctx, &models.Account{ID: erinID + 100, Username: "nobody", RoleID: roleID}), http.StatusNotFound)
+		checkErr(t, repo.UpdatePassword(ctx, erinID+100, "hash"), http.StatusNotFound)
+	})
+}
+
+func TestCompanyRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewCompanyRepository(database, nil, zaptest.NewLogger(t))
+	parentID := dbtest.SeedCompany(t, database, "Fastener Group", nil)
+
+	tests := []struct {
+		name     string
+		company  models.Company
+		wantCode int
+	}{
+		{name: "no parent", company: models.Company{Name: "Standalone Ltd"}},
+		{name: "with parent", company: models.Company{Name: "Subsidiary Ltd", ParentCompanyID: &parentID}},
+		{name: "with tax ID", company: models.Company{Name: "Tax Ltd", TaxID: "12345678"}},
+		{name: "duplicate name", company: models.Company{Name: "Fastener Group"}, wantCode: http.StatusConflict},
+		{name: "duplicate tax ID", company: models.Company{Name: "Tax Again Ltd", TaxID: "12345678"}, wantCode: http.StatusConflict},
+		{name: "unknown parent", company: models.Company{Name: "Orphan Ltd", ParentCompanyID: intPtr(parentID + 100)}, wantCode: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := tt.company
+			err := repo.Create(ctx, &company, 0)
+			checkErr(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			// parent_company_id 為 NULL 與有值都能原樣讀回
+			found, err := repo.FindByID(ctx, company.ID)
+			checkErr(t, err, 0)
+			if found == nil || found.Name != tt.company.Name {
+				t.Fatalf("FindByID = %+v, want %s", found, tt.company.Name)
+			}
+			if !equalIntPtr(found.ParentCompanyID, tt.company.ParentCompanyID) {
+				t.Errorf("ParentCompanyID = %v, want %v", found.ParentCompanyID, tt.company.ParentCompanyID)
+			}
+			if found.CreatedBy != nil {
+				t.Errorf("CreatedBy = %v, want NULL for a system write", *found.CreatedBy)
+			}
+		})
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		found, err := repo.FindByID(ctx, parentID+100)
+		checkErr(t, err, 0)
+		if found != nil {
+			t.Errorf("FindByID(missing) = %+v, want nil", found)
+		}
+		checkErr(t, repo.Update(ctx, &models.Company{ID: parentID + 100, Name: "Nobody"}, 0), http.StatusNotFound)
+		checkErr(t, repo.Delete(ctx, parentID+100, 0), http.StatusNotFound)
+		checkErr(t, repo.Restore(ctx, parentID, 0), http.StatusNotFound) // 未刪除
+	})
+
+	t.Run("DeleteDetachesChildren", func(t *testing.T) {
+		groupID := dbtest.SeedCompany(t, database, "Deleted Group", nil)
+		childID := dbtest.SeedCompany(t, database, "Deleted Group Child", &groupID)
+		checkErr(t, repo.Delete(ctx, groupID, 0), 0)
+		child, err := repo.FindByID(ctx, childID)
+		checkErr(t, err, 0)
+		if child == nil || child.ParentCompanyID != nil {
+			t.Errorf("child after parent delete = %+v, want no parent", child)
+		}
+		checkErr(t, repo.Restore(ctx, groupID, 0), 0)
+	})
+}
+
+func TestCustomerRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewCustomerRepository(database, nil, zaptest.NewLogger(t))
+	companyID := dbtest.SeedCompany(t, database, "Customer Holdings", nil)
+
+	tests := []struct {
+		name     string
+		customer models.Customer
+		wantCode int
+	}{
+		{name: "no company", customer: models.Customer{Name: "Walk-in", Email: "walkin@example.com", Status: "prospect"}},
+		{name: "with company", customer: models.Customer{Name: "Account", Email: "buyer@example.com", CompanyID: &companyID, Status: "active"}},
+		{name: "duplicate email ignores case", customer: models.Customer{Name: "Again", Email: "Buyer@Example.com", Status: "prospect"}, wantCode: http.StatusConflict},
+		{name: "unknown company", customer: models.Customer{Name: "Lost", Email: "lost@example.com", CompanyID: intPtr(companyID + 100), Status: "prospect"}, wantCode: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			customer := tt.customer
+			err := repo.Create(ctx, &customer, "C", 0, nil)
+			checkErr(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			// company_id 為 NULL 與有值都能原樣讀回，公司名稱由 JOIN 取得
+			found, err := repo.FindByID(ctx, customer.ID)
+			checkErr(t, err, 0)
+			if found == nil || found.Code == "" {
+				t.Fatalf("FindByID = %+v, want the customer with a generated code", found)
+			}
+			if !equalIntPtr(found.CompanyID, tt.customer.CompanyID) {
+				t.Errorf("CompanyID = %v, want %v", found.CompanyID, tt.customer.CompanyID)
+			}
+			if (found.CompanyName == nil) != (tt.customer.CompanyID == nil) {
+				t.Errorf("CompanyName = %v with CompanyID %v", found.CompanyName, tt.customer.CompanyID)
+			}
+		})
+	}
+
+	t.Run("ConflictDetails", func(t *testing.T) {
+		err := repo.Create(ctx, &models.Customer{Name: "Dup", Email: "walkin@example.com", Status: "prospect"}, "C", 0, nil)
+		var customErr *utils.CustomError
+		if !errors.As(err, &customErr) {
+			t.Fatalf("error = %v, want CustomError", err)
+		}
+		conflict, ok := customErr.Details.(models.CustomerConflict)
+		if !ok || conflict.Field != "email" || conflict.ExistingCustomerID == 0 {
+			t.Errorf("details = %#v, want the email conflict with the existing customer ID", customErr.Details)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		found, err := repo.FindByID(ctx, 1000)
+		checkErr(t, err, 0)
+		if found != nil {
+			t.Errorf("FindByID(missing) = %+v, want nil", found)
+		}
+		checkErr(t, repo.Delete(ctx, 1000, 0, nil), http.StatusNotFound)
+		checkErr(t, repo.Restore(ctx, 1000, 0, nil), http.StatusNotFound)
+	})
+}
+
+func TestRoleAndPermissionRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	roles := NewRoleRepository(database, zaptest.NewLogger(t))
+	permissions := NewPermissionRepository(database, zaptest.NewLogger(t))
+	adminID := dbtest.SeedRoleWithPermissions(t, database, "admin", "account:read", "account:create")
+	readID := dbtest.SeedPermission(t, database, "customer:read")
+
+	t.Run("CreateRole", func(t *testing.T) {
+		checkErr(t, roles.Create(ctx, &models.Role{Name: "auditor"}, 0), 0)
+		checkErr(t, roles.Create(ctx, &models.Role{Name: "admin"}, 0), http.StatusConflict)
+	})
+
+	t.Run("DeleteRole", func(t *testing.T) {
+		usedID := dbtest.SeedRole(t, database, "in-use")
+		dbtest.SeedAccount(t, database, "member", usedID)
+		tests := []struct {
+			name     string
+			id       int
+			wantCode int
+		}{
+			{name: "assigned to an account", id: usedID, wantCode: http.StatusConflict},
+			{name: "unused", id: dbtest.SeedRole(t, database, "unused")},
+			{name: "missing", id: usedID + 100, wantCode: http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				checkErr(t, roles.Delete(ctx, tt.id), tt.wantCode)
+			})
+		}
+	})
+
+	t.Run("AssignPermissions", func(t *testing.T) {
+		// 已擁有的權限略過，不算在新增的筆數中
+		created, err := permissions.AssignPermissionsBatch(ctx, []models.RolePermission{
+			{RoleID: adminID, PermissionID: readID},
+			{RoleID: adminID, PermissionID: readID},
+		})
+		checkErr(t, err, 0)
+		if created != 1 {
+			t.Errorf("AssignPermissionsBatch created %d, want 1", created)
+		}
+		checkErr(t, permissions.AssignPermissionToRole(ctx, adminID, readID), 0) // 重複賦予不是錯誤
+		checkErr(t, permissions.AssignPermissionToRole(ctx, adminID, readID+100), -1)
+
+		granted, err := permissions.FindPermissionsByRoleID(ctx, adminID)
+		checkErr(t, err, 0)
+		if len(granted) != 3 {
+			t.Errorf("admin has %d permissions, want 3: %+v", len(granted), granted)
+		}
+
+		copyID := dbtest.SeedRole(t, database, "admin-copy")
+		checkErr(t, permissions.CopyRolePermissions(ctx, adminID, copyID), 0)
+		copied, err := permissions.FindPermissionsByRoleID(ctx, copyID)
+		checkErr(t, err, 0)
+		if len(copied) != len(granted) {
+			t.Errorf("copied %d permissions, want %d", len(copied), len(granted))
+		}
+
+		checkErr(t, permissions.RevokePermissionFromRole(ctx, copyID, readID), 0)
+		checkErr(t, permissions.RevokePermissionFromRole(ctx, copyID, readID), -1) // 已撤銷
+	})
+
+	t.Run("FindPermission", func(t *testing.T) {
+		found, err := permissions.FindByName(ctx, "customer:read")
+		checkErr(t, err, 0)
+		if found == nil || found.ID != readID {
+			t.Errorf("FindByName = %+v, want ID %d", found, readID)
+		}
+		missing, err := permissions.FindByID(ctx, readID+100)
+		checkErr(t, err, 0)
+		if missing != nil {
+			t.Errorf("FindByID(missing) = %+v, want nil", missing)
+		}
+	})
+}
+
+// TestSoftDeleteIntegration 帳戶、公司與客戶的軟刪除行為一致：刪除後預設查不到、IncludeDeleted 時仍可讀取，
+// 重複刪除與還原未刪除的記錄返回 404，還原後恢復正常查詢；有 updated_by 的記錄在刪除與還原時記錄操作者
+func TestSoftDeleteIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	accounts := NewAccountRepository(database, logger)
+	companies := NewCompanyRepository(database, nil, logger)
+	customers := NewCustomerRepository(database, nil, logger)
+	roleID := dbtest.SeedRole(t, database, "user")
+	actorID := dbtest.SeedAccount(t, database, "deleter", roleID)
+
+	entities := []struct {
+		name      string
+		create    func(t *testing.T) int
+		find      func(id int, opts ...FindOptions) (bool, error)
+		updatedBy func(id int) *int // 帳戶沒有 updated_by 時為 nil
+		delete    func(id int) error
+		restore   func(id int) error
+	}{
+		{
+			name:   "account",
+			create: func(t *testing.T) int { return dbtest.SeedAccount(t, database, "deleted-user", roleID) },
+			find: func(id int, opts ...FindOptions) (bool, error) {
+				found, err := accounts.FindByID(ctx, id, opts...)
+				return found != nil, err
+			},
+			delete:  func(id int) error { return accounts.Delete(ctx, id) },
+			restore: func(id int) error { return accounts.Restore(ctx, id) },
+		},
+		{
+			name:   "company",
+			create: func(t *testing.T) int { return dbtest.SeedCompany(t, database, "Deleted Ltd", nil) },
+			find: func(id int, opts ...FindOptions) (bool, error) {
+				found, err := companies.FindByID(ctx, id, opts...)
+				return found != nil, err
+			},
+			updatedBy: func(id int) *int {
+				found, _ := companies.FindByID(ctx, id, FindOptions{IncludeDeleted: true})
+				return found.UpdatedBy
+			},
+			delete:  func(id int) error { return companies.Delete(ctx, id, actorID) },
+			restore: func(id int) error { return companies.Restore(ctx, id, actorID) },
+		},
+		{
+			name: "customer",
+			create: func(t *testing.T) int {
+				customer := models.Customer{Name: "Deleted Customer", Email: "deleted@example.com", Status: "active"}
+				checkErr(t, customers.Create(ctx, &customer, "C", 0, nil), 0)
+				return customer.ID
+			},
+			find: func(id int, opts ...FindOptions) (bool, error) {
+				found, err := customers.FindByID(ctx, id, opts...)
+				return found != nil, err
+			},
+			updatedBy: func(id int) *int {
+				found, _ := customers.FindByID(ctx, id, FindOptions{IncludeDeleted: true})
+				return found.UpdatedBy
+			},
+			delete:  func(id int) error { return customers.Delete(ctx, id, actorID, nil) },
+			restore: func(id int) error { return customers.Restore(ctx, id, actorID, nil) },
+		},
+	}
+	for _, entity := range entities {
+		t.Run(entity.name, func(t *testing.T) {
+			id := entity.create(t)
+			checkFound := func(step string, want, wantIncludingDeleted bool) {
+				t.Helper()
+				found, err := entity.find(id)
+				checkErr(t, err, 0)
+				foundDeleted, err := entity.find(id, FindOptions{IncludeDeleted: true})
+				checkErr(t, err, 0)
+				if found != want || foundDeleted != wantIncludingDeleted {
+					t.Errorf("%s: found = %v, found with IncludeDeleted = %v; want %v, %v", step, found, foundDeleted, want, wantIncludingDeleted)
+				}
+				if entity.updatedBy != nil {
+					if updatedBy := entity.updatedBy(id); updatedBy == nil || *updatedBy != actorID {
+						t.Errorf("%s: updated_by = %v, want %d", step, updatedBy, actorID)
+					}
+				}
+			}
+
+			checkErr(t, entity.restore(id), http.StatusNotFound) // 未刪除
+			checkErr(t, entity.delete(id), 0)
+			checkFound("after delete", false, true)
+			checkErr(t, entity.delete(id), http.StatusNotFound) // 已刪除
+			checkErr(t, entity.restore(id), 0)
+			checkFound("after restore", true, true)
+			checkErr(t, entity.delete(id+100), http.StatusNotFound)
+			checkErr(t, entity.restore(id+100), http.StatusNotFound)
+		})
+	}
+}
+
+// TestTxRollbackIntegration 以 db.TxManager 的 ctx 呼叫多個 Repository 方法時，其中一個失敗會回滾之前的寫入
+func TestTxRollbackIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	roles := NewRoleRepository(database, zaptest.NewLogger(t))
+	dbtest.SeedRole(t, database, "existing")
+
+	err := db.NewTxManager(database, db.RetryConfig{}).WithinTx(ctx, func(ctx context.Context) error {
+		if err := roles.Create(ctx, &models.Role{Name: "rolled-back"}, 0); err != nil {
+			return err
+		}
+		return roles.Create(ctx, &models.Role{Name: "existing"}, 0) // 唯一衝突
+	})
+	checkErr(t, err, http.StatusConflict)
+	if found, err := roles.FindByName(ctx, "rolled-back"); err != nil || found != nil {
+		t.Errorf("FindByName(rolled-back) = %+v, %v; want the insert rolled back", found, err)
+	}
+}
+
+// TestTxDeadlockRetryIntegration 兩個事務以相反順序鎖住同樣的兩列造成死結，PostgreSQL 中止其中一個 (40P01)，
+// WithinTx 以新的事務重試後兩者都成功
+func TestTxDeadlockRetryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	first, second := dbtest.SeedRole(t, database, "deadlock-a"), dbtest.SeedRole(t, database, "deadlock-b")
+	manager := db.NewTxManager(database, db.RetryConfig{MaxRetries: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+
+	var locked sync.WaitGroup
+	locked.Add(2)
+	var attempts atomic.Int32
+	lockBoth := func(ids ...int) error {
+		firstAttempt := true
+		return manager.WithinTx(ctx, func(ctx context.Context) error {
+			attempts.Add(1)
+			tx, _ := db.TxFromContext(ctx)
+			for i, id := range ids {
+				if _, err := tx.ExecContext(ctx, `UPDATE roles SET name = name WHERE id = $1`, id); err != nil {
+					return err
+				}
+				if i == 0 && firstAttempt { // 兩個事務都鎖住第一列後才鎖第二列；重試時不再等待
+					firstAttempt = false
+					locked.Done()
+					locked.Wait()
+				}
+			}
+			return nil
+		})
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- lockBoth(first, second) }()
+	go func() { errs <- lockBoth(second, first) }()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("WithinTx = %v, want the deadlock victim to succeed on retry", err)
+		}
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("transaction bodies ran %d times, want 3 (one retry after the deadlock)", n)
+	}
+}
+
+// TestTimestampDSTIntegration America/New_York 夏令時間切換前後的時間點寫入 timestamptz 後讀回為 UTC 的同一時間點，
+// 以帶時差的 RFC 3339 篩選時比較時間點 (撥快前後只相隔 1 秒、重複的 01:30 相隔 1 小時)，日期篩選的界線為 UTC 的午夜
+func TestTimestampDSTIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	est, edt := time.FixedZone("EST", -5*3600), time.FixedZone("EDT", -4*3600)
+	instants := []time.Time{
+		time.Date(2024, 3, 10, 1, 59, 59, 0, est), // 撥快前最後一秒
+		time.Date(2024, 3, 10, 3, 0, 0, 0, edt),   // 撥快後第一秒
+		time.Date(2024, 11, 3, 1, 30, 0, 0, edt),  // 第一次 01:30
+		time.Date(2024, 11, 3, 1, 30, 0, 0, est),  // 第二次 01:30
+		time.Date(2024, 11, 3, 23, 30, 0, 0, est), // UTC 已是 11 月 4 日
+	}
+
+	for _, instant := range instants {
+		var got time.Time
+		var utcDate string
+		if err := database.QueryRowContext(ctx, `SELECT $1::timestamptz, ($1::timestamptz)::date::text`, instant).Scan(&got, &utcDate); err != nil {
+			t.Fatal(err)
+		}
+		if got.Location() != time.UTC || !got.Equal(instant) {
+			t.Errorf("%v read back as %v, want the same instant in UTC", instant, got)
+		}
+		if want := instant.UTC().Format("2006-01-02"); utcDate != want {
+			t.Errorf("date of %v = %s, want %s (UTC)", instant, utcDate, want)
+		}
+	}
+
+	count := func(params url.Values) int {
+		t.Helper()
+		q, customErr := query.Parse(params, query.Spec{Fields: map[string]query.Field{
+			"created_at": {Type: query.TypeTime, Operators: query.Range},
+			"created_on": {Type: query.TypeDate, Operators: query.Range},
+		}})
+		if customErr != nil {
+			t.Fatal(customErr)
+		}
+		args := []interface{}{instants[0], instants[1], instants[2], instants[3], instants[4]}
+		where, args, err := q.Where(map[string]string{"created_at": "t.created_at", "created_on": "t.created_at"}, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		err = database.QueryRowContext(ctx, `SELECT COUNT(*) FROM (VALUES ($1::timestamptz), ($2), ($3), ($4), ($5)) AS t(created_at) WHERE `+where, args...).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tests := []struct {
+		params url.Values
+		want   int
+	}{
+		{params: url.Values{"created_at_gt": {"2024-03-10T01:59:59-05:00"}, "created_at_lt": {"2024-03-10T03:00:01-04:00"}}, want: 1},
+		{params: url.Values{"created_at_gte": {"2024-11-03T01:30:00-04:00"}, "created_at_lt": {"2024-11-03T01:30:00-05:00"}}, want: 1},
+		{params: url.Values{"created_at_lte": {"2024-11-03T01:30:00-05:00"}}, want: 4},
+		{params: url.Values{"created_on": {"2024-11-03"}}, want: 2},
+		{params: url.Values{"created_on_gte": {"2024-11-04"}}, want: 1},
+	}
+	for _, tt := range tests {
+		if got := count(tt.params); got != tt.want {
+			t.Errorf("%s matched %d, want %d", tt.params.Encode(), got, tt.want)
+		}
+	}
+}
+
+// TestMenuRepositoryIntegration 選單的新增、查詢、更新與刪除；parent_id 與 icon 為 NULL 時都能讀回 (初始遷移的選單沒有圖標)，
+// 父選單刪除後子選單的 parent_id 由外鍵設為 NULL
+func TestMenuRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewMenuRepository(database, zaptest.NewLogger(t))
+	actorID := dbtest.SeedAccount(t, database, "menu_admin", dbtest.SeedRole(t, database, "menu_role"))
+	rootID := dbtest.SeedMenu(t, database, "Dashboard", "/dashboard", nil)
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			menu     models.Menu
+			wantCode int
+		}{
+			{name: "no parent", menu: models.Menu{Name: "Reports", Path: "/reports", Icon: "chart", DisplayOrder: 2}},
+			{name: "with parent", menu: models.Menu{Name: "Sales", Path: "/dashboard/sales", ParentID: &rootID, DisplayOrder: 1}},
+			{name: "duplicate path", menu: models.Menu{Name: "Again", Path: "/dashboard"}, wantCode: http.StatusConflict},
+			{name: "unknown parent", menu: models.Menu{Name: "Orphan", Path: "/orphan", ParentID: intPtr(rootID + 100)}, wantCode: -1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				menu := tt.menu
+				err := repo.Create(ctx, &menu, actorID)
+				checkErr(t, err, tt.wantCode)
+				if err != nil {
+					return
+				}
+				found, err := repo.FindByID(ctx, menu.ID)
+				checkErr(t, err, 0)
+				if found == nil || found.Path != tt.menu.Path || found.Icon != tt.menu.Icon || found.PublicID != menu.PublicID {
+					t.Fatalf("FindByID = %+v, want %+v", found, menu)
+				}
+				if !equalIntPtr(found.ParentID, tt.menu.ParentID) {
+					t.Errorf("ParentID = %v, want %v", found.ParentID, tt.menu.ParentID)
+				}
+				if found.CreatedBy == nil || *found.CreatedBy != actorID || found.CreatedByUsername == nil || *found.CreatedByUsername != "menu_admin" {
+					t.Errorf("CreatedBy = %v (%v), want %d (menu_admin)", found.CreatedBy, found.CreatedByUsername, actorID)
+				}
+			})
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		root, err := repo.FindByPath(ctx, "/dashboard")
+		checkErr(t, err, 0)
+		if root == nil || root.ID != rootID || root.ParentID != nil || root.Icon != "" {
+			t.Fatalf("FindByPath = %+v, want menu %d without parent or icon", root, rootID)
+		}
+		byPublicID, err := repo.FindByPublicID(ctx, root.PublicID)
+		checkErr(t, err, 0)
+		if byPublicID == nil || byPublicID.ID != rootID {
+			t.Errorf("FindByPublicID = %+v, want menu %d", byPublicID, rootID)
+		}
+
+		all, err := repo.FindAll(ctx)
+		checkErr(t, err, 0)
+		for i := 1; i < len(all); i++ {
+			if all[i-1].DisplayOrder > all[i].DisplayOrder {
+				t.Errorf("FindAll is not ordered by display_order: %+v", all)
+				break
+			}
+		}
+
+		for name, find := range map[string]func() (*models.Menu, error){
+			"FindByID":       func() (*models.Menu, error) { return repo.FindByID(ctx, rootID+100) },
+			"FindByPath":     func() (*models.Menu, error) { return repo.FindByPath(ctx, "/missing") },
+			"FindByPublicID": func() (*models.Menu, error) { return repo.FindByPublicID(ctx, "00000000-0000-0000-0000-000000000000") },
+		} {
+			missing, err := find()
+			checkErr(t, err, 0)
+			if missing != nil {
+				t.Errorf("%s(missing) = %+v, want nil", name, missing)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		childID := dbtest.SeedMenu(t, database, "Child", "/dashboard/child", &rootID)
+		tests := []struct {
+			name     string
+			menu     models.Menu
+			wantCode int
+		}{
+			{name: "clear parent", menu: models.Menu{ID: childID, Name: "Child", Path: "/child", Icon: "leaf"}},
+			{name: "set parent", menu: models.Menu{ID: childID, Name: "Child", Path: "/child", ParentID: &rootID}},
+			{name: "duplicate path", menu: models.Menu{ID: childID, Name: "Child", Path: "/dashboard"}, wantCode: http.StatusConflict},
+			{name: "missing", menu: models.Menu{ID: rootID + 100, Name: "Nobody", Path: "/nobody"}, wantCode: http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				menu := tt.menu
+				err := repo.Update(ctx, &menu, actorID)
+				checkErr(t, err, tt.wantCode)
+				if err != nil {
+					return
+				}
+				found, err := repo.FindByID(ctx, menu.ID)
+				checkErr(t, err, 0)
+				if found == nil || found.Path != tt.menu.Path || found.Icon != tt.menu.Icon || !equalIntPtr(found.ParentID, tt.menu.ParentID) {
+					t.Errorf("after Update = %+v, want %+v", found, tt.menu)
+				}
+			})
+		}
+	})
+
+	t.Run("DeleteDetachesChildren", func(t *testing.T) {
+		parentID := dbtest.SeedMenu(t, database, "Settings", "/settings", nil)
+		childID := dbtest.SeedMenu(t, database, "Users", "/settings/users", &parentID)
+		checkErr(t, repo.Delete(ctx, parentID), 0)
+		checkErr(t, repo.Delete(ctx, parentID), http.StatusNotFound)
+		child, err := repo.FindByID(ctx, childID)
+		checkErr(t, err, 0)
+		if child == nil || child.ParentID != nil {
+			t.Errorf("child after parent delete = %+v, want no parent", child)
+		}
+	})
+}
+
+func TestRoleMenuRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewRoleMenuRepository(database, zaptest.NewLogger(t))
+	salesID := dbtest.SeedRole(t, database, "sales")
+	managerID := dbtest.SeedRole(t, database, "manager")
+	dashboardID := dbtest.SeedMenu(t, database, "Dashboard", "/dashboard", nil)
+	reportsID := dbtest.SeedMenu(t, database, "Reports", "/reports", nil)
+	if _, err := database.Exec(`UPDATE menus SET display_order = 1 WHERE id = $1`, reportsID); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		checkErr(t, repo.Create(ctx, &models.RoleMenu{RoleID: salesID, MenuID: dashboardID}), 0)
+		checkErr(t, repo.Create(ctx, &models.RoleMenu{RoleID: salesID, MenuID: dashboardID}), 0) // 已存在時略過
+		checkErr(t, repo.Create(ctx, &models.RoleMenu{RoleID: salesID, MenuID: dashboardID + 100}), -1)
+
+		created, err := repo.CreateBatch(ctx, []models.RoleMenu{
+			{RoleID: salesID, MenuID: dashboardID},
+			{RoleID: salesID, MenuID: reportsID},
+		})
+		checkErr(t, err, 0)
+		if created != 1 {
+			t.Errorf("CreateBatch created %d, want 1", created)
+		}
+	})
+
+	t.Run("FindAll", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			roleID, menuID *int
+			want           int
+		}{
+			{name: "no filter", want: 2},
+			{name: "by role", roleID: &salesID, want: 2},
+			{name: "by menu", menuID: &reportsID, want: 1},
+			{name: "by role and menu", roleID: &managerID, menuID: &reportsID, want: 0},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				details, err := repo.FindAll(ctx, tt.roleID, tt.menuID)
+				checkErr(t, err, 0)
+				if len(details) != tt.want {
+					t.Fatalf("FindAll = %+v, want %d relations", details, tt.want)
+				}
+				for _, detail := range details {
+					if detail.RoleName != "sales" || detail.MenuName == "" || detail.MenuPath == "" {
+						t.Errorf("detail = %+v, want role and menu names from the JOIN", detail)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("FindMenusByRoleID", func(t *testing.T) {
+		menus, err := repo.FindMenusByRoleID(ctx, salesID)
+		checkErr(t, err, 0)
+		if len(menus) != 2 || menus[0].ID != dashboardID || menus[1].ID != reportsID {
+			t.Fatalf("FindMenusByRoleID = %+v, want dashboard then reports", menus)
+		}
+		if menus[0].Icon != "" || menus[0].ParentID != nil { // icon 與 parent_id 都是 NULL
+			t.Errorf("dashboard = %+v, want no icon or parent", menus[0])
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		checkErr(t, repo.Update(ctx, salesID, reportsID, managerID, reportsID), 0)
+		checkErr(t, repo.Update(ctx, salesID, reportsID, managerID, reportsID), http.StatusNotFound)
+		checkErr(t, repo.Update(ctx, managerID, reportsID, managerID, reportsID+100), -1)
+		moved, err := repo.FindAll(ctx, &managerID, &reportsID)
+		checkErr(t, err, 0)
+		if len(moved) != 1 {
+			t.Errorf("manager relations = %+v, want reports (the failed update rolled back)", moved)
+		}
+	})
+
+	t.Run("CopyAndDelete", func(t *testing.T) {
+		checkErr(t, repo.CopyRoleMenus(ctx, salesID, managerID), 0)
+		copied, err := repo.FindMenusByRoleID(ctx, managerID)
+		checkErr(t, err, 0)
+		if len(copied) != 2 {
+			t.Fatalf("manager menus after copy = %+v, want dashboard and reports", copied)
+		}
+
+		checkErr(t, repo.Delete(ctx, managerID, dashboardID), 0)
+		checkErr(t, repo.Delete(ctx, managerID, dashboardID), http.StatusNotFound)
+		checkErr(t, repo.DeleteByRoleID(ctx, managerID), 0)
+		left, err := repo.FindAll(ctx, &managerID, nil)
+		checkErr(t, err, 0)
+		if len(left) != 0 {
+			t.Errorf("manager relations after DeleteByRoleID = %+v, want none", left)
+		}
+	})
+}
+
+// TestCustomerAddressRepositoryIntegration 每個客戶每種類型最多一個預設地址：新增或更新為預設時在同一事務中取消原本的預設，
+// PromoteDefault 只在沒有預設地址時將最早的地址設為預設
+func TestCustomerAddressRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewCustomerAddressRepository(database, zaptest.NewLogger(t))
+	customerID := dbtest.SeedCustomer(t, database, "C0001", "Address Customer")
+	newAddress := func(addressType string, isDefault bool) *models.CustomerAddress {
+		return &models.CustomerAddress{CustomerID: customerID, Type: addressType, Line1: "1 Bolt Road", City: "Taipei", Country: "TW", IsDefault: isDefault}
+	}
+	defaults := func(t *testing.T, addressType string) []int {
+		t.Helper()
+		addresses, err := repo.FindByCustomerID(ctx, customerID)
+		checkErr(t, err, 0)
+		ids := []int{}
+		for _, address := range addresses {
+			if address.Type == addressType && address.IsDefault {
+				ids = append(ids, address.ID)
+			}
+		}
+		return ids
+	}
+
+	first := newAddress("billing", true)
+	checkErr(t, repo.Create(ctx, first), 0)
+	second := newAddress("billing", true)
+	checkErr(t, repo.Create(ctx, second), 0)
+	shipping := newAddress("shipping", true)
+	checkErr(t, repo.Create(ctx, shipping), 0)
+
+	t.Run("Create", func(t *testing.T) {
+		if got := defaults(t, "billing"); len(got) != 1 || got[0] != second.ID {
+			t.Errorf("billing defaults = %v, want only the newest %d", got, second.ID)
+		}
+		if got := defaults(t, "shipping"); len(got) != 1 || got[0] != shipping.ID {
+			t.Errorf("shipping defaults = %v, want %d (other types are not affected)", got, shipping.ID)
+		}
+		checkErr(t, repo.Create(ctx, newAddress("office", false)), -1)
+		unknown := newAddress("billing", false)
+		unknown.CustomerID = customerID + 100
+		checkErr(t, repo.Create(ctx, unknown), -1)
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		addresses, err := repo.FindByCustomerID(ctx, customerID)
+		checkErr(t, err, 0)
+		if len(addresses) != 3 || addresses[0].ID != second.ID || addresses[1].ID != first.ID || addresses[2].ID != shipping.ID {
+			t.Fatalf("FindByCustomerID = %+v, want billing (default first) then shipping", addresses)
+		}
+		found, err := repo.FindByID(ctx, customerID, first.ID)
+		checkErr(t, err, 0)
+		if found == nil || found.Line1 != "1 Bolt Road" || found.Line2 != "" || found.Country != "TW" {
+			t.Errorf("FindByID = %+v", found)
+		}
+		other, err := repo.FindByID(ctx, customerID+100, first.ID)
+		checkErr(t, err, 0)
+		if other != nil {
+			t.Errorf("FindByID with another customer = %+v, want nil", other)
+		}
+		count, err := repo.CountByType(ctx, customerID, "billing")
+		checkErr(t, err, 0)
+		if count != 2 {
+			t.Errorf("CountByType(billing) = %d, want 2", count)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		first.IsDefault = true
+		first.Line2 = "Floor 3"
+		checkErr(t, repo.Update(ctx, first), 0)
+		if got := defaults(t, "billing"); len(got) != 1 || got[0] != first.ID {
+			t.Errorf("billing defaults after update = %v, want %d", got, first.ID)
+		}
+		missing := *first
+		missing.ID = shipping.ID + 100
+		checkErr(t, repo.Update(ctx, &missing), http.StatusNotFound)
+	})
+
+	t.Run("DeleteAndPromote", func(t *testing.T) {
+		checkErr(t, repo.Delete(ctx, customerID, first.ID), 0)
+		checkErr(t, repo.Delete(ctx, customerID, first.ID), http.StatusNotFound)
+		checkErr(t, repo.Delete(ctx, customerID+100, second.ID), http.StatusNotFound)
+		if got := defaults(t, "billing"); len(got) != 0 {
+			t.Fatalf("billing defaults after deleting the default = %v, want none", got)
+		}
+		checkErr(t, repo.PromoteDefault(ctx, customerID, "billing"), 0)
+		if got := defaults(t, "billing"); len(got) != 1 || got[0] != second.ID {
+			t.Errorf("billing defaults after PromoteDefault = %v, want %d", got, second.ID)
+		}
+		// 已有預設地址時不改變
+		third := newAddress("shipping", false)
+		checkErr(t, repo.Create(ctx, third), 0)
+		checkErr(t, repo.PromoteDefault(ctx, customerID, "shipping"), 0)
+		if got := defaults(t, "shipping"); len(got) != 1 || got[0] != shipping.ID {
+			t.Errorf("shipping defaults after PromoteDefault = %v, want %d", got, shipping.ID)
+		}
+	})
+}
+
+func TestCustomerNoteRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewCustomerNoteRepository(database, zaptest.NewLogger(t))
+	customerID := dbtest.SeedCustomer(t, database, "C0001", "Note Customer")
+	authorID := dbtest.SeedAccount(t, database, "note_author", dbtest.SeedRole(t, database, "note_role"))
+
+	notes := []*models.CustomerNote{
+		{CustomerID: customerID, AuthorID: &authorID, Body: "first", Pinned: true},
+		{CustomerID: customerID, Body: "second"}, // author_id 為 NULL
+		{CustomerID: customerID, AuthorID: &authorID, Body: "third"},
+	}
+	for _, note := range notes {
+		checkErr(t, repo.Create(ctx, note), 0)
+	}
+	checkErr(t, repo.Create(ctx, &models.CustomerNote{CustomerID: customerID + 100, Body: "lost"}), -1)
+
+	t.Run("FindByCustomerID", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			pinnedFirst bool
+			pagination  utils.Pagination
+			want        []string
+		}{
+			{name: "newest first", want: []string{"third", "second", "first"}},
+			{name: "pinned first", pinnedFirst: true, want: []string{"first", "third", "second"}},
+			{name: "second page", pagination: utils.Pagination{Page: 2, PageSize: 2}, want: []string{"first"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				found, total, err := repo.FindByCustomerID(ctx, customerID, tt.pinnedFirst, tt.pagination)
+				checkErr(t, err, 0)
+				if total != 3 {
+					t.Errorf("total = %d, want 3", total)
+				}
+				bodies := make([]string, len(found))
+				for i, note := range found {
+					bodies[i] = note.Body
+				}
+				if !reflect.DeepEqual(bodies, tt.want) {
+					t.Errorf("bodies = %v, want %v", bodies, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("FindByID", func(t *testing.T) {
+		found, err := repo.FindByID(ctx, customerID, notes[0].ID)
+		checkErr(t, err, 0)
+		if found == nil || found.AuthorUsername == nil || *found.AuthorUsername != "note_author" || !found.Pinned {
+			t.Fatalf("FindByID = %+v, want the pinned note by note_author", found)
+		}
+		anonymous, err := repo.FindByID(ctx, customerID, notes[1].ID)
+		checkErr(t, err, 0)
+		if anonymous == nil || anonymous.AuthorID != nil || anonymous.AuthorUsername != nil {
+			t.Errorf("FindByID = %+v, want no author", anonymous)
+		}
+		other, err := repo.FindByID(ctx, customerID+100, notes[0].ID)
+		checkErr(t, err, 0)
+		if other != nil {
+			t.Errorf("FindByID with another customer = %+v, want nil", other)
+		}
+	})
+
+	t.Run("AuthorDeleted", func(t *testing.T) {
+		if _, err := database.Exec(`DELETE FROM accounts WHERE id = $1`, authorID); err != nil {
+			t.Fatal(err)
+		}
+		found, err := repo.FindByID(ctx, customerID, notes[0].ID)
+		checkErr(t, err, 0)
+		if found == nil || found.AuthorID != nil {
+			t.Errorf("note after author delete = %+v, want author_id NULL", found)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		checkErr(t, repo.Delete(ctx, customerID+100, notes[2].ID), http.StatusNotFound)
+		checkErr(t, repo.Delete(ctx, customerID, notes[2].ID), 0)
+		checkErr(t, repo.Delete(ctx, customerID, notes[2].ID), http.StatusNotFound)
+	})
+}
+
+// TestCustomerHistoryIntegration 客戶歷史由 CustomerRepository 在寫入客戶的同一事務中記錄，依時間由新到舊讀取
+func TestCustomerHistoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	customers := NewCustomerRepository(database, nil, logger)
+	repo := NewCustomerHistoryRepository(database, logger)
+	actorID := dbtest.SeedAccount(t, database, "history_admin", dbtest.SeedRole(t, database, "history_role"))
+	oldName, newName := "Before", "After"
+
+	customer := &models.Customer{Name: oldName, Email: "history@example.com", Status: "active"}
+	checkErr(t, customers.Create(ctx, customer, "C", actorID, []models.CustomerHistory{{Event: models.CustomerEventCreated, ActorID: &actorID}}), 0)
+	customer.Name = newName
+	checkErr(t, customers.Update(ctx, customer, actorID, []models.CustomerHistory{
+		{CustomerID: customer.ID, Event: models.CustomerEventUpdated, Field: "name", OldValue: &oldName, NewValue: &newName, Reason: "renamed", ActorID: &actorID},
+		{CustomerID: customer.ID, Event: models.CustomerEventUpdated, Field: "phone", NewValue: &newName}, // 系統寫入，沒有操作者
+	}), 0)
+
+	tests := []struct {
+		name       string
+		field      string
+		pagination utils.Pagination
+		wantTotal  int
+		wantFields []string
+	}{
+		{name: "all", wantTotal: 3, wantFields: []string{"phone", "name", ""}},
+		{name: "by field", field: "name", wantTotal: 1, wantFields: []string{"name"}},
+		{name: "paged", pagination: utils.Pagination{Page: 2, PageSize: 2}, wantTotal: 3, wantFields: []string{""}},
+		{name: "unknown field", field: "email", wantTotal: 0, wantFields: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := repo.FindByCustomerID(ctx, customer.ID, tt.field, tt.pagination)
+			checkErr(t, err, 0)
+			fields := make([]string, len(entries))
+			for i, entry := range entries {
+				fields[i] = entry.Field
+			}
+			if total != tt.wantTotal || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("FindByCustomerID = %v (total %d), want %v (total %d)", fields, total, tt.wantFields, tt.wantTotal)
+			}
+		})
+	}
+
+	t.Run("Values", func(t *testing.T) {
+		entries, _, err := repo.FindByCustomerID(ctx, customer.ID, "name", utils.Pagination{})
+		checkErr(t, err, 0)
+		if len(entries) != 1 {
+			t.Fatalf("entries = %+v", entries)
+		}
+		entry := entries[0]
+		if entry.Event != models.CustomerEventUpdated || entry.Reason != "renamed" || entry.OldValue == nil || *entry.OldValue != oldName || *entry.NewValue != newName {
+			t.Errorf("entry = %+v, want the name change with its reason", entry)
+		}
+		if entry.ActorUsername == nil || *entry.ActorUsername != "history_admin" {
+			t.Errorf("ActorUsername = %v, want history_admin", entry.ActorUsername)
+		}
+		phone, _, err := repo.FindByCustomerID(ctx, customer.ID, "phone", utils.Pagination{})
+		checkErr(t, err, 0)
+		if len(phone) != 1 || phone[0].OldValue != nil || phone[0].ActorID != nil || phone[0].ActorUsername != nil || phone[0].Reason != "" {
+			t.Errorf("phone entries = %+v, want NULL old value, actor and reason", phone)
+		}
+	})
+}
+
+func TestAccountHistoryRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewAccountHistoryRepository(database, zaptest.NewLogger(t))
+	roleID := dbtest.SeedRole(t, database, "history_role")
+	accountID := dbtest.SeedAccount(t, database, "history_user", roleID)
+	role := "history_role"
+
+	entry := &models.AccountHistory{AccountID: accountID, Event: models.AccountEventCreated, Field: "role_id", NewValue: &role}
+	checkErr(t, repo.Create(ctx, entry), 0)
+	if entry.ID == 0 || entry.CreatedAt.IsZero() {
+		t.Errorf("Create did not fill the generated columns: %+v", entry)
+	}
+	checkErr(t, repo.Create(ctx, &models.AccountHistory{AccountID: accountID + 100, Event: models.AccountEventCreated}), -1)
+
+	var field, oldValue sql.NullString
+	var actorID sql.NullInt64
+	if err := database.QueryRow(`SELECT field, old_value, actor_account_id FROM account_history WHERE id = $1`, entry.ID).Scan(&field, &oldValue, &actorID); err != nil {
+		t.Fatal(err)
+	}
+	if field.String != "role_id" || oldValue.Valid || actorID.Valid {
+		t.Errorf("stored field = %v, old_value = %v, actor = %v; want role_id with NULL old value and actor", field, oldValue, actorID)
+	}
+}
+
+// intPtr 返回 v 的指針
+func intPtr(v int) *int {
+	return &v
+}
+
+// equalIntPtr 兩個可為 NULL 的整數是否相同
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
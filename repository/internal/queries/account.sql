@@ -0,0 +1,39 @@
+-- name: CreateAccount :one
+INSERT INTO accounts (username, password, role_id)
+VALUES ($1, $2, $3)
+RETURNING id, public_id, created_at, updated_at;
+
+-- name: GetAccountByID :one
+-- include_deleted 為 true 時包含已軟刪除的帳戶
+SELECT a.id, a.public_id, a.username, a.role_id, r.name AS role_name, a.is_active, a.created_at, a.updated_at, a.deleted_at
+FROM accounts a JOIN roles r ON a.role_id = r.id
+WHERE a.id = sqlc.arg(id) AND (a.deleted_at IS NULL OR sqlc.arg(include_deleted)::boolean);
+
+-- name: GetAccountByPublicID :one
+-- include_deleted 為 true 時包含已軟刪除的帳戶
+SELECT a.id, a.public_id, a.username, a.role_id, r.name AS role_name, a.is_active, a.created_at, a.updated_at, a.deleted_at
+FROM accounts a JOIN roles r ON a.role_id = r.id
+WHERE a.public_id = sqlc.arg(public_id) AND (a.deleted_at IS NULL OR sqlc.arg(include_deleted)::boolean);
+
+-- name: GetAccountByUsername :one
+-- 含密碼雜湊，供登入驗證；已刪除的帳戶無法登入
+SELECT a.id, a.public_id, a.username, a.role_id, r.name AS role_name, a.is_active, a.created_at, a.updated_at, a.deleted_at, a.password
+FROM accounts a JOIN roles r ON a.role_id = r.id
+WHERE a.username = $1 AND a.deleted_at IS NULL;
+
+-- name: CountAccounts :one
+SELECT COUNT(*) FROM accounts WHERE deleted_at IS NULL;
+
+-- name: UpdateAccount :one
+UPDATE accounts SET username = $1, role_id = $2, updated_at = NOW()
+WHERE id = $3 AND deleted_at IS NULL
+RETURNING updated_at;
+
+-- name: UpdateAccountPassword :execrows
+UPDATE accounts SET password = $1, updated_at = NOW()
+WHERE id = $2 AND deleted_at IS NULL;
+
+-- name: UpdateAdminPassword :execrows
+-- 專門為 resetadmin 工具提供，只更新 admin 角色的帳戶
+UPDATE accounts SET password = $1, updated_at = NOW()
+WHERE username = $2 AND deleted_at IS NULL AND role_id = (SELECT id FROM roles WHERE name = 'admin');
@@ -0,0 +1,29 @@
+-- name: GetCustomerByID :one
+-- 選取的欄位與 customerColumns 相同 (最後備註時間改以 LEFT JOIN LATERAL 取得，sqlc 才能推斷為可為 NULL 的欄位)；與 GetCustomerByPublicID 的列型別相同，可以互相轉換
+SELECT cu.id, cu.public_id, cu.code, cu.name, cu.contact_person, cu.email, cu.phone, cu.phone_normalized,
+    cu.company_id, co.name AS company_name, cu.currency, cu.payment_terms, cu.status,
+    cu.sales_rep_account_id, sr.username AS sales_rep_username, cu.created_at, cu.updated_at, cu.deleted_at,
+    ln.created_at AS last_note_at,
+    cu.created_by, cb.username AS created_by_username, cu.updated_by, ub.username AS updated_by_username
+FROM customers cu
+LEFT JOIN companies co ON co.id = cu.company_id
+LEFT JOIN accounts sr ON sr.id = cu.sales_rep_account_id
+LEFT JOIN accounts cb ON cb.id = cu.created_by
+LEFT JOIN accounts ub ON ub.id = cu.updated_by
+LEFT JOIN LATERAL (SELECT n.created_at FROM customer_notes n WHERE n.customer_id = cu.id ORDER BY n.created_at DESC NULLS LAST LIMIT 1) ln ON TRUE
+WHERE cu.id = sqlc.arg(id) AND (cu.deleted_at IS NULL OR sqlc.arg(include_deleted)::boolean);
+
+-- name: GetCustomerByPublicID :one
+-- include_deleted 為 true 時包含已軟刪除的客戶
+SELECT cu.id, cu.public_id, cu.code, cu.name, cu.contact_person, cu.email, cu.phone, cu.phone_normalized,
+    cu.company_id, co.name AS company_name, cu.currency, cu.payment_terms, cu.status,
+    cu.sales_rep_account_id, sr.username AS sales_rep_username, cu.created_at, cu.updated_at, cu.deleted_at,
+    ln.created_at AS last_note_at,
+    cu.created_by, cb.username AS created_by_username, cu.updated_by, ub.username AS updated_by_username
+FROM customers cu
+LEFT JOIN companies co ON co.id = cu.company_id
+LEFT JOIN accounts sr ON sr.id = cu.sales_rep_account_id
+LEFT JOIN accounts cb ON cb.id = cu.created_by
+LEFT JOIN accounts ub ON ub.id = cu.updated_by
+LEFT JOIN LATERAL (SELECT n.created_at FROM customer_notes n WHERE n.customer_id = cu.id ORDER BY n.created_at DESC NULLS LAST LIMIT 1) ln ON TRUE
+WHERE cu.public_id = sqlc.arg(public_id) AND (cu.deleted_at IS NULL OR sqlc.arg(include_deleted)::boolean);
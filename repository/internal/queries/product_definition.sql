@@ -0,0 +1,36 @@
+-- name: GetProductDefinitionByID :one
+-- 選取的欄位與 productDefinitionColumns 相同；與 GetProductDefinitionByPublicID 的列型別相同，可以互相轉換
+SELECT pd.id, pd.public_id, pd.sku, pd.name, pd.description, pd.category_id, pc.name AS category_name, pd.standard, pd.unit, pd.price,
+    pd.created_at, pd.updated_at, pd.discontinued_at, pd.image_key, pd.image_content_type, pd.image_updated_at, pd.parent_definition_id,
+    pd.created_by, cb.username AS created_by_username, pd.updated_by, ub.username AS updated_by_username
+FROM product_definitions pd
+JOIN product_categories pc ON pc.id = pd.category_id
+LEFT JOIN accounts cb ON cb.id = pd.created_by
+LEFT JOIN accounts ub ON ub.id = pd.updated_by
+WHERE pd.id = $1;
+
+-- name: GetProductDefinitionByPublicID :one
+SELECT pd.id, pd.public_id, pd.sku, pd.name, pd.description, pd.category_id, pc.name AS category_name, pd.standard, pd.unit, pd.price,
+    pd.created_at, pd.updated_at, pd.discontinued_at, pd.image_key, pd.image_content_type, pd.image_updated_at, pd.parent_definition_id,
+    pd.created_by, cb.username AS created_by_username, pd.updated_by, ub.username AS updated_by_username
+FROM product_definitions pd
+JOIN product_categories pc ON pc.id = pd.category_id
+LEFT JOIN accounts cb ON cb.id = pd.created_by
+LEFT JOIN accounts ub ON ub.id = pd.updated_by
+WHERE pd.public_id = $1;
+
+-- name: CreateProductCategory :one
+-- 描述為空白時寫入 NULL
+INSERT INTO product_categories (name, description, parent_id)
+VALUES (sqlc.arg(name), NULLIF(sqlc.arg(description)::text, ''), sqlc.narg(parent_id))
+RETURNING id, created_at, updated_at;
+
+-- name: ListProductCategories :many
+SELECT id, name, description, parent_id, created_at, updated_at
+FROM product_categories
+ORDER BY id;
+
+-- name: GetProductCategoryByID :one
+SELECT id, name, description, parent_id, created_at, updated_at
+FROM product_categories
+WHERE id = $1;
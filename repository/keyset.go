@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// cursorColumn 可用於游標分頁的排序欄位：SQL 運算式與其 PostgreSQL 型別
+// 游標以文字保存最後一筆的排序值，比較時以 Type 轉回原本的型別，順序才會與 ORDER BY 相同
+type cursorColumn struct {
+	Expr string
+	Type string // 例如 text、integer、numeric、timestamptz
+}
+
+// keyset 游標分頁 (keyset pagination) 的排序：依 Column (NULLS LAST) 排序，再依 IDColumn 升序
+// 以 WHERE (排序欄位, id) 接續上一頁的最後一筆，深層分頁不需要 OFFSET 掃過前面的資料
+type keyset struct {
+	Sort     string        // 排序參數 (例如 "-name")，保存在游標中，排序改變後拒絕舊的游標
+	Column   *cursorColumn // nil 時只依 IDColumn 排序
+	Desc     bool
+	IDColumn string
+}
+
+// newKeyset 以單一排序欄位建立 keyset，field 空白時只依 idColumn 排序；field 不在 columns 中 (無法以游標接續) 時返回 false
+func newKeyset(field string, desc bool, columns map[string]cursorColumn, idColumn string) (keyset, bool) {
+	k := keyset{Desc: desc, IDColumn: idColumn}
+	if field == "" {
+		return k, true
+	}
+	column, ok := columns[field]
+	if !ok {
+		return keyset{}, false
+	}
+	k.Column = &column
+	k.Sort = field
+	if desc {
+		k.Sort = "-" + field
+	}
+	return k, true
+}
+
+// pageCursor 游標的內容，以 JSON 經 utils.EncodeCursor 編碼後返回給用戶端
+type pageCursor struct {
+	Sort  string  `json:"s,omitempty"`
+	Value *string `json:"v,omitempty"` // 排序值的文字，NULL 時省略
+	ID    int     `json:"id"`
+}
+
+// orderBy ORDER BY 子句 (不含關鍵字)，與 buildOrderBy 相同以 NULLS LAST 排序並以 id 升序收尾
+func (k keyset) orderBy() string {
+	if k.Column == nil {
+		return k.IDColumn + " ASC"
+	}
+	direction := "ASC"
+	if k.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, %s ASC", k.Column.Expr, direction, k.IDColumn)
+}
+
+// selectColumn 附加在 SELECT 欄位之後的游標欄位 (排序值的文字)，以 pageColumns 掃描
+func (k keyset) selectColumn() string {
+	if k.Column == nil {
+		return ", NULL::text AS cursor_value"
+	}
+	return ", (" + k.Column.Expr + ")::text AS cursor_value"
+}
+
+// pageClause 返回加上游標位置的 WHERE 子句與 ORDER BY/LIMIT/OFFSET 子句，參數附加到 args
+// 與 pageProbeClause 相同多查詢一筆以判斷是否有下一頁；提供游標時不使用 OFFSET，游標格式錯誤或與排序不符時返回 400
+func (k keyset) pageClause(where string, pagination utils.Pagination, args []interface{}) (string, string, []interface{}, error) {
+	if pagination.Cursor != "" {
+		var cursor pageCursor
+		if err := json.Unmarshal([]byte(pagination.Cursor), &cursor); err != nil {
+			return "", "", nil, utils.NewInvalidParamError("cursor", "malformed cursor")
+		}
+		if cursor.Sort != k.Sort {
+			return "", "", nil, utils.NewInvalidParamError("cursor", "does not match the sort order")
+		}
+		args = append(args, cursor.ID)
+		idArg := len(args)
+		var condition string
+		switch {
+		case k.Column == nil:
+			condition = fmt.Sprintf("%s > $%d", k.IDColumn, idArg)
+		case cursor.Value == nil:
+			// 上一頁停在 NULL (排在最後)，之後只剩排序值同為 NULL 的記錄
+			condition = fmt.Sprintf("(%s IS NULL AND %s > $%d)", k.Column.Expr, k.IDColumn, idArg)
+		default:
+			args = append(args, *cursor.Value)
+			comparison := ">"
+			if k.Desc {
+				comparison = "<"
+			}
+			condition = fmt.Sprintf("(%[1]s %[2]s $%[3]d::%[4]s OR (%[1]s = $%[3]d::%[4]s AND %[5]s > $%[6]d) OR %[1]s IS NULL)",
+				k.Column.Expr, comparison, len(args), k.Column.Type, k.IDColumn, idArg)
+		}
+		if where == "" {
+			where = " WHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+	}
+
+	clause := " ORDER BY " + k.orderBy()
+	if pagination.PageSize > 0 {
+		args = append(args, pagination.Limit()+1)
+		clause += fmt.Sprintf(" LIMIT $%d", len(args))
+		if pagination.Cursor == "" && pagination.Offset() > 0 {
+			args = append(args, pagination.Offset())
+			clause += fmt.Sprintf(" OFFSET $%d", len(args))
+		}
+	}
+	return where, clause, args, nil
+}
+
+// nextCursor 返回從 id 與 value (該列的 cursor_value) 之後接續的游標 (已編碼)
+func (k keyset) nextCursor(id int, value sql.NullString) string {
+	cursor := pageCursor{Sort: k.Sort, ID: id}
+	if value.Valid {
+		cursor.Value = &value.String
+	}
+	data, _ := json.Marshal(cursor) // pageCursor 只有字串與整數，不會編碼失敗
+	return utils.EncodeCursor(string(data))
+}
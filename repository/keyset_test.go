@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// BenchmarkPageClause 比較以 OFFSET 與以游標組裝查詢的開銷 (不需要資料庫)；資料庫端的比較見 BenchmarkCustomerPagination (integration)
+func BenchmarkPageClause(b *testing.B) {
+	k, _ := newKeyset("name", false, customerCursorColumns, "cu.id")
+	cursor := `{"s":"name","v":"Customer 10000","id":10000}`
+	for _, bm := range []struct {
+		name       string
+		pagination utils.Pagination
+	}{
+		{name: "offset", pagination: utils.Pagination{Page: 200, PageSize: 50}},
+		{name: "keyset", pagination: utils.Pagination{Page: 1, PageSize: 50, Cursor: cursor}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, _, err := k.pageClause(" WHERE cu.deleted_at IS NULL", bm.pagination, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
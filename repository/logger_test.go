@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL 驅動
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// unreachableDatabaseURL 沒有服務監聽的位址，查詢會因連線被拒絕而失敗
+const unreachableDatabaseURL = "postgres://fastener@127.0.0.1:1/fastener?sslmode=disable&connect_timeout=1"
+
+// TestRepositoryErrorIsLogged 查詢失敗時以注入的 logger 記錄錯誤，並帶有 ctx 中請求範圍的欄位
+// 不依賴全局的 zap.L() (未呼叫 zap.ReplaceGlobals 時不輸出任何內容)
+func TestRepositoryErrorIsLogged(t *testing.T) {
+	database, err := sql.Open("pgx", unreachableDatabaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	core, logs := observer.New(zapcore.DebugLevel)
+	repo := NewAccountRepository(database, zap.New(core))
+
+	ctx := utils.ContextWithLogFields(context.Background(), zap.String("request_id", "req-1"))
+	ctx = utils.ContextWithLogFields(ctx, zap.Int("account_id", 7))
+	if _, err := repo.FindByID(ctx, 42); err == nil {
+		t.Fatal("FindByID returned no error for an unreachable database")
+	}
+
+	entries := logs.FilterMessage("Repository: Failed to get account by ID").All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d matching entries, want 1 (all: %v)", len(entries), logs.All())
+	}
+	entry := entries[0]
+	fields := entry.ContextMap()
+	if entry.Level != zapcore.ErrorLevel || fields["id"] != int64(42) || fields["error"] == nil {
+		t.Errorf("entry = %v %v", entry.Level, fields)
+	}
+	if fields["request_id"] != "req-1" || fields["account_id"] != int64(7) {
+		t.Errorf("request-scoped fields missing: %v", fields)
+	}
+
+	// 沒有請求範圍欄位時 (例如背景工作) 仍以注入的 logger 記錄
+	logs.TakeAll()
+	if _, err := repo.FindByID(context.Background(), 42); err == nil {
+		t.Fatal("FindByID returned no error for an unreachable database")
+	}
+	if entries := logs.TakeAll(); len(entries) != 1 || entries[0].ContextMap()["request_id"] != nil {
+		t.Errorf("entries without request context = %v", entries)
+	}
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -13,26 +14,61 @@ import (
 
 // MenuRepository 定義選單資料庫操作介面
 type MenuRepository interface {
-	Create(menu *models.Menu) error
-	FindAll() ([]models.Menu, error)
-	FindByID(id int) (*models.Menu, error)
-	Update(menu *models.Menu) error
-	Delete(id int) error
+	Create(ctx context.Context, menu *models.Menu, actorID int) error // actorID 為執行建立的帳戶，記錄在 created_by/updated_by (0 表示系統寫入，記錄為 NULL)
+	FindAll(ctx context.Context) ([]models.Menu, error)
+	FindByID(ctx context.Context, id int) (*models.Menu, error)
+	FindByPublicID(ctx context.Context, publicID string) (*models.Menu, error) // 以對外的 UUID 識別碼查詢
+	FindByPath(ctx context.Context, path string) (*models.Menu, error)
+	Update(ctx context.Context, menu *models.Menu, actorID int) error
+	Delete(ctx context.Context, id int) error
+}
+
+// menuColumns 查詢選單時統一使用的欄位順序，需與 scanMenu 保持一致 (別名 m，搭配 menuFrom)
+const menuColumns = `m.id, m.public_id, m.name, m.path, COALESCE(m.icon, ''), m.parent_id, m.display_order, m.created_at, m.updated_at, m.created_by, cb.username, m.updated_by, ub.username`
+
+// menuFrom 查詢選單的 FROM 子句，JOIN 建立者與最後修改者的帳戶以取得用戶名
+const menuFrom = ` FROM menus m LEFT JOIN accounts cb ON cb.id = m.created_by LEFT JOIN accounts ub ON ub.id = m.updated_by`
+
+// scanMenu 將一列查詢結果掃描為 Menu，處理 NULLABLE 的 parent_id (icon 已在 menuColumns 中以 COALESCE 轉為空字串)
+func scanMenu(row rowScanner) (*models.Menu, error) {
+	var menu models.Menu
+	var parentID sql.NullInt64
+	dest := []interface{}{
+		&menu.ID,
+		&menu.PublicID,
+		&menu.Name,
+		&menu.Path,
+		&menu.Icon,
+		&parentID,
+		&menu.DisplayOrder,
+		&menu.CreatedAt,
+		&menu.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, actorDest(&menu.RecordActors)...)...); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		menu.ParentID = new(int)
+		*menu.ParentID = int(parentID.Int64)
+	}
+	return &menu, nil
 }
 
 // menuRepositoryImpl 實現 MenuRepository 介面
 type menuRepositoryImpl struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *zap.Logger
 }
 
 // NewMenuRepository 創建 MenuRepository 實例
-func NewMenuRepository(db *sql.DB) MenuRepository {
-	return &menuRepositoryImpl{db: db}
+func NewMenuRepository(db *sql.DB, logger *zap.Logger) MenuRepository {
+	return &menuRepositoryImpl{db: db, logger: logger}
 }
 
-// Create 創建新選單
-func (r *menuRepositoryImpl) Create(menu *models.Menu) error {
-	query := `INSERT INTO menus (name, path, icon, parent_id, display_order) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+// Create 創建新選單，建立者與最後修改者為 actorID
+func (r *menuRepositoryImpl) Create(ctx context.Context, menu *models.Menu, actorID int) error {
+	query := `INSERT INTO menus (name, path, icon, parent_id, display_order, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($6, 0))
+              RETURNING id, public_id, created_at, updated_at, ` + actorReturning("menus")
 	var parentID sql.NullInt64
 	if menu.ParentID != nil {
 		parentID = sql.NullInt64{Int64: int64(*menu.ParentID), Valid: true}
@@ -40,13 +76,13 @@ func (r *menuRepositoryImpl) Create(menu *models.Menu) error {
 		parentID = sql.NullInt64{Valid: false}
 	}
 
-	err := r.db.QueryRow(query, menu.Name, menu.Path, menu.Icon, parentID, menu.DisplayOrder).
-		Scan(&menu.ID, &menu.CreatedAt, &menu.UpdatedAt)
+	err := r.db.QueryRowContext(ctx, query, menu.Name, menu.Path, menu.Icon, parentID, menu.DisplayOrder, actorID).
+		Scan(append([]interface{}{&menu.ID, &menu.PublicID, &menu.CreatedAt, &menu.UpdatedAt}, actorDest(&menu.RecordActors)...)...)
 	if err != nil {
-		zap.L().Error("Repository: Failed to create menu", zap.Error(err), zap.String("name", menu.Name))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create menu", zap.Error(err), zap.String("name", menu.Name))
 		// 檢查是否是唯一約束衝突錯誤 (例如，path 已存在)
-		if err.Error() == `pq: duplicate key value violates unique constraint "menus_path_key"` {
-			return utils.ErrBadRequest.SetDetails("Menu path already exists")
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
 		}
 		return fmt.Errorf("failed to create menu: %w", err)
 	}
@@ -54,77 +90,77 @@ func (r *menuRepositoryImpl) Create(menu *models.Menu) error {
 }
 
 // FindAll 獲取所有選單
-func (r *menuRepositoryImpl) FindAll() ([]models.Menu, error) {
-	query := `SELECT id, name, path, icon, parent_id, display_order, created_at, updated_at FROM menus ORDER BY display_order ASC`
-	rows, err := r.db.Query(query)
+func (r *menuRepositoryImpl) FindAll(ctx context.Context) ([]models.Menu, error) {
+	query := `SELECT ` + menuColumns + menuFrom + ` ORDER BY m.display_order ASC`
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get all menus", zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all menus", zap.Error(err))
 		return nil, fmt.Errorf("failed to get all menus: %w", err)
 	}
 	defer rows.Close()
 
 	menus := []models.Menu{}
 	for rows.Next() {
-		var menu models.Menu
-		var parentID sql.NullInt64 // 用於處理 NULLABLE 的 parent_id
-		if err := rows.Scan(
-			&menu.ID,
-			&menu.Name,
-			&menu.Path,
-			&menu.Icon,
-			&parentID, // Scan 到 sql.NullInt64
-			&menu.DisplayOrder,
-			&menu.CreatedAt,
-			&menu.UpdatedAt,
-		); err != nil {
-			zap.L().Error("Repository: Failed to scan menu data", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan menu data: %w", err)
-		}
-		if parentID.Valid {
-			menu.ParentID = new(int)
-			*menu.ParentID = int(parentID.Int64)
-		} else {
-			menu.ParentID = nil
+		menu, err := scanMenu(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan menu data", zap.Int("row", len(menus)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan menu data at row %d: %w", len(menus)+1, err)
 		}
-		menus = append(menus, menu)
+		menus = append(menus, *menu)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating menu data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating menu data: %w", err)
 	}
 	return menus, nil
 }
 
 // FindByID 根據 ID 獲取選單
-func (r *menuRepositoryImpl) FindByID(id int) (*models.Menu, error) {
-	query := `SELECT id, name, path, icon, parent_id, display_order, created_at, updated_at FROM menus WHERE id = $1`
-	row := r.db.QueryRow(query, id)
-	var menu models.Menu
-	var parentID sql.NullInt64
-	if err := row.Scan(
-		&menu.ID,
-		&menu.Name,
-		&menu.Path,
-		&menu.Icon,
-		&parentID,
-		&menu.DisplayOrder,
-		&menu.CreatedAt,
-		&menu.UpdatedAt,
-	); err != nil {
+func (r *menuRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Menu, error) {
+	query := `SELECT ` + menuColumns + menuFrom + ` WHERE m.id = $1`
+	menu, err := scanMenu(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // 未找到
 		}
-		zap.L().Error("Repository: Failed to get menu by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get menu by ID", zap.Int("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get menu by ID %d: %w", id, err)
 	}
-	if parentID.Valid {
-		menu.ParentID = new(int)
-		*menu.ParentID = int(parentID.Int64)
-	} else {
-		menu.ParentID = nil
+	return menu, nil
+}
+
+// FindByPublicID 根據對外的 UUID 識別碼獲取選單
+func (r *menuRepositoryImpl) FindByPublicID(ctx context.Context, publicID string) (*models.Menu, error) {
+	query := `SELECT ` + menuColumns + menuFrom + ` WHERE m.public_id = $1`
+	menu, err := scanMenu(r.db.QueryRowContext(ctx, query, publicID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get menu by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get menu by public ID %s: %w", publicID, err)
 	}
-	return &menu, nil
+	return menu, nil
+}
+
+// FindByPath 根據路徑獲取選單 (檢查路徑是否重複)
+func (r *menuRepositoryImpl) FindByPath(ctx context.Context, path string) (*models.Menu, error) {
+	query := `SELECT ` + menuColumns + menuFrom + ` WHERE m.path = $1`
+	menu, err := scanMenu(r.db.QueryRowContext(ctx, query, path))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get menu by path", zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to get menu by path %s: %w", path, err)
+	}
+	return menu, nil
 }
 
-// Update 更新選單信息
-func (r *menuRepositoryImpl) Update(menu *models.Menu) error {
-	query := `UPDATE menus SET name = $1, path = $2, icon = $3, parent_id = $4, display_order = $5, updated_at = NOW() WHERE id = $6 RETURNING updated_at`
+// Update 更新選單信息，最後修改者為 actorID
+func (r *menuRepositoryImpl) Update(ctx context.Context, menu *models.Menu, actorID int) error {
+	query := `UPDATE menus SET name = $1, path = $2, icon = $3, parent_id = $4, display_order = $5, updated_by = NULLIF($7, 0), updated_at = NOW() WHERE id = $6
+              RETURNING created_at, updated_at, ` + actorReturning("menus")
 	var parentID sql.NullInt64
 	if menu.ParentID != nil {
 		parentID = sql.NullInt64{Int64: int64(*menu.ParentID), Valid: true}
@@ -132,50 +168,43 @@ func (r *menuRepositoryImpl) Update(menu *models.Menu) error {
 		parentID = sql.NullInt64{Valid: false}
 	}
 
-	res, err := r.db.Exec(query,
+	err := r.db.QueryRowContext(ctx, query,
 		menu.Name,
 		menu.Path,
 		menu.Icon,
 		parentID,
 		menu.DisplayOrder,
 		menu.ID,
-	)
+		actorID,
+	).Scan(append([]interface{}{&menu.CreatedAt, &menu.UpdatedAt}, actorDest(&menu.RecordActors)...)...)
 	if err != nil {
-		zap.L().Error("Repository: Failed to update menu", zap.Error(err), zap.Int("id", menu.ID))
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要更新的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update menu", zap.Error(err), zap.Int("id", menu.ID))
 		// 檢查是否是唯一約束衝突錯誤
-		if err.Error() == `pq: duplicate key value violates unique constraint "menus_path_key"` {
-			return utils.ErrBadRequest.SetDetails("Menu path already exists")
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
 		}
 		return fmt.Errorf("failed to update menu %d: %w", menu.ID, err)
 	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after update", zap.Error(err), zap.Int("id", menu.ID))
-		return fmt.Errorf("failed to check update rows affected %d: %w", menu.ID, err)
-	}
-	if rowsAffected == 0 {
-		return utils.ErrNotFound // 未找到要更新的記錄
-	}
-	// 重新讀取 updated_at
-	row := r.db.QueryRow(`SELECT updated_at FROM menus WHERE id = $1`, menu.ID)
-	if err := row.Scan(&menu.UpdatedAt); err != nil {
-		zap.L().Error("Repository: Failed to scan updated_at after update", zap.Error(err), zap.Int("id", menu.ID))
-		return fmt.Errorf("failed to scan updated_at for menu %d: %w", menu.ID, err)
-	}
 	return nil
 }
 
 // Delete 刪除選單
-func (r *menuRepositoryImpl) Delete(id int) error {
+func (r *menuRepositoryImpl) Delete(ctx context.Context, id int) error {
 	query := `DELETE FROM menus WHERE id = $1`
-	res, err := r.db.Exec(query, id)
+	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		zap.L().Error("Repository: Failed to delete menu", zap.Error(err), zap.Int("id", id))
+		if conflictErr := referencedConflictError(err); conflictErr != nil {
+			return conflictErr // 仍被其他記錄引用 (ON DELETE RESTRICT)
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete menu", zap.Error(err), zap.Int("id", id))
 		return fmt.Errorf("failed to delete menu %d: %w", id, err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
 		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
 	}
 	if rowsAffected == 0 {
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// pageProbeClause 與 pageClause 相同，但多查詢一筆，供 pageRows.finish 判斷是否有下一頁
+func pageProbeClause(orderBy string, pagination utils.Pagination, args []interface{}) (string, []interface{}) {
+	clause := " ORDER BY " + orderBy
+	if pagination.PageSize > 0 {
+		args = append(args, pagination.Limit()+1, pagination.Offset())
+		clause += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	return clause, args
+}
+
+// totalColumn 附加在 SELECT 欄位之後 (游標欄位之後) 的總筆數欄位，以 pageColumns 掃描
+// 要求總筆數 (IncludeTotal) 時以 COUNT(*) OVER() 在同一個查詢中計算套用 LIMIT/OFFSET 前的筆數，不需另外查詢 COUNT；
+// 游標分頁時游標條件也在 WHERE 中，視窗函式只會算到剩餘的筆數，因此不計算 (由 finish 另外查詢)
+func totalColumn(pagination utils.Pagination) string {
+	if pagination.IncludeTotal && pagination.Cursor == "" {
+		return ", COUNT(*) OVER() AS total_count"
+	}
+	return ", NULL::bigint AS total_count"
+}
+
+// pageColumns 一列查詢結果中分頁用的欄位 (keyset.selectColumn 的游標值與 totalColumn 的總筆數)
+type pageColumns struct {
+	cursorValue sql.NullString
+	total       sql.NullInt64
+}
+
+// scanner 返回在 row 的欄位之後多掃描分頁欄位的 rowScanner
+func (p *pageColumns) scanner(row rowScanner) rowScanner {
+	return pageScanner{rowScanner: row, columns: p}
+}
+
+// pageScanner 在 rowScanner 的欄位之後多掃描 pageColumns
+type pageScanner struct {
+	rowScanner
+	columns *pageColumns
+}
+
+// Scan 掃描一列，最後兩個欄位寫入 columns
+func (s pageScanner) Scan(dest ...interface{}) error {
+	return s.rowScanner.Scan(append(dest, &s.columns.cursorValue, &s.columns.total)...)
+}
+
+// pageRows 記錄列表查詢每一列的 ID 與分頁欄位，查詢結束後以 finish 計算 models.PageInfo
+type pageRows struct {
+	ids     []int
+	columns []pageColumns
+}
+
+// add 記錄一列
+func (p *pageRows) add(id int, columns pageColumns) {
+	p.ids = append(p.ids, id)
+	p.columns = append(p.columns, columns)
+}
+
+// finish 去除為判斷下一頁而多查詢的一筆 (返回本頁的筆數) 並計算分頁資訊：
+//   - HasMore：查詢結果多於 PageSize
+//   - NextCursor：k 非 nil 且有下一頁時，指向本頁最後一筆
+//   - Total：只在 IncludeTotal 時計算，取自 COUNT(*) OVER()；本頁沒有資料 (OFFSET 超過最後一頁) 或使用游標時才呼叫 count 另外查詢
+func (p *pageRows) finish(ctx context.Context, pagination utils.Pagination, k *keyset, count func(ctx context.Context) (int, error)) (int, models.PageInfo, error) {
+	n := len(p.ids)
+	info := models.PageInfo{}
+	if pagination.PageSize > 0 && n > pagination.PageSize {
+		n = pagination.PageSize
+		info.HasMore = true
+		if k != nil {
+			info.NextCursor = k.nextCursor(p.ids[n-1], p.columns[n-1].cursorValue)
+		}
+	}
+	if !pagination.IncludeTotal {
+		return n, info, nil
+	}
+	switch {
+	case n > 0 && p.columns[0].total.Valid:
+		total := int(p.columns[0].total.Int64)
+		info.Total = &total
+	case n == 0 && pagination.Cursor == "" && pagination.Offset() == 0:
+		total := 0 // 第一頁沒有資料，沒有符合條件的記錄
+		info.Total = &total
+	default:
+		total, err := count(ctx)
+		if err != nil {
+			return 0, models.PageInfo{}, err
+		}
+		info.Total = &total
+	}
+	return n, info, nil
+}
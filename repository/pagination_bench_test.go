@@ -0,0 +1,83 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/db/dbtest"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// benchmarkCustomers 基準測試建立的客戶數量
+const benchmarkCustomers = 20000
+
+// seedBenchmarkCustomers 以單一語句建立 n 個客戶 (不經過 Repository，避免建立資料的時間影響基準)
+func seedBenchmarkCustomers(b *testing.B, n int) CustomerRepository {
+	b.Helper()
+	database := dbtest.Open(b)
+	if _, err := database.ExecContext(context.Background(), `
+		INSERT INTO customers (name, email, code, status)
+		SELECT 'Customer ' || g, 'customer' || g || '@example.com', 'BENCH' || g, 'active'
+		FROM generate_series(1, $1) AS g`, n); err != nil {
+		b.Fatalf("seed customers: %v", err)
+	}
+	return NewCustomerRepository(database, nil, zap.NewNop())
+}
+
+// BenchmarkCustomerPagination 比較以 OFFSET 與以游標 (keyset) 讀取同一頁：
+// OFFSET 需要掃過前面所有的列，越深的頁越慢；游標以 WHERE id > 上一頁最後一筆 直接從索引定位，與頁的深度無關
+func BenchmarkCustomerPagination(b *testing.B) {
+	const pageSize = 50
+	repo := seedBenchmarkCustomers(b, benchmarkCustomers)
+	ctx := context.Background()
+
+	for _, page := range []int{2, benchmarkCustomers / pageSize / 2, benchmarkCustomers / pageSize} {
+		offset := utils.Pagination{Page: page, PageSize: pageSize}
+
+		// 以前一頁的 next_cursor 取得指向同一頁的游標，並確認兩種方式返回相同的客戶
+		_, previous, err := repo.FindAll(ctx, models.CustomerFilter{}, utils.Pagination{Page: page - 1, PageSize: pageSize})
+		if err != nil || previous.NextCursor == "" {
+			b.Fatalf("page %d: next_cursor = %q, %v", page-1, previous.NextCursor, err)
+		}
+		decoded, err := utils.DecodeCursor(previous.NextCursor)
+		if err != nil {
+			b.Fatal(err)
+		}
+		cursor := utils.Pagination{Page: 1, PageSize: pageSize, Cursor: decoded}
+		byOffset, _, err := repo.FindAll(ctx, models.CustomerFilter{}, offset)
+		if err != nil {
+			b.Fatal(err)
+		}
+		byCursor, _, err := repo.FindAll(ctx, models.CustomerFilter{}, cursor)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(byOffset) != pageSize || !reflect.DeepEqual(byOffset, byCursor) {
+			b.Fatalf("page %d: offset and cursor returned different customers", page)
+		}
+
+		for _, bm := range []struct {
+			name       string
+			pagination utils.Pagination
+		}{
+			{name: "offset", pagination: offset},
+			{name: "keyset", pagination: cursor},
+		} {
+			b.Run(fmt.Sprintf("page=%d/%s", page, bm.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, _, err := repo.FindAll(ctx, models.CustomerFilter{}, bm.pagination); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -1,72 +1,120 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	"go.uber.org/zap"
 
 	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
 )
 
 // PermissionRepository 定義權限資料庫操作介面
+// 以 db.TxManager.WithinTx 的 ctx 呼叫時，查詢在該事務中執行
 type PermissionRepository interface {
-	FindByID(id int) (*models.Permission, error)
-	FindByName(name string) (*models.Permission, error)
-	FindPermissionsByRoleID(roleID int) ([]models.Permission, error) // 獲取某個角色擁有的所有權限
-	AssignPermissionToRole(roleID, permissionID int) error
-	RevokePermissionFromRole(roleID, permissionID int) error
+	FindByID(ctx context.Context, id int) (*models.Permission, error)
+	FindByName(ctx context.Context, name string) (*models.Permission, error)
+	FindAll(ctx context.Context) ([]models.Permission, error)                             // 權限目錄，依名稱排序
+	FindPermissionsByRoleID(ctx context.Context, roleID int) ([]models.Permission, error) // 獲取某個角色擁有的所有權限
+	CreateBatch(ctx context.Context, permissions []models.Permission) (int, error)        // 以多列 INSERT 批次登記權限，名稱已存在的略過，返回新增的筆數
+	AssignPermissionToRole(ctx context.Context, roleID, permissionID int) error
+	AssignPermissionsBatch(ctx context.Context, rolePermissions []models.RolePermission) (int, error) // 以多列 INSERT 批次賦予權限，已擁有的略過，返回新增的筆數
+	RevokePermissionFromRole(ctx context.Context, roleID, permissionID int) error
+	CopyRolePermissions(ctx context.Context, fromRoleID, toRoleID int) error // 將 fromRoleID 的權限複製給 toRoleID
 }
 
 // permissionRepositoryImpl 實現 PermissionRepository 介面
 type permissionRepositoryImpl struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *zap.Logger
 }
 
 // NewPermissionRepository 創建 PermissionRepository 實例
-func NewPermissionRepository(db *sql.DB) PermissionRepository {
-	return &permissionRepositoryImpl{db: db}
+func NewPermissionRepository(db *sql.DB, logger *zap.Logger) PermissionRepository {
+	return &permissionRepositoryImpl{db: db, logger: logger}
 }
 
 // FindByID 根據 ID 獲取權限
-func (r *permissionRepositoryImpl) FindByID(id int) (*models.Permission, error) {
+func (r *permissionRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Permission, error) {
 	query := `SELECT id, name, description, created_at, updated_at FROM permissions WHERE id = $1`
-	row := r.db.QueryRow(query, id)
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, id)
 	var permission models.Permission
 	if err := row.Scan(&permission.ID, &permission.Name, &permission.Description, &permission.CreatedAt, &permission.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		zap.L().Error("Repository: Failed to get permission by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get permission by ID", zap.Int("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get permission by ID %d: %w", id, err)
 	}
 	return &permission, nil
 }
 
 // FindByName 根據名稱獲取權限
-func (r *permissionRepositoryImpl) FindByName(name string) (*models.Permission, error) {
+func (r *permissionRepositoryImpl) FindByName(ctx context.Context, name string) (*models.Permission, error) {
 	query := `SELECT id, name, description, created_at, updated_at FROM permissions WHERE name = $1`
-	row := r.db.QueryRow(query, name)
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, name)
 	var permission models.Permission
 	if err := row.Scan(&permission.ID, &permission.Name, &permission.Description, &permission.CreatedAt, &permission.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		zap.L().Error("Repository: Failed to get permission by name", zap.String("name", name), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get permission by name", zap.String("name", name), zap.Error(err))
 		return nil, fmt.Errorf("failed to get permission by name %s: %w", name, err)
 	}
 	return &permission, nil
 }
 
+// FindAll 獲取所有權限 (權限目錄)，依名稱排序
+func (r *permissionRepositoryImpl) FindAll(ctx context.Context) ([]models.Permission, error) {
+	query := `SELECT id, name, COALESCE(description, ''), created_at, updated_at FROM permissions ORDER BY name`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all permissions", zap.Error(err))
+		return nil, fmt.Errorf("failed to get all permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []models.Permission{}
+	for rows.Next() {
+		var p models.Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan permission data", zap.Int("row", len(permissions)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan permission data at row %d: %w", len(permissions)+1, err)
+		}
+		permissions = append(permissions, p)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating permission data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating permission data: %w", err)
+	}
+	return permissions, nil
+}
+
+// CreateBatch 以多列 INSERT 批次登記權限 (見 insertIgnoreBatch)，名稱已存在的權限略過 (不更新描述)
+func (r *permissionRepositoryImpl) CreateBatch(ctx context.Context, permissions []models.Permission) (int, error) {
+	rows := make([][]interface{}, len(permissions))
+	for i, permission := range permissions {
+		rows[i] = []interface{}{permission.Name, permission.Description}
+	}
+	created, err := insertIgnoreBatch(ctx, conn(ctx, r.db), "permissions", []string{"name", "description"}, "name", rows)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to batch create permissions", zap.Error(err), zap.Int("count", len(permissions)))
+		return created, fmt.Errorf("failed to batch create permissions: %w", err)
+	}
+	return created, nil
+}
+
 // FindPermissionsByRoleID 獲取某個角色擁有的所有權限
-func (r *permissionRepositoryImpl) FindPermissionsByRoleID(roleID int) ([]models.Permission, error) {
+func (r *permissionRepositoryImpl) FindPermissionsByRoleID(ctx context.Context, roleID int) ([]models.Permission, error) {
 	query := `SELECT p.id, p.name, p.description, p.created_at, p.updated_at
               FROM permissions p
               JOIN role_permissions rp ON p.id = rp.permission_id
               WHERE rp.role_id = $1`
-	rows, err := r.db.Query(query, roleID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, roleID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get permissions by role ID", zap.Int("role_id", roleID), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get permissions by role ID", zap.Int("role_id", roleID), zap.Error(err))
 		return nil, fmt.Errorf("failed to get permissions for role %d: %w", roleID, err)
 	}
 	defer rows.Close()
@@ -75,36 +123,67 @@ func (r *permissionRepositoryImpl) FindPermissionsByRoleID(roleID int) ([]models
 	for rows.Next() {
 		var p models.Permission
 		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			zap.L().Error("Repository: Failed to scan permission data for role", zap.Int("role_id", roleID), zap.Error(err))
-			return nil, fmt.Errorf("failed to scan permission data for role %d: %w", roleID, err)
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan permission data for role", zap.Int("role_id", roleID), zap.Int("row", len(permissions)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan permission data for role %d at row %d: %w", roleID, len(permissions)+1, err)
 		}
 		permissions = append(permissions, p)
 	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating permission data for role", zap.Int("role_id", roleID), zap.Error(err))
+		return nil, fmt.Errorf("error iterating permission data for role %d: %w", roleID, err)
+	}
 	return permissions, nil
 }
 
 // AssignPermissionToRole 將權限賦予角色
-func (r *permissionRepositoryImpl) AssignPermissionToRole(roleID, permissionID int) error {
+func (r *permissionRepositoryImpl) AssignPermissionToRole(ctx context.Context, roleID, permissionID int) error {
 	query := `INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT (role_id, permission_id) DO NOTHING`
-	_, err := r.db.Exec(query, roleID, permissionID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, roleID, permissionID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to assign permission to role", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to assign permission to role", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
 		return fmt.Errorf("failed to assign permission %d to role %d: %w", permissionID, roleID, err)
 	}
 	return nil
 }
 
+// AssignPermissionsBatch 以多列 INSERT 批次將權限賦予角色 (分批以符合參數上限，見 insertIgnoreBatch)，已擁有的權限略過
+// 分成多個語句時需要以 db.TxManager.WithinTx 的 ctx 呼叫，才能在失敗時回滾已寫入的批次
+func (r *permissionRepositoryImpl) AssignPermissionsBatch(ctx context.Context, rolePermissions []models.RolePermission) (int, error) {
+	rows := make([][]interface{}, len(rolePermissions))
+	for i, rolePermission := range rolePermissions {
+		rows[i] = []interface{}{rolePermission.RoleID, rolePermission.PermissionID}
+	}
+	created, err := insertIgnoreBatch(ctx, conn(ctx, r.db), "role_permissions", []string{"role_id", "permission_id"}, "role_id, permission_id", rows)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to batch assign permissions", zap.Error(err), zap.Int("count", len(rolePermissions)))
+		return created, fmt.Errorf("failed to batch assign permissions: %w", err)
+	}
+	return created, nil
+}
+
+// CopyRolePermissions 將 fromRoleID 的所有權限複製給 toRoleID，已擁有的權限略過
+func (r *permissionRepositoryImpl) CopyRolePermissions(ctx context.Context, fromRoleID, toRoleID int) error {
+	query := `INSERT INTO role_permissions (role_id, permission_id)
+              SELECT $2, permission_id FROM role_permissions WHERE role_id = $1
+              ON CONFLICT (role_id, permission_id) DO NOTHING`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, fromRoleID, toRoleID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to copy role permissions", zap.Error(err), zap.Int("from_role_id", fromRoleID), zap.Int("to_role_id", toRoleID))
+		return fmt.Errorf("failed to copy permissions from role %d to %d: %w", fromRoleID, toRoleID, err)
+	}
+	return nil
+}
+
 // RevokePermissionFromRole 從角色撤銷權限
-func (r *permissionRepositoryImpl) RevokePermissionFromRole(roleID, permissionID int) error {
+func (r *permissionRepositoryImpl) RevokePermissionFromRole(ctx context.Context, roleID, permissionID int) error {
 	query := `DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`
-	res, err := r.db.Exec(query, roleID, permissionID)
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, roleID, permissionID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to revoke permission from role", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to revoke permission from role", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
 		return fmt.Errorf("failed to revoke permission %d from role %d: %w", permissionID, roleID, err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after revoke", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after revoke", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
 		return fmt.Errorf("failed to check rows affected for revoke %d from %d: %w", permissionID, roleID, err)
 	}
 	if rowsAffected == 0 {
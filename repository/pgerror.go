@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// uniqueConstraintMessages 唯一約束 (或唯一索引) 名稱對應的衝突訊息，作為 409 錯誤的 details
+var uniqueConstraintMessages = map[string]string{
+	"accounts_username_key": "Username already exists",
+	"roles_name_key":        "Role name already exists",
+	"companies_name_key":    "Company name already exists",
+	"companies_tax_id_key":  "Company tax ID already exists",
+	"menus_path_key":        "Menu path already exists",
+}
+
+// foreignKeyConstraintMessages 刪除時擋下的外鍵約束 (ON DELETE RESTRICT) 名稱對應的衝突訊息
+var foreignKeyConstraintMessages = map[string]string{
+	"accounts_role_id_fkey":                         "Role is still assigned to accounts",
+	"product_definitions_category_id_fkey":          "Product category is still used by product definitions",
+	"product_categories_parent_id_fkey":             "Product category still has child categories",
+	"product_definitions_parent_definition_id_fkey": "Product definition still has variants",
+}
+
+// isUniqueViolation err 是否為指定約束 (唯一索引) 的衝突 (23505)
+func isUniqueViolation(err error, constraint string) bool {
+	name, ok := db.IsUniqueViolation(err)
+	return ok && name == constraint
+}
+
+// uniqueConflictError 若 err 為唯一約束衝突 (23505)，返回 409 錯誤，details 為 uniqueConstraintMessages 中該約束的訊息；否則返回 nil
+func uniqueConflictError(err error) error {
+	constraint, ok := db.IsUniqueViolation(err)
+	if !ok {
+		return nil
+	}
+	message, known := uniqueConstraintMessages[constraint]
+	if !known {
+		message = "Resource already exists"
+	}
+	return utils.ErrConflict.SetDetails(message)
+}
+
+// referencedConflictError 若 err 為外鍵約束錯誤 (23503)，例如刪除仍被其他記錄引用的記錄，返回 409 錯誤；否則返回 nil
+func referencedConflictError(err error) error {
+	constraint, ok := db.IsForeignKeyViolation(err)
+	if !ok {
+		return nil
+	}
+	message, known := foreignKeyConstraintMessages[constraint]
+	if !known {
+		message = "Resource is still referenced by other records"
+	}
+	return utils.ErrConflict.SetDetails(message)
+}
@@ -0,0 +1,1258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository/internal/queries"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// ProductDefinitionRepository 定義產品類別與產品定義的資料庫操作介面
+// 列表與依 ID 查詢在有唯讀副本時從副本讀取，ctx 以 db.WithPrimary 標記或在事務中時改用主庫
+type ProductDefinitionRepository interface {
+	CreateCategory(ctx context.Context, category *models.ProductCategory) error
+	FindAllCategories(ctx context.Context) ([]models.ProductCategory, error)
+	FindCategoryByID(ctx context.Context, id int) (*models.ProductCategory, error)
+	FindCategoryByName(ctx context.Context, name string) (*models.ProductCategory, error) // 不區分大小寫，優先返回完全相符的類別
+	UpdateCategory(ctx context.Context, category *models.ProductCategory) error
+	// DeleteCategory 依 mode 處理子類別；reassignTo 非 nil 時先將被刪除類別的產品定義移到該類別
+	DeleteCategory(ctx context.Context, id int, mode models.CategoryDeleteMode, reassignTo *int) error
+
+	Create(ctx context.Context, definition *models.ProductDefinition, actorID int, history []models.ProductDefinitionHistory) error                       // 在同一事務中寫入歷史 (ProductID 由新產品定義的 ID 填入)；actorID 記錄在 created_by/updated_by
+	FindAll(ctx context.Context, filter models.ProductDefinitionFilter, pagination utils.Pagination) ([]models.ProductDefinition, models.PageInfo, error) // 分頁搜尋，返回分頁資訊 (總筆數、是否有下一頁與游標)
+	FindByID(ctx context.Context, id int) (*models.ProductDefinition, error)
+	FindByPublicID(ctx context.Context, publicID string) (*models.ProductDefinition, error)                                         // 以對外的 UUID 識別碼查詢，包含已停售的產品定義
+	FindByNameAndCategory(ctx context.Context, name string, categoryID int) (*models.ProductDefinition, error)                      // 名稱不區分大小寫，不含已停售的產品定義
+	Update(ctx context.Context, definition *models.ProductDefinition, actorID int, history []models.ProductDefinitionHistory) error // 在同一事務中寫入歷史；actorID 記錄在 updated_by
+	// Delete 停售 (設定 discontinued_at)，不刪除記錄；Reactivate 清除 discontinued_at；actorID 記錄在 updated_by
+	// 兩者只在停售狀態確實改變時寫入 history，並由資料庫中的停售時間填入 discontinued_at 的新舊值
+	Delete(ctx context.Context, id, actorID int, history []models.ProductDefinitionHistory) error
+	Reactivate(ctx context.Context, id, actorID int, history []models.ProductDefinitionHistory) error
+	FindDistinctStandards(ctx context.Context) ([]string, error) // 使用中的標準代號 (去重並排序)
+	// Clone 在同一事務中複製產品定義及其幣別價格、數量分級價格與換算單位，返回新產品定義的 ID
+	// name 為空時沿用原名稱加上 "(copy)"，sku 為空時由原 SKU 產生；新產品一律為啟用狀態，建立者為 actorID
+	Clone(ctx context.Context, sourceID int, name, sku string, actorID int) (int, error)
+	// CountByCategoryID 計算類別下的產品定義數量，includeDescendants 為 true 時包含所有子孫類別
+	CountByCategoryID(ctx context.Context, categoryID int, includeDescendants bool) (int, error)
+	// CountGroupByCategory 以單一 GROUP BY 計算每個類別直接擁有的產品定義數量 (不含子孫類別)，沒有產品的類別不在結果中
+	CountGroupByCategory(ctx context.Context, includeDiscontinued bool) (map[int]int, error)
+	CountVariants(ctx context.Context, id int) (int, error) // 計算產品定義的變體數量 (含已停售)
+	// CreateVariants 在單一事務中建立變體並填入各自的 ID；SKU 已存在的變體不建立，
+	// 返回與 variants 對應的既有產品 ID (0 表示已建立)；建立的變體以 actorID 為建立者
+	CreateVariants(ctx context.Context, parentID int, variants []models.ProductDefinition, actorID int) ([]int, error)
+	// SetImage 記錄產品圖片並返回被取代的舊圖片 key (沒有時為空字串)；ClearImage 清除圖片並返回原本的 key；actorID 記錄在 updated_by
+	SetImage(ctx context.Context, id int, key, contentType string, actorID int) (string, error)
+	ClearImage(ctx context.Context, id, actorID int) (string, error)
+	// ImportBatch 在單一事務中依 SKU 批次 upsert，每列使用 savepoint，失敗的列不影響其他列；actorID 記錄在新增與更新的產品定義
+	// partial 為 false 時只要有一列失敗就整批回滾；dryRun 為 true 時一律回滾
+	ImportBatch(ctx context.Context, rows []models.ProductDefinitionImportRow, actorID int, partial, dryRun bool) ([]models.ImportRowResult, error)
+	// BulkUpdatePrices 在單一事務中鎖定符合 filter 的產品定義，依 adjustment 調整價格並寫入價格歷史
+	// 返回符合條件的數量與價格有改變的產品 (依 ID 排序)；dryRun 為 true 時一律回滾
+	BulkUpdatePrices(ctx context.Context, filter models.ProductDefinitionFilter, adjustment models.ProductPriceAdjustment, actorID int, dryRun bool) (int, []models.ProductPriceChange, error)
+}
+
+// maxProductPrice product_definitions.price (NUMERIC(12, 4)) 可儲存的最大值
+var maxProductPrice = decimal.MustParse("99999999.9999")
+
+// productDefinitionSortColumns 產品定義列表允許排序的欄位白名單
+var productDefinitionSortColumns = map[string]string{
+	"id":         "pd.id",
+	"name":       "pd.name",
+	"price":      "pd.price",
+	"standard":   "pd.standard",
+	"created_at": "pd.created_at",
+}
+
+// productDefinitionCursorColumns 產品定義排序欄位的型別，用於游標分頁 (見 keyset)
+var productDefinitionCursorColumns = map[string]cursorColumn{
+	"id":         {Expr: "pd.id", Type: "integer"},
+	"name":       {Expr: "pd.name", Type: "text"},
+	"price":      {Expr: "pd.price", Type: "numeric"},
+	"standard":   {Expr: "pd.standard", Type: "text"},
+	"created_at": {Expr: "pd.created_at", Type: "timestamptz"},
+}
+
+// productDefinitionColumns 動態查詢 (列表、搜尋) 產品定義時統一使用的欄位順序，需與 scanProductDefinition
+// 及 internal/queries/product_definition.sql 中 GetProductDefinitionByID 的欄位保持一致
+// 搭配 productDefinitionFrom 使用：pd 為 product_definitions，pc 為所屬類別，cb 與 ub 為建立者與最後修改者帳戶
+const productDefinitionColumns = `pd.id, pd.public_id, pd.sku, pd.name, pd.description, pd.category_id, pc.name, pd.standard, pd.unit, pd.price, pd.created_at, pd.updated_at, pd.discontinued_at, pd.image_key, pd.image_content_type, pd.image_updated_at, pd.parent_definition_id,
+    pd.created_by, cb.username, pd.updated_by, ub.username`
+
+// productDefinitionFrom 查詢產品定義時的 FROM 子句，JOIN 類別、建立者與最後修改者以取得名稱
+const productDefinitionFrom = ` FROM product_definitions pd JOIN product_categories pc ON pc.id = pd.category_id
+    LEFT JOIN accounts cb ON cb.id = pd.created_by LEFT JOIN accounts ub ON ub.id = pd.updated_by`
+
+// scanProductDefinition 將一列查詢結果掃描為 ProductDefinition，處理 NULLABLE 的描述與單位
+// extra 為 productDefinitionColumns 之後額外選取欄位的掃描目標
+func scanProductDefinition(row rowScanner, extra ...interface{}) (*models.ProductDefinition, error) {
+	var definition models.ProductDefinition
+	var sku, description, standard, unit sql.NullString
+	var discontinuedAt, imageUpdatedAt sql.NullTime
+	var imageKey, imageContentType sql.NullString
+	var parentID sql.NullInt64
+	dest := []interface{}{
+		&definition.ID,
+		&definition.PublicID,
+		&sku,
+		&definition.Name,
+		&description,
+		&definition.CategoryID,
+		&definition.CategoryName,
+		&standard,
+		&unit,
+		&definition.Price,
+		&definition.CreatedAt,
+		&definition.UpdatedAt,
+		&discontinuedAt,
+		&imageKey,
+		&imageContentType,
+		&imageUpdatedAt,
+		&parentID,
+	}
+	dest = append(dest, actorDest(&definition.RecordActors)...)
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		definition.ParentID = &id
+	}
+	setProductImage(&definition, imageKey, imageContentType, imageUpdatedAt)
+	if discontinuedAt.Valid {
+		definition.DiscontinuedAt = &discontinuedAt.Time
+		definition.Discontinued = true
+	}
+	definition.SKU = sku.String
+	definition.Description = description.String
+	definition.Standard = standard.String
+	definition.Unit = unit.String
+	return &definition, nil
+}
+
+// productDefinitionFromRow 將 sqlc 產生的產品定義查詢結果轉為 ProductDefinition
+// GetProductDefinitionByPublicIDRow 欄位相同，可直接轉換為 GetProductDefinitionByIDRow 後傳入
+func productDefinitionFromRow(row queries.GetProductDefinitionByIDRow) *models.ProductDefinition {
+	definition := &models.ProductDefinition{
+		ID:             row.ID,
+		PublicID:       row.PublicID,
+		SKU:            row.Sku.String,
+		Name:           row.Name,
+		Description:    row.Description.String,
+		CategoryID:     row.CategoryID,
+		CategoryName:   row.CategoryName,
+		Standard:       row.Standard.String,
+		Unit:           row.Unit.String,
+		Price:          row.Price,
+		CreatedAt:      row.CreatedAt.Time,
+		UpdatedAt:      row.UpdatedAt.Time,
+		DiscontinuedAt: optionalTime(row.DiscontinuedAt),
+		Discontinued:   row.DiscontinuedAt.Valid,
+		ParentID:       optionalInt(row.ParentDefinitionID),
+		RecordActors:   recordActors(row.CreatedBy, row.CreatedByUsername, row.UpdatedBy, row.UpdatedByUsername),
+	}
+	setProductImage(definition, row.ImageKey, row.ImageContentType, row.ImageUpdatedAt)
+	return definition
+}
+
+// setProductImage 填入圖片欄位，有圖片時以上傳時間作為 ImageURL 的版本參數，讓用戶端在更換圖片後不會使用快取的舊圖
+func setProductImage(definition *models.ProductDefinition, key, contentType sql.NullString, updatedAt sql.NullTime) {
+	definition.ImageKey = key.String
+	definition.ImageContentType = contentType.String
+	definition.ImageURL = ""
+	definition.ImageUpdatedAt = nil
+	if key.Valid && updatedAt.Valid {
+		definition.ImageUpdatedAt = &updatedAt.Time
+		definition.ImageURL = fmt.Sprintf("/api/v1/product_definitions/%d/image?v=%d", definition.ID, updatedAt.Time.Unix())
+	}
+}
+
+// productSearchDocument 全文搜尋比對的欄位組合，需與 migration 中的 trigram 索引運算式一致
+const productSearchDocument = `(pd.name || ' ' || coalesce(pd.description, '') || ' ' || coalesce(pd.unit, '') || ' ' || coalesce(pd.standard, ''))`
+
+// scanProductDefinitionWithRank 掃描 productDefinitionColumns 加上 match_rank 與 variant_count 欄位的查詢結果
+func scanProductDefinitionWithRank(row rowScanner) (*models.ProductDefinition, error) {
+	var matchRank sql.NullFloat64
+	var variantCount sql.NullInt64
+	definition, err := scanProductDefinition(row, &matchRank, &variantCount)
+	if err != nil {
+		return nil, err
+	}
+	if matchRank.Valid {
+		definition.MatchRank = &matchRank.Float64
+	}
+	if variantCount.Valid {
+		count := int(variantCount.Int64)
+		definition.VariantCount = &count
+	}
+	return definition, nil
+}
+
+// productDefinitionRepositoryImpl 實現 ProductDefinitionRepository 介面
+type productDefinitionRepositoryImpl struct {
+	db         *sql.DB
+	reader     *sql.DB     // 產品定義與類別的列表及依 ID 查詢使用的唯讀副本，nil 時使用 db (見 readConn)
+	useTrigram func() bool // 資料庫已安裝 pg_trgm 時以 word_similarity 計算搜尋排名
+	logger     *zap.Logger
+}
+
+// NewProductDefinitionRepository 創建 ProductDefinitionRepository 實例，reader 為唯讀副本 (db.Reader())，沒有副本時可傳入 nil
+// useTrigram 返回啟動時以 HasExtension(db, "pg_trgm") 偵測的結果 (資料庫連線後才能偵測)；為 false 時搜尋排名退回 ILIKE 命中比例
+func NewProductDefinitionRepository(db, reader *sql.DB, useTrigram func() bool, logger *zap.Logger) ProductDefinitionRepository {
+	return &productDefinitionRepositoryImpl{db: db, reader: reader, useTrigram: useTrigram, logger: logger}
+}
+
+// searchTerms 將搜尋字串以空白切分為詞
+func searchTerms(search string) []string {
+	return strings.Fields(search)
+}
+
+// searchRankExpr 返回搜尋排名 (0 到 1) 的 SQL 運算式，並將所需參數加入 args
+// 使用 pg_trgm 時為搜尋字串與 productSearchDocument 的 word_similarity，否則為名稱中命中的詞比例
+func (r *productDefinitionRepositoryImpl) searchRankExpr(search string, args *[]interface{}) string {
+	if r.useTrigram() {
+		*args = append(*args, search)
+		return fmt.Sprintf("word_similarity($%d, %s)", len(*args), productSearchDocument)
+	}
+	terms := searchTerms(search)
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		*args = append(*args, containsPattern(term))
+		parts = append(parts, fmt.Sprintf("CASE WHEN pd.name ILIKE $%d THEN 1 ELSE 0 END", len(*args)))
+	}
+	return fmt.Sprintf("((%s)::float / %d)", strings.Join(parts, " + "), len(terms))
+}
+
+// productCategoryColumns 依名稱查詢產品類別時使用的欄位順序，需與 scanProductCategory
+// 及 internal/queries/product_definition.sql 中 GetProductCategoryByID 的欄位保持一致
+const productCategoryColumns = `id, name, description, parent_id, created_at, updated_at`
+
+// scanProductCategory 將一列查詢結果掃描為 ProductCategory，處理 NULLABLE 的描述與父類別
+func scanProductCategory(row rowScanner) (*models.ProductCategory, error) {
+	var category models.ProductCategory
+	var description sql.NullString
+	var parentID sql.NullInt64
+	if err := row.Scan(&category.ID, &category.Name, &description, &parentID, &category.CreatedAt, &category.UpdatedAt); err != nil {
+		return nil, err
+	}
+	category.Description = description.String
+	if parentID.Valid {
+		category.ParentID = new(int)
+		*category.ParentID = int(parentID.Int64)
+	}
+	return &category, nil
+}
+
+// productCategoryFromRow 將 sqlc 產生的產品類別查詢結果轉為 ProductCategory
+// ListProductCategoriesRow 欄位相同，可直接轉換為 GetProductCategoryByIDRow 後傳入
+func productCategoryFromRow(row queries.GetProductCategoryByIDRow) *models.ProductCategory {
+	return &models.ProductCategory{
+		ID:          row.ID,
+		Name:        row.Name,
+		Description: row.Description.String,
+		ParentID:    optionalInt(row.ParentID),
+		CreatedAt:   row.CreatedAt.Time,
+		UpdatedAt:   row.UpdatedAt.Time,
+	}
+}
+
+// nullableInt 將 *int 轉為可寫入 NULLABLE 欄位的 sql.NullInt64
+func nullableInt(v *int) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{Valid: false}
+	}
+	return sql.NullInt64{Int64: int64(*v), Valid: true}
+}
+
+// CreateCategory 創建新產品類別
+func (r *productDefinitionRepositoryImpl) CreateCategory(ctx context.Context, category *models.ProductCategory) error {
+	row, err := queriesOn(r.db).CreateProductCategory(ctx, queries.CreateProductCategoryParams{
+		Name:        category.Name,
+		Description: category.Description,
+		ParentID:    nullableInt32(category.ParentID),
+	})
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create product category", zap.Error(err), zap.String("name", category.Name))
+		return fmt.Errorf("failed to create product category: %w", err)
+	}
+	category.ID, category.CreatedAt, category.UpdatedAt = row.ID, row.CreatedAt.Time, row.UpdatedAt.Time
+	return nil
+}
+
+// FindAllCategories 獲取所有產品類別
+func (r *productDefinitionRepositoryImpl) FindAllCategories(ctx context.Context) ([]models.ProductCategory, error) {
+	rows, err := queriesOn(readConn(ctx, r.db, r.reader)).ListProductCategories(ctx)
+	if err != nil { // 包含掃描與逐列讀取的錯誤
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all product categories", zap.Error(err))
+		return nil, fmt.Errorf("failed to get all product categories: %w", err)
+	}
+	categories := make([]models.ProductCategory, 0, len(rows))
+	for _, row := range rows {
+		categories = append(categories, *productCategoryFromRow(queries.GetProductCategoryByIDRow(row)))
+	}
+	return categories, nil
+}
+
+// FindCategoryByID 根據 ID 獲取產品類別
+func (r *productDefinitionRepositoryImpl) FindCategoryByID(ctx context.Context, id int) (*models.ProductCategory, error) {
+	row, err := queriesOn(readConn(ctx, r.db, r.reader)).GetProductCategoryByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product category by ID", zap.Int("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get product category by ID %d: %w", id, err)
+	}
+	return productCategoryFromRow(row), nil
+}
+
+// FindCategoryByName 根據名稱獲取產品類別，不區分大小寫；有多個僅大小寫不同的類別時優先返回完全相符者
+func (r *productDefinitionRepositoryImpl) FindCategoryByName(ctx context.Context, name string) (*models.ProductCategory, error) {
+	query := `SELECT ` + productCategoryColumns + ` FROM product_categories WHERE lower(name) = lower($1) ORDER BY name = $1 DESC, id LIMIT 1`
+	category, err := scanProductCategory(r.db.QueryRowContext(ctx, query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product category by name", zap.String("name", name), zap.Error(err))
+		return nil, fmt.Errorf("failed to get product category by name %q: %w", name, err)
+	}
+	return category, nil
+}
+
+// UpdateCategory 更新產品類別信息
+func (r *productDefinitionRepositoryImpl) UpdateCategory(ctx context.Context, category *models.ProductCategory) error {
+	query := `UPDATE product_categories SET name = $1, description = NULLIF($2, ''), parent_id = $3, updated_at = NOW() WHERE id = $4 RETURNING created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, category.Name, category.Description, nullableInt(category.ParentID), category.ID).Scan(&category.CreatedAt, &category.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要更新的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update product category", zap.Error(err), zap.Int("id", category.ID))
+		return fmt.Errorf("failed to update product category %d: %w", category.ID, err)
+	}
+	return nil
+}
+
+// categorySubtreeQuery 返回以遞迴 CTE 選出類別 $n 及其所有子孫類別 ID 的子查詢
+func categorySubtreeQuery(n int) string {
+	return fmt.Sprintf(`WITH RECURSIVE subtree AS (
+		SELECT id FROM product_categories WHERE id = $%d
+		UNION ALL
+		SELECT c.id FROM product_categories c JOIN subtree s ON c.parent_id = s.id
+	) SELECT id FROM subtree`, n)
+}
+
+// DeleteCategory 依 mode 刪除產品類別，並在同一事務中處理其子類別
+//   - block: 有子類別時返回 409
+//   - cascade: 一併刪除所有子孫類別
+//   - detach: 子類別的 parent_id 設為 NULL 後再刪除
+//
+// reassignTo 非 nil 時，先將被刪除類別 (cascade 時含子孫類別) 的產品定義移到該類別；
+// 被刪除的類別仍有產品定義時返回 409
+func (r *productDefinitionRepositoryImpl) DeleteCategory(ctx context.Context, id int, mode models.CategoryDeleteMode, reassignTo *int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product category delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	var childCount int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_categories WHERE parent_id = $1`, id).Scan(&childCount); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count product subcategories", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to count subcategories of %d: %w", id, err)
+	}
+
+	deleteQuery := `DELETE FROM product_categories WHERE id = $1`
+	switch mode {
+	case models.CategoryDeleteCascade:
+		deleteQuery = `DELETE FROM product_categories WHERE id IN (` + categorySubtreeQuery(1) + `)`
+	case models.CategoryDeleteDetach:
+		if _, err := tx.ExecContext(ctx, `UPDATE product_categories SET parent_id = NULL, updated_at = NOW() WHERE parent_id = $1`, id); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to detach product subcategories", zap.Error(err), zap.Int("id", id))
+			return fmt.Errorf("failed to detach subcategories of %d: %w", id, err)
+		}
+	default:
+		if childCount > 0 {
+			return utils.NewConflictError("Product category has subcategories", map[string]interface{}{
+				"category_id":       id,
+				"subcategory_count": childCount,
+				"hint":              "Use children=cascade to delete them or children=detach to make them root categories",
+			})
+		}
+	}
+
+	if reassignTo != nil {
+		reassignQuery := `UPDATE product_definitions SET category_id = $2, updated_at = NOW() WHERE category_id = $1`
+		if mode == models.CategoryDeleteCascade {
+			reassignQuery = `UPDATE product_definitions SET category_id = $2, updated_at = NOW() WHERE category_id IN (` + categorySubtreeQuery(1) + `)`
+		}
+		if _, err := tx.ExecContext(ctx, reassignQuery, id, *reassignTo); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to reassign product definitions", zap.Error(err), zap.Int("id", id), zap.Int("reassign_to", *reassignTo))
+			return fmt.Errorf("failed to reassign product definitions of category %d: %w", id, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, deleteQuery, id)
+	if err != nil {
+		if _, ok := db.IsForeignKeyViolation(err); ok { // 仍有產品定義引用被刪除的類別
+			return utils.NewConflictError("Product category is still used by product definitions", map[string]interface{}{"category_id": id})
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete product category", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to delete product category %d: %w", id, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		return utils.ErrNotFound // 未找到要刪除的記錄
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product category delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to commit product category delete: %w", err)
+	}
+	return nil
+}
+
+// productDefinitionSKUConstraint SKU 唯一索引名稱
+const productDefinitionSKUConstraint = "product_definitions_sku_key"
+
+// productDefinitionSoftDelete 產品定義以 discontinued_at (停售) 作為軟刪除欄位 (見 softDeletable)
+// 與其他資料表不同，FindByID 仍返回已停售的產品 (檢視、複製與重新啟用都需要)，列表以篩選條件的 IncludeDiscontinued 決定是否包含
+var productDefinitionSoftDelete = softDeletable{table: "product_definitions", alias: "pd", column: "discontinued_at", actors: true}
+
+// productDefinitionNameConstraint 同一類別中名稱 (不區分大小寫) 唯一的索引名稱
+const productDefinitionNameConstraint = "product_definitions_category_name_key"
+
+// cloneSKUMaxAttempts 複製產品時產生不重複 SKU 的最大嘗試次數
+const cloneSKUMaxAttempts = 100
+
+// skuConflictError 若 err 為 SKU 唯一索引衝突 (23505)，返回 409 錯誤；否則返回 nil
+func skuConflictError(err error, sku string) error {
+	if !isUniqueViolation(err, productDefinitionSKUConstraint) {
+		return nil
+	}
+	return utils.NewConflictError("Product definition SKU already exists", map[string]interface{}{"sku": sku})
+}
+
+// nameConflictError 若 err 為同類別名稱唯一索引衝突 (23505)，返回 409 錯誤；否則返回 nil
+func nameConflictError(err error, name string, categoryID int) error {
+	if !isUniqueViolation(err, productDefinitionNameConstraint) {
+		return nil
+	}
+	return utils.NewConflictError("A product definition with this name already exists in the category", map[string]interface{}{"name": name, "category_id": categoryID})
+}
+
+// nextCloneSKU 以 base 產生第一個未被使用的 SKU，格式為 "<base>-1"、"<base>-2"…
+func nextCloneSKU(ctx context.Context, q codeQueryer, base string) (string, error) {
+	for n := 1; n <= cloneSKUMaxAttempts; n++ {
+		candidate := fmt.Sprintf("%s-%d", base, n)
+		var exists bool
+		if err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM product_definitions WHERE sku = $1)`, candidate).Scan(&exists); err != nil {
+			return "", fmt.Errorf("failed to check product SKU %s: %w", candidate, err)
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a unique SKU from %s after %d attempts", base, cloneSKUMaxAttempts)
+}
+
+// Create 創建新產品定義，並在同一事務中寫入 history；建立者與最後修改者為 actorID
+func (r *productDefinitionRepositoryImpl) Create(ctx context.Context, definition *models.ProductDefinition, actorID int, history []models.ProductDefinitionHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product definition create", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	query := `INSERT INTO product_definitions (name, description, category_id, unit, price, standard, sku, parent_definition_id, created_by, updated_by)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5, NULLIF($6, ''), NULLIF($7, ''), $8, NULLIF($9, 0), NULLIF($9, 0)) RETURNING id, public_id, created_at, updated_at, ` + actorReturning("product_definitions")
+	err = tx.QueryRowContext(ctx, query,
+		definition.Name,
+		definition.Description,
+		definition.CategoryID,
+		definition.Unit,
+		definition.Price,
+		definition.Standard,
+		definition.SKU,
+		nullableInt(definition.ParentID),
+		actorID,
+	).Scan(append([]interface{}{&definition.ID, &definition.PublicID, &definition.CreatedAt, &definition.UpdatedAt}, actorDest(&definition.RecordActors)...)...)
+	if err != nil {
+		if conflictErr := skuConflictError(err, definition.SKU); conflictErr != nil {
+			return conflictErr
+		}
+		if conflictErr := nameConflictError(err, definition.Name, definition.CategoryID); conflictErr != nil {
+			return conflictErr // 與其他請求同時建立或更新
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create product definition", zap.Error(err), zap.String("name", definition.Name))
+		return fmt.Errorf("failed to create product definition: %w", err)
+	}
+
+	for i := range history {
+		history[i].ProductID = definition.ID
+	}
+	if err := insertProductDefinitionHistory(ctx, r.logger, tx, history); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product definition create", zap.Error(err), zap.Int("id", definition.ID))
+		return fmt.Errorf("failed to commit product definition create: %w", err)
+	}
+	return nil
+}
+
+// buildProductDefinitionWhere 依篩選條件組合 WHERE 子句與參數 (別名 pd 為 product_definitions)
+func buildProductDefinitionWhere(filter models.ProductDefinitionFilter) (string, []interface{}) {
+	// 動態組合 WHERE 條件，所有使用者輸入都透過參數佔位符傳入
+	conditions := []string{}
+	args := []interface{}{}
+	conditions = productDefinitionSoftDelete.conditions(conditions, filter.IncludeDiscontinued)
+	if filter.Query != "" {
+		// 每個欄位都有 pg_trgm 索引 (000040_search_trgm_indexes)，新增比對欄位時需一併建立索引，否則 OR 條件會退回整張表掃描
+		args = append(args, containsPattern(filter.Query))
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf("(pd.name ILIKE $%d OR pd.description ILIKE $%d OR pd.sku ILIKE $%d)", n, n, n))
+	}
+	if filter.CategoryID != nil {
+		args = append(args, *filter.CategoryID)
+		if filter.IncludeDescendants {
+			conditions = append(conditions, "pd.category_id IN ("+categorySubtreeQuery(len(args))+")")
+		} else {
+			conditions = append(conditions, fmt.Sprintf("pd.category_id = $%d", len(args)))
+		}
+	}
+	if filter.Standard != "" {
+		args = append(args, filter.Standard)
+		conditions = append(conditions, fmt.Sprintf("pd.standard = $%d", len(args)))
+	}
+	if filter.ParentID != nil {
+		args = append(args, *filter.ParentID)
+		conditions = append(conditions, fmt.Sprintf("pd.parent_definition_id = $%d", len(args)))
+	}
+	if filter.PriceMin != nil {
+		args = append(args, *filter.PriceMin)
+		conditions = append(conditions, fmt.Sprintf("pd.price >= $%d", len(args)))
+	}
+	if filter.PriceMax != nil {
+		args = append(args, *filter.PriceMax)
+		conditions = append(conditions, fmt.Sprintf("pd.price <= $%d", len(args)))
+	}
+	if filter.Unit != "" {
+		args = append(args, strings.ToLower(filter.Unit))
+		conditions = append(conditions, fmt.Sprintf("LOWER(pd.unit) = $%d", len(args)))
+	}
+	if len(filter.SKUs) > 0 {
+		args = append(args, filter.SKUs)
+		conditions = append(conditions, fmt.Sprintf("pd.sku = ANY($%d)", len(args)))
+	}
+	if filter.HasImage != nil {
+		if *filter.HasImage {
+			conditions = append(conditions, "pd.image_key IS NOT NULL")
+		} else {
+			conditions = append(conditions, "pd.image_key IS NULL")
+		}
+	}
+	if filter.Variants == models.VariantListCollapse {
+		conditions = append(conditions, "pd.parent_definition_id IS NULL") // 只比對非變體的產品本身
+	}
+	// 每個搜尋詞都必須出現在名稱、描述、單位或標準代號中 (不限順序)；安裝 pg_trgm 時 ILIKE 可使用 trigram 索引
+	for _, term := range searchTerms(filter.Search) {
+		args = append(args, containsPattern(term))
+		conditions = append(conditions, fmt.Sprintf("%s ILIKE $%d", productSearchDocument, len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// FindAll 依篩選條件分頁獲取產品定義，並返回分頁資訊
+// pagination.PageSize 為 0 時不分頁；未指定排序時依 ID 升序，有 Search 時依搜尋排名降序並填入 MatchRank
+// 依欄位排序 (含 ID) 時以游標分頁 (keyset)，有下一頁時返回 NextCursor；依搜尋排名排序時只支援 page (提供 cursor 時返回 400)
+// 總筆數只在 IncludeTotal 時於同一個查詢中計算 (見 totalColumn)，游標分頁或本頁沒有資料時才另外查詢 COUNT
+func (r *productDefinitionRepositoryImpl) FindAll(ctx context.Context, filter models.ProductDefinitionFilter, pagination utils.Pagination) ([]models.ProductDefinition, models.PageInfo, error) {
+	where, args := buildProductDefinitionWhere(filter)
+	orderBy, err := buildOrderBy(filter.Sort, productDefinitionSortColumns, "pd.id")
+	if err != nil {
+		return nil, models.PageInfo{}, err
+	}
+	countArgs := append([]interface{}{}, args...)
+	k, useKeyset := newKeyset(strings.TrimPrefix(filter.Sort, "-"), strings.HasPrefix(filter.Sort, "-"), productDefinitionCursorColumns, "pd.id")
+
+	rankColumn := ", NULL::float AS match_rank"
+	if strings.TrimSpace(filter.Search) != "" {
+		rankColumn = ", " + r.searchRankExpr(filter.Search, &args) + " AS match_rank"
+		if filter.Sort == "" {
+			orderBy = "match_rank DESC, pd.id ASC"
+			useKeyset = false
+		}
+	}
+	if !useKeyset && pagination.Cursor != "" {
+		return nil, models.PageInfo{}, utils.NewInvalidParamError("cursor", "cannot be combined with search ranking; specify sort")
+	}
+	variantColumn := ", NULL::int AS variant_count"
+	if filter.Variants == models.VariantListCollapse {
+		// 變體數量與列表相同，依 IncludeDiscontinued 決定是否計入已停售的變體
+		variantColumn = ", (SELECT COUNT(*) FROM product_definitions v WHERE v.parent_definition_id = pd.id" + productDefinitionSoftDelete.as("v").and(filter.IncludeDiscontinued) + ") AS variant_count"
+	}
+
+	countWhere := where
+	var clause string
+	cursorSelect := ", NULL::text AS cursor_value"
+	if useKeyset {
+		where, clause, args, err = k.pageClause(where, pagination, args)
+		if err != nil {
+			return nil, models.PageInfo{}, err
+		}
+		cursorSelect = k.selectColumn()
+	} else {
+		clause, args = pageProbeClause(orderBy, pagination, args)
+	}
+	rows, err := readConn(ctx, r.db, r.reader).QueryContext(ctx, `SELECT `+productDefinitionColumns+rankColumn+variantColumn+cursorSelect+totalColumn(pagination)+productDefinitionFrom+where+clause, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all product definitions", zap.Error(err))
+		return nil, models.PageInfo{}, fmt.Errorf("failed to get all product definitions: %w", err)
+	}
+	defer rows.Close()
+
+	definitions := []models.ProductDefinition{}
+	var page pageRows
+	for rows.Next() {
+		var columns pageColumns
+		definition, err := scanProductDefinitionWithRank(columns.scanner(rows))
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product definition data", zap.Int("row", len(definitions)+1), zap.Error(err))
+			return nil, models.PageInfo{}, fmt.Errorf("failed to scan product definition data at row %d: %w", len(definitions)+1, err)
+		}
+		definitions = append(definitions, *definition)
+		page.add(definition.ID, columns)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product definition data", zap.Error(err))
+		return nil, models.PageInfo{}, fmt.Errorf("error iterating product definition data: %w", err)
+	}
+	var cursorKeyset *keyset
+	if useKeyset {
+		cursorKeyset = &k
+	}
+	n, info, err := page.finish(ctx, pagination, cursorKeyset, func(ctx context.Context) (int, error) {
+		var total int
+		if err := readConn(ctx, r.db, r.reader).QueryRowContext(ctx, `SELECT COUNT(*) FROM product_definitions pd`+countWhere, countArgs...).Scan(&total); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count product definitions", zap.Error(err))
+			return 0, fmt.Errorf("failed to count product definitions: %w", err)
+		}
+		return total, nil
+	})
+	if err != nil {
+		return nil, models.PageInfo{}, err
+	}
+	return definitions[:n], info, nil
+}
+
+// FindByID 根據 ID 獲取產品定義
+func (r *productDefinitionRepositoryImpl) FindByID(ctx context.Context, id int) (*models.ProductDefinition, error) {
+	row, err := queriesOn(readConn(ctx, r.db, r.reader)).GetProductDefinitionByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product definition by ID", zap.Int("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get product definition by ID %d: %w", id, err)
+	}
+	return productDefinitionFromRow(row), nil
+}
+
+// FindByPublicID 根據對外的 UUID 識別碼獲取產品定義 (包含已停售的產品定義)
+func (r *productDefinitionRepositoryImpl) FindByPublicID(ctx context.Context, publicID string) (*models.ProductDefinition, error) {
+	row, err := queriesOn(readConn(ctx, r.db, r.reader)).GetProductDefinitionByPublicID(ctx, publicID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product definition by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get product definition by public ID %s: %w", publicID, err)
+	}
+	return productDefinitionFromRow(queries.GetProductDefinitionByIDRow(row)), nil
+}
+
+// FindByNameAndCategory 根據名稱 (不區分大小寫) 與類別獲取未停售的產品定義，用於檢查同類別中名稱是否重複
+// 名稱的唯一索引不含已停售的產品，停售產品的名稱可以再使用
+func (r *productDefinitionRepositoryImpl) FindByNameAndCategory(ctx context.Context, name string, categoryID int) (*models.ProductDefinition, error) {
+	query := `SELECT ` + productDefinitionColumns + productDefinitionFrom + ` WHERE pd.category_id = $1 AND LOWER(pd.name) = LOWER($2)` + productDefinitionSoftDelete.and(false)
+	definition, err := scanProductDefinition(r.db.QueryRowContext(ctx, query, categoryID, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product definition by name and category", zap.String("name", name), zap.Int("category_id", categoryID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get product definition by name %q in category %d: %w", name, categoryID, err)
+	}
+	return definition, nil
+}
+
+// Update 更新產品定義信息，並在同一事務中寫入 history 中的變更記錄；最後修改者為 actorID
+func (r *productDefinitionRepositoryImpl) Update(ctx context.Context, definition *models.ProductDefinition, actorID int, history []models.ProductDefinitionHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product definition update", zap.Error(err), zap.Int("id", definition.ID))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	var discontinuedAt, imageUpdatedAt sql.NullTime
+	var imageKey, imageContentType sql.NullString
+	query := `UPDATE product_definitions SET name = $1, description = NULLIF($2, ''), category_id = $3, unit = NULLIF($4, ''), price = $5, standard = NULLIF($6, ''), sku = NULLIF($7, ''), parent_definition_id = $8, updated_by = NULLIF($10, 0), updated_at = NOW() WHERE id = $9
+		RETURNING created_at, updated_at, discontinued_at, image_key, image_content_type, image_updated_at, ` + actorReturning("product_definitions")
+	err = tx.QueryRowContext(ctx, query,
+		definition.Name,
+		definition.Description,
+		definition.CategoryID,
+		definition.Unit,
+		definition.Price,
+		definition.Standard,
+		definition.SKU,
+		nullableInt(definition.ParentID),
+		definition.ID,
+		actorID,
+	).Scan(append([]interface{}{&definition.CreatedAt, &definition.UpdatedAt, &discontinuedAt, &imageKey, &imageContentType, &imageUpdatedAt}, actorDest(&definition.RecordActors)...)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要更新的記錄
+		}
+		if conflictErr := skuConflictError(err, definition.SKU); conflictErr != nil {
+			return conflictErr
+		}
+		if conflictErr := nameConflictError(err, definition.Name, definition.CategoryID); conflictErr != nil {
+			return conflictErr // 與其他請求同時建立或更新
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update product definition", zap.Error(err), zap.Int("id", definition.ID))
+		return fmt.Errorf("failed to update product definition %d: %w", definition.ID, err)
+	}
+
+	if err := insertProductDefinitionHistory(ctx, r.logger, tx, history); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product definition update", zap.Error(err), zap.Int("id", definition.ID))
+		return fmt.Errorf("failed to commit product definition update %d: %w", definition.ID, err)
+	}
+	definition.DiscontinuedAt = nil // 以資料庫中的停售狀態為準
+	definition.Discontinued = discontinuedAt.Valid
+	if discontinuedAt.Valid {
+		definition.DiscontinuedAt = &discontinuedAt.Time
+	}
+	setProductImage(definition, imageKey, imageContentType, imageUpdatedAt)
+	return nil
+}
+
+// Delete 停售產品定義 (軟刪除)，只設定 discontinued_at 以保留歷史報價的引用；已停售時保留原停售時間且不寫入 history
+// NOW() 在同一事務中固定不變，因此 discontinued_at = NOW() 表示此次才停售
+func (r *productDefinitionRepositoryImpl) Delete(ctx context.Context, id, actorID int, history []models.ProductDefinitionHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product definition discontinue", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	var discontinuedAt time.Time
+	var changed bool
+	err = tx.QueryRowContext(ctx, `UPDATE product_definitions SET discontinued_at = COALESCE(discontinued_at, NOW()), updated_by = NULLIF($2, 0), updated_at = NOW() WHERE id = $1
+		RETURNING discontinued_at, discontinued_at = NOW()`, id, actorID).Scan(&discontinuedAt, &changed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要刪除的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to discontinue product definition", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to discontinue product definition %d: %w", id, err)
+	}
+
+	if changed {
+		value := discontinuedAt.UTC().Format(models.ProductDefinitionHistoryTimeLayout)
+		for i := range history {
+			history[i].ProductID = id
+			history[i].OldValue = nil
+			history[i].NewValue = &value
+		}
+		if err := insertProductDefinitionHistory(ctx, r.logger, tx, history); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product definition discontinue", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to commit product definition discontinue %d: %w", id, err)
+	}
+	return nil
+}
+
+// FindDistinctStandards 獲取產品定義中使用中的標準代號，去重後依字母排序
+func (r *productDefinitionRepositoryImpl) FindDistinctStandards(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT standard FROM product_definitions WHERE standard IS NOT NULL`+productDefinitionSoftDelete.as("").and(false)+` ORDER BY standard`)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get distinct product standards", zap.Error(err))
+		return nil, fmt.Errorf("failed to get distinct product standards: %w", err)
+	}
+	defer rows.Close()
+
+	standards := []string{}
+	for rows.Next() {
+		var standard string
+		if err := rows.Scan(&standard); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product standard", zap.Int("row", len(standards)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan product standard at row %d: %w", len(standards)+1, err)
+		}
+		standards = append(standards, standard)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product standards", zap.Error(err))
+		return nil, fmt.Errorf("error iterating product standards: %w", err)
+	}
+	return standards, nil
+}
+
+// Clone 在同一事務中複製產品定義及其幣別價格、數量分級價格與換算單位，返回新產品定義的 ID
+// name 為空時沿用原名稱加上 "(copy)"；sku 為空時以原 SKU (沒有時為 "PD-<原 ID>") 加上序號產生；
+// 新產品的 discontinued_at 一律為 NULL，因此複製已停售的產品也會得到啟用中的新產品；建立者為 actorID，不沿用原產品的建立者
+func (r *productDefinitionRepositoryImpl) Clone(ctx context.Context, sourceID int, name, sku string, actorID int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product definition clone", zap.Error(err), zap.Int("source_id", sourceID))
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	var sourceName string
+	var sourceSKU sql.NullString
+	var categoryID int
+	err = tx.QueryRowContext(ctx, `SELECT name, sku, category_id FROM product_definitions WHERE id = $1`, sourceID).Scan(&sourceName, &sourceSKU, &categoryID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, utils.ErrNotFound // 未找到要複製的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get source product definition for clone", zap.Error(err), zap.Int("source_id", sourceID))
+		return 0, fmt.Errorf("failed to get product definition %d for clone: %w", sourceID, err)
+	}
+	if name == "" {
+		name = sourceName + " (copy)"
+	}
+	if sku == "" {
+		base := sourceSKU.String
+		if base == "" {
+			base = fmt.Sprintf("PD-%d", sourceID)
+		}
+		if sku, err = nextCloneSKU(ctx, tx, base); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to generate SKU for clone", zap.Error(err), zap.Int("source_id", sourceID))
+			return 0, err
+		}
+	}
+
+	var newID int
+	err = tx.QueryRowContext(ctx, `INSERT INTO product_definitions (name, description, category_id, unit, price, standard, sku, parent_definition_id, created_by, updated_by)
+		SELECT $2, description, category_id, unit, price, standard, $3, parent_definition_id, NULLIF($4, 0), NULLIF($4, 0) FROM product_definitions WHERE id = $1
+		RETURNING id`, sourceID, name, sku, actorID).Scan(&newID)
+	if err != nil {
+		if conflictErr := skuConflictError(err, sku); conflictErr != nil {
+			return 0, conflictErr
+		}
+		if conflictErr := nameConflictError(err, name, categoryID); conflictErr != nil {
+			return 0, conflictErr // 例如同一產品已複製過且未指定名稱
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to insert cloned product definition", zap.Error(err), zap.Int("source_id", sourceID))
+		return 0, fmt.Errorf("failed to clone product definition %d: %w", sourceID, err)
+	}
+
+	// 複製子資源：幣別價格、數量分級價格與換算單位
+	copies := []struct {
+		table string
+		query string
+	}{
+		{"product_prices", `INSERT INTO product_prices (product_id, currency, price, valid_from) SELECT $2, currency, price, valid_from FROM product_prices WHERE product_id = $1`},
+		{"product_price_tiers", `INSERT INTO product_price_tiers (product_id, min_qty, price) SELECT $2, min_qty, price FROM product_price_tiers WHERE product_id = $1`},
+		{"product_units", `INSERT INTO product_units (product_id, unit, factor) SELECT $2, unit, factor FROM product_units WHERE product_id = $1`},
+	}
+	for _, child := range copies {
+		if _, err := tx.ExecContext(ctx, child.query, sourceID, newID); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to copy product definition data for clone", zap.Error(err), zap.String("table", child.table), zap.Int("source_id", sourceID))
+			return 0, fmt.Errorf("failed to copy %s for clone of %d: %w", child.table, sourceID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product definition clone", zap.Error(err), zap.Int("source_id", sourceID))
+		return 0, fmt.Errorf("failed to commit product definition clone: %w", err)
+	}
+	return newID, nil
+}
+
+// CountByCategoryID 計算類別下的產品定義數量，includeDescendants 為 true 時包含所有子孫類別
+func (r *productDefinitionRepositoryImpl) CountByCategoryID(ctx context.Context, categoryID int, includeDescendants bool) (int, error) {
+	query := `SELECT COUNT(*) FROM product_definitions WHERE category_id = $1`
+	if includeDescendants {
+		query = `SELECT COUNT(*) FROM product_definitions WHERE category_id IN (` + categorySubtreeQuery(1) + `)`
+	}
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, categoryID).Scan(&count); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count product definitions by category", zap.Error(err), zap.Int("category_id", categoryID))
+		return 0, fmt.Errorf("failed to count product definitions of category %d: %w", categoryID, err)
+	}
+	return count, nil
+}
+
+// CountGroupByCategory 計算每個類別直接擁有的產品定義數量，includeDiscontinued 為 false 時不計已停售的產品
+func (r *productDefinitionRepositoryImpl) CountGroupByCategory(ctx context.Context, includeDiscontinued bool) (map[int]int, error) {
+	query := `SELECT category_id, COUNT(*) FROM product_definitions`
+	if !includeDiscontinued {
+		query += ` WHERE ` + productDefinitionSoftDelete.as("").active()
+	}
+	rows, err := r.db.QueryContext(ctx, query+` GROUP BY category_id`)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count product definitions per category", zap.Error(err))
+		return nil, fmt.Errorf("failed to count product definitions per category: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[int]int{}
+	for rows.Next() {
+		var categoryID, count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product definition count per category", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan product definition count: %w", err)
+		}
+		counts[categoryID] = count
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product definition counts per category", zap.Error(err))
+		return nil, fmt.Errorf("error iterating product definition counts: %w", err)
+	}
+	return counts, nil
+}
+
+// Reactivate 重新啟用已停售的產品定義，原本未停售時不寫入 history
+func (r *productDefinitionRepositoryImpl) Reactivate(ctx context.Context, id, actorID int, history []models.ProductDefinitionHistory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product definition reactivate", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	var previous sql.NullTime
+	err = tx.QueryRowContext(ctx, `UPDATE product_definitions pd SET discontinued_at = NULL, updated_by = NULLIF($2, 0), updated_at = NOW()
+		FROM (SELECT id, discontinued_at FROM product_definitions WHERE id = $1 FOR UPDATE) old
+		WHERE pd.id = old.id
+		RETURNING old.discontinued_at`, id, actorID).Scan(&previous)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要重新啟用的記錄
+		}
+		if isUniqueViolation(err, productDefinitionNameConstraint) {
+			// 停售期間同類別已有同名的產品，以停售產品的名稱回報衝突
+			var name string
+			var categoryID int
+			if lookupErr := r.db.QueryRowContext(ctx, `SELECT name, category_id FROM product_definitions WHERE id = $1`, id).Scan(&name, &categoryID); lookupErr == nil {
+				return nameConflictError(err, name, categoryID)
+			}
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to reactivate product definition", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to reactivate product definition %d: %w", id, err)
+	}
+
+	if previous.Valid {
+		value := previous.Time.UTC().Format(models.ProductDefinitionHistoryTimeLayout)
+		for i := range history {
+			history[i].ProductID = id
+			history[i].OldValue = &value
+			history[i].NewValue = nil
+		}
+		if err := insertProductDefinitionHistory(ctx, r.logger, tx, history); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product definition reactivate", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to commit product definition reactivate %d: %w", id, err)
+	}
+	return nil
+}
+
+// CountVariants 計算產品定義的變體數量 (含已停售)
+func (r *productDefinitionRepositoryImpl) CountVariants(ctx context.Context, id int) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_definitions WHERE parent_definition_id = $1`, id).Scan(&count); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count product variants", zap.Error(err), zap.Int("id", id))
+		return 0, fmt.Errorf("failed to count variants of product definition %d: %w", id, err)
+	}
+	return count, nil
+}
+
+// CreateVariants 在單一事務中建立 parentID 的變體 (建立者為 actorID)，SKU 已存在時略過該變體並返回既有的產品 ID
+func (r *productDefinitionRepositoryImpl) CreateVariants(ctx context.Context, parentID int, variants []models.ProductDefinition, actorID int) ([]int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product variants", zap.Error(err), zap.Int("parent_id", parentID))
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	existingIDs := make([]int, len(variants))
+	for i := range variants {
+		variant := &variants[i]
+		err := tx.QueryRowContext(ctx, `SELECT id FROM product_definitions WHERE sku = $1`, variant.SKU).Scan(&existingIDs[i])
+		if err == nil {
+			continue // SKU 已存在 (例如重複產生同一段長度)
+		}
+		if err != sql.ErrNoRows {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to check variant SKU", zap.Error(err), zap.String("sku", variant.SKU))
+			return nil, fmt.Errorf("failed to check variant SKU %s: %w", variant.SKU, err)
+		}
+		err = tx.QueryRowContext(ctx, `INSERT INTO product_definitions (name, description, category_id, unit, price, standard, sku, parent_definition_id, created_by, updated_by)
+			VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5, NULLIF($6, ''), $7, $8, NULLIF($9, 0), NULLIF($9, 0)) RETURNING id, public_id, created_at, updated_at, `+actorReturning("product_definitions"),
+			variant.Name, variant.Description, variant.CategoryID, variant.Unit, variant.Price, variant.Standard, variant.SKU, parentID, actorID,
+		).Scan(append([]interface{}{&variant.ID, &variant.PublicID, &variant.CreatedAt, &variant.UpdatedAt}, actorDest(&variant.RecordActors)...)...)
+		if err != nil {
+			if conflictErr := skuConflictError(err, variant.SKU); conflictErr != nil {
+				return nil, conflictErr // 與其他請求同時建立
+			}
+			if conflictErr := nameConflictError(err, variant.Name, variant.CategoryID); conflictErr != nil {
+				return nil, conflictErr // SKU 不同但名稱與類別中既有的產品相同
+			}
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create product variant", zap.Error(err), zap.Int("parent_id", parentID), zap.String("sku", variant.SKU))
+			return nil, fmt.Errorf("failed to create variant %s: %w", variant.SKU, err)
+		}
+		variant.ParentID = &parentID
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product variants", zap.Error(err), zap.Int("parent_id", parentID))
+		return nil, fmt.Errorf("failed to commit product variants: %w", err)
+	}
+	return existingIDs, nil
+}
+
+// SetImage 記錄產品圖片的 key 與格式，返回被取代的舊圖片 key；以 FOR UPDATE 鎖定該列，避免同時上傳時遺失舊 key
+func (r *productDefinitionRepositoryImpl) SetImage(ctx context.Context, id int, key, contentType string, actorID int) (string, error) {
+	return r.replaceImage(ctx, id, sql.NullString{String: key, Valid: true}, sql.NullString{String: contentType, Valid: true}, actorID)
+}
+
+// ClearImage 清除產品圖片，返回原本的圖片 key (沒有圖片時為空字串)
+func (r *productDefinitionRepositoryImpl) ClearImage(ctx context.Context, id, actorID int) (string, error) {
+	return r.replaceImage(ctx, id, sql.NullString{}, sql.NullString{}, actorID)
+}
+
+// replaceImage 更新圖片欄位並返回更新前的 key；key 為 NULL 時一併清除上傳時間
+func (r *productDefinitionRepositoryImpl) replaceImage(ctx context.Context, id int, key, contentType sql.NullString, actorID int) (string, error) {
+	query := `UPDATE product_definitions pd SET image_key = $2, image_content_type = $3, image_updated_at = CASE WHEN $2::text IS NULL THEN NULL ELSE NOW() END, updated_by = NULLIF($4, 0), updated_at = NOW()
+		FROM (SELECT id, image_key FROM product_definitions WHERE id = $1 FOR UPDATE) old
+		WHERE pd.id = old.id
+		RETURNING old.image_key`
+	var oldKey sql.NullString
+	if err := r.db.QueryRowContext(ctx, query, id, key, contentType, actorID).Scan(&oldKey); err != nil {
+		if err == sql.ErrNoRows {
+			return "", utils.ErrNotFound // 未找到要更新的記錄
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update product definition image", zap.Error(err), zap.Int("id", id))
+		return "", fmt.Errorf("failed to update image of product definition %d: %w", id, err)
+	}
+	return oldKey.String, nil
+}
+
+// ImportBatch 在單一事務中依 SKU 批次 upsert 產品定義
+// 每列在各自的 savepoint 中寫入，失敗時只回滾該列並標記為 invalid；CategoryID 為 0 的列以 CategoryName 建立類別 (同名只建立一次)。
+// 既有產品即使已停售也會更新，但不改變停售狀態。未提交 (dry run 或整批回滾) 時不返回新建產品的 ID
+func (r *productDefinitionRepositoryImpl) ImportBatch(ctx context.Context, rows []models.ProductDefinitionImportRow, actorID int, partial, dryRun bool) ([]models.ImportRowResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product definition import", zap.Error(err))
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback() // 確保在函數返回前回滾，除非明確提交
+
+	createdCategories := map[string]int{} // 小寫類別名稱 => 匯入時建立的類別 ID
+	results := make([]models.ImportRowResult, 0, len(rows))
+	failed := false
+	for _, row := range rows {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT product_import_row`); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create savepoint for product definition import", zap.Error(err), zap.Int("line", row.Line))
+			return nil, fmt.Errorf("line %d: failed to create savepoint: %w", row.Line, err)
+		}
+
+		result, newCategory, err := importProductDefinitionRow(ctx, tx, row, actorID, createdCategories)
+		if err != nil {
+			if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT product_import_row`); rbErr != nil {
+				utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to roll back product definition import row", zap.Error(rbErr), zap.Int("line", row.Line))
+				return nil, fmt.Errorf("line %d: failed to roll back to savepoint: %w", row.Line, rbErr)
+			}
+			if newCategory != "" {
+				delete(createdCategories, newCategory) // 該列建立的類別已隨 savepoint 回滾
+			}
+			utils.ContextLogger(ctx, r.logger).Warn("Repository: Product definition import row failed", zap.Error(err), zap.Int("line", row.Line), zap.String("sku", row.SKU))
+			results = append(results, models.ImportRowResult{Line: row.Line, Action: models.ImportActionInvalid, Details: err.Error()})
+			failed = true
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT product_import_row`); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to release savepoint for product definition import", zap.Error(err), zap.Int("line", row.Line))
+			return nil, fmt.Errorf("line %d: failed to release savepoint: %w", row.Line, err)
+		}
+		results = append(results, result)
+	}
+
+	if dryRun || (failed && !partial) {
+		for i := range results {
+			if results[i].Action == models.ImportActionCreated {
+				results[i].ID = nil // 回滾後此 ID 不存在，不返回
+			}
+		}
+		return results, nil // 延遲的 Rollback 會丟棄所有寫入
+	}
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product definition import", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit product definition import: %w", err)
+	}
+	return results, nil
+}
+
+// importProductDefinitionRow 在事務中寫入一列匯入資料 (操作者為 actorID)，返回處理結果；
+// newCategory 為此列新建類別在 createdCategories 中的鍵 (未建立時為空字串)，供失敗回滾時移除
+func importProductDefinitionRow(ctx context.Context, tx *sql.Tx, row models.ProductDefinitionImportRow, actorID int, createdCategories map[string]int) (models.ImportRowResult, string, error) {
+	newCategory := ""
+	categoryID := row.CategoryID
+	if categoryID == 0 {
+		key := strings.ToLower(row.CategoryName)
+		id, ok := createdCategories[key]
+		if !ok {
+			if err := tx.QueryRowContext(ctx, `INSERT INTO product_categories (name) VALUES ($1) RETURNING id`, row.CategoryName).Scan(&id); err != nil {
+				return models.ImportRowResult{}, "", fmt.Errorf("failed to create product category %q: %w", row.CategoryName, err)
+			}
+			createdCategories[key] = id
+			newCategory = key
+		}
+		categoryID = id
+	}
+
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM product_definitions WHERE sku = $1`, row.SKU).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return models.ImportRowResult{}, newCategory, fmt.Errorf("failed to match existing product definition: %w", err)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `UPDATE product_definitions SET name = $1, description = NULLIF($2, ''), category_id = $3, standard = NULLIF($4, ''), unit = NULLIF($5, ''), price = $6, updated_by = NULLIF($8, 0), updated_at = NOW() WHERE id = $7`,
+			row.Name, row.Description, categoryID, row.Standard, row.Unit, row.Price, id, actorID)
+		if err != nil {
+			if nameConflictError(err, row.Name, categoryID) != nil {
+				return models.ImportRowResult{}, newCategory, fmt.Errorf("product definition name already exists in category: %s", row.Name)
+			}
+			return models.ImportRowResult{}, newCategory, fmt.Errorf("failed to update product definition %d: %w", id, err)
+		}
+		return models.ImportRowResult{Line: row.Line, Action: models.ImportActionUpdated, ID: &id}, newCategory, nil
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO product_definitions (sku, name, description, category_id, standard, unit, price, created_by, updated_by) VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, 0), NULLIF($8, 0)) RETURNING id`,
+		row.SKU, row.Name, row.Description, categoryID, row.Standard, row.Unit, row.Price, actorID).Scan(&id)
+	if err != nil {
+		if conflictErr := skuConflictError(err, row.SKU); conflictErr != nil {
+			return models.ImportRowResult{}, newCategory, fmt.Errorf("product definition SKU already exists: %s", row.SKU) // 與其他請求同時建立
+		}
+		if nameConflictError(err, row.Name, categoryID) != nil {
+			return models.ImportRowResult{}, newCategory, fmt.Errorf("product definition name already exists in category: %s", row.Name)
+		}
+		return models.ImportRowResult{}, newCategory, fmt.Errorf("failed to create product definition: %w", err)
+	}
+	return models.ImportRowResult{Line: row.Line, Action: models.ImportActionCreated, ID: &id}, newCategory, nil
+}
+
+// BulkUpdatePrices 批次調整符合條件的產品定義價格，價格與歷史各以一個 unnest 陳述式寫入
+// 調整後有任何價格為負數或超出欄位範圍時整批不寫入並返回 400
+func (r *productDefinitionRepositoryImpl) BulkUpdatePrices(ctx context.Context, filter models.ProductDefinitionFilter, adjustment models.ProductPriceAdjustment, actorID int, dryRun bool) (int, []models.ProductPriceChange, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for bulk price update", zap.Error(err))
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	where, args := buildProductDefinitionWhere(filter)
+	rows, err := tx.QueryContext(ctx, `SELECT pd.id, COALESCE(pd.sku, ''), pd.name, pd.price FROM product_definitions pd`+where+` ORDER BY pd.id FOR UPDATE`, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to lock product definitions for bulk price update", zap.Error(err))
+		return 0, nil, fmt.Errorf("failed to select product definitions for bulk price update: %w", err)
+	}
+	matched := 0
+	changes := []models.ProductPriceChange{}
+	invalid := []int{}
+	for rows.Next() {
+		var change models.ProductPriceChange
+		if err := rows.Scan(&change.ProductID, &change.SKU, &change.Name, &change.OldPrice); err != nil {
+			rows.Close()
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product definition for bulk price update", zap.Error(err))
+			return 0, nil, fmt.Errorf("failed to scan product definition: %w", err)
+		}
+		matched++
+		change.NewPrice = adjustment.Apply(change.OldPrice)
+		if change.NewPrice.Equal(change.OldPrice) {
+			continue
+		}
+		if change.NewPrice.Sign() < 0 || change.NewPrice.Cmp(maxProductPrice) > 0 {
+			invalid = append(invalid, change.ProductID)
+		}
+		changes = append(changes, change)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to iterate product definitions for bulk price update", zap.Error(err))
+		return 0, nil, fmt.Errorf("failed to iterate product definitions: %w", err)
+	}
+	if len(invalid) > 0 {
+		return 0, nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Adjustment would make the price of %d product definition(s) negative or out of range, e.g. product %d", len(invalid), invalid[0]))
+	}
+	if dryRun || len(changes) == 0 {
+		return matched, changes, nil // 未提交的事務由 defer 回滾並釋放鎖
+	}
+
+	ids := make([]int64, len(changes))
+	oldPrices := make([]string, len(changes))
+	newPrices := make([]string, len(changes))
+	for i, change := range changes {
+		ids[i] = int64(change.ProductID)
+		oldPrices[i] = change.OldPrice.String()
+		newPrices[i] = change.NewPrice.String()
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE product_definitions pd SET price = v.price::numeric, updated_by = NULLIF($3, 0), updated_at = NOW()
+		FROM unnest($1::int[], $2::text[]) AS v(id, price)
+		WHERE pd.id = v.id`, ids, newPrices, actorID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to bulk update product prices", zap.Error(err), zap.Int("count", len(changes)))
+		return 0, nil, fmt.Errorf("failed to bulk update product prices: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO product_definition_history (product_definition_id, event, field, old_value, new_value, actor_account_id)
+		SELECT v.id, $1, 'price', v.old_price, v.new_price, $2
+		FROM unnest($3::int[], $4::text[], $5::text[]) AS v(id, old_price, new_price)`,
+		models.ProductDefinitionEventBulkPriceUpdated, actorID, ids, oldPrices, newPrices); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to insert bulk price update history", zap.Error(err), zap.Int("count", len(changes)))
+		return 0, nil, fmt.Errorf("failed to insert bulk price update history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit bulk price update", zap.Error(err))
+		return 0, nil, fmt.Errorf("failed to commit bulk price update: %w", err)
+	}
+	return matched, changes, nil
+}
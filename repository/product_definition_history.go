@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// ProductDefinitionHistoryRepository 定義產品定義變更歷史資料庫操作介面
+// 寫入由 ProductDefinitionRepository 在變更產品定義的同一事務中完成 (見 insertProductDefinitionHistory)
+type ProductDefinitionHistoryRepository interface {
+	FindByProductID(ctx context.Context, productID int, field string, pagination utils.Pagination) ([]models.ProductDefinitionHistory, int, error) // 依時間由新到舊分頁，field 不為空時只返回該欄位的變更
+	FindAllByProductID(ctx context.Context, productID int) ([]models.ProductDefinitionHistory, error)                                              // 依時間由舊到新返回全部記錄，用於重建過去的版本
+}
+
+// productDefinitionHistoryColumns 查詢歷史時統一使用的欄位順序，需與 scanProductDefinitionHistory 保持一致
+const productDefinitionHistoryColumns = `h.id, h.product_definition_id, h.event, h.field, h.old_value, h.new_value, h.actor_account_id, a.username, h.created_at`
+
+// productDefinitionHistoryFrom 查詢歷史時的 FROM 子句，JOIN 帳戶取得執行者名稱
+const productDefinitionHistoryFrom = ` FROM product_definition_history h LEFT JOIN accounts a ON a.id = h.actor_account_id`
+
+// scanProductDefinitionHistory 將一列查詢結果掃描為 ProductDefinitionHistory，處理 NULLABLE 的欄位與執行者
+func scanProductDefinitionHistory(row rowScanner) (*models.ProductDefinitionHistory, error) {
+	var entry models.ProductDefinitionHistory
+	var field, oldValue, newValue, actorUsername sql.NullString
+	var actorID sql.NullInt64
+	if err := row.Scan(
+		&entry.ID,
+		&entry.ProductID,
+		&entry.Event,
+		&field,
+		&oldValue,
+		&newValue,
+		&actorID,
+		&actorUsername,
+		&entry.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	entry.Field = field.String
+	if oldValue.Valid {
+		entry.OldValue = &oldValue.String
+	}
+	if newValue.Valid {
+		entry.NewValue = &newValue.String
+	}
+	if actorID.Valid {
+		entry.ActorID = new(int)
+		*entry.ActorID = int(actorID.Int64)
+	}
+	if actorUsername.Valid {
+		entry.ActorUsername = &actorUsername.String
+	}
+	return &entry, nil
+}
+
+// insertProductDefinitionHistory 在事務中寫入產品定義歷史記錄
+func insertProductDefinitionHistory(ctx context.Context, logger *zap.Logger, tx *sql.Tx, history []models.ProductDefinitionHistory) error {
+	for i := range history {
+		entry := &history[i]
+		err := tx.QueryRowContext(ctx, `INSERT INTO product_definition_history (product_definition_id, event, field, old_value, new_value, actor_account_id) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6) RETURNING id, created_at`,
+			entry.ProductID, entry.Event, entry.Field, entry.OldValue, entry.NewValue, entry.ActorID,
+		).Scan(&entry.ID, &entry.CreatedAt)
+		if err != nil {
+			utils.ContextLogger(ctx, logger).Error("Repository: Failed to insert product definition history", zap.Error(err), zap.Int("product_id", entry.ProductID), zap.String("event", entry.Event), zap.String("field", entry.Field))
+			return fmt.Errorf("failed to insert product definition history for product %d: %w", entry.ProductID, err)
+		}
+	}
+	return nil
+}
+
+// productDefinitionHistoryRepositoryImpl 實現 ProductDefinitionHistoryRepository 介面
+type productDefinitionHistoryRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewProductDefinitionHistoryRepository 創建 ProductDefinitionHistoryRepository 實例
+func NewProductDefinitionHistoryRepository(db *sql.DB, logger *zap.Logger) ProductDefinitionHistoryRepository {
+	return &productDefinitionHistoryRepositoryImpl{db: db, logger: logger}
+}
+
+// FindByProductID 分頁獲取產品定義的變更歷史，依時間由新到舊
+func (r *productDefinitionHistoryRepositoryImpl) FindByProductID(ctx context.Context, productID int, field string, pagination utils.Pagination) ([]models.ProductDefinitionHistory, int, error) {
+	where := ` WHERE h.product_definition_id = $1`
+	args := []interface{}{productID}
+	if field != "" {
+		args = append(args, field)
+		where += ` AND h.field = $2`
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_definition_history h`+where, args...).Scan(&total); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to count product definition history", zap.Error(err), zap.Int("product_id", productID))
+		return nil, 0, fmt.Errorf("failed to count history for product definition %d: %w", productID, err)
+	}
+
+	clause, args := pageClause("h.created_at DESC, h.id DESC", pagination, args)
+	history, err := r.query(ctx, `SELECT `+productDefinitionHistoryColumns+productDefinitionHistoryFrom+where+clause, productID, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return history, total, nil
+}
+
+// FindAllByProductID 獲取產品定義的全部變更歷史，依時間由舊到新
+func (r *productDefinitionHistoryRepositoryImpl) FindAllByProductID(ctx context.Context, productID int) ([]models.ProductDefinitionHistory, error) {
+	query := `SELECT ` + productDefinitionHistoryColumns + productDefinitionHistoryFrom + ` WHERE h.product_definition_id = $1 ORDER BY h.created_at, h.id`
+	return r.query(ctx, query, productID, productID)
+}
+
+// query 執行歷史查詢並掃描所有結果
+func (r *productDefinitionHistoryRepositoryImpl) query(ctx context.Context, query string, productID int, args ...interface{}) ([]models.ProductDefinitionHistory, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product definition history", zap.Error(err), zap.Int("product_id", productID))
+		return nil, fmt.Errorf("failed to get history for product definition %d: %w", productID, err)
+	}
+	defer rows.Close()
+
+	history := []models.ProductDefinitionHistory{}
+	for rows.Next() {
+		entry, err := scanProductDefinitionHistory(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product definition history", zap.Int("row", len(history)+1), zap.Error(err), zap.Int("product_id", productID))
+			return nil, fmt.Errorf("failed to scan product definition history at row %d: %w", len(history)+1, err)
+		}
+		history = append(history, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product definition history", zap.Error(err), zap.Int("product_id", productID))
+		return nil, fmt.Errorf("error iterating product definition history: %w", err)
+	}
+	return history, nil
+}
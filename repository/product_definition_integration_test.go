@@ -0,0 +1,892 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/wac0705/fastener-api/db/dbtest"
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// newTestProductDefinitionRepository 建立只使用主庫的 ProductDefinitionRepository，搜尋排名使用 ILIKE 命中比例 (不依賴 pg_trgm)
+func newTestProductDefinitionRepository(t *testing.T, database *sql.DB) ProductDefinitionRepository {
+	return NewProductDefinitionRepository(database, nil, func() bool { return false }, zaptest.NewLogger(t))
+}
+
+// TestProductCategoryRepositoryIntegration 類別的 parent_id 為 NULL 與有值都能原樣讀回；刪除時依 mode 處理子類別，
+// 仍有產品定義的類別返回 409，reassignTo 在同一事務中先移走產品定義
+func TestProductCategoryRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := newTestProductDefinitionRepository(t, database)
+	rootID := dbtest.SeedProductCategory(t, database, "Fasteners", nil)
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			category models.ProductCategory
+			wantCode int
+		}{
+			{name: "root", category: models.ProductCategory{Name: "Tools", Description: "Hand tools"}},
+			{name: "with parent", category: models.ProductCategory{Name: "Bolts", ParentID: &rootID}},
+			{name: "unknown parent", category: models.ProductCategory{Name: "Orphans", ParentID: intPtr(rootID + 100)}, wantCode: -1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				category := tt.category
+				err := repo.CreateCategory(ctx, &category)
+				checkErr(t, err, tt.wantCode)
+				if err != nil {
+					return
+				}
+				found, err := repo.FindCategoryByID(ctx, category.ID)
+				checkErr(t, err, 0)
+				if found == nil || found.Name != tt.category.Name || found.Description != tt.category.Description {
+					t.Fatalf("FindCategoryByID = %+v, want %+v", found, tt.category)
+				}
+				if !equalIntPtr(found.ParentID, tt.category.ParentID) {
+					t.Errorf("ParentID = %v, want %v", found.ParentID, tt.category.ParentID)
+				}
+			})
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		all, err := repo.FindAllCategories(ctx)
+		checkErr(t, err, 0)
+		if len(all) != 3 {
+			t.Errorf("FindAllCategories = %+v, want 3 categories", all)
+		}
+		byName, err := repo.FindCategoryByName(ctx, "fasteners")
+		checkErr(t, err, 0)
+		if byName == nil || byName.ID != rootID || byName.ParentID != nil {
+			t.Errorf("FindCategoryByName(fasteners) = %+v, want root category %d", byName, rootID)
+		}
+		for name, find := range map[string]func() (*models.ProductCategory, error){
+			"FindCategoryByID":   func() (*models.ProductCategory, error) { return repo.FindCategoryByID(ctx, rootID+100) },
+			"FindCategoryByName": func() (*models.ProductCategory, error) { return repo.FindCategoryByName(ctx, "Missing") },
+		} {
+			missing, err := find()
+			checkErr(t, err, 0)
+			if missing != nil {
+				t.Errorf("%s(missing) = %+v, want nil", name, missing)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		childID := dbtest.SeedProductCategory(t, database, "Washers", &rootID)
+		tests := []struct {
+			name     string
+			category models.ProductCategory
+			wantCode int
+		}{
+			{name: "clear parent", category: models.ProductCategory{ID: childID, Name: "Washers"}},
+			{name: "set parent", category: models.ProductCategory{ID: childID, Name: "Washers", Description: "Flat", ParentID: &rootID}},
+			{name: "missing", category: models.ProductCategory{ID: rootID + 100, Name: "Nobody"}, wantCode: http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				category := tt.category
+				err := repo.UpdateCategory(ctx, &category)
+				checkErr(t, err, tt.wantCode)
+				if err != nil {
+					return
+				}
+				found, err := repo.FindCategoryByID(ctx, category.ID)
+				checkErr(t, err, 0)
+				if found == nil || found.Description != tt.category.Description || !equalIntPtr(found.ParentID, tt.category.ParentID) {
+					t.Errorf("after UpdateCategory = %+v, want %+v", found, tt.category)
+				}
+			})
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		exists := func(t *testing.T, id int) bool {
+			t.Helper()
+			found, err := repo.FindCategoryByID(ctx, id)
+			checkErr(t, err, 0)
+			return found != nil
+		}
+
+		blockID := dbtest.SeedProductCategory(t, database, "Block Parent", nil)
+		blockChildID := dbtest.SeedProductCategory(t, database, "Block Child", &blockID)
+		checkErr(t, repo.DeleteCategory(ctx, blockID, models.CategoryDeleteBlock, nil), http.StatusConflict)
+
+		checkErr(t, repo.DeleteCategory(ctx, blockID, models.CategoryDeleteDetach, nil), 0)
+		child, err := repo.FindCategoryByID(ctx, blockChildID)
+		checkErr(t, err, 0)
+		if exists(t, blockID) || child == nil || child.ParentID != nil {
+			t.Errorf("after detach: parent exists %v, child %+v; want the child as a root category", exists(t, blockID), child)
+		}
+
+		cascadeID := dbtest.SeedProductCategory(t, database, "Cascade Parent", nil)
+		cascadeChildID := dbtest.SeedProductCategory(t, database, "Cascade Child", &cascadeID)
+		cascadeGrandchildID := dbtest.SeedProductCategory(t, database, "Cascade Grandchild", &cascadeChildID)
+		productID := dbtest.SeedProductDefinition(t, database, "Cascade Bolt", "", cascadeGrandchildID, "1.00")
+		// 子孫類別仍有產品定義時，外鍵讓整個刪除回滾
+		checkErr(t, repo.DeleteCategory(ctx, cascadeID, models.CategoryDeleteCascade, nil), http.StatusConflict)
+		if !exists(t, cascadeChildID) {
+			t.Fatal("failed cascade delete removed a subcategory")
+		}
+		checkErr(t, repo.DeleteCategory(ctx, cascadeID, models.CategoryDeleteCascade, &rootID), 0)
+		for _, id := range []int{cascadeID, cascadeChildID, cascadeGrandchildID} {
+			if exists(t, id) {
+				t.Errorf("category %d still exists after cascade delete", id)
+			}
+		}
+		moved, err := repo.FindByID(ctx, productID)
+		checkErr(t, err, 0)
+		if moved == nil || moved.CategoryID != rootID {
+			t.Errorf("product after reassign = %+v, want category %d", moved, rootID)
+		}
+
+		checkErr(t, repo.DeleteCategory(ctx, rootID+100, models.CategoryDeleteBlock, nil), http.StatusNotFound)
+	})
+}
+
+// TestProductDefinitionRepositoryIntegration 產品定義的新增、查詢、更新、停售與重新啟用，SKU 與同類別名稱衝突返回 409，
+// 停售狀態只在確實改變時寫入歷史
+func TestProductDefinitionRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := newTestProductDefinitionRepository(t, database)
+	histories := NewProductDefinitionHistoryRepository(database, zaptest.NewLogger(t))
+	actorID := dbtest.SeedAccount(t, database, "product_admin", dbtest.SeedRole(t, database, "product_role"))
+	boltsID := dbtest.SeedProductCategory(t, database, "Bolts", nil)
+	nutsID := dbtest.SeedProductCategory(t, database, "Nuts", nil)
+	historyCount := func(t *testing.T, productID int, field string) int {
+		t.Helper()
+		_, total, err := histories.FindByProductID(ctx, productID, field, utils.Pagination{})
+		checkErr(t, err, 0)
+		return total
+	}
+
+	bolt := &models.ProductDefinition{Name: "Hex Bolt M8", SKU: "M8", CategoryID: boltsID, Standard: "DIN 933", Unit: "pcs", Price: decimal.MustParse("1.2500")}
+	checkErr(t, repo.Create(ctx, bolt, actorID, []models.ProductDefinitionHistory{{Event: models.ProductDefinitionEventCreated, ActorID: &actorID}}), 0)
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			definition models.ProductDefinition
+			wantCode   int
+		}{
+			{name: "no SKU", definition: models.ProductDefinition{Name: "Hex Nut M8", CategoryID: nutsID, Price: decimal.MustParse("0.1000")}},
+			{name: "variant", definition: models.ProductDefinition{Name: "Hex Bolt M8x20", SKU: "M8-20", CategoryID: boltsID, ParentID: &bolt.ID, Price: decimal.MustParse("1.3000")}},
+			{name: "duplicate SKU", definition: models.ProductDefinition{Name: "Other", SKU: "M8", CategoryID: boltsID}, wantCode: http.StatusConflict},
+			{name: "duplicate name in category ignores case", definition: models.ProductDefinition{Name: "hex bolt m8", CategoryID: boltsID}, wantCode: http.StatusConflict},
+			{name: "same name in another category", definition: models.ProductDefinition{Name: "Hex Bolt M8", CategoryID: nutsID}},
+			{name: "unknown category", definition: models.ProductDefinition{Name: "Lost", CategoryID: nutsID + 100}, wantCode: -1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				definition := tt.definition
+				err := repo.Create(ctx, &definition, actorID, nil)
+				checkErr(t, err, tt.wantCode)
+				if err != nil {
+					return
+				}
+				found, err := repo.FindByID(ctx, definition.ID)
+				checkErr(t, err, 0)
+				if found == nil || found.Name != tt.definition.Name || found.SKU != tt.definition.SKU || !found.Price.Equal(tt.definition.Price) {
+					t.Fatalf("FindByID = %+v, want %+v", found, tt.definition)
+				}
+				if !equalIntPtr(found.ParentID, tt.definition.ParentID) {
+					t.Errorf("ParentID = %v, want %v", found.ParentID, tt.definition.ParentID)
+				}
+			})
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		found, err := repo.FindByPublicID(ctx, bolt.PublicID)
+		checkErr(t, err, 0)
+		if found == nil || found.ID != bolt.ID || found.CategoryName != "Bolts" || found.Standard != "DIN 933" || found.Discontinued {
+			t.Fatalf("FindByPublicID = %+v, want the active bolt in Bolts", found)
+		}
+		if found.CreatedByUsername == nil || *found.CreatedByUsername != "product_admin" {
+			t.Errorf("CreatedByUsername = %v, want product_admin", found.CreatedByUsername)
+		}
+		byName, err := repo.FindByNameAndCategory(ctx, "HEX BOLT M8", boltsID)
+		checkErr(t, err, 0)
+		if byName == nil || byName.ID != bolt.ID {
+			t.Errorf("FindByNameAndCategory = %+v, want %d", byName, bolt.ID)
+		}
+		if n := historyCount(t, bolt.ID, ""); n != 1 {
+			t.Errorf("history entries after Create = %d, want 1", n)
+		}
+
+		for name, find := range map[string]func() (*models.ProductDefinition, error){
+			"FindByID": func() (*models.ProductDefinition, error) { return repo.FindByID(ctx, bolt.ID+100) },
+			"FindByPublicID": func() (*models.ProductDefinition, error) {
+				return repo.FindByPublicID(ctx, "00000000-0000-0000-0000-000000000000")
+			},
+			"FindByNameAndCategory": func() (*models.ProductDefinition, error) {
+				return repo.FindByNameAndCategory(ctx, "Hex Bolt M8", boltsID+100)
+			},
+		} {
+			missing, err := find()
+			checkErr(t, err, 0)
+			if missing != nil {
+				t.Errorf("%s(missing) = %+v, want nil", name, missing)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		oldPrice, newPrice := "1.2500", "1.5000"
+		updated := *bolt
+		updated.Price = decimal.MustParse(newPrice)
+		updated.Description = "Zinc plated"
+		checkErr(t, repo.Update(ctx, &updated, actorID, []models.ProductDefinitionHistory{
+			{ProductID: bolt.ID, Event: models.ProductDefinitionEventUpdated, Field: "price", OldValue: &oldPrice, NewValue: &newPrice, ActorID: &actorID},
+		}), 0)
+		found, err := repo.FindByID(ctx, bolt.ID)
+		checkErr(t, err, 0)
+		if found == nil || found.Description != "Zinc plated" || !found.Price.Equal(updated.Price) {
+			t.Errorf("after Update = %+v", found)
+		}
+		if n := historyCount(t, bolt.ID, "price"); n != 1 {
+			t.Errorf("price history entries = %d, want 1", n)
+		}
+
+		conflict := updated
+		conflict.SKU = "M8-20"
+		checkErr(t, repo.Update(ctx, &conflict, actorID, nil), http.StatusConflict)
+		missing := updated
+		missing.ID = bolt.ID + 100
+		checkErr(t, repo.Update(ctx, &missing, actorID, nil), http.StatusNotFound)
+	})
+
+	t.Run("DiscontinueAndReactivate", func(t *testing.T) {
+		discontinued := func() []models.ProductDefinitionHistory {
+			return []models.ProductDefinitionHistory{{Event: models.ProductDefinitionEventDiscontinued, Field: "discontinued_at", ActorID: &actorID}}
+		}
+		reactivated := func() []models.ProductDefinitionHistory {
+			return []models.ProductDefinitionHistory{{Event: models.ProductDefinitionEventReactivated, Field: "discontinued_at", ActorID: &actorID}}
+		}
+
+		checkErr(t, repo.Delete(ctx, bolt.ID, actorID, discontinued()), 0)
+		first, err := repo.FindByID(ctx, bolt.ID)
+		checkErr(t, err, 0)
+		if first == nil || !first.Discontinued || first.DiscontinuedAt == nil {
+			t.Fatalf("after Delete = %+v, want discontinued", first)
+		}
+		checkErr(t, repo.Delete(ctx, bolt.ID, actorID, discontinued()), 0) // 已停售，保留原停售時間
+		again, err := repo.FindByID(ctx, bolt.ID)
+		checkErr(t, err, 0)
+		if !again.DiscontinuedAt.Equal(*first.DiscontinuedAt) {
+			t.Errorf("discontinued_at changed from %v to %v", first.DiscontinuedAt, again.DiscontinuedAt)
+		}
+		if n := historyCount(t, bolt.ID, "discontinued_at"); n != 1 {
+			t.Errorf("discontinued_at history entries after two deletes = %d, want 1", n)
+		}
+		byName, err := repo.FindByNameAndCategory(ctx, "Hex Bolt M8", boltsID)
+		checkErr(t, err, 0)
+		if byName != nil {
+			t.Errorf("FindByNameAndCategory = %+v, want nil for a discontinued product", byName)
+		}
+
+		checkErr(t, repo.Reactivate(ctx, bolt.ID, actorID, reactivated()), 0)
+		checkErr(t, repo.Reactivate(ctx, bolt.ID, actorID, reactivated()), 0) // 未停售時不寫入歷史
+		if n := historyCount(t, bolt.ID, "discontinued_at"); n != 2 {
+			t.Errorf("discontinued_at history entries after reactivate = %d, want 2", n)
+		}
+
+		// 停售期間同類別建立了同名產品，重新啟用時名稱衝突
+		checkErr(t, repo.Delete(ctx, bolt.ID, actorID, nil), 0)
+		checkErr(t, repo.Create(ctx, &models.ProductDefinition{Name: "Hex Bolt M8", CategoryID: boltsID, Price: decimal.Zero}, actorID, nil), 0)
+		checkErr(t, repo.Reactivate(ctx, bolt.ID, actorID, nil), http.StatusConflict)
+
+		checkErr(t, repo.Delete(ctx, bolt.ID+100, actorID, nil), http.StatusNotFound)
+		checkErr(t, repo.Reactivate(ctx, bolt.ID+100, actorID, nil), http.StatusNotFound)
+	})
+}
+
+// TestProductDefinitionQueriesIntegration 列表篩選、游標分頁與各種計數
+func TestProductDefinitionQueriesIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := newTestProductDefinitionRepository(t, database)
+	fastenersID := dbtest.SeedProductCategory(t, database, "Fasteners", nil)
+	boltsID := dbtest.SeedProductCategory(t, database, "Bolts", &fastenersID)
+	ids := []int{
+		dbtest.SeedProductDefinition(t, database, "Hex Bolt M6", "B-M6", boltsID, "0.8000"),
+		dbtest.SeedProductDefinition(t, database, "Hex Bolt M8", "B-M8", boltsID, "1.2000"),
+		dbtest.SeedProductDefinition(t, database, "Flange Nut M8", "N-M8", fastenersID, "0.3000"),
+		dbtest.SeedProductDefinition(t, database, "Old Bolt", "B-OLD", boltsID, "2.0000"),
+	}
+	variantID := dbtest.SeedProductDefinition(t, database, "Hex Bolt M8x20", "B-M8-20", boltsID, "1.3000")
+	if _, err := database.Exec(`UPDATE product_definitions SET parent_definition_id = $1 WHERE id = $2`, ids[1], variantID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`UPDATE product_definitions SET standard = 'DIN 933' WHERE id = ANY($1)`, []int64{int64(ids[0]), int64(ids[1])}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`UPDATE product_definitions SET discontinued_at = NOW() WHERE id = $1`, ids[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("FindAll", func(t *testing.T) {
+		minPrice := decimal.MustParse("1.0000")
+		tests := []struct {
+			name   string
+			filter models.ProductDefinitionFilter
+			want   []int
+		}{
+			{name: "active", want: []int{ids[0], ids[1], ids[2], variantID}},
+			{name: "include discontinued", filter: models.ProductDefinitionFilter{IncludeDiscontinued: true}, want: []int{ids[0], ids[1], ids[2], ids[3], variantID}},
+			{name: "category", filter: models.ProductDefinitionFilter{CategoryID: &fastenersID}, want: []int{ids[2]}},
+			{name: "category with descendants", filter: models.ProductDefinitionFilter{CategoryID: &fastenersID, IncludeDescendants: true}, want: []int{ids[0], ids[1], ids[2], variantID}},
+			{name: "standard", filter: models.ProductDefinitionFilter{Standard: "DIN 933"}, want: []int{ids[0], ids[1]}},
+			{name: "variants of", filter: models.ProductDefinitionFilter{ParentID: &ids[1]}, want: []int{variantID}},
+			{name: "price minimum", filter: models.ProductDefinitionFilter{PriceMin: &minPrice}, want: []int{ids[1], variantID}},
+			{name: "SKUs", filter: models.ProductDefinitionFilter{SKUs: []string{"B-M6", "N-M8"}}, want: []int{ids[0], ids[2]}},
+			{name: "query", filter: models.ProductDefinitionFilter{Query: "nut"}, want: []int{ids[2]}},
+			{name: "search matches every term", filter: models.ProductDefinitionFilter{Search: "bolt m8"}, want: []int{ids[1], variantID}},
+			{name: "sort by price descending", filter: models.ProductDefinitionFilter{Sort: "-price"}, want: []int{variantID, ids[1], ids[0], ids[2]}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				definitions, _, err := repo.FindAll(ctx, tt.filter, utils.Pagination{})
+				checkErr(t, err, 0)
+				got := make([]int, len(definitions))
+				for i, definition := range definitions {
+					got[i] = definition.ID
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("FindAll = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("FindAllCollapsesVariants", func(t *testing.T) {
+		definitions, _, err := repo.FindAll(ctx, models.ProductDefinitionFilter{Variants: models.VariantListCollapse}, utils.Pagination{})
+		checkErr(t, err, 0)
+		if len(definitions) != 3 {
+			t.Fatalf("FindAll(collapse) = %+v, want the 3 active non-variants", definitions)
+		}
+		for _, definition := range definitions {
+			want := 0
+			if definition.ID == ids[1] {
+				want = 1
+			}
+			if definition.VariantCount == nil || *definition.VariantCount != want {
+				t.Errorf("product %d VariantCount = %v, want %d", definition.ID, definition.VariantCount, want)
+			}
+		}
+	})
+
+	t.Run("FindAllPages", func(t *testing.T) {
+		first, info, err := repo.FindAll(ctx, models.ProductDefinitionFilter{}, utils.Pagination{Page: 1, PageSize: 3, IncludeTotal: true})
+		checkErr(t, err, 0)
+		if len(first) != 3 || !info.HasMore || info.NextCursor == "" || info.Total == nil || *info.Total != 4 {
+			t.Fatalf("first page = %d rows, %+v; want 3 rows, more pages and total 4", len(first), info)
+		}
+		cursor, err := utils.DecodeCursor(info.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, info, err := repo.FindAll(ctx, models.ProductDefinitionFilter{}, utils.Pagination{Page: 1, PageSize: 3, Cursor: cursor, IncludeTotal: true})
+		checkErr(t, err, 0)
+		if len(second) != 1 || second[0].ID != variantID || info.HasMore || info.Total == nil || *info.Total != 4 {
+			t.Errorf("second page = %+v, %+v; want only %d and total 4", second, info, variantID)
+		}
+
+		_, _, err = repo.FindAll(ctx, models.ProductDefinitionFilter{Search: "bolt"}, utils.Pagination{Page: 1, PageSize: 3, Cursor: cursor})
+		checkErr(t, err, http.StatusBadRequest)
+		_, _, err = repo.FindAll(ctx, models.ProductDefinitionFilter{Sort: "unknown"}, utils.Pagination{})
+		checkErr(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Counts", func(t *testing.T) {
+		standards, err := repo.FindDistinctStandards(ctx)
+		checkErr(t, err, 0)
+		if !reflect.DeepEqual(standards, []string{"DIN 933"}) {
+			t.Errorf("FindDistinctStandards = %v, want [DIN 933]", standards)
+		}
+
+		direct, err := repo.CountByCategoryID(ctx, fastenersID, false)
+		checkErr(t, err, 0)
+		withDescendants, err := repo.CountByCategoryID(ctx, fastenersID, true)
+		checkErr(t, err, 0)
+		if direct != 1 || withDescendants != 5 { // 計數包含已停售的產品
+			t.Errorf("CountByCategoryID = %d direct and %d with descendants, want 1 and 5", direct, withDescendants)
+		}
+
+		active, err := repo.CountGroupByCategory(ctx, false)
+		checkErr(t, err, 0)
+		all, err := repo.CountGroupByCategory(ctx, true)
+		checkErr(t, err, 0)
+		if !reflect.DeepEqual(active, map[int]int{fastenersID: 1, boltsID: 3}) || !reflect.DeepEqual(all, map[int]int{fastenersID: 1, boltsID: 4}) {
+			t.Errorf("CountGroupByCategory = %v active and %v in total", active, all)
+		}
+
+		variants, err := repo.CountVariants(ctx, ids[1])
+		checkErr(t, err, 0)
+		none, err := repo.CountVariants(ctx, ids[0])
+		checkErr(t, err, 0)
+		if variants != 1 || none != 0 {
+			t.Errorf("CountVariants = %d and %d, want 1 and 0", variants, none)
+		}
+	})
+}
+
+// TestProductDefinitionCopiesIntegration 複製產品時一併複製價格、分級與換算單位；建立變體時既有的 SKU 不重複建立
+func TestProductDefinitionCopiesIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	repo := newTestProductDefinitionRepository(t, database)
+	prices := NewProductPriceRepository(database, logger)
+	units := NewProductUnitRepository(database, logger)
+	actorID := dbtest.SeedAccount(t, database, "copy_admin", dbtest.SeedRole(t, database, "copy_role"))
+	categoryID := dbtest.SeedProductCategory(t, database, "Bolts", nil)
+	sourceID := dbtest.SeedProductDefinition(t, database, "Hex Bolt", "HB", categoryID, "1.0000")
+	noSKUID := dbtest.SeedProductDefinition(t, database, "Carriage Bolt", "", categoryID, "2.0000")
+	checkErr(t, prices.Create(ctx, &models.ProductPrice{ProductID: sourceID, Currency: "USD", Price: decimal.MustParse("0.0300")}), 0)
+	checkErr(t, prices.ReplaceTiers(ctx, sourceID, []models.ProductPriceTier{{MinQty: 100, Price: decimal.MustParse("0.9000")}}), 0)
+	checkErr(t, units.Replace(ctx, sourceID, []models.ProductUnit{{Unit: "box", Factor: 100}}), 0)
+
+	t.Run("Clone", func(t *testing.T) {
+		cloneID, err := repo.Clone(ctx, sourceID, "", "", actorID)
+		checkErr(t, err, 0)
+		clone, err := repo.FindByID(ctx, cloneID)
+		checkErr(t, err, 0)
+		if clone == nil || clone.Name != "Hex Bolt (copy)" || clone.SKU != "HB-1" || clone.CreatedBy == nil || *clone.CreatedBy != actorID {
+			t.Fatalf("clone = %+v, want Hex Bolt (copy) with SKU HB-1 created by %d", clone, actorID)
+		}
+		clonedPrices, err := prices.FindByProductID(ctx, cloneID)
+		checkErr(t, err, 0)
+		clonedTiers, err := prices.FindTiers(ctx, cloneID)
+		checkErr(t, err, 0)
+		clonedUnits, err := units.FindByProductID(ctx, cloneID)
+		checkErr(t, err, 0)
+		if len(clonedPrices) != 1 || len(clonedTiers) != 1 || len(clonedUnits) != 1 {
+			t.Errorf("clone has %d prices, %d tiers and %d units, want 1 of each", len(clonedPrices), len(clonedTiers), len(clonedUnits))
+		}
+
+		_, err = repo.Clone(ctx, sourceID, "", "", actorID) // 名稱 "(copy)" 已存在
+		checkErr(t, err, http.StatusConflict)
+		_, err = repo.Clone(ctx, sourceID, "Hex Bolt Long", "HB-1", actorID)
+		checkErr(t, err, http.StatusConflict)
+		noSKUClone, err := repo.Clone(ctx, noSKUID, "Carriage Bolt Copy", "", actorID)
+		checkErr(t, err, 0)
+		if found, _ := repo.FindByID(ctx, noSKUClone); found == nil || found.SKU != fmt.Sprintf("PD-%d-1", noSKUID) {
+			t.Errorf("clone without source SKU = %+v, want SKU PD-%d-1", found, noSKUID)
+		}
+		_, err = repo.Clone(ctx, sourceID+100, "", "", actorID)
+		checkErr(t, err, http.StatusNotFound)
+	})
+
+	t.Run("CreateVariants", func(t *testing.T) {
+		variants := []models.ProductDefinition{
+			{Name: "Hex Bolt x20", SKU: "HB-20", CategoryID: categoryID, Price: decimal.MustParse("1.1000")},
+			{Name: "Hex Bolt existing", SKU: "HB", CategoryID: categoryID, Price: decimal.MustParse("1.2000")},
+		}
+		existing, err := repo.CreateVariants(ctx, sourceID, variants, actorID)
+		checkErr(t, err, 0)
+		if !reflect.DeepEqual(existing, []int{0, sourceID}) || variants[0].ID == 0 || variants[1].ID != 0 {
+			t.Fatalf("CreateVariants = %v with IDs %d and %d, want [0 %d] and only the first created", existing, variants[0].ID, variants[1].ID, sourceID)
+		}
+		created, err := repo.FindByID(ctx, variants[0].ID)
+		checkErr(t, err, 0)
+		if created == nil || !equalIntPtr(created.ParentID, &sourceID) {
+			t.Errorf("variant = %+v, want parent %d", created, sourceID)
+		}
+
+		// 名稱與既有產品相同時整批回滾
+		_, err = repo.CreateVariants(ctx, sourceID, []models.ProductDefinition{
+			{Name: "Hex Bolt x30", SKU: "HB-30", CategoryID: categoryID},
+			{Name: "Hex Bolt", SKU: "HB-40", CategoryID: categoryID},
+		}, actorID)
+		checkErr(t, err, http.StatusConflict)
+		count, err := repo.CountVariants(ctx, sourceID)
+		checkErr(t, err, 0)
+		if count != 1 {
+			t.Errorf("CountVariants after the failed batch = %d, want 1", count)
+		}
+	})
+
+	t.Run("Image", func(t *testing.T) {
+		old, err := repo.SetImage(ctx, sourceID, "products/1.png", "image/png", actorID)
+		checkErr(t, err, 0)
+		if old != "" {
+			t.Errorf("first SetImage returned %q, want no old key", old)
+		}
+		withImage, err := repo.FindByID(ctx, sourceID)
+		checkErr(t, err, 0)
+		if withImage.ImageKey != "products/1.png" || withImage.ImageContentType != "image/png" || withImage.ImageURL == "" {
+			t.Errorf("after SetImage = %+v", withImage)
+		}
+		old, err = repo.SetImage(ctx, sourceID, "products/2.webp", "image/webp", actorID)
+		checkErr(t, err, 0)
+		if old != "products/1.png" {
+			t.Errorf("second SetImage returned %q, want products/1.png", old)
+		}
+		old, err = repo.ClearImage(ctx, sourceID, actorID)
+		checkErr(t, err, 0)
+		cleared, err := repo.FindByID(ctx, sourceID)
+		checkErr(t, err, 0)
+		if old != "products/2.webp" || cleared.ImageKey != "" || cleared.ImageURL != "" || cleared.ImageUpdatedAt != nil {
+			t.Errorf("ClearImage returned %q, product %+v; want the old key and no image", old, cleared)
+		}
+		_, err = repo.SetImage(ctx, sourceID+100, "x", "image/png", actorID)
+		checkErr(t, err, http.StatusNotFound)
+		_, err = repo.ClearImage(ctx, sourceID+100, actorID)
+		checkErr(t, err, http.StatusNotFound)
+	})
+}
+
+// TestProductDefinitionBatchesIntegration 匯入的每列在各自的 savepoint 中寫入；批次調整價格寫入價格歷史，超出範圍時整批不寫入
+func TestProductDefinitionBatchesIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := newTestProductDefinitionRepository(t, database)
+	histories := NewProductDefinitionHistoryRepository(database, zaptest.NewLogger(t))
+	actorID := dbtest.SeedAccount(t, database, "batch_admin", dbtest.SeedRole(t, database, "batch_role"))
+	boltsID := dbtest.SeedProductCategory(t, database, "Bolts", nil)
+	existingID := dbtest.SeedProductDefinition(t, database, "Hex Bolt", "HB", boltsID, "1.0000")
+
+	rows := []models.ProductDefinitionImportRow{
+		{Line: 2, SKU: "HB", Name: "Hex Bolt Zinc", CategoryID: boltsID, Price: decimal.MustParse("1.1000")},
+		{Line: 3, SKU: "WS", Name: "Washer", CategoryName: "Washers", Price: decimal.MustParse("0.0500")},
+		{Line: 4, SKU: "WS-L", Name: "Washer Large", CategoryName: "washers", Price: decimal.MustParse("0.0800")},
+		{Line: 5, SKU: "DUP", Name: "washer", CategoryName: "Washers", Price: decimal.MustParse("0.0500")}, // 名稱在新類別中重複
+	}
+	actions := func(results []models.ImportRowResult) []string {
+		got := make([]string, len(results))
+		for i, result := range results {
+			got[i] = result.Action
+		}
+		return got
+	}
+	wantActions := []string{models.ImportActionUpdated, models.ImportActionCreated, models.ImportActionCreated, models.ImportActionInvalid}
+	count := func(t *testing.T) int {
+		t.Helper()
+		var n int
+		if err := database.QueryRow(`SELECT COUNT(*) FROM product_definitions`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	t.Run("ImportRollsBack", func(t *testing.T) {
+		for _, mode := range []struct {
+			name            string
+			partial, dryRun bool
+		}{
+			{name: "dry run", partial: true, dryRun: true},
+			{name: "not partial", partial: false},
+		} {
+			results, err := repo.ImportBatch(ctx, rows, actorID, mode.partial, mode.dryRun)
+			checkErr(t, err, 0)
+			if !reflect.DeepEqual(actions(results), wantActions) || results[1].ID != nil {
+				t.Errorf("%s: results = %+v, want %v without IDs of created rows", mode.name, results, wantActions)
+			}
+			if n := count(t); n != 1 {
+				t.Errorf("%s: %d product definitions, want 1 (nothing written)", mode.name, n)
+			}
+		}
+	})
+
+	t.Run("ImportPartial", func(t *testing.T) {
+		results, err := repo.ImportBatch(ctx, rows, actorID, true, false)
+		checkErr(t, err, 0)
+		if !reflect.DeepEqual(actions(results), wantActions) {
+			t.Fatalf("results = %+v, want %v", results, wantActions)
+		}
+		if results[0].ID == nil || *results[0].ID != existingID || results[1].ID == nil || results[2].ID == nil {
+			t.Fatalf("results = %+v, want IDs of the written rows", results)
+		}
+		washer, err := repo.FindByID(ctx, *results[1].ID)
+		checkErr(t, err, 0)
+		large, err := repo.FindByID(ctx, *results[2].ID)
+		checkErr(t, err, 0)
+		if washer.CategoryName != "Washers" || large.CategoryID != washer.CategoryID { // 同名類別只建立一次
+			t.Errorf("imported categories = %d (%s) and %d, want one new Washers category", washer.CategoryID, washer.CategoryName, large.CategoryID)
+		}
+		updated, err := repo.FindByID(ctx, existingID)
+		checkErr(t, err, 0)
+		if updated.Name != "Hex Bolt Zinc" || updated.UpdatedBy == nil || *updated.UpdatedBy != actorID {
+			t.Errorf("updated row = %+v, want the new name updated by %d", updated, actorID)
+		}
+	})
+
+	t.Run("BulkUpdatePrices", func(t *testing.T) {
+		scale := int32(2)
+		filter := models.ProductDefinitionFilter{CategoryID: &boltsID}
+		raise := models.ProductPriceAdjustment{Type: models.PriceAdjustmentPercent, Value: decimal.MustParse("10"), Scale: &scale, Rounding: decimal.RoundHalfUp}
+
+		matched, changes, err := repo.BulkUpdatePrices(ctx, filter, raise, actorID, true)
+		checkErr(t, err, 0)
+		if matched != 1 || len(changes) != 1 || changes[0].NewPrice.String() != "1.2100" {
+			t.Fatalf("dry run = %d matched, %+v; want 1.1000 raised to 1.2100", matched, changes)
+		}
+		if found, _ := repo.FindByID(ctx, existingID); !found.Price.Equal(decimal.MustParse("1.1")) {
+			t.Errorf("price after dry run = %s, want unchanged 1.1000", found.Price)
+		}
+
+		_, _, err = repo.BulkUpdatePrices(ctx, filter, raise, actorID, false)
+		checkErr(t, err, 0)
+		found, err := repo.FindByID(ctx, existingID)
+		checkErr(t, err, 0)
+		if !found.Price.Equal(decimal.MustParse("1.21")) {
+			t.Errorf("price after bulk update = %s, want 1.2100", found.Price)
+		}
+		entries, total, err := histories.FindByProductID(ctx, existingID, "price", utils.Pagination{})
+		checkErr(t, err, 0)
+		if total != 1 || entries[0].Event != models.ProductDefinitionEventBulkPriceUpdated || entries[0].ActorID == nil || *entries[0].ActorID != actorID {
+			t.Errorf("price history = %+v, want one bulk update by %d", entries, actorID)
+		}
+
+		lower := models.ProductPriceAdjustment{Type: models.PriceAdjustmentDelta, Value: decimal.MustParse("-5"), Scale: &scale, Rounding: decimal.RoundHalfUp}
+		_, _, err = repo.BulkUpdatePrices(ctx, filter, lower, actorID, false)
+		checkErr(t, err, http.StatusBadRequest)
+		if found, _ := repo.FindByID(ctx, existingID); !found.Price.Equal(decimal.MustParse("1.21")) {
+			t.Errorf("price after rejected update = %s, want 1.2100", found.Price)
+		}
+	})
+}
+
+// TestProductDefinitionHistoryRepositoryIntegration 分頁查詢由新到舊，FindAllByProductID 由舊到新
+func TestProductDefinitionHistoryRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := newTestProductDefinitionRepository(t, database)
+	histories := NewProductDefinitionHistoryRepository(database, zaptest.NewLogger(t))
+	actorID := dbtest.SeedAccount(t, database, "history_admin", dbtest.SeedRole(t, database, "history_role"))
+	categoryID := dbtest.SeedProductCategory(t, database, "Bolts", nil)
+
+	definition := &models.ProductDefinition{Name: "Hex Bolt", CategoryID: categoryID, Price: decimal.MustParse("1.0000")}
+	checkErr(t, repo.Create(ctx, definition, actorID, []models.ProductDefinitionHistory{{Event: models.ProductDefinitionEventCreated, ActorID: &actorID}}), 0)
+	oldName, newName := "Hex Bolt", "Hex Bolt Zinc"
+	definition.Name = newName
+	checkErr(t, repo.Update(ctx, definition, actorID, []models.ProductDefinitionHistory{
+		{ProductID: definition.ID, Event: models.ProductDefinitionEventUpdated, Field: "name", OldValue: &oldName, NewValue: &newName, ActorID: &actorID},
+		{ProductID: definition.ID, Event: models.ProductDefinitionEventUpdated, Field: "description", NewValue: &newName}, // 系統寫入
+	}), 0)
+
+	tests := []struct {
+		name       string
+		field      string
+		pagination utils.Pagination
+		wantTotal  int
+		wantFields []string
+	}{
+		{name: "all", wantTotal: 3, wantFields: []string{"description", "name", ""}},
+		{name: "by field", field: "name", wantTotal: 1, wantFields: []string{"name"}},
+		{name: "paged", pagination: utils.Pagination{Page: 2, PageSize: 2}, wantTotal: 3, wantFields: []string{""}},
+		{name: "other product", wantTotal: 0, wantFields: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			productID := definition.ID
+			if tt.name == "other product" {
+				productID += 100
+			}
+			entries, total, err := histories.FindByProductID(ctx, productID, tt.field, tt.pagination)
+			checkErr(t, err, 0)
+			fields := make([]string, len(entries))
+			for i, entry := range entries {
+				fields[i] = entry.Field
+			}
+			if total != tt.wantTotal || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("FindByProductID = %v (total %d), want %v (total %d)", fields, total, tt.wantFields, tt.wantTotal)
+			}
+		})
+	}
+
+	t.Run("FindAllByProductID", func(t *testing.T) {
+		entries, err := histories.FindAllByProductID(ctx, definition.ID)
+		checkErr(t, err, 0)
+		if len(entries) != 3 || entries[0].Event != models.ProductDefinitionEventCreated || entries[1].Field != "name" {
+			t.Fatalf("FindAllByProductID = %+v, want created then the two updates", entries)
+		}
+		if entries[1].ActorUsername == nil || *entries[1].ActorUsername != "history_admin" || entries[2].ActorID != nil || entries[2].OldValue != nil {
+			t.Errorf("updates = %+v and %+v, want the actor name on the first and NULL actor and old value on the second", entries[1], entries[2])
+		}
+	})
+}
+
+// TestProductPriceRepositoryIntegration 幣別價格以產品、幣別與生效日唯一，FindEffective 取今天已生效的最新價格；
+// 數量分級整組取代，失敗時保留原本的分級
+func TestProductPriceRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewProductPriceRepository(database, zaptest.NewLogger(t))
+	categoryID := dbtest.SeedProductCategory(t, database, "Bolts", nil)
+	productID := dbtest.SeedProductDefinition(t, database, "Hex Bolt", "", categoryID, "1.0000")
+	otherID := dbtest.SeedProductDefinition(t, database, "Hex Nut", "", categoryID, "0.1000")
+	past := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
+	future := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+
+	current := &models.ProductPrice{ProductID: productID, Currency: "USD", Price: decimal.MustParse("0.0300")}
+	checkErr(t, repo.Create(ctx, current), 0)
+	if current.ValidFrom == "" || current.ValidFrom <= past || current.ValidFrom >= future {
+		t.Fatalf("ValidFrom = %q, want today", current.ValidFrom)
+	}
+	old := &models.ProductPrice{ProductID: productID, Currency: "USD", Price: decimal.MustParse("0.0250"), ValidFrom: past}
+	checkErr(t, repo.Create(ctx, old), 0)
+	upcoming := &models.ProductPrice{ProductID: productID, Currency: "USD", Price: decimal.MustParse("0.0350"), ValidFrom: future}
+	checkErr(t, repo.Create(ctx, upcoming), 0)
+	euro := &models.ProductPrice{ProductID: productID, Currency: "EUR", Price: decimal.MustParse("0.0280"), ValidFrom: past}
+	checkErr(t, repo.Create(ctx, euro), 0)
+
+	t.Run("Create", func(t *testing.T) {
+		err := repo.Create(ctx, &models.ProductPrice{ProductID: productID, Currency: "USD", Price: decimal.MustParse("1"), ValidFrom: past})
+		checkErr(t, err, http.StatusConflict)
+		if details, _ := err.(*utils.CustomError).Details.(map[string]interface{}); details["currency"] != "USD" || details["valid_from"] != past {
+			t.Errorf("conflict details = %v, want the currency and valid_from", details)
+		}
+		checkErr(t, repo.Create(ctx, &models.ProductPrice{ProductID: productID + 100, Currency: "USD", Price: decimal.MustParse("1")}), -1)
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		all, err := repo.FindByProductID(ctx, productID)
+		checkErr(t, err, 0)
+		got := make([]int, len(all))
+		for i, price := range all {
+			got[i] = price.ID
+		}
+		if want := []int{euro.ID, upcoming.ID, current.ID, old.ID}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FindByProductID = %v, want %v (currency, then newest valid_from)", got, want)
+		}
+		found, err := repo.FindByID(ctx, productID, old.ID)
+		checkErr(t, err, 0)
+		if found == nil || found.ValidFrom != past || !found.Price.Equal(old.Price) {
+			t.Errorf("FindByID = %+v, want %+v", found, old)
+		}
+		other, err := repo.FindByID(ctx, otherID, old.ID)
+		checkErr(t, err, 0)
+		if other != nil {
+			t.Errorf("FindByID with another product = %+v, want nil", other)
+		}
+
+		effective, err := repo.FindEffective(ctx, []int{productID, otherID}, "USD")
+		checkErr(t, err, 0)
+		if len(effective) != 1 || effective[productID].ID != current.ID {
+			t.Errorf("FindEffective = %+v, want today's price %d only", effective, current.ID)
+		}
+		none, err := repo.FindEffective(ctx, nil, "USD")
+		checkErr(t, err, 0)
+		if len(none) != 0 {
+			t.Errorf("FindEffective(no IDs) = %+v, want empty", none)
+		}
+	})
+
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		update := *upcoming
+		update.Price = decimal.MustParse("0.0400")
+		update.ValidFrom = "" // 保留原生效日
+		checkErr(t, repo.Update(ctx, &update), 0)
+		if update.ValidFrom != future {
+			t.Errorf("ValidFrom after update = %q, want %q", update.ValidFrom, future)
+		}
+		conflict := *upcoming
+		conflict.ValidFrom = past
+		checkErr(t, repo.Update(ctx, &conflict), http.StatusConflict)
+		missing := *upcoming
+		missing.ProductID = otherID
+		checkErr(t, repo.Update(ctx, &missing), http.StatusNotFound)
+
+		checkErr(t, repo.Delete(ctx, otherID, upcoming.ID), http.StatusNotFound)
+		checkErr(t, repo.Delete(ctx, productID, upcoming.ID), 0)
+		checkErr(t, repo.Delete(ctx, productID, upcoming.ID), http.StatusNotFound)
+	})
+
+	t.Run("Tiers", func(t *testing.T) {
+		tiers := []models.ProductPriceTier{
+			{MinQty: 100, Price: decimal.MustParse("0.9000")},
+			{MinQty: 1000, Price: decimal.MustParse("0.8000")},
+		}
+		checkErr(t, repo.ReplaceTiers(ctx, productID, tiers), 0)
+		// 重複的 min_qty 讓整組取代回滾
+		checkErr(t, repo.ReplaceTiers(ctx, productID, []models.ProductPriceTier{{MinQty: 10, Price: decimal.Zero}, {MinQty: 10, Price: decimal.Zero}}), -1)
+		found, err := repo.FindTiers(ctx, productID)
+		checkErr(t, err, 0)
+		if len(found) != 2 || found[0].MinQty != 100 || found[1].MinQty != 1000 {
+			t.Fatalf("FindTiers = %+v, want the tiers from the successful replace", found)
+		}
+
+		tests := []struct {
+			qty        int
+			wantMinQty int // 0 表示沒有適用的分級
+		}{
+			{qty: 99},
+			{qty: 100, wantMinQty: 100},
+			{qty: 999, wantMinQty: 100},
+			{qty: 5000, wantMinQty: 1000},
+		}
+		for _, tt := range tests {
+			tier, err := repo.FindTierForQuantity(ctx, productID, tt.qty)
+			checkErr(t, err, 0)
+			if (tier == nil && tt.wantMinQty != 0) || (tier != nil && tier.MinQty != tt.wantMinQty) {
+				t.Errorf("FindTierForQuantity(%d) = %+v, want min_qty %d", tt.qty, tier, tt.wantMinQty)
+			}
+		}
+
+		checkErr(t, repo.ReplaceTiers(ctx, productID, nil), 0)
+		if found, err := repo.FindTiers(ctx, productID); err != nil || len(found) != 0 {
+			t.Errorf("FindTiers after clearing = %+v, %v; want none", found, err)
+		}
+	})
+}
+
+// TestProductUnitRepositoryIntegration 換算單位整組取代，失敗時保留原本的單位
+func TestProductUnitRepositoryIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewProductUnitRepository(database, zaptest.NewLogger(t))
+	categoryID := dbtest.SeedProductCategory(t, database, "Bolts", nil)
+	productID := dbtest.SeedProductDefinition(t, database, "Hex Bolt", "", categoryID, "1.0000")
+
+	units := []models.ProductUnit{{Unit: "pcs", Factor: 1}, {Unit: "box", Factor: 100}}
+	checkErr(t, repo.Replace(ctx, productID, units), 0)
+	if units[0].ID == 0 || units[0].ProductID != productID || units[1].CreatedAt.IsZero() {
+		t.Errorf("Replace did not fill the generated columns: %+v", units)
+	}
+
+	tests := []struct {
+		name     string
+		units    []models.ProductUnit
+		wantCode int
+	}{
+		{name: "duplicate unit", units: []models.ProductUnit{{Unit: "kg", Factor: 2}, {Unit: "kg", Factor: 3}}, wantCode: -1},
+		{name: "zero factor", units: []models.ProductUnit{{Unit: "kg", Factor: 0}}, wantCode: -1},
+		{name: "unknown product", units: []models.ProductUnit{{Unit: "kg", Factor: 1}}, wantCode: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := productID
+			if tt.name == "unknown product" {
+				id += 100
+			}
+			checkErr(t, repo.Replace(ctx, id, tt.units), tt.wantCode)
+		})
+	}
+
+	found, err := repo.FindByProductID(ctx, productID)
+	checkErr(t, err, 0)
+	if len(found) != 2 || found[0].Unit != "box" || found[0].Factor != 100 || found[1].Unit != "pcs" {
+		t.Fatalf("FindByProductID = %+v, want box and pcs (ordered by unit) from the successful replace", found)
+	}
+
+	checkErr(t, repo.Replace(ctx, productID, nil), 0)
+	if found, err := repo.FindByProductID(ctx, productID); err != nil || len(found) != 0 {
+		t.Errorf("FindByProductID after clearing = %+v, %v; want none", found, err)
+	}
+}
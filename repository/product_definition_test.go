@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+)
+
+// TestBuildProductDefinitionWhereRanges 價格範圍兩端都包含邊界 (>= 與 <=)，與其他篩選條件以 AND 組合，值一律以參數傳入
+func TestBuildProductDefinitionWhereRanges(t *testing.T) {
+	min, max := decimal.MustParse("1.50"), decimal.MustParse("2")
+	categoryID := 3
+	hasImage := true
+
+	tests := []struct {
+		name     string
+		filter   models.ProductDefinitionFilter
+		wantSQL  []string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "lower bound inclusive",
+			filter:   models.ProductDefinitionFilter{PriceMin: &min},
+			wantSQL:  []string{"pd.price >= $1"},
+			wantArgs: []interface{}{min},
+		},
+		{
+			name:     "upper bound inclusive",
+			filter:   models.ProductDefinitionFilter{PriceMax: &max},
+			wantSQL:  []string{"pd.price <= $1"},
+			wantArgs: []interface{}{max},
+		},
+		{
+			name:     "single price point",
+			filter:   models.ProductDefinitionFilter{PriceMin: &min, PriceMax: &min},
+			wantSQL:  []string{"pd.price >= $1", "pd.price <= $2"},
+			wantArgs: []interface{}{min, min},
+		},
+		{
+			name:     "combined with category, unit and image",
+			filter:   models.ProductDefinitionFilter{CategoryID: &categoryID, PriceMin: &min, PriceMax: &max, Unit: "PCS", HasImage: &hasImage},
+			wantSQL:  []string{"pd.category_id = $1", "pd.price >= $2", "pd.price <= $3", "LOWER(pd.unit) = $4", "pd.image_key IS NOT NULL"},
+			wantArgs: []interface{}{categoryID, min, max, "pcs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := buildProductDefinitionWhere(tt.filter)
+			conditions := strings.Split(strings.TrimPrefix(where, " WHERE "), " AND ")
+			// 第一個條件為排除已停售的產品
+			if want := productDefinitionSoftDelete.active(); conditions[0] != want {
+				t.Errorf("first condition = %q, want %q", conditions[0], want)
+			}
+			if got := conditions[1:]; !reflect.DeepEqual(got, tt.wantSQL) {
+				t.Errorf("conditions = %q, want %q", got, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// productPriceUniqueConstraint 同一產品、幣別與生效日只能有一筆價格的唯一約束名稱
+const productPriceUniqueConstraint = "product_prices_product_currency_valid_from_key"
+
+// ProductPriceRepository 定義產品多幣別價格與數量分級價格的資料庫操作介面
+type ProductPriceRepository interface {
+	Create(ctx context.Context, price *models.ProductPrice) error
+	FindByProductID(ctx context.Context, productID int) ([]models.ProductPrice, error)
+	FindByID(ctx context.Context, productID, id int) (*models.ProductPrice, error)
+	Update(ctx context.Context, price *models.ProductPrice) error
+	Delete(ctx context.Context, productID, id int) error
+	// FindEffective 獲取各產品在該幣別目前生效 (valid_from <= 今天且最新) 的價格，以產品 ID 為鍵
+	FindEffective(ctx context.Context, productIDs []int, currency string) (map[int]models.ProductPrice, error)
+
+	FindTiers(ctx context.Context, productID int) ([]models.ProductPriceTier, error)
+	// ReplaceTiers 在同一事務中刪除舊分級並寫入新分級
+	ReplaceTiers(ctx context.Context, productID int, tiers []models.ProductPriceTier) error
+	// FindTierForQuantity 獲取 min_qty <= qty 中最大的一級，沒有時返回 nil, nil
+	FindTierForQuantity(ctx context.Context, productID, qty int) (*models.ProductPriceTier, error)
+}
+
+// productPriceRepositoryImpl 實現 ProductPriceRepository 介面
+type productPriceRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewProductPriceRepository 創建 ProductPriceRepository 實例
+func NewProductPriceRepository(db *sql.DB, logger *zap.Logger) ProductPriceRepository {
+	return &productPriceRepositoryImpl{db: db, logger: logger}
+}
+
+// productPriceColumns 查詢產品價格時統一使用的欄位順序，需與 scanProductPrice 保持一致
+const productPriceColumns = `id, product_id, currency, price, to_char(valid_from, 'YYYY-MM-DD'), created_at, updated_at`
+
+// scanProductPrice 將一列查詢結果掃描為 ProductPrice
+func scanProductPrice(row rowScanner) (*models.ProductPrice, error) {
+	var price models.ProductPrice
+	if err := row.Scan(&price.ID, &price.ProductID, &price.Currency, &price.Price, &price.ValidFrom, &price.CreatedAt, &price.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// priceConflictError 若 err 為產品、幣別與生效日的唯一約束衝突 (23505)，返回 409 錯誤；否則返回 nil
+func priceConflictError(err error, price *models.ProductPrice) error {
+	if !isUniqueViolation(err, productPriceUniqueConstraint) {
+		return nil
+	}
+	return utils.NewConflictError("Product price already exists for this currency and valid_from", map[string]interface{}{
+		"currency":   price.Currency,
+		"valid_from": price.ValidFrom,
+	})
+}
+
+// Create 創建新產品價格，未指定生效日時為當天
+func (r *productPriceRepositoryImpl) Create(ctx context.Context, price *models.ProductPrice) error {
+	query := `INSERT INTO product_prices (product_id, currency, price, valid_from)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, '')::date, CURRENT_DATE))
+		RETURNING id, to_char(valid_from, 'YYYY-MM-DD'), created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, price.ProductID, price.Currency, price.Price, price.ValidFrom).
+		Scan(&price.ID, &price.ValidFrom, &price.CreatedAt, &price.UpdatedAt)
+	if err != nil {
+		if conflictErr := priceConflictError(err, price); conflictErr != nil {
+			return conflictErr
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create product price", zap.Error(err), zap.Int("product_id", price.ProductID), zap.String("currency", price.Currency))
+		return fmt.Errorf("failed to create product price: %w", err)
+	}
+	return nil
+}
+
+// FindByProductID 獲取產品的所有價格，依幣別與生效日 (新到舊) 排序
+func (r *productPriceRepositoryImpl) FindByProductID(ctx context.Context, productID int) ([]models.ProductPrice, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+productPriceColumns+` FROM product_prices WHERE product_id = $1 ORDER BY currency, valid_from DESC`, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product prices", zap.Error(err), zap.Int("product_id", productID))
+		return nil, fmt.Errorf("failed to get product prices for product %d: %w", productID, err)
+	}
+	defer rows.Close()
+
+	prices := []models.ProductPrice{}
+	for rows.Next() {
+		price, err := scanProductPrice(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product price data", zap.Int("row", len(prices)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan product price data at row %d: %w", len(prices)+1, err)
+		}
+		prices = append(prices, *price)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product price data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating product price data: %w", err)
+	}
+	return prices, nil
+}
+
+// FindByID 根據 ID 獲取產品價格，價格不屬於該產品時視為未找到
+func (r *productPriceRepositoryImpl) FindByID(ctx context.Context, productID, id int) (*models.ProductPrice, error) {
+	price, err := scanProductPrice(r.db.QueryRowContext(ctx, `SELECT `+productPriceColumns+` FROM product_prices WHERE id = $1 AND product_id = $2`, id, productID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product price by ID", zap.Int("id", id), zap.Int("product_id", productID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get product price by ID %d: %w", id, err)
+	}
+	return price, nil
+}
+
+// Update 更新產品價格，未指定生效日時保留原值
+func (r *productPriceRepositoryImpl) Update(ctx context.Context, price *models.ProductPrice) error {
+	query := `UPDATE product_prices SET currency = $1, price = $2, valid_from = COALESCE(NULLIF($3, '')::date, valid_from), updated_at = NOW()
+		WHERE id = $4 AND product_id = $5
+		RETURNING to_char(valid_from, 'YYYY-MM-DD'), created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, price.Currency, price.Price, price.ValidFrom, price.ID, price.ProductID).
+		Scan(&price.ValidFrom, &price.CreatedAt, &price.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.ErrNotFound // 未找到要更新的記錄
+		}
+		if conflictErr := priceConflictError(err, price); conflictErr != nil {
+			return conflictErr
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update product price", zap.Error(err), zap.Int("id", price.ID))
+		return fmt.Errorf("failed to update product price %d: %w", price.ID, err)
+	}
+	return nil
+}
+
+// Delete 刪除產品價格
+func (r *productPriceRepositoryImpl) Delete(ctx context.Context, productID, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM product_prices WHERE id = $1 AND product_id = $2`, id, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete product price", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to delete product price %d: %w", id, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
+		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		return utils.ErrNotFound // 未找到要刪除的記錄
+	}
+	return nil
+}
+
+// FindEffective 獲取各產品在該幣別目前生效的價格，沒有有效價格的產品不會出現在結果中
+func (r *productPriceRepositoryImpl) FindEffective(ctx context.Context, productIDs []int, currency string) (map[int]models.ProductPrice, error) {
+	prices := make(map[int]models.ProductPrice, len(productIDs))
+	if len(productIDs) == 0 {
+		return prices, nil
+	}
+
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+	query := `SELECT DISTINCT ON (product_id) ` + productPriceColumns + ` FROM product_prices
+		WHERE product_id = ANY($1) AND currency = $2 AND valid_from <= CURRENT_DATE
+		ORDER BY product_id, valid_from DESC`
+	rows, err := r.db.QueryContext(ctx, query, ids, currency)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get effective product prices", zap.Error(err), zap.String("currency", currency))
+		return nil, fmt.Errorf("failed to get effective product prices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		price, err := scanProductPrice(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product price data", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan product price data: %w", err)
+		}
+		prices[price.ProductID] = *price
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product price data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating product price data: %w", err)
+	}
+	return prices, nil
+}
+
+// productPriceTierColumns 查詢數量分級價格時統一使用的欄位順序，需與 scanProductPriceTier 保持一致
+const productPriceTierColumns = `id, product_id, min_qty, price, created_at`
+
+// scanProductPriceTier 將一列查詢結果掃描為 ProductPriceTier
+func scanProductPriceTier(row rowScanner) (*models.ProductPriceTier, error) {
+	var tier models.ProductPriceTier
+	if err := row.Scan(&tier.ID, &tier.ProductID, &tier.MinQty, &tier.Price, &tier.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &tier, nil
+}
+
+// FindTiers 獲取產品的數量分級價格，依 min_qty 升序
+func (r *productPriceRepositoryImpl) FindTiers(ctx context.Context, productID int) ([]models.ProductPriceTier, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+productPriceTierColumns+` FROM product_price_tiers WHERE product_id = $1 ORDER BY min_qty`, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product price tiers", zap.Error(err), zap.Int("product_id", productID))
+		return nil, fmt.Errorf("failed to get product price tiers for product %d: %w", productID, err)
+	}
+	defer rows.Close()
+
+	tiers := []models.ProductPriceTier{}
+	for rows.Next() {
+		tier, err := scanProductPriceTier(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product price tier data", zap.Int("row", len(tiers)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan product price tier data at row %d: %w", len(tiers)+1, err)
+		}
+		tiers = append(tiers, *tier)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product price tier data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating product price tier data: %w", err)
+	}
+	return tiers, nil
+}
+
+// ReplaceTiers 以 tiers 整組取代產品的數量分級價格，刪除與寫入在同一事務中完成
+// 寫入後回填每一級的 ID、ProductID 與 CreatedAt
+func (r *productPriceRepositoryImpl) ReplaceTiers(ctx context.Context, productID int, tiers []models.ProductPriceTier) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for price tier replace", zap.Error(err), zap.Int("product_id", productID))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_price_tiers WHERE product_id = $1`, productID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete product price tiers", zap.Error(err), zap.Int("product_id", productID))
+		return fmt.Errorf("failed to delete product price tiers for product %d: %w", productID, err)
+	}
+	for i := range tiers {
+		tier := &tiers[i]
+		tier.ProductID = productID
+		err := tx.QueryRowContext(ctx, `INSERT INTO product_price_tiers (product_id, min_qty, price) VALUES ($1, $2, $3) RETURNING id, created_at`,
+			productID, tier.MinQty, tier.Price).Scan(&tier.ID, &tier.CreatedAt)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to insert product price tier", zap.Error(err), zap.Int("product_id", productID), zap.Int("min_qty", tier.MinQty))
+			return fmt.Errorf("failed to insert product price tier (min_qty %d): %w", tier.MinQty, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit price tier replace", zap.Error(err), zap.Int("product_id", productID))
+		return fmt.Errorf("failed to commit price tier replace: %w", err)
+	}
+	return nil
+}
+
+// FindTierForQuantity 獲取購買數量 qty 適用的分級 (min_qty <= qty 中最大的一級)，沒有適用的分級時返回 nil, nil
+func (r *productPriceRepositoryImpl) FindTierForQuantity(ctx context.Context, productID, qty int) (*models.ProductPriceTier, error) {
+	query := `SELECT ` + productPriceTierColumns + ` FROM product_price_tiers WHERE product_id = $1 AND min_qty <= $2 ORDER BY min_qty DESC LIMIT 1`
+	tier, err := scanProductPriceTier(r.db.QueryRowContext(ctx, query, productID, qty))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 沒有適用的分級
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product price tier for quantity", zap.Error(err), zap.Int("product_id", productID), zap.Int("qty", qty))
+		return nil, fmt.Errorf("failed to get product price tier for quantity %d: %w", qty, err)
+	}
+	return tier, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// ProductUnitRepository 定義產品換算單位的資料庫操作介面
+type ProductUnitRepository interface {
+	FindByProductID(ctx context.Context, productID int) ([]models.ProductUnit, error)
+	// Replace 在同一事務中刪除產品的舊換算單位並寫入 units
+	Replace(ctx context.Context, productID int, units []models.ProductUnit) error
+}
+
+// productUnitRepositoryImpl 實現 ProductUnitRepository 介面
+type productUnitRepositoryImpl struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewProductUnitRepository 創建 ProductUnitRepository 實例
+func NewProductUnitRepository(db *sql.DB, logger *zap.Logger) ProductUnitRepository {
+	return &productUnitRepositoryImpl{db: db, logger: logger}
+}
+
+// FindByProductID 獲取產品的所有換算單位，依單位名稱排序
+func (r *productUnitRepositoryImpl) FindByProductID(ctx context.Context, productID int) ([]models.ProductUnit, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, product_id, unit, factor, created_at FROM product_units WHERE product_id = $1 ORDER BY unit`, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get product units", zap.Error(err), zap.Int("product_id", productID))
+		return nil, fmt.Errorf("failed to get product units for product %d: %w", productID, err)
+	}
+	defer rows.Close()
+
+	units := []models.ProductUnit{}
+	for rows.Next() {
+		var unit models.ProductUnit
+		if err := rows.Scan(&unit.ID, &unit.ProductID, &unit.Unit, &unit.Factor, &unit.CreatedAt); err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan product unit data", zap.Int("row", len(units)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan product unit data at row %d: %w", len(units)+1, err)
+		}
+		units = append(units, unit)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating product unit data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating product unit data: %w", err)
+	}
+	return units, nil
+}
+
+// Replace 以 units 整組取代產品的換算單位，刪除與寫入在同一事務中完成
+// 寫入後回填每個單位的 ID、ProductID 與 CreatedAt
+func (r *productUnitRepositoryImpl) Replace(ctx context.Context, productID int, units []models.ProductUnit) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for product unit replace", zap.Error(err), zap.Int("product_id", productID))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Commit 成功後 Rollback 不會有作用
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_units WHERE product_id = $1`, productID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete product units", zap.Error(err), zap.Int("product_id", productID))
+		return fmt.Errorf("failed to delete product units for product %d: %w", productID, err)
+	}
+	for i := range units {
+		unit := &units[i]
+		unit.ProductID = productID
+		err := tx.QueryRowContext(ctx, `INSERT INTO product_units (product_id, unit, factor) VALUES ($1, $2, $3) RETURNING id, created_at`,
+			productID, unit.Unit, unit.Factor).Scan(&unit.ID, &unit.CreatedAt)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to insert product unit", zap.Error(err), zap.Int("product_id", productID), zap.String("unit", unit.Unit))
+			return fmt.Errorf("failed to insert product unit %s: %w", unit.Unit, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to commit product unit replace", zap.Error(err), zap.Int("product_id", productID))
+		return fmt.Errorf("failed to commit product unit replace: %w", err)
+	}
+	return nil
+}
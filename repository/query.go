@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// likeEscaper 跳脫 LIKE/ILIKE 模式中的萬用字元，讓使用者輸入只做字面比對
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern 將搜尋字串轉為 "包含" 比對用的 ILIKE 模式
+func containsPattern(q string) string {
+	return "%" + likeEscaper.Replace(q) + "%"
+}
+
+// buildOrderBy 依白名單將 sort 參數轉為 ORDER BY 子句 (不含關鍵字)
+// sort 前綴 "-" 表示降序；總是以 tiebreaker 升序收尾，確保分頁順序穩定
+func buildOrderBy(sort string, columns map[string]string, tiebreaker string) (string, error) {
+	if sort == "" {
+		return tiebreaker + " ASC", nil
+	}
+	direction := "ASC"
+	field := sort
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+		field = strings.TrimPrefix(sort, "-")
+	}
+	column, ok := columns[field]
+	if !ok {
+		return "", utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid sort field: %s", field))
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, %s ASC", column, direction, tiebreaker), nil
+}
+
+// pageClause 返回 ORDER BY 子句與分頁的 LIMIT/OFFSET，LIMIT 與 OFFSET 以參數傳入並附加到 args
+// pagination.PageSize 為 0 時不分頁
+func pageClause(orderBy string, pagination utils.Pagination, args []interface{}) (string, []interface{}) {
+	clause := " ORDER BY " + orderBy
+	if pagination.PageSize > 0 {
+		args = append(args, pagination.Limit(), pagination.Offset())
+		clause += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	return clause, args
+}
+
+// HasExtension 檢查資料庫是否已安裝指定的擴充套件 (例如 "pg_trgm")，供啟動時決定查詢策略
+// 查詢失敗時視為未安裝
+func HasExtension(db *sql.DB, logger *zap.Logger, name string) bool {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = $1)`, name).Scan(&exists); err != nil {
+		logger.Warn("Repository: Failed to detect database extension", zap.String("extension", name), zap.Error(err))
+		return false
+	}
+	return exists
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// TestPageClause LIMIT 與 OFFSET 以參數傳入，佔位符編號接在既有的篩選參數之後
+func TestPageClause(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination utils.Pagination
+		args       []interface{}
+		wantClause string
+		wantArgs   []interface{}
+	}{
+		{
+			name:       "first page",
+			pagination: utils.Pagination{Page: 1, PageSize: 20},
+			wantClause: " ORDER BY c.name, c.id LIMIT $1 OFFSET $2",
+			wantArgs:   []interface{}{20, 0},
+		},
+		{
+			name:       "later page after filter args",
+			pagination: utils.Pagination{Page: 3, PageSize: 50},
+			args:       []interface{}{"%acme%", 7},
+			wantClause: " ORDER BY c.name, c.id LIMIT $3 OFFSET $4",
+			wantArgs:   []interface{}{"%acme%", 7, 50, 100},
+		},
+		{
+			name:       "max page size",
+			pagination: utils.Pagination{Page: 2, PageSize: 100},
+			wantClause: " ORDER BY c.name, c.id LIMIT $1 OFFSET $2",
+			wantArgs:   []interface{}{100, 100},
+		},
+		{
+			name:       "page below one starts at the first row",
+			pagination: utils.Pagination{Page: 0, PageSize: 20},
+			wantClause: " ORDER BY c.name, c.id LIMIT $1 OFFSET $2",
+			wantArgs:   []interface{}{20, 0},
+		},
+		{
+			name:       "unpaginated",
+			pagination: utils.Pagination{Page: 1},
+			args:       []interface{}{"%acme%"},
+			wantClause: " ORDER BY c.name, c.id",
+			wantArgs:   []interface{}{"%acme%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, args := pageClause("c.name, c.id", tt.pagination, tt.args)
+			if clause != tt.wantClause || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("pageClause = %q %v, want %q %v", clause, args, tt.wantClause, tt.wantArgs)
+			}
+		})
+	}
+}
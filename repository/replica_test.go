@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/db"
+)
+
+// TestReadConnRouting 以兩個模擬的資料庫分別作為主庫與唯讀副本：純讀取送到副本，寫入、事務中的讀取與以 db.WithPrimary 標記的讀取
+// (寫入請求中的讀取，避免副本延遲) 使用主庫；沒有副本時全部使用主庫。未預期的查詢會讓 sqlmock 返回錯誤
+func TestReadConnRouting(t *testing.T) {
+	const selectCustomer = `SELECT cu\.id, .* FROM customers cu`
+	tests := []struct {
+		name       string
+		noReplica  bool
+		expectOnly func(writer, reader sqlmock.Sqlmock) // 預期的語句；另一個資料庫不應收到任何語句
+		run        func(ctx context.Context, repo CustomerRepository, txManager db.TxManager) error
+	}{
+		{
+			name: "read goes to the replica",
+			expectOnly: func(writer, reader sqlmock.Sqlmock) {
+				reader.ExpectQuery(selectCustomer).WithArgs(7, false).WillReturnRows(customerRows(1))
+			},
+			run: func(ctx context.Context, repo CustomerRepository, _ db.TxManager) error {
+				return findCustomer(ctx, repo)
+			},
+		},
+		{
+			name: "write goes to the primary",
+			expectOnly: func(writer, reader sqlmock.Sqlmock) {
+				writer.ExpectBegin()
+				writer.ExpectExec(`UPDATE customers SET deleted_at = NOW\(\)`).WithArgs(7, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+				writer.ExpectCommit()
+			},
+			run: func(ctx context.Context, repo CustomerRepository, _ db.TxManager) error {
+				return repo.Delete(ctx, 7, 3, nil)
+			},
+		},
+		{
+			name: "read in a transaction uses the transaction",
+			expectOnly: func(writer, reader sqlmock.Sqlmock) {
+				writer.ExpectBegin()
+				writer.ExpectQuery(selectCustomer).WithArgs(7, false).WillReturnRows(customerRows(1))
+				writer.ExpectCommit()
+			},
+			run: func(ctx context.Context, repo CustomerRepository, txManager db.TxManager) error {
+				return txManager.WithinTx(ctx, func(ctx context.Context) error {
+					return findCustomer(ctx, repo)
+				})
+			},
+		},
+		{
+			name: "read marked with WithPrimary opts out of replica lag",
+			expectOnly: func(writer, reader sqlmock.Sqlmock) {
+				writer.ExpectQuery(selectCustomer).WithArgs(7, false).WillReturnRows(customerRows(1))
+			},
+			run: func(ctx context.Context, repo CustomerRepository, _ db.TxManager) error {
+				return findCustomer(db.WithPrimary(ctx), repo)
+			},
+		},
+		{
+			name:      "no replica configured",
+			noReplica: true,
+			expectOnly: func(writer, reader sqlmock.Sqlmock) {
+				writer.ExpectQuery(selectCustomer).WithArgs(7, false).WillReturnRows(customerRows(1))
+			},
+			run: func(ctx context.Context, repo CustomerRepository, _ db.TxManager) error {
+				return findCustomer(ctx, repo)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, writerMock := newMockDB(t)
+			reader, readerMock := newMockDB(t)
+			tt.expectOnly(writerMock, readerMock)
+			var replica *sql.DB
+			if !tt.noReplica {
+				replica = reader
+			}
+
+			repo := NewCustomerRepository(writer, replica, zap.NewNop())
+			if err := tt.run(context.Background(), repo, db.NewTxManager(writer, db.RetryConfig{})); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// findCustomer 讀取 ID 為 7 的客戶，確認查詢有結果
+func findCustomer(ctx context.Context, repo CustomerRepository) error {
+	customer, err := repo.FindByID(ctx, 7)
+	if err == nil && customer == nil {
+		return sql.ErrNoRows
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -12,35 +13,54 @@ import (
 )
 
 // RoleRepository 定義角色資料庫操作介面
+// 以 db.TxManager.WithinTx 的 ctx 呼叫時，查詢在該事務中執行
 type RoleRepository interface {
-	Create(role *models.Role) error
-	FindAll() ([]models.Role, error)
-	FindByID(id int) (*models.Role, error)
-	FindByName(name string) (*models.Role, error) // 根據名稱查找角色
-	Update(role *models.Role) error
-	Delete(id int) error
+	Create(ctx context.Context, role *models.Role, actorID int) error // actorID 為執行建立的帳戶，記錄在 created_by/updated_by (0 表示系統寫入，記錄為 NULL)
+	FindAll(ctx context.Context) ([]models.Role, error)
+	FindByID(ctx context.Context, id int) (*models.Role, error)
+	FindByPublicID(ctx context.Context, publicID string) (*models.Role, error) // 以對外的 UUID 識別碼查詢
+	FindByName(ctx context.Context, name string) (*models.Role, error)         // 根據名稱查找角色
+	Update(ctx context.Context, role *models.Role, actorID int) error
+	Delete(ctx context.Context, id int) error
+}
+
+// roleColumns 查詢角色時統一使用的欄位順序，需與 scanRole 保持一致 (別名 r，搭配 roleFrom)
+const roleColumns = `r.id, r.public_id, r.name, r.created_at, r.updated_at, r.created_by, cb.username, r.updated_by, ub.username`
+
+// roleFrom 查詢角色的 FROM 子句，JOIN 建立者與最後修改者的帳戶以取得用戶名
+const roleFrom = ` FROM roles r LEFT JOIN accounts cb ON cb.id = r.created_by LEFT JOIN accounts ub ON ub.id = r.updated_by`
+
+// scanRole 將一列查詢結果掃描為 Role
+func scanRole(row rowScanner) (*models.Role, error) {
+	var role models.Role
+	dest := []interface{}{&role.ID, &role.PublicID, &role.Name, &role.CreatedAt, &role.UpdatedAt}
+	if err := row.Scan(append(dest, actorDest(&role.RecordActors)...)...); err != nil {
+		return nil, err
+	}
+	return &role, nil
 }
 
 // roleRepositoryImpl 實現 RoleRepository 介面
 type roleRepositoryImpl struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *zap.Logger
 }
 
 // NewRoleRepository 創建 RoleRepository 實例
-func NewRoleRepository(db *sql.DB) RoleRepository {
-	return &roleRepositoryImpl{db: db}
+func NewRoleRepository(db *sql.DB, logger *zap.Logger) RoleRepository {
+	return &roleRepositoryImpl{db: db, logger: logger}
 }
 
-// Create 創建新角色
-func (r *roleRepositoryImpl) Create(role *models.Role) error {
-	query := `INSERT INTO roles (name) VALUES ($1) RETURNING id, created_at, updated_at`
-	err := r.db.QueryRow(query, role.Name).
-		Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
+// Create 創建新角色，建立者與最後修改者為 actorID
+func (r *roleRepositoryImpl) Create(ctx context.Context, role *models.Role, actorID int) error {
+	query := `INSERT INTO roles (name, created_by, updated_by) VALUES ($1, NULLIF($2, 0), NULLIF($2, 0)) RETURNING id, public_id, created_at, updated_at, ` + actorReturning("roles")
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, role.Name, actorID).
+		Scan(append([]interface{}{&role.ID, &role.PublicID, &role.CreatedAt, &role.UpdatedAt}, actorDest(&role.RecordActors)...)...)
 	if err != nil {
-		zap.L().Error("Repository: Failed to create role", zap.Error(err), zap.String("name", role.Name))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create role", zap.Error(err), zap.String("name", role.Name))
 		// 檢查是否是唯一約束衝突錯誤
-		if err.Error() == `pq: duplicate key value violates unique constraint "roles_name_key"` {
-			return utils.ErrBadRequest.SetDetails("Role name already exists")
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
 		}
 		return fmt.Errorf("failed to create role: %w", err)
 	}
@@ -48,69 +68,86 @@ func (r *roleRepositoryImpl) Create(role *models.Role) error {
 }
 
 // FindAll 獲取所有角色
-func (r *roleRepositoryImpl) FindAll() ([]models.Role, error) {
-	query := `SELECT id, name, created_at, updated_at FROM roles`
-	rows, err := r.db.Query(query)
+func (r *roleRepositoryImpl) FindAll(ctx context.Context) ([]models.Role, error) {
+	query := `SELECT ` + roleColumns + roleFrom
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get all roles", zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all roles", zap.Error(err))
 		return nil, fmt.Errorf("failed to get all roles: %w", err)
 	}
 	defer rows.Close()
 
 	roles := []models.Role{}
 	for rows.Next() {
-		var role models.Role
-		if err := rows.Scan(&role.ID, &role.Name, &role.CreatedAt, &role.UpdatedAt); err != nil {
-			zap.L().Error("Repository: Failed to scan role data", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan role data: %w", err)
+		role, err := scanRole(rows)
+		if err != nil {
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan role data", zap.Int("row", len(roles)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan role data at row %d: %w", len(roles)+1, err)
 		}
-		roles = append(roles, role)
+		roles = append(roles, *role)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating role data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating role data: %w", err)
 	}
 	return roles, nil
 }
 
 // FindByID 根據 ID 獲取角色
-func (r *roleRepositoryImpl) FindByID(id int) (*models.Role, error) {
-	query := `SELECT id, name, created_at, updated_at FROM roles WHERE id = $1`
-	row := r.db.QueryRow(query, id)
-	var role models.Role
-	if err := row.Scan(&role.ID, &role.Name, &role.CreatedAt, &role.UpdatedAt); err != nil {
+func (r *roleRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Role, error) {
+	query := `SELECT ` + roleColumns + roleFrom + ` WHERE r.id = $1`
+	role, err := scanRole(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // 未找到
 		}
-		zap.L().Error("Repository: Failed to get role by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get role by ID", zap.Int("id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to get role by ID %d: %w", id, err)
 	}
-	return &role, nil
+	return role, nil
+}
+
+// FindByPublicID 根據對外的 UUID 識別碼獲取角色
+func (r *roleRepositoryImpl) FindByPublicID(ctx context.Context, publicID string) (*models.Role, error) {
+	query := `SELECT ` + roleColumns + roleFrom + ` WHERE r.public_id = $1`
+	role, err := scanRole(conn(ctx, r.db).QueryRowContext(ctx, query, publicID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 未找到
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get role by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get role by public ID %s: %w", publicID, err)
+	}
+	return role, nil
 }
 
 // FindByName 根據名稱獲取角色
-func (r *roleRepositoryImpl) FindByName(name string) (*models.Role, error) {
-	query := `SELECT id, name, created_at, updated_at FROM roles WHERE name = $1`
-	row := r.db.QueryRow(query, name)
-	var role models.Role
-	if err := row.Scan(&role.ID, &role.Name, &role.CreatedAt, &role.UpdatedAt); err != nil {
+func (r *roleRepositoryImpl) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	query := `SELECT ` + roleColumns + roleFrom + ` WHERE r.name = $1`
+	role, err := scanRole(conn(ctx, r.db).QueryRowContext(ctx, query, name))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // 未找到
 		}
-		zap.L().Error("Repository: Failed to get role by name", zap.String("name", name), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get role by name", zap.String("name", name), zap.Error(err))
 		return nil, fmt.Errorf("failed to get role by name %s: %w", name, err)
 	}
-	return &role, nil
+	return role, nil
 }
 
-// Update 更新角色信息
-func (r *roleRepositoryImpl) Update(role *models.Role) error {
-	query := `UPDATE roles SET name = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`
-	err := r.db.QueryRow(query, role.Name, role.ID).Scan(&role.UpdatedAt)
+// Update 更新角色信息，最後修改者為 actorID
+func (r *roleRepositoryImpl) Update(ctx context.Context, role *models.Role, actorID int) error {
+	query := `UPDATE roles SET name = $1, updated_by = NULLIF($3, 0), updated_at = NOW() WHERE id = $2 RETURNING created_at, updated_at, ` + actorReturning("roles")
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, role.Name, role.ID, actorID).
+		Scan(append([]interface{}{&role.CreatedAt, &role.UpdatedAt}, actorDest(&role.RecordActors)...)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return utils.ErrNotFound // 未找到要更新的記錄
 		}
-		zap.L().Error("Repository: Failed to update role", zap.Error(err), zap.Int("id", role.ID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to update role", zap.Error(err), zap.Int("id", role.ID))
 		// 檢查是否是唯一約束衝突錯誤
-		if err.Error() == `pq: duplicate key value violates unique constraint "roles_name_key"` {
-			return utils.ErrBadRequest.SetDetails("Role name already exists")
+		if conflictErr := uniqueConflictError(err); conflictErr != nil {
+			return conflictErr
 		}
 		return fmt.Errorf("failed to update role %d: %w", role.ID, err)
 	}
@@ -118,16 +155,19 @@ func (r *roleRepositoryImpl) Update(role *models.Role) error {
 }
 
 // Delete 刪除角色
-func (r *roleRepositoryImpl) Delete(id int) error {
+func (r *roleRepositoryImpl) Delete(ctx context.Context, id int) error {
 	query := `DELETE FROM roles WHERE id = $1`
-	res, err := r.db.Exec(query, id)
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	if err != nil {
-		zap.L().Error("Repository: Failed to delete role", zap.Error(err), zap.Int("id", id))
+		if conflictErr := referencedConflictError(err); conflictErr != nil {
+			return conflictErr // 仍被其他記錄引用 (ON DELETE RESTRICT)
+		}
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete role", zap.Error(err), zap.Int("id", id))
 		return fmt.Errorf("failed to delete role %d: %w", id, err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("id", id))
 		return fmt.Errorf("failed to check delete rows affected %d: %w", id, err)
 	}
 	if rowsAffected == 0 {
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -12,37 +13,57 @@ import (
 )
 
 // RoleMenuRepository 定義角色選單資料庫操作介面
+// 以 db.TxManager.WithinTx 的 ctx 呼叫時，查詢在該事務中執行
 type RoleMenuRepository interface {
-	Create(roleMenu *models.RoleMenu) error
-	FindAll(roleID, menuID *int) ([]models.RoleMenuDetail, error) // 允許按角色或選單ID過濾
-	Delete(roleID, menuID int) error
-	Update(oldRoleID, oldMenuID, newRoleID, newMenuID int) error // 由於複合主鍵，更新是特殊操作
-	FindMenusByRoleID(roleID int) ([]models.Menu, error) // 新增：根據角色ID獲取所有選單
+	Create(ctx context.Context, roleMenu *models.RoleMenu) error
+	CreateBatch(ctx context.Context, roleMenus []models.RoleMenu) (int, error)         // 以多列 INSERT 批次建立關聯，已存在的略過，返回新增的筆數
+	FindAll(ctx context.Context, roleID, menuID *int) ([]models.RoleMenuDetail, error) // 允許按角色或選單ID過濾
+	Delete(ctx context.Context, roleID, menuID int) error
+	Update(ctx context.Context, oldRoleID, oldMenuID, newRoleID, newMenuID int) error // 由於複合主鍵，更新是特殊操作
+	FindMenusByRoleID(ctx context.Context, roleID int) ([]models.Menu, error)         // 新增：根據角色ID獲取所有選單
+	DeleteByRoleID(ctx context.Context, roleID int) error                             // 刪除角色的所有選單關聯
+	CopyRoleMenus(ctx context.Context, fromRoleID, toRoleID int) error                // 將 fromRoleID 的選單關聯複製給 toRoleID
 }
 
 // roleMenuRepositoryImpl 實現 RoleMenuRepository 介面
 type roleMenuRepositoryImpl struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *zap.Logger
 }
 
 // NewRoleMenuRepository 創建 RoleMenuRepository 實例
-func NewRoleMenuRepository(db *sql.DB) RoleMenuRepository {
-	return &roleMenuRepositoryImpl{db: db}
+func NewRoleMenuRepository(db *sql.DB, logger *zap.Logger) RoleMenuRepository {
+	return &roleMenuRepositoryImpl{db: db, logger: logger}
 }
 
 // Create 創建新的角色選單關聯
-func (r *roleMenuRepositoryImpl) Create(roleMenu *models.RoleMenu) error {
+func (r *roleMenuRepositoryImpl) Create(ctx context.Context, roleMenu *models.RoleMenu) error {
 	query := `INSERT INTO role_menus (role_id, menu_id) VALUES ($1, $2) ON CONFLICT (role_id, menu_id) DO NOTHING`
-	_, err := r.db.Exec(query, roleMenu.RoleID, roleMenu.MenuID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, roleMenu.RoleID, roleMenu.MenuID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to create role menu", zap.Error(err), zap.Int("role_id", roleMenu.RoleID), zap.Int("menu_id", roleMenu.MenuID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create role menu", zap.Error(err), zap.Int("role_id", roleMenu.RoleID), zap.Int("menu_id", roleMenu.MenuID))
 		return fmt.Errorf("failed to create role menu: %w", err)
 	}
 	return nil
 }
 
+// CreateBatch 以多列 INSERT 批次建立角色選單關聯 (分批以符合參數上限，見 insertIgnoreBatch)，已存在的關聯略過
+// 分成多個語句時需要以 db.TxManager.WithinTx 的 ctx 呼叫，才能在失敗時回滾已寫入的批次
+func (r *roleMenuRepositoryImpl) CreateBatch(ctx context.Context, roleMenus []models.RoleMenu) (int, error) {
+	rows := make([][]interface{}, len(roleMenus))
+	for i, roleMenu := range roleMenus {
+		rows[i] = []interface{}{roleMenu.RoleID, roleMenu.MenuID}
+	}
+	created, err := insertIgnoreBatch(ctx, conn(ctx, r.db), "role_menus", []string{"role_id", "menu_id"}, "role_id, menu_id", rows)
+	if err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to batch create role menus", zap.Error(err), zap.Int("count", len(roleMenus)))
+		return created, fmt.Errorf("failed to batch create role menus: %w", err)
+	}
+	return created, nil
+}
+
 // FindAll 獲取所有角色選單關聯，並帶上詳細資訊
-func (r *roleMenuRepositoryImpl) FindAll(roleIDFilter, menuIDFilter *int) ([]models.RoleMenuDetail, error) {
+func (r *roleMenuRepositoryImpl) FindAll(ctx context.Context, roleIDFilter, menuIDFilter *int) ([]models.RoleMenuDetail, error) {
 	query := `SELECT rm.role_id, r.name AS role_name, rm.menu_id, m.name AS menu_name, m.path AS menu_path
               FROM role_menus rm
               JOIN roles r ON rm.role_id = r.id
@@ -63,9 +84,9 @@ func (r *roleMenuRepositoryImpl) FindAll(roleIDFilter, menuIDFilter *int) ([]mod
 		argCounter++
 	}
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get all role menus", zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get all role menus", zap.Error(err))
 		return nil, fmt.Errorf("failed to get all role menus: %w", err)
 	}
 	defer rows.Close()
@@ -74,25 +95,29 @@ func (r *roleMenuRepositoryImpl) FindAll(roleIDFilter, menuIDFilter *int) ([]mod
 	for rows.Next() {
 		var rm models.RoleMenuDetail
 		if err := rows.Scan(&rm.RoleID, &rm.RoleName, &rm.MenuID, &rm.MenuName, &rm.MenuPath); err != nil {
-			zap.L().Error("Repository: Failed to scan role menu data", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan role menu data: %w", err)
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan role menu data", zap.Int("row", len(roleMenus)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan role menu data at row %d: %w", len(roleMenus)+1, err)
 		}
 		roleMenus = append(roleMenus, rm)
 	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating role menu data", zap.Error(err))
+		return nil, fmt.Errorf("error iterating role menu data: %w", err)
+	}
 	return roleMenus, nil
 }
 
 // Delete 刪除角色選單關聯
-func (r *roleMenuRepositoryImpl) Delete(roleID, menuID int) error {
+func (r *roleMenuRepositoryImpl) Delete(ctx context.Context, roleID, menuID int) error {
 	query := `DELETE FROM role_menus WHERE role_id = $1 AND menu_id = $2`
-	res, err := r.db.Exec(query, roleID, menuID)
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, roleID, menuID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to delete role menu", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete role menu", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
 		return fmt.Errorf("failed to delete role menu %d-%d: %w", roleID, menuID, err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
 		return fmt.Errorf("failed to check delete rows affected %d-%d: %w", roleID, menuID, err)
 	}
 	if rowsAffected == 0 {
@@ -103,25 +128,25 @@ func (r *roleMenuRepositoryImpl) Delete(roleID, menuID int) error {
 
 // Update 更新角色選單關聯
 // 由於複合主鍵，這實際上是先刪除舊關聯，再創建新關聯。
-func (r *roleMenuRepositoryImpl) Update(oldRoleID, oldMenuID, newRoleID, newMenuID int) error {
-	tx, err := r.db.Begin()
+func (r *roleMenuRepositoryImpl) Update(ctx context.Context, oldRoleID, oldMenuID, newRoleID, newMenuID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		zap.L().Error("Repository: Failed to begin transaction for role menu update", zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to begin transaction for role menu update", zap.Error(err))
 		return fmt.Errorf("failed to start transaction: %w", err)
 	}
 	defer tx.Rollback() // 確保在函數返回前回滾，除非明確提交
 
 	// 1. 刪除舊的關聯
 	deleteQuery := `DELETE FROM role_menus WHERE role_id = $1 AND menu_id = $2`
-	res, err := tx.Exec(deleteQuery, oldRoleID, oldMenuID)
+	res, err := tx.ExecContext(ctx, deleteQuery, oldRoleID, oldMenuID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to delete old role menu for update", zap.Error(err),
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete old role menu for update", zap.Error(err),
 			zap.Int("old_role_id", oldRoleID), zap.Int("old_menu_id", oldMenuID))
 		return fmt.Errorf("failed to delete old role menu: %w", err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		zap.L().Error("Repository: Failed to get rows affected after delete for update", zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get rows affected after delete for update", zap.Error(err))
 		return fmt.Errorf("failed to check deleted rows: %w", err)
 	}
 	if rowsAffected == 0 {
@@ -130,9 +155,9 @@ func (r *roleMenuRepositoryImpl) Update(oldRoleID, oldMenuID, newRoleID, newMenu
 
 	// 2. 創建新的關聯
 	createQuery := `INSERT INTO role_menus (role_id, menu_id) VALUES ($1, $2) ON CONFLICT (role_id, menu_id) DO NOTHING`
-	_, err = tx.Exec(createQuery, newRoleID, newMenuID)
+	_, err = tx.ExecContext(ctx, createQuery, newRoleID, newMenuID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to create new role menu for update", zap.Error(err),
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to create new role menu for update", zap.Error(err),
 			zap.Int("new_role_id", newRoleID), zap.Int("new_menu_id", newMenuID))
 		return fmt.Errorf("failed to create new role menu: %w", err)
 	}
@@ -140,16 +165,38 @@ func (r *roleMenuRepositoryImpl) Update(oldRoleID, oldMenuID, newRoleID, newMenu
 	return tx.Commit() // 提交事務
 }
 
+// DeleteByRoleID 刪除角色的所有選單關聯，角色沒有任何關聯時不視為錯誤
+func (r *roleMenuRepositoryImpl) DeleteByRoleID(ctx context.Context, roleID int) error {
+	query := `DELETE FROM role_menus WHERE role_id = $1`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, roleID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to delete role menus by role ID", zap.Error(err), zap.Int("role_id", roleID))
+		return fmt.Errorf("failed to delete role menus of role %d: %w", roleID, err)
+	}
+	return nil
+}
+
+// CopyRoleMenus 將 fromRoleID 的所有選單關聯複製給 toRoleID，已存在的關聯略過
+func (r *roleMenuRepositoryImpl) CopyRoleMenus(ctx context.Context, fromRoleID, toRoleID int) error {
+	query := `INSERT INTO role_menus (role_id, menu_id)
+              SELECT $2, menu_id FROM role_menus WHERE role_id = $1
+              ON CONFLICT (role_id, menu_id) DO NOTHING`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, fromRoleID, toRoleID); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to copy role menus", zap.Error(err), zap.Int("from_role_id", fromRoleID), zap.Int("to_role_id", toRoleID))
+		return fmt.Errorf("failed to copy role menus from role %d to %d: %w", fromRoleID, toRoleID, err)
+	}
+	return nil
+}
+
 // FindMenusByRoleID 根據角色 ID 獲取該角色能訪問的所有選單
-func (r *roleMenuRepositoryImpl) FindMenusByRoleID(roleID int) ([]models.Menu, error) {
-	query := `SELECT m.id, m.name, m.path, m.icon, m.parent_id, m.display_order, m.created_at, m.updated_at
+func (r *roleMenuRepositoryImpl) FindMenusByRoleID(ctx context.Context, roleID int) ([]models.Menu, error) {
+	query := `SELECT m.id, m.public_id, m.name, m.path, COALESCE(m.icon, ''), m.parent_id, m.display_order, m.created_at, m.updated_at
               FROM menus m
               JOIN role_menus rm ON m.id = rm.menu_id
               WHERE rm.role_id = $1
               ORDER BY m.display_order ASC`
-	rows, err := r.db.Query(query, roleID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, roleID)
 	if err != nil {
-		zap.L().Error("Repository: Failed to get menus by role ID", zap.Int("role_id", roleID), zap.Error(err))
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to get menus by role ID", zap.Int("role_id", roleID), zap.Error(err))
 		return nil, fmt.Errorf("failed to get menus for role %d: %w", roleID, err)
 	}
 	defer rows.Close()
@@ -160,6 +207,7 @@ func (r *roleMenuRepositoryImpl) FindMenusByRoleID(roleID int) ([]models.Menu, e
 		var parentID sql.NullInt64
 		if err := rows.Scan(
 			&menu.ID,
+			&menu.PublicID,
 			&menu.Name,
 			&menu.Path,
 			&menu.Icon,
@@ -168,8 +216,8 @@ func (r *roleMenuRepositoryImpl) FindMenusByRoleID(roleID int) ([]models.Menu, e
 			&menu.CreatedAt,
 			&menu.UpdatedAt,
 		); err != nil {
-			zap.L().Error("Repository: Failed to scan menu data for role", zap.Int("role_id", roleID), zap.Error(err))
-			return nil, fmt.Errorf("failed to scan menu data for role %d: %w", roleID, err)
+			utils.ContextLogger(ctx, r.logger).Error("Repository: Failed to scan menu data for role", zap.Int("role_id", roleID), zap.Int("row", len(menus)+1), zap.Error(err))
+			return nil, fmt.Errorf("failed to scan menu data for role %d at row %d: %w", roleID, len(menus)+1, err)
 		}
 		if parentID.Valid {
 			menu.ParentID = new(int)
@@ -179,5 +227,9 @@ func (r *roleMenuRepositoryImpl) FindMenusByRoleID(roleID int) ([]models.Menu, e
 		}
 		menus = append(menus, menu)
 	}
+	if err := rows.Err(); err != nil {
+		utils.ContextLogger(ctx, r.logger).Error("Repository: Error iterating menu data for role", zap.Int("role_id", roleID), zap.Error(err))
+		return nil, fmt.Errorf("error iterating menu data for role %d: %w", roleID, err)
+	}
 	return menus, nil
 }
@@ -0,0 +1,6 @@
+package repository
+
+// rowScanner 抽象 *sql.Row 與 *sql.Rows 共有的 Scan 方法，讓掃描邏輯可以共用
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// FindOptions 以 ID 等條件查詢單筆記錄時的選項，省略時使用零值 (不包含已軟刪除的記錄)
+type FindOptions struct {
+	IncludeDeleted bool // 包含已軟刪除的記錄，例如還原前檢查或管理員查看
+}
+
+// includeDeleted 合併可省略的 FindOptions
+func includeDeleted(opts []FindOptions) bool {
+	for _, opt := range opts {
+		if opt.IncludeDeleted {
+			return true
+		}
+	}
+	return false
+}
+
+// softDeletable 軟刪除的資料表 (帳戶、客戶、公司與產品定義) 共用的查詢片段與語句
+// 慣例：Delete 設定刪除時間而不刪除記錄；查詢預設附加 "AND <欄位> IS NULL"，FindOptions.IncludeDeleted
+// (列表為篩選條件的 IncludeDeleted) 時才包含已刪除的記錄；Restore 清除刪除時間
+// 資料表需要 updated_at；唯一索引需建立為排除已刪除記錄的部分索引 (WHERE <欄位> IS NULL)，還原時才會以唯一衝突回報重複
+type softDeletable struct {
+	table  string // 資料表名稱
+	alias  string // 查詢中資料表的別名 (例如 cu)，空白時不加前綴
+	column string // 刪除時間欄位，空白時為 deleted_at (產品定義以 discontinued_at 表示停售)
+	actors bool   // 資料表有 updated_by (models.RecordActors)，刪除與還原時記錄操作者
+}
+
+// field 返回查詢中的刪除時間欄位 (含別名)
+func (s softDeletable) field() string {
+	column := s.column
+	if column == "" {
+		column = "deleted_at"
+	}
+	if s.alias == "" {
+		return column
+	}
+	return s.alias + "." + column
+}
+
+// active 未刪除的條件，例如 "cu.deleted_at IS NULL"
+func (s softDeletable) active() string {
+	return s.field() + " IS NULL"
+}
+
+// and 附加在既有 WHERE 條件之後的未刪除條件，include 為 true 時返回空白
+func (s softDeletable) and(include bool) string {
+	if include {
+		return ""
+	}
+	return " AND " + s.active()
+}
+
+// conditions 將未刪除的條件加入 conditions (以 AND 組合的 WHERE 條件)，include 為 true 時不變
+func (s softDeletable) conditions(conditions []string, include bool) []string {
+	if include {
+		return conditions
+	}
+	return append(conditions, s.active())
+}
+
+// as 返回以 alias 為別名的副本，供同一資料表在查詢中使用其他別名時使用；alias 空白時不加前綴 (UPDATE 語句)
+func (s softDeletable) as(alias string) softDeletable {
+	s.alias = alias
+	return s
+}
+
+// delete 軟刪除 id 的記錄，記錄不存在或已刪除時返回 utils.ErrNotFound；actorID 記錄在 updated_by (見 actors)
+func (s softDeletable) delete(ctx context.Context, exec executor, id, actorID int) error {
+	t := s.as("")
+	query := fmt.Sprintf(`UPDATE %s SET %s = NOW(), %s WHERE id = $1 AND %s`, s.table, t.field(), s.touch(), t.active())
+	return s.exec(ctx, exec, query, id, actorID, "delete")
+}
+
+// restore 清除 id 的刪除時間，記錄不存在或未刪除時返回 utils.ErrNotFound；actorID 記錄在 updated_by (見 actors)
+// 與未刪除的記錄唯一衝突 (部分唯一索引) 時返回包裝後的資料庫錯誤，由呼叫端轉為 409 (例如 uniqueConflictError)
+func (s softDeletable) restore(ctx context.Context, exec executor, id, actorID int) error {
+	t := s.as("")
+	query := fmt.Sprintf(`UPDATE %s SET %s = NULL, %s WHERE id = $1 AND %s IS NOT NULL`, s.table, t.field(), s.touch(), t.field())
+	return s.exec(ctx, exec, query, id, actorID, "restore")
+}
+
+// touch 更新 updated_at 的 SET 片段，有 updated_by 的資料表同時以 $2 (操作者，0 表示系統寫入) 更新 updated_by
+func (s softDeletable) touch() string {
+	if s.actors {
+		return "updated_by = NULLIF($2, 0), updated_at = NOW()"
+	}
+	return "updated_at = NOW()"
+}
+
+// exec 執行 delete 或 restore 的語句並檢查是否有記錄被更新
+func (s softDeletable) exec(ctx context.Context, exec executor, query string, id, actorID int, action string) error {
+	args := []interface{}{id}
+	if s.actors {
+		args = append(args, actorID)
+	}
+	res, err := exec.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s %d: %w", action, s.table, id, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check %s rows affected for %s %d: %w", action, s.table, id, err)
+	}
+	if rowsAffected == 0 {
+		return utils.ErrNotFound // 未找到可刪除 (或可還原) 的記錄
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// TestSoftDeletableDeleteAndRestore 所有軟刪除的資料表以相同的語句刪除與還原：
+// 刪除只更新未刪除的記錄、還原只更新已刪除的記錄，有 updated_by 的資料表記錄操作者，沒有記錄被更新時返回 404，資料庫錯誤包裝後返回
+func TestSoftDeletableDeleteAndRestore(t *testing.T) {
+	entities := []struct {
+		name                  string
+		table                 softDeletable
+		deleteSQL, restoreSQL string
+		args                  []driver.Value // 記錄 ID 7；有 updated_by 的資料表另有操作者 3
+	}{
+		{
+			name: "accounts", table: accountSoftDelete,
+			deleteSQL:  `UPDATE accounts SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`,
+			restoreSQL: `UPDATE accounts SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL`,
+			args:       []driver.Value{7},
+		},
+		{
+			name: "companies", table: companySoftDelete,
+			deleteSQL:  `UPDATE companies SET deleted_at = NOW(), updated_by = NULLIF($2, 0), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`,
+			restoreSQL: `UPDATE companies SET deleted_at = NULL, updated_by = NULLIF($2, 0), updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL`,
+			args:       []driver.Value{7, 3},
+		},
+		{
+			name: "customers", table: customerSoftDelete,
+			deleteSQL:  `UPDATE customers SET deleted_at = NOW(), updated_by = NULLIF($2, 0), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`,
+			restoreSQL: `UPDATE customers SET deleted_at = NULL, updated_by = NULLIF($2, 0), updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL`,
+			args:       []driver.Value{7, 3},
+		},
+		{
+			name: "product definitions", table: productDefinitionSoftDelete,
+			deleteSQL:  `UPDATE product_definitions SET discontinued_at = NOW(), updated_by = NULLIF($2, 0), updated_at = NOW() WHERE id = $1 AND discontinued_at IS NULL`,
+			restoreSQL: `UPDATE product_definitions SET discontinued_at = NULL, updated_by = NULLIF($2, 0), updated_at = NOW() WHERE id = $1 AND discontinued_at IS NOT NULL`,
+			args:       []driver.Value{7, 3},
+		},
+	}
+	outcomes := []struct {
+		name     string
+		affected int64
+		err      error
+		wantErr  error
+	}{
+		{name: "updated", affected: 1},
+		{name: "not found", affected: 0, wantErr: utils.ErrNotFound},
+		{name: "database error", err: errConnectionReset, wantErr: errConnectionReset},
+	}
+	for _, entity := range entities {
+		for _, action := range []struct {
+			name string
+			sql  string
+			run  func(s softDeletable, exec executor) error
+		}{
+			{name: "delete", sql: entity.deleteSQL, run: func(s softDeletable, exec executor) error { return s.delete(context.Background(), exec, 7, 3) }},
+			{name: "restore", sql: entity.restoreSQL, run: func(s softDeletable, exec executor) error { return s.restore(context.Background(), exec, 7, 3) }},
+		} {
+			for _, outcome := range outcomes {
+				t.Run(entity.name+"/"+action.name+"/"+outcome.name, func(t *testing.T) {
+					database, mock := newMockDB(t)
+					expect := mock.ExpectExec("^" + regexp.QuoteMeta(action.sql) + "$").WithArgs(entity.args...)
+					if outcome.err != nil {
+						expect.WillReturnError(outcome.err)
+					} else {
+						expect.WillReturnResult(sqlmock.NewResult(0, outcome.affected))
+					}
+
+					if err := action.run(entity.table, database); !errors.Is(err, outcome.wantErr) || (outcome.wantErr == nil) != (err == nil) {
+						t.Fatalf("%s error = %v, want %v", action.name, err, outcome.wantErr)
+					}
+				})
+			}
+		}
+	}
+}
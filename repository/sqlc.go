@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository/internal/queries"
+)
+
+// 固定的查詢 (欄位與條件不隨參數改變) 寫在 repository/internal/queries/*.sql，由 sqlc 產生型別安全的 Go 程式碼 (見 sqlc.yaml 與 make generate)；
+// Repository 只負責選擇連接 (conn、readConn)、將產生的列型別轉為 models 並處理錯誤。
+// 篩選、排序、搜尋與分頁等依參數組合 WHERE 或 ORDER BY 的查詢仍以字串組合，並以 scanXxx 掃描相同順序的欄位
+
+// queriesOn 返回在 exec (conn 或 readConn 的結果) 上執行產生的查詢的 Queries
+func queriesOn(exec executor) *queries.Queries {
+	return queries.New(exec)
+}
+
+// optionalTime 將可為 NULL 的時間轉為指標，NULL 時為 nil
+func optionalTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// optionalInt 將可為 NULL 的整數轉為指標，NULL 時為 nil
+func optionalInt(v sql.NullInt32) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int32)
+	return &i
+}
+
+// optionalString 將可為 NULL 的字串轉為指標，NULL 時為 nil
+func optionalString(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+// nullableInt32 將 *int 轉為產生的查詢參數使用的 sql.NullInt32 (INTEGER 欄位)
+func nullableInt32(v *int) sql.NullInt32 {
+	if v == nil {
+		return sql.NullInt32{Valid: false}
+	}
+	return sql.NullInt32{Int32: int32(*v), Valid: true}
+}
+
+// recordActors 由產生的列中 created_by、建立者用戶名、updated_by 與修改者用戶名的欄位組成 RecordActors
+func recordActors(createdBy sql.NullInt32, createdByUsername sql.NullString, updatedBy sql.NullInt32, updatedByUsername sql.NullString) models.RecordActors {
+	return models.RecordActors{
+		CreatedBy:         optionalInt(createdBy),
+		CreatedByUsername: optionalString(createdByUsername),
+		UpdatedBy:         optionalInt(updatedBy),
+		UpdatedByUsername: optionalString(updatedByUsername),
+	}
+}
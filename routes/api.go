@@ -1,17 +1,125 @@
 package routes
 
 import (
+	"crypto/subtle"
 	"net/http" // 導入 http 包，用於定義方法常數
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 
 	"github.com/wac0705/fastener-api/handler"
+	"github.com/wac0705/fastener-api/middleware/audit"
 	"github.com/wac0705/fastener-api/middleware/authz"
+	"github.com/wac0705/fastener-api/middleware/bodylimit"
+	"github.com/wac0705/fastener-api/middleware/deprecation"
 	"github.com/wac0705/fastener-api/middleware/jwt"
+	"github.com/wac0705/fastener-api/middleware/requestlog"
 	"github.com/wac0705/fastener-api/service" // 導入 service 包以傳遞 PermissionService
+	"github.com/wac0705/fastener-api/utils"
 )
 
-// RegisterAPIRoutes 註冊所有 API 路由
+// API 路徑前綴
+const (
+	APIV1Prefix     = "/api/v1"
+	LegacyAPIPrefix = "/api" // 已棄用，與 APIV1Prefix 下的路由相同
+)
+
+// probePaths 健康檢查、探針與 Prometheus 抓取的路徑，不經過 JWT 驗證，並略過 CORS 與請求日誌 (見 IsProbeRequest)
+var probePaths = map[string]bool{"/healthz": true, "/livez": true, "/readyz": true, "/metrics": true}
+
+// IsProbeRequest 請求是否為健康檢查、探針或指標抓取，供全局中介軟體的 Skipper 使用
+func IsProbeRequest(c echo.Context) bool {
+	return probePaths[c.Request().URL.Path]
+}
+
+// uploadPaths 接受檔案上傳的路由 (不含版本前綴)，以 UPLOAD_MAX_BODY_BYTES 取代全局的請求內容大小上限
+// 在 RegisterAPIGroup 中這些路由都需套用 bodylimit.Middleware(h.UploadBodyLimit)
+var uploadPaths = map[string]bool{
+	"/companies/import":              true,
+	"/customers/import":              true,
+	"/product_definitions/import":    true,
+	"/product_definitions/:id/image": true,
+}
+
+// IsUploadRequest 請求的路由是否接受檔案上傳，供全局 BodyLimit 中介軟體的 Skipper 使用 (上傳路由自行套用較大的上限)
+func IsUploadRequest(c echo.Context) bool {
+	path, ok := apiRoutePath(c)
+	return ok && uploadPaths[path]
+}
+
+// uncompressedPaths 不以 gzip 壓縮回應的 GET 路由 (不含版本前綴)：內容已經壓縮過的圖片，以及需要逐筆送出的事件串流
+var uncompressedPaths = map[string]bool{"/product_definitions/:id/image": true, "/events": true}
+
+// streamPaths 長時間保持連線的路由 (不含版本前綴)，不套用 REQUEST_TIMEOUT
+var streamPaths = map[string]bool{"/events": true}
+
+// IsStreamRequest 請求的路由是否為長時間保持連線的串流 (事件串流)，供請求逾時中介軟體的 Skipper 使用
+func IsStreamRequest(c echo.Context) bool {
+	path, ok := apiRoutePath(c)
+	return ok && streamPaths[path]
+}
+
+// BodyLogSkipper 返回內容日誌 (bodylog) 中介軟體的 Skipper：all 為 true 時記錄所有 API 路由，否則只記錄 paths 中的路由 (不含版本前綴，例如 /customers/:id)
+// 非 API 的路徑 (探針、/metrics) 與事件串流一律不記錄
+func BodyLogSkipper(all bool, paths []string) middleware.Skipper {
+	enabled := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		enabled[path] = true
+	}
+	return func(c echo.Context) bool {
+		path, ok := apiRoutePath(c)
+		if !ok || streamPaths[path] {
+			return true
+		}
+		return !all && !enabled[path]
+	}
+}
+
+// streamedExportPaths 串流輸出 CSV 的匯出路由 (不含版本前綴)，只在 Accept-Encoding 明確列出 gzip 時壓縮
+var streamedExportPaths = map[string]bool{"/customers/export": true}
+
+// SkipCompression 是否不壓縮請求的回應，供 Gzip 中介軟體的 Skipper 使用
+// /metrics 與圖片不壓縮；CSV 匯出只在 Accept-Encoding 明確列出 gzip (q 不為 0) 時壓縮
+func SkipCompression(c echo.Context) bool {
+	if c.Path() == "/metrics" {
+		return true
+	}
+	path, ok := apiRoutePath(c)
+	if !ok {
+		return false
+	}
+	if c.Request().Method == http.MethodGet && uncompressedPaths[path] {
+		return true
+	}
+	return streamedExportPaths[path] && !acceptsGzip(c.Request().Header.Get(echo.HeaderAcceptEncoding))
+}
+
+// acceptsGzip Accept-Encoding 是否明確列出 gzip 且 q 不為 0 (例如 "gzip, deflate" 或 "br;q=1.0, gzip;q=0.8")，* 不算在內
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		return !ok || strings.Trim(q, "0.") != ""
+	}
+	return false
+}
+
+// apiRoutePath 返回請求的路由樣板去除版本前綴 (APIV1Prefix 或 LegacyAPIPrefix) 後的路徑，例如 /customers/:id
+func apiRoutePath(c echo.Context) (string, bool) {
+	for _, prefix := range []string{APIV1Prefix, LegacyAPIPrefix} {
+		if path, ok := strings.CutPrefix(c.Path(), prefix+"/"); ok {
+			return "/" + path, true
+		}
+	}
+	return "", false
+}
+
+// RegisterAPIRoutes 註冊所有 API 路由；API 掛載在 /api/v1 下，/api 下保留相同路由作為已棄用的別名
 func RegisterAPIRoutes(e *echo.Echo,
 	authHandler *handler.AuthHandler,
 	accountHandler *handler.AccountHandler,
@@ -20,19 +128,81 @@ func RegisterAPIRoutes(e *echo.Echo,
 	menuHandler *handler.MenuHandler,
 	productDefinitionHandler *handler.ProductDefinitionHandler,
 	roleMenuHandler *handler.RoleMenuHandler,
+	roleHandler *handler.RoleHandler,
+	auditHandler *handler.AuditHandler,
+	jobHandler *handler.JobHandler,
+	eventsHandler *handler.EventsHandler,
+	cacheHandler *handler.CacheHandler,
+	healthHandler *handler.HealthHandler,
 	permissionService service.PermissionService, // 注入權限服務
+	auditService service.AuditService, // 接收寫入操作的稽核記錄
 	jwtSecret string, // 注入 JWT Secret
+	uploadBodyLimit int64, // 檔案上傳路由的請求內容大小上限 (bytes)
+	legacySunset time.Time, // 無版本的 /api 舊路徑停止提供的日期，零值時不加 Sunset 標頭
 ) {
-	apiGroup := e.Group("/api")
+	e.RouteNotFound("/*", handler.RouteNotFound) // 未知的路徑返回 CustomError 格式的 404 (ROUTE_NOT_FOUND)
+
+	// --- 健康檢查 (不在 /api 分組中，無需身份驗證)，供負載平衡器與 Kubernetes 探針使用 ---
+	e.GET("/healthz", healthHandler.Healthz)
+	e.GET("/livez", healthHandler.Livez)   // 存活探針 (livenessProbe)，不檢查相依元件
+	e.GET("/readyz", healthHandler.Readyz) // 就緒探針 (readinessProbe)，啟動完成前返回 503
 
+	h := APIHandlers{
+		Auth:              authHandler,
+		Account:           accountHandler,
+		Company:           companyHandler,
+		Customer:          customerHandler,
+		Menu:              menuHandler,
+		ProductDefinition: productDefinitionHandler,
+		RoleMenu:          roleMenuHandler,
+		Role:              roleHandler,
+		Audit:             auditHandler,
+		Job:               jobHandler,
+		Events:            eventsHandler,
+		Cache:             cacheHandler,
+		PermissionService: permissionService,
+		AuditRecorder:     auditService,
+		JWTSecret:         jwtSecret,
+		UploadBodyLimit:   uploadBodyLimit,
+	}
+	RegisterAPIGroup(e.Group(APIV1Prefix, utils.StrictJSONBinding()), h) // JSON 請求內容的未知欄位返回 400 (見 utils.JSONBinder)
+	// 舊路徑與 /api/v1 共用相同的 handler 與中介軟體，回應另帶 Deprecation、Sunset 與指向 /api/v1 的 Link 標頭
+	RegisterAPIGroup(e.Group(LegacyAPIPrefix, deprecation.Middleware(LegacyAPIPrefix, APIV1Prefix, legacySunset)), h)
+}
+
+// APIHandlers 註冊 API 分組所需的處理器與相依元件，各版本的分組共用同一組實例
+type APIHandlers struct {
+	Auth              *handler.AuthHandler
+	Account           *handler.AccountHandler
+	Company           *handler.CompanyHandler
+	Customer          *handler.CustomerHandler
+	Menu              *handler.MenuHandler
+	ProductDefinition *handler.ProductDefinitionHandler
+	RoleMenu          *handler.RoleMenuHandler
+	Role              *handler.RoleHandler
+	Audit             *handler.AuditHandler
+	Job               *handler.JobHandler
+	Events            *handler.EventsHandler
+	Cache             *handler.CacheHandler
+	PermissionService service.PermissionService
+	AuditRecorder     audit.Recorder // 已驗證請求中寫入操作的稽核記錄
+	JWTSecret         string
+	UploadBodyLimit   int64 // 檔案上傳路由 (uploadPaths) 的請求內容大小上限
+}
+
+// RegisterAPIGroup 在 apiGroup 下註冊所有 API 路由 (含 JWT 驗證與授權中介軟體)
+// 每個版本前綴 (例如 /api/v1、日後的 /api/v2) 各呼叫一次，路由與權限檢查在各前綴下完全相同
+func RegisterAPIGroup(apiGroup *echo.Group, h APIHandlers) {
 	// --- 公開路由 (無需身份驗證) ---
-	apiGroup.POST("/login", authHandler.Login)
-	apiGroup.POST("/register", authHandler.Register)
-	apiGroup.POST("/refresh-token", authHandler.RefreshToken)
+	apiGroup.POST("/login", h.Auth.Login)
+	apiGroup.POST("/register", h.Auth.Register)
+	apiGroup.POST("/refresh-token", h.Auth.RefreshToken)
+
+	uploadLimit := bodylimit.Middleware(h.UploadBodyLimit) // 檔案上傳路由的請求內容大小上限 (見 uploadPaths)
 
 	// --- 受保護路由 (需要 JWT Access Token 驗證和細粒度授權) ---
 	authGroup := apiGroup.Group("") // 創建一個新的分組，應用 JWT 中介軟體
-	authGroup.Use(jwt.JwtAccessConfig(jwtSecret)) // 應用 JWT Access Token 驗證
+	authGroup.Use(jwt.JwtAccessConfig(h.JWTSecret)) // 應用 JWT Access Token 驗證
 
 	// 額外中介軟體：將 Access Token Claims 存入 Echo Context
 	// 這樣後續的 authz 中介軟體和 handler 就可以方便地訪問用戶資訊
@@ -47,62 +217,155 @@ func RegisterAPIRoutes(e *echo.Echo,
 			return next(c)
 		}
 	})
+	authGroup.Use(requestlog.WithAccount()) // 請求範圍 logger 加上 account_id、username 與 role_id
+	authGroup.Use(audit.Middleware(h.AuditRecorder)) // 記錄所有 POST、PUT、PATCH、DELETE 的帳戶、路由與結果 (GET /audit)
 
 	// --- 應用細粒度授權中介軟體 (authz.Authorize) ---
 	// 傳入每個 API 端點所需的特定權限字串
 	// 格式通常是 "資源:操作"，例如 "company:read", "account:create"
 
 	// 帳戶管理路由
-	authGroup.GET("/accounts", accountHandler.GetAccounts, authz.Authorize("account:read", permissionService))
-	authGroup.GET("/accounts/:id", accountHandler.GetAccountById, authz.Authorize("account:read", permissionService))
-	authGroup.POST("/accounts", accountHandler.CreateAccount, authz.Authorize("account:create", permissionService))
-	authGroup.PUT("/accounts/:id", accountHandler.UpdateAccount, authz.Authorize("account:update", permissionService))
-	authGroup.DELETE("/accounts/:id", accountHandler.DeleteAccount, authz.Authorize("account:delete", permissionService))
-	authGroup.POST("/accounts/:id/password", accountHandler.UpdateAccountPassword, authz.Authorize("account:update_password", permissionService))
-	authGroup.GET("/my-profile", authHandler.GetMyProfile, authz.Authorize("account:read_own_profile", permissionService)) // 用戶查看自己資料
+	authGroup.GET("/accounts", h.Account.GetAccounts, authz.Authorize("account:read", h.PermissionService))
+	authGroup.GET("/accounts/:id", h.Account.GetAccountById, authz.Authorize("account:read", h.PermissionService))
+	authGroup.POST("/accounts", h.Account.CreateAccount, audit.RecordBody(), authz.Authorize("account:create", h.PermissionService)) // 稽核記錄包含請求內容 (密碼已遮蔽)
+	authGroup.PUT("/accounts/:id", h.Account.UpdateAccount, authz.Authorize("account:update", h.PermissionService))
+	authGroup.DELETE("/accounts/:id", h.Account.DeleteAccount, authz.Authorize("account:delete", h.PermissionService))
+	authGroup.POST("/accounts/:id/restore", h.Account.RestoreAccount, authz.Authorize("account:restore", h.PermissionService)) // 還原軟刪除的帳戶
+	authGroup.POST("/accounts/:id/password", h.Account.UpdateAccountPassword, authz.Authorize("account:update_password", h.PermissionService))
+	authGroup.GET("/my-profile", h.Auth.GetMyProfile, authz.Authorize("account:read_own_profile", h.PermissionService)) // 用戶查看自己資料
 
 	// 公司管理路由
-	authGroup.GET("/companies", companyHandler.GetCompanies, authz.Authorize("company:read", permissionService))
-	authGroup.GET("/companies/:id", companyHandler.GetCompanyById, authz.Authorize("company:read", permissionService))
-	authGroup.POST("/companies", companyHandler.CreateCompany, authz.Authorize("company:create", permissionService))
-	authGroup.PUT("/companies/:id", companyHandler.UpdateCompany, authz.Authorize("company:update", permissionService))
-	authGroup.DELETE("/companies/:id", companyHandler.DeleteCompany, authz.Authorize("company:delete", permissionService))
+	authGroup.GET("/companies", h.Company.GetCompanies, authz.Authorize("company:read", h.PermissionService))
+	authGroup.GET("/companies/tree", h.Company.GetCompanyTree, authz.Authorize("company:read", h.PermissionService)) // 集團樹狀結構
+	authGroup.GET("/companies/stats", h.Company.GetCompanyStats, authz.Authorize("company:read", h.PermissionService)) // 客戶統計
+	authGroup.GET("/companies/:id", h.Company.GetCompanyById, authz.Authorize("company:read", h.PermissionService))
+	authGroup.POST("/companies", h.Company.CreateCompany, authz.Authorize("company:create", h.PermissionService))
+	authGroup.PUT("/companies/:id", h.Company.UpdateCompany, authz.Authorize("company:update", h.PermissionService))
+	authGroup.DELETE("/companies/:id", h.Company.DeleteCompany, authz.Authorize("company:delete", h.PermissionService))
+	authGroup.POST("/companies/:id/restore", h.Company.RestoreCompany, authz.Authorize("company:restore", h.PermissionService)) // 還原軟刪除的公司
+	authGroup.POST("/companies/import", h.Company.ImportCompanies, uploadLimit, authz.Authorize("company:import", h.PermissionService)) // CSV 批次匯入
+	authGroup.POST("/companies/:id/merge", h.Company.MergeCompanies, authz.Authorize("company:merge", h.PermissionService)) // 合併重複公司
 
 	// 客戶管理路由
-	authGroup.GET("/customers", customerHandler.GetCustomers, authz.Authorize("customer:read", permissionService))
-	authGroup.GET("/customers/:id", customerHandler.GetCustomerById, authz.Authorize("customer:read", permissionService))
-	authGroup.POST("/customers", customerHandler.CreateCustomer, authz.Authorize("customer:create", permissionService))
-	authGroup.PUT("/customers/:id", customerHandler.UpdateCustomer, authz.Authorize("customer:update", permissionService))
-	authGroup.DELETE("/customers/:id", customerHandler.DeleteCustomer, authz.Authorize("customer:delete", permissionService))
+	authz.RegisterPermissions("customer:read_deleted") // GET /customers?include_deleted=true 由 Handler 檢查
+	authGroup.GET("/customers", h.Customer.GetCustomers, authz.AuthorizeAny([]string{"customer:read", "customer:read_own"}, h.PermissionService)) // 只有 customer:read_own 時僅返回指派給自己的客戶
+	authGroup.GET("/customers/export", h.Customer.ExportCustomers, authz.Authorize("customer:export", h.PermissionService)) // 依列表篩選條件匯出 CSV
+	authGroup.POST("/customers/import", h.Customer.ImportCustomers, uploadLimit, authz.Authorize("customer:import", h.PermissionService)) // CSV 批次匯入
+	authGroup.POST("/customers/check-duplicates", h.Customer.CheckDuplicateCustomers, authz.Authorize("customer:create", h.PermissionService)) // 建立前檢查相似記錄
+	authGroup.GET("/customers/code/:code", h.Customer.GetCustomerByCode, authz.AuthorizeAny([]string{"customer:read", "customer:read_own"}, h.PermissionService)) // 以客戶代碼查詢 (ERP 整合)
+	authGroup.GET("/customers/:id", h.Customer.GetCustomerById, authz.AuthorizeAny([]string{"customer:read", "customer:read_own"}, h.PermissionService))
+	authGroup.POST("/customers", h.Customer.CreateCustomer, authz.Authorize("customer:create", h.PermissionService))
+	authGroup.PUT("/customers/:id", h.Customer.UpdateCustomer, authz.Authorize("customer:update", h.PermissionService))
+	authGroup.DELETE("/customers/:id", h.Customer.DeleteCustomer, authz.Authorize("customer:delete", h.PermissionService))
+	authGroup.POST("/customers/:id/restore", h.Customer.RestoreCustomer, authz.Authorize("customer:restore", h.PermissionService)) // 還原軟刪除的客戶
+	authGroup.GET("/customers/:id/history", h.Customer.GetCustomerHistory, authz.Authorize("customer:read_history", h.PermissionService)) // 欄位層級的變更歷史
+
+	// 客戶地址 (子資源，沿用客戶的讀取/更新權限)
+	authGroup.GET("/customers/:id/addresses", h.Customer.GetCustomerAddresses, authz.Authorize("customer:read", h.PermissionService))
+	authGroup.POST("/customers/:id/addresses", h.Customer.CreateCustomerAddress, authz.Authorize("customer:update", h.PermissionService))
+	authGroup.PUT("/customers/:id/addresses/:address_id", h.Customer.UpdateCustomerAddress, authz.Authorize("customer:update", h.PermissionService))
+	authGroup.DELETE("/customers/:id/addresses/:address_id", h.Customer.DeleteCustomerAddress, authz.Authorize("customer:update", h.PermissionService))
+
+	// 客戶備註時間軸 (刪除時由 Service 層檢查是否為作者或管理員)
+	authGroup.GET("/customers/:id/notes", h.Customer.GetCustomerNotes, authz.Authorize("customer:read", h.PermissionService))
+	authGroup.POST("/customers/:id/notes", h.Customer.CreateCustomerNote, authz.Authorize("customer:update", h.PermissionService))
+	authGroup.DELETE("/customers/:id/notes/:note_id", h.Customer.DeleteCustomerNote, authz.Authorize("customer:update", h.PermissionService))
 
 	// 選單管理路由
-	authGroup.GET("/menus", menuHandler.GetMenus, authz.Authorize("menu:read", permissionService))
-	authGroup.GET("/menus/:id", menuHandler.GetMenuById, authz.Authorize("menu:read", permissionService))
-	authGroup.POST("/menus", menuHandler.CreateMenu, authz.Authorize("menu:create", permissionService))
-	authGroup.PUT("/menus/:id", menuHandler.UpdateMenu, authz.Authorize("menu:update", permissionService))
-	authGroup.DELETE("/menus/:id", menuHandler.DeleteMenu, authz.Authorize("menu:delete", permissionService))
+	authGroup.GET("/menus", h.Menu.GetMenus, authz.Authorize("menu:read", h.PermissionService))
+	authGroup.GET("/menus/:id", h.Menu.GetMenuById, authz.Authorize("menu:read", h.PermissionService))
+	authGroup.POST("/menus", h.Menu.CreateMenu, authz.Authorize("menu:create", h.PermissionService))
+	authGroup.PUT("/menus/:id", h.Menu.UpdateMenu, authz.Authorize("menu:update", h.PermissionService))
+	authGroup.DELETE("/menus/:id", h.Menu.DeleteMenu, authz.Authorize("menu:delete", h.PermissionService))
 
 	// 產品類別和產品定義管理路由
-	authGroup.GET("/product_categories", productDefinitionHandler.GetProductCategories, authz.Authorize("product_category:read", permissionService))
-	authGroup.POST("/product_categories", productDefinitionHandler.CreateProductCategory, authz.Authorize("product_category:create", permissionService))
-	authGroup.PUT("/product_categories/:id", productDefinitionHandler.UpdateProductCategory, authz.Authorize("product_category:update", permissionService))
-	authGroup.DELETE("/product_categories/:id", productDefinitionHandler.DeleteProductCategory, authz.Authorize("product_category:delete", permissionService))
+	authGroup.GET("/product_categories", h.ProductDefinition.GetProductCategories, authz.Authorize("product_category:read", h.PermissionService))
+	authGroup.GET("/product_categories/tree", h.ProductDefinition.GetProductCategoryTree, authz.Authorize("product_category:read", h.PermissionService))
+	authGroup.GET("/product_categories/:id", h.ProductDefinition.GetProductCategoryById, authz.Authorize("product_category:read", h.PermissionService))
+	authGroup.POST("/product_categories", h.ProductDefinition.CreateProductCategory, authz.Authorize("product_category:create", h.PermissionService))
+	authGroup.PUT("/product_categories/:id", h.ProductDefinition.UpdateProductCategory, authz.Authorize("product_category:update", h.PermissionService))
+	authGroup.DELETE("/product_categories/:id", h.ProductDefinition.DeleteProductCategory, authz.Authorize("product_category:delete", h.PermissionService))
+
+	authGroup.GET("/product_definitions", h.ProductDefinition.GetProductDefinitions, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.GET("/product_definitions/standards", h.ProductDefinition.GetProductStandards, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.GET("/product_definitions/:id", h.ProductDefinition.GetProductDefinitionById, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.POST("/product_definitions", h.ProductDefinition.CreateProductDefinition, authz.Authorize("product_definition:create", h.PermissionService))
+	authGroup.POST("/product_definitions/import", h.ProductDefinition.ImportProductDefinitions, uploadLimit, authz.Authorize("product_definition:import", h.PermissionService)) // CSV / XLSX 批次匯入
+	authGroup.POST("/product_definitions/bulk-price-update", h.ProductDefinition.BulkUpdateProductPrices, authz.Authorize("product_definition:bulk_update", h.PermissionService)) // dry_run 時只預覽
+	authGroup.PUT("/product_definitions/:id", h.ProductDefinition.UpdateProductDefinition, authz.Authorize("product_definition:update", h.PermissionService))
+	authGroup.DELETE("/product_definitions/:id", h.ProductDefinition.DeleteProductDefinition, authz.Authorize("product_definition:delete", h.PermissionService)) // 停售 (軟刪除)
+	authGroup.POST("/product_definitions/:id/clone", h.ProductDefinition.CloneProductDefinition, authz.Authorize("product_definition:create", h.PermissionService))
+	authGroup.POST("/product_definitions/:id/reactivate", h.ProductDefinition.ReactivateProductDefinition, authz.Authorize("product_definition:reactivate", h.PermissionService))
+	authGroup.GET("/product_definitions/:id/history", h.ProductDefinition.GetProductDefinitionHistory, authz.Authorize("product_definition:read_history", h.PermissionService)) // 欄位層級的變更歷史
 
-	authGroup.GET("/product_definitions", productDefinitionHandler.GetProductDefinitions, authz.Authorize("product_definition:read", permissionService))
-	authGroup.GET("/product_definitions/:id", productDefinitionHandler.GetProductDefinitionById, authz.Authorize("product_definition:read", permissionService))
-	authGroup.POST("/product_definitions", productDefinitionHandler.CreateProductDefinition, authz.Authorize("product_definition:create", permissionService))
-	authGroup.PUT("/product_definitions/:id", productDefinitionHandler.UpdateProductDefinition, authz.Authorize("product_definition:update", permissionService))
-	authGroup.DELETE("/product_definitions/:id", productDefinitionHandler.DeleteProductDefinition, authz.Authorize("product_definition:delete", permissionService))
+	// 產品多幣別價格 (子資源，沿用產品定義的讀取/更新權限)
+	authGroup.GET("/product_definitions/:id/prices", h.ProductDefinition.GetProductPrices, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.POST("/product_definitions/:id/prices", h.ProductDefinition.CreateProductPrice, authz.Authorize("product_definition:update", h.PermissionService))
+	authGroup.PUT("/product_definitions/:id/prices/:price_id", h.ProductDefinition.UpdateProductPrice, authz.Authorize("product_definition:update", h.PermissionService))
+	authGroup.DELETE("/product_definitions/:id/prices/:price_id", h.ProductDefinition.DeleteProductPrice, authz.Authorize("product_definition:update", h.PermissionService))
+
+	// 產品數量分級價格 (PUT 為整組取代)
+	authGroup.GET("/product_definitions/:id/price-tiers", h.ProductDefinition.GetProductPriceTiers, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.PUT("/product_definitions/:id/price-tiers", h.ProductDefinition.ReplaceProductPriceTiers, authz.Authorize("product_definition:update", h.PermissionService))
+	authGroup.GET("/product_definitions/:id/price", h.ProductDefinition.GetProductPriceForQuantity, authz.Authorize("product_definition:read", h.PermissionService)) // ?qty=5000
+
+	// 產品換算單位 (PUT 為整組取代)
+	authGroup.GET("/product_definitions/:id/units", h.ProductDefinition.GetProductUnits, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.PUT("/product_definitions/:id/units", h.ProductDefinition.ReplaceProductUnits, authz.Authorize("product_definition:update", h.PermissionService))
+	authGroup.GET("/product_definitions/:id/convert", h.ProductDefinition.ConvertProductUnits, authz.Authorize("product_definition:read", h.PermissionService)) // ?from=kg&to=pcs&value=25
+
+	// 產品變體 (子資源，沿用產品定義的讀取/建立權限)
+	authGroup.GET("/product_definitions/:id/variants", h.ProductDefinition.GetProductVariants, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.POST("/product_definitions/:id/variants/generate", h.ProductDefinition.GenerateProductVariants, authz.Authorize("product_definition:create", h.PermissionService))
+
+	// 產品圖片 (子資源，沿用產品定義的讀取/更新權限)
+	authGroup.GET("/product_definitions/:id/image", h.ProductDefinition.GetProductImage, authz.Authorize("product_definition:read", h.PermissionService))
+	authGroup.POST("/product_definitions/:id/image", h.ProductDefinition.UploadProductImage, uploadLimit, authz.Authorize("product_definition:update", h.PermissionService)) // multipart 欄位 "file"
+	authGroup.DELETE("/product_definitions/:id/image", h.ProductDefinition.DeleteProductImage, authz.Authorize("product_definition:update", h.PermissionService))
 
 	// 角色選單關聯管理路由
-	authGroup.GET("/role_menus", roleMenuHandler.GetRoleMenus, authz.Authorize("role_menu:read", permissionService))
-	authGroup.POST("/role_menus", roleMenuHandler.CreateRoleMenu, authz.Authorize("role_menu:create", permissionService))
-	authGroup.DELETE("/role_menus/:id1/:id2", roleMenuHandler.DeleteRoleMenu, authz.Authorize("role_menu:delete", permissionService)) // 複合主鍵刪除
-	authGroup.PUT("/role_menus/:id1/:id2", roleMenuHandler.UpdateRoleMenu, authz.Authorize("role_menu:update", permissionService)) // 複合主鍵更新
+	authGroup.GET("/role_menus", h.RoleMenu.GetRoleMenus, authz.Authorize("role_menu:read", h.PermissionService))
+	authGroup.POST("/role_menus", h.RoleMenu.CreateRoleMenu, authz.Authorize("role_menu:create", h.PermissionService))
+	authGroup.DELETE("/role_menus/:id1/:id2", h.RoleMenu.DeleteRoleMenu, authz.Authorize("role_menu:delete", h.PermissionService)) // 複合主鍵刪除
+	authGroup.PUT("/role_menus/:id1/:id2", h.RoleMenu.UpdateRoleMenu, authz.Authorize("role_menu:update", h.PermissionService)) // 複合主鍵更新
 
 	// (範例) 獲取特定角色可訪問的選單 - 這個路由可以直接從前端使用來獲取動態選單
 	// 由於這個是專門為前端獲取選單數據而設計，其權限檢查可能略有不同，
 	// 例如只檢查是否登入，而不是是否有特定選單管理權限。
 	// 或者，只允許「admin」角色呼叫這個 API。
-	authGroup.GET("/roles/:roleID/menus", menuHandler.GetMenusByRoleID, authz.Authorize("role:read_menus", permissionService)) // 新增權限字串
+	authGroup.GET("/roles/:roleID/menus", h.Menu.GetMenusByRoleID, authz.Authorize("role:read_menus", h.PermissionService)) // 新增權限字串
+	authGroup.PUT("/roles/:roleID/menus", h.RoleMenu.ReplaceRoleMenus, audit.RecordBody(), authz.Authorize("role_menu:update", h.PermissionService)) // 整組取代角色的選單
+	authGroup.POST("/roles/:roleID/clone", h.Role.CloneRole, audit.RecordBody(), authz.Authorize("role:create", h.PermissionService)) // 複製角色 (含權限與選單)
+	authGroup.GET("/roles/:roleID/permissions", h.Role.GetRolePermissions, authz.Authorize("role:read_permissions", h.PermissionService))
+	authGroup.GET("/permissions", h.Role.GetPermissions, authz.Authorize("permission:read", h.PermissionService)) // 權限目錄
+
+	// 寫入操作的稽核記錄 (由 audit.Middleware 產生)
+	authGroup.GET("/audit", h.Audit.GetAuditLogs, authz.Authorize("audit:read", h.PermissionService))
+
+	// 背景工作的排程與最近一次執行的結果
+	authGroup.GET("/admin/jobs", h.Job.GetJobs, authz.Authorize("job:read", h.PermissionService))
+
+	// 讀取緩存
+	authGroup.POST("/admin/cache/flush", h.Cache.FlushCache, authz.Authorize("cache:flush", h.PermissionService))
+
+	// 事件串流 (Server-Sent Events)：已登入的用戶端訂閱角色選單的變更，不需要額外的權限
+	authGroup.GET("/events", h.Events.StreamEvents)
+
+	// 未知的 API 路徑返回 404 (ROUTE_NOT_FOUND)，取代 authGroup.Use 以同樣路徑註冊、會先經過 JWT 驗證而返回 401 的預設 404 路由
+	apiGroup.RouteNotFound("", handler.RouteNotFound)
+	apiGroup.RouteNotFound("/*", handler.RouteNotFound)
+}
+
+// RegisterMetricsRoute 註冊 GET /metrics (不在 /api 分組中)；username 與 password 不為空時以 Basic Auth 保護
+func RegisterMetricsRoute(e *echo.Echo, metricsHandler *handler.MetricsHandler, username, password string) {
+	if username == "" {
+		e.GET("/metrics", metricsHandler.Metrics)
+		return
+	}
+	e.GET("/metrics", metricsHandler.Metrics, middleware.BasicAuth(func(u, p string, c echo.Context) (bool, error) {
+		// 固定時間比較，避免以回應時間猜測帳密
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		return userOK && passOK, nil
+	}))
 }
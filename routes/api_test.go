@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/wac0705/fastener-api/handler"
+)
+
+// routedHandlers 所有註冊路由的 Handler 類型，新增 Handler 時需一併加入
+var routedHandlers = []interface{}{
+	new(handler.AuthHandler),
+	new(handler.AccountHandler),
+	new(handler.CompanyHandler),
+	new(handler.CustomerHandler),
+	new(handler.MenuHandler),
+	new(handler.ProductDefinitionHandler),
+	new(handler.RoleMenuHandler),
+	new(handler.RoleHandler),
+	new(handler.AuditHandler),
+	new(handler.JobHandler),
+	new(handler.EventsHandler),
+	new(handler.CacheHandler),
+	new(handler.HealthHandler),
+	new(handler.MetricsHandler),
+	new(handler.DocsHandler),
+}
+
+// unroutedHandlerMethods 簽名與 echo.HandlerFunc 相同、但刻意不註冊為路由的 Handler 方法 (以 "類型.方法" 表示)
+var unroutedHandlerMethods = map[string]bool{}
+
+// handlerFuncType echo.HandlerFunc 的方法簽名 (不含接收者)
+var handlerFuncType = reflect.TypeOf(echo.HandlerFunc(nil))
+
+// TestEveryHandlerMethodIsRouted 每個簽名為 echo.HandlerFunc 的 Handler 方法都需註冊在路由表中，
+// 避免實作了 handler 卻漏掉路由 (例如 GET /product_categories/:id)
+func TestEveryHandlerMethodIsRouted(t *testing.T) {
+	routed := map[string]bool{}
+	for _, route := range NewRouteTable().Routes() {
+		routed[route.Name] = true
+	}
+
+	var missing []string
+	for _, h := range routedHandlers {
+		value := reflect.ValueOf(h)
+		for i := 0; i < value.NumMethod(); i++ {
+			method := value.Type().Method(i)
+			if !value.Method(i).Type().ConvertibleTo(handlerFuncType) {
+				continue // 不是 handler (例如輔助方法)
+			}
+			key := value.Elem().Type().Name() + "." + method.Name
+			if unroutedHandlerMethods[key] {
+				continue
+			}
+			if !routed[handlerName(method)] {
+				missing = append(missing, key)
+			}
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		t.Errorf("handler method %s is not registered as a route", key)
+	}
+}
+
+// TestRegisteredRoutesUseKnownHandlers 路由表中的 API 路由都指向 routedHandlers 中的 Handler 方法，
+// 確保 routedHandlers 沒有漏列新的 Handler (否則上面的檢查不會涵蓋它)
+func TestRegisteredRoutesUseKnownHandlers(t *testing.T) {
+	known := map[string]bool{}
+	for _, h := range routedHandlers {
+		value := reflect.ValueOf(h)
+		for i := 0; i < value.NumMethod(); i++ {
+			known[handlerName(value.Type().Method(i))] = true
+		}
+	}
+	for _, route := range NewRouteTable().Routes() {
+		if route.Method == echo.RouteNotFound {
+			continue // handler.RouteNotFound 是函數，不屬於任何 Handler
+		}
+		if !known[route.Name] {
+			t.Errorf("route %s %s uses %s, which is not a method of a handler listed in routedHandlers", route.Method, route.Path, route.Name)
+		}
+	}
+}
+
+// handlerName 返回以 method 的方法值註冊路由時 Echo 記錄的 Route.Name
+// Echo 以 handler 的函數名稱作為 Route.Name，方法值的名稱為方法的名稱 ("<package>.(*<類型>).<方法>") 加上 "-fm"
+func handlerName(method reflect.Method) string {
+	return runtime.FuncForPC(method.Func.Pointer()).Name() + "-fm"
+}
+
+// TestSkipCompression 以與伺服器相同的 Gzip 設定 (Skipper 為 SkipCompression) 在實際的路由路徑上回應相同大小的內容：
+// 一般 API 只在 Accept-Encoding 包含 gzip 且內容達到 MinLength 時壓縮；/metrics、產品圖片與事件串流一律不壓縮；
+// CSV 匯出只在 Accept-Encoding 明確列出 gzip 時壓縮 (沒有標頭、只有 * 或 gzip;q=0 時不壓縮)
+func TestSkipCompression(t *testing.T) {
+	body := strings.Repeat("fastener,", 256) // 2304 bytes，超過 minLength
+	const minLength = 1024
+	e := echo.New()
+	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{Skipper: SkipCompression, Level: 5, MinLength: minLength}))
+	for _, path := range []string{"/metrics", APIV1Prefix + "/customers", APIV1Prefix + "/customers/export", APIV1Prefix + "/product_definitions/:id/image", APIV1Prefix + "/events", LegacyAPIPrefix + "/customers/export"} {
+		e.GET(path, func(c echo.Context) error {
+			if c.QueryParam("small") != "" {
+				return c.String(http.StatusOK, body[:minLength-1])
+			}
+			return c.String(http.StatusOK, body)
+		})
+	}
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{path: APIV1Prefix + "/customers", acceptEncoding: "gzip", wantGzip: true},
+		{path: APIV1Prefix + "/customers", acceptEncoding: "br;q=1.0, gzip;q=0.8", wantGzip: true},
+		{path: APIV1Prefix + "/customers", acceptEncoding: "", wantGzip: false},
+		{path: APIV1Prefix + "/customers", acceptEncoding: "br", wantGzip: false},
+		{path: APIV1Prefix + "/customers?small=1", acceptEncoding: "gzip", wantGzip: false}, // 小於 GZIP_MIN_LENGTH
+		{path: "/metrics", acceptEncoding: "gzip", wantGzip: false},
+		{path: APIV1Prefix + "/product_definitions/7/image", acceptEncoding: "gzip", wantGzip: false},
+		{path: APIV1Prefix + "/events", acceptEncoding: "gzip", wantGzip: false},
+		{path: APIV1Prefix + "/customers/export", acceptEncoding: "gzip, deflate", wantGzip: true},
+		{path: LegacyAPIPrefix + "/customers/export", acceptEncoding: "gzip", wantGzip: true},
+		{path: APIV1Prefix + "/customers/export", acceptEncoding: "", wantGzip: false},
+		{path: APIV1Prefix + "/customers/export", acceptEncoding: "*", wantGzip: false},
+		{path: APIV1Prefix + "/customers/export", acceptEncoding: "gzip;q=0", wantGzip: false},
+		{path: APIV1Prefix + "/customers/export", acceptEncoding: "gzip;q=0.000", wantGzip: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.acceptEncoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set(echo.HeaderAcceptEncoding, tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+
+			gzipped := rec.Header().Get(echo.HeaderContentEncoding) == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", rec.Header().Get(echo.HeaderContentEncoding), tt.wantGzip)
+			}
+			got := rec.Body.Bytes()
+			if gzipped {
+				reader, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err = io.ReadAll(reader); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := body
+			if strings.HasSuffix(tt.path, "?small=1") {
+				want = body[:minLength-1]
+			}
+			if string(got) != want {
+				t.Errorf("body has %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/handler"
+	"github.com/wac0705/fastener-api/jobs"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/openapi"
+)
+
+// 文件路由的路徑 (僅在非 production 環境註冊，見 RegisterDocsRoutes)
+const (
+	OpenAPISpecPath = "/api/openapi.json"
+	SwaggerUIPath   = "/api/docs"
+)
+
+// APITitle OpenAPI 文件的標題
+const APITitle = "Fastener API"
+
+// RegisterDocsRoutes 註冊 OpenAPI 文件與 Swagger UI (無需身份驗證)
+func RegisterDocsRoutes(e *echo.Echo, docsHandler *handler.DocsHandler) {
+	e.GET(OpenAPISpecPath, docsHandler.OpenAPISpec)
+	e.GET(SwaggerUIPath, docsHandler.SwaggerUI)
+}
+
+// loginResponse POST /api/v1/login 的回應 (與 AuthHandler.Login 的匿名 struct 相同)
+type loginResponse struct {
+	AccessToken  string          `json:"access_token"`
+	RefreshToken string          `json:"refresh_token"`
+	Account      *models.Account `json:"account"`
+}
+
+// customerFilterParams 客戶列表與匯出共用的篩選參數
+var customerFilterParams = []openapi.Parameter{
+	{Name: "q", Description: "模糊搜尋名稱、聯絡人與 Email"},
+	{Name: "sort", Description: "排序欄位，以逗號分隔多個欄位，前綴 - 為降序"},
+	{Name: "company_id", Description: "公司 ID；null 表示未關聯公司的客戶"},
+	{Name: "include_descendants", Type: "boolean", Description: "搭配 company_id 包含子孫公司的客戶"},
+	{Name: "include_deleted", Type: "boolean", Description: "包含已軟刪除的客戶 (需要 customer:read_deleted)"},
+	{Name: "status", Description: "prospect、active 或 inactive"},
+	{Name: "sales_rep", Description: "me 或業務代表的帳戶 ID"},
+	{Name: "currency", Description: "幣別；另支援 currency_ne 與 currency_in (逗號分隔)"},
+	{Name: "payment_terms", Description: "付款條件；另支援 payment_terms_ne 與 payment_terms_in (逗號分隔)"},
+	{Name: "created_at_gte", Description: "建立日期 (YYYY-MM-DD) 起，包含當天；另支援 _gt、_lt、_lte 與完全相符"},
+	{Name: "created_at_lte", Description: "建立日期 (YYYY-MM-DD) 迄，包含當天"},
+	{Name: "updated_at_gte", Description: "更新日期 (YYYY-MM-DD) 起，包含當天；另支援 _gt、_lt、_lte 與完全相符"},
+	{Name: "updated_at_lte", Description: "更新日期 (YYYY-MM-DD) 迄，包含當天"},
+}
+
+// importParams 匯入端點共用的參數 (也可以放在 multipart 表單中)
+var importParams = []openapi.Parameter{
+	{Name: "dry_run", Type: "boolean", Description: "只驗證並回報，不寫入"},
+}
+
+// APIDocs 所有路由的 OpenAPI 文件，以 openapi.Key(方法, 路由樣板) 為 key
+// 新增路由時需一併登記 (只需登記 APIV1Prefix 下的路徑，LegacyAPIPrefix 的別名由 withLegacyAliases 產生)
+// go run ./cmd/openapi -check 在有路由沒有登記時失敗
+var APIDocs = withLegacyAliases(withPublicIDParams(map[string]openapi.Operation{
+	// 健康檢查與指標
+	openapi.Key(http.MethodGet, "/healthz"): {Summary: "健康檢查 (資料庫 ping)", Tag: "health", Public: true, Response: models.HealthCheckResponse{}},
+	openapi.Key(http.MethodGet, "/livez"):   {Summary: "存活探針", Tag: "health", Public: true, Response: models.HealthCheckResponse{}},
+	openapi.Key(http.MethodGet, "/readyz"):  {Summary: "就緒探針，啟動完成前返回 503", Tag: "health", Public: true, Response: models.HealthCheckResponse{}},
+	openapi.Key(http.MethodGet, "/metrics"): {Summary: "Prometheus 指標", Description: "設定 METRICS_USERNAME 與 METRICS_PASSWORD 時需要 Basic Auth", Tag: "health", Public: true, ResponseContentType: "text/plain"},
+
+	// 文件
+	openapi.Key(http.MethodGet, OpenAPISpecPath): {Summary: "OpenAPI 文件", Tag: "docs", Public: true, ResponseContentType: echo.MIMEApplicationJSON},
+	openapi.Key(http.MethodGet, SwaggerUIPath):   {Summary: "Swagger UI", Tag: "docs", Public: true, ResponseContentType: echo.MIMETextHTML},
+
+	// 身份驗證
+	openapi.Key(http.MethodPost, APIV1Prefix+"/login"):         {Summary: "登入，返回 Access Token 與 Refresh Token", Tag: "auth", Public: true, Request: models.LoginRequest{}, Response: loginResponse{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/register"):      {Summary: "註冊帳戶", Tag: "auth", Public: true, Request: models.RegisterRequest{}, Status: http.StatusCreated, Location: true, Response: models.Account{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/refresh-token"): {Summary: "以 Refresh Token 換發 Access Token", Tag: "auth", Public: true, Request: models.RefreshTokenRequest{}, Response: map[string]string{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/my-profile"):     {Summary: "目前登入帳戶的資料", Tag: "auth", Response: models.Account{}},
+
+	// 帳戶
+	openapi.Key(http.MethodGet, APIV1Prefix+"/accounts"):               {Summary: "帳戶列表", Paginated: true, Response: models.Account{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/accounts/:id"):           {Summary: "取得帳戶", Response: models.Account{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/accounts"):              {Summary: "新增帳戶", Request: models.Account{}, Status: http.StatusCreated, Location: true, Response: models.Account{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/accounts/:id"):           {Summary: "更新帳戶", Request: models.Account{}, Response: models.Account{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/accounts/:id"):        {Summary: "刪除帳戶", Status: http.StatusNoContent},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/accounts/:id/restore"):  {Summary: "還原已軟刪除的帳戶", Response: models.Account{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/accounts/:id/password"): {Summary: "更新帳戶密碼", Request: models.UpdatePasswordRequest{}, Status: http.StatusNoContent},
+
+	// 公司
+	openapi.Key(http.MethodGet, APIV1Prefix+"/companies"):              {Summary: "公司列表", Response: []models.Company{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/companies/tree"):         {Summary: "公司集團樹狀結構", Response: []models.CompanyTreeNode{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/companies/stats"):        {Summary: "各公司客戶統計", Paginated: true, Response: models.CompanyStats{}, Query: []openapi.Parameter{{Name: "sort", Description: "排序欄位，前綴 - 為降序"}}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/companies/:id"):          {Summary: "取得公司", Response: models.Company{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/companies"):             {Summary: "新增公司", Request: models.Company{}, Status: http.StatusCreated, Location: true, Response: models.Company{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/companies/:id"):          {Summary: "更新公司", Request: models.Company{}, Response: models.Company{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/companies/:id"):       {Summary: "刪除公司", Status: http.StatusNoContent, Query: []openapi.Parameter{{Name: "children", Description: "子公司的處理方式"}}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/companies/:id/restore"): {Summary: "還原已軟刪除的公司", Response: models.Company{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/companies/import"):      {Summary: "以 CSV 或 XLSX 匯入公司", Upload: true, Query: importParams, Response: models.ImportResult{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/companies/:id/merge"):   {Summary: "將重複的公司合併到此公司", Request: models.CompanyMergeRequest{}, Response: models.CompanyMergeResult{}},
+
+	// 客戶
+	openapi.Key(http.MethodGet, APIV1Prefix+"/customers"):                   {Summary: "客戶列表", Paginated: true, Response: models.Customer{}, Query: customerFilterParams},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/customers/export"):            {Summary: "以 CSV 匯出客戶", ResponseContentType: "text/csv", Query: append([]openapi.Parameter{{Name: "format", Description: "只支援 csv"}}, customerFilterParams...)},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/customers/import"):           {Summary: "以 CSV 或 XLSX 匯入客戶", Upload: true, Query: importParams, Response: models.ImportResult{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/customers/check-duplicates"): {Summary: "建立前檢查可能重複的客戶", Request: models.CustomerDuplicateCheckRequest{}, Response: []models.CustomerDuplicateCandidate{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/customers/code/:code"):        {Summary: "以客戶代碼取得客戶", Response: models.Customer{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/customers/:id"):               {Summary: "取得客戶", Response: models.Customer{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/customers"):                  {Summary: "新增客戶", Request: models.Customer{}, Status: http.StatusCreated, Location: true, Response: models.Customer{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/customers/:id"):               {Summary: "更新客戶", Request: models.Customer{}, Response: models.Customer{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/customers/:id"):            {Summary: "軟刪除客戶", Status: http.StatusNoContent},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/customers/:id/restore"):      {Summary: "還原已軟刪除的客戶", Response: models.Customer{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/customers/:id/history"):       {Summary: "客戶的欄位變更歷史", Paginated: true, Response: models.CustomerHistory{}, Query: []openapi.Parameter{{Name: "field", Description: "只返回該欄位的變更"}}},
+
+	openapi.Key(http.MethodGet, APIV1Prefix+"/customers/:id/addresses"):                {Summary: "客戶地址列表", Response: []models.CustomerAddress{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/customers/:id/addresses"):               {Summary: "新增客戶地址", Request: models.CustomerAddress{}, Status: http.StatusCreated, Response: models.CustomerAddress{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/customers/:id/addresses/:address_id"):    {Summary: "更新客戶地址", Request: models.CustomerAddress{}, Response: models.CustomerAddress{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/customers/:id/addresses/:address_id"): {Summary: "刪除客戶地址", Status: http.StatusNoContent},
+
+	openapi.Key(http.MethodGet, APIV1Prefix+"/customers/:id/notes"):             {Summary: "客戶備註", Paginated: true, Response: models.CustomerNote{}, Query: []openapi.Parameter{{Name: "pinned_first", Type: "boolean", Description: "置頂的備註排在最前面"}}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/customers/:id/notes"):            {Summary: "新增客戶備註", Request: models.CustomerNote{}, Status: http.StatusCreated, Response: models.CustomerNote{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/customers/:id/notes/:note_id"): {Summary: "刪除客戶備註", Status: http.StatusNoContent},
+
+	// 選單
+	openapi.Key(http.MethodGet, APIV1Prefix+"/menus"):               {Summary: "選單列表", Response: []models.Menu{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/menus/:id"):           {Summary: "取得選單", Response: models.Menu{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/menus"):              {Summary: "新增選單", Request: models.Menu{}, Status: http.StatusCreated, Location: true, Response: models.Menu{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/menus/:id"):           {Summary: "更新選單", Request: models.Menu{}, Response: models.Menu{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/menus/:id"):        {Summary: "刪除選單", Status: http.StatusNoContent},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/roles/:roleID/menus"): {Summary: "角色可訪問的選單", Tag: "menus", Response: []models.Menu{}},
+
+	// 產品類別
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_categories"):        {Summary: "產品類別列表", Response: []models.ProductCategory{}, Query: []openapi.Parameter{{Name: "include", Description: "以逗號分隔的額外資料"}, {Name: "include_discontinued", Type: "boolean"}}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_categories/tree"):   {Summary: "產品類別樹狀結構", Response: []models.ProductCategory{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_categories/:id"):    {Summary: "取得產品類別", Response: models.ProductCategory{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_categories"):       {Summary: "新增產品類別", Request: models.ProductCategory{}, Status: http.StatusCreated, Location: true, Response: models.ProductCategory{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/product_categories/:id"):    {Summary: "更新產品類別", Request: models.ProductCategory{}, Response: models.ProductCategory{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/product_categories/:id"): {Summary: "刪除產品類別", Status: http.StatusNoContent, Query: []openapi.Parameter{{Name: "children", Description: "子類別與產品的處理方式"}, {Name: "reassign_to", Type: "integer", Description: "產品移至的類別 ID"}}},
+
+	// 產品定義
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions"): {Summary: "產品定義列表", Paginated: true, Response: models.ProductDefinition{}, Query: []openapi.Parameter{
+		{Name: "q", Description: "模糊搜尋名稱與描述"},
+		{Name: "search", Description: "全文搜尋，結果依相關度排序"},
+		{Name: "sort", Description: "name、price、standard 或 created_at，前綴 - 為降序"},
+		{Name: "category_id", Type: "integer"},
+		{Name: "descendants", Type: "boolean", Description: "搭配 category_id 包含子類別的產品"},
+		{Name: "standard", Description: "標準代號，例如 DIN 933"},
+		{Name: "currency", Description: "以該幣別 (ISO 4217) 報價"},
+		{Name: "include_discontinued", Type: "boolean"},
+		{Name: "variants", Description: "collapse 或 expand"},
+		{Name: "price_min"},
+		{Name: "price_max"},
+		{Name: "unit"},
+		{Name: "has_image", Type: "boolean"},
+	}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/standards"):               {Summary: "已使用的標準代號", Response: []string{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id"):                     {Summary: "取得產品定義", Response: models.ProductDefinition{}, Query: []openapi.Parameter{{Name: "currency", Description: "以該幣別 (ISO 4217) 報價"}, {Name: "version_at", Description: "RFC 3339 時間，返回當時的版本"}}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions"):                        {Summary: "新增產品定義", Request: models.ProductDefinition{}, Status: http.StatusCreated, Location: true, Response: models.ProductDefinition{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions/import"):                 {Summary: "以 CSV 或 XLSX 匯入產品定義", Upload: true, Query: append([]openapi.Parameter{{Name: "create_categories", Type: "boolean"}, {Name: "partial", Type: "boolean"}}, importParams...), Response: models.ImportResult{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions/bulk-price-update"):      {Summary: "批次調整產品價格", Request: models.ProductBulkPriceUpdateRequest{}, Response: models.ProductBulkPriceUpdateResponse{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/product_definitions/:id"):                     {Summary: "更新產品定義", Request: models.ProductDefinition{}, Response: models.ProductDefinition{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/product_definitions/:id"):                  {Summary: "停售產品定義", Status: http.StatusNoContent},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions/:id/clone"):              {Summary: "複製產品定義", Request: models.ProductDefinitionCloneRequest{}, Status: http.StatusCreated, Location: true, Response: models.ProductDefinition{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions/:id/reactivate"):         {Summary: "重新啟用已停售的產品定義", Response: models.ProductDefinition{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/history"):             {Summary: "產品定義的欄位變更歷史", Paginated: true, Response: models.ProductDefinitionHistory{}, Query: []openapi.Parameter{{Name: "field", Description: "只返回該欄位的變更"}}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/prices"):              {Summary: "產品的各幣別價格", Tag: "product_prices", Response: []models.ProductPrice{}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions/:id/prices"):             {Summary: "新增幣別價格", Tag: "product_prices", Request: models.ProductPrice{}, Status: http.StatusCreated, Response: models.ProductPrice{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/product_definitions/:id/prices/:price_id"):    {Summary: "更新幣別價格", Tag: "product_prices", Request: models.ProductPrice{}, Response: models.ProductPrice{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/product_definitions/:id/prices/:price_id"): {Summary: "刪除幣別價格", Tag: "product_prices", Status: http.StatusNoContent},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/price-tiers"):         {Summary: "數量分級價格", Tag: "product_prices", Response: []models.ProductPriceTier{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/product_definitions/:id/price-tiers"):         {Summary: "取代數量分級價格", Tag: "product_prices", Request: models.ProductPriceTiersRequest{}, Response: models.ProductPriceTiersResponse{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/price"):               {Summary: "依數量計算單價", Tag: "product_prices", Response: models.ProductQuantityPrice{}, Query: []openapi.Parameter{{Name: "qty", Type: "integer", Required: true}}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/units"):               {Summary: "產品的換算單位", Tag: "product_units", Response: []models.ProductUnit{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/product_definitions/:id/units"):               {Summary: "取代產品的換算單位", Tag: "product_units", Request: models.ProductUnitsRequest{}, Response: []models.ProductUnit{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/convert"):             {Summary: "單位換算", Tag: "product_units", Response: models.ProductUnitConversion{}, Query: []openapi.Parameter{{Name: "from", Required: true}, {Name: "to", Required: true}, {Name: "value", Type: "number", Required: true}}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/variants"):            {Summary: "產品的變體", Response: []models.ProductDefinition{}, Query: []openapi.Parameter{{Name: "include_discontinued", Type: "boolean"}}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions/:id/variants/generate"):  {Summary: "依規格範圍產生變體", Request: models.ProductVariantGenerateRequest{}, Status: http.StatusCreated, Response: models.ProductVariantGenerateResponse{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/product_definitions/:id/image"):               {Summary: "取得產品圖片", ResponseContentType: "image/*"},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/product_definitions/:id/image"):              {Summary: "上傳產品圖片", Upload: true, Response: models.ProductDefinition{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/product_definitions/:id/image"):            {Summary: "刪除產品圖片", Status: http.StatusNoContent},
+
+	// 角色選單關聯
+	openapi.Key(http.MethodGet, APIV1Prefix+"/role_menus"):              {Summary: "角色選單關聯列表", Response: []models.RoleMenuDetail{}, Query: []openapi.Parameter{{Name: "role_id", Type: "integer"}, {Name: "menu_id", Type: "integer"}}},
+	openapi.Key(http.MethodPost, APIV1Prefix+"/role_menus"):             {Summary: "新增角色選單關聯", Request: models.RoleMenu{}, Status: http.StatusCreated, Response: models.RoleMenu{}},
+	openapi.Key(http.MethodDelete, APIV1Prefix+"/role_menus/:id1/:id2"): {Summary: "刪除角色選單關聯 (角色 ID、選單 ID)", Status: http.StatusNoContent},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/role_menus/:id1/:id2"):    {Summary: "更新角色選單關聯 (角色 ID、選單 ID)", Request: models.RoleMenu{}, Response: models.RoleMenu{}},
+	openapi.Key(http.MethodPut, APIV1Prefix+"/roles/:roleID/menus"):     {Summary: "整組取代角色的選單", Tag: "role_menus", Request: models.RoleMenusReplaceRequest{}, Response: []models.Menu{}},
+
+	// 角色
+	openapi.Key(http.MethodPost, APIV1Prefix+"/roles/:roleID/clone"):      {Summary: "複製角色 (含權限與選單)", Request: models.RoleCloneRequest{}, Status: http.StatusCreated, Location: true, Response: models.Role{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/roles/:roleID/permissions"): {Summary: "角色擁有的權限", Response: []models.Permission{}},
+	openapi.Key(http.MethodGet, APIV1Prefix+"/permissions"):               {Summary: "權限目錄 (所有已登記的權限，依名稱排序)", Response: []models.Permission{}},
+
+	// 稽核記錄
+	openapi.Key(http.MethodGet, APIV1Prefix+"/audit"): {Summary: "寫入操作的稽核記錄", Paginated: true, Response: models.AuditLog{}, Query: []openapi.Parameter{
+		{Name: "actor_id", Type: "integer", Description: "發出請求的帳戶 ID (支援 _ne、_in)"},
+		{Name: "method", Description: "POST、PUT、PATCH 或 DELETE (支援 _ne、_in)"},
+		{Name: "status", Type: "integer", Description: "回應狀態碼 (支援 _gt、_gte、_lt、_lte、_ne、_in)"},
+		{Name: "created_at_gte", Description: "YYYY-MM-DD，包含當天"},
+		{Name: "created_at_lte", Description: "YYYY-MM-DD，包含當天"},
+		{Name: "path_prefix", Description: "實際路徑的前綴，例如 /api/v1/customers"},
+	}},
+
+	// 背景工作
+	openapi.Key(http.MethodGet, APIV1Prefix+"/admin/jobs"): {Summary: "背景工作的排程與最近一次執行的結果", Response: []jobs.Status{}},
+
+	// 讀取緩存
+	openapi.Key(http.MethodPost, APIV1Prefix+"/admin/cache/flush"): {Summary: "清除所有讀取緩存 (選單、角色與產品類別)", Status: http.StatusNoContent},
+
+	// 事件串流
+	openapi.Key(http.MethodGet, APIV1Prefix+"/events"): {Summary: "以 Server-Sent Events 訂閱角色選單與權限的變更", Description: "每個事件為 `event: menus_changed` (或 `permissions_changed`) 與 JSON 的 `data: {\"type\":\"menus_changed\",\"role_id\":1}`；帳戶的角色改變時 `permissions_changed` 另帶 `account_id`；沒有事件時定期送出註解行 (heartbeat)。每個帳戶同時連線數超過 EVENTS_MAX_STREAMS_PER_ACCOUNT 時返回 429 (TOO_MANY_STREAMS)", ResponseContentType: "text/event-stream"},
+}))
+
+// publicIDResources 有 public_id 的資源 (不含版本前綴的第一段路徑)，這些路由的 publicIDPathParams 也接受 UUID (見 handler.pathID)
+var publicIDResources = []string{"accounts", "companies", "customers", "menus", "roles", "role_menus", "product_definitions"}
+
+// publicIDPathParams 以資源 ID 為值的路徑參數；地址、備註與價格等子資源的參數 (例如 :address_id) 只接受整數
+var publicIDPathParams = []string{"id", "roleID", "id1", "id2"}
+
+// withPublicIDParams 為 publicIDResources 下的路由標記也接受 UUID 的路徑參數 (Operation.PublicIDParams)
+func withPublicIDParams(docs map[string]openapi.Operation) map[string]openapi.Operation {
+	for key, op := range docs {
+		_, path, _ := strings.Cut(key, " ")
+		resource, _, _ := strings.Cut(strings.TrimPrefix(path, APIV1Prefix+"/"), "/")
+		if !slices.Contains(publicIDResources, resource) {
+			continue
+		}
+		for _, segment := range strings.Split(path, "/") {
+			if name, ok := strings.CutPrefix(segment, ":"); ok && slices.Contains(publicIDPathParams, name) {
+				op.PublicIDParams = append(op.PublicIDParams, name)
+			}
+		}
+		docs[key] = op
+	}
+	return docs
+}
+
+// withLegacyAliases 為 APIV1Prefix 下的每個路由加上 LegacyAPIPrefix 別名的文件，別名標記為 deprecated
+func withLegacyAliases(docs map[string]openapi.Operation) map[string]openapi.Operation {
+	result := make(map[string]openapi.Operation, len(docs)*2)
+	for key, op := range docs {
+		result[key] = op
+		method, path, _ := strings.Cut(key, " ")
+		if !strings.HasPrefix(path, APIV1Prefix+"/") {
+			continue
+		}
+		op.Deprecated = true
+		result[openapi.Key(method, LegacyAPIPrefix+strings.TrimPrefix(path, APIV1Prefix))] = op
+	}
+	return result
+}
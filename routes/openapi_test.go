@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/wac0705/fastener-api/openapi"
+)
+
+// TestAPIDocsMatchRoutes 與 go run ./cmd/openapi -check 相同的檢查：每個註冊的路由都登記在 APIDocs，APIDocs 也沒有不存在的路由
+func TestAPIDocsMatchRoutes(t *testing.T) {
+	routes := NewRouteTable().Routes()
+	for _, key := range openapi.MissingRoutes(routes, APIDocs) {
+		t.Errorf("route %s is missing from the OpenAPI spec, add it to routes.APIDocs", key)
+	}
+	for _, key := range openapi.StaleOperations(routes, APIDocs) {
+		t.Errorf("routes.APIDocs documents %s, which is not a registered route", key)
+	}
+}
+
+// TestOpenAPISpecContainsEveryRoute 產生的文件可以編碼為 JSON，且每個可文件化的路由都出現在 paths 中
+func TestOpenAPISpecContainsEveryRoute(t *testing.T) {
+	routes := NewRouteTable().Routes()
+	spec := openapi.Build(openapi.Info{Title: APITitle, Version: "test"}, routes, APIDocs)
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatalf("spec cannot be encoded as JSON: %v", err)
+	}
+
+	paths := spec["paths"].(map[string]interface{})
+	for _, route := range routes {
+		if _, documented := APIDocs[openapi.Key(route.Method, route.Path)]; !documented {
+			continue // 非 HTTP 方法的路由 (RouteNotFound)，或上面的測試已回報
+		}
+		item, _ := paths[specPath(route.Path)].(map[string]interface{})
+		if _, ok := item[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("spec has no %s operation for %s", route.Method, route.Path)
+		}
+	}
+}
+
+// specPath 將 Echo 的路由樣板轉換為 OpenAPI 的路徑 (/accounts/:id 為 /accounts/{id})
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/handler"
+)
+
+// NewRouteTable 以零值的 handler 註冊與伺服器相同的路由，供不連線資料庫的工具使用 (cmd/openapi、cmd/seed)
+// 返回的 Echo 實例只用於讀取路由樣板，並登記各路由所需的權限 (authz.RegisteredPermissions)，不可用於處理請求
+func NewRouteTable() *echo.Echo {
+	e := echo.New()
+	RegisterAPIRoutes(e,
+		new(handler.AuthHandler),
+		new(handler.AccountHandler),
+		new(handler.CompanyHandler),
+		new(handler.CustomerHandler),
+		new(handler.MenuHandler),
+		new(handler.ProductDefinitionHandler),
+		new(handler.RoleMenuHandler),
+		new(handler.RoleHandler),
+		new(handler.AuditHandler),
+		new(handler.JobHandler),
+		new(handler.EventsHandler),
+		new(handler.CacheHandler),
+		new(handler.HealthHandler),
+		nil,           // 權限服務只在請求時使用
+		nil,           // 稽核記錄只在請求時產生
+		"route-table", // JWT Secret 只在請求時使用
+		0,             // 上傳路由的請求內容大小上限只在請求時使用
+		time.Time{},   // Sunset 標頭只在請求時使用
+	)
+	RegisterMetricsRoute(e, new(handler.MetricsHandler), "", "")
+	RegisterDocsRoutes(e, new(handler.DocsHandler))
+	return e
+}
+
+// LegacyAliasMismatches 比對 APIV1Prefix 與 LegacyAPIPrefix 下的路由，返回只出現在其中一個前綴 (或 handler 不同) 的路由 (依序排列)
+// 兩者由同一個 RegisterAPIGroup 註冊，應完全相同；文件路由 (OpenAPISpecPath、SwaggerUIPath) 不在 API 分組中，不列入比對
+func LegacyAliasMismatches(routes []*echo.Route) []string {
+	v1 := map[string]bool{}
+	legacy := map[string]bool{}
+	for _, route := range routes {
+		if route.Method == echo.RouteNotFound || route.Path == OpenAPISpecPath || route.Path == SwaggerUIPath {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(route.Path, APIV1Prefix+"/"):
+			v1[route.Method+" "+strings.TrimPrefix(route.Path, APIV1Prefix)+" "+route.Name] = true
+		case strings.HasPrefix(route.Path, LegacyAPIPrefix+"/"):
+			legacy[route.Method+" "+strings.TrimPrefix(route.Path, LegacyAPIPrefix)+" "+route.Name] = true
+		}
+	}
+
+	mismatches := []string{}
+	for key := range v1 {
+		if !legacy[key] {
+			mismatches = append(mismatches, "missing "+LegacyAPIPrefix+" alias: "+key)
+		}
+	}
+	for key := range legacy {
+		if !v1[key] {
+			mismatches = append(mismatches, "missing "+APIV1Prefix+" route: "+key)
+		}
+	}
+	sort.Strings(mismatches)
+	return mismatches
+}
@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestLegacyAliasParity /api 下的別名與 /api/v1 的路由 (方法、路徑與 handler) 完全相同
+func TestLegacyAliasParity(t *testing.T) {
+	routes := NewRouteTable().Routes()
+	for _, mismatch := range LegacyAliasMismatches(routes) {
+		t.Error(mismatch)
+	}
+
+	v1, legacy := 0, 0
+	for _, route := range routes {
+		if route.Method == echo.RouteNotFound || route.Path == OpenAPISpecPath || route.Path == SwaggerUIPath {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(route.Path, APIV1Prefix+"/"):
+			v1++
+		case strings.HasPrefix(route.Path, LegacyAPIPrefix+"/"):
+			legacy++
+		}
+	}
+	if v1 == 0 || v1 != legacy {
+		t.Errorf("%s has %d routes and %s has %d, want the same non-zero count", APIV1Prefix, v1, LegacyAPIPrefix, legacy)
+	}
+}
+
+// TestLegacyAliasMiddleware 兩個前綴套用相同的 JWT 驗證與未知路由處理，只有 /api 的回應帶有棄用標頭
+func TestLegacyAliasMiddleware(t *testing.T) {
+	e := NewRouteTable()
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{method: http.MethodGet, path: "/customers", wantStatus: http.StatusUnauthorized},                 // 受保護的路由，沒有 Access Token
+		{method: http.MethodDelete, path: "/accounts/1", wantStatus: http.StatusUnauthorized},             // 受保護的路由，沒有 Access Token
+		{method: http.MethodGet, path: "/no-such-resource", wantStatus: http.StatusNotFound},              // 未知的路徑不經過 JWT 驗證
+		{method: http.MethodGet, path: "/product_definitions/1/nothing", wantStatus: http.StatusNotFound}, // 已知資源下未知的路徑
+	}
+	for _, tt := range tests {
+		for _, prefix := range []string{APIV1Prefix, LegacyAPIPrefix} {
+			t.Run(tt.method+" "+prefix+tt.path, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, httptest.NewRequest(tt.method, prefix+tt.path, nil))
+				if rec.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+				}
+
+				deprecated := rec.Header().Get("Deprecation")
+				if prefix == APIV1Prefix && deprecated != "" {
+					t.Errorf("Deprecation = %q on %s, want none", deprecated, APIV1Prefix)
+				}
+				if prefix == LegacyAPIPrefix && tt.wantStatus != http.StatusNotFound {
+					if deprecated != "true" {
+						t.Errorf("Deprecation = %q, want true", deprecated)
+					}
+					if want := "<" + APIV1Prefix + tt.path + `>; rel="successor-version"`; rec.Header().Get("Link") != want {
+						t.Errorf("Link = %q, want %q", rec.Header().Get("Link"), want)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// Menu 種子選單項目 (以 path 判斷是否已存在)
+type Menu struct {
+	Name         string
+	Path         string
+	DisplayOrder int
+}
+
+// AdminAccount 要建立的管理員帳戶，帳戶已存在時只重設密碼
+type AdminAccount struct {
+	Username string
+	Password string
+}
+
+// Plan 種子資料內容；RolePermissions 與 RoleMenus 以角色名稱對應授予的權限名稱與選單路徑
+type Plan struct {
+	Roles           []string
+	Permissions     []string
+	Menus           []Menu
+	RolePermissions map[string][]string
+	RoleMenus       map[string][]string
+	Admin           *AdminAccount // nil 時不處理管理員帳戶
+}
+
+// Result 單一資料表新建立與已存在的筆數
+type Result struct {
+	Table    string
+	Created  int
+	Existing int
+}
+
+// Summary 種子執行結果
+type Summary struct {
+	Results    []Result
+	AdminReset bool // 管理員帳戶已存在且已重設密碼 (dry-run 時不重設)
+	DryRun     bool
+}
+
+// DefaultRoles 預設角色
+var DefaultRoles = []string{"admin", "user"}
+
+// DefaultMenus 預設選單，與 000001 遷移的初始選單相同
+var DefaultMenus = []Menu{
+	{Name: "儀表板", Path: "/dashboard", DisplayOrder: 10},
+	{Name: "公司管理", Path: "/dashboard/companies", DisplayOrder: 20},
+	{Name: "客戶管理", Path: "/dashboard/customers", DisplayOrder: 30},
+	{Name: "產品定義", Path: "/dashboard/product-definitions", DisplayOrder: 40},
+	{Name: "帳戶管理", Path: "/dashboard/accounts", DisplayOrder: 50},
+	{Name: "選單管理", Path: "/dashboard/menus", DisplayOrder: 60},
+	{Name: "角色選單", Path: "/dashboard/role-menus", DisplayOrder: 70},
+}
+
+// DefaultPlan 以路由登記的權限建立預設種子資料：admin 擁有所有權限與選單，user 只能查看自己的資料與儀表板
+func DefaultPlan(permissions []string) Plan {
+	menuPaths := make([]string, 0, len(DefaultMenus))
+	for _, menu := range DefaultMenus {
+		menuPaths = append(menuPaths, menu.Path)
+	}
+	return Plan{
+		Roles:       DefaultRoles,
+		Permissions: permissions,
+		Menus:       DefaultMenus,
+		RolePermissions: map[string][]string{
+			"admin": permissions,
+			"user":  {"account:read_own_profile"},
+		},
+		RoleMenus: map[string][]string{
+			"admin": menuPaths,
+			"user":  {"/dashboard"},
+		},
+	}
+}
+
+// errDryRun 由 dry-run 的交易函式返回，使 WithinTx 回滾
+var errDryRun = errors.New("seed dry run")
+
+// Run 在單一交易中寫入種子資料，已存在的資料列不會被修改，因此可重複執行
+// dryRun 為 true 時在交易結束前回滾，返回的筆數即為實際執行時會新增的資料；logger 注入到建立的 Repository
+// 角色的權限與選單以 Repository 的多列 INSERT 批次寫入 (見 PermissionRepository.AssignPermissionsBatch)
+func Run(ctx context.Context, database *sql.DB, accountRepo repository.AccountRepository, plan Plan, dryRun bool, logger *zap.Logger) (*Summary, error) {
+	permissionRepo := repository.NewPermissionRepository(database, logger)
+	roleMenuRepo := repository.NewRoleMenuRepository(database, logger)
+	summary := &Summary{DryRun: dryRun}
+	adminExists := false
+	err := db.NewTxManager(database, db.DefaultRetryConfig).WithinTx(ctx, func(ctx context.Context) error {
+		tx, _ := db.TxFromContext(ctx)
+		add := func(table string, query string, argsList [][]interface{}) error {
+			result := Result{Table: table}
+			for _, args := range argsList {
+				created, err := insertIgnore(ctx, tx, query, args...)
+				if err != nil {
+					return fmt.Errorf("failed to seed %s %v: %w", table, args, err)
+				}
+				if created {
+					result.Created++
+				} else {
+					result.Existing++
+				}
+			}
+			summary.Results = append(summary.Results, result)
+			return nil
+		}
+
+		var roles, permissions, menus [][]interface{}
+		for _, role := range plan.Roles {
+			roles = append(roles, []interface{}{role})
+		}
+		for _, permission := range plan.Permissions {
+			permissions = append(permissions, []interface{}{permission, models.PermissionDescription(permission)})
+		}
+		for _, menu := range plan.Menus {
+			menus = append(menus, []interface{}{menu.Name, menu.Path, menu.DisplayOrder})
+		}
+
+		steps := []struct {
+			table    string
+			query    string
+			argsList [][]interface{}
+		}{
+			{"roles", `INSERT INTO roles (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, roles},
+			{"permissions", `INSERT INTO permissions (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`, permissions},
+			{"menus", `INSERT INTO menus (name, path, display_order) VALUES ($1, $2, $3) ON CONFLICT (path) DO NOTHING`, menus},
+		}
+		for _, step := range steps {
+			if err := add(step.table, step.query, step.argsList); err != nil {
+				return err
+			}
+		}
+
+		// 角色、權限與選單都已在交易中，以名稱 (選單為 path) 取得 ID 後批次寫入關聯
+		roleIDs, err := idsByKey(ctx, tx, `SELECT name, id FROM roles`)
+		if err != nil {
+			return fmt.Errorf("failed to load role IDs: %w", err)
+		}
+		permissionIDs, err := idsByKey(ctx, tx, `SELECT name, id FROM permissions`)
+		if err != nil {
+			return fmt.Errorf("failed to load permission IDs: %w", err)
+		}
+		menuIDs, err := idsByKey(ctx, tx, `SELECT path, id FROM menus`)
+		if err != nil {
+			return fmt.Errorf("failed to load menu IDs: %w", err)
+		}
+		var rolePermissions []models.RolePermission
+		var roleMenus []models.RoleMenu
+		for _, role := range plan.Roles {
+			for _, permission := range plan.RolePermissions[role] {
+				permissionID, ok := permissionIDs[permission]
+				if !ok {
+					return fmt.Errorf("permission '%s' granted to role '%s' is not in the plan", permission, role)
+				}
+				rolePermissions = append(rolePermissions, models.RolePermission{RoleID: roleIDs[role], PermissionID: permissionID})
+			}
+			for _, path := range plan.RoleMenus[role] {
+				menuID, ok := menuIDs[path]
+				if !ok {
+					return fmt.Errorf("menu '%s' granted to role '%s' is not in the plan", path, role)
+				}
+				roleMenus = append(roleMenus, models.RoleMenu{RoleID: roleIDs[role], MenuID: menuID})
+			}
+		}
+		created, err := permissionRepo.AssignPermissionsBatch(ctx, rolePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to seed role_permissions: %w", err)
+		}
+		summary.Results = append(summary.Results, Result{Table: "role_permissions", Created: created, Existing: len(rolePermissions) - created})
+		created, err = roleMenuRepo.CreateBatch(ctx, roleMenus)
+		if err != nil {
+			return fmt.Errorf("failed to seed role_menus: %w", err)
+		}
+		summary.Results = append(summary.Results, Result{Table: "role_menus", Created: created, Existing: len(roleMenus) - created})
+
+		// 管理員帳戶：不存在時在交易中建立，已存在時於提交後沿用 resetadmin 的邏輯重設密碼
+		if plan.Admin != nil {
+			if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM accounts WHERE username = $1 AND deleted_at IS NULL)`, plan.Admin.Username).Scan(&adminExists); err != nil {
+				return fmt.Errorf("failed to check admin account '%s': %w", plan.Admin.Username, err)
+			}
+			result := Result{Table: "accounts"}
+			if adminExists {
+				result.Existing++
+			} else {
+				hashedPassword, err := utils.HashPassword(plan.Admin.Password)
+				if err != nil {
+					return fmt.Errorf("failed to hash admin password: %w", err)
+				}
+				_, err = tx.ExecContext(ctx, `INSERT INTO accounts (username, password, role_id)
+              SELECT $1, $2, id FROM roles WHERE name = 'admin'`, plan.Admin.Username, hashedPassword)
+				if err != nil {
+					return fmt.Errorf("failed to create admin account '%s': %w", plan.Admin.Username, err)
+				}
+				result.Created++
+			}
+			summary.Results = append(summary.Results, result)
+		}
+
+		if dryRun {
+			return errDryRun // 回滾交易
+		}
+		return nil
+	}, db.NoRetry()) // 交易函式會累加 summary.Results，重新執行會重複計算
+	if dryRun && errors.Is(err, errDryRun) {
+		return summary, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run seed transaction: %w", err)
+	}
+
+	if plan.Admin != nil && adminExists {
+		if err := ResetAdminPassword(ctx, accountRepo, plan.Admin.Username, plan.Admin.Password); err != nil {
+			return summary, err
+		}
+		summary.AdminReset = true
+	}
+	return summary, nil
+}
+
+// ResetAdminPassword 雜湊新密碼並重設管理員帳戶的密碼 (cmd/resetadmin 與 cmd/seed 共用)
+func ResetAdminPassword(ctx context.Context, accountRepo repository.AccountRepository, username, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+	// 只更新 'admin' 角色的帳戶
+	if err := accountRepo.UpdateAdminPassword(ctx, username, hashedPassword); err != nil {
+		return fmt.Errorf("error updating admin password for '%s': %w", username, err)
+	}
+	return nil
+}
+
+// insertIgnore 執行 INSERT ... ON CONFLICT DO NOTHING，返回是否新增了資料列
+func insertIgnore(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// idsByKey 執行返回 (key, id) 兩個欄位的查詢，返回 key 對應的 ID
+func idsByKey(ctx context.Context, tx *sql.Tx, query string) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := map[string]int{}
+	for rows.Next() {
+		var key string
+		var id int
+		if err := rows.Scan(&key, &id); err != nil {
+			return nil, err
+		}
+		ids[key] = id
+	}
+	return ids, rows.Err()
+}
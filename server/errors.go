@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/errorreport"
+	"github.com/wac0705/fastener-api/handler"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// newHTTPErrorHandler 返回全局錯誤處理器：所有錯誤以 CustomError 格式回應並帶上 request_id，5xx 回應交給 reporter 回報
+// 驗證錯誤的訊息以 customValidator 依 Accept-Language 翻譯
+func newHTTPErrorHandler(customValidator *utils.CustomValidator, errorReporter errorreport.Reporter) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		requestID := utils.RequestID(c) // 所有錯誤回應都帶上 request_id，方便以回應查詢日誌
+		defer func() {
+			// 回應為 5xx 時回報錯誤；4xx (包含 CustomError 的 400、404 等) 是用戶端的問題，一律不回報
+			if status := c.Response().Status; status >= http.StatusInternalServerError {
+				errorReporter.Report(errorreport.NewEvent(c, err, status))
+			}
+		}()
+		if c.Response().Committed {
+			// 回應已經開始送出 (例如串流匯出途中失敗)，無法再寫入錯誤內容，只記錄日誌
+			utils.Logger(c).Warn("Error after response was committed", zap.Error(err), zap.String("path", c.Path()))
+			return
+		}
+		var he *echo.HTTPError
+		if errors.As(err, &he) { // 如果是 Echo 內部錯誤
+			// Router 或中介軟體直接返回的 404、405 (未經過 handler.RouteNotFound、handler.MethodNotAllowed)，同樣帶上錯誤代碼與請求路徑
+			switch he {
+			case echo.ErrNotFound:
+				c.JSON(http.StatusNotFound, utils.NewRouteNotFoundError(c.Request().Method, c.Request().URL.Path).WithRequestID(requestID))
+				return
+			case echo.ErrMethodNotAllowed:
+				allow := handler.AllowedMethods(c)
+				if len(allow) > 0 {
+					c.Response().Header().Set(echo.HeaderAllow, strings.Join(allow, ", "))
+				}
+				c.JSON(http.StatusMethodNotAllowed, utils.NewMethodNotAllowedError(c.Request().Method, c.Request().URL.Path, allow).WithRequestID(requestID))
+				return
+			}
+			// 如果內部錯誤是我們自定義的錯誤 (包含被包裝的)，則直接使用
+			var customErr *utils.CustomError
+			if errors.As(he.Internal, &customErr) {
+				c.JSON(customErr.Code, customErr.WithRequestID(requestID))
+				return
+			}
+			// 否則，將 Echo HTTP 錯誤轉換為自定義錯誤格式
+			c.JSON(he.Code, &utils.CustomError{Code: he.Code, Message: httpErrorMessage(he), RequestID: requestID})
+			return
+		}
+
+		// 如果錯誤是我們自定義的錯誤 (包含以 fmt.Errorf 的 %w 包裝的)
+		var customErr *utils.CustomError
+		if errors.As(err, &customErr) {
+			c.JSON(customErr.Code, customErr.WithRequestID(requestID))
+			return
+		}
+
+		// 如果是驗證錯誤 (來自 go-playground/validator)
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			// 欄位名稱使用 JSON 標籤，訊息依 Accept-Language 翻譯 (en、zh-TW)，並保留規則名稱供程式判斷
+			customErr := utils.NewValidationError(customValidator.Translate(validationErrors, c.Request().Header.Get("Accept-Language")))
+			customErr.RequestID = requestID
+			c.JSON(customErr.Code, customErr)
+			return
+		}
+
+		// 其他未處理的錯誤，記錄到日誌並返回通用的內部伺服器錯誤
+		utils.Logger(c).Error("Unhandled internal server error", zap.Error(err),
+			zap.String("path", c.Path()),
+			zap.String("method", c.Request().Method),
+			zap.Any("error_type", fmt.Sprintf("%T", err)), // 記錄錯誤類型
+		)
+		c.JSON(http.StatusInternalServerError, utils.ErrInternalServer.WithRequestID(requestID))
+	}
+}
+
+// httpErrorMessage 返回 echo.HTTPError 的訊息文字：Message 可能是字串、error、含 message 的 map (例如部分中介軟體) 或其他值
+func httpErrorMessage(he *echo.HTTPError) string {
+	switch message := he.Message.(type) {
+	case nil:
+		return http.StatusText(he.Code)
+	case string:
+		return message
+	case error:
+		return message.Error()
+	case map[string]interface{}:
+		if text, ok := message["message"].(string); ok {
+			return text
+		}
+	case echo.Map:
+		if text, ok := message["message"].(string); ok {
+			return text
+		}
+	}
+	return fmt.Sprint(he.Message)
+}
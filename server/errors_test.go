@@ -0,0 +1,179 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/errorreport"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// recordingReporter 記錄被回報的事件
+type recordingReporter struct {
+	events []errorreport.Event
+}
+
+func (r *recordingReporter) Report(event errorreport.Event) {
+	r.events = append(r.events, event)
+}
+
+// errorHandlerTest 以 newHTTPErrorHandler 處理 err，返回回應、解析後的錯誤內容與被回報的事件
+// prepare 不為 nil 時在處理錯誤之前呼叫 (例如先寫出部分回應)
+func errorHandlerTest(t *testing.T, err error, prepare func(c echo.Context)) (*httptest.ResponseRecorder, utils.CustomError, []errorreport.Event) {
+	t.Helper()
+	customValidator := utils.NewCustomValidator()
+	if err := customValidator.RegisterCustomValidations(nil); err != nil {
+		t.Fatal(err)
+	}
+	reporter := &recordingReporter{}
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/customers", nil), rec)
+	c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+	if prepare != nil {
+		prepare(c)
+	}
+
+	newHTTPErrorHandler(customValidator, reporter)(err, c)
+
+	var body utils.CustomError
+	if !c.Response().Committed {
+		t.Fatal("error handler wrote no response")
+	}
+	if prepare == nil {
+		if jsonErr := json.Unmarshal(rec.Body.Bytes(), &body); jsonErr != nil {
+			t.Fatalf("response is not valid JSON: %v (body %s)", jsonErr, rec.Body)
+		}
+	}
+	return rec, body, reporter.events
+}
+
+// validationError 以驗證器驗證無效的內容，返回 validator.ValidationErrors
+func validationError(t *testing.T) error {
+	t.Helper()
+	err := utils.NewCustomValidator().Validate(&struct {
+		Username string `json:"username" validate:"required,min=3"`
+	}{Username: "ab"})
+	if err == nil {
+		t.Fatal("Validate returned no error")
+	}
+	return err
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantStatus    int
+		wantMessage   string
+		wantErrorCode string
+		wantReported  bool
+	}{
+		{name: "HTTPError with string message", err: echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Request Entity Too Large"), wantStatus: http.StatusRequestEntityTooLarge, wantMessage: "Request Entity Too Large"},
+		{name: "HTTPError with error message", err: echo.NewHTTPError(http.StatusUnauthorized, errors.New("missing or malformed jwt")), wantStatus: http.StatusUnauthorized, wantMessage: "missing or malformed jwt"},
+		{name: "HTTPError with map message", err: echo.NewHTTPError(http.StatusTooManyRequests, map[string]interface{}{"message": "rate limit exceeded"}), wantStatus: http.StatusTooManyRequests, wantMessage: "rate limit exceeded"},
+		{name: "HTTPError with echo.Map message", err: echo.NewHTTPError(http.StatusForbidden, echo.Map{"message": "forbidden"}), wantStatus: http.StatusForbidden, wantMessage: "forbidden"},
+		{name: "HTTPError with map without message", err: echo.NewHTTPError(http.StatusBadRequest, echo.Map{"reason": "x"}), wantStatus: http.StatusBadRequest, wantMessage: "map[reason:x]"},
+		{name: "HTTPError with other value", err: echo.NewHTTPError(http.StatusBadRequest, 42), wantStatus: http.StatusBadRequest, wantMessage: "42"},
+		{name: "HTTPError without message", err: &echo.HTTPError{Code: http.StatusServiceUnavailable}, wantStatus: http.StatusServiceUnavailable, wantMessage: http.StatusText(http.StatusServiceUnavailable), wantReported: true},
+		{name: "HTTPError with CustomError internal", err: echo.NewHTTPError(http.StatusBadRequest).SetInternal(utils.ErrConflict), wantStatus: http.StatusConflict, wantMessage: utils.ErrConflict.Message, wantErrorCode: utils.ErrorCodeConflict},
+		{name: "HTTPError with wrapped CustomError internal", err: echo.NewHTTPError(http.StatusBadRequest).SetInternal(fmt.Errorf("lookup: %w", utils.ErrNotFound)), wantStatus: http.StatusNotFound, wantMessage: utils.ErrNotFound.Message},
+		{name: "router 404", err: echo.ErrNotFound, wantStatus: http.StatusNotFound, wantErrorCode: utils.ErrorCodeRouteNotFound},
+		{name: "CustomError", err: utils.ErrConflict.SetDetails("Username already exists"), wantStatus: http.StatusConflict, wantMessage: utils.ErrConflict.Message, wantErrorCode: utils.ErrorCodeConflict},
+		{name: "wrapped CustomError", err: fmt.Errorf("create account: %w", utils.ErrNotFound), wantStatus: http.StatusNotFound, wantMessage: utils.ErrNotFound.Message},
+		{name: "5xx CustomError", err: utils.ErrInternalServer, wantStatus: http.StatusInternalServerError, wantMessage: utils.ErrInternalServer.Message, wantReported: true},
+		{name: "plain error", err: errors.New("connection refused"), wantStatus: http.StatusInternalServerError, wantMessage: utils.ErrInternalServer.Message, wantReported: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, body, events := errorHandlerTest(t, tt.err, nil)
+			if rec.Code != tt.wantStatus || body.Code != tt.wantStatus {
+				t.Fatalf("status = %d, code = %d, want %d (body %s)", rec.Code, body.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantMessage != "" && body.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", body.Message, tt.wantMessage)
+			}
+			if body.ErrorCode != tt.wantErrorCode {
+				t.Errorf("error_code = %q, want %q", body.ErrorCode, tt.wantErrorCode)
+			}
+			if body.RequestID != "req-1" {
+				t.Errorf("request_id = %q, want req-1", body.RequestID)
+			}
+			if reported := len(events) > 0; reported != tt.wantReported {
+				t.Fatalf("reported = %v, want %v", reported, tt.wantReported)
+			}
+			if tt.wantReported && (events[0].Status != tt.wantStatus || events[0].Err != tt.err || events[0].RequestID != "req-1") {
+				t.Errorf("event = %+v", events[0])
+			}
+		})
+	}
+
+	// 預先定義的錯誤不會被寫入 request_id
+	if utils.ErrConflict.RequestID != "" || utils.ErrNotFound.RequestID != "" || utils.ErrInternalServer.RequestID != "" {
+		t.Error("error handler modified a predefined CustomError")
+	}
+}
+
+func TestHTTPErrorHandlerValidationErrors(t *testing.T) {
+	for name, err := range map[string]error{
+		"ValidationErrors":         validationError(t),
+		"wrapped ValidationErrors": fmt.Errorf("validate: %w", validationError(t)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec, _, events := errorHandlerTest(t, err, nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400 (body %s)", rec.Code, rec.Body)
+			}
+			var body struct {
+				Message   string                        `json:"message"`
+				RequestID string                        `json:"request_id"`
+				Details   []utils.ValidationErrorDetail `json:"details"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Message != "Validation failed" || body.RequestID != "req-1" {
+				t.Errorf("body = %+v", body)
+			}
+			if len(body.Details) != 1 || body.Details[0].Field != "username" || body.Details[0].Rule != "min" || body.Details[0].Param != "3" || body.Details[0].Message == "" {
+				t.Errorf("details = %+v", body.Details)
+			}
+			if len(events) != 0 {
+				t.Errorf("validation error was reported: %+v", events)
+			}
+		})
+	}
+}
+
+// TestHTTPErrorHandlerCommittedResponse 回應已開始送出時不再寫入錯誤內容，狀態碼維持已送出的值
+func TestHTTPErrorHandlerCommittedResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantReported bool
+	}{
+		{"committed 200", http.StatusOK, false},
+		{"committed 500", http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _, events := errorHandlerTest(t, errors.New("stream interrupted"), func(c echo.Context) {
+				if err := c.String(tt.status, "partial"); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if rec.Code != tt.status || rec.Body.String() != "partial" {
+				t.Errorf("response = %d %q, want the committed %d %q", rec.Code, rec.Body, tt.status, "partial")
+			}
+			if reported := len(events) > 0; reported != tt.wantReported {
+				t.Errorf("reported = %v, want %v", reported, tt.wantReported)
+			}
+		})
+	}
+}
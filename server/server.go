@@ -0,0 +1,454 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/config"
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/errorreport"
+	"github.com/wac0705/fastener-api/events"
+	"github.com/wac0705/fastener-api/handler"
+	"github.com/wac0705/fastener-api/jobs"
+	"github.com/wac0705/fastener-api/metrics"
+	"github.com/wac0705/fastener-api/middleware/authz"
+	"github.com/wac0705/fastener-api/middleware/bodylimit"
+	"github.com/wac0705/fastener-api/middleware/bodylog"
+	"github.com/wac0705/fastener-api/middleware/displaytz"
+	"github.com/wac0705/fastener-api/middleware/etag"
+	"github.com/wac0705/fastener-api/middleware/httpmetrics"
+	"github.com/wac0705/fastener-api/middleware/httptracing"
+	"github.com/wac0705/fastener-api/middleware/jwt"
+	"github.com/wac0705/fastener-api/middleware/primaryread"
+	"github.com/wac0705/fastener-api/middleware/requestlog"
+	"github.com/wac0705/fastener-api/middleware/requesttimeout"
+	"github.com/wac0705/fastener-api/openapi"
+	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/routes"
+	"github.com/wac0705/fastener-api/service"
+	"github.com/wac0705/fastener-api/storage"
+	"github.com/wac0705/fastener-api/tracing"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// permissionWarmUpRetryInterval 預載入權限緩存失敗時重試的間隔
+const permissionWarmUpRetryInterval = 5 * time.Second
+
+// Server 組裝完成的 API 伺服器：Echo 已註冊所有中介軟體與路由，可直接 Start 或交給 httptest 使用
+type Server struct {
+	*echo.Echo
+	database          *sql.DB
+	reader            *sql.DB // 唯讀副本，未設定 DATABASE_READ_URL 時為 nil
+	pool              db.PoolConfig
+	healthService     service.HealthService
+	permissionService service.PermissionService
+	auditService      service.AuditService
+	jobRunner         jobs.Runner
+	eventHub          events.Hub
+	eventBridge       *events.PostgresBridge // 未設定 EVENTS_PG_NOTIFY 時為 nil
+	eventBridgeCtx    context.Context        // Shutdown 時由 stopEventBridge 取消 (在 New 建立，Startup 與 Shutdown 在不同 goroutine 執行)
+	stopEventBridge   context.CancelFunc
+	useTrigram        *atomic.Bool
+	logger            *zap.Logger
+}
+
+// New 依 cfg 組裝 API 伺服器：中介軟體、Repository、Service、Handler 與路由，version 為建置版本 (顯示在 /healthz 與 API 文件)
+// reader 為唯讀副本 (db.ReadDB)，客戶、公司與產品的列表及依 ID 查詢從副本讀取；為 nil 時所有查詢使用 database
+// 不測試資料庫連接，伺服器開始監聽後再呼叫 Startup；logger 注入到 Repository 與 Service，請求日誌也以它為基礎
+func New(cfg *config.AppConfig, database, reader *sql.DB, version string, logger *zap.Logger) (*Server, error) {
+
+	e := echo.New()                                    // 創建 Echo 實例
+	e.JSONSerializer = utils.RequestIDJSONSerializer{} // 錯誤回應自動帶上 request_id
+
+	// 指標註冊表，由 /metrics 以 Prometheus 格式輸出
+	metricsRegistry := metrics.NewRegistry()
+	db.RegisterPoolMetrics(metricsRegistry, database)
+	db.InitQueryLog(metricsRegistry, cfg.SlowQueryThreshold, utils.LoggerFromContext) // 每個查詢的耗時 (db_query_duration_seconds)，超過 SLOW_QUERY_MS 時以 WARN 記錄
+
+	// 分散式追蹤：設定 OTEL_EXPORTER_OTLP_ENDPOINT 時為請求、service 與資料庫查詢建立 span 並以 OTLP 匯出，否則不追蹤
+	if err := tracing.Init(tracing.Config{Endpoint: cfg.OtelEndpoint, Headers: cfg.OtelHeaders, ServiceName: cfg.OtelServiceName, ServiceVersion: version}, metricsRegistry); err != nil {
+		return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_ENDPOINT: %w", err)
+	}
+
+	// 請求驗證器 (c.Validate)：包含自定義規則 (currency、phone、payment_terms) 與驗證錯誤訊息的翻譯
+	customValidator := utils.NewCustomValidator()
+	if err := customValidator.RegisterCustomValidations(cfg.PaymentTerms); err != nil {
+		return nil, fmt.Errorf("failed to register custom validations: %w", err)
+	}
+	e.Validator = customValidator
+
+	// 請求綁定 (c.Bind)：/api/v1 的 JSON 請求內容含有未知欄位時返回 400 (UNKNOWN_FIELDS)，STRICT_JSON_BINDING 時所有路由都是如此
+	e.Binder = utils.NewJSONBinder(cfg.StrictJSONBinding)
+
+	// 錯誤回報：設定 SENTRY_DSN 時在背景將 5xx 錯誤與 panic 送到 Sentry，否則不回報
+	errorReporter := errorreport.NewNoopReporter()
+	if cfg.SentryDSN != "" {
+		sender, err := errorreport.NewSentrySender(errorreport.SentryConfig{DSN: cfg.SentryDSN, Environment: cfg.SentryEnvironment, Release: version})
+		if err != nil {
+			return nil, fmt.Errorf("invalid SENTRY_DSN: %w", err)
+		}
+		errorReporter = errorreport.NewReporter(sender, metricsRegistry)
+	}
+
+	// 設定自定義錯誤處理器
+	// 未知路由 (404) 與不支援的方法 (405) 以 CustomError 格式回應 (error_code 為 ROUTE_NOT_FOUND、METHOD_NOT_ALLOWED)：
+	// 各分組以 RouteNotFound 註冊 handler.RouteNotFound (見 routes.RegisterAPIRoutes)，Router 直接返回的 echo.ErrNotFound 與 echo.ErrMethodNotAllowed 由錯誤處理器轉換；
+	// 不修改 echo.NotFoundHandler 等套件變數，避免影響同一程序中的其他 Echo 實例 (例如測試)
+	e.HTTPErrorHandler = newHTTPErrorHandler(customValidator, errorReporter)
+
+	// Echo 全局中介軟體
+	e.Use(middleware.RequestID())                                // 請求 ID：沿用請求的 X-Request-ID，沒有時產生新的，並寫入回應標頭
+	e.Use(requestlog.ContextLogger(logger))                      // 帶有 request_id 的請求範圍 logger (utils.Logger)
+	e.Use(httptracing.Middleware(routes.IsProbeRequest))         // 啟用追蹤時建立請求的 server span，logger 與錯誤回應加上 trace_id；不追蹤探針
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{ // 錯誤恢復：panic 轉為 500 回應
+		DisableStackAll: true, // 只記錄發生 panic 的 goroutine
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			utils.Logger(c).Error("Recovered from panic", zap.Error(err), zap.ByteString("stack", stack))
+			return errorreport.Panic(err) // 錯誤處理器回報時附上 panic 發生處的呼叫堆疊
+		},
+	}))
+	if cfg.MetricsEnabled {
+		e.Use(httpmetrics.Middleware(metricsRegistry)) // 請求數量與延遲
+	}
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{ // CORS 設定
+		Skipper:          routes.IsProbeRequest, // 探針不是瀏覽器請求
+		AllowOrigins:     cfg.CorsAllowOrigins,  // 只有 "*" 時使用；其他情況由 AllowOriginFunc 比對 (支援子網域樣式)
+		AllowOriginFunc:  corsAllowOriginFunc(cfg.CorsAllowOrigins),
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderXRequestID},
+		ExposeHeaders:    []string{echo.HeaderXRequestID, displaytz.HeaderDisplayTimezone}, // 讓前端可以讀取請求 ID 並顯示在錯誤畫面，以及顯示時間的時區
+		AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch},
+		AllowCredentials: cfg.CorsAllowCredentials,
+		MaxAge:           int(12 * time.Hour / time.Second), // CORS 預檢請求緩存時間
+	}))
+	if cfg.DisplayTimezone != nil {
+		e.Use(displaytz.Middleware(cfg.DisplayTimezone)) // DISPLAY_TIMEZONE：以 X-Display-Timezone 標頭建議前端顯示時間的時區
+	}
+
+	// 設定 RequestLogger 以使用 zap
+	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		Skipper: func(c echo.Context) bool {
+			return !cfg.LogHealthChecks && routes.IsProbeRequest(c) // 預設不記錄探針請求 (LOG_HEALTH_CHECKS)
+		},
+		LogURI:       true,
+		LogStatus:    true,
+		LogLatency:   true,
+		LogRemoteIP:  true,
+		LogMethod:    true,
+		LogRequestID: true,
+		// 只記錄請求的中繼資料，不記錄請求內容 (登入、註冊與修改密碼的請求含有密碼)
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			fields := []zap.Field{
+				zap.String("method", v.Method),
+				zap.String("uri", v.URI),
+				zap.Int("status", v.Status),
+				zap.Duration("latency", v.Latency),
+				zap.String("remote_ip", v.RemoteIP),
+				zap.String("request_id", v.RequestID),
+			}
+			if traceID := tracing.TraceID(c.Request().Context()); traceID != "" {
+				fields = append(fields, zap.String("trace_id", traceID))
+			}
+			// handler 返回後 JWT 驗證寫入的 claims 仍在 Context 中；未驗證的請求不加帳戶欄位
+			logger.Info("request", append(fields, requestlog.AccountFields(c)...)...)
+			return nil
+		},
+	}))
+
+	// 設定 Echo 的日誌輸出到 Zap
+	e.Logger.SetOutput(zap.NewStdLog(logger).Writer())
+	e.Logger.SetLevel(echo.Lvl(cfg.LogLevel)) // 設定 Echo 日誌級別
+
+	// 將 JWT 驗證器實例綁定到 Echo 上下文 (用於處理器內部手動驗證，如果需要)
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if routes.IsProbeRequest(c) {
+				return next(c) // 探針不需要 JWT
+			}
+			c.Set("jwtVerifier", jwt.NewJwtVerifier(cfg.JwtSecret))
+			return next(c)
+		}
+	})
+
+	// 回應壓縮 (GZIP_LEVEL)：只在 Accept-Encoding 包含 gzip 且內容達到 GZIP_MIN_LENGTH 時壓縮；/metrics、圖片與 CSV 匯出的例外見 routes.SkipCompression
+	if cfg.GzipLevel > 0 {
+		e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
+			Skipper:   routes.SkipCompression,
+			Level:     cfg.GzipLevel,
+			MinLength: cfg.GzipMinLength,
+		}))
+	}
+
+	// 請求與回應內容的除錯日誌 (DEBUG_HTTP_LOG、DEBUG_HTTP_LOG_ROUTES)，遮蔽 DEBUG_HTTP_LOG_REDACT 的欄位；放在壓縮之後，記錄的是壓縮前的內容
+	if cfg.DebugHTTPLog || len(cfg.DebugHTTPLogRoutes) > 0 {
+		if cfg.AppEnv == "production" {
+			logger.Warn("Debug HTTP body logging is forced on in production (DEBUG_HTTP_LOG_FORCE)")
+		}
+		e.Use(bodylog.WithConfig(bodylog.Config{
+			Skipper:      routes.BodyLogSkipper(cfg.DebugHTTPLog, cfg.DebugHTTPLogRoutes),
+			MaxBytes:     cfg.DebugHTTPLogMaxBytes,
+			RedactFields: cfg.DebugHTTPLogRedact,
+		}))
+	}
+
+	// 條件式 GET：handler 以 etag.JSON 返回時，If-None-Match 相符的請求返回 304 (選單、角色選單、產品類別與產品定義)
+	e.Use(etag.Middleware())
+
+	// 請求逾時 (REQUEST_TIMEOUT)：逾時或用戶端中斷連線時取消進行中的資料庫查詢；事件串流長時間保持連線，不套用逾時
+	e.Use(requesttimeout.WithConfig(requesttimeout.Config{Skipper: routes.IsStreamRequest, Timeout: cfg.RequestTimeout}))
+
+	// 請求內容大小上限 (MAX_BODY_BYTES)，超過時返回 413 (REQUEST_TOO_LARGE)；檔案上傳路由改用 UPLOAD_MAX_BODY_BYTES (見 routes.RegisterAPIGroup)
+	e.Use(bodylimit.WithConfig(bodylimit.Config{Skipper: routes.IsUploadRequest, Limit: cfg.MaxBodyBytes}))
+
+	// 寫入請求中的讀取一律使用主庫 (唯讀副本可能還沒有剛寫入的資料)，GET 請求的列表與依 ID 查詢才從副本讀取
+	e.Use(primaryread.Middleware())
+
+	// 設置靜態檔案伺服 (如果需要，可創建 public 目錄)
+	// e.Static("/", "public")
+
+	// --- 依賴注入和服務啟動 ---
+	// 實例化 Repository 層
+	accountRepo := repository.NewAccountRepository(database, logger)
+	companyRepo := repository.NewCompanyRepository(database, reader, logger)
+	customerRepo := repository.NewCustomerRepository(database, reader, logger)
+	customerAddressRepo := repository.NewCustomerAddressRepository(database, logger)
+	customerNoteRepo := repository.NewCustomerNoteRepository(database, logger)
+	customerHistoryRepo := repository.NewCustomerHistoryRepository(database, logger)
+	menuRepo := repository.NewMenuRepository(database, logger)
+	var useTrigram atomic.Bool // 資料庫連線後偵測是否安裝 pg_trgm，未安裝時搜尋排名退回 ILIKE
+	productDefinitionRepo := repository.NewProductDefinitionRepository(database, reader, useTrigram.Load, logger)
+	productPriceRepo := repository.NewProductPriceRepository(database, logger)
+	productUnitRepo := repository.NewProductUnitRepository(database, logger)
+	productDefinitionHistoryRepo := repository.NewProductDefinitionHistoryRepository(database, logger)
+	roleRepo := repository.NewRoleRepository(database, logger)             // 新增 Role Repository
+	roleMenuRepo := repository.NewRoleMenuRepository(database, logger)     // 新增 RoleMenu Repository
+	permissionRepo := repository.NewPermissionRepository(database, logger) // 新增 Permission Repository
+	healthRepo := repository.NewHealthRepository(database)
+	accountHistoryRepo := repository.NewAccountHistoryRepository(database, logger)
+	auditLogRepo := repository.NewAuditLogRepository(database, logger)
+	txManager := db.NewTxManager(database, cfg.TxRetryConfig()) // 跨 Repository 的寫入 (角色選單取代、角色複製、帳戶建立與稽核) 在同一事務中執行，序列化失敗或死結時重試
+
+	// 上傳檔案 (產品圖片) 的儲存位置，由 FILE_STORE_DRIVER 決定
+	var fileStore storage.FileStore
+	if cfg.FileStoreDriver == "s3" {
+		fileStore = storage.NewS3FileStore(storage.S3Config{
+			Endpoint:        cfg.FileStoreS3Endpoint,
+			Region:          cfg.FileStoreS3Region,
+			Bucket:          cfg.FileStoreS3Bucket,
+			AccessKeyID:     cfg.FileStoreS3AccessKeyID,
+			SecretAccessKey: cfg.FileStoreS3SecretAccessKey,
+		})
+	} else {
+		fileStore = storage.NewLocalFileStore(cfg.FileStoreLocalDir)
+	}
+
+	// 事件串流：角色選單與權限變更後通知已連線的用戶端；設定 EVENTS_PG_NOTIFY 時經由 PostgreSQL LISTEN/NOTIFY 轉送給所有實例
+	eventHub := events.NewHub(cfg.EventsMaxStreams, metricsRegistry)
+	var eventPublisher events.Publisher = eventHub
+	var eventBridge *events.PostgresBridge
+	if cfg.EventsPGNotify {
+		eventBridge = events.NewPostgresBridge(database, cfg.DatabaseURL, eventHub)
+		eventPublisher = eventBridge
+	}
+
+	// 選單、角色與產品類別的讀取緩存 (CACHE_*)；CACHE_ENABLED=false 時每次都查詢資料庫
+	readCache := cache.NewDisabledCache()
+	if cfg.CacheEnabled {
+		cacheStore := cache.NewMemoryStore()
+		if cfg.CacheDriver == "redis" {
+			redisStore, err := cache.NewRedisStore(cache.RedisConfig{URL: cfg.CacheRedisURL, Prefix: cfg.CacheRedisPrefix})
+			if err != nil {
+				return nil, fmt.Errorf("invalid CACHE_REDIS_URL: %w", err)
+			}
+			cacheStore = redisStore
+		}
+		readCache = cache.NewCache(cacheStore, cfg.CacheTTL, metricsRegistry)
+	}
+
+	// 實例化 Service 層，並注入 Repository 依賴
+	accountService := service.NewAccountService(accountRepo, roleRepo, accountHistoryRepo, txManager, eventPublisher, logger)                                                                  // AccountService 依賴 AccountRepo、RoleRepo 和 AccountHistoryRepo
+	authService := service.NewAuthService(accountRepo, roleRepo, accountHistoryRepo, txManager, cfg.JwtSecret, cfg.JwtAccessExpiresHours, cfg.JwtRefreshExpiresHours, metricsRegistry, logger) // AuthService 依賴 AccountRepo, RoleRepo, AccountHistoryRepo, JWT配置
+	companyService := service.NewCompanyService(companyRepo, logger)
+	customerService := service.NewCustomerService(customerRepo, companyRepo, customerAddressRepo, customerNoteRepo, customerHistoryRepo, accountRepo, cfg.DefaultPhoneCountry, cfg.CustomerCodePrefix, logger)
+	menuService := service.NewMenuService(menuRepo, roleMenuRepo, readCache, logger) // MenuService 依賴 MenuRepo，並透過 RoleMenuRepo 查詢角色可訪問的選單
+	productDefinitionService := service.NewProductDefinitionService(productDefinitionRepo, productPriceRepo, productUnitRepo, productDefinitionHistoryRepo, fileStore, cfg.ProductStandardBodies, cfg.ProductBaseCurrency, cfg.PriceScale, cfg.PriceRoundingMode, readCache, logger)
+	roleService := service.NewRoleService(roleRepo, permissionRepo, roleMenuRepo, txManager, eventPublisher, readCache, logger)   // 新增 RoleService
+	roleMenuService := service.NewRoleMenuService(roleMenuRepo, roleRepo, menuRepo, txManager, eventPublisher, readCache, logger) // 新增 RoleMenuService
+	permissionService := service.NewPermissionService(permissionRepo, roleRepo, readCache, metricsRegistry, logger)               // 新增 PermissionService 依賴 PermissionRepo 和 RoleRepo
+	// 角色的權限改變時 (本實例或經由 EVENTS_PG_NOTIFY 轉送的其他實例發布) 使該角色的權限緩存與權限目錄的讀取緩存失效；帳戶改變角色不影響角色的權限
+	eventHub.OnBroadcast(func(event events.Event) {
+		if event.Type == events.PermissionsChanged && event.AccountID == 0 {
+			permissionService.InvalidateRole(context.Background(), event.RoleID)
+		}
+	})
+	auditService := service.NewAuditService(auditLogRepo, cfg.AuditBufferSize, metricsRegistry, logger) // 寫入操作的稽核記錄，在背景批次寫入 (見 Startup)
+	latestMigration, err := db.LatestMigrationVersion(cfg.MigrationsDir)
+	if err != nil {
+		logger.Warn("Cannot determine latest migration, /readyz will skip the migration check", zap.Error(err))
+	}
+	healthService := service.NewHealthService(healthRepo, permissionService, version, time.Now(), latestMigration, logger)
+
+	// 定期執行的背景工作，資料庫可連線後由 Startup 開始排程 (GET /api/v1/admin/jobs)
+	jobRunner := jobs.NewRunner(metricsRegistry)
+	if cfg.AuditRetention > 0 {
+		jobRunner.Register(jobs.Job{
+			Name:     "audit_log_cleanup",
+			Interval: cfg.AuditCleanupInterval,
+			Jitter:   cfg.AuditCleanupInterval / 10,
+			Run: func(ctx context.Context) error {
+				_, err := auditService.PurgeExpired(ctx, cfg.AuditRetention)
+				return err
+			},
+		})
+	}
+
+	// 實例化 Handler 層，並注入 Service 依賴
+	accountHandler := handler.NewAccountHandler(accountService)
+	authHandler := handler.NewAuthHandler(authService)
+	companyHandler := handler.NewCompanyHandler(companyService)
+	customerHandler := handler.NewCustomerHandler(customerService, permissionService)
+	menuHandler := handler.NewMenuHandler(menuService, roleService)
+	productDefinitionHandler := handler.NewProductDefinitionHandler(productDefinitionService, permissionService)
+	roleMenuHandler := handler.NewRoleMenuHandler(roleMenuService, roleService, menuService)
+	roleHandler := handler.NewRoleHandler(roleService, permissionService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	jobHandler := handler.NewJobHandler(jobRunner)
+	eventsHandler := handler.NewEventsHandler(eventHub, cfg.EventsHeartbeat)
+	cacheHandler := handler.NewCacheHandler(readCache)
+	healthHandler := handler.NewHealthHandler(healthService, cfg.LivenessTimeout, cfg.ReadinessTimeout)
+
+	// --- API 路由定義 ---
+	// 使用 routes 包來集中定義所有路由
+	routes.RegisterAPIRoutes(e,
+		authHandler,
+		accountHandler,
+		companyHandler,
+		customerHandler,
+		menuHandler,
+		productDefinitionHandler,
+		roleMenuHandler,
+		roleHandler,
+		auditHandler,
+		jobHandler,
+		eventsHandler,
+		cacheHandler,
+		healthHandler,
+		permissionService,      // 將權限服務傳入以便在路由中介軟體中使用
+		auditService,           // 稽核中介軟體將記錄交給 AuditService
+		cfg.JwtSecret,          // JWT Secret 也傳入
+		cfg.UploadMaxBodyBytes, // 檔案上傳路由的請求內容大小上限
+		cfg.LegacyAPISunset,    // 無版本 /api 舊路徑的 Sunset 日期
+	)
+
+	if cfg.MetricsEnabled {
+		routes.RegisterMetricsRoute(e, handler.NewMetricsHandler(metricsRegistry), cfg.MetricsUsername, cfg.MetricsPassword)
+	}
+
+	// OpenAPI 文件與 Swagger UI 只在非 production 環境提供
+	if cfg.AppEnv != "production" {
+		docsHandler := handler.NewDocsHandler(e, openapi.Info{Title: routes.APITitle, Version: version}, routes.APIDocs, routes.OpenAPISpecPath)
+		routes.RegisterDocsRoutes(e, docsHandler)
+		if missing := openapi.MissingRoutes(e.Routes(), routes.APIDocs); len(missing) > 0 {
+			logger.Warn("Routes missing from the OpenAPI spec, add them to routes.APIDocs", zap.Strings("routes", missing))
+		}
+	}
+
+	// 事件橋接的 context 在所有可能失敗的步驟之後才建立，New 返回錯誤時不會遺留未取消的 context
+	eventBridgeCtx, stopEventBridge := context.WithCancel(context.Background())
+	return &Server{
+		Echo:              e,
+		database:          database,
+		reader:            reader,
+		pool:              cfg.DBPoolConfig(),
+		healthService:     healthService,
+		permissionService: permissionService,
+		auditService:      auditService,
+		jobRunner:         jobRunner,
+		eventHub:          eventHub,
+		eventBridge:       eventBridge,
+		eventBridgeCtx:    eventBridgeCtx,
+		stopEventBridge:   stopEventBridge,
+		useTrigram:        &useTrigram,
+		logger:            logger,
+	}, nil
+}
+
+// Startup 等待資料庫可連線、開始背景寫入稽核記錄與排程背景工作並預載入權限緩存，完成前 /readyz 返回 503 (伺服器先開始監聽，/livez 可立即回應)
+// 資料庫在重試次數內無法連線時返回錯誤；權限緩存載入失敗時持續重試，直到成功或 ctx 結束
+func (s *Server) Startup(ctx context.Context) error {
+	if err := db.Connect(ctx, s.database, s.pool); err != nil {
+		return fmt.Errorf("database unavailable: %w", err)
+	}
+	if s.reader != nil {
+		if err := db.Connect(ctx, s.reader, s.pool); err != nil {
+			return fmt.Errorf("read replica unavailable: %w", err)
+		}
+	}
+	s.healthService.MarkDatabaseConnected()
+	go s.auditService.Run(context.Background())                                  // 資料庫可連線前產生的稽核記錄留在緩衝區中
+	s.jobRunner.Start()                                                          // 背景工作 (例如 audit_log_cleanup) 需要資料庫
+	s.useTrigram.Store(repository.HasExtension(s.database, s.logger, "pg_trgm")) // 未安裝 pg_trgm 時搜尋排名退回 ILIKE
+	if !s.useTrigram.Load() {
+		s.logger.Warn("pg_trgm extension is not installed: customer and product searches fall back to sequential scans and product search ranking to ILIKE matches")
+	}
+	if s.eventBridge != nil {
+		go s.eventBridge.Run(s.eventBridgeCtx) // 監聽其他實例發布的事件
+	}
+
+	// 將本版本路由使用的權限登記到權限目錄，新路由的權限不需先執行 cmd/seed 即可在 GET /permissions 看到並賦予角色；失敗時只記錄日誌
+	if _, err := s.permissionService.RegisterPermissions(ctx, authz.RegisteredPermissions()); err != nil {
+		s.logger.Warn("Failed to register permissions, run cmd/seed to add new permissions", zap.Error(err))
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := s.permissionService.WarmCache(ctx)
+		if err == nil {
+			break
+		}
+		s.logger.Warn("Permission cache warm-up failed, retrying", zap.Error(err), zap.Int("attempt", attempt))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(permissionWarmUpRetryInterval):
+		}
+	}
+	s.healthService.MarkStarted()
+	return nil
+}
+
+// Shutdown 優雅關閉伺服器：停止接受新連線並等待進行中的請求完成，同時停止背景工作並等待執行中的工作返回，最後送出尚未匯出的 span
+// 事件串流不會自行結束，先關閉所有訂閱讓串流返回；ctx 結束前仍未完成時返回錯誤
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.eventHub.Close()
+	s.stopEventBridge()
+	jobsStopped := make(chan error, 1)
+	go func() { jobsStopped <- s.jobRunner.Stop(ctx) }()
+	if err := s.Echo.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+	if err := <-jobsStopped; err != nil {
+		return fmt.Errorf("failed to stop background jobs: %w", err)
+	}
+	if err := tracing.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to flush trace spans: %w", err)
+	}
+	return nil
+}
+
+// corsAllowOriginFunc 返回比對 CORS_ALLOW_ORIGIN 的 AllowOriginFunc；只允許 "*" 時返回 nil，改由 AllowOrigins 處理
+func corsAllowOriginFunc(origins []string) func(origin string) (bool, error) {
+	if len(origins) == 1 && origins[0] == "*" {
+		return nil
+	}
+	return func(origin string) (bool, error) {
+		return config.MatchCORSOrigin(origins, origin), nil
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL 驅動 (sql.Open 不會連接資料庫)
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/wac0705/fastener-api/config"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// testDatabaseURL 不存在的資料庫：New 不測試連接，只有需要查詢的請求會失敗
+const testDatabaseURL = "postgres://fastener@127.0.0.1:1/fastener?sslmode=disable"
+
+// newTestServer 以測試用的環境變數組裝伺服器，env 可覆寫或追加環境變數；不連接資料庫
+func newTestServer(t *testing.T, env map[string]string) *Server {
+	t.Helper()
+	database, err := sql.Open("pgx", testDatabaseURL)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return newTestServerWithDB(t, database, env)
+}
+
+// newTestServerWithDB 以 database 組裝伺服器 (例如 dbtest.Open 的測試資料庫)
+func newTestServerWithDB(t *testing.T, database *sql.DB, env map[string]string) *Server {
+	t.Helper()
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("DATABASE_URL", testDatabaseURL)
+	t.Setenv("JWT_SECRET", "server-test-secret-0123456789abcdef")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	if err := config.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	s, err := New(config.Cfg, database, nil, "test", zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+// serve 將請求交給伺服器處理，返回回應與解析後的錯誤內容 (回應不是 JSON 時為零值)
+func serve(t *testing.T, s *Server, req *http.Request) (*httptest.ResponseRecorder, utils.CustomError) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	var body utils.CustomError
+	if strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("response is not valid JSON: %v (body %s)", err, rec.Body)
+		}
+	}
+	return rec, body
+}
+
+// TestNewLeavesEchoGlobalsUnchanged New 不修改 echo 的套件變數，同一程序中的其他 Echo 實例維持預設的 404、405 處理
+func TestNewLeavesEchoGlobalsUnchanged(t *testing.T) {
+	notFound := reflect.ValueOf(echo.NotFoundHandler).Pointer()
+	methodNotAllowed := reflect.ValueOf(echo.MethodNotAllowedHandler).Pointer()
+
+	newTestServer(t, nil)
+
+	if reflect.ValueOf(echo.NotFoundHandler).Pointer() != notFound {
+		t.Error("New replaced echo.NotFoundHandler")
+	}
+	if reflect.ValueOf(echo.MethodNotAllowedHandler).Pointer() != methodNotAllowed {
+		t.Error("New replaced echo.MethodNotAllowedHandler")
+	}
+}
+
+// TestUnknownRoutesAndMethods 未知的路徑與不支援的方法在 API 分組內外都以 CustomError 格式回應，405 以 Allow 標頭列出支援的方法
+func TestUnknownRoutesAndMethods(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		method        string
+		path          string
+		wantStatus    int
+		wantErrorCode string
+		wantAllow     []string
+	}{
+		{method: http.MethodGet, path: "/no-such-path", wantStatus: http.StatusNotFound, wantErrorCode: utils.ErrorCodeRouteNotFound},
+		{method: http.MethodGet, path: "/api/v1/no-such-resource", wantStatus: http.StatusNotFound, wantErrorCode: utils.ErrorCodeRouteNotFound},
+		{method: http.MethodGet, path: "/api/no-such-resource", wantStatus: http.StatusNotFound, wantErrorCode: utils.ErrorCodeRouteNotFound},
+		{method: http.MethodPost, path: "/healthz", wantStatus: http.StatusMethodNotAllowed, wantErrorCode: utils.ErrorCodeMethodNotAllowed, wantAllow: []string{http.MethodGet}},
+		{method: http.MethodPatch, path: "/api/v1/customers", wantStatus: http.StatusMethodNotAllowed, wantErrorCode: utils.ErrorCodeMethodNotAllowed, wantAllow: []string{http.MethodGet, http.MethodPost}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec, body := serve(t, s, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if body.ErrorCode != tt.wantErrorCode {
+				t.Errorf("error_code = %q, want %q", body.ErrorCode, tt.wantErrorCode)
+			}
+			if body.RequestID == "" || body.RequestID != rec.Header().Get(echo.HeaderXRequestID) {
+				t.Errorf("request_id = %q, want the X-Request-ID header %q", body.RequestID, rec.Header().Get(echo.HeaderXRequestID))
+			}
+			allow := rec.Header().Get(echo.HeaderAllow)
+			for _, method := range tt.wantAllow {
+				if !strings.Contains(allow, method) {
+					t.Errorf("Allow = %q, want it to contain %s", allow, method)
+				}
+			}
+			if len(tt.wantAllow) == 0 && allow != "" {
+				t.Errorf("Allow = %q on a 404, want none", allow)
+			}
+		})
+	}
+
+	// 其他 Echo 實例 (未經過 New) 的 404 仍為 Echo 的預設格式
+	rec := httptest.NewRecorder()
+	echo.New().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/no-such-path", nil))
+	if strings.Contains(rec.Body.String(), utils.ErrorCodeRouteNotFound) {
+		t.Errorf("a plain Echo instance answered with %s: %s", utils.ErrorCodeRouteNotFound, rec.Body)
+	}
+}
+
+// TestValidationErrorResponse 驗證失敗的請求經過實際的路由與錯誤處理器回應 400 與欄位明細，不會到達 Service
+// (測試伺服器沒有可連接的資料庫，請求若到達 Service 會得到 500)
+func TestValidationErrorResponse(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := []struct {
+		name       string
+		body       string
+		language   string
+		wantFields map[string]string
+	}{
+		{"short username", `{"username": "ab", "password": "Str0ng-Passw0rd", "role_id": 2}`, "", map[string]string{"username": "min"}},
+		{"missing password and role", `{"username": "new.user"}`, "", map[string]string{"password": "required", "role_id": "required"}},
+		{"translated messages", `{"username": "ab", "password": "Str0ng-Passw0rd", "role_id": 2}`, "zh-TW", map[string]string{"username": "min"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/register", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.language != "" {
+				req.Header.Set("Accept-Language", tt.language)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+			var body struct {
+				Message   string                        `json:"message"`
+				RequestID string                        `json:"request_id"`
+				Details   []utils.ValidationErrorDetail `json:"details"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON response: %v (body %s)", err, rec.Body)
+			}
+			if body.RequestID == "" {
+				t.Error("validation error response has no request_id")
+			}
+			got := map[string]string{}
+			for _, detail := range body.Details {
+				got[detail.Field] = detail.Rule
+				if detail.Message == "" {
+					t.Errorf("field %s has no message", detail.Field)
+				}
+				if tt.language == "zh-TW" && detail.Message == "username must be at least 3 characters in length" {
+					t.Errorf("message for %s was not translated: %q", detail.Field, detail.Message)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("field details = %v, want %v (body %s)", got, tt.wantFields, rec.Body)
+			}
+		})
+	}
+}
+
+// TestResponseCompression New 依 GZIP_LEVEL 與 GZIP_MIN_LENGTH 套用 Gzip 中介軟體：Accept-Encoding 包含 gzip 且內容達到 GZIP_MIN_LENGTH 時才壓縮，
+// /metrics 不論大小都不壓縮，GZIP_LEVEL=0 時不壓縮 (各路由的例外見 routes.TestSkipCompression)
+func TestResponseCompression(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{name: "negotiated", env: map[string]string{"GZIP_MIN_LENGTH": "1"}, path: "/livez", acceptEncoding: "gzip", wantGzip: true},
+		{name: "not accepted", env: map[string]string{"GZIP_MIN_LENGTH": "1"}, path: "/livez", acceptEncoding: "", wantGzip: false},
+		{name: "other encodings only", env: map[string]string{"GZIP_MIN_LENGTH": "1"}, path: "/livez", acceptEncoding: "br, deflate", wantGzip: false},
+		{name: "under the default minimum length", path: "/livez", acceptEncoding: "gzip", wantGzip: false},
+		{name: "metrics above the minimum length", path: "/metrics", acceptEncoding: "gzip", wantGzip: false},
+		{name: "metrics with any length", env: map[string]string{"GZIP_MIN_LENGTH": "1"}, path: "/metrics", acceptEncoding: "gzip", wantGzip: false},
+		{name: "disabled", env: map[string]string{"GZIP_LEVEL": "0", "GZIP_MIN_LENGTH": "1"}, path: "/livez", acceptEncoding: "gzip", wantGzip: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.env)
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set(echo.HeaderAcceptEncoding, tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+			}
+			if gzipped := rec.Header().Get(echo.HeaderContentEncoding) == "gzip"; gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q with a %d-byte body, want gzip %v", rec.Header().Get(echo.HeaderContentEncoding), rec.Body.Len(), tt.wantGzip)
+			}
+			if tt.path == "/metrics" && rec.Body.Len() < 1024 {
+				t.Errorf("/metrics body has %d bytes, want at least the default GZIP_MIN_LENGTH", rec.Body.Len())
+			}
+			if tt.wantGzip {
+				reader, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var body map[string]interface{}
+				if err := json.NewDecoder(reader).Decode(&body); err != nil {
+					t.Errorf("decompressed body is not JSON: %v", err)
+				}
+			}
+		})
+	}
+}
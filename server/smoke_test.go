@@ -0,0 +1,146 @@
+//go:build integration
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/db/dbtest"
+	"github.com/wac0705/fastener-api/models"
+)
+
+// smokeClient 以 JSON 呼叫伺服器的 API，token 不為空白時帶上 Bearer 標頭
+type smokeClient struct {
+	t     *testing.T
+	s     *Server
+	token string
+}
+
+// do 發出請求並返回回應；body 不為 nil 時以 JSON 編碼
+func (c smokeClient) do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	c.t.Helper()
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, "/api/v1"+path, reader)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if c.token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+c.token)
+	}
+	rec := httptest.NewRecorder()
+	c.s.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect 檢查狀態碼並將 JSON 回應解碼到 out (可為 nil)
+func (c smokeClient) expect(rec *httptest.ResponseRecorder, status int, out interface{}) {
+	c.t.Helper()
+	if rec.Code != status {
+		c.t.Fatalf("status = %d, want %d (body %s)", rec.Code, status, rec.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			c.t.Fatalf("invalid JSON response: %v (body %s)", err, rec.Body)
+		}
+	}
+}
+
+// login 以帳號密碼登入，返回帶有 access token 的 client
+func (c smokeClient) login(username, password string) smokeClient {
+	c.t.Helper()
+	var resp struct {
+		AccessToken string          `json:"access_token"`
+		Account     *models.Account `json:"account"`
+	}
+	c.expect(c.do(http.MethodPost, "/login", models.LoginRequest{Username: username, Password: password}), http.StatusOK, &resp)
+	if resp.AccessToken == "" || resp.Account == nil || resp.Account.Username != username {
+		c.t.Fatalf("login response = %+v", resp)
+	}
+	c.token = resp.AccessToken
+	return c
+}
+
+// TestSmoke 以實際的路由表與測試資料庫走過登入、授權的 CRUD 與缺少權限的 403
+func TestSmoke(t *testing.T) {
+	database := dbtest.Open(t)
+	dbtest.Reset(t, database)
+	managerRole := dbtest.SeedRoleWithPermissions(t, database, "manager", "company:read", "company:create", "company:update", "company:delete")
+	viewerRole := dbtest.SeedRoleWithPermissions(t, database, "viewer", "company:read")
+	dbtest.SeedAccountWithPassword(t, database, "manager", "manager-password-1", managerRole)
+	dbtest.SeedAccountWithPassword(t, database, "viewer", "viewer-password-1", viewerRole)
+
+	anonymous := smokeClient{t: t, s: newTestServerWithDB(t, database, nil)}
+
+	t.Run("login", func(t *testing.T) {
+		anonymous := anonymous
+		anonymous.t = t
+		anonymous.expect(anonymous.do(http.MethodPost, "/login", models.LoginRequest{Username: "manager", Password: "wrong-password"}), http.StatusUnauthorized, nil)
+		anonymous.expect(anonymous.do(http.MethodGet, "/companies", nil), http.StatusUnauthorized, nil)
+		anonymous.login("manager", "manager-password-1")
+	})
+
+	t.Run("authorized CRUD", func(t *testing.T) {
+		manager := anonymous
+		manager.t = t
+		manager = manager.login("manager", "manager-password-1")
+
+		var created models.Company
+		rec := manager.do(http.MethodPost, "/companies", map[string]interface{}{"name": "Acme Fasteners", "country": "TW"})
+		manager.expect(rec, http.StatusCreated, &created)
+		if created.ID == 0 || created.Name != "Acme Fasteners" {
+			t.Fatalf("created = %+v", created)
+		}
+		path := fmt.Sprintf("/companies/%d", created.ID)
+		if location := rec.Header().Get(echo.HeaderLocation); !strings.HasSuffix(location, path) {
+			t.Errorf("Location = %q, want suffix %q", location, path)
+		}
+
+		var fetched models.Company
+		manager.expect(manager.do(http.MethodGet, path, nil), http.StatusOK, &fetched)
+		if fetched.ID != created.ID || fetched.Country != "TW" {
+			t.Errorf("fetched = %+v", fetched)
+		}
+
+		var updated models.Company
+		manager.expect(manager.do(http.MethodPut, path, map[string]interface{}{"name": "Acme Fasteners Ltd", "country": "TW"}), http.StatusOK, &updated)
+		manager.expect(manager.do(http.MethodGet, path, nil), http.StatusOK, &fetched)
+		if fetched.Name != "Acme Fasteners Ltd" {
+			t.Errorf("name after update = %q", fetched.Name)
+		}
+
+		manager.expect(manager.do(http.MethodDelete, path, nil), http.StatusNoContent, nil)
+		manager.expect(manager.do(http.MethodGet, path, nil), http.StatusNotFound, nil)
+	})
+
+	t.Run("missing permission", func(t *testing.T) {
+		viewer := anonymous
+		viewer.t = t
+		viewer = viewer.login("viewer", "viewer-password-1")
+
+		var companies []models.Company
+		viewer.expect(viewer.do(http.MethodGet, "/companies", nil), http.StatusOK, &companies)
+		viewer.expect(viewer.do(http.MethodPost, "/companies", map[string]interface{}{"name": "Not Allowed Ltd"}), http.StatusForbidden, nil)
+		viewer.expect(viewer.do(http.MethodDelete, fmt.Sprintf("/companies/%d", dbtest.SeedCompany(t, database, "Protected Ltd", nil)), nil), http.StatusForbidden, nil)
+
+		var count int
+		if err := database.QueryRow(`SELECT COUNT(*) FROM companies WHERE name IN ('Not Allowed Ltd', 'Protected Ltd') AND deleted_at IS NULL`).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("companies after forbidden requests = %d, want only the seeded one", count)
+		}
+	})
+}
@@ -1,11 +1,15 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/events"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/repository" // 導入 Repository 層
 	"github.com/wac0705/fastener-api/utils"      // 導入工具 (包含自定義錯誤)
@@ -13,82 +17,116 @@ import (
 
 // AccountService 定義帳戶服務介面
 type AccountService interface {
-	CreateAccount(account *models.Account) error
-	GetAllAccounts() ([]models.Account, error)
-	GetAccountByID(id int) (*models.Account, error)
-	UpdateAccount(account *models.Account) error
-	DeleteAccount(id int) error
-	UpdatePassword(accountID int, oldPassword, newPassword string, requesterAccountID int, requesterRoleID int) error
+	CreateAccount(ctx context.Context, account *models.Account, actorID int) error // actorID 為執行建立的帳戶，記錄在帳戶歷史中
+	GetAllAccounts(ctx context.Context, pagination utils.Pagination) (*models.PaginatedResponse, error)
+	GetAccountByID(ctx context.Context, id int) (*models.Account, error)
+	ResolvePublicID(ctx context.Context, publicID string) (int, error) // 將對外的 UUID 識別碼 (public_id) 轉換為 ID，不存在時返回 utils.ErrNotFound
+	UpdateAccount(ctx context.Context, account *models.Account) error
+	DeleteAccount(ctx context.Context, id int) error
+	RestoreAccount(ctx context.Context, id int) (*models.Account, error) // 還原已軟刪除的帳戶，返回還原後的帳戶
+	UpdatePassword(ctx context.Context, accountID int, oldPassword, newPassword string, requesterAccountID int, requesterRoleID int) error
 }
 
 // accountServiceImpl 實現 AccountService 介面
 type accountServiceImpl struct {
-	accountRepo repository.AccountRepository
-	roleRepo    repository.RoleRepository // 依賴 RoleRepository 以獲取角色信息
+	accountRepo        repository.AccountRepository
+	roleRepo           repository.RoleRepository // 依賴 RoleRepository 以獲取角色信息
+	accountHistoryRepo repository.AccountHistoryRepository
+	txManager          db.TxManager
+	publisher          events.Publisher // 帳戶的角色改變後發布 permissions_changed
+	logger             *zap.Logger
 }
 
 // NewAccountService 創建 AccountService 實例
-func NewAccountService(accountRepo repository.AccountRepository, roleRepo repository.RoleRepository) AccountService {
-	return &accountServiceImpl{accountRepo: accountRepo, roleRepo: roleRepo}
+func NewAccountService(accountRepo repository.AccountRepository, roleRepo repository.RoleRepository, accountHistoryRepo repository.AccountHistoryRepository, txManager db.TxManager, publisher events.Publisher, logger *zap.Logger) AccountService {
+	return &accountServiceImpl{accountRepo: accountRepo, roleRepo: roleRepo, accountHistoryRepo: accountHistoryRepo, txManager: txManager, publisher: publisher, logger: logger}
 }
 
-// CreateAccount 創建新帳戶
-func (s *accountServiceImpl) CreateAccount(account *models.Account) error {
-	// 檢查用戶名是否已存在
-	existingAccount, err := s.accountRepo.FindByUsername(account.Username)
+// CreateAccount 創建新帳戶，用戶名與角色檢查、帳戶與建立記錄 (帳戶歷史) 在同一事務中完成，任一失敗時都不會留下資料
+// 同時建立相同用戶名時，後寫入者由唯一索引擋下並返回 409
+func (s *accountServiceImpl) CreateAccount(ctx context.Context, account *models.Account, actorID int) error {
+	// 雜湊密碼 (在事務外進行，避免事務持有過久)
+	hashedPassword, err := utils.HashPassword(account.Password)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing account by username", zap.Error(err), zap.String("username", account.Username))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to hash password for new account", zap.Error(err))
 		return utils.ErrInternalServer
 	}
-	if existingAccount != nil {
-		return utils.ErrBadRequest.SetDetails("Username already exists")
+	account.Password = hashedPassword
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		return createAccountWithHistory(ctx, s.logger, s.accountRepo, s.roleRepo, s.accountHistoryRepo, account, &actorID)
+	})
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 用戶名已存在或與其他請求同時建立相同用戶名 (409)、角色無效 (400)
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create account in repository", zap.Error(err), zap.String("username", account.Username))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create account: %v", err))
 	}
+	return nil
+}
 
-	// 檢查角色 ID 是否有效
-	role, err := s.roleRepo.FindByID(account.RoleID)
+// createAccountWithHistory 檢查用戶名與角色後建立帳戶 (密碼需已雜湊) 並寫入帳戶歷史的建立記錄，填入 account.RoleName
+// 需在 db.TxManager.WithinTx 的 ctx 中呼叫；actorID 為 nil 時表示自行註冊
+// 檢查失敗或唯一衝突時返回 *utils.CustomError，其他錯誤由呼叫端記錄並轉為 500
+func createAccountWithHistory(ctx context.Context, logger *zap.Logger, accountRepo repository.AccountRepository, roleRepo repository.RoleRepository, accountHistoryRepo repository.AccountHistoryRepository, account *models.Account, actorID *int) error {
+	// 檢查用戶名是否已存在；預先檢查只為了提早返回，同時建立的請求由唯一索引擋下
+	existingAccount, err := accountRepo.FindByUsername(ctx, account.Username)
 	if err != nil {
-		zap.L().Error("Service: Error checking role ID", zap.Error(err), zap.Int("role_id", account.RoleID))
+		utils.ContextLogger(ctx, logger).Error("Service: Error checking existing account by username", zap.Error(err), zap.String("username", account.Username))
 		return utils.ErrInternalServer
 	}
-	if role == nil {
-		return utils.ErrBadRequest.SetDetails("Invalid Role ID")
+	if existingAccount != nil {
+		return utils.ErrConflict.SetDetails("Username already exists")
 	}
 
-	// 雜湊密碼
-	hashedPassword, err := utils.HashPassword(account.Password)
+	// 檢查角色 ID 是否有效
+	role, err := roleRepo.FindByID(ctx, account.RoleID)
 	if err != nil {
-		zap.L().Error("Service: Failed to hash password for new account", zap.Error(err))
+		utils.ContextLogger(ctx, logger).Error("Service: Error checking role ID", zap.Error(err), zap.Int("role_id", account.RoleID))
 		return utils.ErrInternalServer
 	}
-	account.Password = hashedPassword
+	if role == nil {
+		return utils.ErrBadRequest.SetDetails("Invalid Role ID")
+	}
 
-	// 調用 Repository 創建帳戶
-	if err := s.accountRepo.Create(account); err != nil {
-		// Repository 可能已經處理了一些重複鍵錯誤，但這裡可以再次確保
-		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create account: %v", err))
+	// 調用 Repository 創建帳戶並寫入帳戶歷史
+	if err := accountRepo.Create(ctx, account); err != nil {
+		return err // 唯一衝突時為 409 的 *utils.CustomError
 	}
-	return nil
+	account.RoleName = role.Name
+	roleID := fmt.Sprint(account.RoleID)
+	return accountHistoryRepo.Create(ctx, &models.AccountHistory{
+		AccountID: account.ID,
+		Event:     models.AccountEventCreated,
+		Field:     "role_id",
+		NewValue:  &roleID,
+		ActorID:   actorID,
+	})
 }
 
-// GetAllAccounts 獲取所有帳戶
-func (s *accountServiceImpl) GetAllAccounts() ([]models.Account, error) {
-	accounts, err := s.accountRepo.FindAll()
+// GetAllAccounts 分頁獲取帳戶
+func (s *accountServiceImpl) GetAllAccounts(ctx context.Context, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	accounts, page, err := s.accountRepo.FindAll(ctx, pagination)
 	if err != nil {
-		zap.L().Error("Service: Failed to get all accounts", zap.Error(err))
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 游標無效 (400)
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all accounts", zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
 	// 在返回之前清除敏感資訊
 	for i := range accounts {
 		accounts[i].Password = ""
 	}
-	return accounts, nil
+	return models.NewPageResponse(accounts, pagination.Page, pagination.PageSize, page), nil
 }
 
 // GetAccountByID 根據 ID 獲取帳戶
-func (s *accountServiceImpl) GetAccountByID(id int) (*models.Account, error) {
-	account, err := s.accountRepo.FindByID(id)
+func (s *accountServiceImpl) GetAccountByID(ctx context.Context, id int) (*models.Account, error) {
+	account, err := s.accountRepo.FindByID(ctx, id)
 	if err != nil {
-		zap.L().Error("Service: Failed to get account by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get account by ID", zap.Int("id", id), zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
 	if account == nil {
@@ -98,12 +136,25 @@ func (s *accountServiceImpl) GetAccountByID(id int) (*models.Account, error) {
 	return account, nil
 }
 
+// ResolvePublicID 根據對外的 UUID 識別碼返回帳戶 ID (包含已刪除的帳戶，由之後的操作決定是否返回 404，例如還原)
+func (s *accountServiceImpl) ResolvePublicID(ctx context.Context, publicID string) (int, error) {
+	account, err := s.accountRepo.FindByPublicID(ctx, publicID, repository.FindOptions{IncludeDeleted: true})
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get account by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return 0, utils.ErrInternalServer
+	}
+	if account == nil {
+		return 0, utils.ErrNotFound
+	}
+	return account.ID, nil
+}
+
 // UpdateAccount 更新帳戶信息
-func (s *accountServiceImpl) UpdateAccount(account *models.Account) error {
+func (s *accountServiceImpl) UpdateAccount(ctx context.Context, account *models.Account) error {
 	// 檢查帳戶是否存在
-	existingAccount, err := s.accountRepo.FindByID(account.ID)
+	existingAccount, err := s.accountRepo.FindByID(ctx, account.ID)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing account for update", zap.Error(err), zap.Int("account_id", account.ID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing account for update", zap.Error(err), zap.Int("account_id", account.ID))
 		return utils.ErrInternalServer
 	}
 	if existingAccount == nil {
@@ -112,20 +163,20 @@ func (s *accountServiceImpl) UpdateAccount(account *models.Account) error {
 
 	// 檢查新的用戶名是否被其他帳戶占用 (如果用戶名有更改)
 	if existingAccount.Username != account.Username {
-		otherAccount, err := s.accountRepo.FindByUsername(account.Username)
+		otherAccount, err := s.accountRepo.FindByUsername(ctx, account.Username)
 		if err != nil {
-			zap.L().Error("Service: Error checking username for update conflict", zap.Error(err), zap.String("new_username", account.Username))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking username for update conflict", zap.Error(err), zap.String("new_username", account.Username))
 			return utils.ErrInternalServer
 		}
 		if otherAccount != nil && otherAccount.ID != account.ID {
-			return utils.ErrBadRequest.SetDetails("Username already taken by another account")
+			return utils.ErrConflict.SetDetails("Username already taken by another account")
 		}
 	}
 
 	// 檢查新的角色 ID 是否有效
-	role, err := s.roleRepo.FindByID(account.RoleID)
+	role, err := s.roleRepo.FindByID(ctx, account.RoleID)
 	if err != nil {
-		zap.L().Error("Service: Error checking role ID for update", zap.Error(err), zap.Int("role_id", account.RoleID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking role ID for update", zap.Error(err), zap.Int("role_id", account.RoleID))
 		return utils.ErrInternalServer
 	}
 	if role == nil {
@@ -133,19 +184,25 @@ func (s *accountServiceImpl) UpdateAccount(account *models.Account) error {
 	}
 
 	// 調用 Repository 更新帳戶
-	if err := s.accountRepo.Update(account); err != nil {
-		zap.L().Error("Service: Failed to update account in repository", zap.Error(err), zap.Int("account_id", account.ID))
+	if err := s.accountRepo.Update(ctx, account); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如未找到或用戶名衝突 (409)
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update account in repository", zap.Error(err), zap.Int("account_id", account.ID))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update account: %v", err))
 	}
+	if existingAccount.RoleID != account.RoleID {
+		s.publisher.Publish(ctx, events.Event{Type: events.PermissionsChanged, RoleID: account.RoleID, AccountID: account.ID})
+	}
 	return nil
 }
 
-// DeleteAccount 刪除帳戶
-func (s *accountServiceImpl) DeleteAccount(id int) error {
+// DeleteAccount 刪除帳戶 (軟刪除，用戶名可再使用)
+func (s *accountServiceImpl) DeleteAccount(ctx context.Context, id int) error {
 	// 檢查帳戶是否存在
-	existingAccount, err := s.accountRepo.FindByID(id)
+	existingAccount, err := s.accountRepo.FindByID(ctx, id)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing account for delete", zap.Error(err), zap.Int("account_id", id))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing account for delete", zap.Error(err), zap.Int("account_id", id))
 		return utils.ErrInternalServer
 	}
 	if existingAccount == nil {
@@ -155,77 +212,92 @@ func (s *accountServiceImpl) DeleteAccount(id int) error {
 	// 可以添加業務邏輯，例如不允許刪除管理員帳戶
 	// if existingAccount.RoleID == adminRoleID { ... }
 
-	if err := s.accountRepo.Delete(id); err != nil {
-		zap.L().Error("Service: Failed to delete account in repository", zap.Error(err), zap.Int("account_id", id))
+	if err := s.accountRepo.Delete(ctx, id); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如未找到，或仍被其他記錄引用 (409)
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete account in repository", zap.Error(err), zap.Int("account_id", id))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete account: %v", err))
 	}
 	return nil
 }
 
+// RestoreAccount 還原已軟刪除的帳戶；未找到已刪除的帳戶時返回 404，用戶名已被其他帳戶使用時返回 409
+func (s *accountServiceImpl) RestoreAccount(ctx context.Context, id int) (*models.Account, error) {
+	if err := s.accountRepo.Restore(ctx, id); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to restore account in repository", zap.Error(err), zap.Int("account_id", id))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to restore account: %v", err))
+	}
+	return s.GetAccountByID(ctx, id)
+}
+
 // UpdatePassword 更新帳戶密碼
 // requesterAccountID 是發起密碼修改的用戶ID，用於權限判斷（是否是自己或有權限的管理員）
-func (s *accountServiceImpl) UpdatePassword(accountID int, oldPassword, newPassword string, requesterAccountID int, requesterRoleID int) error {
-    // 獲取目標帳戶信息
-    targetAccount, err := s.accountRepo.FindByID(accountID)
-    if err != nil {
-        zap.L().Error("Service: Error getting target account for password update", zap.Error(err), zap.Int("account_id", accountID))
-        return utils.ErrInternalServer
-    }
-    if targetAccount == nil {
-        return utils.ErrNotFound
-    }
-
-    // 檢查請求者是否有權修改密碼：
-    // 1. 如果是修改自己的密碼
-    // 2. 如果請求者是管理員 (假設 RoleID=1 是 admin) 且有權限修改他人密碼
-    isAdminRoleID, err := s.roleRepo.FindByName("admin")
-    if err != nil {
-        zap.L().Error("Service: Failed to get admin role ID", zap.Error(err))
-        return utils.ErrInternalServer
-    }
-    if isAdminRoleID == nil {
-        zap.L().Error("Service: Admin role not found in database, check initial setup.")
-        return utils.ErrInternalServer.SetDetails("Admin role not configured.")
-    }
-
-    if requesterAccountID != accountID && requesterRoleID != isAdminRoleID.ID {
-        return utils.ErrForbidden.SetDetails("You do not have permission to change this account's password.")
-    }
-
-    // 如果是修改自己的密碼，需要驗證舊密碼
-    if requesterAccountID == accountID {
-        currentAccount, err := s.accountRepo.FindByID(accountID)
-        if err != nil {
-            zap.L().Error("Service: Error retrieving current account for password verification", zap.Error(err), zap.Int("account_id", accountID))
-            return utils.ErrInternalServer
-        }
-        if currentAccount == nil { // 應當不會發生，因為前面已經檢查過 targetAccount
-            return utils.ErrNotFound
-        }
-        if !utils.CheckPasswordHash(oldPassword, currentAccount.Password) {
-            return utils.ErrUnauthorized.SetDetails("Old password is incorrect")
-        }
-    } else {
-        // 如果是管理員修改他人的密碼，不需要舊密碼，但要確保 newPassword 不為空
-        if newPassword == "" {
-             return utils.ErrBadRequest.SetDetails("New password cannot be empty for admin password reset.")
-        }
-    }
-
-    // 雜湊新密碼
-    hashedNewPassword, err := utils.HashPassword(newPassword)
-    if err != nil {
-        zap.L().Error("Service: Failed to hash new password", zap.Error(err))
-        return utils.ErrInternalServer
-    }
-
-    if err := s.accountRepo.UpdatePassword(accountID, hashedNewPassword); err != nil {
-        if err == utils.ErrNotFound { // Repository 返回的未找到錯誤
-            return utils.ErrNotFound // 帳戶可能被刪除
-        }
-        zap.L().Error("Service: Failed to update password in repository", zap.Error(err), zap.Int("account_id", accountID))
-        return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update password: %v", err))
-    }
-
-    return nil
+func (s *accountServiceImpl) UpdatePassword(ctx context.Context, accountID int, oldPassword, newPassword string, requesterAccountID int, requesterRoleID int) error {
+	// 獲取目標帳戶信息
+	targetAccount, err := s.accountRepo.FindByID(ctx, accountID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error getting target account for password update", zap.Error(err), zap.Int("account_id", accountID))
+		return utils.ErrInternalServer
+	}
+	if targetAccount == nil {
+		return utils.ErrNotFound
+	}
+
+	// 檢查請求者是否有權修改密碼：
+	// 1. 如果是修改自己的密碼
+	// 2. 如果請求者是管理員 (假設 RoleID=1 是 admin) 且有權限修改他人密碼
+	isAdminRoleID, err := s.roleRepo.FindByName(ctx, "admin")
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get admin role ID", zap.Error(err))
+		return utils.ErrInternalServer
+	}
+	if isAdminRoleID == nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Admin role not found in database, check initial setup.")
+		return utils.ErrInternalServer.SetDetails("Admin role not configured.")
+	}
+
+	if requesterAccountID != accountID && requesterRoleID != isAdminRoleID.ID {
+		return utils.ErrForbidden.SetDetails("You do not have permission to change this account's password.")
+	}
+
+	// 如果是修改自己的密碼，需要驗證舊密碼
+	if requesterAccountID == accountID {
+		currentAccount, err := s.accountRepo.FindByID(ctx, accountID)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error retrieving current account for password verification", zap.Error(err), zap.Int("account_id", accountID))
+			return utils.ErrInternalServer
+		}
+		if currentAccount == nil { // 應當不會發生，因為前面已經檢查過 targetAccount
+			return utils.ErrNotFound
+		}
+		if !utils.CheckPasswordHash(oldPassword, currentAccount.Password) {
+			return utils.ErrUnauthorized.SetDetails("Old password is incorrect")
+		}
+	} else {
+		// 如果是管理員修改他人的密碼，不需要舊密碼，但要確保 newPassword 不為空
+		if newPassword == "" {
+			return utils.ErrBadRequest.SetDetails("New password cannot be empty for admin password reset.")
+		}
+	}
+
+	// 雜湊新密碼
+	hashedNewPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to hash new password", zap.Error(err))
+		return utils.ErrInternalServer
+	}
+
+	if err := s.accountRepo.UpdatePassword(ctx, accountID, hashedNewPassword); err != nil {
+		if errors.Is(err, utils.ErrNotFound) { // Repository 返回的未找到錯誤
+			return utils.ErrNotFound // 帳戶可能被刪除
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update password in repository", zap.Error(err), zap.Int("account_id", accountID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update password: %v", err))
+	}
+
+	return nil
 }
@@ -0,0 +1,102 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/db/dbtest"
+	"github.com/wac0705/fastener-api/metrics"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// TestCreateAccountConcurrentIntegration 兩個請求同時以相同用戶名建立帳戶 (管理員建立與自行註冊)：
+// 不論後到者在預先檢查 (FindByUsername) 還是在 accounts_username_key 部分唯一索引被擋下，都只有一個成功、另一個返回 409，不會出現 500
+func TestCreateAccountConcurrentIntegration(t *testing.T) {
+	database := dbtest.Open(t)
+	logger := zaptest.NewLogger(t)
+	roleID := dbtest.SeedRole(t, database, "concurrent_role")
+	actorID := dbtest.SeedAccount(t, database, "concurrent_admin", roleID)
+
+	accountRepo := repository.NewAccountRepository(database, logger)
+	roleRepo := repository.NewRoleRepository(database, logger)
+	accountHistoryRepo := repository.NewAccountHistoryRepository(database, logger)
+	txManager := db.NewTxManager(database, db.DefaultRetryConfig)
+	accounts := NewAccountService(accountRepo, roleRepo, accountHistoryRepo, txManager, &recordingPublisher{}, logger)
+	auth := NewAuthService(accountRepo, roleRepo, accountHistoryRepo, txManager, "test-secret", 15, 60, metrics.NewRegistry(), logger)
+
+	create := func(ctx context.Context, username string) error {
+		return accounts.CreateAccount(ctx, &models.Account{Username: username, Password: "password123", RoleID: roleID}, actorID)
+	}
+	register := func(ctx context.Context, username string) error {
+		_, err := auth.Register(ctx, username, "password123", roleID)
+		return err
+	}
+	tests := []struct {
+		name          string
+		first, second func(ctx context.Context, username string) error
+	}{
+		{name: "two creates", first: create, second: create},
+		{name: "two registrations", first: register, second: register},
+		{name: "create and registration", first: create, second: register},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for round := 0; round < 10; round++ { // 多次執行，讓兩條路徑 (預先檢查與唯一索引) 都有機會被觸發
+				username := fmt.Sprintf("race_%d_%d", i, round)
+				ctx := context.Background()
+				start := make(chan struct{})
+				errs := make([]error, 2)
+				var wg sync.WaitGroup
+				for j, fn := range []func(context.Context, string) error{tt.first, tt.second} {
+					wg.Add(1)
+					go func(j int, fn func(context.Context, string) error) {
+						defer wg.Done()
+						<-start
+						errs[j] = fn(ctx, username)
+					}(j, fn)
+				}
+				close(start)
+				wg.Wait()
+
+				succeeded, conflicts := 0, 0
+				for _, err := range errs {
+					var customErr *utils.CustomError
+					switch {
+					case err == nil:
+						succeeded++
+					case errors.As(err, &customErr) && customErr.Code == http.StatusConflict && customErr.ErrorCode == utils.ErrorCodeConflict:
+						conflicts++
+					default:
+						t.Errorf("%s: error = %v, want nil or 409", username, err)
+					}
+				}
+				if succeeded != 1 || conflicts != 1 {
+					t.Fatalf("%s: %d succeeded and %d conflicted, want 1 and 1 (errors: %v)", username, succeeded, conflicts, errs)
+				}
+
+				var count int
+				if err := database.QueryRow(`SELECT COUNT(*) FROM accounts WHERE username = $1`, username).Scan(&count); err != nil {
+					t.Fatal(err)
+				}
+				var histories int
+				if err := database.QueryRow(`SELECT COUNT(*) FROM account_history h JOIN accounts a ON a.id = h.account_id WHERE a.username = $1`, username).Scan(&histories); err != nil {
+					t.Fatal(err)
+				}
+				if count != 1 || histories != 1 {
+					t.Errorf("%s: %d accounts and %d history rows, want 1 and 1", username, count, histories)
+				}
+			}
+		})
+	}
+}
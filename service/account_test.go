@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/events"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+func TestAccountServiceUpdatePassword(t *testing.T) {
+	const (
+		adminRoleID = 1
+		userRoleID  = 2
+		targetID    = 5
+		otherID     = 6
+	)
+	oldHash, err := utils.HashPassword("old-password-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := func(id int) (*models.Account, error) {
+		return &models.Account{ID: id, Username: "target", Password: oldHash, RoleID: userRoleID}, nil
+	}
+	roles := map[int]*models.Role{adminRoleID: {ID: adminRoleID, Name: "admin"}, userRoleID: {ID: userRoleID, Name: "user"}}
+
+	tests := []struct {
+		name            string
+		accounts        fakeAccountRepository
+		roles           fakeRoleRepository
+		oldPassword     string
+		newPassword     string
+		requesterID     int
+		requesterRoleID int
+		wantErr         *utils.CustomError // nil 表示成功
+		wantDetails     interface{}
+	}{
+		{
+			name:     "self with correct old password",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: roles},
+			oldPassword: "old-password-1", newPassword: "new-password-1", requesterID: targetID, requesterRoleID: userRoleID,
+		},
+		{
+			name:     "admin resets another account without old password",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: roles},
+			newPassword: "new-password-1", requesterID: otherID, requesterRoleID: adminRoleID,
+		},
+		{
+			name:     "self with wrong old password",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: roles},
+			oldPassword: "wrong-password", newPassword: "new-password-1", requesterID: targetID, requesterRoleID: userRoleID,
+			wantErr: utils.ErrUnauthorized, wantDetails: "Old password is incorrect",
+		},
+		{
+			name:     "admin changing own password still needs old password",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: roles},
+			oldPassword: "wrong-password", newPassword: "new-password-1", requesterID: targetID, requesterRoleID: adminRoleID,
+			wantErr: utils.ErrUnauthorized, wantDetails: "Old password is incorrect",
+		},
+		{
+			name:     "non-admin changing another account",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: roles},
+			oldPassword: "old-password-1", newPassword: "new-password-1", requesterID: otherID, requesterRoleID: userRoleID,
+			wantErr: utils.ErrForbidden, wantDetails: "You do not have permission to change this account's password.",
+		},
+		{
+			name:     "admin reset with empty password",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: roles},
+			requesterID: otherID, requesterRoleID: adminRoleID,
+			wantErr: utils.ErrBadRequest, wantDetails: "New password cannot be empty for admin password reset.",
+		},
+		{
+			name:     "target not found",
+			accounts: fakeAccountRepository{}, roles: fakeRoleRepository{roles: roles},
+			newPassword: "new-password-1", requesterID: otherID, requesterRoleID: adminRoleID,
+			wantErr: utils.ErrNotFound,
+		},
+		{
+			name:     "target lookup fails",
+			accounts: fakeAccountRepository{findByID: func(int) (*models.Account, error) { return nil, errDatabase }}, roles: fakeRoleRepository{roles: roles},
+			newPassword: "new-password-1", requesterID: targetID, requesterRoleID: userRoleID,
+			wantErr: utils.ErrInternalServer,
+		},
+		{
+			name:     "admin role lookup fails",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: roles, err: errDatabase},
+			newPassword: "new-password-1", requesterID: targetID, requesterRoleID: userRoleID,
+			wantErr: utils.ErrInternalServer,
+		},
+		{
+			name:     "admin role missing",
+			accounts: fakeAccountRepository{findByID: target}, roles: fakeRoleRepository{roles: map[int]*models.Role{userRoleID: roles[userRoleID]}},
+			newPassword: "new-password-1", requesterID: targetID, requesterRoleID: userRoleID,
+			wantErr: utils.ErrInternalServer, wantDetails: "Admin role not configured.",
+		},
+		{
+			name: "account deleted before update",
+			accounts: fakeAccountRepository{findByID: target, updatePassword: func(int, string) error {
+				return utils.ErrNotFound
+			}},
+			roles:       fakeRoleRepository{roles: roles},
+			newPassword: "new-password-1", requesterID: otherID, requesterRoleID: adminRoleID,
+			wantErr: utils.ErrNotFound,
+		},
+		{
+			name: "update fails",
+			accounts: fakeAccountRepository{findByID: target, updatePassword: func(int, string) error {
+				return errDatabase
+			}},
+			roles:       fakeRoleRepository{roles: roles},
+			newPassword: "new-password-1", requesterID: otherID, requesterRoleID: adminRoleID,
+			wantErr: utils.ErrInternalServer, wantDetails: "Failed to update password: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, roles := tt.accounts, tt.roles
+			s := NewAccountService(&accounts, &roles, nil, nil, &recordingPublisher{}, zap.NewNop())
+
+			err := s.UpdatePassword(context.Background(), targetID, tt.oldPassword, tt.newPassword, tt.requesterID, tt.requesterRoleID)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("UpdatePassword: %v", err)
+				}
+				if hash, ok := accounts.updated[targetID]; !ok || !utils.CheckPasswordHash(tt.newPassword, hash) {
+					t.Errorf("stored hash %q does not match the new password", hash)
+				}
+				return
+			}
+			var customErr *utils.CustomError
+			if !errors.As(err, &customErr) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdatePassword error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantDetails != nil && customErr.Details != tt.wantDetails {
+				t.Errorf("details = %v, want %v", customErr.Details, tt.wantDetails)
+			}
+			if _, ok := accounts.updated[targetID]; ok {
+				t.Error("password was updated despite the error")
+			}
+		})
+	}
+}
+
+func TestAccountServiceUpdateAccountPublishesRoleChange(t *testing.T) {
+	const accountID = 5
+	existing := func(id int) (*models.Account, error) {
+		return &models.Account{ID: id, Username: "sales", RoleID: 2}, nil
+	}
+	roles := map[int]*models.Role{2: {ID: 2, Name: "user"}, 3: {ID: 3, Name: "finance"}}
+
+	tests := []struct {
+		name      string
+		roleID    int
+		wantEvent []events.Event
+	}{
+		{name: "role changed", roleID: 3, wantEvent: []events.Event{{Type: events.PermissionsChanged, RoleID: 3, AccountID: accountID}}},
+		{name: "role unchanged", roleID: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, roleRepo := fakeAccountRepository{findByID: existing}, fakeRoleRepository{roles: roles}
+			publisher := &recordingPublisher{}
+			s := NewAccountService(&accounts, &roleRepo, nil, nil, publisher, zap.NewNop())
+
+			if err := s.UpdateAccount(context.Background(), &models.Account{ID: accountID, Username: "sales", RoleID: tt.roleID}); err != nil {
+				t.Fatalf("UpdateAccount: %v", err)
+			}
+			if len(accounts.saved) != 1 {
+				t.Fatalf("saved = %v, want one update", accounts.saved)
+			}
+			if !reflect.DeepEqual(publisher.events, tt.wantEvent) {
+				t.Errorf("published = %v, want %v", publisher.events, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestAccountServiceRestoreAccount(t *testing.T) {
+	const accountID = 5
+	existing := func(id int) (*models.Account, error) {
+		return &models.Account{ID: id, Username: "sales", Password: "hash"}, nil
+	}
+
+	tests := []struct {
+		name        string
+		accounts    fakeAccountRepository
+		wantErr     *utils.CustomError // nil 表示成功
+		wantDetails interface{}
+	}{
+		{name: "restored", accounts: fakeAccountRepository{findByID: existing}},
+		{name: "not deleted or missing", accounts: fakeAccountRepository{findByID: existing, restoreErr: utils.ErrNotFound}, wantErr: utils.ErrNotFound},
+		{
+			name: "username taken", accounts: fakeAccountRepository{findByID: existing, restoreErr: utils.ErrConflict.SetDetails("Username already exists")},
+			wantErr: utils.ErrConflict, wantDetails: "Username already exists",
+		},
+		{
+			name: "restore fails", accounts: fakeAccountRepository{findByID: existing, restoreErr: errDatabase},
+			wantErr: utils.ErrInternalServer, wantDetails: "Failed to restore account: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts := tt.accounts
+			s := NewAccountService(&accounts, &fakeRoleRepository{}, nil, nil, &recordingPublisher{}, zap.NewNop())
+
+			account, err := s.RestoreAccount(context.Background(), accountID)
+			if tt.wantErr == nil {
+				if err != nil || account == nil || account.ID != accountID {
+					t.Fatalf("RestoreAccount = %+v, %v", account, err)
+				}
+				if account.Password != "" {
+					t.Error("restored account includes the password hash")
+				}
+				return
+			}
+			var customErr *utils.CustomError
+			if !errors.As(err, &customErr) || !errors.Is(err, tt.wantErr) || account != nil {
+				t.Fatalf("RestoreAccount = %+v, %v, want %v", account, err, tt.wantErr)
+			}
+			if tt.wantDetails != nil && customErr.Details != tt.wantDetails {
+				t.Errorf("details = %v, want %v", customErr.Details, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestAccountServiceCreateAccount(t *testing.T) {
+	const (
+		actorID = 1
+		roleID  = 2
+	)
+	roles := map[int]*models.Role{roleID: {ID: roleID, Name: "user"}}
+
+	tests := []struct {
+		name     string
+		accounts fakeAccountRepository
+		history  fakeAccountHistoryRepository
+		account  models.Account
+		wantErr  *utils.CustomError // nil 表示成功
+	}{
+		{name: "records created event", account: models.Account{Username: "alice", Password: "password123", RoleID: roleID}},
+		{
+			name:     "username taken",
+			accounts: fakeAccountRepository{usernames: map[string]*models.Account{"alice": {ID: 3, Username: "alice"}}},
+			account:  models.Account{Username: "alice", Password: "password123", RoleID: roleID},
+			wantErr:  utils.ErrConflict,
+		},
+		{name: "invalid role", account: models.Account{Username: "alice", Password: "password123", RoleID: 9}, wantErr: utils.ErrBadRequest},
+		{
+			name:     "unique index conflict is passed through",
+			accounts: fakeAccountRepository{createErr: utils.ErrConflict.SetDetails("Username already exists")},
+			account:  models.Account{Username: "alice", Password: "password123", RoleID: roleID},
+			wantErr:  utils.ErrConflict,
+		},
+		{
+			name:    "history write fails",
+			history: fakeAccountHistoryRepository{err: errDatabase},
+			account: models.Account{Username: "alice", Password: "password123", RoleID: roleID},
+			wantErr: utils.ErrInternalServer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, history := tt.accounts, tt.history
+			s := NewAccountService(&accounts, &fakeRoleRepository{roles: roles}, &history, fakeTxManager{}, &recordingPublisher{}, zap.NewNop())
+
+			account := tt.account
+			err := s.CreateAccount(context.Background(), &account, actorID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateAccount error = %v, want %v", err, tt.wantErr)
+				}
+				if len(history.created) != 0 {
+					t.Errorf("failed create recorded history %+v", history.created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateAccount: %v", err)
+			}
+			if account.Password == tt.account.Password || !utils.CheckPasswordHash(tt.account.Password, account.Password) {
+				t.Error("password was not hashed before create")
+			}
+			if account.RoleName != "user" {
+				t.Errorf("RoleName = %q, want user", account.RoleName)
+			}
+			if len(history.created) != 1 {
+				t.Fatalf("history = %+v, want one created event", history.created)
+			}
+			entry := history.created[0]
+			if entry.AccountID != account.ID || entry.Event != models.AccountEventCreated || entry.ActorID == nil || *entry.ActorID != actorID || entry.NewValue == nil || *entry.NewValue != "2" {
+				t.Errorf("history entry = %+v", entry)
+			}
+		})
+	}
+}
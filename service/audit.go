@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// 背景寫入稽核記錄的批次設定
+const (
+	auditBatchSize     = 100             // 每次 INSERT 最多寫入的筆數
+	auditFlushInterval = time.Second     // 未滿一批時最長的等待時間
+	auditWriteTimeout  = 5 * time.Second // 每批寫入的逾時時間
+	auditPurgeBatch    = 10000           // 清除過期記錄時每次 DELETE 最多刪除的筆數
+)
+
+// AuditService 定義 API 稽核記錄的服務介面
+// Record 只把記錄放入緩衝區，由 Run 在背景批次寫入資料庫，不會拖慢請求
+type AuditService interface {
+	Record(entry models.AuditLog) // 緩衝區已滿時丟棄記錄並計入 audit_log_dropped_total，不阻塞請求
+	Run(ctx context.Context)      // 背景寫入緩衝區中的記錄，直到 ctx 取消 (取消前會先寫入剩餘的記錄)
+	GetAuditLogs(ctx context.Context, filter models.AuditLogFilter, pagination utils.Pagination) (*models.PaginatedResponse, error)
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) // 刪除超過保留期限的記錄 (背景工作 audit_log_cleanup)
+}
+
+// auditServiceImpl 實現 AuditService 介面
+type auditServiceImpl struct {
+	auditLogRepo repository.AuditLogRepository
+	queue        chan models.AuditLog
+	dropped      atomic.Uint64 // 因緩衝區已滿而丟棄的記錄數
+	written      atomic.Uint64 // 已寫入資料庫的記錄數
+	failed       atomic.Uint64 // 寫入資料庫失敗而遺失的記錄數
+	logger       *zap.Logger
+}
+
+// NewAuditService 創建 AuditService 實例，bufferSize 為尚未寫入的記錄上限，並在 reg 註冊緩衝區與寫入結果的指標
+func NewAuditService(auditLogRepo repository.AuditLogRepository, bufferSize int, reg metrics.Registry, logger *zap.Logger) AuditService {
+	s := &auditServiceImpl{
+		auditLogRepo: auditLogRepo,
+		queue:        make(chan models.AuditLog, bufferSize),
+		logger:       logger,
+	}
+	s.registerMetrics(reg)
+	return s
+}
+
+// registerMetrics 註冊稽核記錄緩衝區與寫入結果的指標
+func (s *auditServiceImpl) registerMetrics(reg metrics.Registry) {
+	reg.GaugeFunc("audit_log_queue_length", "Number of audit log entries waiting to be written.", func() float64 {
+		return float64(len(s.queue))
+	})
+	reg.CounterFunc("audit_log_written_total", "Total audit log entries written to the database.", func() float64 {
+		return float64(s.written.Load())
+	})
+	reg.CounterFunc("audit_log_dropped_total", "Total audit log entries dropped because the buffer was full.", func() float64 {
+		return float64(s.dropped.Load())
+	})
+	reg.CounterFunc("audit_log_write_failures_total", "Total audit log entries lost because writing them to the database failed.", func() float64 {
+		return float64(s.failed.Load())
+	})
+}
+
+// Record 將稽核記錄放入緩衝區
+func (s *auditServiceImpl) Record(entry models.AuditLog) {
+	select {
+	case s.queue <- entry:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Run 從緩衝區讀取記錄，每滿 auditBatchSize 筆或每隔 auditFlushInterval 寫入一次
+func (s *auditServiceImpl) Run(ctx context.Context) {
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AuditLog, 0, auditBatchSize)
+	for {
+		select {
+		case entry := <-s.queue:
+			batch = append(batch, entry)
+			if len(batch) >= auditBatchSize {
+				batch = s.flush(batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(batch)
+		case <-ctx.Done():
+			for {
+				select {
+				case entry := <-s.queue:
+					batch = append(batch, entry)
+					if len(batch) >= auditBatchSize {
+						batch = s.flush(batch)
+					}
+				default:
+					s.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush 寫入一批記錄並返回清空後的 batch；寫入失敗時記錄錯誤並丟棄這批記錄，避免資料庫無法使用時緩衝區無限增長
+func (s *auditServiceImpl) flush(batch []models.AuditLog) []models.AuditLog {
+	if len(batch) == 0 {
+		return batch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+	defer cancel()
+	if err := s.auditLogRepo.CreateBatch(ctx, batch); err != nil {
+		s.logger.Error("Service: Failed to write audit log entries, dropping them", zap.Error(err), zap.Int("count", len(batch)))
+		s.failed.Add(uint64(len(batch)))
+	} else {
+		s.written.Add(uint64(len(batch)))
+	}
+	return batch[:0]
+}
+
+// GetAuditLogs 分頁獲取稽核記錄，依時間由新到舊
+func (s *auditServiceImpl) GetAuditLogs(ctx context.Context, filter models.AuditLogFilter, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	entries, total, err := s.auditLogRepo.FindAll(ctx, filter, pagination)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get audit log entries", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	return &models.PaginatedResponse{Data: entries, Total: &total, Page: pagination.Page, PageSize: pagination.PageSize}, nil
+}
+
+// PurgeExpired 分批刪除建立時間早於 retention 之前的稽核記錄，返回刪除的總筆數 (失敗時為失敗前已刪除的筆數)
+func (s *auditServiceImpl) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	before := time.Now().Add(-retention)
+	var total int64
+	for {
+		deleted, err := s.auditLogRepo.DeleteCreatedBefore(ctx, before, auditPurgeBatch)
+		total += deleted
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Failed to purge expired audit log entries", zap.Error(err), zap.Int64("deleted", total))
+			return total, err
+		}
+		if deleted < auditPurgeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		utils.ContextLogger(ctx, s.logger).Info("Service: Purged expired audit log entries", zap.Int64("deleted", total), zap.Time("before", before))
+	}
+	return total, nil
+}
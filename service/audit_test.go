@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics"
+	"github.com/wac0705/fastener-api/models"
+)
+
+func TestAuditServicePurgeExpired(t *testing.T) {
+	tests := []struct {
+		name        string
+		repo        fakeAuditLogRepository
+		wantDeleted int64
+		wantDeletes int // DeleteCreatedBefore 的呼叫次數
+		wantErr     bool
+	}{
+		{name: "nothing expired", repo: fakeAuditLogRepository{}, wantDeletes: 1},
+		{name: "less than one batch", repo: fakeAuditLogRepository{expired: 42}, wantDeleted: 42, wantDeletes: 1},
+		{name: "exactly one batch needs a second call", repo: fakeAuditLogRepository{expired: auditPurgeBatch}, wantDeleted: auditPurgeBatch, wantDeletes: 2},
+		{name: "several batches", repo: fakeAuditLogRepository{expired: 2*auditPurgeBatch + 5}, wantDeleted: 2*auditPurgeBatch + 5, wantDeletes: 3},
+		{
+			name:        "failure returns rows deleted before it",
+			repo:        fakeAuditLogRepository{expired: 3 * auditPurgeBatch, failOn: 2, err: errDatabase},
+			wantDeleted: auditPurgeBatch, wantDeletes: 2, wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			s := NewAuditService(&repo, 10, metrics.NewRegistry(), zap.NewNop())
+
+			deleted, err := s.PurgeExpired(context.Background(), 90*24*time.Hour)
+			if tt.wantErr != errors.Is(err, errDatabase) {
+				t.Fatalf("PurgeExpired error = %v, want error %v", err, tt.wantErr)
+			}
+			if deleted != tt.wantDeleted || repo.deletes != tt.wantDeletes {
+				t.Errorf("deleted %d rows in %d calls, want %d in %d", deleted, repo.deletes, tt.wantDeleted, tt.wantDeletes)
+			}
+		})
+	}
+}
+
+// TestAuditServiceRun ctx 取消時寫入緩衝區中剩餘的記錄；緩衝區已滿時丟棄新的記錄，不阻塞 Record
+func TestAuditServiceRun(t *testing.T) {
+	repo := fakeAuditLogRepository{}
+	s := NewAuditService(&repo, 3, metrics.NewRegistry(), zap.NewNop())
+	for i := 0; i < 5; i++ {
+		s.Record(models.AuditLog{Path: "/api/customers"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx was canceled")
+	}
+
+	if len(repo.written) != 3 {
+		t.Errorf("written %d entries, want the 3 buffered ones", len(repo.written))
+	}
+	if dropped := s.(*auditServiceImpl).dropped.Load(); dropped != 2 {
+		t.Errorf("dropped = %d, want 2", dropped)
+	}
+}
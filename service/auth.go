@@ -1,11 +1,14 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/metrics"        // 指標註冊
 	"github.com/wac0705/fastener-api/middleware/jwt" // 導入 JWT 相關函式
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/repository" // 導入 Repository 層
@@ -14,42 +17,68 @@ import (
 
 // AuthService 定義身份驗證服務介面
 type AuthService interface {
-	Login(username, password string) (accessToken, refreshToken string, account *models.Account, err error)
-	Register(username, password string, roleID int) (*models.Account, error)
-	RefreshToken(refreshToken string) (newAccessToken string, err error)
-    GetAccountByID(accountID int) (*models.Account, error) // 用於獲取我的資料
+	Login(ctx context.Context, username, password string) (accessToken, refreshToken string, account *models.Account, err error)
+	Register(ctx context.Context, username, password string, roleID int) (*models.Account, error)
+	RefreshToken(ctx context.Context, refreshToken string) (newAccessToken string, err error)
+	GetAccountByID(ctx context.Context, accountID int) (*models.Account, error) // 用於獲取我的資料
 }
 
 // authServiceImpl 實現 AuthService 介面
 type authServiceImpl struct {
 	accountRepo        repository.AccountRepository
 	roleRepo           repository.RoleRepository
+	accountHistoryRepo repository.AccountHistoryRepository
+	txManager          db.TxManager
 	jwtSecret          string
 	jwtAccessExpires   int
 	jwtRefreshExpires  int
+	loginAttempts      metrics.Counter // 依 outcome 標籤記錄登入結果
+	logger             *zap.Logger
 }
 
 // NewAuthService 創建 AuthService 實例
 func NewAuthService(
 	accountRepo repository.AccountRepository,
 	roleRepo repository.RoleRepository,
+	accountHistoryRepo repository.AccountHistoryRepository,
+	txManager db.TxManager, // 註冊的帳戶與帳戶歷史在同一事務中寫入
 	jwtSecret string,
 	jwtAccessExpires, jwtRefreshExpires int,
+	reg metrics.Registry, // 用於記錄登入結果
+	logger *zap.Logger,
 ) AuthService {
 	return &authServiceImpl{
-		accountRepo:       accountRepo,
-		roleRepo:          roleRepo,
-		jwtSecret:         jwtSecret,
-		jwtAccessExpires:  jwtAccessExpires,
-		jwtRefreshExpires: jwtRefreshExpires,
+		accountRepo:        accountRepo,
+		roleRepo:           roleRepo,
+		accountHistoryRepo: accountHistoryRepo,
+		txManager:          txManager,
+		jwtSecret:          jwtSecret,
+		jwtAccessExpires:   jwtAccessExpires,
+		jwtRefreshExpires:  jwtRefreshExpires,
+		loginAttempts:      reg.Counter("auth_login_attempts_total", "Total login attempts by outcome (success, invalid_credentials, error).", "outcome"),
+		logger:             logger,
 	}
 }
 
-// Login 處理用戶登入邏輯
-func (s *authServiceImpl) Login(username, password string) (string, string, *models.Account, error) {
-	account, err := s.accountRepo.FindByUsername(username)
+// Login 處理用戶登入邏輯，並依結果 (success、invalid_credentials、error) 記錄登入指標
+func (s *authServiceImpl) Login(ctx context.Context, username, password string) (string, string, *models.Account, error) {
+	accessToken, refreshToken, account, err := s.login(ctx, username, password)
+	outcome := "success"
 	if err != nil {
-		zap.L().Error("AuthService: Error finding account by username during login", zap.Error(err), zap.String("username", username))
+		outcome = "error"
+		if customErr, ok := err.(*utils.CustomError); ok && customErr.Code == http.StatusUnauthorized {
+			outcome = "invalid_credentials"
+		}
+	}
+	s.loginAttempts.Inc(outcome)
+	return accessToken, refreshToken, account, err
+}
+
+// login 驗證帳號密碼並簽發 Token，結果由 Login 記錄到指標
+func (s *authServiceImpl) login(ctx context.Context, username, password string) (string, string, *models.Account, error) {
+	account, err := s.accountRepo.FindByUsername(ctx, username)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Error finding account by username during login", zap.Error(err), zap.String("username", username))
 		return "", "", nil, utils.ErrInternalServer
 	}
 	if account == nil {
@@ -62,14 +91,14 @@ func (s *authServiceImpl) Login(username, password string) (string, string, *mod
 	}
 
 	// 獲取角色名稱 (用於返回給前端顯示)
-	role, err := s.roleRepo.FindByID(account.RoleID)
+	role, err := s.roleRepo.FindByID(ctx, account.RoleID)
 	if err != nil {
-		zap.L().Error("AuthService: Error finding role for account", zap.Error(err), zap.Int("account_id", account.ID))
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Error finding role for account", zap.Error(err), zap.Int("account_id", account.ID))
 		return "", "", nil, utils.ErrInternalServer
 	}
 	if role == nil {
 		// 這種情況不應該發生，表示數據不一致
-		zap.L().Error("AuthService: Role not found for account", zap.Int("account_id", account.ID), zap.Int("role_id", account.RoleID))
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Role not found for account", zap.Int("account_id", account.ID), zap.Int("role_id", account.RoleID))
 		return "", "", nil, utils.ErrInternalServer.SetDetails("Account role not configured correctly")
 	}
 	account.RoleName = role.Name
@@ -77,39 +106,19 @@ func (s *authServiceImpl) Login(username, password string) (string, string, *mod
 	// 生成 Access Token 和 Refresh Token
 	accessToken, refreshToken, err := jwt.GenerateAuthTokens(*account, s.jwtSecret, s.jwtAccessExpires, s.jwtRefreshExpires)
 	if err != nil {
-		zap.L().Error("AuthService: Failed to generate tokens during login", zap.Error(err), zap.Int("account_id", account.ID))
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Failed to generate tokens during login", zap.Error(err), zap.Int("account_id", account.ID))
 		return "", "", nil, utils.ErrInternalServer
 	}
 
 	return accessToken, refreshToken, account, nil
 }
 
-// Register 處理用戶註冊邏輯
-func (s *authServiceImpl) Register(username, password string, roleID int) (*models.Account, error) {
-	// 檢查用戶名是否已存在
-	existingAccount, err := s.accountRepo.FindByUsername(username)
-	if err != nil {
-		zap.L().Error("AuthService: Error checking existing account by username during registration", zap.Error(err), zap.String("username", username))
-		return nil, utils.ErrInternalServer
-	}
-	if existingAccount != nil {
-		return nil, utils.ErrBadRequest.SetDetails("Username already exists")
-	}
-
-	// 檢查角色 ID 是否有效
-	role, err := s.roleRepo.FindByID(roleID)
-	if err != nil {
-		zap.L().Error("AuthService: Error checking role ID during registration", zap.Error(err), zap.Int("role_id", roleID))
-		return nil, utils.ErrInternalServer
-	}
-	if role == nil {
-		return nil, utils.ErrBadRequest.SetDetails("Invalid Role ID")
-	}
-
+// Register 處理用戶註冊邏輯，與 AccountService.CreateAccount 相同在事務中檢查並寫入帳戶與帳戶歷史 (操作者為空)
+func (s *authServiceImpl) Register(ctx context.Context, username, password string, roleID int) (*models.Account, error) {
 	// 雜湊密碼
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
-		zap.L().Error("AuthService: Failed to hash password during registration", zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Failed to hash password during registration", zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
 
@@ -120,17 +129,22 @@ func (s *authServiceImpl) Register(username, password string, roleID int) (*mode
 		RoleID:   roleID,
 	}
 
-	// 調用 Repository 創建帳戶
-	if err := s.accountRepo.Create(newAccount); err != nil {
-		zap.L().Error("AuthService: Failed to create account in repository during registration", zap.Error(err), zap.String("username", username))
+	// 檢查用戶名與角色後創建帳戶，自行註冊沒有操作者
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		return createAccountWithHistory(ctx, s.logger, s.accountRepo, s.roleRepo, s.accountHistoryRepo, newAccount, nil)
+	})
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 用戶名已存在或與其他請求同時註冊相同用戶名 (409)、角色無效 (400)
+		}
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Failed to create account in repository during registration", zap.Error(err), zap.String("username", username))
 		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to register account: %v", err))
 	}
-	newAccount.RoleName = role.Name // 填充角色名稱
 	return newAccount, nil
 }
 
 // RefreshToken 處理 Refresh Token 刷新 Access Token 的邏輯
-func (s *authServiceImpl) RefreshToken(refreshToken string) (string, error) {
+func (s *authServiceImpl) RefreshToken(ctx context.Context, refreshToken string) (string, error) {
 	// 驗證 Refresh Token
 	claims, err := jwt.VerifyRefreshToken(refreshToken, s.jwtSecret)
 	if err != nil {
@@ -139,13 +153,13 @@ func (s *authServiceImpl) RefreshToken(refreshToken string) (string, error) {
 	}
 
 	// 查找對應的帳戶
-	account, err := s.accountRepo.FindByID(claims.AccountID)
+	account, err := s.accountRepo.FindByID(ctx, claims.AccountID)
 	if err != nil {
-		zap.L().Error("AuthService: Error finding account for refresh token", zap.Error(err), zap.Int("account_id", claims.AccountID))
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Error finding account for refresh token", zap.Error(err), zap.Int("account_id", claims.AccountID))
 		return "", utils.ErrInternalServer
 	}
 	if account == nil {
-		zap.L().Info("AuthService: Account not found for refresh token", zap.Int("account_id", claims.AccountID))
+		utils.ContextLogger(ctx, s.logger).Info("AuthService: Account not found for refresh token", zap.Int("account_id", claims.AccountID))
 		return "", utils.ErrUnauthorized.SetDetails("Invalid refresh token: Account not found")
 	}
 
@@ -156,7 +170,7 @@ func (s *authServiceImpl) RefreshToken(refreshToken string) (string, error) {
 	// 生成新的 Access Token
 	newAccessToken, _, err := jwt.GenerateAuthTokens(*account, s.jwtSecret, s.jwtAccessExpires, s.jwtRefreshExpires) // 只返回 Access Token
 	if err != nil {
-		zap.L().Error("AuthService: Failed to generate new access token during refresh", zap.Error(err), zap.Int("account_id", account.ID))
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Failed to generate new access token during refresh", zap.Error(err), zap.Int("account_id", account.ID))
 		return "", utils.ErrInternalServer
 	}
 
@@ -164,16 +178,16 @@ func (s *authServiceImpl) RefreshToken(refreshToken string) (string, error) {
 }
 
 // GetAccountByID 獲取帳戶資料，用於我的資料
-func (s *authServiceImpl) GetAccountByID(accountID int) (*models.Account, error) {
-    account, err := s.accountRepo.FindByID(accountID)
-    if err != nil {
-        zap.L().Error("AuthService: Failed to get account by ID", zap.Int("account_id", accountID), zap.Error(err))
-        return nil, utils.ErrInternalServer
-    }
-    if account == nil {
-        return nil, nil // 未找到
-    }
-    account.Password = "" // 清除密碼
-
-    return account, nil
+func (s *authServiceImpl) GetAccountByID(ctx context.Context, accountID int) (*models.Account, error) {
+	account, err := s.accountRepo.FindByID(ctx, accountID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("AuthService: Failed to get account by ID", zap.Int("account_id", accountID), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if account == nil {
+		return nil, nil // 未找到
+	}
+	account.Password = "" // 清除密碼
+
+	return account, nil
 }
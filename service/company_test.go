@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+func TestCompanyServiceCreateCompany(t *testing.T) {
+	parentID, missingID := 1, 9
+	companies := map[int]*models.Company{parentID: {ID: parentID, Name: "Parent Holdings"}}
+
+	tests := []struct {
+		name        string
+		repo        fakeCompanyRepository
+		company     models.Company
+		wantErr     *utils.CustomError // nil 表示成功
+		wantDetails interface{}
+	}{
+		{name: "top-level company", repo: fakeCompanyRepository{companies: companies}, company: models.Company{Name: "Acme"}},
+		{name: "with existing parent", repo: fakeCompanyRepository{companies: companies}, company: models.Company{Name: "Acme", ParentCompanyID: &parentID}},
+		{
+			name: "parent does not exist", repo: fakeCompanyRepository{companies: companies}, company: models.Company{Name: "Acme", ParentCompanyID: &missingID},
+			wantErr: utils.ErrBadRequest, wantDetails: "Provided Parent Company ID does not exist.",
+		},
+		{
+			name: "existing company with the same ID", repo: fakeCompanyRepository{companies: companies}, company: models.Company{ID: parentID, Name: "Acme"},
+			wantErr: utils.ErrConflict, wantDetails: "Company with this name already exists.",
+		},
+		{
+			name: "lookup fails", repo: fakeCompanyRepository{companies: companies, findErr: errDatabase}, company: models.Company{Name: "Acme"},
+			wantErr: utils.ErrInternalServer,
+		},
+		{
+			name: "unique conflict from repository", repo: fakeCompanyRepository{companies: companies, createErr: utils.ErrConflict.SetDetails("Company name already exists")}, company: models.Company{Name: "Acme"},
+			wantErr: utils.ErrConflict, wantDetails: "Company name already exists",
+		},
+		{
+			name: "create fails", repo: fakeCompanyRepository{companies: companies, createErr: errDatabase}, company: models.Company{Name: "Acme"},
+			wantErr: utils.ErrInternalServer, wantDetails: "Failed to create company: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, company := tt.repo, tt.company
+			err := NewCompanyService(&repo, zap.NewNop()).CreateCompany(context.Background(), &company, 7)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("CreateCompany: %v", err)
+				}
+				if len(repo.created) != 1 || company.ID == 0 {
+					t.Errorf("created = %v, company ID = %d", repo.created, company.ID)
+				}
+				return
+			}
+			var customErr *utils.CustomError
+			if !errors.As(err, &customErr) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateCompany error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantDetails != nil && customErr.Details != tt.wantDetails {
+				t.Errorf("details = %v, want %v", customErr.Details, tt.wantDetails)
+			}
+			if len(repo.created) != 0 {
+				t.Errorf("company was created despite the error: %v", repo.created)
+			}
+		})
+	}
+}
+
+func TestCompanyServiceRestoreCompany(t *testing.T) {
+	const companyID = 1
+	companies := map[int]*models.Company{companyID: {ID: companyID, Name: "Acme"}}
+
+	tests := []struct {
+		name        string
+		repo        fakeCompanyRepository
+		wantErr     *utils.CustomError // nil 表示成功
+		wantDetails interface{}
+	}{
+		{name: "restored", repo: fakeCompanyRepository{companies: companies}},
+		{name: "not deleted or missing", repo: fakeCompanyRepository{companies: companies, restoreErr: utils.ErrNotFound}, wantErr: utils.ErrNotFound},
+		{
+			name: "merged company", repo: fakeCompanyRepository{companies: companies, restoreErr: utils.ErrConflict.SetDetails("Merged companies cannot be restored")},
+			wantErr: utils.ErrConflict, wantDetails: "Merged companies cannot be restored",
+		},
+		{
+			name: "restore fails", repo: fakeCompanyRepository{companies: companies, restoreErr: errDatabase},
+			wantErr: utils.ErrInternalServer, wantDetails: "Failed to restore company: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			company, err := NewCompanyService(&repo, zap.NewNop()).RestoreCompany(context.Background(), companyID, 7)
+			if tt.wantErr == nil {
+				if err != nil || company == nil || company.ID != companyID {
+					t.Fatalf("RestoreCompany = %+v, %v", company, err)
+				}
+				if len(repo.restored) != 1 {
+					t.Errorf("restored = %v, want [%d]", repo.restored, companyID)
+				}
+				return
+			}
+			var customErr *utils.CustomError
+			if !errors.As(err, &customErr) || !errors.Is(err, tt.wantErr) || company != nil {
+				t.Fatalf("RestoreCompany = %+v, %v, want %v", company, err, tt.wantErr)
+			}
+			if tt.wantDetails != nil && customErr.Details != tt.wantDetails {
+				t.Errorf("details = %v, want %v", customErr.Details, tt.wantDetails)
+			}
+		})
+	}
+}
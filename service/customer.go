@@ -1,85 +1,375 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/tracing"
 	"github.com/wac0705/fastener-api/utils"
 )
 
 // CustomerService 定義客戶服務介面
 type CustomerService interface {
-	GetAllCustomers() ([]models.Customer, error)
-	GetCustomerByID(id int) (*models.Customer, error)
-	CreateCustomer(customer *models.Customer) error
-	UpdateCustomer(customer *models.Customer) error
-	DeleteCustomer(id int) error
+	GetAllCustomers(ctx context.Context, filter models.CustomerFilter, pagination utils.Pagination) (*models.PaginatedResponse, error)
+	ExportCustomers(ctx context.Context, filter models.CustomerFilter, maxRows int, write func(batch []models.Customer) error) error // 逐批輸出符合條件的客戶
+	GetCustomerByID(ctx context.Context, id int) (*models.Customer, error)
+	ResolvePublicID(ctx context.Context, publicID string) (int, error)            // 將對外的 UUID 識別碼 (public_id) 轉換為 ID，不存在時返回 utils.ErrNotFound
+	GetCustomerByCode(ctx context.Context, code string) (*models.Customer, error) // 以 ERP 使用的客戶代碼查詢
+	GetCustomersByCompanyID(ctx context.Context, companyID int, includeDescendants bool) ([]models.Customer, error)
+	CreateCustomer(ctx context.Context, customer *models.Customer, actorID int) error // actorID 為執行變更的帳戶，記錄在客戶歷史中
+	UpdateCustomer(ctx context.Context, customer *models.Customer, actorID int) error
+	DeleteCustomer(ctx context.Context, id int, actorID int) error
+	RestoreCustomer(ctx context.Context, id int, actorID int) (*models.Customer, error)                                                   // 還原已軟刪除的客戶
+	GetCustomerHistory(ctx context.Context, customerID int, field string, pagination utils.Pagination) (*models.PaginatedResponse, error) // 欄位層級的變更歷史
+	ImportCustomers(ctx context.Context, rows []models.CustomerImportRow, onConflict models.ImportConflictMode, createCompanies bool, actorID int, dryRun bool) ([]models.ImportRowResult, error)
+	CheckDuplicateCustomers(ctx context.Context, req models.CustomerDuplicateCheckRequest, nameThreshold float64) ([]models.CustomerDuplicateCandidate, error) // 建立前找出可能重複的客戶
+
+	// 客戶地址 (帳單/送貨)
+	GetCustomerAddresses(ctx context.Context, customerID int) ([]models.CustomerAddress, error)
+	CreateCustomerAddress(ctx context.Context, address *models.CustomerAddress) error
+	UpdateCustomerAddress(ctx context.Context, address *models.CustomerAddress) error
+	DeleteCustomerAddress(ctx context.Context, customerID, id int) error
+
+	// 客戶備註時間軸
+	GetCustomerNotes(ctx context.Context, customerID int, pinnedFirst bool, pagination utils.Pagination) (*models.PaginatedResponse, error)
+	CreateCustomerNote(ctx context.Context, note *models.CustomerNote) error
+	DeleteCustomerNote(ctx context.Context, customerID, noteID int, requesterAccountID int, requesterRoleID int) error
 }
 
 // customerServiceImpl 實現 CustomerService 介面
 type customerServiceImpl struct {
-	customerRepo repository.CustomerRepository
-	companyRepo  repository.CompanyRepository // 依賴 CompanyRepository 檢查公司是否存在
+	customerRepo        repository.CustomerRepository
+	companyRepo         repository.CompanyRepository // 依賴 CompanyRepository 檢查公司是否存在
+	addressRepo         repository.CustomerAddressRepository
+	noteRepo            repository.CustomerNoteRepository
+	historyRepo         repository.CustomerHistoryRepository
+	accountRepo         repository.AccountRepository // 依賴 AccountRepository 檢查業務代表是否存在且啟用
+	defaultPhoneCountry string                       // 本地格式電話號碼預設的國家，例如 "TW"
+	codePrefix          string                       // 自動產生客戶代碼的前綴，例如 "C"
+	logger              *zap.Logger
 }
 
 // NewCustomerService 創建 CustomerService 實例
-func NewCustomerService(customerRepo repository.CustomerRepository, companyRepo repository.CompanyRepository) CustomerService {
-	return &customerServiceImpl{customerRepo: customerRepo, companyRepo: companyRepo}
+func NewCustomerService(customerRepo repository.CustomerRepository, companyRepo repository.CompanyRepository, addressRepo repository.CustomerAddressRepository, noteRepo repository.CustomerNoteRepository, historyRepo repository.CustomerHistoryRepository, accountRepo repository.AccountRepository, defaultPhoneCountry, codePrefix string, logger *zap.Logger) CustomerService {
+	return &customerServiceImpl{customerRepo: customerRepo, companyRepo: companyRepo, addressRepo: addressRepo, noteRepo: noteRepo, historyRepo: historyRepo, accountRepo: accountRepo, defaultPhoneCountry: defaultPhoneCountry, codePrefix: codePrefix, logger: logger}
 }
 
-// CreateCustomer 創建新客戶
-func (s *customerServiceImpl) CreateCustomer(customer *models.Customer) error {
+// CreateCustomer 創建新客戶，並記錄 created 事件
+func (s *customerServiceImpl) CreateCustomer(ctx context.Context, customer *models.Customer, actorID int) error {
+	ctx, span := tracing.Start(ctx, "CustomerService.CreateCustomer")
+	defer span.End()
 	// 如果提供了 company_id，檢查公司是否存在
 	if customer.CompanyID != nil {
-		company, err := s.companyRepo.FindByID(*customer.CompanyID)
+		company, err := s.companyRepo.FindByID(ctx, *customer.CompanyID)
 		if err != nil {
-			zap.L().Error("Service: Error checking company ID for new customer", zap.Error(err), zap.Int("company_id", *customer.CompanyID))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking company ID for new customer", zap.Error(err), zap.Int("company_id", *customer.CompanyID))
 			return utils.ErrInternalServer
 		}
 		if company == nil {
 			return utils.ErrBadRequest.SetDetails("Provided Company ID does not exist.")
 		}
+		if customer.Currency == "" {
+			customer.Currency = company.Currency // 未指定幣別時沿用公司的幣別
+		}
+	}
+
+	if err := s.validateSalesRep(ctx, customer.SalesRepAccountID); err != nil {
+		return err
+	}
+
+	if err := s.normalizePhone(customer); err != nil {
+		return err
+	}
+
+	if customer.Status == "" {
+		customer.Status = models.CustomerStatusProspect
 	}
 
-	if err := s.customerRepo.Create(customer); err != nil {
-		zap.L().Error("Service: Failed to create customer in repository", zap.Error(err), zap.String("name", customer.Name))
+	if err := s.checkEmailConflict(ctx, customer.Email, 0); err != nil {
+		return err
+	}
+
+	customer.Code = "" // 客戶代碼一律由系統產生
+	history := []models.CustomerHistory{{Event: models.CustomerEventCreated, ActorID: &actorID}}
+	if err := s.customerRepo.Create(ctx, customer, s.codePrefix, actorID, history); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如寫入時才發現的 Email 衝突
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create customer in repository", zap.Error(err), zap.String("name", customer.Name))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create customer: %v", err))
 	}
 	return nil
 }
 
-// GetAllCustomers 獲取所有客戶
-func (s *customerServiceImpl) GetAllCustomers() ([]models.Customer, error) {
-	customers, err := s.customerRepo.FindAll()
+// normalizePhone 以預設國家將客戶電話轉為 E.164 並填入 PhoneNormalized，原始輸入保留在 Phone
+func (s *customerServiceImpl) normalizePhone(customer *models.Customer) error {
+	normalized, err := utils.NormalizePhone(customer.Phone, s.defaultPhoneCountry)
+	if err != nil {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid phone number: %s", customer.Phone))
+	}
+	customer.PhoneNormalized = normalized
+	return nil
+}
+
+// validateSalesRep 檢查指派的業務代表帳戶存在且為啟用狀態，未指派時不檢查
+func (s *customerServiceImpl) validateSalesRep(ctx context.Context, accountID *int) error {
+	if accountID == nil {
+		return nil
+	}
+	account, err := s.accountRepo.FindByID(ctx, *accountID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking sales rep account for customer", zap.Error(err), zap.Int("account_id", *accountID))
+		return utils.ErrInternalServer
+	}
+	if account == nil {
+		return utils.ErrBadRequest.SetDetails("Provided sales rep account does not exist.")
+	}
+	if !account.IsActive {
+		return utils.ErrBadRequest.SetDetails("Provided sales rep account is inactive.")
+	}
+	return nil
+}
+
+// checkEmailConflict 檢查 Email 是否已被其他客戶使用 (不分大小寫)，excludeID 為正在更新的客戶 ID
+// 空 Email 不會衝突
+func (s *customerServiceImpl) checkEmailConflict(ctx context.Context, email string, excludeID int) error {
+	if email == "" {
+		return nil
+	}
+	existing, err := s.customerRepo.FindByEmail(ctx, email)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking customer email uniqueness", zap.Error(err), zap.String("email", email))
+		return utils.ErrInternalServer
+	}
+	if existing != nil && existing.ID != excludeID {
+		return utils.NewConflictError("Customer email already exists", models.CustomerConflict{Field: "email", ExistingCustomerID: existing.ID})
+	}
+	return nil
+}
+
+// GetAllCustomers 依搜尋條件分頁獲取客戶
+func (s *customerServiceImpl) GetAllCustomers(ctx context.Context, filter models.CustomerFilter, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	ctx, span := tracing.Start(ctx, "CustomerService.GetAllCustomers")
+	defer span.End()
+	customers, page, err := s.customerRepo.FindAll(ctx, filter, pagination)
 	if err != nil {
-		zap.L().Error("Service: Failed to get all customers", zap.Error(err))
+		if _, ok := err.(*utils.CustomError); ok {
+			return nil, err // 例如不合法的排序欄位
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all customers", zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
-	return customers, nil
+	return models.NewPageResponse(customers, pagination.Page, pagination.PageSize, page), nil
+}
+
+// customerExportBatchSize 匯出時每批從資料庫讀取的筆數
+const customerExportBatchSize = 500
+
+// customerDuplicateLimit 重複檢查最多返回的候選筆數
+const customerDuplicateLimit = 10
+
+// customerCodePattern 客戶代碼格式：大寫英數字前綴、連字號與至少 6 位數字，例如 "C-000123"
+var customerCodePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{0,9}-[0-9]{6,}$`)
+
+// CheckDuplicateCustomers 依 Email、電話與名稱找出可能重複的既有客戶，最多返回 customerDuplicateLimit 筆
+func (s *customerServiceImpl) CheckDuplicateCustomers(ctx context.Context, req models.CustomerDuplicateCheckRequest, nameThreshold float64) ([]models.CustomerDuplicateCandidate, error) {
+	name := strings.TrimSpace(req.Name)
+	email := strings.TrimSpace(req.Email)
+	if name == "" && email == "" && strings.TrimSpace(req.Phone) == "" {
+		return nil, utils.ErrBadRequest.SetDetails("At least one of name, email or phone is required")
+	}
+	phoneNormalized, err := utils.NormalizePhone(req.Phone, s.defaultPhoneCountry)
+	if err != nil {
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid phone number: %s", req.Phone))
+	}
+
+	candidates, err := s.customerRepo.FindDuplicates(ctx, name, email, phoneNormalized, nameThreshold, customerDuplicateLimit)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to check duplicate customers", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	return candidates, nil
+}
+
+// ExportCustomers 依篩選條件逐批輸出客戶，符合條件的筆數超過 maxRows 時返回 413 錯誤且不輸出任何資料
+func (s *customerServiceImpl) ExportCustomers(ctx context.Context, filter models.CustomerFilter, maxRows int, write func(batch []models.Customer) error) error {
+	total, err := s.customerRepo.Count(ctx, filter)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to count customers for export", zap.Error(err))
+		return utils.ErrInternalServer
+	}
+	if total > maxRows {
+		return utils.NewCustomError(http.StatusRequestEntityTooLarge, "Export too large",
+			fmt.Sprintf("%d customers match the filters, but at most %d can be exported at once; narrow the filters and try again", total, maxRows))
+	}
+
+	if err := s.customerRepo.StreamAll(ctx, filter, customerExportBatchSize, write); err != nil {
+		if _, ok := err.(*utils.CustomError); ok {
+			return err // 例如不合法的排序欄位
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to export customers", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetCustomerByID 根據 ID 獲取客戶 (包含地址)
+func (s *customerServiceImpl) GetCustomerByID(ctx context.Context, id int) (*models.Customer, error) {
+	customer, err := s.customerRepo.FindByID(ctx, id)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get customer by ID", zap.Int("id", id), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if customer == nil {
+		return nil, nil // Repository 返回 nil, nil 表示未找到
+	}
+
+	addresses, err := s.addressRepo.FindByCustomerID(ctx, id)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get addresses for customer", zap.Int("id", id), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	customer.Addresses = addresses
+	return customer, nil
+}
+
+// ResolvePublicID 根據對外的 UUID 識別碼返回客戶 ID (包含已刪除的客戶，由之後的操作決定是否返回 404，例如還原)
+func (s *customerServiceImpl) ResolvePublicID(ctx context.Context, publicID string) (int, error) {
+	customer, err := s.customerRepo.FindByPublicID(ctx, publicID, repository.FindOptions{IncludeDeleted: true})
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get customer by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return 0, utils.ErrInternalServer
+	}
+	if customer == nil {
+		return 0, utils.ErrNotFound
+	}
+	return customer.ID, nil
 }
 
-// GetCustomerByID 根據 ID 獲取客戶
-func (s *customerServiceImpl) GetCustomerByID(id int) (*models.Customer, error) {
-	customer, err := s.customerRepo.FindByID(id)
+// GetCustomerByCode 根據客戶代碼獲取客戶 (包含地址)
+func (s *customerServiceImpl) GetCustomerByCode(ctx context.Context, code string) (*models.Customer, error) {
+	customer, err := s.customerRepo.FindByCode(ctx, code)
 	if err != nil {
-		zap.L().Error("Service: Failed to get customer by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get customer by code", zap.String("code", code), zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
 	if customer == nil {
 		return nil, nil // Repository 返回 nil, nil 表示未找到
 	}
+
+	addresses, err := s.addressRepo.FindByCustomerID(ctx, customer.ID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get addresses for customer", zap.Int("id", customer.ID), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	customer.Addresses = addresses
 	return customer, nil
 }
 
+// GetCustomersByCompanyID 根據公司 ID 獲取客戶，可選擇包含所有子孫公司的客戶
+func (s *customerServiceImpl) GetCustomersByCompanyID(ctx context.Context, companyID int, includeDescendants bool) ([]models.Customer, error) {
+	customers, err := s.customerRepo.FindByCompanyID(ctx, companyID, includeDescendants)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get customers by company ID", zap.Int("company_id", companyID), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	return customers, nil
+}
+
+// customerStatusTransitions 允許的狀態轉換，值為 true 表示需要提供原因
+var customerStatusTransitions = map[string]map[string]bool{
+	models.CustomerStatusProspect: {models.CustomerStatusActive: false, models.CustomerStatusInactive: true},
+	models.CustomerStatusActive:   {models.CustomerStatusInactive: false},
+	models.CustomerStatusInactive: {models.CustomerStatusActive: false},
+}
+
+// validateStatusTransition 檢查客戶狀態能否從 from 轉換為 to
+func validateStatusTransition(from, to, reason string) error {
+	if from == to {
+		return nil
+	}
+	reasonRequired, ok := customerStatusTransitions[from][to]
+	if !ok {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Customer status cannot change from %s to %s", from, to))
+	}
+	if reasonRequired && strings.TrimSpace(reason) == "" {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("status_reason is required to change customer status from %s to %s", from, to))
+	}
+	return nil
+}
+
+// customerHistoryFields 記錄變更歷史的欄位 (JSON 名稱)，也是 GET /customers/:id/history 的 field 參數可用值
+var customerHistoryFields = []string{"name", "contact_person", "email", "phone", "company_id", "currency", "payment_terms", "status", "sales_rep_account_id"}
+
+// customerHistoryValues 返回 customerHistoryFields 各欄位的字串值，空值為 nil
+func customerHistoryValues(customer *models.Customer) map[string]*string {
+	str := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	id := func(v *int) *string {
+		if v == nil {
+			return nil
+		}
+		return str(strconv.Itoa(*v))
+	}
+	return map[string]*string{
+		"name":                 str(customer.Name),
+		"contact_person":       str(customer.ContactPerson),
+		"email":                str(customer.Email),
+		"phone":                str(customer.Phone),
+		"company_id":           id(customer.CompanyID),
+		"currency":             str(customer.Currency),
+		"payment_terms":        str(customer.PaymentTerms),
+		"status":               str(customer.Status),
+		"sales_rep_account_id": id(customer.SalesRepAccountID),
+	}
+}
+
+// diffCustomer 比較更新前後的客戶，每個變更的欄位產生一筆 updated 歷史；狀態變更附上 StatusReason
+func diffCustomer(before, after *models.Customer, actorID int) []models.CustomerHistory {
+	oldValues, newValues := customerHistoryValues(before), customerHistoryValues(after)
+	history := []models.CustomerHistory{}
+	for _, field := range customerHistoryFields {
+		oldValue, newValue := oldValues[field], newValues[field]
+		if (oldValue == nil && newValue == nil) || (oldValue != nil && newValue != nil && *oldValue == *newValue) {
+			continue
+		}
+		entry := models.CustomerHistory{
+			CustomerID: after.ID,
+			Event:      models.CustomerEventUpdated,
+			Field:      field,
+			OldValue:   oldValue,
+			NewValue:   newValue,
+			ActorID:    &actorID,
+		}
+		if field == "status" {
+			entry.Reason = strings.TrimSpace(after.StatusReason)
+		}
+		history = append(history, entry)
+	}
+	return history
+}
+
 // UpdateCustomer 更新客戶信息
-func (s *customerServiceImpl) UpdateCustomer(customer *models.Customer) error {
+// 狀態變更需符合 customerStatusTransitions；每個變更的欄位以 actorID 記錄在客戶歷史中
+func (s *customerServiceImpl) UpdateCustomer(ctx context.Context, customer *models.Customer, actorID int) error {
+	ctx, span := tracing.Start(ctx, "CustomerService.UpdateCustomer")
+	defer span.End()
 	// 檢查客戶是否存在
-	existingCustomer, err := s.customerRepo.FindByID(customer.ID)
+	existingCustomer, err := s.customerRepo.FindByID(ctx, customer.ID)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing customer for update", zap.Error(err), zap.Int("customer_id", customer.ID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing customer for update", zap.Error(err), zap.Int("customer_id", customer.ID))
 		return utils.ErrInternalServer
 	}
 	if existingCustomer == nil {
@@ -88,38 +378,172 @@ func (s *customerServiceImpl) UpdateCustomer(customer *models.Customer) error {
 
 	// 如果提供了新的 company_id，檢查公司是否存在
 	if customer.CompanyID != nil {
-		company, err := s.companyRepo.FindByID(*customer.CompanyID)
+		company, err := s.companyRepo.FindByID(ctx, *customer.CompanyID)
 		if err != nil {
-			zap.L().Error("Service: Error checking company ID for customer update", zap.Error(err), zap.Int("company_id", *customer.CompanyID))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking company ID for customer update", zap.Error(err), zap.Int("company_id", *customer.CompanyID))
 			return utils.ErrInternalServer
 		}
 		if company == nil {
 			return utils.ErrBadRequest.SetDetails("Provided Company ID for update does not exist.")
 		}
+		if customer.Currency == "" {
+			customer.Currency = company.Currency // 未指定幣別時沿用公司的幣別
+		}
+	}
+
+	if err := s.validateSalesRep(ctx, customer.SalesRepAccountID); err != nil {
+		return err
+	}
+
+	if err := s.normalizePhone(customer); err != nil {
+		return err
+	}
+
+	if err := s.checkEmailConflict(ctx, customer.Email, customer.ID); err != nil {
+		return err
+	}
+
+	customer.Code = existingCustomer.Code // 客戶代碼建立後不可修改
+	if customer.Status == "" {
+		customer.Status = existingCustomer.Status // 未指定時保留原狀態
+	}
+	if err := validateStatusTransition(existingCustomer.Status, customer.Status, customer.StatusReason); err != nil {
+		return err
 	}
+	history := diffCustomer(existingCustomer, customer, actorID)
 
-	if err := s.customerRepo.Update(customer); err != nil {
-		zap.L().Error("Service: Failed to update customer in repository", zap.Error(err), zap.Int("customer_id", customer.ID))
+	if err := s.customerRepo.Update(ctx, customer, actorID, history); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update customer in repository", zap.Error(err), zap.Int("customer_id", customer.ID))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update customer: %v", err))
 	}
 	return nil
 }
 
-// DeleteCustomer 刪除客戶
-func (s *customerServiceImpl) DeleteCustomer(id int) error {
+// DeleteCustomer 刪除客戶，並記錄 deleted 事件
+func (s *customerServiceImpl) DeleteCustomer(ctx context.Context, id int, actorID int) error {
 	// 檢查客戶是否存在
-	existingCustomer, err := s.customerRepo.FindByID(id)
+	existingCustomer, err := s.customerRepo.FindByID(ctx, id)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing customer for delete", zap.Error(err), zap.Int("customer_id", id))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing customer for delete", zap.Error(err), zap.Int("customer_id", id))
 		return utils.ErrInternalServer
 	}
 	if existingCustomer == nil {
 		return utils.ErrNotFound
 	}
 
-	if err := s.customerRepo.Delete(id); err != nil {
-		zap.L().Error("Service: Failed to delete customer in repository", zap.Error(err), zap.Int("customer_id", id))
+	history := []models.CustomerHistory{{CustomerID: id, Event: models.CustomerEventDeleted, ActorID: &actorID}}
+	if err := s.customerRepo.Delete(ctx, id, actorID, history); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete customer in repository", zap.Error(err), zap.Int("customer_id", id))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete customer: %v", err))
 	}
 	return nil
 }
+
+// RestoreCustomer 還原已軟刪除的客戶，記錄 restored 事件並返回還原後的客戶資料
+func (s *customerServiceImpl) RestoreCustomer(ctx context.Context, id int, actorID int) (*models.Customer, error) {
+	history := []models.CustomerHistory{{CustomerID: id, Event: models.CustomerEventRestored, ActorID: &actorID}}
+	if err := s.customerRepo.Restore(ctx, id, actorID, history); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 未找到或 Email 衝突
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to restore customer in repository", zap.Error(err), zap.Int("customer_id", id))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to restore customer: %v", err))
+	}
+	return s.GetCustomerByID(ctx, id)
+}
+
+// ImportCustomers 批次匯入已通過驗證的客戶資料，依 Email upsert
+// 透過 CompanyName 指定的公司以名稱解析為 ID；找不到時若 createCompanies 為 true 則在匯入事務中建立，否則該列標記為失敗。
+// 新增與更新的客戶 (及自動建立的公司) 記錄 actorID 為 created_by/updated_by；所有寫入在同一事務中完成，dryRun 為 true 時只回報結果，不寫入資料
+func (s *customerServiceImpl) ImportCustomers(ctx context.Context, rows []models.CustomerImportRow, onConflict models.ImportConflictMode, createCompanies bool, actorID int, dryRun bool) ([]models.ImportRowResult, error) {
+	ctx, span := tracing.Start(ctx, "CustomerService.ImportCustomers")
+	defer span.End()
+	results := []models.ImportRowResult{}
+	resolved := make([]models.CustomerImportRow, 0, len(rows))
+	companyIDs := map[string]*int{} // 公司名稱 => ID (nil 表示不存在)
+	for _, row := range rows {
+		row.Customer.Code = strings.ToUpper(strings.TrimSpace(row.Customer.Code))
+		if row.Customer.Code != "" && !customerCodePattern.MatchString(row.Customer.Code) {
+			results = append(results, models.ImportRowResult{
+				Line:    row.Line,
+				Action:  models.ImportActionInvalid,
+				Details: fmt.Sprintf("Invalid customer code: %s (expected format like C-000123)", row.Customer.Code),
+			})
+			continue
+		}
+		if err := s.normalizePhone(&row.Customer); err != nil {
+			results = append(results, models.ImportRowResult{
+				Line:    row.Line,
+				Action:  models.ImportActionInvalid,
+				Details: fmt.Sprintf("Invalid phone number: %s", row.Customer.Phone),
+			})
+			continue
+		}
+		if row.CompanyName != "" {
+			companyID, ok := companyIDs[row.CompanyName]
+			if !ok {
+				company, err := s.companyRepo.FindByName(ctx, row.CompanyName)
+				if err != nil {
+					utils.ContextLogger(ctx, s.logger).Error("Service: Failed to resolve company name for customer import", zap.Error(err), zap.Int("line", row.Line))
+					return nil, utils.ErrInternalServer
+				}
+				if company != nil {
+					companyID = &company.ID
+				}
+				companyIDs[row.CompanyName] = companyID
+			}
+			if companyID == nil && !createCompanies {
+				results = append(results, models.ImportRowResult{
+					Line:    row.Line,
+					Action:  models.ImportActionInvalid,
+					Details: fmt.Sprintf("Company not found: %s", row.CompanyName),
+				})
+				continue
+			}
+			row.Customer.CompanyID = companyID
+			if companyID != nil {
+				row.CompanyName = "" // 已存在，不需要建立
+			}
+		}
+		resolved = append(resolved, row)
+	}
+	if len(resolved) == 0 {
+		return results, nil
+	}
+
+	written, err := s.customerRepo.ImportBatch(ctx, resolved, onConflict, s.codePrefix, actorID, dryRun)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to import customers", zap.Error(err), zap.Int("rows", len(resolved)), zap.Bool("dry_run", dryRun))
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Import aborted, no rows were written: %v", err))
+	}
+	return append(results, written...), nil
+}
+
+// GetCustomerHistory 分頁獲取客戶的變更歷史，依時間由新到舊；field 不為空時只返回該欄位的變更
+func (s *customerServiceImpl) GetCustomerHistory(ctx context.Context, customerID int, field string, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	if field != "" {
+		known := false
+		for _, f := range customerHistoryFields {
+			if f == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid field: %s", field))
+		}
+	}
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	history, total, err := s.historyRepo.FindByCustomerID(ctx, customerID, field, pagination)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get customer history", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, utils.ErrInternalServer
+	}
+	return &models.PaginatedResponse{Data: history, Total: &total, Page: pagination.Page, PageSize: pagination.PageSize}, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// postalCodePatterns 各國郵遞區號格式，未列出的國家只檢查長度
+var postalCodePatterns = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"TW": regexp.MustCompile(`^\d{3}(\d{2,3})?$`),
+}
+
+// stateRequiredCountries 地址必須填寫州/省的國家
+var stateRequiredCountries = map[string]bool{
+	"US": true,
+	"CA": true,
+}
+
+// validateAddressForCountry 依國家檢查州/省與郵遞區號
+func validateAddressForCountry(address *models.CustomerAddress) error {
+	if stateRequiredCountries[address.Country] && address.State == "" {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("State is required for addresses in %s", address.Country))
+	}
+	if pattern, ok := postalCodePatterns[address.Country]; ok && !pattern.MatchString(address.PostalCode) {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid postal code for %s", address.Country))
+	}
+	return nil
+}
+
+// ensureCustomerExists 檢查客戶存在且未被刪除
+func (s *customerServiceImpl) ensureCustomerExists(ctx context.Context, customerID int) error {
+	customer, err := s.customerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking customer for address", zap.Error(err), zap.Int("customer_id", customerID))
+		return utils.ErrInternalServer
+	}
+	if customer == nil {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+// GetCustomerAddresses 獲取客戶的所有地址
+func (s *customerServiceImpl) GetCustomerAddresses(ctx context.Context, customerID int) ([]models.CustomerAddress, error) {
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return nil, err
+	}
+	addresses, err := s.addressRepo.FindByCustomerID(ctx, customerID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get customer addresses", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, utils.ErrInternalServer
+	}
+	return addresses, nil
+}
+
+// CreateCustomerAddress 為客戶新增地址；該類型的第一個地址自動成為預設地址
+func (s *customerServiceImpl) CreateCustomerAddress(ctx context.Context, address *models.CustomerAddress) error {
+	if err := s.ensureCustomerExists(ctx, address.CustomerID); err != nil {
+		return err
+	}
+	address.Country = strings.ToUpper(address.Country)
+	if err := validateAddressForCountry(address); err != nil {
+		return err
+	}
+
+	count, err := s.addressRepo.CountByType(ctx, address.CustomerID, address.Type)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to count customer addresses", zap.Error(err), zap.Int("customer_id", address.CustomerID))
+		return utils.ErrInternalServer
+	}
+	if count == 0 {
+		address.IsDefault = true
+	}
+
+	if err := s.addressRepo.Create(ctx, address); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create customer address in repository", zap.Error(err), zap.Int("customer_id", address.CustomerID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create customer address: %v", err))
+	}
+	return nil
+}
+
+// UpdateCustomerAddress 更新客戶地址；設為預設時會取消同類型其他地址的預設，
+// 取消預設或變更類型後，若該類型沒有預設地址則由最早建立的地址遞補
+func (s *customerServiceImpl) UpdateCustomerAddress(ctx context.Context, address *models.CustomerAddress) error {
+	if err := s.ensureCustomerExists(ctx, address.CustomerID); err != nil {
+		return err
+	}
+	existing, err := s.addressRepo.FindByID(ctx, address.CustomerID, address.ID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing customer address for update", zap.Error(err), zap.Int("address_id", address.ID))
+		return utils.ErrInternalServer
+	}
+	if existing == nil {
+		return utils.ErrNotFound
+	}
+
+	address.Country = strings.ToUpper(address.Country)
+	if err := validateAddressForCountry(address); err != nil {
+		return err
+	}
+
+	if err := s.addressRepo.Update(ctx, address); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update customer address in repository", zap.Error(err), zap.Int("address_id", address.ID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update customer address: %v", err))
+	}
+
+	for _, addressType := range []string{existing.Type, address.Type} {
+		if err := s.addressRepo.PromoteDefault(ctx, address.CustomerID, addressType); err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Failed to promote default customer address", zap.Error(err), zap.Int("customer_id", address.CustomerID))
+			return utils.ErrInternalServer
+		}
+	}
+	if !address.IsDefault {
+		// 遞補時可能選中此地址，重新讀取以返回正確的預設狀態
+		if updated, err := s.addressRepo.FindByID(ctx, address.CustomerID, address.ID); err == nil && updated != nil {
+			*address = *updated
+		}
+	}
+	return nil
+}
+
+// DeleteCustomerAddress 刪除客戶地址；刪除預設地址後由同類型最早建立的地址遞補
+func (s *customerServiceImpl) DeleteCustomerAddress(ctx context.Context, customerID, id int) error {
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return err
+	}
+	existing, err := s.addressRepo.FindByID(ctx, customerID, id)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing customer address for delete", zap.Error(err), zap.Int("address_id", id))
+		return utils.ErrInternalServer
+	}
+	if existing == nil {
+		return utils.ErrNotFound
+	}
+
+	if err := s.addressRepo.Delete(ctx, customerID, id); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete customer address in repository", zap.Error(err), zap.Int("address_id", id))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete customer address: %v", err))
+	}
+	if existing.IsDefault {
+		if err := s.addressRepo.PromoteDefault(ctx, customerID, existing.Type); err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Failed to promote default customer address", zap.Error(err), zap.Int("customer_id", customerID))
+			return utils.ErrInternalServer
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// adminRoleID 管理員角色 ID，與 authz 中介軟體的假設一致
+const adminRoleID = 1
+
+// GetCustomerNotes 分頁獲取客戶的備註時間軸 (由新到舊)
+func (s *customerServiceImpl) GetCustomerNotes(ctx context.Context, customerID int, pinnedFirst bool, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return nil, err
+	}
+	notes, total, err := s.noteRepo.FindByCustomerID(ctx, customerID, pinnedFirst, pagination)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get customer notes", zap.Error(err), zap.Int("customer_id", customerID))
+		return nil, utils.ErrInternalServer
+	}
+	return &models.PaginatedResponse{Data: notes, Total: &total, Page: pagination.Page, PageSize: pagination.PageSize}, nil
+}
+
+// CreateCustomerNote 為客戶新增備註，作者由呼叫者 (JWT claims) 決定
+func (s *customerServiceImpl) CreateCustomerNote(ctx context.Context, note *models.CustomerNote) error {
+	if err := s.ensureCustomerExists(ctx, note.CustomerID); err != nil {
+		return err
+	}
+	if err := s.noteRepo.Create(ctx, note); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create customer note in repository", zap.Error(err), zap.Int("customer_id", note.CustomerID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create customer note: %v", err))
+	}
+	return nil
+}
+
+// DeleteCustomerNote 刪除客戶備註，只有作者本人或管理員可以刪除
+func (s *customerServiceImpl) DeleteCustomerNote(ctx context.Context, customerID, noteID int, requesterAccountID int, requesterRoleID int) error {
+	note, err := s.noteRepo.FindByID(ctx, customerID, noteID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing customer note for delete", zap.Error(err), zap.Int("note_id", noteID))
+		return utils.ErrInternalServer
+	}
+	if note == nil {
+		return utils.ErrNotFound
+	}
+
+	isAuthor := note.AuthorID != nil && *note.AuthorID == requesterAccountID
+	if !isAuthor && requesterRoleID != adminRoleID {
+		return utils.ErrForbidden.SetDetails("Only the author or an admin can delete this note")
+	}
+
+	if err := s.noteRepo.Delete(ctx, customerID, noteID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete customer note in repository", zap.Error(err), zap.Int("note_id", noteID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete customer note: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// newTestCustomerService 以假 Repository 建立 CustomerService，未用到的 Repository 為 nil
+func newTestCustomerService(customers *fakeCustomerRepository, addresses *fakeCustomerAddressRepository, notes *fakeCustomerNoteRepository, history *fakeCustomerHistoryRepository) CustomerService {
+	return NewCustomerService(customers, nil, addresses, notes, history, nil, "TW", "C", zap.NewNop())
+}
+
+func TestCustomerServiceCreateCustomerAddress(t *testing.T) {
+	customers := map[int]*models.Customer{1: {ID: 1, Name: "Acme"}}
+	existing := map[int]*models.CustomerAddress{10: {ID: 10, CustomerID: 1, Type: models.AddressTypeBilling, IsDefault: true}}
+
+	tests := []struct {
+		name        string
+		addresses   fakeCustomerAddressRepository
+		address     models.CustomerAddress
+		wantErr     *utils.CustomError // nil 表示成功
+		wantDefault bool
+	}{
+		{
+			name:      "first address of a type becomes default",
+			address:   models.CustomerAddress{CustomerID: 1, Type: models.AddressTypeShipping, Line1: "1 Main St", City: "Taipei", PostalCode: "100", Country: "tw"},
+			addresses: fakeCustomerAddressRepository{addresses: existing}, wantDefault: true,
+		},
+		{
+			name:      "second address of a type is not default",
+			address:   models.CustomerAddress{CustomerID: 1, Type: models.AddressTypeBilling, Line1: "2 Main St", City: "Taipei", PostalCode: "100", Country: "TW"},
+			addresses: fakeCustomerAddressRepository{addresses: existing},
+		},
+		{
+			name:    "missing customer",
+			address: models.CustomerAddress{CustomerID: 9, Type: models.AddressTypeBilling, Country: "TW"},
+			wantErr: utils.ErrNotFound,
+		},
+		{
+			name:    "US address without state",
+			address: models.CustomerAddress{CustomerID: 1, Type: models.AddressTypeBilling, PostalCode: "94105", Country: "us"},
+			wantErr: utils.ErrBadRequest,
+		},
+		{
+			name:    "invalid postal code for country",
+			address: models.CustomerAddress{CustomerID: 1, Type: models.AddressTypeBilling, PostalCode: "ABC", Country: "DE"},
+			wantErr: utils.ErrBadRequest,
+		},
+		{
+			name:      "count fails",
+			address:   models.CustomerAddress{CustomerID: 1, Type: models.AddressTypeBilling, Country: "GB"},
+			addresses: fakeCustomerAddressRepository{err: errDatabase}, wantErr: utils.ErrInternalServer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addresses := tt.addresses
+			s := newTestCustomerService(&fakeCustomerRepository{customers: customers}, &addresses, nil, nil)
+
+			address := tt.address
+			err := s.CreateCustomerAddress(context.Background(), &address)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateCustomerAddress error = %v, want %v", err, tt.wantErr)
+				}
+				if len(addresses.created) != 0 {
+					t.Errorf("failed create stored %v", addresses.created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateCustomerAddress: %v", err)
+			}
+			if len(addresses.created) != 1 || address.ID == 0 {
+				t.Fatalf("created = %v, address ID = %d", addresses.created, address.ID)
+			}
+			if address.IsDefault != tt.wantDefault {
+				t.Errorf("IsDefault = %v, want %v", address.IsDefault, tt.wantDefault)
+			}
+			if address.Country != "TW" {
+				t.Errorf("Country = %q, want upper case TW", address.Country)
+			}
+		})
+	}
+}
+
+func TestCustomerServiceDeleteCustomerAddress(t *testing.T) {
+	customers := map[int]*models.Customer{1: {ID: 1, Name: "Acme"}}
+	addresses := map[int]*models.CustomerAddress{
+		10: {ID: 10, CustomerID: 1, Type: models.AddressTypeBilling, IsDefault: true},
+		11: {ID: 11, CustomerID: 1, Type: models.AddressTypeBilling},
+		20: {ID: 20, CustomerID: 2, Type: models.AddressTypeShipping, IsDefault: true},
+	}
+
+	tests := []struct {
+		name         string
+		id           int
+		err          error
+		wantErr      *utils.CustomError // nil 表示成功
+		wantPromoted []string
+	}{
+		{name: "default address promotes the next one", id: 10, wantPromoted: []string{models.AddressTypeBilling}},
+		{name: "non-default address promotes nothing", id: 11},
+		{name: "address of another customer", id: 20, wantErr: utils.ErrNotFound},
+		{name: "missing address", id: 99, wantErr: utils.ErrNotFound},
+		{name: "lookup fails", id: 10, err: errDatabase, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := fakeCustomerAddressRepository{addresses: addresses, err: tt.err}
+			s := newTestCustomerService(&fakeCustomerRepository{customers: customers}, &repo, nil, nil)
+
+			err := s.DeleteCustomerAddress(context.Background(), 1, tt.id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DeleteCustomerAddress error = %v, want %v", err, tt.wantErr)
+				}
+				if len(repo.deleted) != 0 || len(repo.promoted) != 0 {
+					t.Errorf("failed delete deleted %v and promoted %v", repo.deleted, repo.promoted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeleteCustomerAddress: %v", err)
+			}
+			if !reflect.DeepEqual(repo.deleted, []int{tt.id}) {
+				t.Errorf("deleted = %v, want [%d]", repo.deleted, tt.id)
+			}
+			if !reflect.DeepEqual(repo.promoted, tt.wantPromoted) {
+				t.Errorf("promoted = %v, want %v", repo.promoted, tt.wantPromoted)
+			}
+		})
+	}
+}
+
+func TestCustomerServiceDeleteCustomerNote(t *testing.T) {
+	const (
+		authorID   = 5
+		otherID    = 6
+		userRoleID = 2
+	)
+	author := authorID
+	notes := map[int]*models.CustomerNote{
+		1: {ID: 1, CustomerID: 1, AuthorID: &author, Body: "Prefers email"},
+		2: {ID: 2, CustomerID: 1, Body: "Author account deleted"},
+	}
+
+	tests := []struct {
+		name            string
+		noteID          int
+		requesterID     int
+		requesterRoleID int
+		err             error
+		wantErr         *utils.CustomError // nil 表示成功
+	}{
+		{name: "author deletes own note", noteID: 1, requesterID: authorID, requesterRoleID: userRoleID},
+		{name: "admin deletes another author's note", noteID: 1, requesterID: otherID, requesterRoleID: adminRoleID},
+		{name: "admin deletes note without author", noteID: 2, requesterID: otherID, requesterRoleID: adminRoleID},
+		{name: "other user", noteID: 1, requesterID: otherID, requesterRoleID: userRoleID, wantErr: utils.ErrForbidden},
+		{name: "note without author for non-admin", noteID: 2, requesterID: authorID, requesterRoleID: userRoleID, wantErr: utils.ErrForbidden},
+		{name: "missing note", noteID: 9, requesterID: authorID, requesterRoleID: adminRoleID, wantErr: utils.ErrNotFound},
+		{name: "lookup fails", noteID: 1, requesterID: authorID, requesterRoleID: userRoleID, err: errDatabase, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := fakeCustomerNoteRepository{notes: notes, err: tt.err}
+			s := newTestCustomerService(nil, nil, &repo, nil)
+
+			err := s.DeleteCustomerNote(context.Background(), 1, tt.noteID, tt.requesterID, tt.requesterRoleID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DeleteCustomerNote error = %v, want %v", err, tt.wantErr)
+				}
+				if len(repo.deleted) != 0 {
+					t.Errorf("failed delete deleted %v", repo.deleted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeleteCustomerNote: %v", err)
+			}
+			if !reflect.DeepEqual(repo.deleted, []int{tt.noteID}) {
+				t.Errorf("deleted = %v, want [%d]", repo.deleted, tt.noteID)
+			}
+		})
+	}
+}
+
+func TestCustomerServiceGetCustomerHistory(t *testing.T) {
+	customers := map[int]*models.Customer{1: {ID: 1, Name: "Acme"}}
+	history := []models.CustomerHistory{
+		{ID: 3, CustomerID: 1, Event: models.CustomerEventUpdated, Field: "email"},
+		{ID: 2, CustomerID: 1, Event: models.CustomerEventUpdated, Field: "name"},
+		{ID: 1, CustomerID: 1, Event: models.CustomerEventCreated},
+		{ID: 4, CustomerID: 2, Event: models.CustomerEventCreated},
+	}
+
+	tests := []struct {
+		name       string
+		customerID int
+		field      string
+		err        error
+		wantErr    *utils.CustomError // nil 表示成功
+		wantTotal  int
+	}{
+		{name: "all events", customerID: 1, wantTotal: 3},
+		{name: "single field", customerID: 1, field: "email", wantTotal: 1},
+		{name: "unknown field", customerID: 1, field: "password", wantErr: utils.ErrBadRequest},
+		{name: "missing customer", customerID: 9, wantErr: utils.ErrNotFound},
+		{name: "history lookup fails", customerID: 1, err: errDatabase, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestCustomerService(&fakeCustomerRepository{customers: customers}, nil, nil, &fakeCustomerHistoryRepository{history: history, err: tt.err})
+
+			resp, err := s.GetCustomerHistory(context.Background(), tt.customerID, tt.field, utils.Pagination{Page: 1, PageSize: 20})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetCustomerHistory error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCustomerHistory: %v", err)
+			}
+			if resp.Total == nil || *resp.Total != tt.wantTotal {
+				t.Errorf("Total = %v, want %d", resp.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestCustomerServiceDeleteCustomer(t *testing.T) {
+	const actorID = 7
+	customers := map[int]*models.Customer{1: {ID: 1, Name: "Acme"}}
+
+	tests := []struct {
+		name    string
+		repo    fakeCustomerRepository
+		id      int
+		wantErr *utils.CustomError // nil 表示成功
+	}{
+		{name: "records deleted event", repo: fakeCustomerRepository{customers: customers}, id: 1},
+		{name: "missing customer", repo: fakeCustomerRepository{customers: customers}, id: 9, wantErr: utils.ErrNotFound},
+		{name: "lookup fails", repo: fakeCustomerRepository{err: errDatabase}, id: 1, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			s := newTestCustomerService(&repo, nil, nil, nil)
+
+			err := s.DeleteCustomer(context.Background(), tt.id, actorID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DeleteCustomer error = %v, want %v", err, tt.wantErr)
+				}
+				if len(repo.deleted) != 0 {
+					t.Errorf("failed delete deleted %v", repo.deleted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeleteCustomer: %v", err)
+			}
+			actor := actorID
+			want := []models.CustomerHistory{{CustomerID: tt.id, Event: models.CustomerEventDeleted, ActorID: &actor}}
+			if !reflect.DeepEqual(repo.deleted, []int{tt.id}) || !reflect.DeepEqual(repo.history, want) {
+				t.Errorf("deleted = %v with history %+v, want [%d] with %+v", repo.deleted, repo.history, tt.id, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,502 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/events"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// errDatabase 假 Repository 模擬的資料庫錯誤
+var errDatabase = errors.New("connection refused")
+
+// 以下假 Repository 嵌入介面，只實作受測方法呼叫的方法：函式欄位為 nil 時返回未找到 (nil, nil)；
+// 呼叫其他方法會 panic，讓測試發現 Service 使用了未預期的 Repository 方法
+type (
+	fakeAccountRepository struct {
+		repository.AccountRepository
+		findByID       func(id int) (*models.Account, error)
+		updatePassword func(accountID int, hashedPassword string) error
+		updated        map[int]string // 已更新的密碼雜湊
+		saved          []models.Account
+		restoreErr     error
+		restored       []int
+		usernames      map[string]*models.Account // FindByUsername 的結果
+		createErr      error
+		created        []models.Account
+	}
+	fakeRoleRepository struct {
+		repository.RoleRepository
+		roles     map[int]*models.Role
+		err       error
+		deleteErr error
+		deleted   []int
+	}
+	fakeCompanyRepository struct {
+		repository.CompanyRepository
+		companies  map[int]*models.Company
+		findErr    error
+		createErr  error
+		created    []*models.Company
+		restoreErr error
+		restored   []int
+	}
+	fakeMenuRepository struct {
+		repository.MenuRepository
+		menus map[int]*models.Menu
+		err   error
+	}
+	fakeRoleMenuRepository struct {
+		repository.RoleMenuRepository
+		existing  []models.RoleMenuDetail
+		findErr   error
+		updateErr error
+		updates   int
+	}
+	fakePermissionRepository struct {
+		repository.PermissionRepository
+		permissions map[int][]models.Permission
+		err         error
+		loads       int
+		catalog     []models.Permission // 權限目錄 (FindAll 與 CreateBatch)
+	}
+	fakeProductDefinitionRepository struct {
+		repository.ProductDefinitionRepository
+		categories  map[int]*models.ProductCategory
+		counts      map[int]int // 類別下的產品定義數量 (CountByCategoryID)
+		err         error
+		deleted     []int // 已刪除的類別
+		definitions map[int]*models.ProductDefinition
+	}
+	fakeProductDefinitionHistoryRepository struct {
+		repository.ProductDefinitionHistoryRepository
+		history []models.ProductDefinitionHistory
+		err     error
+	}
+	fakeProductPriceRepository struct {
+		repository.ProductPriceRepository
+		tiers    []models.ProductPriceTier // 依 min_qty 由小到大
+		err      error
+		replaced []models.ProductPriceTier // 最後一次 ReplaceTiers 寫入的分級
+	}
+	fakeProductUnitRepository struct {
+		repository.ProductUnitRepository
+		err      error
+		replaced []models.ProductUnit // 最後一次 Replace 寫入的換算單位
+	}
+	fakeCustomerRepository struct {
+		repository.CustomerRepository
+		customers map[int]*models.Customer
+		err       error
+		deleted   []int
+		history   []models.CustomerHistory // Delete 與 Restore 寫入的歷史
+	}
+	fakeCustomerAddressRepository struct {
+		repository.CustomerAddressRepository
+		addresses map[int]*models.CustomerAddress
+		err       error
+		created   []models.CustomerAddress
+		deleted   []int
+		promoted  []string // PromoteDefault 的地址類型
+	}
+	fakeCustomerNoteRepository struct {
+		repository.CustomerNoteRepository
+		notes   map[int]*models.CustomerNote
+		err     error
+		deleted []int
+	}
+	fakeCustomerHistoryRepository struct {
+		repository.CustomerHistoryRepository
+		history []models.CustomerHistory
+		err     error
+	}
+	fakeAccountHistoryRepository struct {
+		repository.AccountHistoryRepository
+		err     error
+		created []models.AccountHistory
+	}
+	fakeAuditLogRepository struct {
+		repository.AuditLogRepository
+		expired   int64 // 尚未刪除的過期記錄數
+		failOn    int   // 第幾次 DeleteCreatedBefore 返回 err (從 1 開始，0 表示不失敗)
+		err       error
+		deletes   int
+		written   []models.AuditLog
+		createErr error
+	}
+	fakeHealthRepository struct {
+		repository.HealthRepository
+		pingErr    error
+		version    int64
+		dirty      bool
+		versionErr error
+	}
+)
+
+func (r *fakeAccountRepository) FindByID(ctx context.Context, id int, opts ...repository.FindOptions) (*models.Account, error) {
+	if r.findByID == nil {
+		return nil, nil
+	}
+	return r.findByID(id)
+}
+
+func (r *fakeAccountRepository) FindByUsername(ctx context.Context, username string) (*models.Account, error) {
+	return r.usernames[username], nil
+}
+
+func (r *fakeAccountRepository) Create(ctx context.Context, account *models.Account) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	account.ID = 100 + len(r.created)
+	r.created = append(r.created, *account)
+	return nil
+}
+
+func (r *fakeAccountRepository) UpdatePassword(ctx context.Context, accountID int, hashedPassword string) error {
+	if r.updatePassword != nil {
+		if err := r.updatePassword(accountID, hashedPassword); err != nil {
+			return err
+		}
+	}
+	if r.updated == nil {
+		r.updated = map[int]string{}
+	}
+	r.updated[accountID] = hashedPassword
+	return nil
+}
+
+func (r *fakeAccountRepository) Update(ctx context.Context, account *models.Account) error {
+	r.saved = append(r.saved, *account)
+	return nil
+}
+
+func (r *fakeAccountRepository) Restore(ctx context.Context, id int) error {
+	if r.restoreErr != nil {
+		return r.restoreErr
+	}
+	r.restored = append(r.restored, id)
+	return nil
+}
+
+func (r *fakeRoleRepository) FindByID(ctx context.Context, id int) (*models.Role, error) {
+	return r.roles[id], r.err
+}
+
+func (r *fakeRoleRepository) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for _, role := range r.roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRoleRepository) Delete(ctx context.Context, id int) error {
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func (r *fakeCompanyRepository) FindByID(ctx context.Context, id int, opts ...repository.FindOptions) (*models.Company, error) {
+	return r.companies[id], r.findErr
+}
+
+func (r *fakeCompanyRepository) Create(ctx context.Context, company *models.Company, actorID int) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	company.ID = 100 + len(r.created)
+	r.created = append(r.created, company)
+	return nil
+}
+
+func (r *fakeCompanyRepository) Restore(ctx context.Context, id int, actorID int) error {
+	if r.restoreErr != nil {
+		return r.restoreErr
+	}
+	r.restored = append(r.restored, id)
+	return nil
+}
+
+func (r *fakeMenuRepository) FindByID(ctx context.Context, id int) (*models.Menu, error) {
+	return r.menus[id], r.err
+}
+
+func (r *fakeRoleMenuRepository) FindAll(ctx context.Context, roleID, menuID *int) ([]models.RoleMenuDetail, error) {
+	if r.findErr != nil {
+		return nil, r.findErr
+	}
+	var found []models.RoleMenuDetail
+	for _, relation := range r.existing {
+		if (roleID == nil || relation.RoleID == *roleID) && (menuID == nil || relation.MenuID == *menuID) {
+			found = append(found, relation)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeRoleMenuRepository) Update(ctx context.Context, oldRoleID, oldMenuID, newRoleID, newMenuID int) error {
+	if r.updateErr != nil {
+		return r.updateErr
+	}
+	r.updates++
+	return nil
+}
+
+func (r *fakePermissionRepository) FindPermissionsByRoleID(ctx context.Context, roleID int) ([]models.Permission, error) {
+	r.loads++
+	return r.permissions[roleID], r.err
+}
+
+func (r *fakePermissionRepository) FindAll(ctx context.Context) ([]models.Permission, error) {
+	r.loads++
+	return r.catalog, r.err
+}
+
+func (r *fakePermissionRepository) CreateBatch(ctx context.Context, permissions []models.Permission) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	created := 0
+	for _, permission := range permissions {
+		exists := false
+		for _, registered := range r.catalog {
+			exists = exists || registered.Name == permission.Name
+		}
+		if !exists {
+			r.catalog = append(r.catalog, permission)
+			created++
+		}
+	}
+	return created, nil
+}
+
+func (r *fakeProductDefinitionRepository) FindCategoryByID(ctx context.Context, id int) (*models.ProductCategory, error) {
+	return r.categories[id], r.err
+}
+
+func (r *fakeProductDefinitionRepository) CountByCategoryID(ctx context.Context, categoryID int, includeDescendants bool) (int, error) {
+	return r.counts[categoryID], r.err
+}
+
+func (r *fakeProductDefinitionRepository) DeleteCategory(ctx context.Context, id int, mode models.CategoryDeleteMode, reassignTo *int) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func (r *fakeProductDefinitionRepository) FindByID(ctx context.Context, id int) (*models.ProductDefinition, error) {
+	return r.definitions[id], r.err
+}
+
+func (r *fakeProductDefinitionHistoryRepository) FindByProductID(ctx context.Context, productID int, field string, pagination utils.Pagination) ([]models.ProductDefinitionHistory, int, error) {
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	var found []models.ProductDefinitionHistory
+	for _, entry := range r.history {
+		if entry.ProductID == productID && (field == "" || entry.Field == field) {
+			found = append(found, entry)
+		}
+	}
+	return found, len(found), nil
+}
+
+func (r *fakeProductPriceRepository) FindTierForQuantity(ctx context.Context, productID, qty int) (*models.ProductPriceTier, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var found *models.ProductPriceTier
+	for i := range r.tiers {
+		if r.tiers[i].ProductID == productID && r.tiers[i].MinQty <= qty {
+			found = &r.tiers[i]
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeProductPriceRepository) ReplaceTiers(ctx context.Context, productID int, tiers []models.ProductPriceTier) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.replaced = tiers
+	return nil
+}
+
+func (r *fakeProductUnitRepository) Replace(ctx context.Context, productID int, units []models.ProductUnit) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.replaced = units
+	return nil
+}
+
+func (r *fakeCustomerRepository) FindByID(ctx context.Context, id int, opts ...repository.FindOptions) (*models.Customer, error) {
+	return r.customers[id], r.err
+}
+
+func (r *fakeCustomerRepository) Delete(ctx context.Context, id, actorID int, history []models.CustomerHistory) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.deleted = append(r.deleted, id)
+	r.history = append(r.history, history...)
+	return nil
+}
+
+func (r *fakeCustomerAddressRepository) Create(ctx context.Context, address *models.CustomerAddress) error {
+	if r.err != nil {
+		return r.err
+	}
+	address.ID = 100 + len(r.created)
+	r.created = append(r.created, *address)
+	return nil
+}
+
+func (r *fakeCustomerAddressRepository) FindByID(ctx context.Context, customerID, id int) (*models.CustomerAddress, error) {
+	if address := r.addresses[id]; address != nil && address.CustomerID == customerID {
+		return address, r.err
+	}
+	return nil, r.err
+}
+
+func (r *fakeCustomerAddressRepository) Delete(ctx context.Context, customerID, id int) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func (r *fakeCustomerAddressRepository) CountByType(ctx context.Context, customerID int, addressType string) (int, error) {
+	count := 0
+	for _, address := range r.addresses {
+		if address.CustomerID == customerID && address.Type == addressType {
+			count++
+		}
+	}
+	return count, r.err
+}
+
+func (r *fakeCustomerAddressRepository) PromoteDefault(ctx context.Context, customerID int, addressType string) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.promoted = append(r.promoted, addressType)
+	return nil
+}
+
+func (r *fakeCustomerNoteRepository) FindByID(ctx context.Context, customerID, id int) (*models.CustomerNote, error) {
+	if note := r.notes[id]; note != nil && note.CustomerID == customerID {
+		return note, r.err
+	}
+	return nil, r.err
+}
+
+func (r *fakeCustomerNoteRepository) Delete(ctx context.Context, customerID, id int) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func (r *fakeCustomerHistoryRepository) FindByCustomerID(ctx context.Context, customerID int, field string, pagination utils.Pagination) ([]models.CustomerHistory, int, error) {
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	var found []models.CustomerHistory
+	for _, entry := range r.history {
+		if entry.CustomerID == customerID && (field == "" || entry.Field == field) {
+			found = append(found, entry)
+		}
+	}
+	return found, len(found), nil
+}
+
+func (r *fakeAccountHistoryRepository) Create(ctx context.Context, entry *models.AccountHistory) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.created = append(r.created, *entry)
+	return nil
+}
+
+func (r *fakeAuditLogRepository) DeleteCreatedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	r.deletes++
+	if r.deletes == r.failOn {
+		return 0, r.err
+	}
+	deleted := r.expired
+	if deleted > int64(limit) {
+		deleted = int64(limit)
+	}
+	r.expired -= deleted
+	return deleted, nil
+}
+
+func (r *fakeAuditLogRepository) CreateBatch(ctx context.Context, entries []models.AuditLog) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	r.written = append(r.written, entries...)
+	return nil
+}
+
+func (r *fakeHealthRepository) Ping(ctx context.Context) error {
+	return r.pingErr
+}
+
+func (r *fakeHealthRepository) MigrationVersion(ctx context.Context) (int64, bool, error) {
+	return r.version, r.dirty, r.versionErr
+}
+
+// fakeTxManager 不開啟事務，直接以原本的 ctx 執行 fn
+type fakeTxManager struct{}
+
+func (fakeTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...db.TxOption) error {
+	return fn(ctx)
+}
+
+// fakePermissionService 只回報權限緩存是否已預載入，供健康檢查使用
+type fakePermissionService struct {
+	PermissionService
+	warm bool
+}
+
+func (s *fakePermissionService) CacheWarm() bool {
+	return s.warm
+}
+
+// recordingPublisher 記錄發布的事件
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event events.Event) {
+	p.events = append(p.events, event)
+}
+
+// recordingCache 不緩存任何內容，只記錄被設為失效的類別
+type recordingCache struct {
+	cache.Cache
+	invalidated []string
+}
+
+func (c *recordingCache) Invalidate(ctx context.Context, families ...string) {
+	c.invalidated = append(c.invalidated, families...)
+}
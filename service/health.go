@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// healthCheckDatabaseTimeout /healthz 中資料庫 ping 的逾時時間，需短於探針的逾時設定
+const healthCheckDatabaseTimeout = 2 * time.Second
+
+// HealthService 定義健康檢查服務介面
+type HealthService interface {
+	// Check 檢查各相依元件 (/healthz)，任一元件失敗時返回的 Status 為 unavailable
+	Check(ctx context.Context) *models.HealthCheckResponse
+	// Live 存活檢查 (/livez)，不檢查任何相依元件，只要程序能處理請求即為 ok
+	Live(ctx context.Context) *models.HealthCheckResponse
+	// Ready 就緒檢查 (/readyz)：啟動程序、資料庫、權限緩存與遷移版本，ctx 的期限即為整體逾時
+	Ready(ctx context.Context) *models.HealthCheckResponse
+	// MarkStarted 標記啟動程序 (資料庫連線、緩存預載入等) 已完成，在此之前 Ready 一律為 unavailable
+	MarkStarted()
+	// MarkDatabaseConnected 標記啟動時第一次成功連線資料庫，在此之前 Check 與 Ready 的 database 為 connecting，不 ping 資料庫
+	MarkDatabaseConnected()
+}
+
+// healthServiceImpl 實現 HealthService 介面
+type healthServiceImpl struct {
+	healthRepo        repository.HealthRepository
+	permissionService PermissionService
+	version           string
+	startedAt         time.Time
+	latestMigration   int64 // 程式隨附的最新遷移版本，0 表示無法判斷 (略過遷移檢查)
+	started           atomic.Bool
+	databaseConnected atomic.Bool
+	logger            *zap.Logger
+}
+
+// NewHealthService 創建 HealthService 實例
+// version 為建置版本，startedAt 為程序啟動時間，用於計算 uptime；latestMigration 為 db.LatestMigrationVersion 的結果
+func NewHealthService(healthRepo repository.HealthRepository, permissionService PermissionService, version string, startedAt time.Time, latestMigration int64, logger *zap.Logger) HealthService {
+	return &healthServiceImpl{
+		healthRepo:        healthRepo,
+		permissionService: permissionService,
+		version:           version,
+		startedAt:         startedAt,
+		latestMigration:   latestMigration,
+		logger:            logger,
+	}
+}
+
+// newResponse 建立狀態為 ok 的回應，填入版本與運行時間
+func (s *healthServiceImpl) newResponse() *models.HealthCheckResponse {
+	return &models.HealthCheckResponse{
+		Status:        models.HealthStatusOK,
+		Version:       s.version,
+		StartedAt:     s.startedAt,
+		UptimeSeconds: int64(time.Since(s.startedAt) / time.Second),
+		Checks:        map[string]string{},
+	}
+}
+
+// setCheck 記錄元件的檢查結果；err 不為 nil 時將整體狀態設為 unavailable，並記錄第一個失敗的元件
+func setCheck(ctx context.Context, result *models.HealthCheckResponse, component string, err error) {
+	if err == nil {
+		result.Checks[component] = models.HealthStatusOK
+		return
+	}
+	utils.LoggerFromContext(ctx).Warn("Service: Health check failed", zap.String("component", component), zap.Error(err))
+	result.Checks[component] = models.HealthStatusUnavailable
+	result.Status = models.HealthStatusUnavailable
+	if result.FailedComponent == "" {
+		result.FailedComponent = component
+	}
+}
+
+// setConnecting 記錄資料庫仍在啟動時的重試中，整體狀態為 unavailable；不記錄警告，避免探針在等待期間產生大量日誌
+func setConnecting(result *models.HealthCheckResponse) {
+	result.Checks["database"] = models.HealthStatusConnecting
+	result.Status = models.HealthStatusUnavailable
+	if result.FailedComponent == "" {
+		result.FailedComponent = "database"
+	}
+}
+
+// Check 以短逾時 ping 資料庫並返回版本、運行時間與各元件狀態
+func (s *healthServiceImpl) Check(ctx context.Context) *models.HealthCheckResponse {
+	result := s.newResponse()
+	if !s.databaseConnected.Load() {
+		setConnecting(result)
+		return result
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, healthCheckDatabaseTimeout)
+	defer cancel()
+	setCheck(ctx, result, "database", s.healthRepo.Ping(pingCtx))
+	return result
+}
+
+// Live 只返回版本與運行時間；請求本身已逾時 (程序過載) 時視為失敗
+func (s *healthServiceImpl) Live(ctx context.Context) *models.HealthCheckResponse {
+	result := s.newResponse()
+	if err := ctx.Err(); err != nil {
+		setCheck(ctx, result, "process", err)
+	}
+	return result
+}
+
+// Ready 依序檢查啟動程序、資料庫、權限緩存與遷移版本，回應中包含每個元件的結果
+func (s *healthServiceImpl) Ready(ctx context.Context) *models.HealthCheckResponse {
+	result := s.newResponse()
+
+	var startupErr error
+	if !s.started.Load() {
+		startupErr = fmt.Errorf("startup has not completed")
+	}
+	setCheck(ctx, result, "startup", startupErr)
+
+	var databaseErr error
+	if s.databaseConnected.Load() {
+		databaseErr = s.healthRepo.Ping(ctx)
+		setCheck(ctx, result, "database", databaseErr)
+	} else {
+		databaseErr = fmt.Errorf("database is connecting")
+		setConnecting(result)
+	}
+
+	var cacheErr error
+	if !s.permissionService.CacheWarm() {
+		cacheErr = fmt.Errorf("permission cache is not warmed up")
+	}
+	setCheck(ctx, result, "permission_cache", cacheErr)
+
+	switch {
+	case s.latestMigration == 0:
+		result.Checks["migrations"] = models.HealthStatusSkipped
+	case databaseErr != nil:
+		setCheck(ctx, result, "migrations", fmt.Errorf("database is unavailable"))
+	default:
+		setCheck(ctx, result, "migrations", s.checkMigrations(ctx))
+	}
+	return result
+}
+
+// checkMigrations 確認資料庫的遷移版本不低於程式隨附的最新版本，且上一次遷移沒有失敗
+func (s *healthServiceImpl) checkMigrations(ctx context.Context) error {
+	version, dirty, err := s.healthRepo.MigrationVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < s.latestMigration {
+		return fmt.Errorf("database is at migration %d, latest is %d", version, s.latestMigration)
+	}
+	return nil
+}
+
+// MarkDatabaseConnected 標記啟動時已成功連線資料庫
+func (s *healthServiceImpl) MarkDatabaseConnected() {
+	s.databaseConnected.Store(true)
+}
+
+// MarkStarted 標記啟動程序已完成
+func (s *healthServiceImpl) MarkStarted() {
+	s.started.Store(true)
+	s.logger.Info("Service: Startup completed, readiness probe enabled")
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/models"
+)
+
+func TestHealthServiceReady(t *testing.T) {
+	const latestMigration = 42
+	ok, unavailable := models.HealthStatusOK, models.HealthStatusUnavailable
+
+	tests := []struct {
+		name            string
+		repo            fakeHealthRepository
+		latestMigration int64
+		notStarted      bool
+		connecting      bool // 啟動時尚未連線資料庫
+		coldCache       bool
+		wantChecks      map[string]string
+		wantFailed      string // 第一個失敗的元件，空字串表示整體為 ok
+	}{
+		{
+			name: "ready", repo: fakeHealthRepository{version: latestMigration}, latestMigration: latestMigration,
+			wantChecks: map[string]string{"startup": ok, "database": ok, "permission_cache": ok, "migrations": ok},
+		},
+		{
+			name: "database ahead of binary", repo: fakeHealthRepository{version: latestMigration + 1}, latestMigration: latestMigration,
+			wantChecks: map[string]string{"startup": ok, "database": ok, "permission_cache": ok, "migrations": ok},
+		},
+		{
+			name: "unknown latest migration skips the check", repo: fakeHealthRepository{versionErr: errDatabase},
+			wantChecks: map[string]string{"startup": ok, "database": ok, "permission_cache": ok, "migrations": models.HealthStatusSkipped},
+		},
+		{
+			name: "pending migrations", repo: fakeHealthRepository{version: latestMigration - 1}, latestMigration: latestMigration,
+			wantChecks: map[string]string{"startup": ok, "database": ok, "permission_cache": ok, "migrations": unavailable},
+			wantFailed: "migrations",
+		},
+		{
+			name: "dirty migration", repo: fakeHealthRepository{version: latestMigration, dirty: true}, latestMigration: latestMigration,
+			wantChecks: map[string]string{"startup": ok, "database": ok, "permission_cache": ok, "migrations": unavailable},
+			wantFailed: "migrations",
+		},
+		{
+			name: "migration version query fails", repo: fakeHealthRepository{versionErr: errDatabase}, latestMigration: latestMigration,
+			wantChecks: map[string]string{"startup": ok, "database": ok, "permission_cache": ok, "migrations": unavailable},
+			wantFailed: "migrations",
+		},
+		{
+			name: "database down fails migrations without querying", repo: fakeHealthRepository{pingErr: errDatabase, version: latestMigration}, latestMigration: latestMigration,
+			wantChecks: map[string]string{"startup": ok, "database": unavailable, "permission_cache": ok, "migrations": unavailable},
+			wantFailed: "database",
+		},
+		{
+			name: "still connecting", repo: fakeHealthRepository{version: latestMigration}, latestMigration: latestMigration, connecting: true,
+			wantChecks: map[string]string{"startup": ok, "database": models.HealthStatusConnecting, "permission_cache": ok, "migrations": unavailable},
+			wantFailed: "database",
+		},
+		{
+			name: "startup not completed and cold cache", repo: fakeHealthRepository{version: latestMigration}, latestMigration: latestMigration, notStarted: true, coldCache: true,
+			wantChecks: map[string]string{"startup": unavailable, "database": ok, "permission_cache": unavailable, "migrations": ok},
+			wantFailed: "startup",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			s := NewHealthService(&repo, &fakePermissionService{warm: !tt.coldCache}, "test", time.Now(), tt.latestMigration, zap.NewNop())
+			if !tt.notStarted {
+				s.MarkStarted()
+			}
+			if !tt.connecting {
+				s.MarkDatabaseConnected()
+			}
+
+			got := s.Ready(context.Background())
+			if !reflect.DeepEqual(got.Checks, tt.wantChecks) {
+				t.Errorf("Checks = %v, want %v", got.Checks, tt.wantChecks)
+			}
+			wantStatus := ok
+			if tt.wantFailed != "" {
+				wantStatus = unavailable
+			}
+			if got.Status != wantStatus || got.FailedComponent != tt.wantFailed {
+				t.Errorf("Status = %s (failed %q), want %s (failed %q)", got.Status, got.FailedComponent, wantStatus, tt.wantFailed)
+			}
+		})
+	}
+}
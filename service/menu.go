@@ -1,11 +1,12 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"net/http" // 用於檢查錯誤類型
 
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/cache"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/repository"
 	"github.com/wac0705/fastener-api/utils"
@@ -13,42 +14,45 @@ import (
 
 // MenuService 定義選單服務介面
 type MenuService interface {
-	GetAllMenus() ([]models.Menu, error)
-	GetMenuByID(id int) (*models.Menu, error)
-	CreateMenu(menu *models.Menu) error
-	UpdateMenu(menu *models.Menu) error
-	DeleteMenu(id int) error
-	GetMenusByRoleID(roleID int) ([]models.Menu, error) // 新增：根據角色 ID 獲取選單
+	GetAllMenus(ctx context.Context) ([]models.Menu, error)
+	GetMenuByID(ctx context.Context, id int) (*models.Menu, error)
+	ResolvePublicID(ctx context.Context, publicID string) (int, error)    // 將對外的 UUID 識別碼 (public_id) 轉換為 ID，不存在時返回 utils.ErrNotFound
+	CreateMenu(ctx context.Context, menu *models.Menu, actorID int) error // actorID 為執行變更的帳戶，記錄在 created_by/updated_by
+	UpdateMenu(ctx context.Context, menu *models.Menu, actorID int) error
+	DeleteMenu(ctx context.Context, id int) error
+	GetMenusByRoleID(ctx context.Context, roleID int) ([]models.Menu, error) // 新增：根據角色 ID 獲取選單
 }
 
 // menuServiceImpl 實現 MenuService 介面
 type menuServiceImpl struct {
-	menuRepo repository.MenuRepository
+	menuRepo     repository.MenuRepository
 	roleMenuRepo repository.RoleMenuRepository // 導入 RoleMenuRepository
+	readCache    cache.Cache                   // 緩存讀取結果 (cache.FamilyMenus)，寫入成功後失效
+	logger       *zap.Logger
 }
 
 // NewMenuService 創建 MenuService 實例
-func NewMenuService(menuRepo repository.MenuRepository, roleMenuRepo repository.RoleMenuRepository) MenuService {
-	return &menuServiceImpl{menuRepo: menuRepo, roleMenuRepo: roleMenuRepo}
+func NewMenuService(menuRepo repository.MenuRepository, roleMenuRepo repository.RoleMenuRepository, readCache cache.Cache, logger *zap.Logger) MenuService {
+	return &menuServiceImpl{menuRepo: menuRepo, roleMenuRepo: roleMenuRepo, readCache: readCache, logger: logger}
 }
 
 // CreateMenu 創建新選單
-func (s *menuServiceImpl) CreateMenu(menu *models.Menu) error {
+func (s *menuServiceImpl) CreateMenu(ctx context.Context, menu *models.Menu, actorID int) error {
 	// 檢查 Path 是否重複
-	existingMenu, err := s.menuRepo.FindByPath(menu.Path) // 假設 Repository 有 FindByPath
+	existingMenu, err := s.menuRepo.FindByPath(ctx, menu.Path)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing menu by path during creation", zap.Error(err), zap.String("path", menu.Path))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing menu by path during creation", zap.Error(err), zap.String("path", menu.Path))
 		return utils.ErrInternalServer
 	}
 	if existingMenu != nil {
-		return utils.ErrBadRequest.SetDetails("Menu with this path already exists.")
+		return utils.ErrConflict.SetDetails("Menu with this path already exists.")
 	}
 
 	// 如果有 ParentID，檢查父選單是否存在
 	if menu.ParentID != nil {
-		parentMenu, err := s.menuRepo.FindByID(*menu.ParentID)
+		parentMenu, err := s.menuRepo.FindByID(ctx, *menu.ParentID)
 		if err != nil {
-			zap.L().Error("Service: Error checking parent menu ID for new menu", zap.Error(err), zap.Int("parent_id", *menu.ParentID))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking parent menu ID for new menu", zap.Error(err), zap.Int("parent_id", *menu.ParentID))
 			return utils.ErrInternalServer
 		}
 		if parentMenu == nil {
@@ -56,45 +60,70 @@ func (s *menuServiceImpl) CreateMenu(menu *models.Menu) error {
 		}
 	}
 
-	if err := s.menuRepo.Create(menu); err != nil {
-		if customErr, ok := err.(*utils.CustomError); ok && customErr.Code == http.StatusBadRequest {
-			return customErr // 假設 Repository 返回的錯誤已包含詳細信息
+	if err := s.menuRepo.Create(ctx, menu, actorID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如唯一約束衝突 (409)
 		}
-		zap.L().Error("Service: Failed to create menu in repository", zap.Error(err), zap.String("name", menu.Name))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create menu in repository", zap.Error(err), zap.String("name", menu.Name))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create menu: %v", err))
 	}
+	s.readCache.Invalidate(ctx, cache.FamilyMenus)
 	return nil
 }
 
 // GetAllMenus 獲取所有選單
-func (s *menuServiceImpl) GetAllMenus() ([]models.Menu, error) {
-	menus, err := s.menuRepo.FindAll()
+func (s *menuServiceImpl) GetAllMenus(ctx context.Context) ([]models.Menu, error) {
+	var menus []models.Menu
+	if s.readCache.Get(ctx, cache.FamilyMenus, "all", &menus) {
+		return menus, nil
+	}
+	menus, err := s.menuRepo.FindAll(ctx)
 	if err != nil {
-		zap.L().Error("Service: Failed to get all menus", zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all menus", zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
+	s.readCache.Set(ctx, cache.FamilyMenus, "all", menus)
 	return menus, nil
 }
 
 // GetMenuByID 根據 ID 獲取選單
-func (s *menuServiceImpl) GetMenuByID(id int) (*models.Menu, error) {
-	menu, err := s.menuRepo.FindByID(id)
+func (s *menuServiceImpl) GetMenuByID(ctx context.Context, id int) (*models.Menu, error) {
+	key := fmt.Sprintf("id:%d", id)
+	var cached models.Menu
+	if s.readCache.Get(ctx, cache.FamilyMenus, key, &cached) {
+		return &cached, nil
+	}
+	menu, err := s.menuRepo.FindByID(ctx, id)
 	if err != nil {
-		zap.L().Error("Service: Failed to get menu by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get menu by ID", zap.Int("id", id), zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
 	if menu == nil {
-		return nil, nil // Repository 返回 nil, nil 表示未找到
+		return nil, nil // Repository 返回 nil, nil 表示未找到 (不緩存)
 	}
+	s.readCache.Set(ctx, cache.FamilyMenus, key, menu)
 	return menu, nil
 }
 
+// ResolvePublicID 根據對外的 UUID 識別碼返回選單 ID
+func (s *menuServiceImpl) ResolvePublicID(ctx context.Context, publicID string) (int, error) {
+	menu, err := s.menuRepo.FindByPublicID(ctx, publicID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get menu by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return 0, utils.ErrInternalServer
+	}
+	if menu == nil {
+		return 0, utils.ErrNotFound
+	}
+	return menu.ID, nil
+}
+
 // UpdateMenu 更新選單信息
-func (s *menuServiceImpl) UpdateMenu(menu *models.Menu) error {
+func (s *menuServiceImpl) UpdateMenu(ctx context.Context, menu *models.Menu, actorID int) error {
 	// 檢查選單是否存在
-	existingMenu, err := s.menuRepo.FindByID(menu.ID)
+	existingMenu, err := s.menuRepo.FindByID(ctx, menu.ID)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing menu for update", zap.Error(err), zap.Int("menu_id", menu.ID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing menu for update", zap.Error(err), zap.Int("menu_id", menu.ID))
 		return utils.ErrInternalServer
 	}
 	if existingMenu == nil {
@@ -103,21 +132,21 @@ func (s *menuServiceImpl) UpdateMenu(menu *models.Menu) error {
 
 	// 如果 Path 有更改，檢查是否重複
 	if existingMenu.Path != menu.Path {
-		otherMenu, err := s.menuRepo.FindByPath(menu.Path) // 假設 Repository 有 FindByPath
+		otherMenu, err := s.menuRepo.FindByPath(ctx, menu.Path)
 		if err != nil {
-			zap.L().Error("Service: Error checking menu path for update conflict", zap.Error(err), zap.String("new_path", menu.Path))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking menu path for update conflict", zap.Error(err), zap.String("new_path", menu.Path))
 			return utils.ErrInternalServer
 		}
 		if otherMenu != nil && otherMenu.ID != menu.ID {
-			return utils.ErrBadRequest.SetDetails("Menu path already exists for another menu")
+			return utils.ErrConflict.SetDetails("Menu path already exists for another menu")
 		}
 	}
 
 	// 如果有 ParentID，檢查父選單是否存在
 	if menu.ParentID != nil {
-		parentMenu, err := s.menuRepo.FindByID(*menu.ParentID)
+		parentMenu, err := s.menuRepo.FindByID(ctx, *menu.ParentID)
 		if err != nil {
-			zap.L().Error("Service: Error checking parent menu ID for menu update", zap.Error(err), zap.Int("parent_id", *menu.ParentID))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking parent menu ID for menu update", zap.Error(err), zap.Int("parent_id", *menu.ParentID))
 			return utils.ErrInternalServer
 		}
 		if parentMenu == nil {
@@ -125,22 +154,23 @@ func (s *menuServiceImpl) UpdateMenu(menu *models.Menu) error {
 		}
 	}
 
-	if err := s.menuRepo.Update(menu); err != nil {
-		if customErr, ok := err.(*utils.CustomError); ok && customErr.Code == http.StatusBadRequest {
+	if err := s.menuRepo.Update(ctx, menu, actorID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
 			return customErr
 		}
-		zap.L().Error("Service: Failed to update menu in repository", zap.Error(err), zap.Int("menu_id", menu.ID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update menu in repository", zap.Error(err), zap.Int("menu_id", menu.ID))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update menu: %v", err))
 	}
+	s.readCache.Invalidate(ctx, cache.FamilyMenus)
 	return nil
 }
 
 // DeleteMenu 刪除選單
-func (s *menuServiceImpl) DeleteMenu(id int) error {
+func (s *menuServiceImpl) DeleteMenu(ctx context.Context, id int) error {
 	// 檢查選單是否存在
-	existingMenu, err := s.menuRepo.FindByID(id)
+	existingMenu, err := s.menuRepo.FindByID(ctx, id)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing menu for delete", zap.Error(err), zap.Int("menu_id", id))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing menu for delete", zap.Error(err), zap.Int("menu_id", id))
 		return utils.ErrInternalServer
 	}
 	if existingMenu == nil {
@@ -151,24 +181,34 @@ func (s *menuServiceImpl) DeleteMenu(id int) error {
 	// 如果資料庫外鍵設置為 RESTRICT，則會自動阻止刪除
 	// 如果有多個子選單，也可以考慮先將子選單的 parent_id 設為 NULL
 
-	if err := s.menuRepo.Delete(id); err != nil {
-		zap.L().Error("Service: Failed to delete menu in repository", zap.Error(err), zap.Int("menu_id", id))
+	if err := s.menuRepo.Delete(ctx, id); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如未找到，或仍被其他記錄引用 (409)
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete menu in repository", zap.Error(err), zap.Int("menu_id", id))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete menu: %v", err))
 	}
+	s.readCache.Invalidate(ctx, cache.FamilyMenus)
 	return nil
 }
 
 // GetMenusByRoleID 根據角色 ID 獲取選單 (供前端使用)
-func (s *menuServiceImpl) GetMenusByRoleID(roleID int) ([]models.Menu, error) {
+func (s *menuServiceImpl) GetMenusByRoleID(ctx context.Context, roleID int) ([]models.Menu, error) {
 	// 檢查角色是否存在
 	// 這是為了防止查詢一個不存在的角色ID
 	// role, err := s.roleRepo.FindByID(roleID) // 如果有 roleRepo 依賴，可以在這裡檢查
 	// if err != nil || role == nil { return nil, utils.ErrBadRequest.SetDetails("Invalid Role ID") }
 
-	menus, err := s.roleMenuRepo.FindMenusByRoleID(roleID) // 呼叫 RoleMenuRepository
+	key := fmt.Sprintf("role:%d", roleID)
+	var menus []models.Menu
+	if s.readCache.Get(ctx, cache.FamilyMenus, key, &menus) {
+		return menus, nil
+	}
+	menus, err := s.roleMenuRepo.FindMenusByRoleID(ctx, roleID) // 呼叫 RoleMenuRepository
 	if err != nil {
-		zap.L().Error("Service: Failed to get menus by role ID from repository", zap.Error(err), zap.Int("role_id", roleID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get menus by role ID from repository", zap.Error(err), zap.Int("role_id", roleID))
 		return nil, utils.ErrInternalServer
 	}
+	s.readCache.Set(ctx, cache.FamilyMenus, key, menus) // 角色的選單改變時由 RoleMenuService 使其失效
 	return menus, nil
 }
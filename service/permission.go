@@ -1,21 +1,31 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"sync" // 用於緩存的併發安全
+	"sync/atomic"
 
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/metrics"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/tracing"
 	"github.com/wac0705/fastener-api/utils"
 )
 
 // PermissionService 定義權限服務介面
 type PermissionService interface {
-	HasPermission(roleID int, permission string) (bool, error)
+	HasPermission(ctx context.Context, roleID int, permission string) (bool, error)
+	WarmCache(ctx context.Context) error                                             // 啟動時預載入所有角色的權限，成功後 CacheWarm 返回 true
+	CacheWarm() bool                                                                 // 供 /readyz 判斷權限緩存是否已預載入
+	InvalidateRole(ctx context.Context, roleID int)                                  // 角色的權限改變 (收到 permissions_changed 事件) 後使該角色的緩存與權限目錄的讀取緩存失效
+	GetAllPermissions(ctx context.Context) ([]models.Permission, error)              // 權限目錄 (緩存於 cache.FamilyPermissions)
+	GetRolePermissions(ctx context.Context, roleID int) ([]models.Permission, error) // 角色擁有的權限 (緩存於 cache.FamilyPermissions)
+	RegisterPermissions(ctx context.Context, names []string) (int, error)            // 將權限字串 (authz.RegisteredPermissions) 登記到權限目錄，返回新增的筆數
 	// 可以新增其他權限管理方法，例如：
-	// GetRolePermissions(roleID int) ([]models.Permission, error)
 	// AssignPermissionToRole(roleID, permissionID int) error
 	// RevokePermissionFromRole(roleID, permissionID int) error
 }
@@ -24,32 +34,40 @@ type PermissionService interface {
 type permissionServiceImpl struct {
 	permissionRepo repository.PermissionRepository
 	roleRepo       repository.RoleRepository // 依賴 RoleRepository 以獲取角色信息
+	readCache      cache.Cache               // 權限目錄與角色權限列表的讀取緩存 (cache.FamilyPermissions)；權限檢查使用下方的 rolePermissionsCache
 
 	// 考慮新增一個緩存機制來儲存角色-權限映射，避免每次都查詢資料庫
 	rolePermissionsCache map[int]map[string]bool // map[roleID]map[permissionName]true
 	cacheMutex           sync.RWMutex            // 讀寫鎖保護緩存
+	cacheWarm            bool                    // WarmCache 是否已成功執行，由 cacheMutex 保護
+	cacheHits            atomic.Uint64           // HasPermission 直接由緩存判斷的次數
+	cacheMisses          atomic.Uint64           // HasPermission 需要從資料庫載入的次數
+	logger               *zap.Logger
 }
 
-// NewPermissionService 創建 PermissionService 實例
-func NewPermissionService(permissionRepo repository.PermissionRepository, roleRepo repository.RoleRepository) PermissionService {
+// NewPermissionService 創建 PermissionService 實例，並在 reg 註冊權限緩存的大小與命中率指標
+func NewPermissionService(permissionRepo repository.PermissionRepository, roleRepo repository.RoleRepository, readCache cache.Cache, reg metrics.Registry, logger *zap.Logger) PermissionService {
 	s := &permissionServiceImpl{
 		permissionRepo:       permissionRepo,
 		roleRepo:             roleRepo,
+		readCache:            readCache,
 		rolePermissionsCache: make(map[int]map[string]bool),
+		logger:               logger,
 	}
+	s.registerMetrics(reg)
 	// 在服務啟動時預載入一些核心權限到緩存 (可選)
 	// s.loadInitialPermissions()
 	return s
 }
 
 // loadPermissionsForRole 從資料庫載入特定角色的所有權限到緩存
-func (s *permissionServiceImpl) loadPermissionsForRole(roleID int) error {
+func (s *permissionServiceImpl) loadPermissionsForRole(ctx context.Context, roleID int) error {
 	s.cacheMutex.Lock()
 	defer s.cacheMutex.Unlock()
 
-	permissions, err := s.permissionRepo.FindPermissionsByRoleID(roleID)
+	permissions, err := s.permissionRepo.FindPermissionsByRoleID(ctx, roleID)
 	if err != nil {
-		zap.L().Error("Service: Failed to load permissions for role from repository", zap.Error(err), zap.Int("role_id", roleID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to load permissions for role from repository", zap.Error(err), zap.Int("role_id", roleID))
 		return fmt.Errorf("failed to load permissions for role %d: %w", roleID, err)
 	}
 
@@ -58,12 +76,130 @@ func (s *permissionServiceImpl) loadPermissionsForRole(roleID int) error {
 		permissionMap[p.Name] = true
 	}
 	s.rolePermissionsCache[roleID] = permissionMap
-	zap.L().Info("Service: Loaded permissions into cache for role", zap.Int("role_id", roleID), zap.Int("count", len(permissionMap)))
+	utils.ContextLogger(ctx, s.logger).Info("Service: Loaded permissions into cache for role", zap.Int("role_id", roleID), zap.Int("count", len(permissionMap)))
 	return nil
 }
 
+// registerMetrics 註冊權限緩存的指標
+func (s *permissionServiceImpl) registerMetrics(reg metrics.Registry) {
+	reg.GaugeFunc("permission_cache_roles", "Number of roles whose permissions are cached.", func() float64 {
+		s.cacheMutex.RLock()
+		defer s.cacheMutex.RUnlock()
+		return float64(len(s.rolePermissionsCache))
+	})
+	reg.CounterFunc("permission_cache_hits_total", "Total permission checks answered from the cache.", func() float64 {
+		return float64(s.cacheHits.Load())
+	})
+	reg.CounterFunc("permission_cache_misses_total", "Total permission checks that loaded permissions from the database.", func() float64 {
+		return float64(s.cacheMisses.Load())
+	})
+	reg.GaugeFunc("permission_cache_hit_ratio", "Ratio of permission checks answered from the cache since startup (0 before the first check).", func() float64 {
+		hits, misses := s.cacheHits.Load(), s.cacheMisses.Load()
+		if hits+misses == 0 {
+			return 0
+		}
+		return float64(hits) / float64(hits+misses)
+	})
+}
+
+// WarmCache 載入所有角色的權限到緩存，避免啟動後的第一批請求逐一查詢資料庫
+func (s *permissionServiceImpl) WarmCache(ctx context.Context) error {
+	roles, err := s.roleRepo.FindAll(ctx)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get roles for permission cache warm-up", zap.Error(err))
+		return fmt.Errorf("failed to get roles: %w", err)
+	}
+	for _, role := range roles {
+		if err := s.loadPermissionsForRole(ctx, role.ID); err != nil {
+			return err
+		}
+	}
+
+	s.cacheMutex.Lock()
+	s.cacheWarm = true
+	s.cacheMutex.Unlock()
+	utils.ContextLogger(ctx, s.logger).Info("Service: Permission cache warmed up", zap.Int("roles", len(roles)))
+	return nil
+}
+
+// CacheWarm 權限緩存是否已預載入
+func (s *permissionServiceImpl) CacheWarm() bool {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+	return s.cacheWarm
+}
+
+// InvalidateRole 移除角色的權限緩存，並使權限目錄的讀取緩存失效；server.New 在每個實例的事件中心收到 permissions_changed 時呼叫
+func (s *permissionServiceImpl) InvalidateRole(ctx context.Context, roleID int) {
+	s.cacheMutex.Lock()
+	delete(s.rolePermissionsCache, roleID)
+	s.cacheMutex.Unlock()
+	s.readCache.Invalidate(ctx, cache.FamilyPermissions)
+	utils.ContextLogger(ctx, s.logger).Info("Service: Invalidated permission cache for role", zap.Int("role_id", roleID))
+}
+
+// GetAllPermissions 獲取權限目錄
+func (s *permissionServiceImpl) GetAllPermissions(ctx context.Context) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if s.readCache.Get(ctx, cache.FamilyPermissions, "all", &permissions) {
+		return permissions, nil
+	}
+	permissions, err := s.permissionRepo.FindAll(ctx)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all permissions", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	s.readCache.Set(ctx, cache.FamilyPermissions, "all", permissions)
+	return permissions, nil
+}
+
+// GetRolePermissions 獲取角色擁有的權限，角色不存在時返回 404
+func (s *permissionServiceImpl) GetRolePermissions(ctx context.Context, roleID int) ([]models.Permission, error) {
+	key := fmt.Sprintf("role:%d", roleID)
+	var permissions []models.Permission
+	if s.readCache.Get(ctx, cache.FamilyPermissions, key, &permissions) {
+		return permissions, nil
+	}
+	role, err := s.roleRepo.FindByID(ctx, roleID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking role for permissions", zap.Error(err), zap.Int("role_id", roleID))
+		return nil, utils.ErrInternalServer
+	}
+	if role == nil {
+		return nil, utils.ErrNotFound
+	}
+	permissions, err = s.permissionRepo.FindPermissionsByRoleID(ctx, roleID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get permissions by role ID", zap.Error(err), zap.Int("role_id", roleID))
+		return nil, utils.ErrInternalServer
+	}
+	s.readCache.Set(ctx, cache.FamilyPermissions, key, permissions)
+	return permissions, nil
+}
+
+// RegisterPermissions 將權限字串登記到權限目錄 (描述由 models.PermissionDescription 產生)，已存在的略過
+// 有新增的權限時使權限目錄的讀取緩存失效；權限尚未賦予任何角色，不影響權限檢查的緩存
+func (s *permissionServiceImpl) RegisterPermissions(ctx context.Context, names []string) (int, error) {
+	permissions := make([]models.Permission, len(names))
+	for i, name := range names {
+		permissions[i] = models.Permission{Name: name, Description: models.PermissionDescription(name)}
+	}
+	created, err := s.permissionRepo.CreateBatch(ctx, permissions)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to register permissions", zap.Error(err), zap.Int("count", len(names)))
+		return 0, fmt.Errorf("failed to register permissions: %w", err)
+	}
+	if created > 0 {
+		s.readCache.Invalidate(ctx, cache.FamilyPermissions)
+		utils.ContextLogger(ctx, s.logger).Info("Service: Registered new permissions", zap.Int("created", created))
+	}
+	return created, nil
+}
+
 // HasPermission 檢查指定角色是否擁有特定權限
-func (s *permissionServiceImpl) HasPermission(roleID int, permission string) (bool, error) {
+func (s *permissionServiceImpl) HasPermission(ctx context.Context, roleID int, permission string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "PermissionService.HasPermission")
+	defer span.End()
 	// 優先從緩存中讀取
 	s.cacheMutex.RLock()
 	rolePerms, ok := s.rolePermissionsCache[roleID]
@@ -71,14 +207,16 @@ func (s *permissionServiceImpl) HasPermission(roleID int, permission string) (bo
 
 	if ok {
 		// 緩存命中
+		s.cacheHits.Add(1)
 		_, has := rolePerms[permission]
 		return has, nil
 	}
 
 	// 緩存未命中，從資料庫載入
-	err := s.loadPermissionsForRole(roleID)
+	s.cacheMisses.Add(1)
+	err := s.loadPermissionsForRole(ctx, roleID)
 	if err != nil {
-		zap.L().Error("Service: Failed to load permissions to cache for role", zap.Error(err), zap.Int("role_id", roleID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to load permissions to cache for role", zap.Error(err), zap.Int("role_id", roleID))
 		return false, utils.ErrInternalServer.SetDetails("Failed to retrieve permissions")
 	}
 
@@ -93,7 +231,7 @@ func (s *permissionServiceImpl) HasPermission(roleID int, permission string) (bo
 	}
 
 	// 理論上不應該到達這裡，除非 loadPermissionsForRole 失敗但沒有返回錯誤
-	zap.L().Error("Service: Permissions not found in cache after load attempt", zap.Int("role_id", roleID), zap.String("permission", permission))
+	utils.ContextLogger(ctx, s.logger).Error("Service: Permissions not found in cache after load attempt", zap.Int("role_id", roleID), zap.String("permission", permission))
 	return false, utils.ErrInternalServer.SetDetails("Could not verify permission")
 }
 
@@ -110,7 +248,7 @@ func (s *permissionServiceImpl) AssignPermissionToRole(roleID, permissionID int)
 
     err := s.permissionRepo.AssignPermissionToRole(roleID, permissionID)
     if err != nil {
-        zap.L().Error("Service: Failed to assign permission to role in repository", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
+        s.logger.Error("Service: Failed to assign permission to role in repository", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
         return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to assign permission: %v", err))
     }
     s.invalidateCache(roleID) // 權限變更後使緩存失效
@@ -120,7 +258,7 @@ func (s *permissionServiceImpl) AssignPermissionToRole(roleID, permissionID int)
 func (s *permissionServiceImpl) RevokePermissionFromRole(roleID, permissionID int) error {
     err := s.permissionRepo.RevokePermissionFromRole(roleID, permissionID)
     if err != nil {
-        zap.L().Error("Service: Failed to revoke permission from role in repository", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
+        s.logger.Error("Service: Failed to revoke permission from role in repository", zap.Error(err), zap.Int("role_id", roleID), zap.Int("permission_id", permissionID))
         return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to revoke permission: %v", err))
     }
     s.invalidateCache(roleID) // 權限變更後使緩存失效
@@ -132,6 +270,6 @@ func (s *permissionServiceImpl) invalidateCache(roleID int) {
     s.cacheMutex.Lock()
     defer s.cacheMutex.Unlock()
     delete(s.rolePermissionsCache, roleID)
-    zap.L().Info("Service: Invalidated permission cache for role", zap.Int("role_id", roleID))
+    s.logger.Info("Service: Invalidated permission cache for role", zap.Int("role_id", roleID))
 }
 */
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/metrics"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+func TestPermissionServiceHasPermission(t *testing.T) {
+	permissions := map[int][]models.Permission{
+		1: {{Name: "company:read"}, {Name: "company:create"}},
+		2: {{Name: "company:read"}},
+	}
+	tests := []struct {
+		name       string
+		repo       fakePermissionRepository
+		roleID     int
+		permission string
+		want       bool
+		wantErr    *utils.CustomError
+	}{
+		{name: "granted", repo: fakePermissionRepository{permissions: permissions}, roleID: 1, permission: "company:create", want: true},
+		{name: "not granted", repo: fakePermissionRepository{permissions: permissions}, roleID: 2, permission: "company:create"},
+		{name: "role without permissions", repo: fakePermissionRepository{permissions: permissions}, roleID: 3, permission: "company:read"},
+		{name: "empty permission name", repo: fakePermissionRepository{permissions: permissions}, roleID: 1},
+		{name: "load fails", repo: fakePermissionRepository{err: errDatabase}, roleID: 1, permission: "company:read", wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			s := NewPermissionService(&repo, nil, &recordingCache{}, metrics.NewRegistry(), zap.NewNop())
+
+			for i := 0; i < 2; i++ { // 第二次呼叫由緩存回答 (載入失敗時不緩存，會再次載入)
+				has, err := s.HasPermission(context.Background(), tt.roleID, tt.permission)
+				if tt.wantErr != nil {
+					var customErr *utils.CustomError
+					if !errors.As(err, &customErr) || !errors.Is(err, tt.wantErr) || customErr.Details != "Failed to retrieve permissions" {
+						t.Fatalf("HasPermission error = %v, want %v", err, tt.wantErr)
+					}
+					if has {
+						t.Error("HasPermission returned true with an error")
+					}
+					continue
+				}
+				if err != nil || has != tt.want {
+					t.Fatalf("HasPermission = %v, %v, want %v", has, err, tt.want)
+				}
+			}
+			wantLoads := 1
+			if tt.wantErr != nil {
+				wantLoads = 2
+			}
+			if repo.loads != wantLoads {
+				t.Errorf("repository loads = %d, want %d", repo.loads, wantLoads)
+			}
+		})
+	}
+}
+
+// TestPermissionServiceHasPermissionConcurrent 同時檢查多個角色的權限時緩存沒有資料競爭 (以 go test -race 執行)
+func TestPermissionServiceHasPermissionConcurrent(t *testing.T) {
+	repo := &lockedPermissionRepository{fakePermissionRepository: fakePermissionRepository{permissions: map[int][]models.Permission{
+		1: {{Name: "company:read"}},
+		2: {{Name: "customer:read"}},
+	}}}
+	s := NewPermissionService(repo, nil, &recordingCache{}, metrics.NewRegistry(), zap.NewNop())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(roleID int) {
+			defer wg.Done()
+			has, err := s.HasPermission(context.Background(), roleID, "company:read")
+			if err != nil || has != (roleID == 1) {
+				t.Errorf("HasPermission(%d) = %v, %v", roleID, has, err)
+			}
+		}(i%2 + 1)
+	}
+	wg.Wait()
+}
+
+// lockedPermissionRepository 可同時呼叫的 fakePermissionRepository
+type lockedPermissionRepository struct {
+	fakePermissionRepository
+	mu sync.Mutex
+}
+
+func (r *lockedPermissionRepository) FindPermissionsByRoleID(ctx context.Context, roleID int) ([]models.Permission, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fakePermissionRepository.FindPermissionsByRoleID(ctx, roleID)
+}
+
+func TestPermissionServiceInvalidateRole(t *testing.T) {
+	repo := &fakePermissionRepository{permissions: map[int][]models.Permission{1: {{Name: "company:read"}}}}
+	s := NewPermissionService(repo, nil, &recordingCache{}, metrics.NewRegistry(), zap.NewNop())
+	ctx := context.Background()
+
+	if has, err := s.HasPermission(ctx, 1, "company:create"); err != nil || has {
+		t.Fatalf("HasPermission before grant = %v, %v", has, err)
+	}
+	repo.permissions[1] = append(repo.permissions[1], models.Permission{Name: "company:create"})
+	if has, _ := s.HasPermission(ctx, 1, "company:create"); has {
+		t.Fatal("HasPermission saw the grant before the cache was invalidated")
+	}
+
+	s.InvalidateRole(ctx, 1)
+	if has, err := s.HasPermission(ctx, 1, "company:create"); err != nil || !has {
+		t.Fatalf("HasPermission after InvalidateRole = %v, %v, want true", has, err)
+	}
+	if repo.loads != 2 {
+		t.Errorf("repository loads = %d, want 2", repo.loads)
+	}
+}
+
+func TestPermissionServiceCatalogCache(t *testing.T) {
+	roles := fakeRoleRepository{roles: map[int]*models.Role{1: {ID: 1, Name: "admin"}}}
+	repo := &fakePermissionRepository{
+		permissions: map[int][]models.Permission{1: {{Name: "company:read"}}},
+		catalog:     []models.Permission{{Name: "company:read"}},
+	}
+	readCache := cache.NewCache(cache.NewMemoryStore(), time.Minute, metrics.NewRegistry())
+	s := NewPermissionService(repo, &roles, readCache, metrics.NewRegistry(), zap.NewNop())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ { // 第二次由緩存回答
+		if catalog, err := s.GetAllPermissions(ctx); err != nil || len(catalog) != 1 {
+			t.Fatalf("GetAllPermissions = %v, %v", catalog, err)
+		}
+		if permissions, err := s.GetRolePermissions(ctx, 1); err != nil || len(permissions) != 1 {
+			t.Fatalf("GetRolePermissions = %v, %v", permissions, err)
+		}
+	}
+	if repo.loads != 2 {
+		t.Fatalf("repository loads = %d, want 2 (one per key)", repo.loads)
+	}
+	if _, err := s.GetRolePermissions(ctx, 9); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("GetRolePermissions for a missing role = %v, want ErrNotFound", err)
+	}
+
+	// 登記新的權限使權限目錄失效，已登記的權限不會使緩存失效
+	created, err := s.RegisterPermissions(ctx, []string{"company:read", "customer:read_history"})
+	if err != nil || created != 1 {
+		t.Fatalf("RegisterPermissions = %d, %v, want 1 created", created, err)
+	}
+	if repo.catalog[1].Description != "Allow read history on customer" {
+		t.Errorf("registered description = %q", repo.catalog[1].Description)
+	}
+	catalog, _ := s.GetAllPermissions(ctx)
+	if len(catalog) != 2 {
+		t.Errorf("catalog after registration = %v, want 2 permissions", catalog)
+	}
+	loads := repo.loads
+	if created, err := s.RegisterPermissions(ctx, []string{"company:read"}); err != nil || created != 0 {
+		t.Fatalf("RegisterPermissions of an existing permission = %d, %v", created, err)
+	}
+	s.GetAllPermissions(ctx)
+	if repo.loads != loads {
+		t.Error("registering only existing permissions invalidated the catalog")
+	}
+
+	// 角色的權限改變 (permissions_changed) 使角色的權限列表失效
+	repo.permissions[1] = append(repo.permissions[1], models.Permission{Name: "company:create"})
+	s.InvalidateRole(ctx, 1)
+	if permissions, _ := s.GetRolePermissions(ctx, 1); len(permissions) != 2 {
+		t.Errorf("GetRolePermissions after InvalidateRole = %v, want 2 permissions", permissions)
+	}
+}
+
+func TestPermissionServiceRegisterPermissionsFails(t *testing.T) {
+	readCache := &recordingCache{}
+	s := NewPermissionService(&fakePermissionRepository{err: errDatabase}, nil, readCache, metrics.NewRegistry(), zap.NewNop())
+	if _, err := s.RegisterPermissions(context.Background(), []string{"company:read"}); !errors.Is(err, errDatabase) {
+		t.Fatalf("RegisterPermissions error = %v, want %v", err, errDatabase)
+	}
+	if len(readCache.invalidated) != 0 {
+		t.Errorf("failed registration invalidated %v", readCache.invalidated)
+	}
+}
@@ -0,0 +1,1279 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/repository"
+	"github.com/wac0705/fastener-api/storage"
+	"github.com/wac0705/fastener-api/tracing"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// ProductDefinitionService 定義產品類別與產品定義服務介面
+type ProductDefinitionService interface {
+	// 產品類別
+	CreateProductCategory(ctx context.Context, category *models.ProductCategory) error
+	GetAllProductCategories(ctx context.Context, opts models.ProductCategoryListOptions) ([]models.ProductCategory, error)
+	GetProductCategoryByID(ctx context.Context, id int) (*models.ProductCategory, error)
+	GetProductCategoryTree(ctx context.Context, opts models.ProductCategoryListOptions) ([]models.ProductCategory, error)
+	UpdateProductCategory(ctx context.Context, category *models.ProductCategory) error
+	DeleteProductCategory(ctx context.Context, id int, mode models.CategoryDeleteMode, reassignTo *int) error
+
+	// 產品定義
+	CreateProductDefinition(ctx context.Context, definition *models.ProductDefinition, actorID int) error // actorID 為執行變更的帳戶，記錄在產品定義歷史中
+	GetAllProductDefinitions(ctx context.Context, filter models.ProductDefinitionFilter, pagination utils.Pagination) (*models.PaginatedResponse, error)
+	GetProductDefinitionByID(ctx context.Context, id int, currency string) (*models.ProductDefinition, error) // currency 非空時填入 QuotedPrice
+	ResolvePublicID(ctx context.Context, publicID string) (int, error)                                        // 將對外的 UUID 識別碼 (public_id) 轉換為 ID，不存在時返回 utils.ErrNotFound
+	GetProductDefinitionVersion(ctx context.Context, id int, at time.Time) (*models.ProductDefinition, error) // 依變更歷史重建 at 當時的產品定義
+	UpdateProductDefinition(ctx context.Context, definition *models.ProductDefinition, actorID int) error
+	DeleteProductDefinition(ctx context.Context, id int, actorID int) error // 停售，不刪除記錄
+	ReactivateProductDefinition(ctx context.Context, id int, actorID int) (*models.ProductDefinition, error)
+	GetProductDefinitionHistory(ctx context.Context, id int, field string, pagination utils.Pagination) (*models.PaginatedResponse, error) // 欄位層級的變更歷史
+	CloneProductDefinition(ctx context.Context, sourceID int, req models.ProductDefinitionCloneRequest, actorID int) (*models.ProductDefinition, error)
+	GetProductStandards(ctx context.Context) ([]string, error)
+	GetProductVariants(ctx context.Context, id int, includeDiscontinued bool) ([]models.ProductDefinition, error)
+	GenerateProductVariants(ctx context.Context, id int, req models.ProductVariantGenerateRequest, actorID int) (*models.ProductVariantGenerateResponse, error)
+	ImportProductDefinitions(ctx context.Context, rows []models.ProductDefinitionImportRow, opts models.ProductDefinitionImportOptions, actorID int) ([]models.ImportRowResult, error)
+	BulkUpdateProductPrices(ctx context.Context, req models.ProductBulkPriceUpdateRequest, actorID int) (*models.ProductBulkPriceUpdateResponse, error) // 批次調整基準價格，DryRun 時只預覽
+
+	// 產品多幣別價格
+	GetProductPrices(ctx context.Context, productID int) ([]models.ProductPrice, error)
+	CreateProductPrice(ctx context.Context, price *models.ProductPrice) error
+	UpdateProductPrice(ctx context.Context, price *models.ProductPrice) error
+	DeleteProductPrice(ctx context.Context, productID, priceID int) error
+
+	// 產品數量分級價格
+	GetProductPriceTiers(ctx context.Context, productID int) ([]models.ProductPriceTier, error)
+	ReplaceProductPriceTiers(ctx context.Context, productID int, tiers []models.ProductPriceTier) (*models.ProductPriceTiersResponse, error)
+	GetProductPriceForQuantity(ctx context.Context, productID, qty int) (*models.ProductQuantityPrice, error)
+
+	// 產品換算單位
+	GetProductUnits(ctx context.Context, productID int) ([]models.ProductUnit, error)
+	ReplaceProductUnits(ctx context.Context, productID int, units []models.ProductUnit) ([]models.ProductUnit, error)
+	ConvertProductUnits(ctx context.Context, productID int, from, to string, value float64) (*models.ProductUnitConversion, error)
+
+	// 產品圖片
+	SetProductImage(ctx context.Context, productID int, data []byte, contentType string, actorID int) (*models.ProductDefinition, error)
+	GetProductImage(ctx context.Context, productID int) (*models.ProductImage, io.ReadCloser, error) // 返回的內容需由呼叫端關閉
+	DeleteProductImage(ctx context.Context, productID int, actorID int) error
+}
+
+// productDefinitionServiceImpl 實現 ProductDefinitionService 介面
+type productDefinitionServiceImpl struct {
+	productDefinitionRepo repository.ProductDefinitionRepository
+	productPriceRepo      repository.ProductPriceRepository
+	productUnitRepo       repository.ProductUnitRepository
+	historyRepo           repository.ProductDefinitionHistoryRepository
+	fileStore             storage.FileStore // 產品圖片的儲存位置
+	standardBodies        []string          // 標準代號允許的標準組織，例如 DIN、ISO
+	baseCurrency          string            // ProductDefinition.Price 的幣別
+	priceScale            int32             // 計算後的價格捨入的小數位數
+	priceRounding         decimal.RoundingMode
+	readCache             cache.Cache // 緩存產品類別的讀取結果 (cache.FamilyProductCategories)，類別寫入成功後失效
+
+	categoryCountsMutex sync.Mutex
+	categoryCounts      map[bool]categoryCountsEntry // 以 includeDiscontinued 為 key 的類別產品數量快取
+	logger              *zap.Logger
+}
+
+// categoryCountsCacheTTL 類別產品數量快取的有效時間，數量只用於顯示，短暫的延遲可以接受
+const categoryCountsCacheTTL = 30 * time.Second
+
+// categoryCountsEntry 快取的各類別直接擁有的產品定義數量
+type categoryCountsEntry struct {
+	counts   map[int]int
+	loadedAt time.Time
+}
+
+// NewProductDefinitionService 創建 ProductDefinitionService 實例
+// standardBodies 為 config.Cfg.ProductStandardBodies，用於驗證產品的標準代號；baseCurrency 為 config.Cfg.ProductBaseCurrency
+// priceScale 與 priceRounding 為 config.Cfg.PriceScale 與 config.Cfg.PriceRoundingMode，用於報價與數量總價的捨入
+func NewProductDefinitionService(repo repository.ProductDefinitionRepository, priceRepo repository.ProductPriceRepository, unitRepo repository.ProductUnitRepository, historyRepo repository.ProductDefinitionHistoryRepository, fileStore storage.FileStore, standardBodies []string, baseCurrency string, priceScale int32, priceRounding decimal.RoundingMode, readCache cache.Cache, logger *zap.Logger) ProductDefinitionService {
+	return &productDefinitionServiceImpl{
+		productDefinitionRepo: repo,
+		productPriceRepo:      priceRepo,
+		productUnitRepo:       unitRepo,
+		historyRepo:           historyRepo,
+		fileStore:             fileStore,
+		standardBodies:        standardBodies,
+		baseCurrency:          baseCurrency,
+		priceScale:            priceScale,
+		priceRounding:         priceRounding,
+		readCache:             readCache,
+		categoryCounts:        map[bool]categoryCountsEntry{},
+		logger:                logger,
+	}
+}
+
+// roundPrice 依設定的小數位數與捨入方式捨入計算後的價格
+func (s *productDefinitionServiceImpl) roundPrice(price decimal.Decimal) decimal.Decimal {
+	return price.Round(s.priceScale, s.priceRounding)
+}
+
+// validateCategoryParent 檢查父類別存在，且不會讓類別成為自己的祖先 (形成循環)
+// 沿著父類別逐層往上走，若遇到類別本身即為循環；新建類別 (ID 為 0) 只檢查父類別是否存在
+func (s *productDefinitionServiceImpl) validateCategoryParent(ctx context.Context, category *models.ProductCategory) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	if category.ID != 0 && *category.ParentID == category.ID {
+		return utils.ErrBadRequest.SetDetails("Product category cannot be its own parent")
+	}
+
+	visited := map[int]bool{}
+	for parentID := category.ParentID; parentID != nil; {
+		parent, err := s.productDefinitionRepo.FindCategoryByID(ctx, *parentID)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking parent product category", zap.Error(err), zap.Int("parent_id", *parentID))
+			return utils.ErrInternalServer
+		}
+		if parent == nil {
+			if len(visited) == 0 { // 直接指定的父類別不存在
+				return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Parent product category %d does not exist", *parentID))
+			}
+			return nil // 祖先鏈中斷，不會形成循環
+		}
+		if category.ID != 0 && parent.ID == category.ID {
+			return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Parent product category %d is a descendant of category %d, which would create a cycle", *category.ParentID, category.ID))
+		}
+		if visited[parent.ID] { // 既有資料已有循環，避免無窮迴圈
+			utils.ContextLogger(ctx, s.logger).Warn("Service: Existing product category cycle detected", zap.Int("category_id", parent.ID))
+			return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Parent product category %d is part of a cycle", *category.ParentID))
+		}
+		visited[parent.ID] = true
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+// CreateProductCategory 創建新產品類別
+func (s *productDefinitionServiceImpl) CreateProductCategory(ctx context.Context, category *models.ProductCategory) error {
+	if err := s.validateCategoryParent(ctx, category); err != nil {
+		return err
+	}
+	if err := s.productDefinitionRepo.CreateCategory(ctx, category); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create product category in repository", zap.Error(err), zap.String("name", category.Name))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create product category: %v", err))
+	}
+	s.readCache.Invalidate(ctx, cache.FamilyProductCategories)
+	return nil
+}
+
+// GetAllProductCategories 獲取所有產品類別，opts.IncludeCounts 時填入各類別 (含子孫類別) 的產品定義數量
+// 不含數量的結果會被緩存；數量隨產品定義改變，另外以 categoryCountsCacheTTL 快取
+func (s *productDefinitionServiceImpl) GetAllProductCategories(ctx context.Context, opts models.ProductCategoryListOptions) ([]models.ProductCategory, error) {
+	var categories []models.ProductCategory
+	if !opts.IncludeCounts && s.readCache.Get(ctx, cache.FamilyProductCategories, "all", &categories) {
+		return categories, nil
+	}
+	categories, err := s.productDefinitionRepo.FindAllCategories(ctx)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all product categories", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if !opts.IncludeCounts {
+		s.readCache.Set(ctx, cache.FamilyProductCategories, "all", categories)
+		return categories, nil
+	}
+	if err := s.applyCategoryCounts(ctx, categories, opts.IncludeDiscontinued); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// categoryProductCounts 返回各類別直接擁有的產品定義數量，結果快取 categoryCountsCacheTTL
+func (s *productDefinitionServiceImpl) categoryProductCounts(ctx context.Context, includeDiscontinued bool) (map[int]int, error) {
+	s.categoryCountsMutex.Lock()
+	defer s.categoryCountsMutex.Unlock()
+
+	if entry, ok := s.categoryCounts[includeDiscontinued]; ok && time.Since(entry.loadedAt) < categoryCountsCacheTTL {
+		return entry.counts, nil
+	}
+	counts, err := s.productDefinitionRepo.CountGroupByCategory(ctx, includeDiscontinued)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to count product definitions per category", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	s.categoryCounts[includeDiscontinued] = categoryCountsEntry{counts: counts, loadedAt: time.Now()}
+	return counts, nil
+}
+
+// applyCategoryCounts 為每個類別填入 ProductCount：自身的產品數量加上所有子孫類別的產品數量
+// 每個類別的數量沿著父類別逐層往上累加，遇到循環的父子關係時停止
+func (s *productDefinitionServiceImpl) applyCategoryCounts(ctx context.Context, categories []models.ProductCategory, includeDiscontinued bool) error {
+	direct, err := s.categoryProductCounts(ctx, includeDiscontinued)
+	if err != nil {
+		return err
+	}
+	parentOf := make(map[int]*int, len(categories))
+	for _, category := range categories {
+		parentOf[category.ID] = category.ParentID
+	}
+	totals := make(map[int]int, len(categories))
+	for categoryID, count := range direct {
+		visited := map[int]bool{}
+		for id := &categoryID; id != nil && !visited[*id]; id = parentOf[*id] {
+			if _, ok := parentOf[*id]; !ok {
+				break // 類別不存在 (例如剛被刪除)
+			}
+			visited[*id] = true
+			totals[*id] += count
+		}
+	}
+	for i := range categories {
+		count := totals[categories[i].ID]
+		categories[i].ProductCount = &count
+	}
+	return nil
+}
+
+// GetProductCategoryByID 根據 ID 獲取產品類別
+func (s *productDefinitionServiceImpl) GetProductCategoryByID(ctx context.Context, id int) (*models.ProductCategory, error) {
+	key := fmt.Sprintf("id:%d", id)
+	var cached models.ProductCategory
+	if s.readCache.Get(ctx, cache.FamilyProductCategories, key, &cached) {
+		return &cached, nil
+	}
+	category, err := s.productDefinitionRepo.FindCategoryByID(ctx, id)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product category by ID", zap.Int("id", id), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if category != nil {
+		s.readCache.Set(ctx, cache.FamilyProductCategories, key, category)
+	}
+	return category, nil // Repository 返回 nil, nil 表示未找到 (不緩存)
+}
+
+// validateReassignTarget 檢查刪除類別時產品定義要移往的類別：必須存在、不可是被刪除的類別本身，
+// cascade 時也不可是被刪除類別的子孫 (否則會一併被刪除)
+func (s *productDefinitionServiceImpl) validateReassignTarget(ctx context.Context, id, reassignTo int, mode models.CategoryDeleteMode) error {
+	if reassignTo == id {
+		return utils.ErrBadRequest.SetDetails("reassign_to cannot be the category being deleted")
+	}
+	visited := map[int]bool{}
+	for targetID := &reassignTo; targetID != nil; {
+		target, err := s.productDefinitionRepo.FindCategoryByID(ctx, *targetID)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking reassign target product category", zap.Error(err), zap.Int("category_id", *targetID))
+			return utils.ErrInternalServer
+		}
+		if target == nil {
+			if len(visited) == 0 {
+				return utils.ErrBadRequest.SetDetails(fmt.Sprintf("reassign_to category %d does not exist", reassignTo))
+			}
+			return nil
+		}
+		if mode != models.CategoryDeleteCascade || visited[target.ID] {
+			return nil // 只有 cascade 需要檢查祖先鏈
+		}
+		if target.ID == id {
+			return utils.ErrBadRequest.SetDetails(fmt.Sprintf("reassign_to category %d is a subcategory of %d and would be deleted by cascade", reassignTo, id))
+		}
+		visited[target.ID] = true
+		targetID = target.ParentID
+	}
+	return nil
+}
+
+// GetProductCategoryTree 獲取產品類別樹，根類別依 ID 排序，子類別放在 Children 中；與 GetAllProductCategories 相同，只緩存不含數量的結果
+func (s *productDefinitionServiceImpl) GetProductCategoryTree(ctx context.Context, opts models.ProductCategoryListOptions) ([]models.ProductCategory, error) {
+	var tree []models.ProductCategory
+	if !opts.IncludeCounts && s.readCache.Get(ctx, cache.FamilyProductCategories, "tree", &tree) {
+		return tree, nil
+	}
+	categories, err := s.productDefinitionRepo.FindAllCategories(ctx)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product categories for tree", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if opts.IncludeCounts {
+		if err := s.applyCategoryCounts(ctx, categories, opts.IncludeDiscontinued); err != nil {
+			return nil, err
+		}
+	}
+	tree = buildCategoryTree(categories)
+	if !opts.IncludeCounts {
+		s.readCache.Set(ctx, cache.FamilyProductCategories, "tree", tree)
+	}
+	return tree, nil
+}
+
+// buildCategoryTree 將類別依 ParentID 組成樹狀結構，父類別不存在的類別視為根類別
+func buildCategoryTree(categories []models.ProductCategory) []models.ProductCategory {
+	exists := make(map[int]bool, len(categories))
+	for _, category := range categories {
+		exists[category.ID] = true
+	}
+	childrenOf := map[int][]models.ProductCategory{}
+	roots := []models.ProductCategory{}
+	for _, category := range categories {
+		if category.ParentID == nil || !exists[*category.ParentID] {
+			roots = append(roots, category)
+			continue
+		}
+		childrenOf[*category.ParentID] = append(childrenOf[*category.ParentID], category)
+	}
+
+	// 已展開過的類別不再展開，避免既有的循環資料造成無窮遞迴
+	visited := map[int]bool{}
+	var attach func(nodes []models.ProductCategory) []models.ProductCategory
+	attach = func(nodes []models.ProductCategory) []models.ProductCategory {
+		for i := range nodes {
+			if visited[nodes[i].ID] {
+				continue
+			}
+			visited[nodes[i].ID] = true
+			nodes[i].Children = attach(childrenOf[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots)
+}
+
+// UpdateProductCategory 更新產品類別信息
+func (s *productDefinitionServiceImpl) UpdateProductCategory(ctx context.Context, category *models.ProductCategory) error {
+	if err := s.validateCategoryParent(ctx, category); err != nil {
+		return err
+	}
+	if err := s.productDefinitionRepo.UpdateCategory(ctx, category); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如未找到
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update product category in repository", zap.Error(err), zap.Int("category_id", category.ID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update product category: %v", err))
+	}
+	s.readCache.Invalidate(ctx, cache.FamilyProductCategories)
+	return nil
+}
+
+// DeleteProductCategory 刪除產品類別，mode 決定如何處理子類別 (見 models.CategoryDeleteMode)
+// 被刪除的類別仍有產品定義時返回 409 與數量；reassignTo 非 nil 時在同一事務中先將產品定義移到該類別
+func (s *productDefinitionServiceImpl) DeleteProductCategory(ctx context.Context, id int, mode models.CategoryDeleteMode, reassignTo *int) error {
+	if !mode.IsValid() {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid children mode %q, expected block, cascade or detach", mode))
+	}
+
+	if reassignTo != nil {
+		if err := s.validateReassignTarget(ctx, id, *reassignTo, mode); err != nil {
+			return err
+		}
+	} else {
+		count, err := s.productDefinitionRepo.CountByCategoryID(ctx, id, mode == models.CategoryDeleteCascade)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Failed to count product definitions for category delete", zap.Error(err), zap.Int("category_id", id))
+			return utils.ErrInternalServer
+		}
+		if count > 0 {
+			return utils.NewConflictError("Product category still has product definitions", map[string]interface{}{
+				"category_id":              id,
+				"product_definition_count": count,
+				"hint":                     "Pass reassign_to=<categoryID> to move them to another category before deleting",
+			})
+		}
+	}
+
+	if err := s.productDefinitionRepo.DeleteCategory(ctx, id, mode, reassignTo); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete product category in repository", zap.Error(err), zap.Int("category_id", id))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete product category: %v", err))
+	}
+	s.readCache.Invalidate(ctx, cache.FamilyProductCategories)
+	return nil
+}
+
+// ensureCategoryExists 檢查產品定義所屬的類別是否存在，並填入類別名稱
+// 不存在時返回指出該 ID 的 400 錯誤，而不是讓外鍵約束在寫入時失敗
+func (s *productDefinitionServiceImpl) ensureCategoryExists(ctx context.Context, definition *models.ProductDefinition) error {
+	category, err := s.productDefinitionRepo.FindCategoryByID(ctx, definition.CategoryID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking product category for definition", zap.Error(err), zap.Int("category_id", definition.CategoryID))
+		return utils.ErrInternalServer
+	}
+	if category == nil {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Product category %d does not exist", definition.CategoryID))
+	}
+	definition.CategoryName = category.Name
+	return nil
+}
+
+// normalizeStandard 將產品的標準代號正規化 (大寫、單一空白)，標準組織不在允許清單中時返回 400
+func (s *productDefinitionServiceImpl) normalizeStandard(definition *models.ProductDefinition) error {
+	normalized, err := utils.NormalizeStandard(definition.Standard, s.standardBodies)
+	if err != nil {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid standard %q, expected one of %s followed by a number (e.g. \"DIN 933\")", definition.Standard, strings.Join(s.standardBodies, ", ")))
+	}
+	definition.Standard = normalized
+	return nil
+}
+
+// validateParentDefinition 檢查變體的父產品定義：必須存在、不能是自己，且變體只有一層
+// (父產品不能是變體，已有變體的產品也不能成為變體)
+func (s *productDefinitionServiceImpl) validateParentDefinition(ctx context.Context, definition *models.ProductDefinition) error {
+	if definition.ParentID == nil {
+		return nil
+	}
+	parentID := *definition.ParentID
+	if definition.ID != 0 && parentID == definition.ID {
+		return utils.ErrBadRequest.SetDetails("A product definition cannot be its own parent")
+	}
+	parent, err := s.productDefinitionRepo.FindByID(ctx, parentID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking parent product definition", zap.Error(err), zap.Int("parent_id", parentID))
+		return utils.ErrInternalServer
+	}
+	if parent == nil {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Parent product definition %d does not exist", parentID))
+	}
+	if parent.ParentID != nil {
+		return utils.ErrBadRequest.SetDetails(fmt.Sprintf("Product definition %d is itself a variant; variants cannot have variants", parentID))
+	}
+	if definition.ID != 0 {
+		count, err := s.productDefinitionRepo.CountVariants(ctx, definition.ID)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error counting product variants", zap.Error(err), zap.Int("definition_id", definition.ID))
+			return utils.ErrInternalServer
+		}
+		if count > 0 {
+			return utils.NewConflictError("Product definition has variants and cannot become a variant", map[string]interface{}{"variant_count": count})
+		}
+	}
+	return nil
+}
+
+// ensureUniqueName 檢查同一類別中沒有其他同名 (不區分大小寫) 的產品定義，包含已停售的產品
+// 資料庫的唯一索引仍會擋下同時發生的寫入，這裡先檢查以返回明確的錯誤訊息；moving 為 true 表示更新時變更了類別
+func (s *productDefinitionServiceImpl) ensureUniqueName(ctx context.Context, definition *models.ProductDefinition, moving bool) error {
+	existing, err := s.productDefinitionRepo.FindByNameAndCategory(ctx, definition.Name, definition.CategoryID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking product definition name in category", zap.Error(err), zap.String("name", definition.Name), zap.Int("category_id", definition.CategoryID))
+		return utils.ErrInternalServer
+	}
+	if existing == nil || existing.ID == definition.ID {
+		return nil
+	}
+	details := map[string]interface{}{"existing_id": existing.ID, "category_id": definition.CategoryID}
+	if moving {
+		return utils.NewConflictError(fmt.Sprintf("Cannot move product definition to category %q: a product definition named %q already exists there", definition.CategoryName, existing.Name), details)
+	}
+	return utils.NewConflictError(fmt.Sprintf("A product definition named %q already exists in category %q", existing.Name, definition.CategoryName), details)
+}
+
+// CreateProductDefinition 創建新產品定義，並記錄 created 事件
+func (s *productDefinitionServiceImpl) CreateProductDefinition(ctx context.Context, definition *models.ProductDefinition, actorID int) error {
+	ctx, span := tracing.Start(ctx, "ProductDefinitionService.CreateProductDefinition")
+	defer span.End()
+	if err := s.normalizeStandard(definition); err != nil {
+		return err
+	}
+	if err := s.ensureCategoryExists(ctx, definition); err != nil {
+		return err
+	}
+	if err := s.validateParentDefinition(ctx, definition); err != nil {
+		return err
+	}
+	if err := s.ensureUniqueName(ctx, definition, false); err != nil {
+		return err
+	}
+	definition.SKU = strings.TrimSpace(definition.SKU)
+	history := []models.ProductDefinitionHistory{{Event: models.ProductDefinitionEventCreated, ActorID: &actorID}}
+	if err := s.productDefinitionRepo.Create(ctx, definition, actorID, history); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如 SKU 重複
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create product definition in repository", zap.Error(err), zap.String("name", definition.Name))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create product definition: %v", err))
+	}
+	return nil
+}
+
+// GetAllProductDefinitions 依搜尋條件分頁獲取產品定義
+func (s *productDefinitionServiceImpl) GetAllProductDefinitions(ctx context.Context, filter models.ProductDefinitionFilter, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	ctx, span := tracing.Start(ctx, "ProductDefinitionService.GetAllProductDefinitions")
+	defer span.End()
+	if filter.Standard != "" {
+		standard, err := utils.NormalizeStandard(filter.Standard, s.standardBodies)
+		if err != nil {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid standard filter %q", filter.Standard))
+		}
+		filter.Standard = standard // 與儲存時相同的正規化，才能完全相符
+	}
+	definitions, page, err := s.productDefinitionRepo.FindAll(ctx, filter, pagination)
+	if err != nil {
+		if _, ok := err.(*utils.CustomError); ok {
+			return nil, err // 例如不合法的排序欄位
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all product definitions", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if err := s.applyQuotedPrices(ctx, definitions, filter.Currency); err != nil {
+		return nil, err
+	}
+	return models.NewPageResponse(definitions, pagination.Page, pagination.PageSize, page), nil
+}
+
+// GetProductDefinitionByID 根據 ID 獲取產品定義，currency 非空時填入該幣別的報價
+func (s *productDefinitionServiceImpl) GetProductDefinitionByID(ctx context.Context, id int, currency string) (*models.ProductDefinition, error) {
+	definition, err := s.productDefinitionRepo.FindByID(ctx, id)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product definition by ID", zap.Int("id", id), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if definition == nil {
+		return nil, nil // Repository 返回 nil, nil 表示未找到
+	}
+	definitions := []models.ProductDefinition{*definition}
+	if err := s.applyQuotedPrices(ctx, definitions, currency); err != nil {
+		return nil, err
+	}
+	return &definitions[0], nil
+}
+
+// ResolvePublicID 根據對外的 UUID 識別碼返回產品定義 ID (包含已停售的產品定義)
+func (s *productDefinitionServiceImpl) ResolvePublicID(ctx context.Context, publicID string) (int, error) {
+	definition, err := s.productDefinitionRepo.FindByPublicID(ctx, publicID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product definition by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return 0, utils.ErrInternalServer
+	}
+	if definition == nil {
+		return 0, utils.ErrNotFound
+	}
+	return definition.ID, nil
+}
+
+// applyQuotedPrices 為每個產品定義填入 currency 目前生效的價格，並依設定捨入
+// 沒有該幣別的有效價格時退回基準幣別的 price，並標記 Converted 為 false；currency 為空時不處理
+func (s *productDefinitionServiceImpl) applyQuotedPrices(ctx context.Context, definitions []models.ProductDefinition, currency string) error {
+	if currency == "" || len(definitions) == 0 {
+		return nil
+	}
+	ids := make([]int, len(definitions))
+	for i, definition := range definitions {
+		ids[i] = definition.ID
+	}
+	prices, err := s.productPriceRepo.FindEffective(ctx, ids, currency)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get effective product prices", zap.Error(err), zap.String("currency", currency))
+		return utils.ErrInternalServer
+	}
+	for i := range definitions {
+		if price, ok := prices[definitions[i].ID]; ok {
+			definitions[i].QuotedPrice = &models.ProductQuotedPrice{Currency: price.Currency, Price: s.roundPrice(price.Price), ValidFrom: price.ValidFrom, Converted: true}
+			continue
+		}
+		definitions[i].QuotedPrice = &models.ProductQuotedPrice{
+			Currency:  s.baseCurrency,
+			Price:     s.roundPrice(definitions[i].Price),
+			Converted: currency == s.baseCurrency, // 要求的正是基準幣別時無需換算
+		}
+	}
+	return nil
+}
+
+// UpdateProductDefinition 更新產品定義信息，每個變更的欄位以 actorID 記錄在產品定義歷史中
+func (s *productDefinitionServiceImpl) UpdateProductDefinition(ctx context.Context, definition *models.ProductDefinition, actorID int) error {
+	existing, err := s.findProductDefinition(ctx, definition.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.normalizeStandard(definition); err != nil {
+		return err
+	}
+	if err := s.ensureCategoryExists(ctx, definition); err != nil {
+		return err
+	}
+	if err := s.validateParentDefinition(ctx, definition); err != nil {
+		return err
+	}
+	if err := s.ensureUniqueName(ctx, definition, existing.CategoryID != definition.CategoryID); err != nil {
+		return err
+	}
+	definition.SKU = strings.TrimSpace(definition.SKU)
+	history := diffProductDefinition(existing, definition, actorID)
+	if err := s.productDefinitionRepo.Update(ctx, definition, actorID, history); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如未找到
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update product definition in repository", zap.Error(err), zap.Int("definition_id", definition.ID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update product definition: %v", err))
+	}
+	return nil
+}
+
+// DeleteProductDefinition 停售產品定義 (軟刪除)，記錄保留給歷史報價並可重新啟用
+func (s *productDefinitionServiceImpl) DeleteProductDefinition(ctx context.Context, id int, actorID int) error {
+	history := []models.ProductDefinitionHistory{{Event: models.ProductDefinitionEventDiscontinued, Field: "discontinued_at", ActorID: &actorID}}
+	if err := s.productDefinitionRepo.Delete(ctx, id, actorID, history); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete product definition in repository", zap.Error(err), zap.Int("definition_id", id))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete product definition: %v", err))
+	}
+	return nil
+}
+
+// ReactivateProductDefinition 重新啟用已停售的產品定義，返回更新後的記錄
+func (s *productDefinitionServiceImpl) ReactivateProductDefinition(ctx context.Context, id int, actorID int) (*models.ProductDefinition, error) {
+	history := []models.ProductDefinitionHistory{{Event: models.ProductDefinitionEventReactivated, Field: "discontinued_at", ActorID: &actorID}}
+	if err := s.productDefinitionRepo.Reactivate(ctx, id, actorID, history); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 例如未找到
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to reactivate product definition in repository", zap.Error(err), zap.Int("definition_id", id))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to reactivate product definition: %v", err))
+	}
+	return s.findProductDefinition(ctx, id)
+}
+
+// CloneProductDefinition 複製產品定義的規格欄位、幣別價格、數量分級價格與換算單位，返回新的產品定義
+// 未指定 SKU 時由原 SKU 產生；複製已停售的產品時新產品仍為啟用狀態
+func (s *productDefinitionServiceImpl) CloneProductDefinition(ctx context.Context, sourceID int, req models.ProductDefinitionCloneRequest, actorID int) (*models.ProductDefinition, error) {
+	newID, err := s.productDefinitionRepo.Clone(ctx, sourceID, strings.TrimSpace(req.Name), strings.TrimSpace(req.SKU), actorID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 例如未找到或 SKU 重複
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to clone product definition in repository", zap.Error(err), zap.Int("source_id", sourceID))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to clone product definition: %v", err))
+	}
+	return s.findProductDefinition(ctx, newID)
+}
+
+// GetProductStandards 獲取使用中的標準代號，供列表篩選的下拉選單使用
+func (s *productDefinitionServiceImpl) GetProductStandards(ctx context.Context) ([]string, error) {
+	standards, err := s.productDefinitionRepo.FindDistinctStandards(ctx)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product standards", zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	return standards, nil
+}
+
+// ImportProductDefinitions 批次匯入已通過欄位驗證的產品定義，依 SKU upsert
+// 標準代號依設定正規化，類別以名稱解析 (不區分大小寫)；找不到時若 opts.CreateCategories 為 true 則在匯入事務中建立，否則該列標記為失敗。
+// 同一檔案中重複的 SKU 只接受第一列。opts.Partial 為 false 時只要有一列失敗就不寫入任何資料；寫入的記錄以 actorID 為 created_by/updated_by
+func (s *productDefinitionServiceImpl) ImportProductDefinitions(ctx context.Context, rows []models.ProductDefinitionImportRow, opts models.ProductDefinitionImportOptions, actorID int) ([]models.ImportRowResult, error) {
+	ctx, span := tracing.Start(ctx, "ProductDefinitionService.ImportProductDefinitions")
+	defer span.End()
+	results := []models.ImportRowResult{}
+	resolved := make([]models.ProductDefinitionImportRow, 0, len(rows))
+	categoryIDs := map[string]int{} // 小寫類別名稱 => ID (0 表示不存在)
+	skuLines := map[string]int{}    // SKU => 第一次出現的行號
+	for _, row := range rows {
+		row.SKU = strings.TrimSpace(row.SKU)
+		if line, ok := skuLines[row.SKU]; ok {
+			results = append(results, models.ImportRowResult{
+				Line:    row.Line,
+				Action:  models.ImportActionInvalid,
+				Details: fmt.Sprintf("Duplicate SKU %s (already on line %d)", row.SKU, line),
+			})
+			continue
+		}
+		skuLines[row.SKU] = row.Line
+
+		standard, err := utils.NormalizeStandard(row.Standard, s.standardBodies)
+		if err != nil {
+			results = append(results, models.ImportRowResult{
+				Line:    row.Line,
+				Action:  models.ImportActionInvalid,
+				Details: fmt.Sprintf("Invalid standard %q, expected one of %s followed by a number", row.Standard, strings.Join(s.standardBodies, ", ")),
+			})
+			continue
+		}
+		row.Standard = standard
+
+		key := strings.ToLower(row.CategoryName)
+		categoryID, ok := categoryIDs[key]
+		if !ok {
+			category, err := s.productDefinitionRepo.FindCategoryByName(ctx, row.CategoryName)
+			if err != nil {
+				utils.ContextLogger(ctx, s.logger).Error("Service: Failed to resolve category name for product definition import", zap.Error(err), zap.Int("line", row.Line))
+				return nil, utils.ErrInternalServer
+			}
+			if category != nil {
+				categoryID = category.ID
+			}
+			categoryIDs[key] = categoryID
+		}
+		if categoryID == 0 && !opts.CreateCategories {
+			results = append(results, models.ImportRowResult{
+				Line:    row.Line,
+				Action:  models.ImportActionInvalid,
+				Details: fmt.Sprintf("Product category not found: %s", row.CategoryName),
+			})
+			continue
+		}
+		row.CategoryID = categoryID
+		resolved = append(resolved, row)
+	}
+	if len(resolved) == 0 {
+		return results, nil
+	}
+
+	// 不允許部分寫入時，已有失敗的列則其餘各列只驗證不寫入
+	dryRun := opts.DryRun || (!opts.Partial && len(results) > 0)
+	written, err := s.productDefinitionRepo.ImportBatch(ctx, resolved, actorID, opts.Partial, dryRun)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to import product definitions", zap.Error(err), zap.Int("rows", len(resolved)), zap.Bool("dry_run", dryRun))
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Import aborted, no rows were written: %v", err))
+	}
+	if opts.CreateCategories && !dryRun {
+		s.readCache.Invalidate(ctx, cache.FamilyProductCategories) // 匯入時可能建立了新的類別
+	}
+	return append(results, written...), nil
+}
+
+// productBulkPriceSampleSize 批次調整價格的回應中最多返回的變更範例數量
+const productBulkPriceSampleSize = 20
+
+// BulkUpdateProductPrices 依條件批次調整產品定義的基準價格，所有變更與價格歷史在同一事務中寫入
+// 捨入規則未指定時使用 PRICE_SCALE 與 PRICE_ROUNDING_MODE；req.DryRun 為 true 時只返回預覽
+func (s *productDefinitionServiceImpl) BulkUpdateProductPrices(ctx context.Context, req models.ProductBulkPriceUpdateRequest, actorID int) (*models.ProductBulkPriceUpdateResponse, error) {
+	if req.Filter.CategoryID == nil && req.Filter.Standard == "" && len(req.Filter.SKUs) == 0 {
+		return nil, utils.ErrBadRequest.SetDetails("At least one of filter.category_id, filter.standard or filter.skus is required")
+	}
+	adjustment := req.Adjustment
+	if !adjustment.Type.IsValid() {
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid adjustment type: %s", adjustment.Type))
+	}
+	if adjustment.Value.IsZero() {
+		return nil, utils.ErrBadRequest.SetDetails("Adjustment value must not be zero")
+	}
+	if adjustment.Type == models.PriceAdjustmentPercent && adjustment.Value.Cmp(decimal.NewFromInt(-100)) < 0 {
+		return nil, utils.ErrBadRequest.SetDetails("Percent adjustment cannot be below -100")
+	}
+	if adjustment.Scale == nil {
+		scale := s.priceScale
+		adjustment.Scale = &scale
+	}
+	if adjustment.Rounding == "" {
+		adjustment.Rounding = s.priceRounding
+	}
+	if !adjustment.Rounding.IsValid() {
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid rounding mode: %s", adjustment.Rounding))
+	}
+
+	filter := models.ProductDefinitionFilter{
+		CategoryID:         req.Filter.CategoryID,
+		IncludeDescendants: req.Filter.IncludeDescendants,
+		SKUs:               req.Filter.SKUs,
+	}
+	if req.Filter.Standard != "" {
+		standard, err := utils.NormalizeStandard(req.Filter.Standard, s.standardBodies)
+		if err != nil {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid standard filter %q", req.Filter.Standard))
+		}
+		filter.Standard = standard
+	}
+	if filter.CategoryID != nil {
+		category, err := s.productDefinitionRepo.FindCategoryByID(ctx, *filter.CategoryID)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Failed to check product category for bulk price update", zap.Error(err), zap.Int("category_id", *filter.CategoryID))
+			return nil, utils.ErrInternalServer
+		}
+		if category == nil {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Product category not found: %d", *filter.CategoryID))
+		}
+	}
+
+	matched, changes, err := s.productDefinitionRepo.BulkUpdatePrices(ctx, filter, adjustment, actorID, req.DryRun)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 例如調整後價格為負數
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to bulk update product prices", zap.Error(err), zap.Bool("dry_run", req.DryRun))
+		return nil, utils.ErrInternalServer
+	}
+	samples := changes
+	if len(samples) > productBulkPriceSampleSize {
+		samples = samples[:productBulkPriceSampleSize]
+	}
+	if !req.DryRun {
+		utils.ContextLogger(ctx, s.logger).Info("Service: Bulk updated product prices", zap.Int("matched", matched), zap.Int("affected", len(changes)), zap.Int("actor_id", actorID))
+	}
+	return &models.ProductBulkPriceUpdateResponse{DryRun: req.DryRun, Matched: matched, Affected: len(changes), Samples: samples}, nil
+}
+
+// maxGeneratedVariants 單次產生變體的數量上限
+const maxGeneratedVariants = 200
+
+// 產生變體時未指定樣式的預設值
+const (
+	defaultVariantNamePattern = "{name} x {length}"
+	defaultVariantSKUPattern  = "{sku}-{length}"
+)
+
+// GetProductVariants 獲取產品定義的變體，依 ID 排序 (產生時依長度遞增建立)
+func (s *productDefinitionServiceImpl) GetProductVariants(ctx context.Context, id int, includeDiscontinued bool) ([]models.ProductDefinition, error) {
+	if _, err := s.findProductDefinition(ctx, id); err != nil {
+		return nil, err
+	}
+	filter := models.ProductDefinitionFilter{ParentID: &id, IncludeDiscontinued: includeDiscontinued}
+	variants, _, err := s.productDefinitionRepo.FindAll(ctx, filter, utils.Pagination{})
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product variants", zap.Error(err), zap.Int("definition_id", id))
+		return nil, utils.ErrInternalServer
+	}
+	return variants, nil
+}
+
+// GenerateProductVariants 依長度範圍與間距產生變體，規格、類別、單位與價格沿用父產品
+// SKU 已存在的長度會略過 (可重複呼叫以補齊範圍)；父產品不能是變體或已停售
+func (s *productDefinitionServiceImpl) GenerateProductVariants(ctx context.Context, id int, req models.ProductVariantGenerateRequest, actorID int) (*models.ProductVariantGenerateResponse, error) {
+	parent, err := s.findProductDefinition(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if parent.ParentID != nil {
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Product definition %d is itself a variant; variants cannot have variants", id))
+	}
+	if parent.Discontinued {
+		return nil, utils.NewConflictError("Cannot generate variants of a discontinued product definition", map[string]interface{}{"id": id})
+	}
+
+	namePattern := strings.TrimSpace(req.NamePattern)
+	if namePattern == "" {
+		namePattern = defaultVariantNamePattern
+	}
+	skuPattern := strings.TrimSpace(req.SKUPattern)
+	if skuPattern == "" {
+		skuPattern = defaultVariantSKUPattern
+	}
+	if !strings.Contains(namePattern, "{length}") || !strings.Contains(skuPattern, "{length}") {
+		return nil, utils.ErrBadRequest.SetDetails("name_pattern and sku_pattern must contain {length}")
+	}
+	if strings.Contains(skuPattern, "{sku}") && parent.SKU == "" {
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Product definition %d has no SKU; set one or use a sku_pattern without {sku}", id))
+	}
+
+	count := int(math.Floor((req.LengthTo-req.LengthFrom)/req.Step+1e-9)) + 1 // 容許浮點誤差，讓 20~100 間距 10 包含 100
+	if count > maxGeneratedVariants {
+		return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Length range produces %d variants, at most %d can be generated at once", count, maxGeneratedVariants))
+	}
+
+	lengths := make([]float64, count)
+	variants := make([]models.ProductDefinition, count)
+	for i := range variants {
+		lengths[i] = math.Round((req.LengthFrom+float64(i)*req.Step)*1e4) / 1e4
+		replacer := strings.NewReplacer("{name}", parent.Name, "{sku}", parent.SKU, "{length}", strconv.FormatFloat(lengths[i], 'f', -1, 64))
+		variants[i] = models.ProductDefinition{
+			SKU:          replacer.Replace(skuPattern),
+			Name:         replacer.Replace(namePattern),
+			Description:  parent.Description,
+			CategoryID:   parent.CategoryID,
+			CategoryName: parent.CategoryName,
+			Standard:     parent.Standard,
+			Unit:         parent.Unit,
+			Price:        parent.Price,
+		}
+		if len(variants[i].SKU) > 64 || len(variants[i].Name) > 255 {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Generated SKU or name for length %v is too long", lengths[i]))
+		}
+	}
+
+	existingIDs, err := s.productDefinitionRepo.CreateVariants(ctx, id, variants, actorID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 例如 SKU 與同時建立的產品衝突
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to generate product variants", zap.Error(err), zap.Int("definition_id", id))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to generate product variants: %v", err))
+	}
+
+	result := &models.ProductVariantGenerateResponse{Created: []models.ProductDefinition{}, Skipped: []models.ProductVariantSkipped{}}
+	for i, variant := range variants {
+		if existingIDs[i] != 0 {
+			result.Skipped = append(result.Skipped, models.ProductVariantSkipped{Length: lengths[i], SKU: variant.SKU, ExistingID: existingIDs[i]})
+			continue
+		}
+		result.Created = append(result.Created, variant)
+	}
+	return result, nil
+}
+
+// findProductDefinition 獲取子資源所屬的產品定義 (含已停售)，不存在時返回 404
+func (s *productDefinitionServiceImpl) findProductDefinition(ctx context.Context, productID int) (*models.ProductDefinition, error) {
+	definition, err := s.productDefinitionRepo.FindByID(ctx, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking product definition existence", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer
+	}
+	if definition == nil {
+		return nil, utils.ErrNotFound.SetDetails(fmt.Sprintf("Product definition %d not found", productID))
+	}
+	return definition, nil
+}
+
+// GetProductPrices 獲取產品定義的所有幣別價格
+func (s *productDefinitionServiceImpl) GetProductPrices(ctx context.Context, productID int) ([]models.ProductPrice, error) {
+	if _, err := s.findProductDefinition(ctx, productID); err != nil {
+		return nil, err
+	}
+	prices, err := s.productPriceRepo.FindByProductID(ctx, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product prices", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer
+	}
+	return prices, nil
+}
+
+// CreateProductPrice 為產品定義新增一個幣別價格
+func (s *productDefinitionServiceImpl) CreateProductPrice(ctx context.Context, price *models.ProductPrice) error {
+	if _, err := s.findProductDefinition(ctx, price.ProductID); err != nil {
+		return err
+	}
+	if err := s.productPriceRepo.Create(ctx, price); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如同幣別同生效日已存在
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create product price in repository", zap.Error(err), zap.Int("product_id", price.ProductID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create product price: %v", err))
+	}
+	return nil
+}
+
+// UpdateProductPrice 更新產品定義的幣別價格
+func (s *productDefinitionServiceImpl) UpdateProductPrice(ctx context.Context, price *models.ProductPrice) error {
+	if err := s.productPriceRepo.Update(ctx, price); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如未找到或衝突
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update product price in repository", zap.Error(err), zap.Int("price_id", price.ID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update product price: %v", err))
+	}
+	return nil
+}
+
+// DeleteProductPrice 刪除產品定義的幣別價格
+func (s *productDefinitionServiceImpl) DeleteProductPrice(ctx context.Context, productID, priceID int) error {
+	if err := s.productPriceRepo.Delete(ctx, productID, priceID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete product price in repository", zap.Error(err), zap.Int("price_id", priceID))
+		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete product price: %v", err))
+	}
+	return nil
+}
+
+// GetProductPriceTiers 獲取產品定義的數量分級價格
+func (s *productDefinitionServiceImpl) GetProductPriceTiers(ctx context.Context, productID int) ([]models.ProductPriceTier, error) {
+	if _, err := s.findProductDefinition(ctx, productID); err != nil {
+		return nil, err
+	}
+	tiers, err := s.productPriceRepo.FindTiers(ctx, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product price tiers", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer
+	}
+	return tiers, nil
+}
+
+// ReplaceProductPriceTiers 以 tiers 整組取代產品定義的數量分級價格 (傳入空陣列表示清除)
+// 已停售的產品只能清除分級，不能設定新的分級
+// 分級必須屬於該產品且 min_qty 不可重複；數量越多單價反而越高時仍會儲存，但在 Warnings 中提醒
+func (s *productDefinitionServiceImpl) ReplaceProductPriceTiers(ctx context.Context, productID int, tiers []models.ProductPriceTier) (*models.ProductPriceTiersResponse, error) {
+	definition, err := s.findProductDefinition(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if definition.Discontinued && len(tiers) > 0 {
+		return nil, utils.NewConflictError(fmt.Sprintf("Product definition %d is discontinued and cannot get new price tiers", productID), map[string]interface{}{"discontinued_at": definition.DiscontinuedAt})
+	}
+
+	sorted := append([]models.ProductPriceTier{}, tiers...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].MinQty < sorted[j].MinQty })
+	warnings := []string{}
+	for i, tier := range sorted {
+		if tier.ProductID != 0 && tier.ProductID != productID {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Price tier with min_qty %d belongs to product %d, not %d", tier.MinQty, tier.ProductID, productID))
+		}
+		if i == 0 {
+			continue
+		}
+		previous := sorted[i-1]
+		if tier.MinQty == previous.MinQty {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Duplicate price tier min_qty %d", tier.MinQty))
+		}
+		if tier.Price.Cmp(previous.Price) > 0 {
+			warnings = append(warnings, fmt.Sprintf("Tier min_qty %d price %s is higher than tier min_qty %d price %s", tier.MinQty, tier.Price, previous.MinQty, previous.Price))
+		}
+	}
+
+	if err := s.productPriceRepo.ReplaceTiers(ctx, productID, sorted); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to replace product price tiers in repository", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to replace product price tiers: %v", err))
+	}
+	return &models.ProductPriceTiersResponse{Tiers: sorted, Warnings: warnings}, nil
+}
+
+// GetProductPriceForQuantity 依購買數量解析產品定義的單價，沒有適用的分級時使用基準價格
+// UnitPrice 保留儲存的精度，Total 以未捨入的單價乘以數量後再依設定捨入，避免單價極低時誤差被放大
+func (s *productDefinitionServiceImpl) GetProductPriceForQuantity(ctx context.Context, productID, qty int) (*models.ProductQuantityPrice, error) {
+	definition, err := s.findProductDefinition(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	tier, err := s.productPriceRepo.FindTierForQuantity(ctx, productID, qty)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to resolve product price tier", zap.Error(err), zap.Int("product_id", productID), zap.Int("qty", qty))
+		return nil, utils.ErrInternalServer
+	}
+
+	result := &models.ProductQuantityPrice{
+		ProductID: productID,
+		Quantity:  qty,
+		Currency:  s.baseCurrency,
+		UnitPrice: definition.Price,
+		Tier:      tier,
+	}
+	if tier != nil {
+		result.UnitPrice = tier.Price
+	}
+	result.Total = s.roundPrice(result.UnitPrice.MulInt(int64(qty)))
+	return result, nil
+}
+
+// normalizeUnit 單位名稱一律去除前後空白並轉為小寫，比對時不分大小寫
+func normalizeUnit(unit string) string {
+	return strings.ToLower(strings.TrimSpace(unit))
+}
+
+// findProductWithUnit 獲取產品定義並確認已設定標準單位 (Unit)，返回正規化後的標準單位
+func (s *productDefinitionServiceImpl) findProductWithUnit(ctx context.Context, productID int) (string, error) {
+	definition, err := s.findProductDefinition(ctx, productID)
+	if err != nil {
+		return "", err
+	}
+	canonical := normalizeUnit(definition.Unit)
+	if canonical == "" {
+		return "", utils.ErrBadRequest.SetDetails(fmt.Sprintf("Product definition %d has no unit; set its canonical unit (e.g. pcs) before configuring conversions", productID))
+	}
+	return canonical, nil
+}
+
+// GetProductUnits 獲取產品定義的換算單位
+func (s *productDefinitionServiceImpl) GetProductUnits(ctx context.Context, productID int) ([]models.ProductUnit, error) {
+	if _, err := s.findProductDefinition(ctx, productID); err != nil {
+		return nil, err
+	}
+	units, err := s.productUnitRepo.FindByProductID(ctx, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product units", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer
+	}
+	return units, nil
+}
+
+// ReplaceProductUnits 以 units 整組取代產品定義的換算單位 (傳入空陣列表示清除)
+// 單位名稱不可重複、不可為標準單位本身，換算係數必須大於 0
+func (s *productDefinitionServiceImpl) ReplaceProductUnits(ctx context.Context, productID int, units []models.ProductUnit) ([]models.ProductUnit, error) {
+	canonical, err := s.findProductWithUnit(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := make([]models.ProductUnit, 0, len(units))
+	seen := make(map[string]bool, len(units))
+	for _, unit := range units {
+		unit.Unit = normalizeUnit(unit.Unit)
+		switch {
+		case unit.Unit == "":
+			return nil, utils.ErrBadRequest.SetDetails("Unit name is required")
+		case unit.Unit == canonical:
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Unit %s is the canonical unit and always has factor 1", unit.Unit))
+		case seen[unit.Unit]:
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Duplicate unit %s", unit.Unit))
+		case unit.Factor <= 0:
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Factor for unit %s must be positive", unit.Unit))
+		}
+		seen[unit.Unit] = true
+		normalized = append(normalized, unit)
+	}
+
+	if err := s.productUnitRepo.Replace(ctx, productID, normalized); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to replace product units in repository", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to replace product units: %v", err))
+	}
+	return normalized, nil
+}
+
+// ConvertProductUnits 將產品數量 value 從 from 單位換算為 to 單位 (經由標準單位)
+// 缺少所需的換算係數時返回 400，並列出需要設定的單位與目前已設定的單位
+func (s *productDefinitionServiceImpl) ConvertProductUnits(ctx context.Context, productID int, from, to string, value float64) (*models.ProductUnitConversion, error) {
+	canonical, err := s.findProductWithUnit(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	units, err := s.productUnitRepo.FindByProductID(ctx, productID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product units for conversion", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer
+	}
+
+	factors := map[string]float64{canonical: 1}
+	configured := []string{canonical}
+	for _, unit := range units {
+		factors[unit.Unit] = unit.Factor
+		configured = append(configured, unit.Unit)
+	}
+
+	from, to = normalizeUnit(from), normalizeUnit(to)
+	missing := []string{}
+	if _, ok := factors[from]; !ok {
+		missing = append(missing, from)
+	}
+	if _, ok := factors[to]; !ok && to != from {
+		missing = append(missing, to)
+	}
+	if len(missing) > 0 {
+		return nil, utils.NewCustomError(http.StatusBadRequest, "Missing unit conversion factor", map[string]interface{}{
+			"missing_units":    missing,
+			"canonical_unit":   canonical,
+			"configured_units": configured,
+			"hint":             fmt.Sprintf("Configure each missing unit via PUT /api/product_definitions/%d/units with its factor in %s per unit", productID, canonical),
+		})
+	}
+
+	return &models.ProductUnitConversion{
+		ProductID:     productID,
+		CanonicalUnit: canonical,
+		From:          from,
+		To:            to,
+		Value:         value,
+		Result:        value * factors[from] / factors[to],
+	}, nil
+}
+
+// productImageExtensions 常見圖片格式的副檔名，其他格式由 mime 套件推斷
+var productImageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// productImageKey 以內容雜湊產生圖片的 key，例如 "product_definitions/12/3f2a…c9.png"；相同內容重複上傳時 key 不變
+func productImageKey(productID int, data []byte, contentType string) string {
+	sum := sha256.Sum256(data)
+	ext, ok := productImageExtensions[contentType]
+	if !ok {
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	return fmt.Sprintf("product_definitions/%d/%s%s", productID, hex.EncodeToString(sum[:16]), ext)
+}
+
+// SetProductImage 儲存產品圖片並記錄於產品定義，成功後刪除被取代的舊圖片檔案
+// contentType 由呼叫端依檔案內容判斷並檢查是否允許；記錄失敗時刪除剛上傳的檔案，不留下孤兒檔案
+func (s *productDefinitionServiceImpl) SetProductImage(ctx context.Context, productID int, data []byte, contentType string, actorID int) (*models.ProductDefinition, error) {
+	definition, err := s.findProductDefinition(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := productImageKey(productID, data, contentType)
+	if err := s.fileStore.Put(key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to store product image", zap.Error(err), zap.Int("product_id", productID), zap.String("key", key))
+		return nil, utils.ErrInternalServer
+	}
+	oldKey, err := s.productDefinitionRepo.SetImage(ctx, productID, key, contentType, actorID)
+	if err != nil {
+		if key != definition.ImageKey { // 與現有圖片相同時檔案仍在使用中，不能刪除
+			if delErr := s.fileStore.Delete(key); delErr != nil {
+				utils.ContextLogger(ctx, s.logger).Warn("Service: Failed to remove stored product image after error", zap.Error(delErr), zap.String("key", key))
+			}
+		}
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 例如上傳期間產品定義被刪除
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to save product image in repository", zap.Error(err), zap.Int("product_id", productID))
+		return nil, utils.ErrInternalServer
+	}
+	if oldKey != "" && oldKey != key {
+		if err := s.fileStore.Delete(oldKey); err != nil {
+			utils.ContextLogger(ctx, s.logger).Warn("Service: Failed to delete replaced product image", zap.Error(err), zap.Int("product_id", productID), zap.String("key", oldKey))
+		}
+	}
+	return s.findProductDefinition(ctx, productID)
+}
+
+// GetProductImage 開啟產品圖片，沒有圖片 (或檔案已遺失) 時返回 404
+func (s *productDefinitionServiceImpl) GetProductImage(ctx context.Context, productID int) (*models.ProductImage, io.ReadCloser, error) {
+	definition, err := s.findProductDefinition(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if definition.ImageKey == "" || definition.ImageUpdatedAt == nil {
+		return nil, nil, utils.ErrNotFound.SetDetails(fmt.Sprintf("Product definition %d has no image", productID))
+	}
+
+	content, size, err := s.fileStore.Open(definition.ImageKey)
+	if err != nil {
+		if err == storage.ErrNotExist {
+			utils.ContextLogger(ctx, s.logger).Warn("Service: Product image file is missing from file store", zap.Int("product_id", productID), zap.String("key", definition.ImageKey))
+			return nil, nil, utils.ErrNotFound.SetDetails(fmt.Sprintf("Product definition %d has no image", productID))
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to open product image", zap.Error(err), zap.Int("product_id", productID), zap.String("key", definition.ImageKey))
+		return nil, nil, utils.ErrInternalServer
+	}
+	image := &models.ProductImage{
+		ProductID:   productID,
+		ContentType: definition.ImageContentType,
+		Size:        size,
+		ETag:        `"` + strings.TrimSuffix(path.Base(definition.ImageKey), path.Ext(definition.ImageKey)) + `"`, // key 即內容雜湊
+		UpdatedAt:   *definition.ImageUpdatedAt,
+	}
+	return image, content, nil
+}
+
+// DeleteProductImage 刪除產品圖片，沒有圖片時返回 404
+func (s *productDefinitionServiceImpl) DeleteProductImage(ctx context.Context, productID int, actorID int) error {
+	definition, err := s.findProductDefinition(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if definition.ImageKey == "" {
+		return utils.ErrNotFound.SetDetails(fmt.Sprintf("Product definition %d has no image", productID))
+	}
+
+	oldKey, err := s.productDefinitionRepo.ClearImage(ctx, productID, actorID)
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to clear product image in repository", zap.Error(err), zap.Int("product_id", productID))
+		return utils.ErrInternalServer
+	}
+	if oldKey != "" {
+		if err := s.fileStore.Delete(oldKey); err != nil {
+			utils.ContextLogger(ctx, s.logger).Warn("Service: Failed to delete product image file", zap.Error(err), zap.Int("product_id", productID), zap.String("key", oldKey))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// productDefinitionHistoryFields 更新時記錄變更歷史的欄位 (JSON 名稱)
+// 連同停售與重新啟用記錄的 discontinued_at，也是 GET /product_definitions/:id/history 的 field 參數可用值
+var productDefinitionHistoryFields = []string{"name", "sku", "description", "category_id", "parent_definition_id", "standard", "unit", "price"}
+
+// productDefinitionHistoryValues 返回 productDefinitionHistoryFields 各欄位的字串值，空值為 nil
+func productDefinitionHistoryValues(definition *models.ProductDefinition) map[string]*string {
+	str := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	id := func(v *int) *string {
+		if v == nil {
+			return nil
+		}
+		return str(strconv.Itoa(*v))
+	}
+	return map[string]*string{
+		"name":                 str(definition.Name),
+		"sku":                  str(definition.SKU),
+		"description":          str(definition.Description),
+		"category_id":          str(strconv.Itoa(definition.CategoryID)),
+		"parent_definition_id": id(definition.ParentID),
+		"standard":             str(definition.Standard),
+		"unit":                 str(definition.Unit),
+		"price":                str(definition.Price.String()),
+	}
+}
+
+// diffProductDefinition 比較更新前後的產品定義，每個變更的欄位產生一筆 updated 歷史
+func diffProductDefinition(before, after *models.ProductDefinition, actorID int) []models.ProductDefinitionHistory {
+	oldValues, newValues := productDefinitionHistoryValues(before), productDefinitionHistoryValues(after)
+	history := []models.ProductDefinitionHistory{}
+	for _, field := range productDefinitionHistoryFields {
+		oldValue, newValue := oldValues[field], newValues[field]
+		if field == "price" && before.Price.Equal(after.Price) {
+			continue // 小數位數不同 (例如 12.5 與 12.5000) 不算變更
+		}
+		if (oldValue == nil && newValue == nil) || (oldValue != nil && newValue != nil && *oldValue == *newValue) {
+			continue
+		}
+		history = append(history, models.ProductDefinitionHistory{
+			ProductID: after.ID,
+			Event:     models.ProductDefinitionEventUpdated,
+			Field:     field,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+			ActorID:   &actorID,
+		})
+	}
+	return history
+}
+
+// applyProductDefinitionHistoryValue 將歷史記錄中的欄位值寫回產品定義，value 的格式與 productDefinitionHistoryValues 相同
+func applyProductDefinitionHistoryValue(definition *models.ProductDefinition, field string, value *string) error {
+	text := ""
+	if value != nil {
+		text = *value
+	}
+	switch field {
+	case "name":
+		definition.Name = text
+	case "sku":
+		definition.SKU = text
+	case "description":
+		definition.Description = text
+	case "standard":
+		definition.Standard = text
+	case "unit":
+		definition.Unit = text
+	case "category_id":
+		categoryID, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("invalid category_id %q in history: %w", text, err)
+		}
+		definition.CategoryID = categoryID
+	case "parent_definition_id":
+		definition.ParentID = nil
+		if value != nil {
+			parentID, err := strconv.Atoi(text)
+			if err != nil {
+				return fmt.Errorf("invalid parent_definition_id %q in history: %w", text, err)
+			}
+			definition.ParentID = &parentID
+		}
+	case "price":
+		price := decimal.Zero
+		if value != nil {
+			var err error
+			if price, err = decimal.Parse(text); err != nil {
+				return fmt.Errorf("invalid price %q in history: %w", text, err)
+			}
+		}
+		definition.Price = price
+	case "discontinued_at":
+		definition.DiscontinuedAt = nil
+		if value != nil {
+			discontinuedAt, err := time.Parse(models.ProductDefinitionHistoryTimeLayout, text)
+			if err != nil {
+				return fmt.Errorf("invalid discontinued_at %q in history: %w", text, err)
+			}
+			definition.DiscontinuedAt = &discontinuedAt
+		}
+	default:
+		return fmt.Errorf("unknown product definition history field %q", field)
+	}
+	return nil
+}
+
+// GetProductDefinitionHistory 分頁獲取產品定義的變更歷史，依時間由新到舊；field 不為空時只返回該欄位的變更
+func (s *productDefinitionServiceImpl) GetProductDefinitionHistory(ctx context.Context, id int, field string, pagination utils.Pagination) (*models.PaginatedResponse, error) {
+	if field != "" && field != "discontinued_at" {
+		known := false
+		for _, f := range productDefinitionHistoryFields {
+			if f == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid field: %s", field))
+		}
+	}
+	if _, err := s.findProductDefinition(ctx, id); err != nil {
+		return nil, err
+	}
+
+	history, total, err := s.historyRepo.FindByProductID(ctx, id, field, pagination)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product definition history", zap.Error(err), zap.Int("product_id", id))
+		return nil, utils.ErrInternalServer
+	}
+	return &models.PaginatedResponse{Data: history, Total: &total, Page: pagination.Page, PageSize: pagination.PageSize}, nil
+}
+
+// GetProductDefinitionVersion 依變更歷史重建產品定義在 at 當時的狀態
+// 從目前的記錄開始，將 at 之後的每筆變更由新到舊還原為 OldValue；at 早於建立時間時返回 404
+// 只有透過新增、更新、停售與重新啟用所做的變更有歷史記錄 (匯入與圖片不在其中)
+func (s *productDefinitionServiceImpl) GetProductDefinitionVersion(ctx context.Context, id int, at time.Time) (*models.ProductDefinition, error) {
+	definition, err := s.productDefinitionRepo.FindByID(ctx, id)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product definition by ID", zap.Int("id", id), zap.Error(err))
+		return nil, utils.ErrInternalServer
+	}
+	if definition == nil {
+		return nil, nil // Repository 返回 nil, nil 表示未找到
+	}
+	if at.Before(definition.CreatedAt) {
+		return nil, utils.ErrNotFound.SetDetails(fmt.Sprintf("Product definition %d did not exist at %s", id, at.Format(time.RFC3339)))
+	}
+
+	history, err := s.historyRepo.FindAllByProductID(ctx, id)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product definition history for version", zap.Error(err), zap.Int("product_id", id))
+		return nil, utils.ErrInternalServer
+	}
+
+	currentCategoryID := definition.CategoryID
+	lastChange := definition.CreatedAt
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if !entry.CreatedAt.After(at) {
+			lastChange = entry.CreatedAt // 由新到舊，第一筆不晚於 at 的記錄即為當時最後一次變更
+			break
+		}
+		if entry.Field == "" {
+			continue
+		}
+		if err := applyProductDefinitionHistoryValue(definition, entry.Field, entry.OldValue); err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Failed to apply product definition history", zap.Error(err), zap.Int("product_id", id), zap.Int("history_id", entry.ID))
+			return nil, utils.ErrInternalServer
+		}
+	}
+
+	if definition.UpdatedAt.After(at) {
+		definition.UpdatedAt = lastChange
+	}
+	definition.Discontinued = definition.DiscontinuedAt != nil
+	definition.VariantCount = nil
+	if definition.CategoryID != currentCategoryID {
+		category, err := s.productDefinitionRepo.FindCategoryByID(ctx, definition.CategoryID)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get product category for version", zap.Error(err), zap.Int("category_id", definition.CategoryID))
+			return nil, utils.ErrInternalServer
+		}
+		definition.CategoryName = ""
+		if category != nil {
+			definition.CategoryName = category.Name // 類別名稱為目前的名稱
+		}
+	}
+	return definition, nil
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+func TestProductDefinitionServiceDeleteProductCategory(t *testing.T) {
+	categories := map[int]*models.ProductCategory{
+		2: {ID: 2, Name: "Bolts"},
+		3: {ID: 3, Name: "Nuts"},
+	}
+	reassignTo := func(id int) *int { return &id }
+
+	tests := []struct {
+		name        string
+		repo        fakeProductDefinitionRepository
+		id          int
+		reassignTo  *int
+		wantErr     *utils.CustomError // nil 表示成功
+		wantDetails map[string]interface{}
+	}{
+		{name: "empty category is deleted", repo: fakeProductDefinitionRepository{categories: categories}, id: 2},
+		{name: "reassigned before delete", repo: fakeProductDefinitionRepository{categories: categories, counts: map[int]int{2: 4}}, id: 2, reassignTo: reassignTo(3)},
+		{
+			name:    "category with product definitions",
+			repo:    fakeProductDefinitionRepository{categories: categories, counts: map[int]int{2: 4}},
+			id:      2,
+			wantErr: utils.NewConflictError("Product category still has product definitions", nil),
+			wantDetails: map[string]interface{}{
+				"category_id":              2,
+				"product_definition_count": 4,
+				"hint":                     "Pass reassign_to=<categoryID> to move them to another category before deleting",
+			},
+		},
+		{name: "reassign to itself", repo: fakeProductDefinitionRepository{categories: categories}, id: 2, reassignTo: reassignTo(2), wantErr: utils.ErrBadRequest},
+		{name: "reassign to missing category", repo: fakeProductDefinitionRepository{categories: categories}, id: 2, reassignTo: reassignTo(9), wantErr: utils.ErrBadRequest},
+		{name: "count fails", repo: fakeProductDefinitionRepository{categories: categories, err: errDatabase}, id: 2, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			readCache := &recordingCache{}
+			s := NewProductDefinitionService(&repo, nil, nil, nil, nil, nil, "TWD", 2, decimal.RoundHalfUp, readCache, zap.NewNop())
+
+			err := s.DeleteProductCategory(context.Background(), tt.id, models.CategoryDeleteBlock, tt.reassignTo)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("DeleteProductCategory: %v", err)
+				}
+				if !reflect.DeepEqual(repo.deleted, []int{tt.id}) {
+					t.Errorf("deleted = %v, want [%d]", repo.deleted, tt.id)
+				}
+				if !reflect.DeepEqual(readCache.invalidated, []string{cache.FamilyProductCategories}) {
+					t.Errorf("invalidated = %v", readCache.invalidated)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteProductCategory error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantDetails != nil {
+				if details := err.(*utils.CustomError).Details; !reflect.DeepEqual(details, tt.wantDetails) {
+					t.Errorf("details = %v, want %v", details, tt.wantDetails)
+				}
+			}
+			if len(repo.deleted) != 0 || len(readCache.invalidated) != 0 {
+				t.Errorf("failed delete deleted %v and invalidated %v", repo.deleted, readCache.invalidated)
+			}
+		})
+	}
+}
+
+// newTestProductDefinitionService 以假 Repository 建立 ProductDefinitionService，未用到的 Repository 為 nil
+func newTestProductDefinitionService(repo *fakeProductDefinitionRepository, prices *fakeProductPriceRepository, units *fakeProductUnitRepository, history *fakeProductDefinitionHistoryRepository) ProductDefinitionService {
+	return NewProductDefinitionService(repo, prices, units, history, nil, nil, "TWD", 2, decimal.RoundHalfUp, &recordingCache{}, zap.NewNop())
+}
+
+func TestProductDefinitionServiceGetProductPriceForQuantity(t *testing.T) {
+	definitions := map[int]*models.ProductDefinition{1: {ID: 1, Name: "M6 bolt", Price: decimal.MustParse("0.4500")}}
+	tiers := []models.ProductPriceTier{
+		{ProductID: 1, MinQty: 100, Price: decimal.MustParse("0.4000")},
+		{ProductID: 1, MinQty: 1000, Price: decimal.MustParse("0.3333")},
+	}
+
+	tests := []struct {
+		name          string
+		productID     int
+		qty           int
+		err           error
+		wantErr       *utils.CustomError // nil 表示成功
+		wantUnitPrice string
+		wantTotal     string
+		wantTier      int // 適用分級的 min_qty，0 表示使用基準價格
+	}{
+		{name: "below first tier uses base price", productID: 1, qty: 10, wantUnitPrice: "0.4500", wantTotal: "4.50"},
+		{name: "first tier boundary", productID: 1, qty: 100, wantUnitPrice: "0.4000", wantTotal: "40.00", wantTier: 100},
+		{name: "total rounds the unrounded unit price", productID: 1, qty: 1001, wantUnitPrice: "0.3333", wantTotal: "333.63", wantTier: 1000},
+		{name: "missing product", productID: 9, qty: 10, wantErr: utils.ErrNotFound},
+		{name: "tier lookup fails", productID: 1, qty: 10, err: errDatabase, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestProductDefinitionService(&fakeProductDefinitionRepository{definitions: definitions}, &fakeProductPriceRepository{tiers: tiers, err: tt.err}, nil, nil)
+
+			got, err := s.GetProductPriceForQuantity(context.Background(), tt.productID, tt.qty)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetProductPriceForQuantity error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetProductPriceForQuantity: %v", err)
+			}
+			if got.UnitPrice.String() != tt.wantUnitPrice || got.Total.String() != tt.wantTotal || got.Currency != "TWD" {
+				t.Errorf("price = %s × %d = %s %s, want %s and %s TWD", got.UnitPrice, tt.qty, got.Total, got.Currency, tt.wantUnitPrice, tt.wantTotal)
+			}
+			switch {
+			case tt.wantTier == 0 && got.Tier != nil:
+				t.Errorf("Tier = %+v, want none", got.Tier)
+			case tt.wantTier != 0 && (got.Tier == nil || got.Tier.MinQty != tt.wantTier):
+				t.Errorf("Tier = %+v, want min_qty %d", got.Tier, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestProductDefinitionServiceReplaceProductPriceTiers(t *testing.T) {
+	definitions := map[int]*models.ProductDefinition{
+		1: {ID: 1, Name: "M6 bolt"},
+		2: {ID: 2, Name: "M8 bolt", Discontinued: true},
+	}
+	tier := func(productID, minQty int, price string) models.ProductPriceTier {
+		return models.ProductPriceTier{ProductID: productID, MinQty: minQty, Price: decimal.MustParse(price)}
+	}
+
+	tests := []struct {
+		name         string
+		productID    int
+		tiers        []models.ProductPriceTier
+		wantErr      *utils.CustomError // nil 表示成功
+		wantMinQty   []int              // 寫入的分級 (依 min_qty 排序)
+		wantWarnings int
+	}{
+		{name: "sorted by min_qty", productID: 1, tiers: []models.ProductPriceTier{tier(0, 100, "0.40"), tier(1, 10, "0.45")}, wantMinQty: []int{10, 100}},
+		{name: "higher price for larger quantity warns", productID: 1, tiers: []models.ProductPriceTier{tier(0, 10, "0.40"), tier(0, 100, "0.45")}, wantMinQty: []int{10, 100}, wantWarnings: 1},
+		{name: "discontinued product can clear tiers", productID: 2, tiers: []models.ProductPriceTier{}, wantMinQty: []int{}},
+		{name: "discontinued product cannot add tiers", productID: 2, tiers: []models.ProductPriceTier{tier(0, 10, "0.40")}, wantErr: utils.NewConflictError("Product definition 2 is discontinued and cannot get new price tiers", nil)},
+		{name: "duplicate min_qty", productID: 1, tiers: []models.ProductPriceTier{tier(0, 10, "0.40"), tier(0, 10, "0.30")}, wantErr: utils.ErrBadRequest},
+		{name: "tier of another product", productID: 1, tiers: []models.ProductPriceTier{tier(2, 10, "0.40")}, wantErr: utils.ErrBadRequest},
+		{name: "missing product", productID: 9, tiers: []models.ProductPriceTier{}, wantErr: utils.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := fakeProductPriceRepository{}
+			s := newTestProductDefinitionService(&fakeProductDefinitionRepository{definitions: definitions}, &prices, nil, nil)
+
+			resp, err := s.ReplaceProductPriceTiers(context.Background(), tt.productID, tt.tiers)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ReplaceProductPriceTiers error = %v, want %v", err, tt.wantErr)
+				}
+				if prices.replaced != nil {
+					t.Errorf("failed replace wrote %v", prices.replaced)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReplaceProductPriceTiers: %v", err)
+			}
+			minQty := []int{}
+			for _, written := range prices.replaced {
+				minQty = append(minQty, written.MinQty)
+			}
+			if !reflect.DeepEqual(minQty, tt.wantMinQty) {
+				t.Errorf("written min_qty = %v, want %v", minQty, tt.wantMinQty)
+			}
+			if len(resp.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %v, want %d", resp.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestProductDefinitionServiceReplaceProductUnits(t *testing.T) {
+	definitions := map[int]*models.ProductDefinition{
+		1: {ID: 1, Name: "M6 bolt", Unit: "PCS"},
+		2: {ID: 2, Name: "Washer"},
+	}
+
+	tests := []struct {
+		name      string
+		productID int
+		units     []models.ProductUnit
+		err       error
+		wantErr   *utils.CustomError // nil 表示成功
+		wantUnits []string
+	}{
+		{name: "names are normalized", productID: 1, units: []models.ProductUnit{{Unit: " Box ", Factor: 100}, {Unit: "CARTON", Factor: 1000}}, wantUnits: []string{"box", "carton"}},
+		{name: "clear units", productID: 1, units: []models.ProductUnit{}, wantUnits: []string{}},
+		{name: "canonical unit", productID: 1, units: []models.ProductUnit{{Unit: "pcs", Factor: 1}}, wantErr: utils.ErrBadRequest},
+		{name: "duplicate unit ignoring case", productID: 1, units: []models.ProductUnit{{Unit: "box", Factor: 100}, {Unit: "BOX", Factor: 50}}, wantErr: utils.ErrBadRequest},
+		{name: "non-positive factor", productID: 1, units: []models.ProductUnit{{Unit: "box", Factor: 0}}, wantErr: utils.ErrBadRequest},
+		{name: "empty name", productID: 1, units: []models.ProductUnit{{Unit: " ", Factor: 10}}, wantErr: utils.ErrBadRequest},
+		{name: "product without canonical unit", productID: 2, units: []models.ProductUnit{}, wantErr: utils.ErrBadRequest},
+		{name: "replace fails", productID: 1, units: []models.ProductUnit{{Unit: "box", Factor: 100}}, err: errDatabase, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			units := fakeProductUnitRepository{err: tt.err}
+			s := newTestProductDefinitionService(&fakeProductDefinitionRepository{definitions: definitions}, nil, &units, nil)
+
+			got, err := s.ReplaceProductUnits(context.Background(), tt.productID, tt.units)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ReplaceProductUnits error = %v, want %v", err, tt.wantErr)
+				}
+				if units.replaced != nil {
+					t.Errorf("failed replace wrote %v", units.replaced)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReplaceProductUnits: %v", err)
+			}
+			names := []string{}
+			for _, unit := range units.replaced {
+				names = append(names, unit.Unit)
+			}
+			if !reflect.DeepEqual(names, tt.wantUnits) || !reflect.DeepEqual(got, units.replaced) {
+				t.Errorf("written units = %v (returned %v), want %v", names, got, tt.wantUnits)
+			}
+		})
+	}
+}
+
+func TestProductDefinitionServiceGetProductDefinitionHistory(t *testing.T) {
+	definitions := map[int]*models.ProductDefinition{1: {ID: 1, Name: "M6 bolt"}}
+	history := []models.ProductDefinitionHistory{
+		{ID: 3, ProductID: 1, Event: "updated", Field: "price"},
+		{ID: 2, ProductID: 1, Event: "discontinued", Field: "discontinued_at"},
+		{ID: 1, ProductID: 1, Event: "created"},
+	}
+
+	tests := []struct {
+		name      string
+		productID int
+		field     string
+		err       error
+		wantErr   *utils.CustomError // nil 表示成功
+		wantTotal int
+	}{
+		{name: "all events", productID: 1, wantTotal: 3},
+		{name: "single field", productID: 1, field: "price", wantTotal: 1},
+		{name: "discontinued_at is a known field", productID: 1, field: "discontinued_at", wantTotal: 1},
+		{name: "unknown field", productID: 1, field: "cost", wantErr: utils.ErrBadRequest},
+		{name: "missing product", productID: 9, wantErr: utils.ErrNotFound},
+		{name: "history lookup fails", productID: 1, err: errDatabase, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestProductDefinitionService(&fakeProductDefinitionRepository{definitions: definitions}, nil, nil, &fakeProductDefinitionHistoryRepository{history: history, err: tt.err})
+
+			resp, err := s.GetProductDefinitionHistory(context.Background(), tt.productID, tt.field, utils.Pagination{Page: 1, PageSize: 20})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetProductDefinitionHistory error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetProductDefinitionHistory: %v", err)
+			}
+			if resp.Total == nil || *resp.Total != tt.wantTotal {
+				t.Errorf("Total = %v, want %d", resp.Total, tt.wantTotal)
+			}
+		})
+	}
+}
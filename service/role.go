@@ -1,11 +1,14 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"net/http" // 用於檢查錯誤類型
 
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/events"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/repository"
 	"github.com/wac0705/fastener-api/utils"
@@ -13,74 +16,107 @@ import (
 
 // RoleService 定義角色服務介面
 type RoleService interface {
-	GetAllRoles() ([]models.Role, error)
-	GetRoleByID(id int) (*models.Role, error)
-	CreateRole(role *models.Role) error
-	UpdateRole(role *models.Role) error
-	DeleteRole(id int) error
+	GetAllRoles(ctx context.Context) ([]models.Role, error)
+	GetRoleByID(ctx context.Context, id int) (*models.Role, error)
+	ResolvePublicID(ctx context.Context, publicID string) (int, error)    // 將對外的 UUID 識別碼 (public_id) 轉換為 ID，不存在時返回 utils.ErrNotFound
+	CreateRole(ctx context.Context, role *models.Role, actorID int) error // actorID 為執行變更的帳戶，記錄在 created_by/updated_by
+	UpdateRole(ctx context.Context, role *models.Role, actorID int) error
+	DeleteRole(ctx context.Context, id int) error
+	CloneRole(ctx context.Context, sourceID int, name string, actorID int) (*models.Role, error) // 以新名稱複製角色的權限與選單
 }
 
 // roleServiceImpl 實現 RoleService 介面
 type roleServiceImpl struct {
-	roleRepo repository.RoleRepository
+	roleRepo       repository.RoleRepository
+	permissionRepo repository.PermissionRepository // 複製角色時複製其權限
+	roleMenuRepo   repository.RoleMenuRepository   // 複製角色時複製其選單
+	txManager      db.TxManager
+	publisher      events.Publisher // 角色的權限改變 (複製、刪除) 後發布 permissions_changed
+	readCache      cache.Cache      // 緩存讀取結果 (cache.FamilyRoles)，寫入成功後失效
+	logger         *zap.Logger
 }
 
 // NewRoleService 創建 RoleService 實例
-func NewRoleService(repo repository.RoleRepository) RoleService {
-	return &roleServiceImpl{roleRepo: repo}
+func NewRoleService(repo repository.RoleRepository, permissionRepo repository.PermissionRepository, roleMenuRepo repository.RoleMenuRepository, txManager db.TxManager, publisher events.Publisher, readCache cache.Cache, logger *zap.Logger) RoleService {
+	return &roleServiceImpl{roleRepo: repo, permissionRepo: permissionRepo, roleMenuRepo: roleMenuRepo, txManager: txManager, publisher: publisher, readCache: readCache, logger: logger}
 }
 
 // CreateRole 創建新角色
-func (s *roleServiceImpl) CreateRole(role *models.Role) error {
+func (s *roleServiceImpl) CreateRole(ctx context.Context, role *models.Role, actorID int) error {
 	// 檢查角色名稱是否已存在
-	existingRole, err := s.roleRepo.FindByName(role.Name)
+	existingRole, err := s.roleRepo.FindByName(ctx, role.Name)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing role by name during creation", zap.Error(err), zap.String("name", role.Name))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing role by name during creation", zap.Error(err), zap.String("name", role.Name))
 		return utils.ErrInternalServer
 	}
 	if existingRole != nil {
-		return utils.ErrBadRequest.SetDetails("Role with this name already exists.")
+		return utils.ErrConflict.SetDetails("Role with this name already exists.")
 	}
 
-	if err := s.roleRepo.Create(role); err != nil {
-		if customErr, ok := err.(*utils.CustomError); ok && customErr.Code == http.StatusBadRequest {
-			return customErr // 假設 Repository 返回的錯誤已包含詳細信息
+	if err := s.roleRepo.Create(ctx, role, actorID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如唯一約束衝突 (409)
 		}
-		zap.L().Error("Service: Failed to create role in repository", zap.Error(err), zap.String("name", role.Name))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create role in repository", zap.Error(err), zap.String("name", role.Name))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create role: %v", err))
 	}
+	s.readCache.Invalidate(ctx, cache.FamilyRoles)
 	return nil
 }
 
 // GetAllRoles 獲取所有角色
-func (s *roleServiceImpl) GetAllRoles() ([]models.Role, error) {
-	roles, err := s.roleRepo.FindAll()
+func (s *roleServiceImpl) GetAllRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	if s.readCache.Get(ctx, cache.FamilyRoles, "all", &roles) {
+		return roles, nil
+	}
+	roles, err := s.roleRepo.FindAll(ctx)
 	if err != nil {
-		zap.L().Error("Service: Failed to get all roles", zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all roles", zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
+	s.readCache.Set(ctx, cache.FamilyRoles, "all", roles)
 	return roles, nil
 }
 
 // GetRoleByID 根據 ID 獲取角色
-func (s *roleServiceImpl) GetRoleByID(id int) (*models.Role, error) {
-	role, err := s.roleRepo.FindByID(id)
+func (s *roleServiceImpl) GetRoleByID(ctx context.Context, id int) (*models.Role, error) {
+	key := fmt.Sprintf("id:%d", id)
+	var cached models.Role
+	if s.readCache.Get(ctx, cache.FamilyRoles, key, &cached) {
+		return &cached, nil
+	}
+	role, err := s.roleRepo.FindByID(ctx, id)
 	if err != nil {
-		zap.L().Error("Service: Failed to get role by ID", zap.Int("id", id), zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get role by ID", zap.Int("id", id), zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
 	if role == nil {
-		return nil, nil // Repository 返回 nil, nil 表示未找到
+		return nil, nil // Repository 返回 nil, nil 表示未找到 (不緩存)
 	}
+	s.readCache.Set(ctx, cache.FamilyRoles, key, role)
 	return role, nil
 }
 
+// ResolvePublicID 根據對外的 UUID 識別碼返回角色 ID
+func (s *roleServiceImpl) ResolvePublicID(ctx context.Context, publicID string) (int, error) {
+	role, err := s.roleRepo.FindByPublicID(ctx, publicID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get role by public ID", zap.String("public_id", publicID), zap.Error(err))
+		return 0, utils.ErrInternalServer
+	}
+	if role == nil {
+		return 0, utils.ErrNotFound
+	}
+	return role.ID, nil
+}
+
 // UpdateRole 更新角色信息
-func (s *roleServiceImpl) UpdateRole(role *models.Role) error {
+func (s *roleServiceImpl) UpdateRole(ctx context.Context, role *models.Role, actorID int) error {
 	// 檢查角色是否存在
-	existingRole, err := s.roleRepo.FindByID(role.ID)
+	existingRole, err := s.roleRepo.FindByID(ctx, role.ID)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing role for update", zap.Error(err), zap.Int("role_id", role.ID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing role for update", zap.Error(err), zap.Int("role_id", role.ID))
 		return utils.ErrInternalServer
 	}
 	if existingRole == nil {
@@ -89,32 +125,76 @@ func (s *roleServiceImpl) UpdateRole(role *models.Role) error {
 
 	// 檢查新名稱是否被其他角色占用 (如果名稱有更改)
 	if existingRole.Name != role.Name {
-		otherRole, err := s.roleRepo.FindByName(role.Name)
+		otherRole, err := s.roleRepo.FindByName(ctx, role.Name)
 		if err != nil {
-			zap.L().Error("Service: Error checking role name for update conflict", zap.Error(err), zap.String("new_name", role.Name))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking role name for update conflict", zap.Error(err), zap.String("new_name", role.Name))
 			return utils.ErrInternalServer
 		}
 		if otherRole != nil && otherRole.ID != role.ID {
-			return utils.ErrBadRequest.SetDetails("Role name already exists for another role")
+			return utils.ErrConflict.SetDetails("Role name already exists for another role")
 		}
 	}
 
-	if err := s.roleRepo.Update(role); err != nil {
-		if customErr, ok := err.(*utils.CustomError); ok && customErr.Code == http.StatusBadRequest {
+	if err := s.roleRepo.Update(ctx, role, actorID); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
 			return customErr
 		}
-		zap.L().Error("Service: Failed to update role in repository", zap.Error(err), zap.Int("role_id", role.ID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update role in repository", zap.Error(err), zap.Int("role_id", role.ID))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update role: %v", err))
 	}
+	s.readCache.Invalidate(ctx, cache.FamilyRoles)
 	return nil
 }
 
+// CloneRole 以 name 建立新角色，並複製來源角色的權限與選單
+// 角色、權限與選單在同一事務中寫入，任一步驟失敗時不會留下只有部分權限的角色；新角色的建立者為 actorID
+func (s *roleServiceImpl) CloneRole(ctx context.Context, sourceID int, name string, actorID int) (*models.Role, error) {
+	source, err := s.roleRepo.FindByID(ctx, sourceID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking source role for clone", zap.Error(err), zap.Int("role_id", sourceID))
+		return nil, utils.ErrInternalServer
+	}
+	if source == nil {
+		return nil, utils.ErrNotFound.SetDetails(fmt.Sprintf("Role %d not found", sourceID))
+	}
+
+	existingRole, err := s.roleRepo.FindByName(ctx, name)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing role by name during clone", zap.Error(err), zap.String("name", name))
+		return nil, utils.ErrInternalServer
+	}
+	if existingRole != nil {
+		return nil, utils.ErrConflict.SetDetails("Role with this name already exists.")
+	}
+
+	role := &models.Role{Name: name}
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.roleRepo.Create(ctx, role, actorID); err != nil {
+			return err
+		}
+		if err := s.permissionRepo.CopyRolePermissions(ctx, sourceID, role.ID); err != nil {
+			return err
+		}
+		return s.roleMenuRepo.CopyRoleMenus(ctx, sourceID, role.ID)
+	})
+	if err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return nil, customErr // 與其他請求同時建立相同名稱的角色
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to clone role", zap.Error(err), zap.Int("source_role_id", sourceID), zap.String("name", name))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to clone role: %v", err))
+	}
+	s.readCache.Invalidate(ctx, cache.FamilyRoles, cache.FamilyMenus, cache.FamilyPermissions) // 新角色已複製來源角色的選單與權限
+	s.publisher.Publish(ctx, events.Event{Type: events.PermissionsChanged, RoleID: role.ID})
+	return role, nil
+}
+
 // DeleteRole 刪除角色
-func (s *roleServiceImpl) DeleteRole(id int) error {
+func (s *roleServiceImpl) DeleteRole(ctx context.Context, id int) error {
 	// 檢查角色是否存在
-	existingRole, err := s.roleRepo.FindByID(id)
+	existingRole, err := s.roleRepo.FindByID(ctx, id)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing role for delete", zap.Error(err), zap.Int("role_id", id))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing role for delete", zap.Error(err), zap.Int("role_id", id))
 		return utils.ErrInternalServer
 	}
 	if existingRole == nil {
@@ -126,9 +206,14 @@ func (s *roleServiceImpl) DeleteRole(id int) error {
 	// 例如：userCount, _ := s.accountRepo.CountByRoleID(id)
 	// if userCount > 0 { return utils.ErrBadRequest.SetDetails("Cannot delete role with associated accounts") }
 
-	if err := s.roleRepo.Delete(id); err != nil {
-		zap.L().Error("Service: Failed to delete role in repository", zap.Error(err), zap.Int("role_id", id))
+	if err := s.roleRepo.Delete(ctx, id); err != nil {
+		if customErr, ok := err.(*utils.CustomError); ok {
+			return customErr // 例如未找到，或仍被其他記錄引用 (409)
+		}
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete role in repository", zap.Error(err), zap.Int("role_id", id))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete role: %v", err))
 	}
+	s.readCache.Invalidate(ctx, cache.FamilyRoles, cache.FamilyMenus, cache.FamilyPermissions) // 角色的選單與權限關聯一併刪除
+	s.publisher.Publish(ctx, events.Event{Type: events.PermissionsChanged, RoleID: id})
 	return nil
 }
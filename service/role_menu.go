@@ -1,11 +1,15 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"net/http" // 用於錯誤檢查
 
 	"go.uber.org/zap"
 
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/db"
+	"github.com/wac0705/fastener-api/events"
 	"github.com/wac0705/fastener-api/models"
 	"github.com/wac0705/fastener-api/repository"
 	"github.com/wac0705/fastener-api/utils"
@@ -13,10 +17,11 @@ import (
 
 // RoleMenuService 定義角色選單服務介面
 type RoleMenuService interface {
-	CreateRoleMenu(roleMenu *models.RoleMenu) error
-	GetAllRoleMenus(roleID, menuID *int) ([]models.RoleMenuDetail, error)
-	DeleteRoleMenu(roleID, menuID int) error
-	UpdateRoleMenu(oldRoleID, oldMenuID, newRoleID, newMenuID int) error
+	CreateRoleMenu(ctx context.Context, roleMenu *models.RoleMenu) error
+	GetAllRoleMenus(ctx context.Context, roleID, menuID *int) ([]models.RoleMenuDetail, error)
+	DeleteRoleMenu(ctx context.Context, roleID, menuID int) error
+	UpdateRoleMenu(ctx context.Context, oldRoleID, oldMenuID, newRoleID, newMenuID int) error
+	ReplaceRoleMenus(ctx context.Context, roleID int, menuIDs []int) ([]models.Menu, error) // 整組取代角色的選單，返回取代後的選單
 }
 
 // roleMenuServiceImpl 實現 RoleMenuService 介面
@@ -24,28 +29,46 @@ type roleMenuServiceImpl struct {
 	roleMenuRepo repository.RoleMenuRepository
 	roleRepo     repository.RoleRepository // 依賴 RoleRepository 檢查角色是否存在
 	menuRepo     repository.MenuRepository // 依賴 MenuRepository 檢查選單是否存在
+	txManager    db.TxManager
+	publisher    events.Publisher // 角色的選單改變後通知事件串流的訂閱者 (menus_changed)
+	readCache    cache.Cache      // 角色的選單改變後使 MenuService 的緩存 (cache.FamilyMenus) 失效
+	logger       *zap.Logger
 }
 
 // NewRoleMenuService 創建 RoleMenuService 實例
-func NewRoleMenuService(roleMenuRepo repository.RoleMenuRepository, roleRepo repository.RoleRepository, menuRepo repository.MenuRepository) RoleMenuService {
-	return &roleMenuServiceImpl{roleMenuRepo: roleMenuRepo, roleRepo: roleRepo, menuRepo: menuRepo}
+func NewRoleMenuService(roleMenuRepo repository.RoleMenuRepository, roleRepo repository.RoleRepository, menuRepo repository.MenuRepository, txManager db.TxManager, publisher events.Publisher, readCache cache.Cache, logger *zap.Logger) RoleMenuService {
+	return &roleMenuServiceImpl{roleMenuRepo: roleMenuRepo, roleRepo: roleRepo, menuRepo: menuRepo, txManager: txManager, publisher: publisher, readCache: readCache, logger: logger}
+}
+
+// menusChanged 使選單緩存失效，並通知訂閱者 roleIDs 的選單已改變 (重複的角色只通知一次)
+// 緩存需在通知前失效，收到事件的用戶端重新載入時才會讀到新的選單
+func (s *roleMenuServiceImpl) menusChanged(ctx context.Context, roleIDs ...int) {
+	s.readCache.Invalidate(ctx, cache.FamilyMenus)
+	published := map[int]bool{}
+	for _, roleID := range roleIDs {
+		if published[roleID] {
+			continue
+		}
+		published[roleID] = true
+		s.publisher.Publish(ctx, events.Event{Type: events.MenusChanged, RoleID: roleID})
+	}
 }
 
 // CreateRoleMenu 創建新的角色選單關聯
-func (s *roleMenuServiceImpl) CreateRoleMenu(roleMenu *models.RoleMenu) error {
+func (s *roleMenuServiceImpl) CreateRoleMenu(ctx context.Context, roleMenu *models.RoleMenu) error {
 	// 業務驗證：檢查 roleID 和 menuID 是否真實存在
-	role, err := s.roleRepo.FindByID(roleMenu.RoleID)
+	role, err := s.roleRepo.FindByID(ctx, roleMenu.RoleID)
 	if err != nil {
-		zap.L().Error("Service: Error checking role for role menu creation", zap.Error(err), zap.Int("role_id", roleMenu.RoleID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking role for role menu creation", zap.Error(err), zap.Int("role_id", roleMenu.RoleID))
 		return utils.ErrInternalServer
 	}
 	if role == nil {
 		return utils.ErrBadRequest.SetDetails("Invalid Role ID")
 	}
 
-	menu, err := s.menuRepo.FindByID(roleMenu.MenuID)
+	menu, err := s.menuRepo.FindByID(ctx, roleMenu.MenuID)
 	if err != nil {
-		zap.L().Error("Service: Error checking menu for role menu creation", zap.Error(err), zap.Int("menu_id", roleMenu.MenuID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking menu for role menu creation", zap.Error(err), zap.Int("menu_id", roleMenu.MenuID))
 		return utils.ErrInternalServer
 	}
 	if menu == nil {
@@ -53,70 +76,71 @@ func (s *roleMenuServiceImpl) CreateRoleMenu(roleMenu *models.RoleMenu) error {
 	}
 
 	// 檢查是否已存在相同的關聯 (Repository 的 ON CONFLICT DO NOTHING 會處理，但這裡可以提前返回錯誤)
-	existingRelations, err := s.roleMenuRepo.FindAll(&roleMenu.RoleID, &roleMenu.MenuID)
+	existingRelations, err := s.roleMenuRepo.FindAll(ctx, &roleMenu.RoleID, &roleMenu.MenuID)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing role menu relationship", zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing role menu relationship", zap.Error(err))
 		return utils.ErrInternalServer
 	}
 	if len(existingRelations) > 0 {
-		return utils.ErrBadRequest.SetDetails("Role-menu relationship already exists.")
+		return utils.ErrConflict.SetDetails("Role-menu relationship already exists.")
 	}
 
-
-	if err := s.roleMenuRepo.Create(roleMenu); err != nil {
-		zap.L().Error("Service: Failed to create role menu in repository", zap.Error(err), zap.Int("role_id", roleMenu.RoleID), zap.Int("menu_id", roleMenu.MenuID))
+	if err := s.roleMenuRepo.Create(ctx, roleMenu); err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to create role menu in repository", zap.Error(err), zap.Int("role_id", roleMenu.RoleID), zap.Int("menu_id", roleMenu.MenuID))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to create role menu: %v", err))
 	}
+	s.menusChanged(ctx, roleMenu.RoleID)
 	return nil
 }
 
 // GetAllRoleMenus 獲取所有角色選單關聯
-func (s *roleMenuServiceImpl) GetAllRoleMenus(roleID, menuID *int) ([]models.RoleMenuDetail, error) {
-	roleMenus, err := s.roleMenuRepo.FindAll(roleID, menuID)
+func (s *roleMenuServiceImpl) GetAllRoleMenus(ctx context.Context, roleID, menuID *int) ([]models.RoleMenuDetail, error) {
+	roleMenus, err := s.roleMenuRepo.FindAll(ctx, roleID, menuID)
 	if err != nil {
-		zap.L().Error("Service: Failed to get all role menus", zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get all role menus", zap.Error(err))
 		return nil, utils.ErrInternalServer
 	}
 	return roleMenus, nil
 }
 
 // DeleteRoleMenu 刪除角色選單關聯
-func (s *roleMenuServiceImpl) DeleteRoleMenu(roleID, menuID int) error {
+func (s *roleMenuServiceImpl) DeleteRoleMenu(ctx context.Context, roleID, menuID int) error {
 	// 業務驗證：檢查關聯是否存在
-	existingRelations, err := s.roleMenuRepo.FindAll(&roleID, &menuID)
+	existingRelations, err := s.roleMenuRepo.FindAll(ctx, &roleID, &menuID)
 	if err != nil {
-		zap.L().Error("Service: Error checking existing role menu relationship for delete", zap.Error(err))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing role menu relationship for delete", zap.Error(err))
 		return utils.ErrInternalServer
 	}
 	if len(existingRelations) == 0 {
 		return utils.ErrNotFound.SetDetails(fmt.Sprintf("Role-menu relationship (role_id: %d, menu_id: %d) not found.", roleID, menuID))
 	}
 
-	if err := s.roleMenuRepo.Delete(roleID, menuID); err != nil {
+	if err := s.roleMenuRepo.Delete(ctx, roleID, menuID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok && customErr.Code == http.StatusNotFound {
 			return customErr // 如果 Repository 返回的是未找到錯誤
 		}
-		zap.L().Error("Service: Failed to delete role menu in repository", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to delete role menu in repository", zap.Error(err), zap.Int("role_id", roleID), zap.Int("menu_id", menuID))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to delete role menu: %v", err))
 	}
+	s.menusChanged(ctx, roleID)
 	return nil
 }
 
 // UpdateRoleMenu 更新角色選單關聯
-func (s *roleMenuServiceImpl) UpdateRoleMenu(oldRoleID, oldMenuID, newRoleID, newMenuID int) error {
+func (s *roleMenuServiceImpl) UpdateRoleMenu(ctx context.Context, oldRoleID, oldMenuID, newRoleID, newMenuID int) error {
 	// 業務驗證：檢查新的 roleID 和 menuID 是否存在
-	role, err := s.roleRepo.FindByID(newRoleID)
+	role, err := s.roleRepo.FindByID(ctx, newRoleID)
 	if err != nil {
-		zap.L().Error("Service: Error checking new role for role menu update", zap.Error(err), zap.Int("role_id", newRoleID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking new role for role menu update", zap.Error(err), zap.Int("role_id", newRoleID))
 		return utils.ErrInternalServer
 	}
 	if role == nil {
 		return utils.ErrBadRequest.SetDetails("Invalid New Role ID")
 	}
 
-	menu, err := s.menuRepo.FindByID(newMenuID)
+	menu, err := s.menuRepo.FindByID(ctx, newMenuID)
 	if err != nil {
-		zap.L().Error("Service: Error checking new menu for role menu update", zap.Error(err), zap.Int("menu_id", newMenuID))
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking new menu for role menu update", zap.Error(err), zap.Int("menu_id", newMenuID))
 		return utils.ErrInternalServer
 	}
 	if menu == nil {
@@ -125,23 +149,79 @@ func (s *roleMenuServiceImpl) UpdateRoleMenu(oldRoleID, oldMenuID, newRoleID, ne
 
 	// 檢查新關聯是否已存在 (如果新舊ID相同，且新關聯已存在，則視為成功)
 	if oldRoleID != newRoleID || oldMenuID != newMenuID {
-		existingNewRelations, err := s.roleMenuRepo.FindAll(&newRoleID, &newMenuID)
+		existingNewRelations, err := s.roleMenuRepo.FindAll(ctx, &newRoleID, &newMenuID)
 		if err != nil {
-			zap.L().Error("Service: Error checking existing new role menu relationship for update", zap.Error(err))
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking existing new role menu relationship for update", zap.Error(err))
 			return utils.ErrInternalServer
 		}
 		if len(existingNewRelations) > 0 {
-			return utils.ErrBadRequest.SetDetails("New role-menu relationship already exists.")
+			return utils.ErrConflict.SetDetails("New role-menu relationship already exists.")
 		}
 	}
 
-	if err := s.roleMenuRepo.Update(oldRoleID, oldMenuID, newRoleID, newMenuID); err != nil {
+	if err := s.roleMenuRepo.Update(ctx, oldRoleID, oldMenuID, newRoleID, newMenuID); err != nil {
 		if customErr, ok := err.(*utils.CustomError); ok && customErr.Code == http.StatusNotFound {
 			return customErr // 如果 Repository 返回的是未找到錯誤
 		}
-		zap.L().Error("Service: Failed to update role menu in repository", zap.Error(err),
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to update role menu in repository", zap.Error(err),
 			zap.Int("old_role_id", oldRoleID), zap.Int("old_menu_id", oldMenuID))
 		return utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to update role menu: %v", err))
 	}
+	s.menusChanged(ctx, oldRoleID, newRoleID) // 關聯移到其他角色時兩個角色都受影響
 	return nil
 }
+
+// ReplaceRoleMenus 以 menuIDs 整組取代角色的選單 (重複的 ID 只計一次，空陣列表示移除所有選單)
+// 刪除舊關聯與建立新關聯在同一事務中執行，任一步驟失敗時保留原本的選單
+func (s *roleMenuServiceImpl) ReplaceRoleMenus(ctx context.Context, roleID int, menuIDs []int) ([]models.Menu, error) {
+	role, err := s.roleRepo.FindByID(ctx, roleID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Error checking role for role menu replace", zap.Error(err), zap.Int("role_id", roleID))
+		return nil, utils.ErrInternalServer
+	}
+	if role == nil {
+		return nil, utils.ErrNotFound.SetDetails(fmt.Sprintf("Role %d not found", roleID))
+	}
+
+	seen := map[int]bool{}
+	uniqueIDs := []int{}
+	for _, menuID := range menuIDs {
+		if seen[menuID] {
+			continue
+		}
+		seen[menuID] = true
+		menu, err := s.menuRepo.FindByID(ctx, menuID)
+		if err != nil {
+			utils.ContextLogger(ctx, s.logger).Error("Service: Error checking menu for role menu replace", zap.Error(err), zap.Int("menu_id", menuID))
+			return nil, utils.ErrInternalServer
+		}
+		if menu == nil {
+			return nil, utils.ErrBadRequest.SetDetails(fmt.Sprintf("Invalid Menu ID %d", menuID))
+		}
+		uniqueIDs = append(uniqueIDs, menuID)
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.roleMenuRepo.DeleteByRoleID(ctx, roleID); err != nil {
+			return err
+		}
+		roleMenus := make([]models.RoleMenu, len(uniqueIDs))
+		for i, menuID := range uniqueIDs {
+			roleMenus[i] = models.RoleMenu{RoleID: roleID, MenuID: menuID}
+		}
+		_, err := s.roleMenuRepo.CreateBatch(ctx, roleMenus) // 多列 INSERT，不需要每個選單一次往返
+		return err
+	})
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to replace role menus", zap.Error(err), zap.Int("role_id", roleID))
+		return nil, utils.ErrInternalServer.SetDetails(fmt.Sprintf("Failed to replace role menus: %v", err))
+	}
+	s.menusChanged(ctx, roleID)
+
+	menus, err := s.roleMenuRepo.FindMenusByRoleID(ctx, roleID)
+	if err != nil {
+		utils.ContextLogger(ctx, s.logger).Error("Service: Failed to get menus after role menu replace", zap.Error(err), zap.Int("role_id", roleID))
+		return nil, utils.ErrInternalServer
+	}
+	return menus, nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/events"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+func TestRoleMenuServiceUpdateRoleMenu(t *testing.T) {
+	roles := map[int]*models.Role{1: {ID: 1, Name: "admin"}, 2: {ID: 2, Name: "finance"}}
+	menus := map[int]*models.Menu{10: {ID: 10, Name: "Customers"}, 20: {ID: 20, Name: "Reports"}}
+	existing := []models.RoleMenuDetail{{RoleID: 1, MenuID: 10}, {RoleID: 2, MenuID: 10}}
+
+	tests := []struct {
+		name                               string
+		roleMenus                          fakeRoleMenuRepository
+		roles                              fakeRoleRepository
+		menus                              fakeMenuRepository
+		oldRole, oldMenu, newRole, newMenu int
+		wantErr                            *utils.CustomError // nil 表示成功
+		wantDetails                        interface{}
+		wantPublished                      []int // 收到 menus_changed 的角色
+	}{
+		{
+			name:      "move relation to another role and menu",
+			roleMenus: fakeRoleMenuRepository{existing: existing}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 2, newMenu: 20,
+			wantPublished: []int{1, 2},
+		},
+		{
+			name:      "same role publishes once",
+			roleMenus: fakeRoleMenuRepository{existing: existing}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 1, newMenu: 20,
+			wantPublished: []int{1},
+		},
+		{
+			name:      "unchanged relation skips the conflict check",
+			roleMenus: fakeRoleMenuRepository{existing: existing, findErr: errDatabase}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 1, newMenu: 10,
+			wantPublished: []int{1},
+		},
+		{
+			name:      "new relation already exists",
+			roleMenus: fakeRoleMenuRepository{existing: existing}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 2, newMenu: 10,
+			wantErr: utils.ErrConflict, wantDetails: "New role-menu relationship already exists.",
+		},
+		{
+			name:      "invalid new role",
+			roleMenus: fakeRoleMenuRepository{existing: existing}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 9, newMenu: 20,
+			wantErr: utils.ErrBadRequest, wantDetails: "Invalid New Role ID",
+		},
+		{
+			name:      "invalid new menu",
+			roleMenus: fakeRoleMenuRepository{existing: existing}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 2, newMenu: 99,
+			wantErr: utils.ErrBadRequest, wantDetails: "Invalid New Menu ID",
+		},
+		{
+			name:      "role lookup fails",
+			roleMenus: fakeRoleMenuRepository{existing: existing}, roles: fakeRoleRepository{roles: roles, err: errDatabase}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 2, newMenu: 20,
+			wantErr: utils.ErrInternalServer,
+		},
+		{
+			name:      "menu lookup fails",
+			roleMenus: fakeRoleMenuRepository{existing: existing}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus, err: errDatabase},
+			oldRole: 1, oldMenu: 10, newRole: 2, newMenu: 20,
+			wantErr: utils.ErrInternalServer,
+		},
+		{
+			name:      "conflict check fails",
+			roleMenus: fakeRoleMenuRepository{existing: existing, findErr: errDatabase}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 2, newMenu: 20,
+			wantErr: utils.ErrInternalServer,
+		},
+		{
+			name:      "old relation not found",
+			roleMenus: fakeRoleMenuRepository{existing: existing, updateErr: utils.ErrNotFound}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 2, oldMenu: 20, newRole: 1, newMenu: 20,
+			wantErr: utils.ErrNotFound,
+		},
+		{
+			name:      "update fails",
+			roleMenus: fakeRoleMenuRepository{existing: existing, updateErr: errDatabase}, roles: fakeRoleRepository{roles: roles}, menus: fakeMenuRepository{menus: menus},
+			oldRole: 1, oldMenu: 10, newRole: 2, newMenu: 20,
+			wantErr: utils.ErrInternalServer, wantDetails: "Failed to update role menu: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roleMenus, roles, menus := tt.roleMenus, tt.roles, tt.menus
+			publisher, readCache := &recordingPublisher{}, &recordingCache{}
+			s := NewRoleMenuService(&roleMenus, &roles, &menus, nil, publisher, readCache, zap.NewNop())
+
+			err := s.UpdateRoleMenu(context.Background(), tt.oldRole, tt.oldMenu, tt.newRole, tt.newMenu)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("UpdateRoleMenu: %v", err)
+				}
+				if roleMenus.updates != 1 {
+					t.Errorf("repository updates = %d, want 1", roleMenus.updates)
+				}
+				var published []int
+				for _, event := range publisher.events {
+					if event.Type != events.MenusChanged {
+						t.Errorf("published %q, want %q", event.Type, events.MenusChanged)
+					}
+					published = append(published, event.RoleID)
+				}
+				if !reflect.DeepEqual(published, tt.wantPublished) {
+					t.Errorf("published roles = %v, want %v", published, tt.wantPublished)
+				}
+				if !reflect.DeepEqual(readCache.invalidated, []string{cache.FamilyMenus}) {
+					t.Errorf("invalidated = %v, want [%s]", readCache.invalidated, cache.FamilyMenus)
+				}
+				return
+			}
+			var customErr *utils.CustomError
+			if !errors.As(err, &customErr) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateRoleMenu error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantDetails != nil && customErr.Details != tt.wantDetails {
+				t.Errorf("details = %v, want %v", customErr.Details, tt.wantDetails)
+			}
+			if roleMenus.updates != 0 || len(publisher.events) != 0 || len(readCache.invalidated) != 0 {
+				t.Errorf("failed update changed state: updates %d, events %v, invalidated %v", roleMenus.updates, publisher.events, readCache.invalidated)
+			}
+		})
+	}
+}
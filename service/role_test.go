@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/cache"
+	"github.com/wac0705/fastener-api/events"
+	"github.com/wac0705/fastener-api/models"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+func TestRoleServiceDeleteRole(t *testing.T) {
+	roles := map[int]*models.Role{2: {ID: 2, Name: "finance"}}
+
+	tests := []struct {
+		name    string
+		repo    fakeRoleRepository
+		id      int
+		wantErr *utils.CustomError // nil 表示成功
+	}{
+		{name: "deleted", repo: fakeRoleRepository{roles: roles}, id: 2},
+		{name: "not found", repo: fakeRoleRepository{roles: roles}, id: 9, wantErr: utils.ErrNotFound},
+		{name: "still referenced", repo: fakeRoleRepository{roles: roles, deleteErr: utils.ErrConflict.SetDetails("Role is still in use")}, id: 2, wantErr: utils.ErrConflict},
+		{name: "delete fails", repo: fakeRoleRepository{roles: roles, deleteErr: errDatabase}, id: 2, wantErr: utils.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			publisher, readCache := &recordingPublisher{}, &recordingCache{}
+			s := NewRoleService(&repo, nil, nil, nil, publisher, readCache, zap.NewNop())
+
+			err := s.DeleteRole(context.Background(), tt.id)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("DeleteRole: %v", err)
+				}
+				want := []events.Event{{Type: events.PermissionsChanged, RoleID: tt.id}}
+				if !reflect.DeepEqual(publisher.events, want) {
+					t.Errorf("published = %v, want %v", publisher.events, want)
+				}
+				if !reflect.DeepEqual(readCache.invalidated, []string{cache.FamilyRoles, cache.FamilyMenus, cache.FamilyPermissions}) {
+					t.Errorf("invalidated = %v", readCache.invalidated)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteRole error = %v, want %v", err, tt.wantErr)
+			}
+			if len(publisher.events) != 0 || len(readCache.invalidated) != 0 {
+				t.Errorf("failed delete published %v and invalidated %v", publisher.events, readCache.invalidated)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotExist 要讀取的檔案不存在
+var ErrNotExist = errors.New("file does not exist")
+
+// FileStore 定義上傳檔案 (例如產品圖片) 的儲存介面，key 為以 "/" 分隔的相對路徑
+type FileStore interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Open(key string) (io.ReadCloser, int64, error) // 返回內容與大小，不存在時返回 ErrNotExist
+	Delete(key string) error                       // 不存在時不視為錯誤
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localFileStore 將檔案儲存在本機磁碟的 baseDir 之下
+type localFileStore struct {
+	baseDir string
+}
+
+// NewLocalFileStore 創建以本機目錄儲存檔案的 FileStore 實例
+func NewLocalFileStore(baseDir string) FileStore {
+	return &localFileStore{baseDir: baseDir}
+}
+
+// path 將 key 轉為 baseDir 下的檔案路徑，拒絕絕對路徑與 ".." 以免寫到 baseDir 之外
+func (s *localFileStore) path(key string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file key %q", key)
+	}
+	return filepath.Join(s.baseDir, cleaned), nil
+}
+
+// Put 先寫入同目錄的暫存檔再改名，避免讀取到寫入一半的檔案
+func (s *localFileStore) Put(key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name()) // 改名成功後暫存檔已不存在
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+// Open 開啟檔案並返回大小
+func (s *localFileStore) Open(key string) (io.ReadCloser, int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, ErrNotExist
+		}
+		return nil, 0, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	return f, info.Size(), nil
+}
+
+// Delete 刪除檔案
+func (s *localFileStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
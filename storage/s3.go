@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3UnsignedPayload 不對內容計算雜湊，上傳時可以直接串流 (需搭配 HTTPS)
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config S3 相容物件儲存 (AWS S3、MinIO、Cloudflare R2…) 的連線設定
+type S3Config struct {
+	Endpoint        string // 例如 https://s3.ap-northeast-1.amazonaws.com 或 http://minio:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// s3FileStore 以 path-style URL (<endpoint>/<bucket>/<key>) 與 AWS Signature V4 存取 S3 相容的物件儲存
+type s3FileStore struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+// NewS3FileStore 創建以 S3 相容物件儲存保存檔案的 FileStore 實例
+func NewS3FileStore(cfg S3Config) FileStore {
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &s3FileStore{cfg: cfg, client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+// Put 上傳物件
+func (s *s3FileStore) Put(key string, r io.Reader, size int64, contentType string) error {
+	req, err := s.newRequest(http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Open 下載物件，返回的內容需由呼叫端關閉
+func (s *s3FileStore) Open(key string) (io.ReadCloser, int64, error) {
+	req, err := s.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Delete 刪除物件 (S3 刪除不存在的物件也會返回成功)
+func (s *s3FileStore) Delete(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		if err == ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// newRequest 建立指向物件的請求
+func (s *s3FileStore) newRequest(method, key string, body io.Reader) (*http.Request, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return nil, fmt.Errorf("invalid file key %q", key)
+	}
+	req, err := http.NewRequest(method, s.cfg.Endpoint+"/"+s3EscapePath(s.cfg.Bucket+"/"+key), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request for %s: %w", key, err)
+	}
+	return req, nil
+}
+
+// do 簽署並送出請求；404 轉為 ErrNotExist，其他非 2xx 狀態轉為錯誤
+func (s *s3FileStore) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s %s failed: %w", req.Method, req.URL.Path, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) // S3 以 XML 返回錯誤代碼與訊息
+	return nil, fmt.Errorf("S3 %s %s returned %d: %s", req.Method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(detail)))
+}
+
+// sign 以 AWS Signature Version 4 簽署請求，簽署 host、range 與所有 x-amz-* 標頭
+// 未設定 X-Amz-Content-Sha256 時不對內容計算雜湊 (UNSIGNED-PAYLOAD)
+func (s *s3FileStore) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = s3UnsignedPayload
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name = strings.ToLower(name); name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // 沒有查詢參數
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	signature := hex.EncodeToString(hmacSHA256(sigV4SigningKey(s.cfg.SecretAccessKey, date, s.cfg.Region, "s3"), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// sigV4SigningKey 由 secret 依日期 (YYYYMMDD)、區域與服務逐層衍生簽署金鑰
+func sigV4SigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// hmacSHA256 計算 HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath 依 SigV4 規則編碼物件路徑：除了非保留字元 (A-Z a-z 0-9 - _ . ~) 與 "/" 以外一律以 %XX 編碼
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/wac0705/fastener-api/metrics" // 指標註冊介面
+)
+
+// 批次匯出的設定
+const (
+	exportQueueSize   = 2048             // 等待匯出的 span 上限，已滿時丟棄新的 span
+	exportBatchSize   = 512              // 每次匯出最多的 span 數
+	exportInterval    = 5 * time.Second  // 未滿一批時最長的等待時間
+	exportTimeout     = 10 * time.Second // 每次匯出的逾時時間
+	instrumentationID = "github.com/wac0705/fastener-api"
+)
+
+// Config OTLP 匯出設定，對應 OpenTelemetry 的標準環境變數
+type Config struct {
+	Endpoint       string            // OTEL_EXPORTER_OTLP_ENDPOINT，例如 http://otel-collector:4318；span 送到 <endpoint>/v1/traces
+	Headers        map[string]string // OTEL_EXPORTER_OTLP_HEADERS，例如驗證用的 API key
+	ServiceName    string            // OTEL_SERVICE_NAME
+	ServiceVersion string            // 建置版本
+}
+
+// tracerProvider 啟用追蹤時的 OpenTelemetry TracerProvider 與本服務的 Tracer
+type tracerProvider struct {
+	sdk    *sdktrace.TracerProvider
+	tracer trace.Tracer
+}
+
+// provider 目前使用的 TracerProvider，未呼叫 Init 或未設定端點時為 nil (不追蹤)
+// 不設定 otel.SetTracerProvider 的全局 provider，同一程序中的其他程式庫不會因此開始追蹤
+var provider atomic.Pointer[tracerProvider]
+
+// ParseHeaders 解析 OTEL_EXPORTER_OTLP_HEADERS (以逗號分隔的 key=value，值可為 URL 編碼)
+// 標頭值通常是 API key，錯誤訊息只包含標頭的位置或名稱
+func ParseHeaders(raw string) (map[string]string, error) {
+	headers := map[string]string{}
+	for i, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid header #%d: expected key=value", i+1)
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %q: value is not valid URL encoding", key)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
+
+// Init 以 OpenTelemetry SDK 與 OTLP/HTTP 匯出器 (otlptracehttp) 啟用追蹤，cfg.Endpoint 為空白時不啟用 (Start 返回 nil span，沒有額外開銷)
+// 並在 reg 註冊匯出結果的指標；程序結束前需呼叫 Shutdown 送出尚未匯出的 span
+func Init(cfg Config, reg metrics.Registry) error {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: expected a URL such as http://otel-collector:4318", cfg.Endpoint)
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+		otlptracehttp.WithHeaders(cfg.Headers),
+		otlptracehttp.WithTimeout(exportTimeout),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	counted := &countingExporter{SpanExporter: exporter}
+	reg.CounterFunc("trace_spans_exported_total", "Total spans exported to the OTLP endpoint.", func() float64 {
+		return float64(counted.exported.Load())
+	})
+	reg.CounterFunc("trace_spans_dropped_total", "Total spans dropped because the export failed.", func() float64 {
+		return float64(counted.dropped.Load())
+	})
+	install(cfg, sdktrace.WithBatcher(counted,
+		sdktrace.WithMaxQueueSize(exportQueueSize),
+		sdktrace.WithMaxExportBatchSize(exportBatchSize),
+		sdktrace.WithBatchTimeout(exportInterval),
+		sdktrace.WithExportTimeout(exportTimeout),
+	))
+	return nil
+}
+
+// install 以 span 處理方式 (批次匯出或測試用的同步匯出) 建立 TracerProvider 並啟用追蹤
+func install(cfg Config, processor sdktrace.TracerProviderOption) {
+	res := resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+		attribute.String("service.version", cfg.ServiceVersion),
+	)
+	sdk := sdktrace.NewTracerProvider(processor, sdktrace.WithResource(res))
+	provider.Store(&tracerProvider{sdk: sdk, tracer: sdk.Tracer(instrumentationID)})
+}
+
+// Shutdown 停用追蹤並送出佇列中尚未匯出的 span，未啟用追蹤時不做任何事
+func Shutdown(ctx context.Context) error {
+	p := provider.Swap(nil)
+	if p == nil {
+		return nil
+	}
+	return p.sdk.Shutdown(ctx)
+}
+
+// countingExporter 記錄匯出成功與失敗的 span 數量 (trace_spans_exported_total、trace_spans_dropped_total)
+// 失敗的匯出已由 otlptracehttp 重試過，之後 span 即被丟棄
+type countingExporter struct {
+	sdktrace.SpanExporter
+	exported atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// ExportSpans 匯出一批 span 並計數
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if err := e.SpanExporter.ExportSpans(ctx, spans); err != nil {
+		e.dropped.Add(uint64(len(spans)))
+		zap.L().Warn("Failed to export trace spans, dropping them", zap.Error(err), zap.Int("count", len(spans)))
+		return err
+	}
+	e.exported.Add(uint64(len(spans)))
+	return nil
+}
@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// maxStatementLength db.statement 屬性的長度上限 (位元組)，避免批次 INSERT 等長查詢讓 span 過大
+const maxStatementLength = 2048
+
+// WrapConnector 包裝資料庫驅動的 Connector，為每個查詢、Exec 與事務建立 client span (db.query、db.exec、db.begin)
+// 只在 ctx 中已有 span 時 (例如請求的 server span) 建立，背景工作與未啟用追蹤時直接呼叫原本的驅動
+// 只記錄 SQL 語句，不記錄參數
+func WrapConnector(connector driver.Connector) driver.Connector {
+	return &tracedConnector{connector: connector}
+}
+
+// tracedConnector 建立 tracedConn 的 Connector
+type tracedConnector struct {
+	connector driver.Connector
+}
+
+// Connect 建立連接並包裝為 tracedConn
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+// Driver 返回原本的驅動
+func (c *tracedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// startDBSpan 在 ctx 已有 span 時開始資料庫 client span，否則返回 nil
+func startDBSpan(ctx context.Context, name, query string) *Span {
+	if FromContext(ctx) == nil {
+		return nil
+	}
+	if len(query) > maxStatementLength {
+		query = query[:maxStatementLength]
+	}
+	_, span := StartSpan(ctx, name, KindClient, String("db.system", "postgresql"), String("db.statement", query))
+	return span
+}
+
+// endDBSpan 記錄錯誤並結束 span；ErrSkip 表示 database/sql 會改用其他方式執行，不是失敗
+func endDBSpan(span *Span, err error) {
+	if err != nil && !errors.Is(err, driver.ErrSkip) {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// tracedConn 包裝資料庫連接，轉發 database/sql 會使用的選用介面
+// pgx 的 stdlib 連接實現了這裡所有的介面
+type tracedConn struct {
+	driver.Conn
+}
+
+// ExecContext 執行不返回資料列的語句
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := startDBSpan(ctx, "db.exec", query)
+	result, err := execer.ExecContext(ctx, query, args)
+	endDBSpan(span, err)
+	return result, err
+}
+
+// QueryContext 執行查詢；span 在查詢返回時結束，不包含讀取資料列的時間
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := startDBSpan(ctx, "db.query", query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endDBSpan(span, err)
+	return rows, err
+}
+
+// PrepareContext 準備語句
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx 開始事務
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.Conn.(driver.ConnBeginTx)
+	if !ok {
+		return nil, errors.New("driver does not support BeginTx")
+	}
+	span := startDBSpan(ctx, "db.begin", "BEGIN")
+	tx, err := beginner.BeginTx(ctx, opts)
+	endDBSpan(span, err)
+	return tx, err
+}
+
+// Ping 測試連接
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession 連接回到連接池前重置狀態
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid 連接是否可以繼續使用
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue 讓驅動自行轉換參數 (pgx 以此支援陣列等型別)，未實現時使用 database/sql 的預設轉換
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
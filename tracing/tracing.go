@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanKind span 的類型，對應 OpenTelemetry 的 SpanKind
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1 // service 等內部操作
+	KindServer   SpanKind = 2 // 處理 HTTP 請求
+	KindClient   SpanKind = 3 // 對外部服務 (資料庫) 的呼叫
+)
+
+// otelKind 對應的 OpenTelemetry SpanKind
+func (k SpanKind) otelKind() trace.SpanKind {
+	switch k {
+	case KindServer:
+		return trace.SpanKindServer
+	case KindClient:
+		return trace.SpanKindClient
+	default:
+		return trace.SpanKindInternal
+	}
+}
+
+// Attribute span 的屬性，Value 為 string、int、int64、float64 或 bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String 字串屬性
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int 整數屬性
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool 布林屬性
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// keyValue 轉為 OpenTelemetry 的屬性，其他類型以字串表示
+func (a Attribute) keyValue() attribute.KeyValue {
+	switch v := a.Value.(type) {
+	case string:
+		return attribute.String(a.Key, v)
+	case int:
+		return attribute.Int(a.Key, v)
+	case int64:
+		return attribute.Int64(a.Key, v)
+	case float64:
+		return attribute.Float64(a.Key, v)
+	case bool:
+		return attribute.Bool(a.Key, v)
+	default:
+		return attribute.String(a.Key, fmt.Sprint(v))
+	}
+}
+
+// keyValues 轉換屬性列表
+func keyValues(attrs []Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		kvs[i] = attr.keyValue()
+	}
+	return kvs
+}
+
+// Span 一段計時的操作，包裝 OpenTelemetry 的 span；未啟用追蹤時 Start 返回 nil，所有方法在 nil 上呼叫都不做任何事
+type Span struct {
+	span trace.Span
+}
+
+// spanContextKey 在 context 中保存目前 span 的 key
+// OpenTelemetry 的 context 也可能只帶有上游服務的 span (ContextWithRemoteParent)，FromContext 只返回本服務建立的 span
+type spanContextKey struct{}
+
+// propagator 解析 W3C Trace Context 的 traceparent 標頭
+var propagator = propagation.TraceContext{}
+
+// Enabled 是否已啟用追蹤 (已設定 OTEL_EXPORTER_OTLP_ENDPOINT)
+func Enabled() bool {
+	return provider.Load() != nil
+}
+
+// Start 以 ctx 中的 span 為父 span 開始一個內部 span，返回的 ctx 帶有新的 span，呼叫端需在操作結束時呼叫 End
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartSpan(ctx, name, KindInternal, attrs...)
+}
+
+// StartSpan 開始指定類型的 span；ctx 中沒有 span 時沿用上游的 traceparent (見 ContextWithRemoteParent)，都沒有時開始新的 trace
+func StartSpan(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	p := provider.Load()
+	if p == nil {
+		return ctx, nil
+	}
+	ctx, otelSpan := p.tracer.Start(ctx, name, trace.WithSpanKind(kind.otelKind()), trace.WithAttributes(keyValues(attrs)...))
+	span := &Span{span: otelSpan}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// FromContext 返回 ctx 中目前的 span，沒有時返回 nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// TraceID 返回 ctx 中目前 span 的 trace ID (32 個十六進位字元)，沒有 span 時返回空字串
+func TraceID(ctx context.Context) string {
+	return FromContext(ctx).TraceID()
+}
+
+// ContextWithRemoteParent 解析 W3C traceparent 標頭 (00-<trace_id>-<span_id>-<flags>)，之後的 StartSpan 會延續上游的 trace
+// 標頭格式錯誤或為空白時返回原本的 ctx
+func ContextWithRemoteParent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.HeaderCarrier(http.Header{"Traceparent": {traceparent}}))
+}
+
+// TraceID 返回 span 的 trace ID
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.span.SpanContext().TraceID().String()
+}
+
+// SetAttributes 新增或覆蓋 span 的屬性
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(keyValues(attrs)...)
+}
+
+// RecordError 將 span 標記為失敗並記錄錯誤訊息，err 為 nil 時不做任何事
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End 結束 span 並交給匯出器，重複呼叫時只有第一次有效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// installTestExporter 以同步匯出到記憶體的 TracerProvider 啟用追蹤，測試結束時停用
+func installTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	install(Config{ServiceName: "fastener-api", ServiceVersion: "1.2.3"}, sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = Shutdown(context.Background()) })
+	return exporter
+}
+
+func attributeValue(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestDisabledIsNoop(t *testing.T) {
+	if Enabled() {
+		t.Fatal("tracing enabled without Init")
+	}
+	ctx, span := Start(context.Background(), "op", String("k", "v"))
+	if span != nil || FromContext(ctx) != nil || TraceID(ctx) != "" {
+		t.Fatalf("span = %v, trace ID = %q", span, TraceID(ctx))
+	}
+	// nil span 的方法不做任何事
+	span.SetAttributes(Int("n", 1))
+	span.RecordError(errors.New("boom"))
+	span.End()
+
+	if err := Init(Config{}, nil); err != nil || Enabled() {
+		t.Fatalf("Init(empty endpoint) = %v, enabled %v", err, Enabled())
+	}
+}
+
+func TestInitRejectsInvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"otel-collector:4318", "ftp://otel-collector:4318", "http://"} {
+		if err := Init(Config{Endpoint: endpoint}, nil); err == nil {
+			t.Errorf("Init(%q) returned no error", endpoint)
+		}
+	}
+	if Enabled() {
+		t.Error("tracing enabled after invalid endpoints")
+	}
+}
+
+// TestSpanParentAndAttributes 子 span 屬於同一個 trace，父 span 為 ctx 中的 span，屬性、類型與服務資訊都會匯出
+func TestSpanParentAndAttributes(t *testing.T) {
+	exporter := installTestExporter(t)
+
+	ctx, parent := StartSpan(context.Background(), "GET /api/v1/customers", KindServer, String("http.method", "GET"))
+	childCtx, child := Start(ctx, "CustomerService.List", Int("page", 2), Bool("cached", false))
+	if FromContext(childCtx) != child || TraceID(childCtx) != parent.TraceID() || len(parent.TraceID()) != 32 {
+		t.Fatalf("child trace ID %q, parent trace ID %q", child.TraceID(), parent.TraceID())
+	}
+	child.End()
+	parent.SetAttributes(Int("http.status_code", 200))
+	parent.End()
+	parent.End() // 重複呼叫只匯出一次
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	gotChild, gotParent := spans[0], spans[1]
+	if gotChild.Parent.SpanID() != gotParent.SpanContext.SpanID() || gotParent.Parent.IsValid() {
+		t.Errorf("child parent = %s, parent span = %s", gotChild.Parent.SpanID(), gotParent.SpanContext.SpanID())
+	}
+	if gotParent.SpanKind != trace.SpanKindServer || gotChild.SpanKind != trace.SpanKindInternal {
+		t.Errorf("kinds = %v, %v", gotParent.SpanKind, gotChild.SpanKind)
+	}
+	if v, _ := attributeValue(gotParent.Attributes, "http.status_code"); v.AsInt64() != 200 {
+		t.Errorf("http.status_code = %v", v.Emit())
+	}
+	if v, _ := attributeValue(gotChild.Attributes, "page"); v.AsInt64() != 2 {
+		t.Errorf("page = %v", v.Emit())
+	}
+	if v, ok := attributeValue(gotChild.Attributes, "cached"); !ok || v.AsBool() {
+		t.Errorf("cached = %v", v.Emit())
+	}
+	if v, _ := attributeValue(gotParent.Resource.Attributes(), "service.name"); v.AsString() != "fastener-api" {
+		t.Errorf("service.name = %v", v.Emit())
+	}
+}
+
+// TestRemoteParent 延續上游 traceparent 的 trace，格式錯誤時開始新的 trace
+func TestRemoteParent(t *testing.T) {
+	exporter := installTestExporter(t)
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+
+	ctx := ContextWithRemoteParent(context.Background(), "00-"+traceID+"-"+spanID+"-01")
+	if FromContext(ctx) != nil {
+		t.Fatal("remote parent exposed as a local span")
+	}
+	_, span := StartSpan(ctx, "GET /healthz", KindServer)
+	span.End()
+	if span.TraceID() != traceID {
+		t.Errorf("trace ID = %q, want %q", span.TraceID(), traceID)
+	}
+	if got := exporter.GetSpans()[0].Parent; !got.IsRemote() || got.SpanID().String() != spanID {
+		t.Errorf("parent = %+v", got)
+	}
+
+	for _, header := range []string{"", "garbage", "00-" + traceID + "-" + spanID} {
+		_, span := StartSpan(ContextWithRemoteParent(context.Background(), header), "op", KindServer)
+		span.End()
+		if span.TraceID() == traceID || span.TraceID() == "" {
+			t.Errorf("traceparent %q: trace ID = %q", header, span.TraceID())
+		}
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	exporter := installTestExporter(t)
+
+	_, span := Start(context.Background(), "op")
+	span.RecordError(nil)
+	span.RecordError(errors.New("connection refused"))
+	span.End()
+
+	got := exporter.GetSpans()[0]
+	if got.Status.Code != codes.Error || got.Status.Description != "connection refused" {
+		t.Errorf("status = %+v", got.Status)
+	}
+	if len(got.Events) != 1 || got.Events[0].Name != "exception" {
+		t.Errorf("events = %+v", got.Events)
+	}
+}
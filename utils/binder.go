@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// strictJSONContextKey StrictJSONBinding 標記路由使用嚴格 JSON 綁定的 Echo Context key
+const strictJSONContextKey = "strict_json_binding"
+
+// jsonUnmarshalerType 自行解析 JSON 的型別 (例如 time.Time、decimal.Decimal、json.RawMessage)，不檢查其內部欄位
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// JSONBinder 與 Echo 預設的綁定器相同 (路徑參數、GET/DELETE/HEAD 的查詢參數、請求內容)，
+// 但嚴格模式下 JSON 請求內容含有結構中沒有的欄位時返回 400，details 列出所有未知欄位 (見 NewUnknownFieldsError)，
+// 避免 "pasword" 之類的拼字錯誤被靜默忽略
+type JSONBinder struct {
+	echo.DefaultBinder
+	strict bool
+}
+
+// NewJSONBinder 創建 JSONBinder 實例；strict 為 true 時所有路由都使用嚴格模式，否則只有套用 StrictJSONBinding 的路由分組
+func NewJSONBinder(strict bool) *JSONBinder {
+	return &JSONBinder{strict: strict}
+}
+
+// StrictJSONBinding 讓路由分組的 JSON 請求內容使用嚴格綁定 (需將 e.Binder 設為 JSONBinder)
+func StrictJSONBinding() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(strictJSONContextKey, true)
+			return next(c)
+		}
+	}
+}
+
+// Bind 實現 echo.Binder 介面，綁定順序與 echo.DefaultBinder 相同
+func (b *JSONBinder) Bind(i interface{}, c echo.Context) error {
+	if err := b.BindPathParams(c, i); err != nil {
+		return err
+	}
+	method := c.Request().Method
+	if method == http.MethodGet || method == http.MethodDelete || method == http.MethodHead {
+		if err := b.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+	req := c.Request()
+	strict, _ := c.Get(strictJSONContextKey).(bool)
+	if (!b.strict && !strict) || req.ContentLength == 0 || !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return b.BindBody(c, i)
+	}
+	return bindStrictJSON(req.Body, i)
+}
+
+// bindStrictJSON 以 DisallowUnknownFields 解析 JSON，有未知欄位時返回列出所有未知欄位的 400 錯誤
+func bindStrictJSON(body io.Reader, i interface{}) error {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(i)
+	if err == nil {
+		return nil
+	}
+	if !strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	var value interface{}
+	fields := []string{}
+	if json.Unmarshal(raw, &value) == nil {
+		fields = unknownFields(value, reflect.TypeOf(i), "")
+	}
+	if len(fields) == 0 { // 無法比對時至少列出解析器回報的欄位
+		fields = append(fields, strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`))
+	}
+	return NewUnknownFieldsError(fields)
+}
+
+// unknownFields 比對已解析的 JSON 值與目標型別，返回所有型別中沒有的物件欄位；
+// 巢狀欄位以 . 連接、陣列元素以 [index] 表示 (例如 addresses[0].ctiy)，與驗證錯誤的 field 格式相同
+func unknownFields(value interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		if t.Implements(jsonUnmarshalerType) {
+			return nil
+		}
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+
+	unknown := []string{}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(object) {
+			fieldType, ok := fields[strings.ToLower(key)] // encoding/json 比對欄位名稱時不區分大小寫
+			if !ok {
+				unknown = append(unknown, joinFieldPath(path, key))
+				continue
+			}
+			unknown = append(unknown, unknownFields(object[key], fieldType, joinFieldPath(path, key))...)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for index, item := range items {
+			unknown = append(unknown, unknownFields(item, t.Elem(), path+"["+strconv.Itoa(index)+"]")...)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(object) {
+			unknown = append(unknown, unknownFields(object[key], t.Elem(), joinFieldPath(path, key))...)
+		}
+	}
+	return unknown
+}
+
+// jsonFields 返回結構的 JSON 欄位名稱 (小寫) 對應的型別，包含嵌入結構中提升的欄位
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for key, fieldType := range jsonFields(embedded) {
+					if _, exists := fields[key]; !exists { // 外層的欄位優先
+						fields[key] = fieldType
+					}
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
+
+// joinFieldPath 以 . 連接巢狀欄位名稱
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sortedKeys 依字母順序返回物件的欄位名稱，讓錯誤中的欄位順序固定
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// BindError 返回 c.Bind 失敗時的 400 錯誤：綁定器返回的 CustomError (例如嚴格綁定發現的未知欄位) 原樣返回，其他錯誤返回 ErrBadRequest
+func BindError(err error) *CustomError {
+	var customErr *CustomError
+	if errors.As(err, &customErr) {
+		return customErr
+	}
+	return ErrBadRequest
+}
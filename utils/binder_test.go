@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 綁定測試用的巢狀結構：嵌入結構、巢狀物件、物件陣列、map 與自行解析 JSON 的型別
+type (
+	bindBase struct {
+		ID int `json:"id"`
+	}
+	bindAddress struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	}
+	bindLine struct {
+		SKU      string      `json:"sku"`
+		Quantity int         `json:"quantity"`
+		Ship     bindAddress `json:"ship"`
+	}
+	bindOrder struct {
+		bindBase
+		Customer struct {
+			Name    string       `json:"name"`
+			Address *bindAddress `json:"address"`
+		} `json:"customer"`
+		Lines     []bindLine             `json:"lines"`
+		Labels    map[string]bindAddress `json:"labels"`
+		Meta      json.RawMessage        `json:"meta"`
+		PlacedAt  time.Time              `json:"placed_at"`
+		Internal  string                 `json:"-"`
+		NoTagName string
+	}
+)
+
+// bindJSON 以 binder 綁定 JSON 請求內容，strictRoute 為 true 時模擬套用 StrictJSONBinding 的路由
+func bindJSON(t *testing.T, binder *JSONBinder, strictRoute bool, body string, out interface{}) error {
+	t.Helper()
+	e := echo.New()
+	e.Binder = binder
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, httptest.NewRecorder())
+	if !strictRoute {
+		return c.Bind(out)
+	}
+	return StrictJSONBinding()(func(c echo.Context) error { return c.Bind(out) })(c)
+}
+
+const validOrder = `{
+	"id": 9,
+	"customer": {"name": "Acme", "address": {"city": "Taipei", "country": "TW"}},
+	"lines": [
+		{"sku": "M6-20", "quantity": 100, "ship": {"city": "Tainan"}},
+		{"sku": "M8-30", "quantity": 50, "ship": {"city": "Kaohsiung", "country": "TW"}}
+	],
+	"labels": {"billing": {"city": "Hsinchu"}},
+	"meta": {"anything": {"goes": [1, 2]}},
+	"placed_at": "2024-03-10T12:00:00Z",
+	"NoTagName": "x"
+}`
+
+func TestJSONBinderBindsNestedObjectsAndArrays(t *testing.T) {
+	var order bindOrder
+	if err := bindJSON(t, NewJSONBinder(true), false, validOrder, &order); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if order.ID != 9 || order.Customer.Name != "Acme" || order.Customer.Address == nil || order.Customer.Address.City != "Taipei" {
+		t.Errorf("customer = %+v, id = %d", order.Customer, order.ID)
+	}
+	if len(order.Lines) != 2 || order.Lines[1].SKU != "M8-30" || order.Lines[1].Quantity != 50 || order.Lines[0].Ship.City != "Tainan" {
+		t.Errorf("lines = %+v", order.Lines)
+	}
+	if order.Labels["billing"].City != "Hsinchu" || !order.PlacedAt.Equal(time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)) || order.NoTagName != "x" {
+		t.Errorf("labels = %+v, placed_at = %v", order.Labels, order.PlacedAt)
+	}
+	if !strings.Contains(string(order.Meta), `"goes"`) {
+		t.Errorf("meta = %s", order.Meta)
+	}
+}
+
+func TestJSONBinderRejectsUnknownFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"top level", `{"id": 1, "extra": true}`, []string{"extra"}},
+		{"nested object", `{"customer": {"nmae": "Acme"}}`, []string{"customer.nmae"}},
+		{"nested pointer", `{"customer": {"name": "Acme", "address": {"ctiy": "Taipei"}}}`, []string{"customer.address.ctiy"}},
+		{"array element", `{"lines": [{"sku": "a"}, {"sku": "b", "qty": 1}]}`, []string{"lines[1].qty"}},
+		{"struct in array element", `{"lines": [{"ship": {"zip": "100"}}]}`, []string{"lines[0].ship.zip"}},
+		{"map value", `{"labels": {"billing": {"colour": "red"}}}`, []string{"labels.billing.colour"}},
+		{"ignored field", `{"Internal": "x"}`, []string{"Internal"}},
+		{"all unknown fields sorted", `{"zz": 1, "customer": {"b": 1, "a": 2}, "lines": [{"x": 1}, {"y": 2}]}`,
+			[]string{"customer.a", "customer.b", "lines[0].x", "lines[1].y", "zz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bindJSON(t, NewJSONBinder(true), false, tt.body, &bindOrder{})
+			var customErr *CustomError
+			if !errors.As(err, &customErr) || customErr.Code != http.StatusBadRequest || customErr.ErrorCode != ErrorCodeUnknownFields {
+				t.Fatalf("Bind error = %v", err)
+			}
+			details, ok := customErr.Details.(UnknownFieldsDetails)
+			if !ok || !reflect.DeepEqual(details.Fields, tt.want) {
+				t.Errorf("unknown fields = %+v, want %v", customErr.Details, tt.want)
+			}
+			if BindError(err) != customErr {
+				t.Error("BindError did not return the unknown fields error")
+			}
+		})
+	}
+}
+
+// TestJSONBinderAcceptsKnownVariants 嵌入結構提升的欄位、大小寫不同的欄位名稱與自行解析 JSON 的型別內容不視為未知欄位
+func TestJSONBinderAcceptsKnownVariants(t *testing.T) {
+	body := `{"ID": 1, "Customer": {"NAME": "Acme"}, "meta": {"unknown": 1}, "lines": [], "labels": {}}`
+	var order bindOrder
+	if err := bindJSON(t, NewJSONBinder(true), false, body, &order); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if order.ID != 1 || order.Customer.Name != "Acme" {
+		t.Errorf("order = %+v", order)
+	}
+}
+
+// TestJSONBinderStrictMode 非嚴格的 binder 只在套用 StrictJSONBinding 的路由檢查未知欄位
+func TestJSONBinderStrictMode(t *testing.T) {
+	body := `{"customer": {"nmae": "Acme"}, "lines": [{"qty": 1}]}`
+	if err := bindJSON(t, NewJSONBinder(false), false, body, &bindOrder{}); err != nil {
+		t.Errorf("lenient route: %v", err)
+	}
+	if err := bindJSON(t, NewJSONBinder(false), true, body, &bindOrder{}); BindError(err).ErrorCode != ErrorCodeUnknownFields {
+		t.Errorf("strict route: %v", err)
+	}
+}
+
+func TestJSONBinderMalformedJSON(t *testing.T) {
+	for _, body := range []string{`{"lines": [{"sku": "a"}`, `{"lines": {"sku": "a"}}`, `{"customer": {"name": 1}}`} {
+		err := bindJSON(t, NewJSONBinder(true), false, body, &bindOrder{})
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("Bind(%s) = %v, want a 400 HTTPError", body, err)
+		}
+		if BindError(err) != ErrBadRequest {
+			t.Errorf("BindError(%v) = %v", err, BindError(err))
+		}
+	}
+}
@@ -9,7 +9,10 @@ import (
 type CustomError struct {
 	Code    int         `json:"code"`    // HTTP 狀態碼
 	Message string      `json:"message"` // 錯誤訊息
+	ErrorCode string    `json:"error_code,omitempty"` // 機器可讀的錯誤代碼 (例如 ROUTE_NOT_FOUND)，供用戶端區分同一狀態碼下的不同錯誤
 	Details interface{} `json:"details,omitempty"` // 錯誤細節 (例如驗證錯誤列表、原始錯誤等)
+	RequestID string    `json:"request_id,omitempty"` // 請求 ID，與日誌中的 request_id 相同，方便回報問題時查詢
+	TraceID string      `json:"trace_id,omitempty"` // 追蹤 ID (啟用追蹤時)，與日誌中的 trace_id 相同，可在追蹤系統中查詢請求經過的 span
 }
 
 // Error 實現 error 介面，讓 CustomError 可以作為 Go 的錯誤類型使用
@@ -20,10 +23,32 @@ func (e *CustomError) Error() string {
 	return fmt.Sprintf("Error %d: %s", e.Code, e.Message)
 }
 
-// SetDetails 設置錯誤的詳細信息，並返回 CustomError 實例
+// SetDetails 返回填入詳細信息的副本，不修改原本的錯誤
+// 常用錯誤實例 (例如 ErrBadRequest) 為全局共用，直接修改會讓同時處理的請求看到彼此的 details
 func (e *CustomError) SetDetails(details interface{}) *CustomError {
-	e.Details = details
-	return e
+	withDetails := *e
+	withDetails.Details = details
+	return &withDetails
+}
+
+// Is 讓 errors.Is 將副本 (SetDetails、WithRequestID 等返回的錯誤) 視為原本的錯誤：狀態碼、訊息與錯誤代碼相同即相符
+func (e *CustomError) Is(target error) bool {
+	t, ok := target.(*CustomError)
+	return ok && t.Code == e.Code && t.Message == e.Message && t.ErrorCode == e.ErrorCode
+}
+
+// WithRequestID 返回填入 request_id 的副本，不修改原本的錯誤 (常用錯誤實例為全局共用)
+func (e *CustomError) WithRequestID(requestID string) *CustomError {
+	withID := *e
+	withID.RequestID = requestID
+	return &withID
+}
+
+// WithTraceID 返回填入 trace_id 的副本，不修改原本的錯誤
+func (e *CustomError) WithTraceID(traceID string) *CustomError {
+	withID := *e
+	withID.TraceID = traceID
+	return &withID
 }
 
 // 常用錯誤實例
@@ -33,9 +58,15 @@ var (
 	ErrUnauthorized   = &CustomError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
 	ErrForbidden      = &CustomError{Code: http.StatusForbidden, Message: "Forbidden"}
 	ErrNotFound       = &CustomError{Code: http.StatusNotFound, Message: "Resource not found"}
+	ErrConflict       = &CustomError{Code: http.StatusConflict, Message: "Conflict", ErrorCode: ErrorCodeConflict} // 唯一性衝突 (例如名稱已存在)，與輸入無效的 400 區分
 	ErrInternalServer = &CustomError{Code: http.StatusInternalServerError, Message: "Internal server error"}
+	ErrRequestTimeout = &CustomError{Code: http.StatusServiceUnavailable, Message: "Request timed out"}
+	ErrClientClosedRequest = &CustomError{Code: StatusClientClosedRequest, Message: "Client closed request"}
 )
 
+// StatusClientClosedRequest 用戶端在回應前中斷連線 (nginx 的非標準狀態碼 499)，只會出現在日誌與指標中
+const StatusClientClosedRequest = 499
+
 // NewValidationError 創建一個特定用於驗證失敗的錯誤實例
 func NewValidationError(details interface{}) *CustomError {
 	return &CustomError{Code: http.StatusBadRequest, Message: "Validation failed", Details: details}
@@ -45,3 +76,76 @@ func NewValidationError(details interface{}) *CustomError {
 func NewCustomError(code int, message string, details interface{}) *CustomError {
 	return &CustomError{Code: code, Message: message, Details: details}
 }
+
+// NewConflictError 創建一個 409 衝突錯誤，details 可包含衝突記錄的資訊 (例如既有記錄的 ID)
+func NewConflictError(message string, details interface{}) *CustomError {
+	return &CustomError{Code: http.StatusConflict, Message: message, ErrorCode: ErrorCodeConflict, Details: details}
+}
+
+// InvalidParam 無效的查詢參數，作為 400 錯誤的 details
+type InvalidParam struct {
+	Param   string `json:"param"`   // 參數名稱，例如 page_size
+	Message string `json:"message"` // 錯誤說明
+}
+
+// NewInvalidParamError 創建查詢參數無效的 400 錯誤，details 為 []InvalidParam
+func NewInvalidParamError(param, message string) *CustomError {
+	return NewInvalidParamsError([]InvalidParam{{Param: param, Message: message}})
+}
+
+// NewInvalidParamsError 創建一次列出多個無效查詢參數的 400 錯誤
+func NewInvalidParamsError(params []InvalidParam) *CustomError {
+	return &CustomError{Code: http.StatusBadRequest, Message: "Invalid query parameter", Details: params}
+}
+
+// 機器可讀的錯誤代碼 (CustomError.ErrorCode)
+const (
+	ErrorCodeRouteNotFound    = "ROUTE_NOT_FOUND"    // 沒有對應請求路徑的路由
+	ErrorCodeMethodNotAllowed = "METHOD_NOT_ALLOWED" // 路徑存在，但不支援請求的 HTTP 方法
+	ErrorCodeRequestTooLarge  = "REQUEST_TOO_LARGE"  // 請求內容或上傳的檔案超過大小上限
+	ErrorCodeUnknownFields    = "UNKNOWN_FIELDS"     // 嚴格綁定的 JSON 請求內容含有未知欄位
+	ErrorCodeConflict         = "CONFLICT"           // 與既有記錄衝突 (例如名稱、路徑、Email 或 SKU 已存在)
+	ErrorCodeTooManyStreams   = "TOO_MANY_STREAMS"   // 帳戶同時開啟的事件串流 (GET /events) 已達上限
+)
+
+// RouteErrorDetails 路由錯誤 (404、405) 的 details
+type RouteErrorDetails struct {
+	Method string   `json:"method"`          // 請求的 HTTP 方法
+	Path   string   `json:"path"`            // 請求的路徑
+	Allow  []string `json:"allow,omitempty"` // 405 時此路徑支援的 HTTP 方法，與 Allow 標頭相同
+}
+
+// NewRouteNotFoundError 創建沒有對應路由的 404 錯誤 (與資源不存在的 ErrNotFound 以 ErrorCode 區分)
+func NewRouteNotFoundError(method, path string) *CustomError {
+	return &CustomError{Code: http.StatusNotFound, Message: "Route not found", ErrorCode: ErrorCodeRouteNotFound,
+		Details: RouteErrorDetails{Method: method, Path: path}}
+}
+
+// NewMethodNotAllowedError 創建路徑不支援請求方法的 405 錯誤，allow 為此路徑支援的方法
+func NewMethodNotAllowedError(method, path string, allow []string) *CustomError {
+	return &CustomError{Code: http.StatusMethodNotAllowed, Message: "Method not allowed", ErrorCode: ErrorCodeMethodNotAllowed,
+		Details: RouteErrorDetails{Method: method, Path: path, Allow: allow}}
+}
+
+// NewRequestTooLargeError 創建請求內容或上傳檔案超過大小上限的 413 錯誤，details 的 max_bytes 為設定的上限
+func NewRequestTooLargeError(message string, maxBytes int64) *CustomError {
+	return &CustomError{Code: http.StatusRequestEntityTooLarge, Message: message, ErrorCode: ErrorCodeRequestTooLarge,
+		Details: map[string]interface{}{"max_bytes": maxBytes}}
+}
+
+// NewTooManyStreamsError 創建帳戶同時開啟的事件串流已達上限的 429 錯誤，details 的 max_streams 為設定的上限
+func NewTooManyStreamsError(maxStreams int) *CustomError {
+	return &CustomError{Code: http.StatusTooManyRequests, Message: "Too many event streams", ErrorCode: ErrorCodeTooManyStreams,
+		Details: map[string]interface{}{"max_streams": maxStreams}}
+}
+
+// UnknownFieldsDetails 未知欄位錯誤的 details
+type UnknownFieldsDetails struct {
+	Fields []string `json:"fields"` // 未知欄位的名稱，巢狀欄位以 . 連接，例如 addresses[0].ctiy
+}
+
+// NewUnknownFieldsError 創建 JSON 請求內容含有未知欄位的 400 錯誤 (見 JSONBinder)
+func NewUnknownFieldsError(fields []string) *CustomError {
+	return &CustomError{Code: http.StatusBadRequest, Message: "Unknown fields in request body", ErrorCode: ErrorCodeUnknownFields,
+		Details: UnknownFieldsDetails{Fields: fields}}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestSetDetailsConcurrent 多個 goroutine 同時對共用的 ErrBadRequest 呼叫 SetDetails 與 WithRequestID，
+// 每個呼叫端只看到自己的 details，原本的錯誤維持不變 (以 go test -race 執行時也檢查沒有資料競爭)
+func TestSetDetailsConcurrent(t *testing.T) {
+	const goroutines = 64
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				want := fmt.Sprintf("goroutine %d call %d", i, j)
+				got := ErrBadRequest.SetDetails(want).WithRequestID(want)
+				if got == ErrBadRequest || got.Details != want || got.RequestID != want {
+					errs <- fmt.Errorf("SetDetails(%q) = %+v", want, got)
+					return
+				}
+				if !errors.Is(got, ErrBadRequest) {
+					errs <- fmt.Errorf("errors.Is(%v, ErrBadRequest) = false", got)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if ErrBadRequest.Details != nil || ErrBadRequest.RequestID != "" {
+		t.Errorf("ErrBadRequest was modified: %+v", ErrBadRequest)
+	}
+}
+
+// TestSetDetailsDoesNotBleedAcrossRequests 兩個端點同時以 ErrBadRequest 回應各自的 details，回應中不會出現另一個端點的內容
+func TestSetDetailsDoesNotBleedAcrossRequests(t *testing.T) {
+	e := echo.New()
+	e.POST("/accounts", func(c echo.Context) error {
+		return c.JSON(http.StatusBadRequest, ErrBadRequest.SetDetails("Username already exists"))
+	})
+	e.POST("/customers", func(c echo.Context) error {
+		return c.JSON(http.StatusBadRequest, ErrBadRequest.SetDetails("Invalid customer ID"))
+	})
+	want := map[string]string{"/accounts": "Username already exists", "/customers": "Invalid customer ID"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*32)
+	for i := 0; i < 32; i++ {
+		for path := range want {
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+				var body CustomError
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					errs <- err
+					return
+				}
+				if body.Details != want[path] {
+					errs <- fmt.Errorf("%s details = %v, want %q", path, body.Details, want[path])
+				}
+			}(path)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
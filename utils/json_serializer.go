@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/tracing" // 目前請求的 trace ID
+)
+
+// RequestIDJSONSerializer 在輸出 CustomError 時自動填入 request_id 與 trace_id (啟用追蹤時)，並依 Accept-Language 翻譯訊息 (見 CustomError.Localize)
+// 其他值與 Echo 預設的序列化相同；handler 直接以 c.JSON 返回錯誤時也能帶有 request_id 與翻譯，不需逐一修改
+type RequestIDJSONSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+// Serialize 實現 echo.JSONSerializer 介面
+func (s RequestIDJSONSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	if customErr, ok := i.(*CustomError); ok && customErr != nil {
+		if customErr.RequestID == "" {
+			customErr = customErr.WithRequestID(RequestID(c))
+		}
+		if traceID := tracing.TraceID(c.Request().Context()); traceID != "" && customErr.TraceID == "" {
+			customErr = customErr.WithTraceID(traceID)
+		}
+		locale := NegotiateLocale(c.Request().Header.Get("Accept-Language"))
+		customErr = customErr.Localize(locale)
+		c.Response().Header().Set("Content-Language", locale)
+		i = customErr
+	}
+	return s.DefaultJSONSerializer.Serialize(c, i, indent)
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/wac0705/fastener-api/config" // 預設語系
+)
+
+// 支援的語系 (Content-Language 的值)
+const (
+	LocaleEnglish            = "en"    // 原始訊息的語系，不需要訊息目錄
+	LocaleTraditionalChinese = "zh-TW" // utils/locales/zh-TW.json
+)
+
+// localeFiles 編譯時嵌入的訊息目錄，檔名 (不含 .json) 為語系，內容為英文訊息對應的翻譯
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// messageCatalogs 語系 => 英文訊息 => 翻譯
+var messageCatalogs = loadMessageCatalogs()
+
+// loadMessageCatalogs 讀取嵌入的訊息目錄；目錄隨程式一起編譯，格式錯誤為程式錯誤
+func loadMessageCatalogs() map[string]map[string]string {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic("invalid message catalog " + file.Name() + ": " + err.Error())
+		}
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = catalog
+	}
+	return catalogs
+}
+
+// supportedLocales Accept-Language 的語言標籤 (小寫) 對應的語系，不在其中的語言使用預設語系
+var supportedLocales = map[string]string{
+	"en":         LocaleEnglish,
+	"zh":         LocaleTraditionalChinese,
+	"zh-tw":      LocaleTraditionalChinese,
+	"zh-hant":    LocaleTraditionalChinese,
+	"zh-hant-tw": LocaleTraditionalChinese,
+}
+
+// DefaultLocale 返回 Accept-Language 沒有支援的語言時使用的語系 (DEFAULT_LOCALE，未載入配置時為英文)
+func DefaultLocale() string {
+	if config.Cfg != nil && config.Cfg.DefaultLocale != "" {
+		return config.Cfg.DefaultLocale
+	}
+	return LocaleEnglish
+}
+
+// NegotiateLocale 依 Accept-Language 標頭 (例如 "zh-TW,zh;q=0.9,en;q=0.8") 選擇 q 值最高的支援語系，沒有時返回 DefaultLocale
+func NegotiateLocale(acceptLanguage string) string {
+	if locales := preferredLocales(acceptLanguage); len(locales) > 0 {
+		return locales[0]
+	}
+	return DefaultLocale()
+}
+
+// LocalizeMessage 返回 message 在 locale 的翻譯，訊息目錄中沒有時返回原本的訊息
+func LocalizeMessage(locale, message string) string {
+	if translated, ok := messageCatalogs[locale][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Localize 返回訊息翻譯為 locale 的副本，不修改原本的錯誤 (常用錯誤實例為全局共用)
+// 翻譯 Message、字串的 Details 與 []InvalidParam 的 Message；ErrorCode 與其他 Details 不變，用戶端應以 ErrorCode 判斷錯誤
+// 翻譯後的副本與原本的錯誤不再符合 errors.Is，只在輸出回應時呼叫 (見 RequestIDJSONSerializer)
+func (e *CustomError) Localize(locale string) *CustomError {
+	if len(messageCatalogs[locale]) == 0 {
+		return e
+	}
+	localized := *e
+	localized.Message = LocalizeMessage(locale, e.Message)
+	switch details := e.Details.(type) {
+	case string:
+		localized.Details = LocalizeMessage(locale, details)
+	case []InvalidParam:
+		params := make([]InvalidParam, len(details))
+		for i, param := range details {
+			params[i] = InvalidParam{Param: param.Param, Message: LocalizeMessage(locale, param.Message)}
+		}
+		localized.Details = params
+	}
+	return &localized
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// loggerContextKey 請求範圍 logger 在 context 中的 key
+type loggerContextKey struct{}
+
+// requestLogger 請求範圍的 logger 與已加上的欄位 (request_id、trace_id、帳戶)
+// 另外保存欄位，注入 logger 的 service 與 repository 才能在自己的 logger 加上相同的欄位 (見 ContextLogger)
+type requestLogger struct {
+	logger *zap.Logger
+	fields []zap.Field
+}
+
+// ContextWithLogger 返回以 logger 作為請求範圍 logger 的 context，供同一請求中的 handler 使用；已加上的欄位保留
+func ContextWithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	fields := LogFields(ctx)
+	return context.WithValue(ctx, loggerContextKey{}, requestLogger{logger: logger.With(fields...), fields: fields})
+}
+
+// ContextWithLogFields 返回在請求範圍 logger 加上 fields 的 context
+func ContextWithLogFields(ctx context.Context, fields ...zap.Field) context.Context {
+	all := append(append([]zap.Field{}, LogFields(ctx)...), fields...)
+	return context.WithValue(ctx, loggerContextKey{}, requestLogger{logger: LoggerFromContext(ctx).With(fields...), fields: all})
+}
+
+// LogFields 返回 context 中請求範圍的日誌欄位，沒有時返回 nil
+func LogFields(ctx context.Context) []zap.Field {
+	rl, _ := ctx.Value(loggerContextKey{}).(requestLogger)
+	return rl.fields
+}
+
+// LoggerFromContext 返回 context 中的請求範圍 logger (已帶有 request_id)，沒有時返回 zap.L()
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if rl, ok := ctx.Value(loggerContextKey{}).(requestLogger); ok {
+		return rl.logger
+	}
+	return zap.L()
+}
+
+// ContextLogger 返回加上 ctx 中請求範圍欄位的 base，service 與 repository 以它記錄注入的 logger，日誌才能以 request_id 對應到請求
+func ContextLogger(ctx context.Context, base *zap.Logger) *zap.Logger {
+	if fields := LogFields(ctx); len(fields) > 0 {
+		return base.With(fields...)
+	}
+	return base
+}
+
+// Logger 返回目前請求的 logger，handler 與中介軟體應以它取代 zap.L()，日誌才能以 request_id 對應到回應
+func Logger(c echo.Context) *zap.Logger {
+	return LoggerFromContext(c.Request().Context())
+}
+
+// RequestID 返回目前請求的 ID (由 RequestID 中介軟體寫入回應的 X-Request-ID 標頭)
+func RequestID(c echo.Context) string {
+	return c.Response().Header().Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestRequestLogFields handler 的請求範圍 logger 與注入的 logger (ContextLogger) 帶有相同的欄位
+func TestRequestLogFields(t *testing.T) {
+	requestCore, requestLogs := observer.New(zapcore.InfoLevel)
+	injectedCore, injectedLogs := observer.New(zapcore.InfoLevel)
+	injected := zap.New(injectedCore)
+
+	if got := ContextLogger(context.Background(), injected); got != injected {
+		t.Error("ContextLogger without request fields did not return the base logger")
+	}
+
+	ctx := ContextWithLogFields(context.Background(), zap.String("request_id", "req-1")) // 設定 logger 前加上的欄位也保留
+	ctx = ContextWithLogger(ctx, zap.New(requestCore))
+	ctx = ContextWithLogFields(ctx, zap.String("trace_id", "abc"))
+	parent := ctx
+	ctx = ContextWithLogFields(ctx, zap.Int("account_id", 7))
+	_ = ContextWithLogFields(parent, zap.Int("account_id", 8)) // 不影響 ctx 的欄位
+
+	LoggerFromContext(ctx).Info("handler")
+	ContextLogger(ctx, injected).Info("repository")
+
+	want := map[string]interface{}{"request_id": "req-1", "trace_id": "abc", "account_id": int64(7)}
+	for name, logs := range map[string]*observer.ObservedLogs{"request": requestLogs, "injected": injectedLogs} {
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Fatalf("%s logger: %d entries, want 1", name, len(entries))
+		}
+		got := entries[0].ContextMap()
+		if len(got) != len(want) {
+			t.Errorf("%s logger fields = %v, want %v", name, got, want)
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("%s logger %s = %v, want %v", name, key, got[key], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/config"
+)
+
+const (
+	defaultPageSize    = 20  // 未設定 DEFAULT_PAGE_SIZE 時的每頁筆數
+	defaultMaxPageSize = 100 // 未設定 MAX_PAGE_SIZE 時的 page_size 上限
+)
+
+// Pagination 列表查詢的分頁參數，由 ParsePagination 解析後直接傳給 Repository
+// PageSize 為 0 表示不分頁 (例如匯出或內部查詢)
+type Pagination struct {
+	Page         int    // 頁碼，從 1 開始
+	PageSize     int    // 每頁筆數
+	Cursor       string // 解碼後的游標，提供時由支援游標分頁的列表以此定位，Page 固定為 1
+	IncludeTotal bool   // include_total=true：支援的列表 (帳戶、客戶、產品定義) 才計算總筆數，未要求時 total 為 null
+}
+
+// Limit 返回 LIMIT 的值
+func (p Pagination) Limit() int {
+	return p.PageSize
+}
+
+// Offset 返回 OFFSET 的值；使用游標或不分頁時為 0
+func (p Pagination) Offset() int {
+	if p.Page < 1 || p.PageSize == 0 {
+		return 0
+	}
+	return (p.Page - 1) * p.PageSize
+}
+
+// MaxPageSize 返回 page_size 上限，由 MAX_PAGE_SIZE 設定
+func MaxPageSize() int {
+	if config.Cfg != nil && config.Cfg.MaxPageSize > 0 {
+		return config.Cfg.MaxPageSize
+	}
+	return defaultMaxPageSize
+}
+
+// DefaultPageSize 返回未指定 page_size 時的每頁筆數，由 DEFAULT_PAGE_SIZE 設定
+func DefaultPageSize() int {
+	if config.Cfg != nil && config.Cfg.DefaultPageSize > 0 {
+		return config.Cfg.DefaultPageSize
+	}
+	return defaultPageSize
+}
+
+// ParsePagination 解析 page、page_size、cursor 與 include_total 查詢參數，未提供時使用預設值；page_size 超過 MaxPageSize 時以上限計算
+// 參數無效時返回 400 的 *CustomError，details 指出是哪一個參數 (見 NewInvalidParamError)
+func ParsePagination(c echo.Context) (Pagination, error) {
+	p := Pagination{Page: 1, PageSize: DefaultPageSize()}
+	if v := c.QueryParam("page_size"); v != "" {
+		pageSize, err := strconv.Atoi(v)
+		if err != nil || pageSize < 1 {
+			return Pagination{}, NewInvalidParamError("page_size", "must be a positive integer")
+		}
+		p.PageSize = pageSize
+		if pageSize > MaxPageSize() {
+			p.PageSize = MaxPageSize() // 超過上限時以上限計算，不返回錯誤
+		}
+	}
+	if v := c.QueryParam("include_total"); v != "" {
+		includeTotal, err := strconv.ParseBool(v)
+		if err != nil {
+			return Pagination{}, NewInvalidParamError("include_total", "must be true or false")
+		}
+		p.IncludeTotal = includeTotal
+	}
+	if v := c.QueryParam("cursor"); v != "" {
+		if c.QueryParam("page") != "" {
+			return Pagination{}, NewInvalidParamError("cursor", "cannot be combined with page")
+		}
+		cursor, err := DecodeCursor(v)
+		if err != nil {
+			return Pagination{}, NewInvalidParamError("cursor", "malformed cursor")
+		}
+		p.Cursor = cursor
+		return p, nil
+	}
+	if v := c.QueryParam("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return Pagination{}, NewInvalidParamError("page", "must be a positive integer")
+		}
+		p.Page = page
+	}
+	return p, nil
+}
+
+// EncodeCursor 將列表最後一筆的定位值編碼為不透明的游標 (URL 安全的 base64)
+func EncodeCursor(value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// DecodeCursor 解碼 EncodeCursor 產生的游標
+func DecodeCursor(cursor string) (string, error) {
+	value, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
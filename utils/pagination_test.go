@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/wac0705/fastener-api/config"
+)
+
+// parsePaginationQuery 以 query 字串建立請求並呼叫 ParsePagination
+func parsePaginationQuery(query string) (Pagination, error) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/customers?"+query, nil)
+	return ParsePagination(echo.New().NewContext(req, httptest.NewRecorder()))
+}
+
+func TestParsePagination(t *testing.T) {
+	cursor := EncodeCursor(`{"s":"name","v":"Acme","id":7}`)
+	tests := []struct {
+		name      string
+		query     string
+		want      Pagination
+		wantParam string // 返回 400 時 details 中的參數，空字串表示成功
+	}{
+		{name: "defaults", query: "", want: Pagination{Page: 1, PageSize: defaultPageSize}},
+		{name: "explicit page and size", query: "page=3&page_size=50", want: Pagination{Page: 3, PageSize: 50}},
+		{name: "smallest page size", query: "page_size=1", want: Pagination{Page: 1, PageSize: 1}},
+		{name: "page size at the max", query: "page_size=100", want: Pagination{Page: 1, PageSize: defaultMaxPageSize}},
+		{name: "page size above the max clamps", query: "page_size=101", want: Pagination{Page: 1, PageSize: defaultMaxPageSize}},
+		{name: "huge page size clamps", query: "page_size=1000000", want: Pagination{Page: 1, PageSize: defaultMaxPageSize}},
+		{name: "include total", query: "include_total=true", want: Pagination{Page: 1, PageSize: defaultPageSize, IncludeTotal: true}},
+		{name: "cursor", query: "cursor=" + cursor + "&page_size=10", want: Pagination{Page: 1, PageSize: 10, Cursor: `{"s":"name","v":"Acme","id":7}`}},
+		{name: "page zero", query: "page=0", wantParam: "page"},
+		{name: "negative page", query: "page=-1", wantParam: "page"},
+		{name: "non-numeric page", query: "page=two", wantParam: "page"},
+		{name: "page size zero", query: "page_size=0", wantParam: "page_size"},
+		{name: "negative page size", query: "page_size=-20", wantParam: "page_size"},
+		{name: "non-numeric page size", query: "page_size=ten", wantParam: "page_size"},
+		{name: "fractional page size", query: "page_size=1.5", wantParam: "page_size"},
+		{name: "invalid include total", query: "include_total=maybe", wantParam: "include_total"},
+		{name: "cursor with page", query: "cursor=" + cursor + "&page=2", wantParam: "cursor"},
+		{name: "malformed cursor", query: "cursor=not*base64", wantParam: "cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePaginationQuery(tt.query)
+			if tt.wantParam == "" {
+				if err != nil || got != tt.want {
+					t.Fatalf("ParsePagination(%q) = %+v, %v; want %+v", tt.query, got, err, tt.want)
+				}
+				return
+			}
+			customErr, ok := err.(*CustomError)
+			if !ok || customErr.Code != http.StatusBadRequest {
+				t.Fatalf("ParsePagination(%q) error = %v, want 400", tt.query, err)
+			}
+			params, _ := customErr.Details.([]InvalidParam)
+			if len(params) != 1 || params[0].Param != tt.wantParam {
+				t.Errorf("details = %+v, want param %s", customErr.Details, tt.wantParam)
+			}
+		})
+	}
+}
+
+// TestParsePaginationConfig 預設值與上限由 DEFAULT_PAGE_SIZE 與 MAX_PAGE_SIZE 設定
+func TestParsePaginationConfig(t *testing.T) {
+	previous := config.Cfg
+	config.Cfg = &config.AppConfig{DefaultPageSize: 10, MaxPageSize: 25}
+	t.Cleanup(func() { config.Cfg = previous })
+
+	for query, wantSize := range map[string]int{"": 10, "page_size=25": 25, "page_size=26": 25} {
+		got, err := parsePaginationQuery(query)
+		if err != nil || got.PageSize != wantSize {
+			t.Errorf("ParsePagination(%q) = %+v, %v; want page_size %d", query, got, err, wantSize)
+		}
+	}
+}
+
+func TestPaginationLimitOffset(t *testing.T) {
+	tests := []struct {
+		pagination            Pagination
+		wantLimit, wantOffset int
+	}{
+		{Pagination{Page: 1, PageSize: 20}, 20, 0},
+		{Pagination{Page: 2, PageSize: 20}, 20, 20},
+		{Pagination{Page: 500, PageSize: 100}, 100, 49900},
+		{Pagination{Page: 0, PageSize: 20}, 20, 0}, // 頁碼無效時從第一筆開始
+		{Pagination{Page: -3, PageSize: 20}, 20, 0},
+		{Pagination{Page: 4, PageSize: 0}, 0, 0}, // 不分頁
+	}
+	for _, tt := range tests {
+		got := []int{tt.pagination.Limit(), tt.pagination.Offset()}
+		if want := []int{tt.wantLimit, tt.wantOffset}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: LIMIT/OFFSET = %v, want %v", tt.pagination, got, want)
+		}
+	}
+}
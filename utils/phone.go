@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// phoneSeparatorReplacer 移除電話號碼中常見的分隔字元
+var phoneSeparatorReplacer = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "")
+
+// countryCallingCodes 國碼對照表，用於將本地格式的號碼轉為 E.164
+var countryCallingCodes = map[string]string{
+	"TW": "886",
+	"CN": "86",
+	"HK": "852",
+	"JP": "81",
+	"KR": "82",
+	"SG": "65",
+	"US": "1",
+	"CA": "1",
+	"GB": "44",
+	"DE": "49",
+}
+
+// StripPhoneSeparators 移除電話號碼中的空白、連字號、點、斜線與括號
+func StripPhoneSeparators(phone string) string {
+	return phoneSeparatorReplacer.Replace(strings.TrimSpace(phone))
+}
+
+// IsValidPhone 檢查電話號碼格式：移除分隔字元後只能是數字 (可帶前綴 "+")，且為 7 到 15 位數
+func IsValidPhone(phone string) bool {
+	digits := strings.TrimPrefix(StripPhoneSeparators(phone), "+")
+	if len(digits) < 7 || len(digits) > 15 {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizePhone 將電話號碼轉為 E.164 格式 (例如 "+886212345678")
+// 以 "+" 或 "00" 開頭的號碼視為國際格式，其餘視為 defaultCountry 的本地號碼並去掉開頭的 "0"；空字串返回空字串
+func NormalizePhone(phone, defaultCountry string) (string, error) {
+	stripped := StripPhoneSeparators(phone)
+	if stripped == "" {
+		return "", nil
+	}
+	if !IsValidPhone(stripped) {
+		return "", fmt.Errorf("invalid phone number: %q", phone)
+	}
+
+	var digits string
+	switch {
+	case strings.HasPrefix(stripped, "+"):
+		digits = stripped[1:]
+	case strings.HasPrefix(stripped, "00"):
+		digits = stripped[2:]
+	default:
+		code, ok := countryCallingCodes[strings.ToUpper(defaultCountry)]
+		if !ok {
+			return "", fmt.Errorf("unsupported default phone country: %q", defaultCountry)
+		}
+		digits = code + strings.TrimPrefix(stripped, "0")
+	}
+	if len(digits) < 8 || len(digits) > 15 { // E.164 最多 15 位 (含國碼)
+		return "", fmt.Errorf("invalid phone number: %q", phone)
+	}
+	return "+" + digits, nil
+}
@@ -0,0 +1,317 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wac0705/fastener-api/decimal"
+	"github.com/wac0705/fastener-api/utils"
+)
+
+// Type 篩選欄位的型別，決定查詢參數的解析方式
+type Type string
+
+const (
+	TypeString Type = "string"
+	TypeInt    Type = "integer"
+	TypeNumber Type = "number" // 以 decimal.Parse 解析，避免浮點誤差
+	TypeBool   Type = "boolean"
+	TypeDate   Type = "date"      // YYYY-MM-DD，以日期比較 (欄位轉為 date)，created_at_lte=2024-01-31 包含當天；日期的界線為 UTC 的午夜 (連接的工作階段時區固定為 UTC)
+	TypeTime   Type = "date-time" // RFC 3339，例如 2024-01-01T08:00:00+08:00
+)
+
+// Operator 篩選運算子，查詢參數以 "欄位_運算子" 表示 (例如 created_at_gte)，只有欄位名稱時為 eq
+type Operator string
+
+const (
+	Eq       Operator = "eq"
+	Ne       Operator = "ne"
+	Gt       Operator = "gt"
+	Gte      Operator = "gte"
+	Lt       Operator = "lt"
+	Lte      Operator = "lte"
+	Contains Operator = "contains" // 不區分大小寫的包含比對 (ILIKE)，只適用於字串
+	In       Operator = "in"       // 以逗號分隔的多個值
+)
+
+// operators 所有運算子，解析參數名稱時依序比對後綴
+var operators = []Operator{Eq, Ne, Gte, Gt, Lte, Lt, Contains, In}
+
+// 常用的運算子組合
+var (
+	Equality = []Operator{Eq, Ne, In}
+	Range    = []Operator{Eq, Gt, Gte, Lt, Lte}
+	Text     = []Operator{Eq, Ne, Contains, In}
+)
+
+// paginationParams 分頁參數由 utils.ParsePagination 處理，一律允許
+var paginationParams = []string{"page", "page_size", "cursor", "include_total"}
+
+// Field 可篩選的欄位
+type Field struct {
+	Type      Type
+	Operators []Operator // 允許的運算子，空白時只允許 eq
+}
+
+// allows 欄位是否允許指定的運算子
+func (f Field) allows(op Operator) bool {
+	if len(f.Operators) == 0 {
+		return op == Eq
+	}
+	for _, allowed := range f.Operators {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Spec 一個列表端點允許的篩選欄位與排序欄位，由 Handler 宣告
+// 欄位名稱對應的 SQL 欄位由 Repository 在編譯時提供 (見 Query.Where 與 Query.OrderBy)
+type Spec struct {
+	Fields map[string]Field
+	Sorts  []string // 允許排序的欄位，空白時不接受 sort 參數
+	Params []string // 由 Handler 自行解析的其他參數 (例如 q)，不視為未知欄位
+}
+
+// Condition 一個已驗證的篩選條件；Value 已依欄位型別轉換，In 時為 []interface{}
+type Condition struct {
+	Field    string
+	Type     Type
+	Operator Operator
+	Value    interface{}
+}
+
+// Sort 一個已驗證的排序欄位
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Query 解析後的篩選條件與排序
+type Query struct {
+	Conditions []Condition
+	Sorts      []Sort
+}
+
+// Parse 依 spec 解析查詢參數，例如 ?created_at_gte=2024-01-01&sort=-name
+// 未知的參數、不允許的運算子、格式錯誤的值與不允許的排序欄位會一次列出，返回 400 的錯誤 (details 為 []utils.InvalidParam)
+func Parse(values url.Values, spec Spec) (Query, *utils.CustomError) {
+	known := map[string]bool{}
+	for _, name := range append(append([]string{}, paginationParams...), spec.Params...) {
+		known[name] = true
+	}
+
+	q := Query{}
+	problems := []utils.InvalidParam{}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if known[key] {
+			continue
+		}
+		if len(values[key]) > 1 {
+			problems = append(problems, utils.InvalidParam{Param: key, Message: "must not be specified more than once"})
+			continue
+		}
+		raw := values[key][0]
+		if key == "sort" && len(spec.Sorts) > 0 {
+			sorts, sortProblems := parseSorts(raw, spec.Sorts)
+			q.Sorts = sorts
+			problems = append(problems, sortProblems...)
+			continue
+		}
+
+		name, op := splitParam(key, spec.Fields)
+		field, ok := spec.Fields[name]
+		if !ok {
+			problems = append(problems, utils.InvalidParam{Param: key, Message: "unknown filter"})
+			continue
+		}
+		if !field.allows(op) {
+			problems = append(problems, utils.InvalidParam{Param: key, Message: fmt.Sprintf("operator %s is not supported for %s", op, name)})
+			continue
+		}
+		if op == Contains && field.Type != TypeString {
+			problems = append(problems, utils.InvalidParam{Param: key, Message: "contains is only supported for string fields"})
+			continue
+		}
+
+		value, err := parseValues(raw, field.Type, op)
+		if err != nil {
+			problems = append(problems, utils.InvalidParam{Param: key, Message: err.Error()})
+			continue
+		}
+		q.Conditions = append(q.Conditions, Condition{Field: name, Type: field.Type, Operator: op, Value: value})
+	}
+
+	if len(problems) > 0 {
+		return Query{}, utils.NewInvalidParamsError(problems)
+	}
+	return q, nil
+}
+
+// splitParam 將參數名稱拆為欄位名稱與運算子；名稱本身就是欄位時為 eq
+func splitParam(key string, fields map[string]Field) (string, Operator) {
+	if _, ok := fields[key]; ok {
+		return key, Eq
+	}
+	for _, op := range operators {
+		if name := strings.TrimSuffix(key, "_"+string(op)); name != key {
+			return name, op
+		}
+	}
+	return key, Eq
+}
+
+// parseValues 依型別解析參數值，In 時以逗號分隔為多個值
+func parseValues(raw string, typ Type, op Operator) (interface{}, error) {
+	if op != In {
+		return parseValue(raw, typ)
+	}
+	values := []interface{}{}
+	for _, part := range strings.Split(raw, ",") {
+		value, err := parseValue(strings.TrimSpace(part), typ)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// parseValue 依型別解析單一值
+func parseValue(raw string, typ Type) (interface{}, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("must not be empty")
+	}
+	switch typ {
+	case TypeInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return n, nil
+	case TypeNumber:
+		d, err := decimal.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return d, nil
+	case TypeBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	case TypeDate:
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a date in YYYY-MM-DD format")
+		}
+		return t, nil
+	case TypeTime:
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be an RFC 3339 timestamp")
+		}
+		return t, nil
+	}
+	return raw, nil
+}
+
+// parseSorts 解析以逗號分隔的排序欄位，前綴 "-" 表示降序
+func parseSorts(raw string, allowed []string) ([]Sort, []utils.InvalidParam) {
+	allowedSet := map[string]bool{}
+	for _, name := range allowed {
+		allowedSet[name] = true
+	}
+	sorts := []Sort{}
+	problems := []utils.InvalidParam{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		s := Sort{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		switch {
+		case !allowedSet[s.Field]:
+			problems = append(problems, utils.InvalidParam{Param: "sort", Message: fmt.Sprintf("cannot sort by %q", s.Field)})
+		case seen[s.Field]:
+			problems = append(problems, utils.InvalidParam{Param: "sort", Message: fmt.Sprintf("%s is specified more than once", s.Field)})
+		default:
+			seen[s.Field] = true
+			sorts = append(sorts, s)
+		}
+	}
+	return sorts, problems
+}
+
+// sqlOperators 運算子對應的 SQL 比較運算子
+var sqlOperators = map[Operator]string{Eq: "=", Ne: "<>", Gt: ">", Gte: ">=", Lt: "<", Lte: "<="}
+
+// likeEscaper 跳脫 ILIKE 模式中的萬用字元，讓 contains 只做字面比對
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Where 將篩選條件編譯為以 AND 連接的參數化 SQL 條件 (不含 WHERE)，參數依序附加到 args
+// columns 為欄位名稱對應的 SQL 欄位運算式 (由 Repository 提供)；沒有條件時返回空字串
+func (q Query) Where(columns map[string]string, args []interface{}) (string, []interface{}, error) {
+	conditions := []string{}
+	for _, cond := range q.Conditions {
+		column, ok := columns[cond.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("no column mapped for filter field %q", cond.Field)
+		}
+		if cond.Type == TypeDate {
+			column = "(" + column + ")::date"
+		}
+		placeholder := func(value interface{}) string {
+			if t, ok := value.(time.Time); ok && cond.Type == TypeDate {
+				value = t.Format("2006-01-02")
+			}
+			args = append(args, value)
+			if cond.Type == TypeDate {
+				return fmt.Sprintf("$%d::date", len(args))
+			}
+			return fmt.Sprintf("$%d", len(args))
+		}
+
+		switch cond.Operator {
+		case Contains:
+			conditions = append(conditions, fmt.Sprintf("%s ILIKE %s", column, placeholder("%"+likeEscaper.Replace(cond.Value.(string))+"%")))
+		case In:
+			placeholders := []string{}
+			for _, value := range cond.Value.([]interface{}) {
+				placeholders = append(placeholders, placeholder(value))
+			}
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")))
+		default:
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", column, sqlOperators[cond.Operator], placeholder(cond.Value)))
+		}
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// OrderBy 將排序編譯為 ORDER BY 子句 (不含關鍵字)，總是以 tiebreaker 升序收尾，確保分頁順序穩定
+// columns 為排序欄位對應的 SQL 欄位運算式；沒有排序時依 tiebreaker 升序
+func (q Query) OrderBy(columns map[string]string, tiebreaker string) (string, error) {
+	parts := []string{}
+	for _, s := range q.Sorts {
+		column, ok := columns[s.Field]
+		if !ok {
+			return "", fmt.Errorf("no column mapped for sort field %q", s.Field)
+		}
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s NULLS LAST", column, direction))
+	}
+	return strings.Join(append(parts, tiebreaker+" ASC"), ", "), nil
+}
@@ -0,0 +1,111 @@
+package query
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // 與 main 相同，不依賴主機的時區資料
+)
+
+// dstInstants America/New_York 夏令時間切換前後的時間點 (以當地時間表示)：
+// 2024-03-10 02:00 EST 撥快為 03:00 EDT，2024-11-03 02:00 EDT 撥慢為 01:00 EST (01:30 出現兩次)
+func dstInstants(t *testing.T) (*time.Location, []time.Time) {
+	t.Helper()
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	est, edt := time.FixedZone("EST", -5*3600), time.FixedZone("EDT", -4*3600)
+	return newYork, []time.Time{
+		time.Date(2024, 3, 10, 1, 59, 59, 0, est),    // 撥快前最後一秒
+		time.Date(2024, 3, 10, 3, 0, 0, 0, edt),      // 撥快後第一秒 (實際只相隔 1 秒)
+		time.Date(2024, 11, 3, 1, 30, 0, 0, edt),     // 第一次 01:30
+		time.Date(2024, 11, 3, 1, 30, 0, 0, est),     // 第二次 01:30 (晚一小時)
+		time.Date(2024, 11, 3, 23, 30, 0, 0, est),    // 當地日期與 UTC 日期不同
+		time.Date(2024, 7, 1, 12, 0, 0, 123000, edt), // 一般的夏令時間，含微秒
+	}
+}
+
+// TestTimestampRoundTripAcrossDST API 的時間以 UTC 輸出 (JSON 帶 "Z")，以帶時差的 RFC 3339 傳回的篩選值與原本的時間點相同；
+// 轉為顯示時區 (X-Display-Timezone) 後得到原本的當地時間與時區縮寫，夏令時間切換前後與重複的 01:30 都不會混淆
+func TestTimestampRoundTripAcrossDST(t *testing.T) {
+	newYork, instants := dstInstants(t)
+	for _, instant := range instants {
+		encoded, err := json.Marshal(instant.UTC())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(string(encoded), `Z"`) {
+			t.Errorf("JSON of %v = %s, want a UTC timestamp ending in Z", instant, encoded)
+		}
+		var decoded time.Time
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		local := decoded.In(newYork)
+		wantZone, _ := instant.Zone()
+		if gotZone, _ := local.Zone(); !decoded.Equal(instant) || gotZone != wantZone ||
+			local.Format("2006-01-02 15:04:05.999999") != instant.Format("2006-01-02 15:04:05.999999") {
+			t.Errorf("%v round-tripped to %v (%s in America/New_York)", instant, decoded, local)
+		}
+
+		// 前端以當地時間 (帶時差) 送回篩選值
+		q, customErr := Parse(url.Values{"created_at_gte": {instant.Format(time.RFC3339Nano)}}, Spec{
+			Fields: map[string]Field{"created_at": {Type: TypeTime, Operators: Range}},
+		})
+		if customErr != nil {
+			t.Fatalf("Parse(%s): %v", instant.Format(time.RFC3339Nano), customErr)
+		}
+		if parsed := q.Conditions[0].Value.(time.Time); !parsed.Equal(instant) {
+			t.Errorf("created_at_gte=%s parsed as %v, want %v", instant.Format(time.RFC3339Nano), parsed, instant)
+		}
+	}
+}
+
+// TestWhereTimeFiltersAcrossDST created_at 的範圍篩選比較時間點而非當地時間：撥快前後相隔 1 秒的兩個時間點與重複的 01:30 都能區分；
+// 日期篩選以 UTC 的日期比較，參數只保留日期
+func TestWhereTimeFiltersAcrossDST(t *testing.T) {
+	_, instants := dstInstants(t)
+	spec := Spec{Fields: map[string]Field{
+		"created_at": {Type: TypeTime, Operators: Range},
+		"created_on": {Type: TypeDate, Operators: Range},
+	}}
+	q, customErr := Parse(url.Values{
+		"created_at_gt":  {instants[0].Format(time.RFC3339)}, // 2024-03-10T01:59:59-05:00
+		"created_at_lte": {instants[3].Format(time.RFC3339)}, // 2024-11-03T01:30:00-05:00
+		"created_on_lt":  {"2024-11-04"},
+	}, spec)
+	if customErr != nil {
+		t.Fatal(customErr)
+	}
+	where, args, err := q.Where(map[string]string{"created_at": "cu.created_at", "created_on": "cu.created_at"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "cu.created_at > $1 AND cu.created_at <= $2 AND (cu.created_at)::date < $3::date"; where != want {
+		t.Errorf("Where = %q, want %q", where, want)
+	}
+	if args[2] != "2024-11-04" {
+		t.Errorf("date argument = %v, want 2024-11-04", args[2])
+	}
+
+	after, through := args[0].(time.Time), args[1].(time.Time)
+	var matched []int
+	for i, instant := range instants {
+		if instant.After(after) && !instant.After(through) { // 與 SQL 的 > 與 <= 相同
+			matched = append(matched, i)
+		}
+	}
+	if want := []int{1, 2, 3, 5}; !reflect.DeepEqual(matched, want) {
+		t.Errorf("instants in range = %v, want %v", matched, want)
+	}
+	if gap := instants[1].Sub(instants[0]); gap != time.Second {
+		t.Errorf("spring-forward gap = %v, want 1s", gap)
+	}
+	if gap := instants[3].Sub(instants[2]); gap != time.Hour {
+		t.Errorf("repeated 01:30 gap = %v, want 1h", gap)
+	}
+}
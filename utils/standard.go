@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// standardNumberPattern 標準編號部分：英數字開頭，可含 "." "-" "/" ":"，例如 "933"、"898-1"、"B18.2.1"
+var standardNumberPattern = regexp.MustCompile(`^[0-9A-Z][0-9A-Z.\-/:]*$`)
+
+// canonicalStandard 將標準代號轉為大寫，並將連續空白壓縮為單一空白
+func canonicalStandard(standard string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(standard)), " ")
+}
+
+// NormalizeStandard 將標準代號正規化為 "<標準組織> <編號>" (例如 "din933" => "DIN 933")
+// 標準組織必須是 bodies 中的其中一個，編號必須包含數字；空字串返回空字串
+func NormalizeStandard(standard string, bodies []string) (string, error) {
+	canonical := canonicalStandard(standard)
+	if canonical == "" {
+		return "", nil
+	}
+
+	// 依長度由長到短比對，避免較短的組織代號 (例如 "EN") 誤判較長的
+	sorted := append([]string{}, bodies...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, body := range sorted {
+		body = strings.ToUpper(body)
+		if !strings.HasPrefix(canonical, body) {
+			continue
+		}
+		number := strings.TrimPrefix(canonical[len(body):], " ")
+		if standardNumberPattern.MatchString(number) && strings.ContainsAny(number, "0123456789") {
+			return body + " " + number, nil
+		}
+	}
+	return "", fmt.Errorf("invalid standard designation: %q", standard)
+}
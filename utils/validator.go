@@ -1,18 +1,66 @@
 package utils
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh_Hant_TW"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	zh_tw_translations "github.com/go-playground/validator/v10/translations/zh_tw"
 	"github.com/labstack/echo/v4"
+	"github.com/wac0705/fastener-api/decimal"
 )
 
-// CustomValidator 結構體，包裝 go-playground/validator 實例
+// CustomValidator 結構體，包裝 go-playground/validator 實例與驗證錯誤訊息的翻譯器
 type CustomValidator struct {
-	validator *validator.Validate
+	validator   *validator.Validate
+	translators *ut.UniversalTranslator
 }
 
 // NewCustomValidator 創建一個新的 CustomValidator 實例
+// decimal.Decimal 欄位以其數值參與驗證，因此可以直接使用 required、min、gt 等標籤
+// 驗證錯誤的欄位名稱使用 JSON 標籤，錯誤訊息提供英文與繁體中文 (見 TranslateValidationErrors)
 func NewCustomValidator() *CustomValidator {
-	return &CustomValidator{validator: validator.New()}
+	v := validator.New()
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(decimal.Decimal).Float64()
+	}, decimal.Decimal{})
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	enLocale := en.New()
+	translators := ut.New(enLocale, enLocale, zh_Hant_TW.New())
+	enTrans, _ := translators.GetTranslator(enLocale.Locale())
+	zhTrans, _ := translators.GetTranslator(zh_Hant_TW.New().Locale())
+	if err := en_translations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		panic(err) // 內建的翻譯只在程式錯誤時註冊失敗
+	}
+	if err := zh_tw_translations.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		panic(err)
+	}
+	// 沒有登記翻譯的規則 (例如 iso4217) 使用的通用訊息
+	if err := enTrans.Add("validation_failed", "{0} is invalid", false); err != nil {
+		panic(err)
+	}
+	if err := zhTrans.Add("validation_failed", "{0}格式不正確", false); err != nil {
+		panic(err)
+	}
+	return &CustomValidator{validator: v, translators: translators}
 }
 
 // Validate 實現 Echo 的 Validator 介面
@@ -27,14 +75,180 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return nil
 }
 
-// 你可以在這裡添加自定義的驗證規則，例如：
-/*
-func (cv *CustomValidator) RegisterCustomValidations() {
-    // 註冊一個自定義的日期格式驗證器
-    cv.validator.RegisterValidation("date_format", func(fl validator.FieldLevel) bool {
-        dateStr := fl.Field().String()
-        _, err := time.Parse("2006-01-02", dateStr) // 例如，驗證 "YYYY-MM-DD" 格式
-        return err == nil
-    })
+// 密碼政策 (password 規則)：長度以字元計算下限，以位元組計算上限 (bcrypt 只接受 72 位元組以內的密碼)
+const (
+	MinPasswordLength     = 6
+	MaxPasswordByteLength = 72
+)
+
+// RegisterCustomValidations 註冊專案自定義的驗證規則
+//   - currency: ISO 4217 幣別代碼 (必須為大寫，例如 "USD")
+//   - payment_terms: 付款條件，必須是 paymentTerms 中的其中一個 (例如 "NET30")
+//   - phone: 電話號碼，移除分隔字元後為 7 到 15 位數字，可帶前綴 "+"
+//   - password: 符合密碼政策 (至少 MinPasswordLength 個字元、最多 MaxPasswordByteLength 位元組)
+//
+// 由 server.New 在設定 Echo 的 Validator 時呼叫；未註冊時使用這些規則的標籤會在驗證時 panic
+func (cv *CustomValidator) RegisterCustomValidations(paymentTerms []string) error {
+	if err := cv.validator.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		code := fl.Field().String()
+		return code == strings.ToUpper(code) && cv.validator.Var(code, "iso4217") == nil
+	}); err != nil {
+		return err
+	}
+
+	if err := cv.validator.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return IsValidPhone(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+
+	allowedTerms := make(map[string]bool, len(paymentTerms))
+	for _, term := range paymentTerms {
+		allowedTerms[term] = true
+	}
+	if err := cv.validator.RegisterValidation("payment_terms", func(fl validator.FieldLevel) bool {
+		return allowedTerms[fl.Field().String()]
+	}); err != nil {
+		return err
+	}
+
+	if err := cv.validator.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		password := fl.Field().String()
+		return utf8.RuneCountInString(password) >= MinPasswordLength && len(password) <= MaxPasswordByteLength
+	}); err != nil {
+		return err
+	}
+
+	// 自定義規則的錯誤訊息，{0} 為欄位名稱
+	messages := map[string]map[string]string{
+		"en": {
+			"currency":      "{0} must be an uppercase ISO 4217 currency code",
+			"phone":         "{0} must be a valid phone number",
+			"payment_terms": "{0} must be one of " + strings.Join(paymentTerms, ", "),
+			"password":      fmt.Sprintf("{0} must be at least %d characters and at most %d bytes long", MinPasswordLength, MaxPasswordByteLength),
+		},
+		"zh_Hant_TW": {
+			"currency":      "{0}必須是大寫的 ISO 4217 幣別代碼",
+			"phone":         "{0}必須是有效的電話號碼",
+			"payment_terms": "{0}必須是下列其中之一：" + strings.Join(paymentTerms, ", "),
+			"password":      fmt.Sprintf("{0}長度必須至少 %d 個字元，且不超過 %d 位元組", MinPasswordLength, MaxPasswordByteLength),
+		},
+	}
+	for locale, tags := range messages {
+		trans, _ := cv.translators.GetTranslator(locale)
+		for tag, message := range tags {
+			if err := cv.validator.RegisterTranslation(tag, trans, registerMessage(tag, message), translateMessage); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// registerMessage 返回以 message 登記 tag 錯誤訊息的 validator.RegisterTranslationsFunc
+func registerMessage(tag, message string) validator.RegisterTranslationsFunc {
+	return func(trans ut.Translator) error {
+		return trans.Add(tag, message, true)
+	}
+}
+
+// translateMessage 以欄位名稱填入登記的錯誤訊息
+func translateMessage(trans ut.Translator, fe validator.FieldError) string {
+	message, err := trans.T(fe.Tag(), fe.Field())
+	if err != nil {
+		return fe.Error()
+	}
+	return message
+}
+
+// ValidationErrorDetail 一個欄位的驗證錯誤，作為驗證失敗 (400) 的 details
+type ValidationErrorDetail struct {
+	Field   string `json:"field"`           // 欄位的 JSON 名稱，巢狀欄位以 . 連接，例如 addresses[0].city
+	Rule    string `json:"rule"`            // 未通過的驗證規則 (validate 標籤)，例如 min，供程式判斷
+	Param   string `json:"param,omitempty"` // 規則的參數，例如 min=3 的 3
+	Message string `json:"message"`         // 依 Accept-Language 翻譯的錯誤訊息，例如 "username must be at least 3 characters in length"
+}
+
+// validationTranslators 支援的語系對應的驗證錯誤翻譯器 (universal-translator 的語系名稱)
+var validationTranslators = map[string]string{
+	LocaleEnglish:            "en",
+	LocaleTraditionalChinese: "zh_Hant_TW",
+}
+
+// Translator 依 Accept-Language 標頭 (例如 "zh-TW,zh;q=0.9,en;q=0.8") 選擇翻譯器，沒有支援的語言時使用預設語系 (DEFAULT_LOCALE)
+func (cv *CustomValidator) Translator(acceptLanguage string) ut.Translator {
+	trans, _ := cv.translators.FindTranslator(validationTranslators[NegotiateLocale(acceptLanguage)], "en")
+	return trans
+}
+
+// Translate 將驗證錯誤轉換為帶有翻譯訊息的 ValidationErrorDetail 列表，順序與驗證錯誤相同
+func (cv *CustomValidator) Translate(errs validator.ValidationErrors, acceptLanguage string) []ValidationErrorDetail {
+	trans := cv.Translator(acceptLanguage)
+	details := make([]ValidationErrorDetail, 0, len(errs))
+	for _, fe := range errs {
+		message := fe.Translate(trans)
+		if message == fe.Error() { // 沒有登記翻譯的規則
+			message, _ = trans.T("validation_failed", fe.Field())
+		}
+		details = append(details, ValidationErrorDetail{Field: fieldPath(fe), Rule: fe.Tag(), Param: fe.Param(), Message: message})
+	}
+	return details
+}
+
+// fieldPath 以 JSON 名稱表示的欄位路徑 (去除最外層的結構名稱)，例如 CreateCustomerRequest.addresses[0].city => addresses[0].city
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
+
+// preferredLocales 依 q 值由高到低返回 Accept-Language 中支援的語系 (見 supportedLocales)
+func preferredLocales(acceptLanguage string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	candidates := []weighted{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+		locale, ok := supportedLocales[tag]
+		if !ok {
+			primary, _, _ := strings.Cut(tag, "-")
+			locale, ok = supportedLocales[primary]
+		}
+		if ok && q > 0 {
+			candidates = append(candidates, weighted{locale: locale, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	locales := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		locales = append(locales, candidate.locale)
+	}
+	return locales
+}
+
+// TranslateValidationErrors 以請求的 Accept-Language 翻譯驗證錯誤 (Echo 的 Validator 為 CustomValidator 時)
+// 其他 Validator 只返回欄位與規則，訊息為原始的錯誤字串
+func TranslateValidationErrors(c echo.Context, errs validator.ValidationErrors) []ValidationErrorDetail {
+	if cv, ok := c.Echo().Validator.(*CustomValidator); ok {
+		return cv.Translate(errs, c.Request().Header.Get("Accept-Language"))
+	}
+	details := make([]ValidationErrorDetail, 0, len(errs))
+	for _, fe := range errs {
+		details = append(details, ValidationErrorDetail{Field: fieldPath(fe), Rule: fe.Tag(), Param: fe.Param(), Message: fe.Error()})
+	}
+	return details
 }
-*/